@@ -0,0 +1,33 @@
+package tgwrapper
+
+import (
+	"context"
+
+	"github.com/mymmrac/telego"
+
+	"github.com/0xVanfer/tg-listener/core"
+	"github.com/0xVanfer/tg-listener/handler"
+)
+
+// AckCallback wraps a callback handler so the pressed button instantly shows
+// label (core.AckPending if empty) while fn runs. The handler can settle the
+// feedback itself through core.ButtonAckFrom(ctx), e.g. with Done or Replace;
+// otherwise the original keyboard is restored when it returns, also on error.
+// Handlers that change the keyboard should use Replace rather than editing the
+// message directly, or the restore overwrites their keyboard.
+//
+// Example:
+//
+//	wrapper.RegisterCallback("refresh", wrapper.AckCallback("", func(ctx context.Context, q telego.CallbackQuery) error {
+//	    if err := refreshPrices(ctx); err != nil {
+//	        return err
+//	    }
+//	    return core.ButtonAckFrom(ctx).Done(ctx, "✅ Refreshed")
+//	}))
+func (w *Wrapper) AckCallback(label string, fn handler.CallbackHandler) handler.CallbackHandler {
+	return func(ctx context.Context, query telego.CallbackQuery) error {
+		ack := w.bot.AckButton(ctx, query, label)
+		defer func() { _ = ack.Restore(ctx) }()
+		return fn(core.WithButtonAck(ctx, ack), query)
+	}
+}
@@ -0,0 +1,363 @@
+package tgwrapper
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mymmrac/telego"
+
+	"github.com/0xVanfer/tg-listener/config"
+	"github.com/0xVanfer/tg-listener/conv"
+	"github.com/0xVanfer/tg-listener/core"
+	"github.com/0xVanfer/tg-listener/store"
+)
+
+// Store keys for bot-wide settings documents.
+const (
+	botSettingsKey  = "bot_settings"
+	featureFlagsKey = "feature_flags"
+)
+
+// adminCallbackPrefix is the callback data prefix for admin panel buttons.
+const adminCallbackPrefix = "admin:"
+
+// BotSettings returns the persistent bot-wide settings (e.g. maintenance mode).
+func (w *Wrapper) BotSettings() *Settings {
+	return store.NewSettings(w.Store(), botSettingsKey)
+}
+
+// FeatureFlags returns the persistent feature flags.
+// Flags are readable in conditions as flag.<name> and in templates as {{.flag.<name>}}.
+func (w *Wrapper) FeatureFlags() *Settings {
+	return store.NewSettings(w.Store(), featureFlagsKey)
+}
+
+// FlagEnabled returns true if the named feature flag is on.
+func (w *Wrapper) FlagEnabled(ctx context.Context, name string) bool {
+	return w.FeatureFlags().GetBool(ctx, name)
+}
+
+// InMaintenance returns true if maintenance mode is on.
+func (w *Wrapper) InMaintenance(ctx context.Context) bool {
+	return w.maintenance.Load()
+}
+
+// SetMaintenance turns maintenance mode on or off.
+// While on, only operators can interact with the bot; everyone else gets
+// the configured maintenance text.
+func (w *Wrapper) SetMaintenance(ctx context.Context, on bool) error {
+	if err := w.BotSettings().Set(ctx, "maintenance", on); err != nil {
+		return err
+	}
+	w.maintenance.Store(on)
+	return nil
+}
+
+// IsOperator returns true if the user holds the "operator" role: configured as
+// an admin panel operator, listed in the "operator" or "admin" role by ID or
+// username, or given it by the role provider. Inside update handling the
+// sender's roles are used; other users are looked up by ID.
+func (w *Wrapper) IsOperator(ctx context.Context, userID int64) bool {
+	roles := core.RolesFor(ctx, userID)
+	if roles == nil {
+		roles = w.Roles(ctx, userID, "")
+	}
+	return config.HasRole(roles, []string{config.RoleOperator})
+}
+
+// setupAdminState registers the flag namespace and maintenance gate, and
+// restores the persisted maintenance state.
+func (w *Wrapper) setupAdminState() {
+	w.flowEngine.RegisterNamespace("flag", func(ctx context.Context, _ *conv.Conversation) map[string]interface{} {
+		flags, err := w.FeatureFlags().All(ctx)
+		if err != nil {
+			return nil
+		}
+		return flags
+	})
+
+	maintenanceText := ""
+	if w.Config().Admin != nil {
+		maintenanceText = w.Config().Admin.MaintenanceText
+	}
+	w.router.SetMaintenanceCheck(func(ctx context.Context, userID int64) bool {
+		return w.InMaintenance(ctx) && !w.IsOperator(ctx, userID)
+	}, maintenanceText)
+
+	w.maintenance.Store(w.BotSettings().GetBool(context.Background(), "maintenance"))
+}
+
+// Built-in admin panel menus, installed when the admin panel is enabled.
+const (
+	// AdminMenuID is the ID of the admin panel menu.
+	AdminMenuID = "_admin"
+
+	// AdminStatsMenuID is the ID of the admin panel's conversation stats menu.
+	AdminStatsMenuID = "_admin_stats"
+)
+
+// adminMenuText is the admin panel text, rendered from the admin namespace.
+const adminMenuText = `<b>🛠 Admin Panel</b>
+
+<b>Maintenance:</b> {{if .admin.maintenance}}ON{{else}}OFF{{end}}
+<b>Active conversations:</b> {{.admin.conversations}}
+<b>Uptime:</b> {{.admin.uptime}}`
+
+// adminStatsMenuText is the conversation stats text, rendered from the admin namespace.
+const adminStatsMenuText = `<b>📊 Conversation Stats</b>
+
+<b>Active conversations:</b> {{.admin.conversations}}
+<b>Uptime:</b> {{.admin.uptime}}{{if .admin.flows}}
+
+<b>By flow</b>{{range $id, $n := .admin.flows}}
+<b>{{$id}}:</b> {{$n}}{{end}}{{end}}`
+
+// adminMenus returns the configuration of the built-in admin panel menus.
+// Every button is restricted to operators, so the router rejects presses
+// by anyone else.
+func adminMenus(cfg *config.Config) []*config.MenuConfig {
+	operator := []string{config.RoleOperator}
+
+	buttons := [][]config.ButtonConfig{{
+		{Text: "🔧 Maintenance: ON", Callback: adminCallbackPrefix + "maintenance", Condition: "admin.maintenance", Roles: operator},
+		{Text: "🔧 Maintenance: OFF", Callback: adminCallbackPrefix + "maintenance", Condition: "!admin.maintenance", Roles: operator},
+	}}
+
+	// Feature flag toggles, two per row; conditions pick the label of the flag's state
+	var row []config.ButtonConfig
+	for i, name := range cfg.Admin.FeatureFlags {
+		callback := adminCallbackPrefix + "flag:" + name
+		row = append(row,
+			config.ButtonConfig{Text: "✅ " + name, Callback: callback, Condition: "flag." + name, Roles: operator},
+			config.ButtonConfig{Text: "⬜ " + name, Callback: callback, Condition: "!flag." + name, Roles: operator},
+		)
+		if i%2 == 1 || i == len(cfg.Admin.FeatureFlags)-1 {
+			buttons = append(buttons, row)
+			row = nil
+		}
+	}
+
+	buttons = append(buttons,
+		[]config.ButtonConfig{{Text: "📣 Broadcast", FlowID: BroadcastComposerFlowID, Roles: operator}},
+		[]config.ButtonConfig{
+			{Text: "📊 Stats", MenuID: AdminStatsMenuID, Roles: operator},
+			{Text: "🔄 Reload Config", Callback: adminCallbackPrefix + "reload", Roles: operator},
+		},
+	)
+	if cfg.Bot != nil && cfg.Bot.UsageStats.IsEnabled() {
+		buttons = append(buttons, []config.ButtonConfig{{Text: "📈 Usage (24h)", Callback: adminCallbackPrefix + "usage", Roles: operator}})
+	}
+	buttons = append(buttons, []config.ButtonConfig{{Text: "✖️ Close", Callback: adminCallbackPrefix + "close", Roles: operator}})
+
+	return []*config.MenuConfig{
+		{
+			ID:        AdminMenuID,
+			Text:      adminMenuText,
+			Buttons:   buttons,
+			Roles:     operator,
+			ParseMode: "HTML",
+		},
+		{
+			ID:        AdminStatsMenuID,
+			Text:      adminStatsMenuText,
+			Buttons:   [][]config.ButtonConfig{{{Text: "⬅️ Back", MenuID: AdminMenuID, Roles: operator}}},
+			Roles:     operator,
+			ParseMode: "HTML",
+		},
+	}
+}
+
+// setupAdminPanel registers the admin panel commands, the admin namespace
+// read by the panel menus, and the callbacks of the panel's actions if enabled.
+func (w *Wrapper) setupAdminPanel() {
+	admin := w.Config().Admin
+	if admin == nil || !admin.Enabled {
+		return
+	}
+
+	w.flowEngine.RegisterNamespace("admin", func(ctx context.Context, _ *conv.Conversation) map[string]interface{} {
+		flows := make(map[string]interface{})
+		for id, n := range w.convManager.CountByFlow() {
+			flows[id] = n
+		}
+		return map[string]interface{}{
+			"maintenance":   w.InMaintenance(ctx),
+			"conversations": w.convManager.Count(),
+			"uptime":        w.uptime().String(),
+			"flows":         flows,
+		}
+	})
+
+	w.router.RegisterCommand(admin.GetCommand(), func(ctx context.Context, msg telego.Message) error {
+		if !w.IsOperator(ctx, msg.From.ID) {
+			return nil
+		}
+		return w.ShowMenu(ctx, msg.Chat.ID, msg.MessageThreadID, AdminMenuID, 0)
+	})
+
+	w.router.RegisterCommand(admin.GetStuckCommand(), func(ctx context.Context, msg telego.Message) error {
+		if !w.IsOperator(ctx, msg.From.ID) {
+			return nil
+		}
+		text, entities := w.buildStuckList(admin.GetStuckCommand(), strings.Fields(msg.Text)[1:])
+		_, err := w.bot.SendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, text, entities...)
+		return err
+	})
+
+	w.router.RegisterCommand(admin.GetForceCommand(), func(ctx context.Context, msg telego.Message) error {
+		if !w.IsOperator(ctx, msg.From.ID) {
+			return nil
+		}
+		return w.handleForceCommand(ctx, msg, admin.GetForceCommand(), strings.Fields(msg.Text)[1:])
+	})
+
+	w.router.RegisterCommand(admin.GetPreviewCommand(), func(ctx context.Context, msg telego.Message) error {
+		if !w.IsOperator(ctx, msg.From.ID) {
+			return nil
+		}
+		return w.handlePreviewCommand(ctx, msg, admin.GetPreviewCommand(), strings.Fields(msg.Text)[1:])
+	})
+
+	w.registerDiagnostics()
+
+	w.router.RegisterCallbackPrefix(adminCallbackPrefix, w.handleAdminCallback)
+}
+
+// maxStuckListed is the maximum number of conversations listed by the stuck command.
+const maxStuckListed = 50
+
+// FindConversations returns views of the active conversations passing the
+// filter, the longest idle first, e.g. to find users stuck on a step.
+func (w *Wrapper) FindConversations(f conv.Filter) []conv.View {
+	return w.convManager.Find(f)
+}
+
+// buildStuckList renders the users waiting on a flow step, from the command
+// arguments "<flow> <step> [min idle]", e.g. "checkout amount_input 30m".
+func (w *Wrapper) buildStuckList(command string, args []string) (string, []telego.MessageEntity) {
+	b := core.NewBuilder()
+	if len(args) < 2 || len(args) > 3 {
+		b.Text("Usage: ").Code("/" + command + " <flow> <step> [min idle, e.g. 30m]")
+		return b.Build()
+	}
+	f := conv.Filter{FlowID: args[0], StepID: args[1]}
+	if len(args) == 3 {
+		idle, err := time.ParseDuration(args[2])
+		if err != nil {
+			b.Text("❌ Invalid duration: ").Code(args[2])
+			return b.Build()
+		}
+		f.MinIdle = idle
+	}
+
+	views := w.convManager.Find(f)
+	b.Header("🧭 Stuck on " + f.FlowID + "/" + f.StepID)
+	b.KeyValue("Conversations", strconv.Itoa(len(views)))
+	if f.MinIdle > 0 {
+		b.KeyValue("Idle for at least", f.MinIdle.String())
+	}
+	if len(views) == 0 {
+		return b.Build()
+	}
+	b.Ln()
+	for i, v := range views {
+		if i == maxStuckListed {
+			b.Line(fmt.Sprintf("…and %d more", len(views)-maxStuckListed))
+			break
+		}
+		idle := time.Since(v.UpdatedAt).Truncate(time.Second)
+		b.Text("• ").UserMention(strconv.FormatInt(v.UserID, 10), v.UserID)
+		b.Line(fmt.Sprintf(" in %d, idle %s", v.ChatID, idle))
+	}
+	if w.Config().Admin != nil {
+		b.Ln().Text("Unstick with ").Code("/" + w.Config().Admin.GetForceCommand() + " step <user> <chat> <step>")
+	}
+	return b.Build()
+}
+
+// handleAdminCallback runs the actions of the admin panel's buttons.
+func (w *Wrapper) handleAdminCallback(ctx context.Context, query telego.CallbackQuery) error {
+	if !w.IsOperator(ctx, query.From.ID) {
+		return w.bot.AnswerCallbackWithAlert(ctx, query.ID, "⛔ Operators only")
+	}
+
+	chatID := query.Message.GetChat().ID
+	msgID := query.Message.GetMessageID()
+	action := core.ParseCallbackData(query.Data, adminCallbackPrefix)
+
+	switch {
+	case action == "maintenance":
+		on := !w.InMaintenance(ctx)
+		if err := w.SetMaintenance(ctx, on); err != nil {
+			return w.bot.AnswerCallbackWithAlert(ctx, query.ID, "❌ "+err.Error())
+		}
+		_ = w.bot.AnswerCallback(ctx, query.ID, "Maintenance "+onOff(on))
+
+	case strings.HasPrefix(action, "flag:"):
+		name := strings.TrimPrefix(action, "flag:")
+		on, err := w.FeatureFlags().Toggle(ctx, name)
+		if err != nil {
+			return w.bot.AnswerCallbackWithAlert(ctx, query.ID, "❌ "+err.Error())
+		}
+		_ = w.bot.AnswerCallback(ctx, query.ID, name+" "+onOff(on))
+
+	case action == "usage":
+		report, err := w.UsageReport(ctx, time.Now().Add(-24*time.Hour))
+		if err != nil {
+			return w.bot.AnswerCallbackWithAlert(ctx, query.ID, "❌ "+err.Error())
+		}
+		_ = w.bot.AnswerCallback(ctx, query.ID, "")
+		text, entities := report.Build()
+		kb := core.NewKeyboard().Button("⬅️ Back", "menu:"+AdminMenuID).Build()
+		_, err = w.bot.EditMessageWithKeyboard(ctx, chatID, msgID, text, kb, entities...)
+		return err
+
+	case action == "reload":
+		if err := w.reloadConfigFromFile(); err != nil {
+			return w.bot.AnswerCallbackWithAlert(ctx, query.ID, "❌ "+err.Error())
+		}
+		_ = w.bot.AnswerCallback(ctx, query.ID, "✅ Configuration reloaded")
+
+	case action == "close":
+		_ = w.bot.AnswerCallback(ctx, query.ID, "")
+		return w.bot.DeleteMessage(ctx, chatID, msgID)
+
+	default:
+		return w.bot.AnswerCallback(ctx, query.ID, "")
+	}
+
+	// Re-render the panel to reflect the new state
+	return w.ShowMenu(ctx, chatID, 0, AdminMenuID, msgID)
+}
+
+// reloadConfigFromFile reloads the configuration from the file it was loaded from.
+func (w *Wrapper) reloadConfigFromFile() error {
+	path := w.Config().Path()
+	if path == "" {
+		return fmt.Errorf("configuration was not loaded from a file")
+	}
+	cfg, err := config.LoadFromFile(path)
+	if err != nil {
+		return err
+	}
+	return w.ReloadConfig(cfg)
+}
+
+// uptime returns how long the bot has been running, rounded to seconds.
+func (w *Wrapper) uptime() time.Duration {
+	if w.startedAt.IsZero() {
+		return 0
+	}
+	return time.Since(w.startedAt).Round(time.Second)
+}
+
+// onOff formats a boolean state for display.
+func onOff(on bool) string {
+	if on {
+		return "ON"
+	}
+	return "OFF"
+}
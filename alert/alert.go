@@ -0,0 +1,181 @@
+// Package alert tracks critical alerts that must be acknowledged, persisting
+// who acknowledged them and how often they were escalated, so the on-call
+// state survives restarts.
+package alert
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mymmrac/telego"
+
+	"github.com/0xVanfer/tg-listener/store"
+)
+
+// keyPrefix is the store key prefix for alert states.
+const keyPrefix = "alert:"
+
+// ErrNotFound is returned when an alert does not exist.
+var ErrNotFound = errors.New("alert not found")
+
+// Ack records a user acknowledging an alert.
+type Ack struct {
+	UserID int64     `json:"user_id"`
+	Name   string    `json:"name"` // Display name at the time of acknowledgment
+	At     time.Time `json:"at"`
+}
+
+// State is the persisted state of a critical alert.
+type State struct {
+	ID             string                 `json:"id"`
+	ChatID         int64                  `json:"chat_id"`
+	TopicID        int                    `json:"topic_id"`
+	Text           string                 `json:"text"`
+	Entities       []telego.MessageEntity `json:"entities,omitempty"`
+	SentAt         time.Time              `json:"sent_at"`
+	Deadline       time.Duration          `json:"deadline"`        // Time to acknowledge before each escalation
+	Escalations    int                    `json:"escalations"`     // Escalations performed so far
+	MaxEscalations int                    `json:"max_escalations"` // Escalations before giving up
+	NextAt         time.Time              `json:"next_at"`         // Time of the next escalation
+	Acks           []Ack                  `json:"acks,omitempty"`
+}
+
+// Acknowledged returns true if anyone acknowledged the alert.
+func (s *State) Acknowledged() bool {
+	return len(s.Acks) > 0
+}
+
+// Pending returns true if the alert still awaits acknowledgment and escalation.
+func (s *State) Pending() bool {
+	return !s.Acknowledged() && s.Escalations < s.MaxEscalations
+}
+
+// AckedBy returns true if the user acknowledged the alert.
+func (s *State) AckedBy(userID int64) bool {
+	for _, a := range s.Acks {
+		if a.UserID == userID {
+			return true
+		}
+	}
+	return false
+}
+
+// Tracker persists alert states in a store.
+type Tracker struct {
+	store store.Store // Backing store
+	mu    sync.Mutex  // Serializes read-modify-write cycles
+}
+
+// NewTracker creates an alert tracker persisted in the given store.
+func NewTracker(s store.Store) *Tracker {
+	return &Tracker{store: s}
+}
+
+// NewID returns a random alert ID, short enough for callback data.
+func NewID() (string, error) {
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// Save stores an alert state, replacing any previous state with the same ID.
+func (t *Tracker) Save(ctx context.Context, s *State) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return store.PutJSON(ctx, t.store, keyPrefix+s.ID, s)
+}
+
+// Get retrieves an alert state.
+// Returns ErrNotFound if the alert does not exist.
+func (t *Tracker) Get(ctx context.Context, id string) (*State, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.get(ctx, id)
+}
+
+// get retrieves an alert state without locking.
+func (t *Tracker) get(ctx context.Context, id string) (*State, error) {
+	var s State
+	if err := store.GetJSON(ctx, t.store, keyPrefix+id, &s); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return &s, nil
+}
+
+// Acknowledge records a user's acknowledgment of an alert.
+// Returns the updated state and false if the user had already acknowledged it.
+func (t *Tracker) Acknowledge(ctx context.Context, id string, userID int64, name string, at time.Time) (*State, bool, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	s, err := t.get(ctx, id)
+	if err != nil {
+		return nil, false, err
+	}
+	if s.AckedBy(userID) {
+		return s, false, nil
+	}
+	s.Acks = append(s.Acks, Ack{UserID: userID, Name: name, At: at})
+	if err := store.PutJSON(ctx, t.store, keyPrefix+id, s); err != nil {
+		return nil, false, err
+	}
+	return s, true, nil
+}
+
+// Escalate records an escalation of a pending alert and schedules the next one.
+// Returns the updated state and false if the alert was acknowledged or fully escalated meanwhile.
+func (t *Tracker) Escalate(ctx context.Context, id string, at time.Time) (*State, bool, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	s, err := t.get(ctx, id)
+	if err != nil {
+		return nil, false, err
+	}
+	if !s.Pending() {
+		return s, false, nil
+	}
+	s.Escalations++
+	s.NextAt = at.Add(s.Deadline)
+	if err := store.PutJSON(ctx, t.store, keyPrefix+id, s); err != nil {
+		return nil, false, err
+	}
+	return s, true, nil
+}
+
+// List returns all alert states.
+func (t *Tracker) List(ctx context.Context) ([]*State, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	keys, err := t.store.List(ctx, keyPrefix)
+	if err != nil {
+		return nil, err
+	}
+	states := make([]*State, 0, len(keys))
+	for _, key := range keys {
+		s, err := t.get(ctx, strings.TrimPrefix(key, keyPrefix))
+		if errors.Is(err, ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		states = append(states, s)
+	}
+	return states, nil
+}
+
+// Delete removes an alert state.
+func (t *Tracker) Delete(ctx context.Context, id string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.store.Delete(ctx, keyPrefix+id)
+}
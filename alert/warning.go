@@ -0,0 +1,186 @@
+package alert
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/0xVanfer/tg-listener/store"
+)
+
+// warningPrefix is the store key prefix for warning occurrence states.
+const warningPrefix = "warning:"
+
+// Level is how far a warning has escalated.
+type Level int
+
+const (
+	// LevelLog sends the warning to the log chat.
+	LevelLog Level = iota
+
+	// LevelWarning sends the warning to the warning chat.
+	LevelWarning
+
+	// LevelMention sends the warning to the warning chat and mentions admins.
+	LevelMention
+)
+
+// String returns the name of the level.
+func (l Level) String() string {
+	switch l {
+	case LevelWarning:
+		return "warning"
+	case LevelMention:
+		return "mention"
+	default:
+		return "log"
+	}
+}
+
+// Escalation defines how repeated warnings with the same key escalate.
+type Escalation struct {
+	WarningAfter int           // Occurrences before escalating to LevelWarning; 0 starts there
+	MentionAfter time.Duration // Time since the first occurrence before LevelMention; 0 disables
+	Silence      time.Duration // Minimum time between sends at the same level; repeats are only counted
+	ResetAfter   time.Duration // Quiet time after which the chain starts over; 0 never resets
+}
+
+// level returns the escalation level of an occurrence.
+func (e Escalation) level(o *Occurrence, now time.Time) Level {
+	switch {
+	case e.MentionAfter > 0 && now.Sub(o.FirstAt) >= e.MentionAfter:
+		return LevelMention
+	case o.Count >= e.WarningAfter:
+		return LevelWarning
+	default:
+		return LevelLog
+	}
+}
+
+// Occurrence is the persisted state of a deduplicated warning.
+type Occurrence struct {
+	Key           string    `json:"key"`
+	Count         int       `json:"count"`          // Occurrences since the chain started
+	FirstAt       time.Time `json:"first_at"`       // First occurrence of the chain
+	LastAt        time.Time `json:"last_at"`        // Latest occurrence
+	Level         Level     `json:"level"`          // Level of the latest occurrence
+	SentAt        time.Time `json:"sent_at"`        // Latest send
+	SentLevel     Level     `json:"sent_level"`     // Level of the latest send
+	Suppressed    int       `json:"suppressed"`     // Repeats suppressed since the previous send
+	SilencedUntil time.Time `json:"silenced_until"` // Time until which the warning is muted
+}
+
+// Silenced returns true if the warning is muted at the given time.
+func (o *Occurrence) Silenced(now time.Time) bool {
+	return now.Before(o.SilencedUntil)
+}
+
+// Occur records an occurrence of the warning with the given dedup key and decides
+// whether to send it. A warning is sent when it escalated to a higher level than
+// its latest send, or when the silence window since that send has passed, unless
+// it is muted. Returns the updated state and true if the warning should be sent.
+func (t *Tracker) Occur(ctx context.Context, key string, now time.Time, e Escalation) (*Occurrence, bool, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	o, err := t.getWarning(ctx, key)
+	if err != nil {
+		return nil, false, err
+	}
+	if o == nil || (e.ResetAfter > 0 && now.Sub(o.LastAt) >= e.ResetAfter) {
+		var silencedUntil time.Time
+		if o != nil {
+			silencedUntil = o.SilencedUntil
+		}
+		o = &Occurrence{Key: key, FirstAt: now, SentLevel: -1, SilencedUntil: silencedUntil}
+	}
+	o.Count++
+	o.LastAt = now
+	o.Level = e.level(o, now)
+
+	send := !o.Silenced(now) && (o.Level > o.SentLevel || now.Sub(o.SentAt) >= e.Silence)
+	var result Occurrence
+	if send {
+		// The sent state reports the repeats suppressed since the previous send
+		o.SentAt = now
+		o.SentLevel = o.Level
+		result = *o
+		o.Suppressed = 0
+	} else {
+		o.Suppressed++
+		result = *o
+	}
+	if err := store.PutJSON(ctx, t.store, warningPrefix+key, o); err != nil {
+		return nil, false, err
+	}
+	return &result, send, nil
+}
+
+// getWarning retrieves a warning state without locking.
+// Returns nil if the warning has not occurred.
+func (t *Tracker) getWarning(ctx context.Context, key string) (*Occurrence, error) {
+	var o Occurrence
+	if err := store.GetJSON(ctx, t.store, warningPrefix+key, &o); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &o, nil
+}
+
+// Warning retrieves the state of a warning.
+// Returns ErrNotFound if the warning has not occurred.
+func (t *Tracker) Warning(ctx context.Context, key string) (*Occurrence, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	o, err := t.getWarning(ctx, key)
+	if err == nil && o == nil {
+		err = ErrNotFound
+	}
+	return o, err
+}
+
+// Warnings returns the states of all warnings.
+func (t *Tracker) Warnings(ctx context.Context) ([]*Occurrence, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	keys, err := t.store.List(ctx, warningPrefix)
+	if err != nil {
+		return nil, err
+	}
+	states := make([]*Occurrence, 0, len(keys))
+	for _, key := range keys {
+		o, err := t.getWarning(ctx, strings.TrimPrefix(key, warningPrefix))
+		if err != nil {
+			return nil, err
+		}
+		if o != nil {
+			states = append(states, o)
+		}
+	}
+	return states, nil
+}
+
+// Silence mutes a warning until the given time; occurrences are still counted.
+func (t *Tracker) Silence(ctx context.Context, key string, until time.Time) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	o, err := t.getWarning(ctx, key)
+	if err != nil {
+		return err
+	}
+	if o == nil {
+		o = &Occurrence{Key: key, SentLevel: -1}
+	}
+	o.SilencedUntil = until
+	return store.PutJSON(ctx, t.store, warningPrefix+key, o)
+}
+
+// Resolve ends a warning's escalation chain and lifts any silence;
+// its next occurrence starts over.
+func (t *Tracker) Resolve(ctx context.Context, key string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.store.Delete(ctx, warningPrefix+key)
+}
@@ -0,0 +1,261 @@
+package tgwrapper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mymmrac/telego"
+
+	"github.com/0xVanfer/tg-listener/alert"
+	"github.com/0xVanfer/tg-listener/core"
+	"github.com/0xVanfer/tg-listener/quota"
+)
+
+// AlertCallbackPrefix is the callback data prefix of alert acknowledgment buttons.
+const AlertCallbackPrefix = "ack:"
+
+// Defaults for critical alerts.
+const (
+	// DefaultAckButtonText is the label of alert acknowledgment buttons.
+	DefaultAckButtonText = "✅ Acknowledge"
+
+	// DefaultAlertDeadline is the time to acknowledge an alert before it is escalated.
+	DefaultAlertDeadline = 15 * time.Minute
+
+	// DefaultAlertEscalations is the number of escalations before an alert is given up.
+	DefaultAlertEscalations = 3
+)
+
+// CriticalAlert describes an alert that must be acknowledged.
+// Unacknowledged alerts are re-sent to their chat, and pinged to the warning chat
+// if that is a different chat, every Deadline until MaxEscalations is reached.
+type CriticalAlert struct {
+	ID             string        // Alert ID; generated if empty. Reusing an ID replaces the alert
+	ChatID         int64         // Chat to alert; defaults to the warning chat
+	TopicID        int           // Topic to alert in, if ChatID is set
+	Message        *core.Builder // Alert message
+	Deadline       time.Duration // Time to acknowledge before escalating; defaults to DefaultAlertDeadline
+	MaxEscalations int           // Escalations before giving up; defaults to DefaultAlertEscalations
+}
+
+// AlertAckFunc is called when a user acknowledges an alert.
+type AlertAckFunc func(ctx context.Context, state *alert.State, ack alert.Ack)
+
+// alertTimers holds the escalation timers of pending alerts.
+type alertTimers struct {
+	timers map[string]*time.Timer // Escalation timers by alert ID
+	onAck  AlertAckFunc           // User callback for acknowledgments
+	mu     sync.Mutex             // Mutex for thread-safe timer access
+}
+
+// Alerts returns the tracker of critical alert states.
+func (w *Wrapper) Alerts() *alert.Tracker {
+	w.storeMu.RLock()
+	defer w.storeMu.RUnlock()
+	return w.alertStates
+}
+
+// SetOnAlertAcknowledged sets a callback for alert acknowledgments,
+// e.g. to resolve the incident in an external system.
+func (w *Wrapper) SetOnAlertAcknowledged(fn AlertAckFunc) {
+	w.alerts.mu.Lock()
+	defer w.alerts.mu.Unlock()
+	w.alerts.onAck = fn
+}
+
+// SendCriticalAlert sends an alert with an acknowledgment button and escalates
+// it until someone acknowledges it. Acknowledgments and escalations are persisted
+// in the store, and pending escalations resume after a restart.
+func (w *Wrapper) SendCriticalAlert(ctx context.Context, a CriticalAlert) (*alert.State, error) {
+	if a.Message == nil {
+		return nil, fmt.Errorf("alert message cannot be nil")
+	}
+	chatID, topicID := a.ChatID, a.TopicID
+	if chatID == 0 {
+		if !w.Config().Bot.HasWarningChat() {
+			return nil, fmt.Errorf("alert has no chat and no warning chat is configured")
+		}
+		chatID, topicID = w.Config().Bot.WarningChat.ChatID, w.Config().Bot.WarningChat.TopicID
+	}
+	id := a.ID
+	if id == "" {
+		var err error
+		if id, err = alert.NewID(); err != nil {
+			return nil, err
+		}
+	}
+	deadline := a.Deadline
+	if deadline <= 0 {
+		deadline = DefaultAlertDeadline
+	}
+	maxEscalations := a.MaxEscalations
+	if maxEscalations <= 0 {
+		maxEscalations = DefaultAlertEscalations
+	}
+
+	text, entities := a.Message.Build()
+	if _, err := w.bot.SendMessageWithKeyboard(ctx, chatID, topicID, text, alertKeyboard(id), entities...); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	state := &alert.State{
+		ID:             id,
+		ChatID:         chatID,
+		TopicID:        topicID,
+		Text:           text,
+		Entities:       entities,
+		SentAt:         now,
+		Deadline:       deadline,
+		MaxEscalations: maxEscalations,
+		NextAt:         now.Add(deadline),
+	}
+	if err := w.Alerts().Save(ctx, state); err != nil {
+		return nil, err
+	}
+	w.scheduleAlert(state)
+	return state, nil
+}
+
+// alertKeyboard returns the acknowledgment keyboard of an alert.
+func alertKeyboard(id string) *telego.InlineKeyboardMarkup {
+	return core.NewKeyboard().Row(core.Button(DefaultAckButtonText, AlertCallbackPrefix+id)).Build()
+}
+
+// scheduleAlert arms the escalation timer of a pending alert.
+func (w *Wrapper) scheduleAlert(s *alert.State) {
+	if !s.Pending() {
+		return
+	}
+	id := s.ID
+	timer := time.AfterFunc(time.Until(s.NextAt), func() {
+		w.escalateAlert(context.Background(), id)
+	})
+
+	w.alerts.mu.Lock()
+	defer w.alerts.mu.Unlock()
+	if w.alerts.timers == nil {
+		w.alerts.timers = make(map[string]*time.Timer)
+	}
+	if old, ok := w.alerts.timers[id]; ok {
+		old.Stop()
+	}
+	w.alerts.timers[id] = timer
+}
+
+// cancelAlert stops the escalation timer of an alert.
+func (w *Wrapper) cancelAlert(id string) {
+	w.alerts.mu.Lock()
+	defer w.alerts.mu.Unlock()
+	if timer, ok := w.alerts.timers[id]; ok {
+		timer.Stop()
+		delete(w.alerts.timers, id)
+	}
+}
+
+// escalateAlert re-sends an unacknowledged alert and pings the warning chat.
+func (w *Wrapper) escalateAlert(ctx context.Context, id string) {
+	s, ok, err := w.Alerts().Escalate(ctx, id, time.Now())
+	if err != nil || !ok {
+		w.cancelAlert(id)
+		return
+	}
+
+	b := core.NewBuilder().
+		Bold(fmt.Sprintf("⏰ Unacknowledged for %s", quota.FormatDuration(time.Since(s.SentAt)))).
+		Ln().Ln().
+		Append(s.Text, s.Entities)
+	text, entities := b.Build()
+	_, _ = w.bot.SendMessageWithKeyboard(ctx, s.ChatID, s.TopicID, text, alertKeyboard(id), entities...)
+
+	if w.Config().Bot.HasWarningChat() && w.Config().Bot.WarningChat.ChatID != s.ChatID {
+		warn := w.Config().Bot.WarningChat
+		b = core.NewBuilder().
+			Bold(fmt.Sprintf("🚨 Alert unacknowledged (escalation %d/%d)", s.Escalations, s.MaxEscalations)).
+			Ln().Ln().
+			Append(s.Text, s.Entities)
+		text, entities = b.Build()
+		_, _ = w.bot.SendMessageWithKeyboard(ctx, warn.ChatID, warn.TopicID, text, alertKeyboard(id), entities...)
+	}
+
+	if s.Pending() {
+		w.scheduleAlert(s)
+	} else {
+		w.cancelAlert(id)
+	}
+}
+
+// resumeAlerts re-arms the escalation timers of alerts pending in the store.
+func (w *Wrapper) resumeAlerts(ctx context.Context) {
+	states, err := w.Alerts().List(ctx)
+	if err != nil {
+		return
+	}
+	for _, s := range states {
+		w.scheduleAlert(s)
+	}
+}
+
+// userDisplayName returns a user's full name, or their @username if they have none.
+func userDisplayName(u telego.User) string {
+	name := strings.TrimSpace(u.FirstName + " " + u.LastName)
+	if name == "" {
+		name = "@" + u.Username
+	}
+	return name
+}
+
+// setupAlerts registers the alert acknowledgment callback.
+func (w *Wrapper) setupAlerts() {
+	w.router.RegisterCallbackPrefix(AlertCallbackPrefix, func(ctx context.Context, query telego.CallbackQuery) error {
+		id := core.ParseCallbackData(query.Data, AlertCallbackPrefix)
+		s, added, err := w.Alerts().Acknowledge(ctx, id, query.From.ID, userDisplayName(query.From), time.Now())
+		if errors.Is(err, alert.ErrNotFound) {
+			return w.bot.AnswerCallback(ctx, query.ID, "This alert no longer exists.")
+		}
+		if err != nil {
+			_ = w.bot.AnswerCallback(ctx, query.ID, "")
+			return err
+		}
+		if !added {
+			return w.bot.AnswerCallback(ctx, query.ID, "You already acknowledged this alert.")
+		}
+		_ = w.bot.AnswerCallback(ctx, query.ID, "Acknowledged")
+		w.cancelAlert(id)
+
+		// Show who acknowledged on the clicked message
+		if query.Message != nil {
+			b := core.NewBuilder().Append(s.Text, s.Entities).Ln().Ln().Text("✅ Acknowledged by ")
+			for i, ack := range s.Acks {
+				if i > 0 {
+					b.Text(", ")
+				}
+				b.UserMention(ack.Name, ack.UserID)
+			}
+			text, entities := b.Build()
+			_, _ = w.bot.EditMessageWithKeyboard(ctx, query.Message.GetChat().ID, query.Message.GetMessageID(), text, alertKeyboard(id), entities...)
+		}
+
+		w.alerts.mu.Lock()
+		fn := w.alerts.onAck
+		w.alerts.mu.Unlock()
+		if fn != nil {
+			fn(ctx, s, s.Acks[len(s.Acks)-1])
+		}
+		return nil
+	})
+}
+
+// stopAlerts stops all escalation timers; they resume from the store on the next Start.
+func (w *Wrapper) stopAlerts() {
+	w.alerts.mu.Lock()
+	defer w.alerts.mu.Unlock()
+	for id, timer := range w.alerts.timers {
+		timer.Stop()
+		delete(w.alerts.timers, id)
+	}
+}
@@ -0,0 +1,118 @@
+package tgwrapper
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/0xVanfer/tg-listener/alert"
+	"github.com/0xVanfer/tg-listener/anomaly"
+	"github.com/0xVanfer/tg-listener/config"
+	"github.com/0xVanfer/tg-listener/core"
+)
+
+// apiSpikeWebhookTimeout bounds the POST of an API error spike to the webhook.
+const apiSpikeWebhookTimeout = 10 * time.Second
+
+// APIErrorSpikeFunc is called when the rate of a kind of Telegram API error
+// crosses its threshold in bot.api_anomalies.
+type APIErrorSpikeFunc func(ctx context.Context, spike anomaly.Spike)
+
+// OnAPIErrorSpike sets a callback function that is called when the rate of a
+// kind of Telegram API error crosses its threshold, e.g. to page an operator.
+// The spike is still posted to the warning chat and the configured webhook.
+func (w *Wrapper) OnAPIErrorSpike(fn APIErrorSpikeFunc) {
+	w.onAPIErrorSpike = fn
+}
+
+// anomalySettings converts the API anomaly configuration for the detector.
+func anomalySettings(cfg *config.APIAnomalyConfig) anomaly.Settings {
+	thresholds := make(map[anomaly.Kind]float64)
+	for kind, rate := range cfg.GetThresholds() {
+		thresholds[anomaly.Kind(kind)] = rate
+	}
+	return anomaly.Settings{
+		Window:      cfg.GetWindow(),
+		MinRequests: cfg.GetMinRequests(),
+		Cooldown:    cfg.GetCooldown(),
+		Thresholds:  thresholds,
+	}
+}
+
+// apiRequested counts a request attempt for API anomaly detection and the
+// admin stats. The bot's own log and warning posts aren't counted. Spikes are
+// only reported if bot.api_anomalies is set.
+func (w *Wrapper) apiRequested(ctx context.Context, chatID int64, err error) {
+	if ctx.Value(logCtxKey{}) != nil {
+		return
+	}
+	spike := w.anomalies.Observe(time.Now(), err)
+	if spike == nil || w.Config().Bot.APIAnomalies == nil {
+		return
+	}
+	go w.reportAPISpike(context.WithoutCancel(ctx), *spike)
+}
+
+// reportAPISpike logs a spike, calls the spike hook, posts the spike to the
+// webhook, and sends it to the warning chat.
+func (w *Wrapper) reportAPISpike(ctx context.Context, spike anomaly.Spike) {
+	log.Printf("[API] %s error spike: %d of %d requests within %s (%.0f%%): %s",
+		spike.Kind, spike.Errors, spike.Requests, spike.Window, spike.Rate*100, spike.LastError)
+	if fn := w.onAPIErrorSpike; fn != nil {
+		fn(ctx, spike)
+	}
+	if url := w.Config().Bot.APIAnomalies.WebhookURL; url != "" {
+		if err := postAPISpike(ctx, url, spike); err != nil {
+			log.Printf("[API] Failed to post error spike to webhook: %v", err)
+		}
+	}
+
+	chat := w.warningChat(alert.LevelWarning)
+	if chat == nil {
+		return
+	}
+	msg := core.NewBuilder().
+		Text("🚨 Telegram API error spike: ").Code(string(spike.Kind)).Ln().
+		Text(fmt.Sprintf("%d of %d requests within %s failed (%.0f%%, threshold %.0f%%)",
+			spike.Errors, spike.Requests, spike.Window, spike.Rate*100, spike.Threshold*100)).Ln().
+		Pre(spike.LastError, "")
+	text, entities := msg.Build()
+	ctx = context.WithValue(ctx, logCtxKey{}, true)
+	if _, err := w.bot.SendMessage(ctx, chat.ChatID, chat.TopicID, text, entities...); err != nil {
+		log.Printf("[API] Failed to send error spike warning: %v", err)
+	}
+}
+
+// postAPISpike posts a spike as JSON to a webhook.
+func postAPISpike(ctx context.Context, url string, spike anomaly.Spike) error {
+	body, err := json.Marshal(spike)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, apiSpikeWebhookTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("webhook responded %s", resp.Status)
+	}
+	return nil
+}
+
+// setupAnomalies watches Telegram API error rates.
+func (w *Wrapper) setupAnomalies() {
+	w.anomalies = anomaly.NewDetector(anomalySettings(w.Config().Bot.APIAnomalies))
+	w.bot.SetRequestObserver(w.apiRequested)
+}
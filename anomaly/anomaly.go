@@ -0,0 +1,189 @@
+// Package anomaly detects spikes in Telegram API error rates, e.g. flood
+// bans, outages, mass blocking, or a revoked token, by comparing the share of
+// failed requests of each kind within a sliding window against thresholds.
+package anomaly
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	ta "github.com/mymmrac/telego/telegoapi"
+
+	"github.com/0xVanfer/tg-listener/core"
+)
+
+// Kind is a kind of failed request.
+type Kind string
+
+const (
+	// KindRateLimited is a request rejected with 429 Too Many Requests.
+	KindRateLimited Kind = "rate_limited"
+
+	// KindServer is a request failed with a 5xx Telegram server error.
+	KindServer Kind = "server"
+
+	// KindBlocked is a request to a chat that blocked or removed the bot.
+	KindBlocked Kind = "blocked"
+
+	// KindUnauthorized is a request rejected with 401 Unauthorized, usually
+	// because the bot token was revoked.
+	KindUnauthorized Kind = "unauthorized"
+
+	// KindNetwork is a request that got no response from Telegram.
+	KindNetwork Kind = "network"
+)
+
+// Classify returns the kind of a failed request, or "" for successful
+// requests, canceled requests, and other errors such as bad requests.
+func Classify(err error) Kind {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return ""
+	}
+	if core.IsBlockedError(err) {
+		return KindBlocked
+	}
+	var apiErr *ta.Error
+	if !errors.As(err, &apiErr) {
+		return KindNetwork
+	}
+	switch {
+	case apiErr.ErrorCode == http.StatusTooManyRequests:
+		return KindRateLimited
+	case apiErr.ErrorCode == http.StatusUnauthorized:
+		return KindUnauthorized
+	case apiErr.ErrorCode >= http.StatusInternalServerError:
+		return KindServer
+	}
+	return ""
+}
+
+// Settings are the thresholds of a Detector.
+type Settings struct {
+	Window      time.Duration    // Sliding window error rates are computed over
+	MinRequests int              // Requests needed in the window before a spike fires
+	Cooldown    time.Duration    // Minimum time between spikes of the same kind
+	Thresholds  map[Kind]float64 // Share of failed requests (0-1) that fires a spike, by kind; missing kinds are ignored
+}
+
+// Spike is an error rate of a kind that crossed its threshold.
+type Spike struct {
+	Kind      Kind          `json:"kind"`
+	At        time.Time     `json:"at"`
+	Window    time.Duration `json:"window"`
+	Requests  int           `json:"requests"`  // Requests in the window
+	Errors    int           `json:"errors"`    // Requests of the kind that failed in the window
+	Rate      float64       `json:"rate"`      // Errors / Requests
+	Threshold float64       `json:"threshold"` // Rate that fires a spike of the kind
+	LastError string        `json:"last_error"`
+}
+
+// second counts the requests of one second.
+type second struct {
+	at     int64        // Unix second
+	total  int          // Requests
+	errors map[Kind]int // Failed requests by kind
+}
+
+// Detector counts requests and their failures in per-second buckets and
+// reports spikes. It is safe for concurrent use.
+type Detector struct {
+	settings Settings           // Window and thresholds
+	seconds  []second           // Buckets within the window, oldest first
+	fired    map[Kind]time.Time // Time the last spike of each kind fired
+	mu       sync.Mutex         // Mutex for thread-safe bucket access
+}
+
+// NewDetector creates a detector with the given settings.
+func NewDetector(s Settings) *Detector {
+	return &Detector{
+		settings: s,
+		fired:    make(map[Kind]time.Time),
+	}
+}
+
+// SetSettings replaces the thresholds. Counted requests are kept.
+func (d *Detector) SetSettings(s Settings) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.settings = s
+}
+
+// Observe counts a request that ended with err, nil for success, and returns
+// the spike it caused, if any. A kind fires at most once per cooldown.
+func (d *Detector) Observe(now time.Time, err error) *Spike {
+	kind := Classify(err)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.trim(now)
+	sec := now.Unix()
+	if n := len(d.seconds); n == 0 || d.seconds[n-1].at != sec {
+		d.seconds = append(d.seconds, second{at: sec})
+	}
+	b := &d.seconds[len(d.seconds)-1]
+	b.total++
+	if kind == "" {
+		return nil
+	}
+	if b.errors == nil {
+		b.errors = make(map[Kind]int)
+	}
+	b.errors[kind]++
+
+	threshold := d.settings.Thresholds[kind]
+	if threshold <= 0 {
+		return nil
+	}
+	requests, errs := 0, 0
+	for _, s := range d.seconds {
+		requests += s.total
+		errs += s.errors[kind]
+	}
+	rate := float64(errs) / float64(requests)
+	if requests < d.settings.MinRequests || rate < threshold {
+		return nil
+	}
+	if last, ok := d.fired[kind]; ok && now.Sub(last) < d.settings.Cooldown {
+		return nil
+	}
+	d.fired[kind] = now
+	return &Spike{
+		Kind:      kind,
+		At:        now,
+		Window:    d.settings.Window,
+		Requests:  requests,
+		Errors:    errs,
+		Rate:      rate,
+		Threshold: threshold,
+		LastError: err.Error(),
+	}
+}
+
+// Counts returns the requests counted within the window and the failed ones
+// by kind.
+func (d *Detector) Counts(now time.Time) (requests int, errors map[Kind]int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.trim(now)
+	errors = make(map[Kind]int)
+	for _, s := range d.seconds {
+		requests += s.total
+		for kind, n := range s.errors {
+			errors[kind] += n
+		}
+	}
+	return requests, errors
+}
+
+// trim drops the buckets that left the window.
+func (d *Detector) trim(now time.Time) {
+	cutoff := now.Add(-d.settings.Window).Unix()
+	i := 0
+	for i < len(d.seconds) && d.seconds[i].at <= cutoff {
+		i++
+	}
+	d.seconds = d.seconds[i:]
+}
@@ -0,0 +1,98 @@
+package tgwrapper
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/0xVanfer/tg-listener/config"
+	"github.com/0xVanfer/tg-listener/conv"
+	"github.com/0xVanfer/tg-listener/handler"
+	"github.com/0xVanfer/tg-listener/menu"
+	"github.com/0xVanfer/tg-listener/scheduler"
+)
+
+// bindingPruneJob is the ID of the recurring job deleting expired keyboard bindings.
+const bindingPruneJob = "_bindings"
+
+// BindMessage binds the keyboard of a message to a user, so other users'
+// presses get the not_your_menu_text alert. Menus and flows with bind_user
+// are bound automatically. Bindings are persisted in the store.
+func (w *Wrapper) BindMessage(ctx context.Context, chatID int64, messageID int, userID int64) error {
+	return w.router.BindMessage(ctx, chatID, messageID, userID)
+}
+
+// UnbindMessage lets every user press the keyboard of a message again.
+func (w *Wrapper) UnbindMessage(ctx context.Context, chatID int64, messageID int) error {
+	return w.router.UnbindMessage(ctx, chatID, messageID)
+}
+
+// bindMessage binds a keyboard to a user, logging failures.
+func (w *Wrapper) bindMessage(ctx context.Context, chatID int64, messageID int, userID int64) {
+	if err := w.router.BindMessage(ctx, chatID, messageID, userID); err != nil {
+		log.Printf("[Binding] Failed to bind message %d in %d: %v", messageID, chatID, err)
+	}
+}
+
+// unbindMessage releases a keyboard, logging failures.
+func (w *Wrapper) unbindMessage(ctx context.Context, chatID int64, messageID int) {
+	if err := w.router.UnbindMessage(ctx, chatID, messageID); err != nil {
+		log.Printf("[Binding] Failed to unbind message %d in %d: %v", messageID, chatID, err)
+	}
+}
+
+// pruneBindings deletes expired keyboard bindings from the store.
+func (w *Wrapper) pruneBindings(ctx context.Context) {
+	n, err := w.router.PruneBindings(ctx)
+	if err != nil {
+		log.Printf("[Binding] Failed to prune: %v", err)
+		return
+	}
+	if n > 0 && w.Config().Bot.Debug {
+		log.Printf("[Binding] Pruned %d expired keyboard bindings", n)
+	}
+}
+
+// setupBindings persists keyboard bindings in the store and prunes expired ones hourly.
+func (w *Wrapper) setupBindings() {
+	w.router.SetBindingStore(w.Store())
+	w.scheduler.Add(bindingPruneJob, scheduler.Every(time.Hour), w.pruneBindings)
+}
+
+// menuShown binds a shown menu to the user who opened it if the menu has
+// bind_user or is shown in a fork, and releases the message otherwise. Menus
+// shown outside update handling have no user to bind to. Menus with pin are
+// pinned unless shown in a fork.
+func (w *Wrapper) menuShown(ctx context.Context, chatID int64, messageID int, m *menu.Menu) {
+	if w.forkShown(ctx, chatID, messageID) {
+		return
+	}
+	w.syncPin(ctx, chatID, messageID, m.Config.Pin)
+	w.setShared(chatID, messageID, m.Config.Fork)
+	if m.Config.BindUser {
+		if sender := handler.Sender(ctx); sender != nil {
+			w.bindMessage(ctx, chatID, messageID, sender.ID)
+			return
+		}
+	}
+	w.unbindMessage(ctx, chatID, messageID)
+}
+
+// bindStepKeyboard binds a step's keyboard message to the conversation's user
+// if the flow or keyboard has bind_user or the flow was started from a shared
+// menu, and releases it otherwise.
+func (w *Wrapper) bindStepKeyboard(ctx context.Context, c *conv.Conversation, step *config.StepConfig) {
+	if c.KeyboardMsgID == 0 {
+		return
+	}
+	if w.isForkedConversation(c) {
+		w.keepFork(ctx, c.ChatID, c.KeyboardMsgID, c.UserID)
+		return
+	}
+	flow := w.Config().GetFlow(c.FlowID)
+	if (flow != nil && flow.BindUser) || (step.Keyboard != nil && step.Keyboard.BindUser) {
+		w.bindMessage(ctx, c.ChatID, c.KeyboardMsgID, c.UserID)
+		return
+	}
+	w.unbindMessage(ctx, c.ChatID, c.KeyboardMsgID)
+}
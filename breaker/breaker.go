@@ -0,0 +1,216 @@
+// Package breaker implements circuit breakers for calls to external services,
+// so a failing upstream API is skipped for a while instead of being retried
+// (and timing out) on every call.
+package breaker
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrOpen is returned for calls rejected because their circuit is open.
+var ErrOpen = errors.New("circuit breaker open")
+
+// Default breaker settings.
+const (
+	// DefaultThreshold is the number of consecutive failures that opens a circuit.
+	DefaultThreshold = 5
+
+	// DefaultCooldown is how long an open circuit rejects calls before probing.
+	DefaultCooldown = 30 * time.Second
+
+	// DefaultProbes is the number of successful probes that closes a half-open circuit.
+	DefaultProbes = 1
+)
+
+// State is the state of a circuit.
+type State int
+
+const (
+	// Closed lets all calls through and counts consecutive failures.
+	Closed State = iota
+
+	// Open rejects all calls until the cool-down has passed.
+	Open
+
+	// HalfOpen lets a limited number of probe calls through; they close the
+	// circuit when they succeed and reopen it when one fails.
+	HalfOpen
+)
+
+// String returns the state name.
+func (s State) String() string {
+	switch s {
+	case Open:
+		return "open"
+	case HalfOpen:
+		return "half-open"
+	default:
+		return "closed"
+	}
+}
+
+// Settings configure a breaker. Zero values use the defaults.
+type Settings struct {
+	Threshold int           // Consecutive failures that open the circuit
+	Cooldown  time.Duration // Time an open circuit waits before probing
+	Probes    int           // Successful probes that close a half-open circuit
+}
+
+// withDefaults returns the settings with zero values replaced by defaults.
+func (s Settings) withDefaults() Settings {
+	if s.Threshold <= 0 {
+		s.Threshold = DefaultThreshold
+	}
+	if s.Cooldown <= 0 {
+		s.Cooldown = DefaultCooldown
+	}
+	if s.Probes <= 0 {
+		s.Probes = DefaultProbes
+	}
+	return s
+}
+
+// Breaker is a circuit breaker for one external call.
+// Each call allowed by Allow must be followed by Success or Failure.
+type Breaker struct {
+	settings  Settings  // Thresholds and cool-down
+	state     State     // Current state
+	failures  int       // Consecutive failures while closed
+	openedAt  time.Time // When the circuit last opened
+	inFlight  int       // Probes allowed but not yet reported while half-open
+	successes int       // Successful probes while half-open
+
+	mu sync.Mutex // Mutex for thread-safe state access
+}
+
+// New creates a closed breaker.
+func New(settings Settings) *Breaker {
+	return &Breaker{settings: settings.withDefaults()}
+}
+
+// Allow reports whether a call may proceed at now. An open circuit turns
+// half-open once its cool-down has passed, then allows up to Probes calls at a time.
+func (b *Breaker) Allow(now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == Open {
+		if now.Sub(b.openedAt) < b.settings.Cooldown {
+			return false
+		}
+		b.state = HalfOpen
+		b.inFlight = 0
+		b.successes = 0
+	}
+	if b.state == HalfOpen {
+		if b.inFlight >= b.settings.Probes {
+			return false
+		}
+		b.inFlight++
+	}
+	return true
+}
+
+// Success reports a successful call.
+func (b *Breaker) Success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case Closed:
+		b.failures = 0
+	case HalfOpen:
+		b.inFlight--
+		b.successes++
+		if b.successes >= b.settings.Probes {
+			b.state = Closed
+			b.failures = 0
+		}
+	}
+}
+
+// Failure reports a failed call at now. Threshold consecutive failures open a
+// closed circuit; any failed probe reopens a half-open one.
+func (b *Breaker) Failure(now time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case Closed:
+		b.failures++
+		if b.failures >= b.settings.Threshold {
+			b.open(now)
+		}
+	case HalfOpen:
+		b.open(now)
+	}
+}
+
+// open opens the circuit at now. Callers must hold b.mu.
+func (b *Breaker) open(now time.Time) {
+	b.state = Open
+	b.openedAt = now
+	b.failures = 0
+	b.inFlight = 0
+	b.successes = 0
+}
+
+// State returns the current state. An open circuit whose cool-down has
+// passed is still reported as open until the next Allow.
+func (b *Breaker) State() State {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+// setSettings replaces the settings, keeping the current state.
+func (b *Breaker) setSettings(settings Settings) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.settings = settings.withDefaults()
+}
+
+// Set keeps breakers by call name, e.g. "keyboard:getCryptoPrices".
+type Set struct {
+	breakers map[string]*Breaker // Breakers by call name
+
+	mu sync.Mutex // Mutex for thread-safe breaker access
+}
+
+// NewSet creates an empty set.
+func NewSet() *Set {
+	return &Set{breakers: make(map[string]*Breaker)}
+}
+
+// Get returns the breaker of a call, creating it with settings if needed.
+// An existing breaker keeps its state and adopts the given settings.
+func (s *Set) Get(name string, settings Settings) *Breaker {
+	s.mu.Lock()
+	b, ok := s.breakers[name]
+	if !ok {
+		b = New(settings)
+		s.breakers[name] = b
+	}
+	s.mu.Unlock()
+	if ok {
+		b.setSettings(settings)
+	}
+	return b
+}
+
+// States returns the state of every breaker by call name.
+func (s *Set) States() map[string]State {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	states := make(map[string]State, len(s.breakers))
+	for name, b := range s.breakers {
+		states[name] = b.State()
+	}
+	return states
+}
+
+// Reset closes a circuit by discarding its breaker.
+func (s *Set) Reset(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.breakers, name)
+}
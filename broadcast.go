@@ -0,0 +1,245 @@
+package tgwrapper
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mymmrac/telego"
+
+	"github.com/0xVanfer/tg-listener/core"
+)
+
+// BroadcastMessage is the content delivered to every recipient of a broadcast.
+type BroadcastMessage struct {
+	Text     string                       // Message text
+	Entities []telego.MessageEntity       // Formatting entities for the text
+	Keyboard *telego.InlineKeyboardMarkup // Optional inline keyboard
+}
+
+// BroadcastReport summarizes the progress or outcome of a broadcast.
+type BroadcastReport struct {
+	Total   int // Number of recipients
+	Sent    int // Messages delivered successfully
+	Failed  int // Messages that could not be delivered
+	Blocked int // Recipients who blocked the bot or removed it from the chat
+	Skipped int // Recipients skipped because they blocked the bot earlier
+	Retried int // Sends retried after Telegram answered 429 Too Many Requests
+
+	FailedChatIDs  []int64       // Recipients counted in Failed
+	BlockedChatIDs []int64       // Recipients counted in Blocked
+	Duration       time.Duration // Time spent so far
+}
+
+// Done returns the number of recipients processed so far.
+func (r BroadcastReport) Done() int {
+	return r.Sent + r.Failed + r.Blocked + r.Skipped
+}
+
+// Summary returns a one-line summary of the report, e.g.
+// "980/1000 sent, 12 blocked, 3 skipped, 5 failed in 41s".
+func (r BroadcastReport) Summary() string {
+	parts := []string{fmt.Sprintf("%d/%d sent", r.Sent, r.Total)}
+	if r.Blocked > 0 {
+		parts = append(parts, fmt.Sprintf("%d blocked", r.Blocked))
+	}
+	if r.Skipped > 0 {
+		parts = append(parts, fmt.Sprintf("%d skipped", r.Skipped))
+	}
+	if r.Failed > 0 {
+		parts = append(parts, fmt.Sprintf("%d failed", r.Failed))
+	}
+	return strings.Join(parts, ", ") + " in " + r.Duration.Round(time.Second).String()
+}
+
+// BroadcastOptions configures a broadcast.
+type BroadcastOptions struct {
+	// RatePerSecond caps the number of messages sent per second.
+	// Defaults to 25, below Telegram's global limit of about 30 messages per second.
+	RatePerSecond int
+
+	// MaxRetries is how often a recipient is retried after the send queue's own
+	// retries were answered with 429 Too Many Requests. Defaults to 3.
+	MaxRetries int
+
+	// IncludeBlocked sends to users who blocked the bot in an earlier
+	// broadcast and haven't been seen since. By default they are skipped.
+	IncludeBlocked bool
+
+	// OnProgress is called after each recipient is processed.
+	OnProgress func(report BroadcastReport)
+
+	// OnBlocked is called for each recipient found to have blocked the bot,
+	// e.g. to unsubscribe them.
+	OnBlocked func(chatID int64)
+}
+
+// defaultBroadcastRetries is how often a rate-limited recipient is retried by default.
+const defaultBroadcastRetries = 3
+
+// BroadcastTargetFunc resolves the chat IDs of a named broadcast audience.
+type BroadcastTargetFunc func(ctx context.Context) ([]int64, error)
+
+// broadcastAudiences holds registered broadcast audiences by name.
+type broadcastAudiences struct {
+	targets map[string]BroadcastTargetFunc
+	mu      sync.RWMutex
+}
+
+// Broadcast sends a message to every chat in chatIDs, pacing sends to stay
+// within Telegram's rate limits. Recipients answered with 429 Too Many
+// Requests are retried after the requested delay. Users who blocked the bot
+// are counted separately, marked in the user registry, and skipped by later
+// broadcasts until they are seen again. It blocks until all recipients are
+// processed or ctx is cancelled, and returns the final report.
+//
+// Example:
+//
+//	report := wrapper.Broadcast(ctx, chatIDs, tgwrapper.BroadcastMessage{Text: "Hello!"}, nil)
+//	log.Println(report.Summary())
+func (w *Wrapper) Broadcast(ctx context.Context, chatIDs []int64, msg BroadcastMessage, opts *BroadcastOptions) BroadcastReport {
+	if opts == nil {
+		opts = &BroadcastOptions{}
+	}
+	rate := opts.RatePerSecond
+	if rate <= 0 {
+		rate = 25
+	}
+	retries := opts.MaxRetries
+	if retries <= 0 {
+		retries = defaultBroadcastRetries
+	}
+
+	start := time.Now()
+	report := BroadcastReport{Total: len(chatIDs)}
+	ticker := time.NewTicker(time.Second / time.Duration(rate))
+	defer ticker.Stop()
+
+	for _, chatID := range chatIDs {
+		if !opts.IncludeBlocked && w.blockedUser(ctx, chatID) {
+			report.Skipped++
+			report.Duration = time.Since(start)
+			if opts.OnProgress != nil {
+				opts.OnProgress(report)
+			}
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			report.Duration = time.Since(start)
+			return report
+		case <-ticker.C:
+		}
+
+		err := w.broadcastTo(ctx, chatID, msg, retries, &report)
+		switch {
+		case err == nil:
+			report.Sent++
+		case core.IsBlockedError(err):
+			report.Blocked++
+			report.BlockedChatIDs = append(report.BlockedChatIDs, chatID)
+			if chatID > 0 {
+				_ = w.Users().MarkBlocked(ctx, chatID, time.Now())
+			}
+			if opts.OnBlocked != nil {
+				opts.OnBlocked(chatID)
+			}
+		default:
+			report.Failed++
+			report.FailedChatIDs = append(report.FailedChatIDs, chatID)
+		}
+
+		report.Duration = time.Since(start)
+		if opts.OnProgress != nil {
+			opts.OnProgress(report)
+		}
+	}
+
+	report.Duration = time.Since(start)
+	return report
+}
+
+// broadcastTo sends a broadcast message to one chat, retrying up to retries
+// times while Telegram answers 429 Too Many Requests.
+func (w *Wrapper) broadcastTo(ctx context.Context, chatID int64, msg BroadcastMessage, retries int, report *BroadcastReport) error {
+	for attempt := 0; ; attempt++ {
+		_, err := w.bot.SendMessageWithKeyboard(ctx, chatID, 0, msg.Text, msg.Keyboard, msg.Entities...)
+		retryAfter, limited := core.RetryAfter(err)
+		if !limited || attempt >= retries {
+			return err
+		}
+		if retryAfter <= 0 {
+			retryAfter = time.Second << attempt
+		}
+		report.Retried++
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(retryAfter):
+		}
+	}
+}
+
+// blockedUser returns true if a private chat's user blocked the bot earlier.
+func (w *Wrapper) blockedUser(ctx context.Context, chatID int64) bool {
+	if chatID <= 0 {
+		return false
+	}
+	u, err := w.Users().Get(ctx, chatID)
+	return err == nil && u.Blocked()
+}
+
+// RegisterBroadcastTarget registers a named audience selectable in the broadcast composer.
+//
+// Example:
+//
+//	wrapper.RegisterBroadcastTarget("subscribers", func(ctx context.Context) ([]int64, error) {
+//	    return db.SubscriberChatIDs(ctx)
+//	})
+func (w *Wrapper) RegisterBroadcastTarget(name string, fn BroadcastTargetFunc) {
+	w.audiences.mu.Lock()
+	defer w.audiences.mu.Unlock()
+	if w.audiences.targets == nil {
+		w.audiences.targets = make(map[string]BroadcastTargetFunc)
+	}
+	w.audiences.targets[name] = fn
+}
+
+// BroadcastTargets returns the names of all registered broadcast audiences
+// and of the segments declared in the configuration in sorted order.
+func (w *Wrapper) BroadcastTargets() []string {
+	configured := w.configuredSegments()
+	w.audiences.mu.RLock()
+	defer w.audiences.mu.RUnlock()
+	names := make([]string, 0, len(w.audiences.targets)+len(configured))
+	for name := range w.audiences.targets {
+		names = append(names, name)
+	}
+	for _, name := range configured {
+		if _, ok := w.audiences.targets[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ResolveBroadcastTarget returns the chat IDs of a registered broadcast
+// audience, or of the users in a segment declared in the configuration.
+func (w *Wrapper) ResolveBroadcastTarget(ctx context.Context, name string) ([]int64, error) {
+	w.audiences.mu.RLock()
+	fn, ok := w.audiences.targets[name]
+	w.audiences.mu.RUnlock()
+	if ok {
+		return fn(ctx)
+	}
+	if slices.Contains(w.configuredSegments(), name) {
+		return w.SegmentUserIDs(ctx, name)
+	}
+	return nil, fmt.Errorf("broadcast target %s is not registered", name)
+}
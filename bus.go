@@ -0,0 +1,130 @@
+package tgwrapper
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/mymmrac/telego"
+
+	"github.com/0xVanfer/tg-listener/bus"
+	"github.com/0xVanfer/tg-listener/conv"
+	"github.com/0xVanfer/tg-listener/core"
+)
+
+// SetBus connects the bot to a message broker, e.g. NATS or Kafka through
+// bus.PublisherFunc and bus.SubscriberFunc adapters. Update and conversation
+// events are published to the topics of bot.bus, and outgoing messages are
+// consumed from its outgoing topic. Either may be nil. Must be called before
+// Start.
+func (w *Wrapper) SetBus(pub bus.Publisher, sub bus.Subscriber) {
+	w.busPub = pub
+	w.busSub = sub
+}
+
+// startBus starts publishing events and consuming outgoing messages, and
+// returns the updates to process, which are published as they pass.
+func (w *Wrapper) startBus(ctx context.Context, updates <-chan telego.Update) <-chan telego.Update {
+	cfg := w.Config().Bot.Bus
+	if cfg == nil {
+		return updates
+	}
+	if w.busSub != nil && cfg.OutgoingTopic != "" {
+		go func() {
+			if err := w.busSub.Subscribe(ctx, cfg.OutgoingTopic, func(data []byte) error {
+				return w.handleOutgoing(ctx, data)
+			}); err != nil && ctx.Err() == nil {
+				log.Printf("[Bus] Consuming %s stopped: %v", cfg.OutgoingTopic, err)
+			}
+		}()
+	}
+	if w.busPub == nil {
+		return updates
+	}
+	w.emitter = bus.NewEmitter(w.busPub, cfg.Buffer)
+	go w.emitter.Run(ctx)
+	if cfg.UpdatesTopic == "" {
+		return updates
+	}
+
+	out := make(chan telego.Update)
+	go func() {
+		defer close(out)
+		for update := range updates {
+			w.emitter.Emit(cfg.UpdatesTopic, bus.NewUpdateEvent(update, cfg.IncludeRaw))
+			select {
+			case out <- update:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out
+}
+
+// publishConversation publishes the event of an ended conversation.
+func (w *Wrapper) publishConversation(c *conv.Conversation) {
+	cfg := w.Config().Bot.Bus
+	if w.emitter == nil || cfg == nil || cfg.ConversationsTopic == "" {
+		return
+	}
+	w.emitter.Emit(cfg.ConversationsTopic, bus.ConversationEvent{
+		UserID:    c.UserID,
+		ChatID:    c.ChatID,
+		TopicID:   c.TopicID,
+		FlowID:    c.FlowID,
+		StepID:    c.StepID,
+		Outcome:   conversationOutcome(c),
+		Data:      c.CopyData(),
+		StartedAt: c.CreatedAt,
+		EndedAt:   time.Now(),
+	})
+}
+
+// handleOutgoing sends a message consumed from the outgoing topic and
+// publishes its result. Malformed commands are logged and acknowledged, as
+// redelivering them can't succeed; send errors are returned so the broker
+// can redeliver.
+func (w *Wrapper) handleOutgoing(ctx context.Context, data []byte) error {
+	var m bus.OutgoingMessage
+	if err := json.Unmarshal(data, &m); err != nil || m.ChatID == 0 || m.Text == "" {
+		log.Printf("[Bus] Dropping malformed outgoing message: %s", data)
+		return nil
+	}
+
+	ctx = core.WithParseMode(ctx, m.ParseMode)
+	var msg *telego.Message
+	var err error
+	switch {
+	case len(m.Buttons) > 0:
+		kb := core.NewKeyboard()
+		for _, row := range m.Buttons {
+			buttons := make([]telego.InlineKeyboardButton, 0, len(row))
+			for _, b := range row {
+				if b.URL != "" {
+					buttons = append(buttons, core.URLButton(b.Text, b.URL))
+				} else {
+					buttons = append(buttons, core.Button(b.Text, b.Callback))
+				}
+			}
+			kb.Row(buttons...)
+		}
+		msg, err = w.bot.SendMessageWithKeyboard(ctx, m.ChatID, m.TopicID, m.Text, kb.Build())
+	case m.ReplyTo > 0:
+		msg, err = w.bot.SendReply(ctx, m.ChatID, m.TopicID, m.ReplyTo, m.Text)
+	default:
+		msg, err = w.bot.SendMessage(ctx, m.ChatID, m.TopicID, m.Text)
+	}
+
+	if cfg := w.Config().Bot.Bus; m.ID != "" && w.emitter != nil && cfg.ResultsTopic != "" {
+		result := bus.OutgoingResult{ID: m.ID, ChatID: m.ChatID, At: time.Now()}
+		if err != nil {
+			result.Error = err.Error()
+		} else if msg != nil {
+			result.MessageID = msg.MessageID
+		}
+		w.emitter.Emit(cfg.ResultsTopic, result)
+	}
+	return err
+}
@@ -0,0 +1,212 @@
+// Package bus connects the bot to a message broker such as NATS or Kafka:
+// it publishes normalized update and conversation events, and consumes
+// commands to send messages, so the bot can act as the Telegram edge of an
+// event-driven system. Brokers are plugged in through the Publisher and
+// Subscriber interfaces, which adapt any client library.
+package bus
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/mymmrac/telego"
+
+	"github.com/0xVanfer/tg-listener/dispatch"
+)
+
+// DefaultBuffer is the default number of events waiting to be published
+// before new ones are dropped.
+const DefaultBuffer = 1024
+
+// Publisher publishes messages to a topic of a broker, e.g. a NATS subject
+// or a Kafka topic.
+type Publisher interface {
+	Publish(ctx context.Context, topic string, data []byte) error
+}
+
+// PublisherFunc adapts a function to a Publisher.
+type PublisherFunc func(ctx context.Context, topic string, data []byte) error
+
+// Publish calls f.
+func (f PublisherFunc) Publish(ctx context.Context, topic string, data []byte) error {
+	return f(ctx, topic, data)
+}
+
+// Subscriber delivers the messages of a topic of a broker. Subscribe must
+// call handle with each message until ctx is done. A message handle returns
+// an error for must not be acknowledged.
+type Subscriber interface {
+	Subscribe(ctx context.Context, topic string, handle func(data []byte) error) error
+}
+
+// SubscriberFunc adapts a function to a Subscriber.
+type SubscriberFunc func(ctx context.Context, topic string, handle func(data []byte) error) error
+
+// Subscribe calls f.
+func (f SubscriberFunc) Subscribe(ctx context.Context, topic string, handle func(data []byte) error) error {
+	return f(ctx, topic, handle)
+}
+
+// UpdateEvent is the normalized form of a received update.
+type UpdateEvent struct {
+	UpdateID int             `json:"update_id"`
+	Kind     string          `json:"kind"` // Update class, e.g. "command" or "callback", see dispatch.Class
+	At       time.Time       `json:"at"`
+	UserID   int64           `json:"user_id,omitempty"`
+	Username string          `json:"username,omitempty"`
+	ChatID   int64           `json:"chat_id,omitempty"`
+	TopicID  int             `json:"topic_id,omitempty"`
+	Command  string          `json:"command,omitempty"` // Command without the slash and bot name
+	Text     string          `json:"text,omitempty"`    // Message text or caption, or inline query
+	Data     string          `json:"data,omitempty"`    // Callback data, or invoice payload of payment queries
+	Raw      json.RawMessage `json:"raw,omitempty"`     // The update as sent by Telegram, if requested
+}
+
+// NewUpdateEvent normalizes an update, keeping its raw JSON if raw is true.
+func NewUpdateEvent(update telego.Update, raw bool) UpdateEvent {
+	e := UpdateEvent{UpdateID: update.UpdateID, Kind: dispatch.Class(update), At: time.Now()}
+	var from *telego.User
+	switch {
+	case update.Message != nil:
+		msg := update.Message
+		from, e.ChatID, e.TopicID = msg.From, msg.Chat.ID, msg.MessageThreadID
+		e.Text = msg.Text
+		if e.Text == "" {
+			e.Text = msg.Caption
+		}
+		if e.Kind == dispatch.ClassCommand {
+			e.Command = strings.TrimPrefix(strings.SplitN(strings.Fields(msg.Text)[0], "@", 2)[0], "/")
+		}
+	case update.EditedMessage != nil:
+		msg := update.EditedMessage
+		from, e.ChatID, e.TopicID, e.Text = msg.From, msg.Chat.ID, msg.MessageThreadID, msg.Text
+	case update.CallbackQuery != nil:
+		from, e.Data = &update.CallbackQuery.From, update.CallbackQuery.Data
+		if update.CallbackQuery.Message != nil {
+			e.ChatID = update.CallbackQuery.Message.GetChat().ID
+		}
+	case update.InlineQuery != nil:
+		from, e.Text = &update.InlineQuery.From, update.InlineQuery.Query
+	case update.ChosenInlineResult != nil:
+		from, e.Text = &update.ChosenInlineResult.From, update.ChosenInlineResult.Query
+	case update.MyChatMember != nil:
+		from, e.ChatID = &update.MyChatMember.From, update.MyChatMember.Chat.ID
+	case update.ChatMember != nil:
+		from, e.ChatID = &update.ChatMember.From, update.ChatMember.Chat.ID
+	case update.PreCheckoutQuery != nil:
+		from, e.Data = &update.PreCheckoutQuery.From, update.PreCheckoutQuery.InvoicePayload
+	case update.ShippingQuery != nil:
+		from, e.Data = &update.ShippingQuery.From, update.ShippingQuery.InvoicePayload
+	}
+	if from != nil {
+		e.UserID, e.Username = from.ID, from.Username
+	}
+	if raw {
+		e.Raw, _ = json.Marshal(update)
+	}
+	return e
+}
+
+// ConversationEvent reports an ended conversation.
+type ConversationEvent struct {
+	UserID    int64                  `json:"user_id"`
+	ChatID    int64                  `json:"chat_id"`
+	TopicID   int                    `json:"topic_id,omitempty"`
+	FlowID    string                 `json:"flow_id"`
+	StepID    string                 `json:"step_id"`
+	Outcome   string                 `json:"outcome"` // "completed", "cancelled", "expired", or "ended"
+	Data      map[string]interface{} `json:"data,omitempty"`
+	StartedAt time.Time              `json:"started_at"`
+	EndedAt   time.Time              `json:"ended_at"`
+}
+
+// OutgoingMessage is a command to send a message, consumed from a topic.
+type OutgoingMessage struct {
+	ID        string             `json:"id,omitempty"` // Correlates the result; results are only published for messages with an ID
+	ChatID    int64              `json:"chat_id"`
+	TopicID   int                `json:"topic_id,omitempty"`
+	Text      string             `json:"text"`
+	ParseMode string             `json:"parse_mode,omitempty"` // "HTML", "MarkdownV2", or empty for plain text
+	ReplyTo   int                `json:"reply_to,omitempty"`   // Message replied to; ignored with Buttons
+	Buttons   [][]OutgoingButton `json:"buttons,omitempty"`    // Inline keyboard rows
+}
+
+// OutgoingButton is an inline keyboard button of an outgoing message.
+type OutgoingButton struct {
+	Text     string `json:"text"`
+	Callback string `json:"callback,omitempty"` // Callback data, routed like the bot's own buttons
+	URL      string `json:"url,omitempty"`      // Link opened instead of a callback
+}
+
+// OutgoingResult reports the outcome of an outgoing message.
+type OutgoingResult struct {
+	ID        string    `json:"id"`
+	ChatID    int64     `json:"chat_id"`
+	MessageID int       `json:"message_id,omitempty"` // ID of the sent message
+	Error     string    `json:"error,omitempty"`      // Why the message wasn't sent
+	At        time.Time `json:"at"`
+}
+
+// envelope is an event waiting to be published.
+type envelope struct {
+	topic string // Topic to publish to
+	data  []byte // JSON of the event
+}
+
+// Emitter publishes events in the background, in the order they were
+// emitted, so a slow broker doesn't delay update handling. Events emitted
+// while its buffer is full are dropped.
+type Emitter struct {
+	pub     Publisher     // Broker publisher
+	queue   chan envelope // Events waiting to be published
+	dropped atomic.Uint64 // Events dropped because the buffer was full
+}
+
+// NewEmitter creates an emitter buffering up to buffer events; 0 uses DefaultBuffer.
+func NewEmitter(pub Publisher, buffer int) *Emitter {
+	if buffer <= 0 {
+		buffer = DefaultBuffer
+	}
+	return &Emitter{pub: pub, queue: make(chan envelope, buffer)}
+}
+
+// Emit queues an event for publishing as JSON. Returns false if it was dropped.
+func (e *Emitter) Emit(topic string, event interface{}) bool {
+	data, err := json.Marshal(event)
+	if err != nil {
+		log.Printf("[Bus] Failed to encode event for %s: %v", topic, err)
+		return false
+	}
+	select {
+	case e.queue <- envelope{topic: topic, data: data}:
+		return true
+	default:
+		if e.dropped.Add(1) == 1 {
+			log.Printf("[Bus] Publish buffer full, dropping events for %s", topic)
+		}
+		return false
+	}
+}
+
+// Dropped returns the number of events dropped because the buffer was full.
+func (e *Emitter) Dropped() uint64 {
+	return e.dropped.Load()
+}
+
+// Run publishes queued events until ctx is done.
+func (e *Emitter) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case env := <-e.queue:
+			if err := e.pub.Publish(ctx, env.topic, env.data); err != nil && ctx.Err() == nil {
+				log.Printf("[Bus] Failed to publish to %s: %v", env.topic, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package tgwrapper
+
+import (
+	"context"
+
+	"github.com/mymmrac/telego"
+)
+
+// handleCancelCommand ends the sender's conversation in the chat as cancelled
+// and turns its keyboard message back into the main menu.
+func (w *Wrapper) handleCancelCommand(ctx context.Context, msg telego.Message) error {
+	cfg := w.Config().Bot.Cancel
+	c := w.convManager.GetIn(msg.From.ID, msg.Chat.ID, msg.MessageThreadID)
+	if c == nil {
+		_, err := w.bot.SendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, cfg.GetNothingText())
+		return err
+	}
+
+	// Keep the keyboard message out of the cleanup and sweep, to reuse it for the menu
+	msgID := c.KeyboardMsgID
+	if msgID > 0 {
+		c.SetKeyboardMsgID(0)
+		c.UntrackMessage(msgID)
+	}
+	c.Cancel()
+	w.convManager.EndIn(ctx, c.UserID, c.ChatID, c.TopicID)
+
+	if _, err := w.bot.SendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, cfg.GetText()); err != nil {
+		return err
+	}
+	if msgID > 0 && w.ShowMainMenu(ctx, msg.Chat.ID, msg.MessageThreadID, msgID) != nil {
+		return w.ShowMainMenu(ctx, msg.Chat.ID, msg.MessageThreadID, 0)
+	}
+	return nil
+}
+
+// setupCancelCommand registers the built-in cancel command unless it is
+// disabled. A command registered later under the same name replaces it.
+func (w *Wrapper) setupCancelCommand() {
+	if cfg := w.Config().Bot.Cancel; cfg.IsEnabled() {
+		w.router.RegisterCommand(cfg.GetCommand(), w.handleCancelCommand)
+	}
+}
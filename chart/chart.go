@@ -0,0 +1,300 @@
+// Package chart renders small line, bar, and sparkline charts of numeric data to PNG.
+package chart
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"math"
+	"strconv"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+)
+
+// Kind is the type of chart to draw.
+type Kind string
+
+const (
+	// KindLine draws values as a line with a labeled value axis.
+	KindLine Kind = "line"
+
+	// KindBar draws values as vertical bars with a labeled value axis.
+	KindBar Kind = "bar"
+
+	// KindSparkline draws values as a bare line without axes or labels.
+	KindSparkline Kind = "sparkline"
+)
+
+// Default chart dimensions in pixels.
+const (
+	DefaultWidth           = 800
+	DefaultHeight          = 400
+	DefaultSparklineWidth  = 300
+	DefaultSparklineHeight = 60
+)
+
+// ErrNoData is returned when rendering a chart without values.
+var ErrNoData = errors.New("chart has no data")
+
+// Default colors.
+var (
+	DefaultColor = color.RGBA{R: 0x2a, G: 0x7a, B: 0xe2, A: 0xff}
+	gridColor    = color.RGBA{R: 0xe5, G: 0xe5, B: 0xe5, A: 0xff}
+	textColor    = color.RGBA{R: 0x44, G: 0x44, B: 0x44, A: 0xff}
+)
+
+// Chart describes a chart of a single numeric series.
+type Chart struct {
+	Kind   Kind        // Chart type, defaults to KindLine
+	Title  string      // Title drawn above the plot (not drawn on sparklines)
+	Values []float64   // Data points in order
+	Labels []string    // Optional x-axis labels, one per value; sparse labels are thinned out
+	Width  int         // Image width in pixels, defaults per kind
+	Height int         // Image height in pixels, defaults per kind
+	Color  color.Color // Series color, defaults to DefaultColor
+}
+
+// Line creates a line chart.
+func Line(title string, values []float64, labels []string) *Chart {
+	return &Chart{Kind: KindLine, Title: title, Values: values, Labels: labels}
+}
+
+// Bar creates a bar chart.
+func Bar(title string, values []float64, labels []string) *Chart {
+	return &Chart{Kind: KindBar, Title: title, Values: values, Labels: labels}
+}
+
+// Sparkline creates a sparkline.
+func Sparkline(values []float64) *Chart {
+	return &Chart{Kind: KindSparkline, Values: values}
+}
+
+// size returns the image size, applying defaults.
+func (c *Chart) size() (int, int) {
+	w, h := c.Width, c.Height
+	if c.Kind == KindSparkline {
+		if w <= 0 {
+			w = DefaultSparklineWidth
+		}
+		if h <= 0 {
+			h = DefaultSparklineHeight
+		}
+		return w, h
+	}
+	if w <= 0 {
+		w = DefaultWidth
+	}
+	if h <= 0 {
+		h = DefaultHeight
+	}
+	return w, h
+}
+
+// PNG renders the chart as a PNG image.
+func (c *Chart) PNG() ([]byte, error) {
+	img, err := c.Image()
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Image renders the chart.
+func (c *Chart) Image() (image.Image, error) {
+	if len(c.Values) == 0 {
+		return nil, ErrNoData
+	}
+	width, height := c.size()
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(img, img.Bounds(), image.White, image.Point{}, draw.Src)
+
+	col := c.Color
+	if col == nil {
+		col = DefaultColor
+	}
+
+	// Value range, always including zero for bars
+	lo, hi := c.Values[0], c.Values[0]
+	for _, v := range c.Values {
+		lo, hi = math.Min(lo, v), math.Max(hi, v)
+	}
+	if c.Kind == KindBar {
+		lo, hi = math.Min(lo, 0), math.Max(hi, 0)
+	}
+	if hi == lo {
+		hi, lo = hi+1, lo-1
+	}
+
+	if c.Kind == KindSparkline {
+		plot := image.Rect(2, 2, width-2, height-2)
+		drawLine(img, plot, c.Values, lo, hi, col)
+		return img, nil
+	}
+
+	// Leave room for the title, value axis labels, and x labels
+	top := 12
+	if c.Title != "" {
+		drawText(img, 12, 22, c.Title)
+		top = 36
+	}
+	bottom := height - 12
+	if len(c.Labels) > 0 {
+		bottom = height - 26
+	}
+	left := 12 + 7*max(len(formatValue(lo)), len(formatValue(hi)))
+	plot := image.Rect(left, top, width-16, bottom)
+
+	// Horizontal grid lines with value labels
+	const gridLines = 4
+	for i := 0; i <= gridLines; i++ {
+		v := lo + (hi-lo)*float64(i)/gridLines
+		y := scaleY(plot, v, lo, hi)
+		for x := plot.Min.X; x < plot.Max.X; x++ {
+			img.Set(x, y, gridColor)
+		}
+		label := formatValue(v)
+		drawText(img, plot.Min.X-6-7*len(label), y+4, label)
+	}
+
+	switch c.Kind {
+	case KindBar:
+		drawBars(img, plot, c.Values, lo, hi, col)
+	default:
+		drawLine(img, plot, c.Values, lo, hi, col)
+	}
+
+	// X labels, thinned out so they don't overlap
+	if n := len(c.Labels); n > 0 {
+		widest := 0
+		for _, l := range c.Labels {
+			widest = max(widest, len(l))
+		}
+		step := max(1, int(math.Ceil(float64(n*(7*widest+8))/float64(plot.Dx()))))
+		for i := 0; i < n && i < len(c.Values); i += step {
+			x := pointX(plot, i, len(c.Values), c.Kind == KindBar)
+			drawText(img, x-7*len(c.Labels[i])/2, height-10, c.Labels[i])
+		}
+	}
+	return img, nil
+}
+
+// pointX returns the x coordinate of value i out of n.
+// Bars are centered in equal slots; line points span the full width.
+func pointX(plot image.Rectangle, i, n int, slots bool) int {
+	if slots {
+		slot := float64(plot.Dx()) / float64(n)
+		return plot.Min.X + int(slot*(float64(i)+0.5))
+	}
+	if n == 1 {
+		return plot.Min.X + plot.Dx()/2
+	}
+	return plot.Min.X + int(float64(plot.Dx()-1)*float64(i)/float64(n-1))
+}
+
+// scaleY maps a value to a y coordinate in the plot.
+func scaleY(plot image.Rectangle, v, lo, hi float64) int {
+	return plot.Max.Y - 1 - int(math.Round((v-lo)/(hi-lo)*float64(plot.Dy()-1)))
+}
+
+// drawLine draws values as a 2px polyline.
+func drawLine(img *image.RGBA, plot image.Rectangle, values []float64, lo, hi float64, col color.Color) {
+	px, py := pointX(plot, 0, len(values), false), scaleY(plot, values[0], lo, hi)
+	if len(values) == 1 {
+		fillRect(img, image.Rect(px-2, py-2, px+3, py+3), col)
+		return
+	}
+	for i := 1; i < len(values); i++ {
+		x, y := pointX(plot, i, len(values), false), scaleY(plot, values[i], lo, hi)
+		segment(img, px, py, x, y, col)
+		px, py = x, y
+	}
+}
+
+// drawBars draws values as bars from the zero line.
+func drawBars(img *image.RGBA, plot image.Rectangle, values []float64, lo, hi float64, col color.Color) {
+	slot := float64(plot.Dx()) / float64(len(values))
+	half := max(1, int(slot*0.35))
+	zero := scaleY(plot, 0, lo, hi)
+	for i, v := range values {
+		x := pointX(plot, i, len(values), true)
+		y := scaleY(plot, v, lo, hi)
+		fillRect(img, image.Rect(x-half, min(y, zero), x+half, max(y, zero)+1), col)
+	}
+}
+
+// segment draws a 2px line between two points using Bresenham's algorithm.
+func segment(img *image.RGBA, x0, y0, x1, y1 int, col color.Color) {
+	dx, dy := abs(x1-x0), -abs(y1-y0)
+	sx, sy := 1, 1
+	if x0 > x1 {
+		sx = -1
+	}
+	if y0 > y1 {
+		sy = -1
+	}
+	e := dx + dy
+	for {
+		fillRect(img, image.Rect(x0, y0, x0+2, y0+2), col)
+		if x0 == x1 && y0 == y1 {
+			return
+		}
+		if e2 := 2 * e; e2 >= dy {
+			e += dy
+			x0 += sx
+		} else {
+			e += dx
+			y0 += sy
+		}
+	}
+}
+
+// fillRect fills a rectangle with a solid color.
+func fillRect(img *image.RGBA, r image.Rectangle, col color.Color) {
+	draw.Draw(img, r.Intersect(img.Bounds()), image.NewUniform(col), image.Point{}, draw.Src)
+}
+
+// drawText draws text with its baseline at (x, y) in the built-in 7x13 font.
+func drawText(img *image.RGBA, x, y int, text string) {
+	d := &font.Drawer{
+		Dst:  img,
+		Src:  image.NewUniform(textColor),
+		Face: basicfont.Face7x13,
+		Dot:  fixed.P(x, y),
+	}
+	d.DrawString(text)
+}
+
+// formatValue formats an axis value compactly.
+func formatValue(v float64) string {
+	switch a := math.Abs(v); {
+	case a >= 1e9:
+		return strconv.FormatFloat(v/1e9, 'f', 1, 64) + "B"
+	case a >= 1e6:
+		return strconv.FormatFloat(v/1e6, 'f', 1, 64) + "M"
+	case a >= 1e4:
+		return strconv.FormatFloat(v/1e3, 'f', 1, 64) + "K"
+	case a >= 100 || a == 0:
+		return strconv.FormatFloat(v, 'f', 0, 64)
+	case a >= 1:
+		return strconv.FormatFloat(math.Round(v*100)/100, 'f', -1, 64)
+	default:
+		return strconv.FormatFloat(v, 'g', 3, 64)
+	}
+}
+
+// abs returns the absolute value of x.
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
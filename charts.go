@@ -0,0 +1,107 @@
+package tgwrapper
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/mymmrac/telego"
+	"github.com/mymmrac/telego/telegoutil"
+
+	"github.com/0xVanfer/tg-listener/chart"
+	"github.com/0xVanfer/tg-listener/core"
+)
+
+// ChartCallbackPrefix is the callback data prefix of chart refresh buttons.
+const ChartCallbackPrefix = "chart:"
+
+// DefaultChartRefreshText is the label of chart refresh buttons.
+const DefaultChartRefreshText = "🔄 Refresh"
+
+// ChartFunc produces a chart and its caption for a chat.
+// It is called when the chart is sent and again on every refresh;
+// return a nil caption for none.
+type ChartFunc func(ctx context.Context, chatID int64) (*chart.Chart, *core.Builder, error)
+
+// chartRegistry holds named charts that can be sent and refreshed.
+type chartRegistry struct {
+	charts map[string]ChartFunc // Chart producers by name
+	mu     sync.RWMutex         // Mutex for thread-safe operations
+}
+
+// RegisterChart registers a named chart. Sent charts carry a refresh button
+// that re-renders the chart in place by calling fn again.
+//
+// Example:
+//
+//	wrapper.RegisterChart("eth", func(ctx context.Context, chatID int64) (*chart.Chart, *core.Builder, error) {
+//	    prices, labels := feed.Last24h(ctx, "ETH")
+//	    return chart.Line("ETH 24h", prices, labels), core.NewBuilder().Bold("ETH"), nil
+//	})
+func (w *Wrapper) RegisterChart(name string, fn ChartFunc) {
+	w.charts.mu.Lock()
+	defer w.charts.mu.Unlock()
+	if w.charts.charts == nil {
+		w.charts.charts = make(map[string]ChartFunc)
+	}
+	w.charts.charts[name] = fn
+}
+
+// SendChart renders a registered chart and sends it as a photo with its caption
+// and a refresh button.
+func (w *Wrapper) SendChart(ctx context.Context, chatID int64, topicID int, name string) (*telego.Message, error) {
+	png, caption, entities, err := w.renderChart(ctx, chatID, name)
+	if err != nil {
+		return nil, err
+	}
+	return w.bot.SendPhotoWithKeyboard(ctx, chatID, topicID, telegoutil.FileFromBytes(png, name+".png"), caption, chartKeyboard(name), entities...)
+}
+
+// renderChart calls a registered chart function and renders the chart.
+func (w *Wrapper) renderChart(ctx context.Context, chatID int64, name string) ([]byte, string, []telego.MessageEntity, error) {
+	w.charts.mu.RLock()
+	fn, ok := w.charts.charts[name]
+	w.charts.mu.RUnlock()
+	if !ok {
+		return nil, "", nil, fmt.Errorf("chart %s is not registered", name)
+	}
+
+	c, b, err := fn(ctx, chatID)
+	if err != nil {
+		return nil, "", nil, err
+	}
+	png, err := c.PNG()
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	var (
+		caption  string
+		entities []telego.MessageEntity
+	)
+	if b != nil {
+		caption, entities = b.Build()
+	}
+	return png, caption, entities, nil
+}
+
+// chartKeyboard returns the refresh keyboard of a chart.
+func chartKeyboard(name string) *telego.InlineKeyboardMarkup {
+	return core.NewKeyboard().Row(core.Button(DefaultChartRefreshText, ChartCallbackPrefix+name)).Build()
+}
+
+// setupCharts registers the chart refresh callback.
+func (w *Wrapper) setupCharts() {
+	w.router.RegisterCallbackPrefix(ChartCallbackPrefix, func(ctx context.Context, query telego.CallbackQuery) error {
+		_ = w.bot.AnswerCallback(ctx, query.ID, "")
+		name := core.ParseCallbackData(query.Data, ChartCallbackPrefix)
+		chatID := query.Message.GetChat().ID
+
+		png, caption, entities, err := w.renderChart(ctx, chatID, name)
+		if err != nil {
+			return err
+		}
+		_, err = w.bot.EditPhoto(ctx, chatID, query.Message.GetMessageID(), telegoutil.FileFromBytes(png, name+".png"), caption, chartKeyboard(name), entities...)
+		return err
+	})
+}
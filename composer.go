@@ -0,0 +1,414 @@
+package tgwrapper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mymmrac/telego"
+
+	"github.com/0xVanfer/tg-listener/config"
+	"github.com/0xVanfer/tg-listener/conv"
+	"github.com/0xVanfer/tg-listener/core"
+	"github.com/0xVanfer/tg-listener/scheduler"
+)
+
+// BroadcastComposerFlowID is the ID of the built-in broadcast composer flow.
+// The flow is installed when the admin panel is enabled, restricted to
+// operators, and started from the panel's Broadcast button.
+const BroadcastComposerFlowID = "_admin_broadcast"
+
+// Names of the composer's internal handlers, providers, and validators.
+const (
+	composerPreview   = "_broadcastPreview"
+	composerConfirm   = "_broadcastConfirm"
+	composerSchedule  = "_broadcastSchedule"
+	composerTargets   = "_broadcastTargets"
+	composerButtons   = "_broadcastButtons"
+	composerWhen      = "_broadcastWhen"
+	composerTargetPfx = "target:"
+)
+
+// scheduledBroadcastKind is the delayed task kind of broadcasts scheduled in the composer.
+const scheduledBroadcastKind = "_broadcast"
+
+// scheduledBroadcast is the payload of a broadcast scheduled in the composer.
+type scheduledBroadcast struct {
+	ChatID   int64                        `json:"chat_id"`
+	TopicID  int                          `json:"topic_id,omitempty"`
+	Target   string                       `json:"target"`
+	Text     string                       `json:"text"`
+	Entities []telego.MessageEntity       `json:"entities,omitempty"`
+	Keyboard *telego.InlineKeyboardMarkup `json:"keyboard,omitempty"`
+}
+
+// scheduleLayout is the accepted absolute time format for scheduled times, in the user's time zone.
+const scheduleLayout = "2006-01-02 15:04"
+
+// broadcastComposerFlow returns the configuration of the built-in broadcast composer flow.
+func broadcastComposerFlow() *config.FlowConfig {
+	return &config.FlowConfig{
+		ID:          BroadcastComposerFlowID,
+		Name:        "Broadcast Composer",
+		InitialStep: "compose",
+		Roles:       []string{config.RoleOperator},
+		TTL:         30 * time.Minute,
+		Steps: map[string]*config.StepConfig{
+			"compose": {
+				ID:         "compose",
+				PromptText: "📣 Broadcast Composer\n\nSend the message text. Formatting (bold, links, …) is kept as-is.",
+				Keyboard:   &config.KeyboardConfig{AddMain: true},
+				InputType:  config.InputTypeText,
+				StoreAs:    "text",
+				NextStep:   "buttons",
+			},
+			"buttons": {
+				ID:         "buttons",
+				PromptText: "🔘 Buttons (optional)\n\nSend one link button per line as:\nText - https://example.com\n\nor press Skip.",
+				Keyboard: &config.KeyboardConfig{
+					Type:    config.KeyboardTypeStatic,
+					Buttons: [][]config.ButtonConfig{{{Text: "⏭ Skip", Callback: "skip"}}},
+					AddBack: true,
+					AddMain: true,
+				},
+				InputType:  config.InputTypeAny,
+				Validation: &config.ValidationConfig{Type: "custom", Custom: composerButtons},
+				StoreAs:    "buttons",
+				NextStep:   "target",
+			},
+			"target": {
+				ID:         "target",
+				PromptText: "🎯 Choose the audience:",
+				Keyboard: &config.KeyboardConfig{
+					Type:     config.KeyboardTypeDynamic,
+					Provider: composerTargets,
+					Columns:  1,
+					AddBack:  true,
+					AddMain:  true,
+				},
+				InputType:  config.InputTypeCallback,
+				StoreAs:    "target",
+				OnComplete: composerPreview,
+			},
+			"confirm": {
+				ID:         "confirm",
+				PromptText: "👆 This is how the broadcast will look.\n\nRecipients: {{.recipients}}",
+				Keyboard: &config.KeyboardConfig{
+					Type: config.KeyboardTypeStatic,
+					Buttons: [][]config.ButtonConfig{
+						{{Text: "🚀 Send now", Callback: "send"}},
+						{{Text: "⏰ Schedule", Callback: "schedule"}},
+					},
+					AddMain: true,
+				},
+				InputType:  config.InputTypeCallback,
+				StoreAs:    "action",
+				OnComplete: composerConfirm,
+			},
+			"schedule": {
+				ID:         "schedule",
+				PromptText: "⏰ When should it be sent?\n\nEnter a delay such as 30m or 2h, or a time in your time zone as " + scheduleLayout + ".",
+				Keyboard:   &config.KeyboardConfig{AddMain: true},
+				InputType:  config.InputTypeText,
+				Validation: &config.ValidationConfig{Type: "custom", Custom: composerWhen},
+				StoreAs:    "when",
+				OnComplete: composerSchedule,
+			},
+		},
+	}
+}
+
+// installBuiltins adds the library's built-in menus and flows to a configuration.
+func installBuiltins(cfg *config.Config) {
+	if cfg.Admin != nil && cfg.Admin.Enabled {
+		for _, m := range adminMenus(cfg) {
+			cfg.AddMenu(m)
+		}
+		cfg.AddFlow(broadcastComposerFlow())
+	}
+	if cfg.Reminders != nil && cfg.Reminders.Enabled {
+		cfg.AddFlow(reminderFlow())
+	}
+	if cfg.Timezone != nil {
+		cfg.AddFlow(timezoneFlow())
+	}
+	if cfg.I18n != nil {
+		cfg.AddFlow(languageFlow())
+	}
+}
+
+// setupBroadcastComposer registers the composer's handlers, provider, and validators.
+func (w *Wrapper) setupBroadcastComposer() {
+	w.flowEngine.RegisterValidator(composerButtons, func(value string, _ *conv.Conversation) error {
+		_, err := parseLinkButtons(value)
+		return err
+	})
+	w.flowEngine.RegisterValidator(composerWhen, func(value string, c *conv.Conversation) error {
+		_, err := parseScheduleTime(value, time.Now(), w.UserLocation(context.Background(), c.UserID))
+		return err
+	})
+	w.flowEngine.RegisterKeyboardProvider(composerTargets, w.composerTargetButtons)
+	w.flowEngine.RegisterStepHandler(composerPreview, w.composerPreview)
+	w.flowEngine.RegisterStepHandler(composerConfirm, w.composerConfirm)
+	w.flowEngine.RegisterStepHandler(composerSchedule, w.composerSchedule)
+	w.scheduler.Handle(scheduledBroadcastKind, w.runScheduledBroadcast)
+}
+
+// composerTargetButtons lists registered audiences with their recipient counts.
+func (w *Wrapper) composerTargetButtons(ctx context.Context, _ *conv.Conversation) []config.ButtonData {
+	var buttons []config.ButtonData
+	for _, name := range w.BroadcastTargets() {
+		text := name
+		if ids, err := w.ResolveBroadcastTarget(ctx, name); err == nil {
+			text = fmt.Sprintf("%s (%d)", name, len(ids))
+		}
+		buttons = append(buttons, config.ButtonData{Text: text, Callback: composerTargetPfx + name})
+	}
+	if len(buttons) == 0 {
+		buttons = append(buttons, config.ButtonData{Text: "📭 No audiences registered", Callback: core.CallbackNoop})
+	}
+	return buttons
+}
+
+// composerPreview sends the draft as recipients will see it, then asks for confirmation.
+func (w *Wrapper) composerPreview(ctx context.Context, c *conv.Conversation) error {
+	if !w.IsOperator(ctx, c.UserID) {
+		w.EndConversation(ctx, c.UserID, c.ChatID)
+		return nil
+	}
+
+	target := c.GetString("target")
+	if !strings.HasPrefix(target, composerTargetPfx) {
+		// Not an audience button (e.g. the empty placeholder); stay on this step
+		return w.showStepPrompt(ctx, c)
+	}
+	ids, err := w.ResolveBroadcastTarget(ctx, strings.TrimPrefix(target, composerTargetPfx))
+	if err != nil {
+		_, _ = w.bot.SendMessage(ctx, c.ChatID, c.TopicID, "❌ "+err.Error())
+		return err
+	}
+	c.Set("recipients", len(ids))
+
+	msg, err := composerDraft(c)
+	if err != nil {
+		return err
+	}
+
+	// Replace the prompt with the preview followed by a fresh confirmation prompt
+	if c.KeyboardMsgID > 0 {
+		_ = w.bot.DeleteMessage(ctx, c.ChatID, c.KeyboardMsgID)
+		c.UntrackMessage(c.KeyboardMsgID)
+		c.SetKeyboardMsgID(0)
+	}
+	if _, err := w.bot.SendMessageWithKeyboard(ctx, c.ChatID, c.TopicID, msg.Text, msg.Keyboard, msg.Entities...); err != nil {
+		return err
+	}
+
+	w.convManager.ChangeStepIn(ctx, c.UserID, c.ChatID, c.TopicID, "confirm")
+	return w.showStepPrompt(ctx, c)
+}
+
+// composerConfirm sends the broadcast immediately or moves on to scheduling.
+func (w *Wrapper) composerConfirm(ctx context.Context, c *conv.Conversation) error {
+	if !w.IsOperator(ctx, c.UserID) {
+		w.EndConversation(ctx, c.UserID, c.ChatID)
+		return nil
+	}
+
+	switch c.GetString("action") {
+	case "send":
+		return w.launchComposedBroadcast(ctx, c, time.Time{})
+	case "schedule":
+		w.convManager.ChangeStepIn(ctx, c.UserID, c.ChatID, c.TopicID, "schedule")
+		return w.showStepPrompt(ctx, c)
+	}
+	return nil
+}
+
+// composerSchedule schedules the broadcast for the entered time.
+func (w *Wrapper) composerSchedule(ctx context.Context, c *conv.Conversation) error {
+	if !w.IsOperator(ctx, c.UserID) {
+		w.EndConversation(ctx, c.UserID, c.ChatID)
+		return nil
+	}
+
+	at, err := parseScheduleTime(c.GetString("when"), time.Now(), w.UserLocation(ctx, c.UserID))
+	if err != nil {
+		return err
+	}
+	return w.launchComposedBroadcast(ctx, c, at)
+}
+
+// launchComposedBroadcast ends the composer conversation and runs the broadcast,
+// either now (zero at) or at the scheduled time, reporting progress in the composer message.
+// Scheduled broadcasts are persisted as delayed tasks, so they survive restarts.
+func (w *Wrapper) launchComposedBroadcast(ctx context.Context, c *conv.Conversation, at time.Time) error {
+	msg, err := composerDraft(c)
+	if err != nil {
+		return err
+	}
+	target := strings.TrimPrefix(c.GetString("target"), composerTargetPfx)
+	chatID, topicID, statusMsgID := c.ChatID, c.TopicID, c.KeyboardMsgID
+	w.EndConversation(ctx, c.UserID, c.ChatID)
+
+	if at.IsZero() {
+		// Broadcasts outlive the update that started them
+		bgCtx := context.WithoutCancel(ctx)
+		go func() {
+			ids, err := w.ResolveBroadcastTarget(bgCtx, target)
+			if err != nil {
+				_, _ = w.bot.SendMessage(bgCtx, chatID, topicID, "❌ Broadcast failed: "+err.Error())
+				return
+			}
+			w.runBroadcastWithProgress(bgCtx, chatID, topicID, statusMsgID, ids, msg)
+		}()
+		return nil
+	}
+
+	text := "⏰ Broadcast to " + target + " scheduled for " + w.FormatUserTime(ctx, c.UserID, at)
+	_, err = w.scheduler.At(ctx, at, scheduledBroadcastKind, scheduledBroadcast{
+		ChatID:   chatID,
+		TopicID:  topicID,
+		Target:   target,
+		Text:     msg.Text,
+		Entities: msg.Entities,
+		Keyboard: msg.Keyboard,
+	})
+	if err != nil {
+		text = "❌ Failed to schedule the broadcast: " + err.Error()
+	}
+	if statusMsgID > 0 {
+		_, _ = w.bot.EditMessage(ctx, chatID, statusMsgID, text)
+	} else {
+		_, _ = w.bot.SendMessage(ctx, chatID, topicID, text)
+	}
+	return nil
+}
+
+// runScheduledBroadcast runs a broadcast scheduled in the composer, reporting
+// progress in a new message below the schedule notice.
+func (w *Wrapper) runScheduledBroadcast(ctx context.Context, task *scheduler.Task) error {
+	var p scheduledBroadcast
+	if err := task.Decode(&p); err != nil {
+		return err
+	}
+	ids, err := w.ResolveBroadcastTarget(ctx, p.Target)
+	if err != nil {
+		_, _ = w.bot.SendMessage(ctx, p.ChatID, p.TopicID, "❌ Broadcast failed: "+err.Error())
+		return err
+	}
+	msg := BroadcastMessage{Text: p.Text, Entities: p.Entities, Keyboard: p.Keyboard}
+	w.runBroadcastWithProgress(ctx, p.ChatID, p.TopicID, 0, ids, msg)
+	return nil
+}
+
+// runBroadcastWithProgress runs a broadcast while editing a status message with its progress.
+// If statusMsgID is 0, a new status message is sent first.
+func (w *Wrapper) runBroadcastWithProgress(ctx context.Context, chatID int64, topicID, statusMsgID int, ids []int64, msg BroadcastMessage) BroadcastReport {
+	if statusMsgID == 0 {
+		sent, err := w.bot.SendMessage(ctx, chatID, topicID, formatBroadcastProgress(BroadcastReport{Total: len(ids)}, false))
+		if err == nil && sent != nil {
+			statusMsgID = sent.MessageID
+		}
+	}
+
+	var lastEdit time.Time
+	report := w.Broadcast(ctx, ids, msg, &BroadcastOptions{
+		OnProgress: func(r BroadcastReport) {
+			// Throttle edits so progress updates don't eat into the send rate
+			if statusMsgID == 0 || time.Since(lastEdit) < 2*time.Second {
+				return
+			}
+			lastEdit = time.Now()
+			_, _ = w.bot.EditMessage(ctx, chatID, statusMsgID, formatBroadcastProgress(r, false))
+		},
+	})
+
+	if statusMsgID > 0 {
+		_, _ = w.bot.EditMessage(ctx, chatID, statusMsgID, formatBroadcastProgress(report, true))
+	}
+	return report
+}
+
+// formatBroadcastProgress formats a broadcast status line.
+func formatBroadcastProgress(r BroadcastReport, done bool) string {
+	status := "📤 Sending broadcast…"
+	if done {
+		status = "✅ Broadcast finished"
+	}
+	return fmt.Sprintf("%s\n\n%d/%d processed\nSent: %d\nBlocked: %d\nSkipped: %d\nFailed: %d", status, r.Done(), r.Total, r.Sent, r.Blocked, r.Skipped, r.Failed)
+}
+
+// composerDraft assembles the broadcast message collected by the composer.
+func composerDraft(c *conv.Conversation) (BroadcastMessage, error) {
+	msg := BroadcastMessage{Text: c.GetString("text")}
+	if msg.Text == "" {
+		return msg, errors.New("broadcast text is empty")
+	}
+	if v, ok := c.Get("text_entities"); ok {
+		if entities, ok := v.([]telego.MessageEntity); ok {
+			msg.Entities = entities
+		}
+	}
+
+	buttons := c.GetString("buttons")
+	if buttons != "" && buttons != "skip" {
+		kb, err := parseLinkButtons(buttons)
+		if err != nil {
+			return msg, err
+		}
+		msg.Keyboard = kb
+	}
+	return msg, nil
+}
+
+// parseLinkButtons parses link buttons written one per line as "Text - URL".
+func parseLinkButtons(input string) (*telego.InlineKeyboardMarkup, error) {
+	if input == "skip" {
+		return nil, nil
+	}
+
+	kb := core.NewKeyboard()
+	for i, line := range strings.Split(input, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		idx := strings.LastIndex(line, " - ")
+		if idx <= 0 {
+			return nil, fmt.Errorf("line %d: use the format Text - https://example.com", i+1)
+		}
+		text := strings.TrimSpace(line[:idx])
+		url := strings.TrimSpace(line[idx+3:])
+		if !strings.HasPrefix(url, "https://") && !strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "tg://") {
+			return nil, fmt.Errorf("line %d: %q is not a valid link", i+1, url)
+		}
+		kb.URLButton(text, url)
+	}
+	return kb.Build(), nil
+}
+
+// parseScheduleTime parses a delay such as "30m" or an absolute time in scheduleLayout in loc.
+// The resulting time must be in the future.
+func parseScheduleTime(input string, now time.Time, loc *time.Location) (time.Time, error) {
+	input = strings.TrimSpace(input)
+
+	var at time.Time
+	if d, err := time.ParseDuration(input); err == nil {
+		at = now.Add(d)
+	} else if t, err := time.ParseInLocation(scheduleLayout, input, loc); err == nil {
+		at = t
+	} else if minutes, err := strconv.Atoi(input); err == nil {
+		at = now.Add(time.Duration(minutes) * time.Minute)
+	} else {
+		return time.Time{}, errors.New("Please enter a delay such as 30m or a time as " + scheduleLayout)
+	}
+
+	if !at.After(now) {
+		return time.Time{}, errors.New("The scheduled time must be in the future")
+	}
+	return at, nil
+}
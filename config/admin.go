@@ -0,0 +1,113 @@
+// Package config defines configuration structures for tgwrapper.
+package config
+
+// AdminConfig defines the built-in operator panel configuration.
+// When enabled, operators get a command that opens a panel with runtime
+// controls: maintenance mode, feature flags, broadcast composer, conversation stats,
+// and config reload, plus commands listing users stuck on a flow step,
+// moving or ending their conversations, previewing flows, reporting runtime
+// stats, and dumping conversation state.
+type AdminConfig struct {
+	// Enabled turns on the admin panel.
+	Enabled bool `json:"enabled" yaml:"enabled" mapstructure:"enabled"`
+
+	// Command is the command that opens the panel, without the leading slash.
+	// Defaults to "admin". The command is not registered in Telegram's command menu.
+	Command string `json:"command" yaml:"command" mapstructure:"command"`
+
+	// StuckCommand is the command listing the users waiting on a flow step,
+	// without the leading slash. Defaults to "stuck". Not registered in
+	// Telegram's command menu.
+	StuckCommand string `json:"stuck_command" yaml:"stuck_command" mapstructure:"stuck_command"`
+
+	// ForceCommand is the command moving a user's conversation to another
+	// step or ending it, without the leading slash. Defaults to "force".
+	// Not registered in Telegram's command menu.
+	ForceCommand string `json:"force_command" yaml:"force_command" mapstructure:"force_command"`
+
+	// PreviewCommand is the command running a flow as a sandbox for the
+	// operator, without the leading slash. Defaults to "preview".
+	// Not registered in Telegram's command menu.
+	PreviewCommand string `json:"preview_command" yaml:"preview_command" mapstructure:"preview_command"`
+
+	// StatsCommand is the command reporting runtime stats: active
+	// conversations, uptime, update counts, and the Telegram API error rate,
+	// without the leading slash. Defaults to "stats". Not registered in
+	// Telegram's command menu.
+	StatsCommand string `json:"stats_command" yaml:"stats_command" mapstructure:"stats_command"`
+
+	// DebugCommand is the command dumping the operator's conversation state in
+	// the chat, or another user's, without the leading slash. Defaults to
+	// "debugconv". Not registered in Telegram's command menu, and run during
+	// conversations whatever the command policy.
+	DebugCommand string `json:"debug_command" yaml:"debug_command" mapstructure:"debug_command"`
+
+	// Operators lists the user IDs allowed to use the panel.
+	// Operators also bypass maintenance mode.
+	Operators []int64 `json:"operators" yaml:"operators" mapstructure:"operators"`
+
+	// FeatureFlags lists the flags shown as toggles in the panel.
+	// Flag values are readable in conditions as flag.<name>.
+	FeatureFlags []string `json:"feature_flags" yaml:"feature_flags" mapstructure:"feature_flags"`
+
+	// MaintenanceText is the reply shown to users while maintenance mode is on.
+	MaintenanceText string `json:"maintenance_text" yaml:"maintenance_text" mapstructure:"maintenance_text"`
+}
+
+// GetCommand returns the panel command, defaulting to "admin".
+func (a *AdminConfig) GetCommand() string {
+	if a.Command == "" {
+		return "admin"
+	}
+	return a.Command
+}
+
+// GetStuckCommand returns the stuck users command, defaulting to "stuck".
+func (a *AdminConfig) GetStuckCommand() string {
+	if a.StuckCommand == "" {
+		return "stuck"
+	}
+	return a.StuckCommand
+}
+
+// GetForceCommand returns the force command, defaulting to "force".
+func (a *AdminConfig) GetForceCommand() string {
+	if a.ForceCommand == "" {
+		return "force"
+	}
+	return a.ForceCommand
+}
+
+// GetPreviewCommand returns the flow preview command, defaulting to "preview".
+func (a *AdminConfig) GetPreviewCommand() string {
+	if a.PreviewCommand == "" {
+		return "preview"
+	}
+	return a.PreviewCommand
+}
+
+// GetStatsCommand returns the runtime stats command, defaulting to "stats".
+func (a *AdminConfig) GetStatsCommand() string {
+	if a.StatsCommand == "" {
+		return "stats"
+	}
+	return a.StatsCommand
+}
+
+// GetDebugCommand returns the conversation dump command, defaulting to "debugconv".
+func (a *AdminConfig) GetDebugCommand() string {
+	if a.DebugCommand == "" {
+		return "debugconv"
+	}
+	return a.DebugCommand
+}
+
+// IsOperator returns true if the user is listed as an operator.
+func (a *AdminConfig) IsOperator(userID int64) bool {
+	for _, id := range a.Operators {
+		if id == userID {
+			return true
+		}
+	}
+	return false
+}
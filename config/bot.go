@@ -3,7 +3,13 @@
 // bot settings, menus, conversation flows, keyboards, and buttons.
 package config
 
-import "time"
+import (
+	"crypto/sha256"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
 
 // BotConfig defines the bot-level configuration settings.
 // This includes authentication credentials, command registration,
@@ -21,6 +27,93 @@ type BotConfig struct {
 	// Use this to send critical notifications to administrators.
 	WarningChat *ChatConfig `json:"warning_chat" yaml:"warning_chat" mapstructure:"warning_chat"`
 
+	// Escalation configures how repeated warnings escalate from the log chat to
+	// the warning chat and to admin mentions. Defaults apply if nil.
+	Escalation *EscalationConfig `json:"escalation" yaml:"escalation" mapstructure:"escalation"`
+
+	// Logging batches leveled log messages posted to the log and warning chats
+	// and warns about repeated Telegram API failures. Defaults apply if nil.
+	Logging *LoggingConfig `json:"logging" yaml:"logging" mapstructure:"logging"`
+
+	// APIAnomalies watches Telegram API error rates and alerts when the share
+	// of rate limited, failed, blocked, or unauthorized requests spikes.
+	// Disabled if nil.
+	APIAnomalies *APIAnomalyConfig `json:"api_anomalies" yaml:"api_anomalies" mapstructure:"api_anomalies"`
+
+	// EventLog persists router and flow events in the store for postmortems.
+	// Disabled if nil.
+	EventLog *EventLogConfig `json:"event_log" yaml:"event_log" mapstructure:"event_log"`
+
+	// UsageStats counts command uses per user in the store for usage
+	// statistics and leaderboards. Disabled if nil.
+	UsageStats *UsageStatsConfig `json:"usage_stats" yaml:"usage_stats" mapstructure:"usage_stats"`
+
+	// SLO sets latency budgets for handlers; calls exceeding their budget
+	// fire the slow handler hook. Disabled if nil.
+	SLO *SLOConfig `json:"slo" yaml:"slo" mapstructure:"slo"`
+
+	// Timeouts bound how long step handlers and keyboard providers may run
+	// before the bot falls back instead of waiting. Disabled if nil.
+	Timeouts *TimeoutConfig `json:"timeouts" yaml:"timeouts" mapstructure:"timeouts"`
+
+	// CircuitBreaker stops calling step handlers and keyboard providers whose
+	// upstream keeps failing, for a cool-down. Disabled if nil.
+	CircuitBreaker *BreakerConfig `json:"circuit_breaker" yaml:"circuit_breaker" mapstructure:"circuit_breaker"`
+
+	// RateLimit paces outgoing messages to stay within Telegram's limits.
+	// Defaults apply if nil.
+	RateLimit *RateLimitConfig `json:"rate_limit" yaml:"rate_limit" mapstructure:"rate_limit"`
+
+	// Dispatch bounds how many updates are processed at once and keeps the
+	// updates of one user in one chat in order. Defaults apply if nil.
+	Dispatch *DispatchConfig `json:"dispatch" yaml:"dispatch" mapstructure:"dispatch"`
+
+	// Updates selects where updates come from: long polling, a webhook, or a
+	// replay file. Long polling if nil.
+	Updates *UpdatesConfig `json:"updates" yaml:"updates" mapstructure:"updates"`
+
+	// Bus sets the message broker topics events are published to and
+	// outgoing messages consumed from. Only used once a broker is set with
+	// SetBus; nothing is published or consumed if nil.
+	Bus *BusConfig `json:"bus" yaml:"bus" mapstructure:"bus"`
+
+	// TicketSinks are HTTP/JSON ticket sinks by name, used by flows with a
+	// ticket configuration. Sinks registered from code take precedence.
+	TicketSinks map[string]*TicketSinkConfig `json:"ticket_sinks" yaml:"ticket_sinks" mapstructure:"ticket_sinks"`
+
+	// Providers are dynamic keyboard providers declared in configuration, by
+	// name. Providers registered from code take precedence.
+	Providers map[string]*ProviderConfig `json:"providers" yaml:"providers" mapstructure:"providers"`
+
+	// WebApp configures the menu button and the validation of Web App init data.
+	WebApp *WebAppConfig `json:"web_app" yaml:"web_app" mapstructure:"web_app"`
+
+	// Fork configures the per-user messages opened from shared group menus.
+	// Defaults apply if nil.
+	Fork *ForkConfig `json:"fork" yaml:"fork" mapstructure:"fork"`
+
+	// Cancel configures the built-in cancel command and what happens to other
+	// commands sent during a conversation. Defaults apply if nil.
+	Cancel *CancelConfig `json:"cancel" yaml:"cancel" mapstructure:"cancel"`
+
+	// OneShot protects one-shot action buttons, e.g. order confirmations,
+	// from double submits. Disabled if nil.
+	OneShot *OneShotConfig `json:"one_shot" yaml:"one_shot" mapstructure:"one_shot"`
+
+	// CallbackSecret is the key for signing sensitive callback data.
+	// If empty, a key is derived from the bot token.
+	CallbackSecret string `json:"callback_secret" yaml:"callback_secret" mapstructure:"callback_secret"`
+
+	// CallbackPayloadTTL is how long long callback data stays resolvable after
+	// the keyboard carrying it was last sent. Data over Telegram's 64-byte limit
+	// is stored in the store behind a short token; share the store between
+	// instances so any of them resolves it. Defaults to 7 days.
+	CallbackPayloadTTL time.Duration `json:"callback_payload_ttl" yaml:"callback_payload_ttl" mapstructure:"callback_payload_ttl"`
+
+	// ReportPanics sends handler panics, with their stack trace, to the warning
+	// chat through the escalation chain. Panics are always recovered and logged.
+	ReportPanics bool `json:"report_panics" yaml:"report_panics" mapstructure:"report_panics"`
+
 	// LogChat specifies the target chat for log messages.
 	// Use this for general logging and debugging information.
 	LogChat *ChatConfig `json:"log_chat" yaml:"log_chat" mapstructure:"log_chat"`
@@ -32,10 +125,73 @@ type BotConfig struct {
 	// Debug enables debug mode for verbose logging.
 	Debug bool `json:"debug" yaml:"debug" mapstructure:"debug"`
 
+	// WatchConfig reloads the configuration when the file it was loaded from
+	// changes, so menu and flow edits apply without a restart.
+	WatchConfig bool `json:"watch_config" yaml:"watch_config" mapstructure:"watch_config"`
+
 	// DeleteCommandsOnExit determines whether to delete all registered
 	// commands when the bot stops. Useful for development/testing.
 	DeleteCommandsOnExit bool `json:"delete_commands_on_exit" yaml:"delete_commands_on_exit" mapstructure:"delete_commands_on_exit"`
 
+	// StoreDir is the directory for the file-backed store holding persistent
+	// state such as chat settings. If empty, state is kept in memory.
+	StoreDir string `json:"store_dir" yaml:"store_dir" mapstructure:"store_dir"`
+
+	// StoreBackend selects how StoreDir is used: "file" (default) keeps one JSON
+	// file per key, "bolt" keeps everything in a single bbolt database file.
+	StoreBackend string `json:"store_backend" yaml:"store_backend" mapstructure:"store_backend"`
+
+	// PersistConversations saves active conversations in the store so flows
+	// survive restarts and can be shared by several bot instances.
+	PersistConversations bool `json:"persist_conversations" yaml:"persist_conversations" mapstructure:"persist_conversations"`
+
+	// TopicConversations scopes conversations per forum topic, so a user can run
+	// separate flows in different topics of the same group. By default a user has
+	// one conversation per chat, whichever topic it was started in.
+	TopicConversations bool `json:"topic_conversations" yaml:"topic_conversations" mapstructure:"topic_conversations"`
+
+	// ErrorThrottle is the minimum time between updates of a conversation's validation
+	// error message. Repeated invalid input edits a single error message instead of
+	// sending new ones; input arriving faster than this is only counted. Defaults to 1s.
+	ErrorThrottle time.Duration `json:"error_throttle" yaml:"error_throttle" mapstructure:"error_throttle"`
+
+	// ErrorDisplay is how validation errors are shown: "reply" (default) sends a
+	// separate error message, "inline" edits the error into the step prompt.
+	// Steps can override it with validation.error_display.
+	ErrorDisplay string `json:"error_display" yaml:"error_display" mapstructure:"error_display"`
+
+	// FlowCleanup sets what happens to the intermediate messages of flows
+	// without their own cleanup when a conversation ends: "delete",
+	// "strip_keyboards", or "mark_expired". Empty keeps them.
+	FlowCleanup CleanupMode `json:"flow_cleanup" yaml:"flow_cleanup" mapstructure:"flow_cleanup"`
+
+	// FlowSweep sets what happens to the last keyboard message of flows without
+	// their own sweep when a conversation expires or is cancelled: "delete",
+	// "strip_keyboards", or "mark_expired". Empty keeps it.
+	FlowSweep CleanupMode `json:"flow_sweep" yaml:"flow_sweep" mapstructure:"flow_sweep"`
+
+	// ExpiredFooter is appended to messages swept with "mark_expired".
+	// Defaults to DefaultExpiredFooter.
+	ExpiredFooter string `json:"expired_footer" yaml:"expired_footer" mapstructure:"expired_footer"`
+
+	// CooldownText is the reply when a command or flow is used again before its
+	// cooldown elapsed, rendered as a template with {{.retry_in}}.
+	// Defaults to DefaultCooldownText.
+	CooldownText string `json:"cooldown_text" yaml:"cooldown_text" mapstructure:"cooldown_text"`
+
+	// DailyLimitText is the reply when a command or flow reached its daily limit,
+	// rendered as a template with {{.retry_in}} and {{.limit}}.
+	// Defaults to DefaultDailyLimitText.
+	DailyLimitText string `json:"daily_limit_text" yaml:"daily_limit_text" mapstructure:"daily_limit_text"`
+
+	// NotYourMenuText is the alert when a user presses a keyboard bound to
+	// another user (see bind_user). Defaults to DefaultNotYourMenuText.
+	NotYourMenuText string `json:"not_your_menu_text" yaml:"not_your_menu_text" mapstructure:"not_your_menu_text"`
+
+	// ForbiddenText is the reply when a user lacks the role for a command, menu,
+	// button, or flow. Defaults to DefaultForbiddenText.
+	ForbiddenText string `json:"forbidden_text" yaml:"forbidden_text" mapstructure:"forbidden_text"`
+
 	// RegisterCommands determines whether to register commands on startup.
 	// Defaults to true if nil. Set to false to skip command registration.
 	RegisterCommands *bool `json:"register_commands" yaml:"register_commands" mapstructure:"register_commands"`
@@ -51,6 +207,906 @@ type ChatConfig struct {
 	// TopicID is the message thread ID for group topics (forum mode).
 	// Set to 0 for regular chats without topics.
 	TopicID int `json:"topic_id" yaml:"topic_id" mapstructure:"topic_id"`
+
+	// Retention optionally deletes the bot's own old messages in this chat,
+	// keeping alert channels from growing unbounded.
+	Retention *RetentionConfig `json:"retention" yaml:"retention" mapstructure:"retention"`
+}
+
+// RetentionConfig limits how long and how many of the bot's messages are kept in a chat.
+// Messages beyond either limit are deleted periodically.
+type RetentionConfig struct {
+	// MaxAge is the age after which the bot's messages are deleted. 0 disables.
+	MaxAge time.Duration `json:"max_age" yaml:"max_age" mapstructure:"max_age"`
+
+	// MaxCount is the number of most recent bot messages to keep. 0 disables.
+	MaxCount int `json:"max_count" yaml:"max_count" mapstructure:"max_count"`
+}
+
+// EscalationConfig defines an escalation chain for warnings. Warnings carry a
+// dedup key; repeats of a key go to the log chat first, to the warning chat
+// after WarningAfter occurrences, and mention Admins once the key has kept
+// occurring for MentionAfter.
+type EscalationConfig struct {
+	// WarningAfter is the number of occurrences of a key before it is sent to
+	// the warning chat instead of the log chat. 0 sends to the warning chat at once.
+	WarningAfter int `json:"warning_after" yaml:"warning_after" mapstructure:"warning_after"`
+
+	// MentionAfter is the time since a key's first occurrence after which Admins
+	// are mentioned. 0 disables mentions.
+	MentionAfter time.Duration `json:"mention_after" yaml:"mention_after" mapstructure:"mention_after"`
+
+	// Admins are the user IDs mentioned once a warning reaches MentionAfter.
+	Admins []int64 `json:"admins" yaml:"admins" mapstructure:"admins"`
+
+	// Silence is the minimum time between sends of a key at the same level;
+	// repeats within it are counted and reported with the next send.
+	// Defaults to DefaultWarningSilence.
+	Silence time.Duration `json:"silence" yaml:"silence" mapstructure:"silence"`
+
+	// ResetAfter is the quiet time after which a key's chain starts over.
+	// Defaults to DefaultWarningResetAfter.
+	ResetAfter time.Duration `json:"reset_after" yaml:"reset_after" mapstructure:"reset_after"`
+}
+
+// Default escalation timings.
+const (
+	// DefaultWarningSilence is the default minimum time between sends of a warning.
+	DefaultWarningSilence = 5 * time.Minute
+
+	// DefaultWarningResetAfter is the default quiet time after which a warning chain resets.
+	DefaultWarningResetAfter = time.Hour
+)
+
+// GetSilence returns the minimum time between sends of a warning.
+func (c *EscalationConfig) GetSilence() time.Duration {
+	if c == nil || c.Silence <= 0 {
+		return DefaultWarningSilence
+	}
+	return c.Silence
+}
+
+// GetResetAfter returns the quiet time after which a warning chain resets.
+func (c *EscalationConfig) GetResetAfter() time.Duration {
+	if c == nil || c.ResetAfter <= 0 {
+		return DefaultWarningResetAfter
+	}
+	return c.ResetAfter
+}
+
+// Log levels for LoggingConfig.Level, from the most to the least verbose.
+const (
+	LogLevelDebug = "debug"
+	LogLevelInfo  = "info"
+	LogLevelWarn  = "warn"
+	LogLevelError = "error"
+)
+
+// Default logging settings.
+const (
+	// DefaultLogFlushInterval is the default time log messages are collected before being posted.
+	DefaultLogFlushInterval = 10 * time.Second
+
+	// DefaultLogMaxBatch is the default number of log messages kept per chat between posts.
+	DefaultLogMaxBatch = 50
+
+	// DefaultAPIFailureThreshold is the default number of failures of the same
+	// Telegram API error within APIFailureWindow that triggers a warning.
+	DefaultAPIFailureThreshold = 5
+
+	// DefaultAPIFailureWindow is the default window API failures are counted in.
+	DefaultAPIFailureWindow = time.Minute
+)
+
+// LoggingConfig defines the log messages posted to the log and warning chats.
+// Debug and info messages go to the log chat, warn and error messages to the
+// warning chat, each falling back to the other. Messages are collected and
+// posted together every FlushInterval so bursts don't hit rate limits.
+type LoggingConfig struct {
+	// Level is the least severe level posted: "debug", "info" (default), "warn" or "error".
+	Level string `json:"level" yaml:"level" mapstructure:"level"`
+
+	// FlushInterval is how long messages are collected before being posted. Defaults to 10s.
+	FlushInterval time.Duration `json:"flush_interval" yaml:"flush_interval" mapstructure:"flush_interval"`
+
+	// MaxBatch is the number of messages kept per chat between posts; further
+	// messages are dropped and only counted. Defaults to 50.
+	MaxBatch int `json:"max_batch" yaml:"max_batch" mapstructure:"max_batch"`
+
+	// APIFailureThreshold is the number of failures of the same Telegram API
+	// error within APIFailureWindow that sends a warning. Blocked chats and
+	// canceled requests aren't counted. Defaults to 5; negative disables.
+	APIFailureThreshold int `json:"api_failure_threshold" yaml:"api_failure_threshold" mapstructure:"api_failure_threshold"`
+
+	// APIFailureWindow is the window API failures are counted in. Defaults to 1m.
+	APIFailureWindow time.Duration `json:"api_failure_window" yaml:"api_failure_window" mapstructure:"api_failure_window"`
+}
+
+// Kinds of failed Telegram API requests watched by APIAnomalyConfig.
+const (
+	// APIErrorRateLimited is a request rejected with 429 Too Many Requests.
+	APIErrorRateLimited = "rate_limited"
+
+	// APIErrorServer is a request failed with a 5xx server error.
+	APIErrorServer = "server"
+
+	// APIErrorBlocked is a request to a chat that blocked or removed the bot.
+	APIErrorBlocked = "blocked"
+
+	// APIErrorUnauthorized is a request rejected with 401 Unauthorized,
+	// usually because the bot token was revoked.
+	APIErrorUnauthorized = "unauthorized"
+
+	// APIErrorNetwork is a request that got no response from Telegram.
+	APIErrorNetwork = "network"
+)
+
+// Defaults for API anomaly detection.
+const (
+	DefaultAPIAnomalyWindow      = 5 * time.Minute
+	DefaultAPIAnomalyMinRequests = 20
+	DefaultAPIAnomalyCooldown    = 30 * time.Minute
+)
+
+// DefaultAPIAnomalyThresholds are the default error rates (0-1) that fire an
+// alert, by kind.
+var DefaultAPIAnomalyThresholds = map[string]float64{
+	APIErrorRateLimited:  0.05,
+	APIErrorServer:       0.2,
+	APIErrorBlocked:      0.5,
+	APIErrorUnauthorized: 0.5,
+	APIErrorNetwork:      0.5,
+}
+
+// APIAnomalyConfig configures the detection of Telegram API error spikes.
+type APIAnomalyConfig struct {
+	// Window is the sliding window error rates are computed over. Defaults to 5m.
+	Window time.Duration `json:"window" yaml:"window" mapstructure:"window"`
+
+	// MinRequests is the number of requests needed in the window before an
+	// alert fires, so a few errors at quiet times don't. Defaults to 20.
+	MinRequests int `json:"min_requests" yaml:"min_requests" mapstructure:"min_requests"`
+
+	// Cooldown is the minimum time between alerts of the same kind. Defaults to 30m.
+	Cooldown time.Duration `json:"cooldown" yaml:"cooldown" mapstructure:"cooldown"`
+
+	// Thresholds overrides the error rates (0-1) that fire an alert, by kind:
+	// "rate_limited", "server", "blocked", "unauthorized", or "network".
+	// A negative rate disables a kind.
+	Thresholds map[string]float64 `json:"thresholds" yaml:"thresholds" mapstructure:"thresholds"`
+
+	// WebhookURL receives every alert as a JSON POST, e.g. for a pager. It
+	// still works when Telegram can't be reached with the bot token.
+	WebhookURL string `json:"webhook_url" yaml:"webhook_url" mapstructure:"webhook_url"`
+}
+
+// Valid returns true if every threshold names a known kind and is at most 1.
+func (c *APIAnomalyConfig) Valid() bool {
+	if c == nil {
+		return true
+	}
+	for kind, rate := range c.Thresholds {
+		if _, ok := DefaultAPIAnomalyThresholds[kind]; !ok || rate > 1 {
+			return false
+		}
+	}
+	return true
+}
+
+// GetWindow returns the sliding window error rates are computed over.
+func (c *APIAnomalyConfig) GetWindow() time.Duration {
+	if c == nil || c.Window <= 0 {
+		return DefaultAPIAnomalyWindow
+	}
+	return c.Window
+}
+
+// GetMinRequests returns the number of requests needed before an alert fires.
+func (c *APIAnomalyConfig) GetMinRequests() int {
+	if c == nil || c.MinRequests <= 0 {
+		return DefaultAPIAnomalyMinRequests
+	}
+	return c.MinRequests
+}
+
+// GetCooldown returns the minimum time between alerts of the same kind.
+func (c *APIAnomalyConfig) GetCooldown() time.Duration {
+	if c == nil || c.Cooldown <= 0 {
+		return DefaultAPIAnomalyCooldown
+	}
+	return c.Cooldown
+}
+
+// GetThresholds returns the error rates that fire an alert, by kind, with
+// defaults for kinds not overridden. Disabled kinds are left out.
+func (c *APIAnomalyConfig) GetThresholds() map[string]float64 {
+	thresholds := make(map[string]float64, len(DefaultAPIAnomalyThresholds))
+	for kind, rate := range DefaultAPIAnomalyThresholds {
+		thresholds[kind] = rate
+	}
+	if c != nil {
+		for kind, rate := range c.Thresholds {
+			if rate < 0 {
+				delete(thresholds, kind)
+			} else if rate > 0 {
+				thresholds[kind] = rate
+			}
+		}
+	}
+	return thresholds
+}
+
+// Valid returns true if the level is empty or a known log level.
+func (c *LoggingConfig) Valid() bool {
+	if c == nil {
+		return true
+	}
+	switch c.Level {
+	case "", LogLevelDebug, LogLevelInfo, LogLevelWarn, LogLevelError:
+		return true
+	}
+	return false
+}
+
+// GetLevel returns the least severe level posted, defaulting to LogLevelInfo.
+func (c *LoggingConfig) GetLevel() string {
+	if c == nil || c.Level == "" {
+		return LogLevelInfo
+	}
+	return c.Level
+}
+
+// GetFlushInterval returns how long messages are collected before being posted.
+func (c *LoggingConfig) GetFlushInterval() time.Duration {
+	if c == nil || c.FlushInterval <= 0 {
+		return DefaultLogFlushInterval
+	}
+	return c.FlushInterval
+}
+
+// GetMaxBatch returns the number of messages kept per chat between posts.
+func (c *LoggingConfig) GetMaxBatch() int {
+	if c == nil || c.MaxBatch <= 0 {
+		return DefaultLogMaxBatch
+	}
+	return c.MaxBatch
+}
+
+// GetAPIFailureThreshold returns the number of API failures that sends a
+// warning, or 0 if API failure warnings are disabled.
+func (c *LoggingConfig) GetAPIFailureThreshold() int {
+	if c == nil || c.APIFailureThreshold == 0 {
+		return DefaultAPIFailureThreshold
+	}
+	if c.APIFailureThreshold < 0 {
+		return 0
+	}
+	return c.APIFailureThreshold
+}
+
+// GetAPIFailureWindow returns the window API failures are counted in.
+func (c *LoggingConfig) GetAPIFailureWindow() time.Duration {
+	if c == nil || c.APIFailureWindow <= 0 {
+		return DefaultAPIFailureWindow
+	}
+	return c.APIFailureWindow
+}
+
+// EventLogConfig configures the persisted event log.
+type EventLogConfig struct {
+	// Enabled turns on recording of router and flow events.
+	Enabled bool `json:"enabled" yaml:"enabled" mapstructure:"enabled"`
+
+	// Retention is how long events are kept. Defaults to DefaultEventRetention.
+	Retention time.Duration `json:"retention" yaml:"retention" mapstructure:"retention"`
+}
+
+// DefaultEventRetention is the default time events are kept in the event log.
+const DefaultEventRetention = 7 * 24 * time.Hour
+
+// IsEnabled returns true if events should be recorded.
+func (c *EventLogConfig) IsEnabled() bool {
+	return c != nil && c.Enabled
+}
+
+// GetRetention returns how long events are kept.
+func (c *EventLogConfig) GetRetention() time.Duration {
+	if c == nil || c.Retention <= 0 {
+		return DefaultEventRetention
+	}
+	return c.Retention
+}
+
+// UsageStatsConfig configures command usage statistics.
+type UsageStatsConfig struct {
+	// Enabled turns on counting of command uses.
+	Enabled bool `json:"enabled" yaml:"enabled" mapstructure:"enabled"`
+
+	// Retention is how long counts are kept. Defaults to DefaultUsageRetention.
+	Retention time.Duration `json:"retention" yaml:"retention" mapstructure:"retention"`
+
+	// Top is the number of commands and users listed in usage reports.
+	// Defaults to DefaultUsageTop.
+	Top int `json:"top" yaml:"top" mapstructure:"top"`
+}
+
+// DefaultUsageRetention is the default time command usage counts are kept.
+const DefaultUsageRetention = 30 * 24 * time.Hour
+
+// DefaultUsageTop is the default number of entries listed in usage reports.
+const DefaultUsageTop = 10
+
+// IsEnabled returns true if command uses should be counted.
+func (c *UsageStatsConfig) IsEnabled() bool {
+	return c != nil && c.Enabled
+}
+
+// GetRetention returns how long usage counts are kept.
+func (c *UsageStatsConfig) GetRetention() time.Duration {
+	if c == nil || c.Retention <= 0 {
+		return DefaultUsageRetention
+	}
+	return c.Retention
+}
+
+// GetTop returns the number of entries listed in usage reports.
+func (c *UsageStatsConfig) GetTop() int {
+	if c == nil || c.Top <= 0 {
+		return DefaultUsageTop
+	}
+	return c.Top
+}
+
+// SLOConfig defines latency budgets for handlers. Handler names are prefixed
+// by kind: "cmd:<command>", "callback:<data or prefix>", "step:<handler>",
+// "keyboard:<provider>", "llm:<completer>", "analyzer:<name>", "intent",
+// "transcriber", and "message", "photo", "document", or "voice" for the
+// default handlers.
+type SLOConfig struct {
+	// Default is the budget of handlers without their own. 0 leaves them unbudgeted.
+	Default time.Duration `json:"default" yaml:"default" mapstructure:"default"`
+
+	// Handlers are budgets by handler name.
+	Handlers map[string]time.Duration `json:"handlers" yaml:"handlers" mapstructure:"handlers"`
+}
+
+// Budget returns the latency budget of a handler, or 0 if it has none.
+func (c *SLOConfig) Budget(name string) time.Duration {
+	if c == nil {
+		return 0
+	}
+	if budget, ok := c.Handlers[name]; ok {
+		return budget
+	}
+	return c.Default
+}
+
+// DefaultTimeoutText is the apology sent when a step handler runs out of time.
+const DefaultTimeoutText = "⌛ This is taking longer than expected. Please try again in a moment."
+
+// TimeoutConfig defines handler timeouts, keyed by the same names as SLO
+// budgets ("step:<name>", "keyboard:<name>"). A timed out keyboard provider
+// is replaced by its last result or the keyboard's fallback buttons; a timed
+// out step handler is abandoned and the user receives Text.
+type TimeoutConfig struct {
+	// Default is the timeout of handlers without their own. 0 means no timeout.
+	Default time.Duration `json:"default" yaml:"default" mapstructure:"default"`
+
+	// Handlers are timeouts by handler name.
+	Handlers map[string]time.Duration `json:"handlers" yaml:"handlers" mapstructure:"handlers"`
+
+	// Text is sent when a step handler times out or its circuit breaker is open.
+	// Defaults to DefaultTimeoutText.
+	Text string `json:"text" yaml:"text" mapstructure:"text"`
+}
+
+// Timeout returns the timeout of a handler, or 0 if it has none.
+func (c *TimeoutConfig) Timeout(name string) time.Duration {
+	if c == nil {
+		return 0
+	}
+	if timeout, ok := c.Handlers[name]; ok {
+		return timeout
+	}
+	return c.Default
+}
+
+// GetText returns the message sent when a step handler times out.
+func (c *TimeoutConfig) GetText() string {
+	if c == nil || c.Text == "" {
+		return DefaultTimeoutText
+	}
+	return c.Text
+}
+
+// BreakerConfig defines circuit breakers for step handlers and keyboard providers.
+// A keyboard provider fails when it times out, a step handler when it times
+// out or returns an error. While a circuit is open, providers show their
+// timeout fallback and step handlers are skipped with the timeout text.
+type BreakerConfig struct {
+	// Threshold is the number of consecutive failures that opens a circuit. Defaults to 5.
+	Threshold int `json:"threshold" yaml:"threshold" mapstructure:"threshold"`
+
+	// Cooldown is how long an open circuit skips calls before probing again. Defaults to 30s.
+	Cooldown time.Duration `json:"cooldown" yaml:"cooldown" mapstructure:"cooldown"`
+
+	// Probes is the number of successful probe calls that close the circuit again. Defaults to 1.
+	Probes int `json:"probes" yaml:"probes" mapstructure:"probes"`
+
+	// Handlers are the guarded handler names, e.g. "keyboard:getCryptoPrices".
+	// If empty, all step handlers and keyboard providers are guarded.
+	Handlers []string `json:"handlers" yaml:"handlers" mapstructure:"handlers"`
+}
+
+// Guards returns true if the handler is guarded by a circuit breaker.
+func (c *BreakerConfig) Guards(name string) bool {
+	if c == nil {
+		return false
+	}
+	return len(c.Handlers) == 0 || slices.Contains(c.Handlers, name)
+}
+
+// RateLimitConfig defines the pacing of outgoing messages and edits.
+// Zero values use the defaults of core.Limits.
+type RateLimitConfig struct {
+	// GlobalRate is the number of messages per second sent across all chats. Defaults to 30.
+	GlobalRate int `json:"global_rate" yaml:"global_rate" mapstructure:"global_rate"`
+
+	// ChatInterval is the time between messages to one chat once its burst is used. Defaults to 1s.
+	ChatInterval time.Duration `json:"chat_interval" yaml:"chat_interval" mapstructure:"chat_interval"`
+
+	// ChatBurst is the number of messages sent to one chat without waiting. Defaults to 3.
+	ChatBurst int `json:"chat_burst" yaml:"chat_burst" mapstructure:"chat_burst"`
+
+	// MaxRetries is how often a request rejected with 429 Too Many Requests is retried. Defaults to 3.
+	MaxRetries int `json:"max_retries" yaml:"max_retries" mapstructure:"max_retries"`
+}
+
+// DefaultDispatchWorkers is the default number of updates processed at once.
+const DefaultDispatchWorkers = 64
+
+// DispatchConfig defines how incoming updates are processed.
+// Updates of one user in one chat are processed one at a time, in the order
+// Telegram sent them, so quick taps can't interleave conversation steps.
+// Updates of different users or chats run in parallel.
+type DispatchConfig struct {
+	// Workers is the number of updates processed at once. Defaults to 64.
+	Workers int `json:"workers" yaml:"workers" mapstructure:"workers"`
+
+	// Unordered processes the updates of one user in one chat in parallel too,
+	// only bounding them by Workers.
+	Unordered bool `json:"unordered" yaml:"unordered" mapstructure:"unordered"`
+
+	// Backpressure sheds and reorders updates while the workers are saturated.
+	// Disabled if nil.
+	Backpressure *BackpressureConfig `json:"backpressure" yaml:"backpressure" mapstructure:"backpressure"`
+
+	// Pools dedicate workers to some classes of updates, e.g. callbacks and
+	// conversation input, so a flood of group messages can't delay them.
+	// Updates of other classes run on the Workers shared workers.
+	Pools []DispatchPoolConfig `json:"pools" yaml:"pools" mapstructure:"pools"`
+}
+
+// DispatchPoolConfig defines a pool of workers dedicated to some classes of updates.
+type DispatchPoolConfig struct {
+	// Name identifies the pool in stats.
+	Name string `json:"name" yaml:"name" mapstructure:"name"`
+
+	// Workers is the number of updates of the pool processed at once. Defaults to 1.
+	Workers int `json:"workers" yaml:"workers" mapstructure:"workers"`
+
+	// Classes lists the classes of the updates the pool runs, see UpdateClasses.
+	Classes []string `json:"classes" yaml:"classes" mapstructure:"classes"`
+}
+
+// GetWorkers returns the number of updates processed at once.
+func (c *DispatchConfig) GetWorkers() int {
+	if c == nil || c.Workers <= 0 {
+		return DefaultDispatchWorkers
+	}
+	return c.Workers
+}
+
+// IsOrdered returns true if the updates of one user in one chat are processed in order.
+func (c *DispatchConfig) IsOrdered() bool {
+	return c == nil || !c.Unordered
+}
+
+// Valid returns true if the backpressure settings are valid, and pools have
+// unique names and list known classes, each in one pool only.
+func (c *DispatchConfig) Valid() bool {
+	if c == nil {
+		return true
+	}
+	names := make(map[string]bool)
+	classes := make(map[string]bool)
+	for _, pool := range c.Pools {
+		if pool.Name == "" || pool.Name == "shared" || names[pool.Name] {
+			return false
+		}
+		names[pool.Name] = true
+		for _, class := range pool.Classes {
+			if !slices.Contains(UpdateClasses, class) || classes[class] {
+				return false
+			}
+			classes[class] = true
+		}
+	}
+	return c.Backpressure.Valid()
+}
+
+// UpdateClasses lists the update classes BackpressureConfig.Shed and
+// DispatchPoolConfig.Classes accept.
+var UpdateClasses = []string{
+	"command", "message", "group_message", "conversation", "edited_message", "channel_post",
+	"callback", "inline_query", "chosen_inline_result", "member", "reaction",
+	"poll", "other",
+}
+
+// BackpressureConfig defines how updates are handled while the workers are
+// saturated. Backpressure starts once HighWater updates wait to be processed,
+// and ends once at most LowWater do.
+type BackpressureConfig struct {
+	// HighWater is the number of waiting updates starting backpressure.
+	// Defaults to the number of workers.
+	HighWater int `json:"high_water" yaml:"high_water" mapstructure:"high_water"`
+
+	// LowWater is the number of waiting updates ending backpressure.
+	// Defaults to half of HighWater.
+	LowWater int `json:"low_water" yaml:"low_water" mapstructure:"low_water"`
+
+	// Shed lists the classes of updates dropped under backpressure, e.g.
+	// "group_message" for chatter in groups, see UpdateClasses.
+	Shed []string `json:"shed" yaml:"shed" mapstructure:"shed"`
+
+	// PrioritizeCallbacks gives free workers to button presses before other
+	// updates, so menus stay responsive under load.
+	PrioritizeCallbacks bool `json:"prioritize_callbacks" yaml:"prioritize_callbacks" mapstructure:"prioritize_callbacks"`
+}
+
+// GetHighWater returns the number of waiting updates starting backpressure.
+// Returns 0 if backpressure is disabled.
+func (c *BackpressureConfig) GetHighWater(workers int) int {
+	if c == nil {
+		return 0
+	}
+	if c.HighWater <= 0 {
+		return workers
+	}
+	return c.HighWater
+}
+
+// GetLowWater returns the number of waiting updates ending backpressure.
+func (c *BackpressureConfig) GetLowWater(workers int) int {
+	high := c.GetHighWater(workers)
+	if c == nil || c.LowWater <= 0 || c.LowWater >= high {
+		return high / 2
+	}
+	return c.LowWater
+}
+
+// Valid returns true if Shed lists known classes only and LowWater is below HighWater.
+func (c *BackpressureConfig) Valid() bool {
+	if c == nil {
+		return true
+	}
+	if c.HighWater > 0 && c.LowWater >= c.HighWater {
+		return false
+	}
+	for _, class := range c.Shed {
+		if !slices.Contains(UpdateClasses, class) {
+			return false
+		}
+	}
+	return true
+}
+
+// Update sources for UpdatesConfig.Source.
+const (
+	// UpdateSourcePolling polls Telegram with getUpdates.
+	UpdateSourcePolling = "polling"
+
+	// UpdateSourceWebhook serves a webhook Telegram posts updates to.
+	UpdateSourceWebhook = "webhook"
+
+	// UpdateSourceReplay replays a file with one JSON update per line.
+	UpdateSourceReplay = "replay"
+)
+
+// UpdatesConfig defines where updates come from. Sources set from code with
+// SetUpdateSource, e.g. message queue bridges, take precedence.
+type UpdatesConfig struct {
+	// Source is "polling" (default), "webhook", or "replay".
+	Source string `json:"source" yaml:"source" mapstructure:"source"`
+
+	// WebhookURL is the public HTTPS URL Telegram posts updates to. Required for webhooks.
+	WebhookURL string `json:"webhook_url" yaml:"webhook_url" mapstructure:"webhook_url"`
+
+	// Listen is the address the webhook server listens on, e.g. ":8443". Required for webhooks.
+	Listen string `json:"listen" yaml:"listen" mapstructure:"listen"`
+
+	// Path is the path updates are posted to. Defaults to the path of WebhookURL.
+	Path string `json:"path" yaml:"path" mapstructure:"path"`
+
+	// SecretToken is sent by Telegram with every webhook request and checked.
+	SecretToken string `json:"secret_token" yaml:"secret_token" mapstructure:"secret_token"`
+
+	// ReplayFile is the file replayed. Required for replays.
+	ReplayFile string `json:"replay_file" yaml:"replay_file" mapstructure:"replay_file"`
+
+	// ReplayInterval is the pause between replayed updates.
+	ReplayInterval time.Duration `json:"replay_interval" yaml:"replay_interval" mapstructure:"replay_interval"`
+}
+
+// GetSource returns the update source.
+func (c *UpdatesConfig) GetSource() string {
+	if c == nil || c.Source == "" {
+		return UpdateSourcePolling
+	}
+	return c.Source
+}
+
+// Valid returns true if the source is known and has its required settings.
+func (c *UpdatesConfig) Valid() bool {
+	switch c.GetSource() {
+	case UpdateSourcePolling:
+		return true
+	case UpdateSourceWebhook:
+		return c.WebhookURL != "" && c.Listen != ""
+	case UpdateSourceReplay:
+		return c.ReplayFile != ""
+	}
+	return false
+}
+
+// BusConfig defines the message broker topics of the bot. Topics left empty
+// are not used.
+type BusConfig struct {
+	// UpdatesTopic receives a normalized event for every received update.
+	UpdatesTopic string `json:"updates_topic" yaml:"updates_topic" mapstructure:"updates_topic"`
+
+	// ConversationsTopic receives an event for every ended conversation, with its data.
+	ConversationsTopic string `json:"conversations_topic" yaml:"conversations_topic" mapstructure:"conversations_topic"`
+
+	// OutgoingTopic is consumed for commands to send messages.
+	OutgoingTopic string `json:"outgoing_topic" yaml:"outgoing_topic" mapstructure:"outgoing_topic"`
+
+	// ResultsTopic receives the outcome of outgoing messages carrying an ID.
+	ResultsTopic string `json:"results_topic" yaml:"results_topic" mapstructure:"results_topic"`
+
+	// IncludeRaw adds the update as sent by Telegram to update events.
+	IncludeRaw bool `json:"include_raw" yaml:"include_raw" mapstructure:"include_raw"`
+
+	// Buffer is the number of events waiting to be published before new ones
+	// are dropped. Defaults to 1024.
+	Buffer int `json:"buffer" yaml:"buffer" mapstructure:"buffer"`
+}
+
+// Default fork settings.
+const (
+	// DefaultForkTTL is how long a fork message in a group lives before it is deleted.
+	DefaultForkTTL = 10 * time.Minute
+
+	// DefaultForkDMText is the alert shown when a DM fork can't be sent because
+	// the user never started a private chat with the bot.
+	DefaultForkDMText = "💬 Start a private chat with me first, then press the button again."
+)
+
+// ForkConfig defines the per-user messages opened from shared group menus.
+type ForkConfig struct {
+	// TTL is how long a fork message in a group lives before it is deleted. Defaults to 10m.
+	// Forks sent as direct messages are kept.
+	TTL time.Duration `json:"ttl" yaml:"ttl" mapstructure:"ttl"`
+
+	// DMText is the alert shown when a direct message fork can't be sent.
+	DMText string `json:"dm_text" yaml:"dm_text" mapstructure:"dm_text"`
+}
+
+// GetTTL returns how long a fork message in a group lives.
+func (c *ForkConfig) GetTTL() time.Duration {
+	if c == nil || c.TTL <= 0 {
+		return DefaultForkTTL
+	}
+	return c.TTL
+}
+
+// GetDMText returns the alert shown when a direct message fork can't be sent.
+func (c *ForkConfig) GetDMText() string {
+	if c == nil || c.DMText == "" {
+		return DefaultForkDMText
+	}
+	return c.DMText
+}
+
+// Default one-shot settings.
+const (
+	// DefaultOneShotTTL is how long a pressed one-shot button stays consumed.
+	DefaultOneShotTTL = time.Minute
+
+	// DefaultOneShotText is the alert shown when a consumed button is pressed again.
+	DefaultOneShotText = "✅ Already processed."
+)
+
+// OneShotConfig defines buttons whose action must run only once per message.
+// Once such a button is pressed, further presses of it on the same message
+// within TTL get an alert instead of running the action again.
+type OneShotConfig struct {
+	// Callbacks are the callback data prefixes of one-shot buttons, e.g. "order:confirm".
+	Callbacks []string `json:"callbacks" yaml:"callbacks" mapstructure:"callbacks"`
+
+	// TTL is how long a pressed button stays consumed. Defaults to DefaultOneShotTTL.
+	TTL time.Duration `json:"ttl" yaml:"ttl" mapstructure:"ttl"`
+
+	// Text is the alert shown on repeated presses. Defaults to DefaultOneShotText.
+	Text string `json:"text" yaml:"text" mapstructure:"text"`
+}
+
+// Matches reports whether callback data belongs to a one-shot button.
+func (c *OneShotConfig) Matches(data string) bool {
+	if c == nil {
+		return false
+	}
+	return slices.ContainsFunc(c.Callbacks, func(prefix string) bool {
+		return strings.HasPrefix(data, prefix)
+	})
+}
+
+// GetTTL returns how long a pressed button stays consumed.
+func (c *OneShotConfig) GetTTL() time.Duration {
+	if c == nil || c.TTL <= 0 {
+		return DefaultOneShotTTL
+	}
+	return c.TTL
+}
+
+// GetText returns the alert shown on repeated presses.
+func (c *OneShotConfig) GetText() string {
+	if c == nil || c.Text == "" {
+		return DefaultOneShotText
+	}
+	return c.Text
+}
+
+// Command policies for CancelConfig.CommandPolicy.
+const (
+	// CommandPolicyRun runs commands during a conversation and keeps the conversation.
+	CommandPolicyRun = "run"
+
+	// CommandPolicyCancel cancels the conversation, then runs the command.
+	CommandPolicyCancel = "cancel"
+
+	// CommandPolicyIgnore drops the command and reminds the user to finish or cancel.
+	CommandPolicyIgnore = "ignore"
+
+	// CommandPolicyQueue holds the command and runs it once the conversation ends.
+	CommandPolicyQueue = "queue"
+)
+
+// Default cancel settings.
+const (
+	// DefaultCancelCommand is the default name of the cancel command.
+	DefaultCancelCommand = "cancel"
+
+	// DefaultCancelText is sent when the cancel command ended a conversation.
+	DefaultCancelText = "❌ Cancelled."
+
+	// DefaultNothingToCancelText is sent when the cancel command finds no conversation.
+	DefaultNothingToCancelText = "There's nothing to cancel."
+
+	// DefaultCommandBusyText is sent for commands dropped by CommandPolicyIgnore;
+	// %s is the cancel command.
+	DefaultCommandBusyText = "✋ Finish the current step first, or send %s to stop it."
+
+	// DefaultCommandQueuedText is sent for commands held by CommandPolicyQueue;
+	// %s is the cancel command.
+	DefaultCommandQueuedText = "⏳ I'll do that once you finish, or send %s to stop now."
+)
+
+// CancelConfig defines the built-in cancel command and the policy for
+// commands sent during a conversation.
+type CancelConfig struct {
+	// Command is the name of the command ending the active conversation,
+	// without the leading slash. Defaults to "cancel".
+	Command string `json:"command" yaml:"command" mapstructure:"command"`
+
+	// Disabled turns the built-in cancel command off.
+	Disabled bool `json:"disabled" yaml:"disabled" mapstructure:"disabled"`
+
+	// Text is sent after a conversation was cancelled. Defaults to DefaultCancelText.
+	Text string `json:"text" yaml:"text" mapstructure:"text"`
+
+	// NothingText is sent if there was no conversation to cancel.
+	// Defaults to DefaultNothingToCancelText.
+	NothingText string `json:"nothing_text" yaml:"nothing_text" mapstructure:"nothing_text"`
+
+	// CommandPolicy decides what happens to other commands sent during a
+	// conversation: "run" (default), "cancel", "ignore", or "queue".
+	CommandPolicy string `json:"command_policy" yaml:"command_policy" mapstructure:"command_policy"`
+
+	// AllowCommands are run during a conversation whatever the policy, e.g. "help".
+	AllowCommands []string `json:"allow_commands" yaml:"allow_commands" mapstructure:"allow_commands"`
+
+	// BusyText is sent for commands dropped by the "ignore" policy.
+	// Defaults to DefaultCommandBusyText.
+	BusyText string `json:"busy_text" yaml:"busy_text" mapstructure:"busy_text"`
+
+	// QueuedText is sent for commands held by the "queue" policy.
+	// Defaults to DefaultCommandQueuedText.
+	QueuedText string `json:"queued_text" yaml:"queued_text" mapstructure:"queued_text"`
+}
+
+// Valid returns true if the command policy is empty or known.
+func (c *CancelConfig) Valid() bool {
+	if c == nil {
+		return true
+	}
+	switch c.CommandPolicy {
+	case "", CommandPolicyRun, CommandPolicyCancel, CommandPolicyIgnore, CommandPolicyQueue:
+		return true
+	}
+	return false
+}
+
+// IsEnabled returns true if the built-in cancel command is registered.
+func (c *CancelConfig) IsEnabled() bool {
+	return c == nil || !c.Disabled
+}
+
+// GetCommand returns the name of the cancel command.
+func (c *CancelConfig) GetCommand() string {
+	if c == nil || c.Command == "" {
+		return DefaultCancelCommand
+	}
+	return c.Command
+}
+
+// GetText returns the text sent after a conversation was cancelled.
+func (c *CancelConfig) GetText() string {
+	if c == nil || c.Text == "" {
+		return DefaultCancelText
+	}
+	return c.Text
+}
+
+// GetNothingText returns the text sent if there was no conversation to cancel.
+func (c *CancelConfig) GetNothingText() string {
+	if c == nil || c.NothingText == "" {
+		return DefaultNothingToCancelText
+	}
+	return c.NothingText
+}
+
+// GetCommandPolicy returns the policy for commands sent during a conversation.
+func (c *CancelConfig) GetCommandPolicy() string {
+	if c == nil || c.CommandPolicy == "" {
+		return CommandPolicyRun
+	}
+	return c.CommandPolicy
+}
+
+// Allows returns true if a command runs during a conversation whatever the
+// policy: the cancel command and AllowCommands.
+func (c *CancelConfig) Allows(command string) bool {
+	if command == c.GetCommand() {
+		return true
+	}
+	return c != nil && slices.Contains(c.AllowCommands, command)
+}
+
+// GetBusyText returns the text sent for commands dropped by the "ignore" policy.
+func (c *CancelConfig) GetBusyText() string {
+	if c == nil || c.BusyText == "" {
+		return fmt.Sprintf(DefaultCommandBusyText, "/"+c.GetCommand())
+	}
+	return c.BusyText
+}
+
+// GetQueuedText returns the text sent for commands held by the "queue" policy.
+func (c *CancelConfig) GetQueuedText() string {
+	if c == nil || c.QueuedText == "" {
+		return fmt.Sprintf(DefaultCommandQueuedText, "/"+c.GetCommand())
+	}
+	return c.QueuedText
 }
 
 // CmdConfig defines a single bot command configuration.
@@ -76,6 +1132,15 @@ type CmdConfig struct {
 	// For "show_menu": the menu ID to show
 	// For "start_flow": the flow ID to start
 	Target string `json:"target" yaml:"target" mapstructure:"target"`
+
+	// Cooldown is the minimum time between uses of the command per user.
+	Cooldown time.Duration `json:"cooldown" yaml:"cooldown" mapstructure:"cooldown"`
+
+	// DailyLimit is the maximum number of uses of the command per user per UTC day.
+	DailyLimit int `json:"daily_limit" yaml:"daily_limit" mapstructure:"daily_limit"`
+
+	// Roles restricts the command to users holding any of these roles.
+	Roles []string `json:"roles" yaml:"roles" mapstructure:"roles"`
 }
 
 // CallbackConfig defines a callback handler configuration.
@@ -116,9 +1181,152 @@ func (c *BotConfig) Validate() error {
 	if c.Token == "" {
 		return ErrEmptyToken
 	}
+	if !c.FlowCleanup.Valid() || !c.FlowSweep.Valid() {
+		return ErrInvalidFlow
+	}
+	if !c.Logging.Valid() {
+		return ErrInvalidLogging
+	}
+	if !c.APIAnomalies.Valid() {
+		return ErrInvalidAPIAnomalies
+	}
+	if !c.Cancel.Valid() {
+		return ErrInvalidCancel
+	}
+	if !c.Dispatch.Valid() {
+		return ErrInvalidDispatch
+	}
+	if !c.Updates.Valid() {
+		return ErrInvalidUpdates
+	}
+	for _, sink := range c.TicketSinks {
+		if !sink.Valid() {
+			return ErrInvalidTicket
+		}
+	}
+	for _, p := range c.Providers {
+		if !p.Valid() {
+			return ErrInvalidProvider
+		}
+	}
+	if !c.WebApp.Valid() {
+		return ErrInvalidWebApp
+	}
+	return nil
+}
+
+// Store backends for StoreBackend.
+const (
+	// StoreBackendFile stores each key as a JSON file in StoreDir.
+	StoreBackendFile = "file"
+
+	// StoreBackendBolt stores all keys in StoreDir/BoltFileName.
+	StoreBackendBolt = "bolt"
+
+	// BoltFileName is the database file name used by the bolt backend.
+	BoltFileName = "tgwrapper.db"
+)
+
+// GetStoreBackend returns the store backend, defaulting to StoreBackendFile.
+func (c *BotConfig) GetStoreBackend() string {
+	if c.StoreBackend == "" {
+		return StoreBackendFile
+	}
+	return c.StoreBackend
+}
+
+// Default replies for usage limits.
+const (
+	// DefaultCooldownText is shown when a command or flow is still cooling down.
+	DefaultCooldownText = "⏳ Please try again in {{.retry_in}}."
+
+	// DefaultDailyLimitText is shown when a command or flow reached its daily limit.
+	DefaultDailyLimitText = "🚫 You've reached today's limit of {{.limit}}. Try again in {{.retry_in}}."
+
+	// DefaultNotYourMenuText is shown when a user presses a keyboard bound to another user.
+	DefaultNotYourMenuText = "🙅 This menu belongs to someone else."
+
+	// DefaultExpiredFooter is appended to messages swept with "mark_expired".
+	DefaultExpiredFooter = "⌛ This session has expired."
+)
+
+// GetErrorThrottle returns the validation error update interval, defaulting to 1 second.
+func (c *BotConfig) GetErrorThrottle() time.Duration {
+	if c.ErrorThrottle <= 0 {
+		return time.Second
+	}
+	return c.ErrorThrottle
+}
+
+// GetCooldownText returns the cooldown reply template.
+func (c *BotConfig) GetCooldownText() string {
+	if c.CooldownText == "" {
+		return DefaultCooldownText
+	}
+	return c.CooldownText
+}
+
+// GetDailyLimitText returns the daily limit reply template.
+func (c *BotConfig) GetDailyLimitText() string {
+	if c.DailyLimitText == "" {
+		return DefaultDailyLimitText
+	}
+	return c.DailyLimitText
+}
+
+// GetNotYourMenuText returns the alert for keyboards bound to another user.
+func (c *BotConfig) GetNotYourMenuText() string {
+	if c.NotYourMenuText == "" {
+		return DefaultNotYourMenuText
+	}
+	return c.NotYourMenuText
+}
+
+// GetExpiredFooter returns the footer appended to messages swept with "mark_expired".
+func (c *BotConfig) GetExpiredFooter() string {
+	if c.ExpiredFooter == "" {
+		return DefaultExpiredFooter
+	}
+	return c.ExpiredFooter
+}
+
+// GetForbiddenText returns the reply when a user lacks a required role.
+func (c *BotConfig) GetForbiddenText() string {
+	if c.ForbiddenText == "" {
+		return DefaultForbiddenText
+	}
+	return c.ForbiddenText
+}
+
+// GetCommand returns the configuration of a command by name.
+// Returns nil if the command is not configured.
+func (c *BotConfig) GetCommand(command string) *CmdConfig {
+	for i := range c.Commands {
+		if c.Commands[i].Command == command {
+			return &c.Commands[i]
+		}
+	}
 	return nil
 }
 
+// GetCallbackPayloadTTL returns how long long callback data stays resolvable, defaulting to 7 days.
+func (c *BotConfig) GetCallbackPayloadTTL() time.Duration {
+	if c.CallbackPayloadTTL <= 0 {
+		return 7 * 24 * time.Hour
+	}
+	return c.CallbackPayloadTTL
+}
+
+// GetCallbackSecret returns the key for signing callback data, derived from
+// the bot token if no callback secret is configured.
+func (c *BotConfig) GetCallbackSecret() []byte {
+	if c.CallbackSecret != "" {
+		return []byte(c.CallbackSecret)
+	}
+	sum := sha256.Sum256([]byte("callback-signing:" + c.Token))
+	return sum[:]
+}
+
 // HasWarningChat returns true if a warning chat is configured.
 func (c *BotConfig) HasWarningChat() bool {
 	return c.WarningChat != nil && c.WarningChat.ChatID != 0
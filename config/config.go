@@ -3,6 +3,7 @@ package config
 
 import (
 	"encoding/json"
+	"fmt"
 	"os"
 
 	"gopkg.in/yaml.v3"
@@ -33,6 +34,50 @@ type Config struct {
 	// Environment contains custom variables for conditional logic.
 	// These can be accessed in condition expressions.
 	Environment map[string]interface{} `json:"environment" yaml:"environment" mapstructure:"environment"`
+
+	// Tenants is a map of per-chat override layers keyed by tenant ID.
+	// Chats listed in a tenant see its menus, texts, and flow parameters
+	// instead of the base ones; everything else is shared.
+	Tenants map[string]*TenantConfig `json:"tenants" yaml:"tenants" mapstructure:"tenants"`
+
+	// Segments is a map of user segment definitions keyed by segment ID.
+	// Each segment is registered as a broadcast audience.
+	Segments map[string]*SegmentConfig `json:"segments" yaml:"segments" mapstructure:"segments"`
+
+	// Votes is a map of vote configurations keyed by vote ID.
+	// Votes are posted with Wrapper.StartVote.
+	Votes map[string]*VoteConfig `json:"votes" yaml:"votes" mapstructure:"votes"`
+
+	// RSVPs is a map of event signup sheet configurations keyed by ID.
+	// Sheets are posted with Wrapper.StartRSVP.
+	RSVPs map[string]*RSVPConfig `json:"rsvps" yaml:"rsvps" mapstructure:"rsvps"`
+
+	// Referral configures referral tracking through deep links and invite links.
+	Referral *ReferralConfig `json:"referral" yaml:"referral" mapstructure:"referral"`
+
+	// Admin configures the built-in operator panel.
+	Admin *AdminConfig `json:"admin" yaml:"admin" mapstructure:"admin"`
+
+	// Reminders configures user reminders created with the built-in reminder flow.
+	Reminders *ReminderConfig `json:"reminders" yaml:"reminders" mapstructure:"reminders"`
+
+	// Timezone configures per-user time zones and the built-in time zone flow.
+	Timezone *TimezoneConfig `json:"timezone" yaml:"timezone" mapstructure:"timezone"`
+
+	// Theme overrides the built-in texts of navigation buttons and pagination,
+	// globally and per language.
+	Theme *ThemeConfig `json:"theme" yaml:"theme" mapstructure:"theme"`
+
+	// I18n configures message catalogs, per-user languages, and the built-in
+	// language flow.
+	I18n *I18nConfig `json:"i18n" yaml:"i18n" mapstructure:"i18n"`
+
+	// Roles is a map of role members keyed by role name. Commands, menus,
+	// buttons, and flows with roles are restricted to users holding one of them.
+	// The "admin" role passes every check; "user" is held by everyone.
+	Roles map[string]*RoleConfig `json:"roles" yaml:"roles" mapstructure:"roles"`
+
+	path string // File path the configuration was loaded from (empty if built in code)
 }
 
 // NewConfig creates a new empty configuration with initialized maps.
@@ -51,7 +96,18 @@ func LoadFromFile(path string) (*Config, error) {
 	if err != nil {
 		return nil, err
 	}
-	return LoadFromBytes(data, path)
+	cfg, err := LoadFromBytes(data, path)
+	if err != nil {
+		return nil, err
+	}
+	cfg.path = path
+	return cfg, nil
+}
+
+// Path returns the file path the configuration was loaded from.
+// Returns empty string if the configuration was not loaded with LoadFromFile.
+func (c *Config) Path() string {
+	return c.path
 }
 
 // LoadFromBytes loads configuration from byte data.
@@ -92,6 +148,95 @@ func (c *Config) Validate() error {
 		if err := flow.Validate(); err != nil {
 			return err
 		}
+		if flow.Rollout != nil {
+			if err := flow.Rollout.validate(c, flow.ID); err != nil {
+				return err
+			}
+		}
+		for _, step := range flow.Steps {
+			if step.SubFlow == nil {
+				continue
+			}
+			if step.SubFlow.FlowID == "" {
+				return ErrInvalidStep
+			}
+			if c.GetFlow(step.SubFlow.FlowID) == nil {
+				return ErrFlowNotFound
+			}
+		}
+	}
+
+	tenantOf := make(map[int64]string)
+	for _, id := range sortedKeys(c.Tenants) {
+		tenant := c.Tenants[id]
+		if err := tenant.Validate(); err != nil {
+			return err
+		}
+		for _, chatID := range tenant.ChatIDs {
+			if other, ok := tenantOf[chatID]; ok && other != tenant.ID {
+				return fmt.Errorf("%w: chat %d is in tenants %q and %q", ErrInvalidTenant, chatID, other, tenant.ID)
+			}
+			tenantOf[chatID] = tenant.ID
+		}
+		if tenant.MainMenuID != "" && tenant.Menus[tenant.MainMenuID] == nil && c.GetMenu(tenant.MainMenuID) == nil {
+			return fmt.Errorf("%w: tenant %q main_menu_id names %q", ErrMenuNotFound, tenant.ID, tenant.MainMenuID)
+		}
+		for flowID, override := range tenant.Flows {
+			flow := c.GetFlow(flowID)
+			if flow == nil {
+				return ErrFlowNotFound
+			}
+			if override != nil && override.InitialStep != "" && flow.GetStep(override.InitialStep) == nil {
+				return ErrStepNotFound
+			}
+		}
+	}
+
+	// Check the step graph of every flow, reporting all broken references at once
+	if err := c.CheckFlows(nil).Err(); err != nil {
+		return err
+	}
+
+	for _, segment := range c.Segments {
+		if err := segment.Validate(); err != nil {
+			return err
+		}
+	}
+
+	for _, vote := range c.Votes {
+		if err := vote.Validate(); err != nil {
+			return err
+		}
+	}
+
+	for _, sheet := range c.RSVPs {
+		if err := sheet.Validate(); err != nil {
+			return err
+		}
+	}
+
+	if c.Reminders != nil {
+		if err := c.Reminders.Validate(); err != nil {
+			return err
+		}
+	}
+
+	if c.Timezone != nil {
+		if err := c.Timezone.Validate(); err != nil {
+			return err
+		}
+	}
+
+	if c.Theme != nil {
+		if err := c.Theme.Validate(); err != nil {
+			return err
+		}
+	}
+
+	if c.I18n != nil {
+		if err := c.I18n.Validate(); err != nil {
+			return err
+		}
 	}
 
 	return nil
@@ -118,6 +263,18 @@ func (c *Config) GetMainMenu() *MenuConfig {
 	return c.Menus[c.MainMenuID]
 }
 
+// GetVote retrieves a vote configuration by ID.
+// Returns nil if the vote doesn't exist.
+func (c *Config) GetVote(id string) *VoteConfig {
+	return c.Votes[id]
+}
+
+// GetRSVP retrieves an RSVP configuration by ID.
+// Returns nil if the RSVP doesn't exist.
+func (c *Config) GetRSVP(id string) *RSVPConfig {
+	return c.RSVPs[id]
+}
+
 // AddMenu adds a menu to the configuration.
 // The menu's ID is used as the map key.
 func (c *Config) AddMenu(menu *MenuConfig) {
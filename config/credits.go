@@ -0,0 +1,58 @@
+// Package config defines configuration structures for tgwrapper.
+package config
+
+// Credit charge timings for flows.
+const (
+	// ChargeOnStart deducts the cost when the flow starts.
+	ChargeOnStart = "start"
+
+	// ChargeOnComplete holds the cost when the flow starts and deducts it when
+	// the flow completes. Abandoned or cancelled flows release the hold, so
+	// they are free.
+	ChargeOnComplete = "complete"
+)
+
+// DefaultInsufficientCreditsText is shown when a user cannot afford a flow.
+const DefaultInsufficientCreditsText = "💳 Not enough credits. You need {{.required}}, your balance is {{.balance}}."
+
+// CreditsConfig defines the credit requirements and cost of a flow.
+// Balances are kept in the wrapper's ledger.
+type CreditsConfig struct {
+	// Cost is the amount deducted from the user's balance.
+	Cost int64 `json:"cost" yaml:"cost" mapstructure:"cost"`
+
+	// Require is the minimum balance needed to start the flow.
+	// Defaults to Cost.
+	Require int64 `json:"require" yaml:"require" mapstructure:"require"`
+
+	// ChargeOn is when the cost is deducted: "start" or "complete" (default).
+	ChargeOn string `json:"charge_on" yaml:"charge_on" mapstructure:"charge_on"`
+
+	// InsufficientText is shown when the balance is too low, rendered as a template
+	// with {{.required}} and {{.balance}}. Defaults to DefaultInsufficientCreditsText.
+	InsufficientText string `json:"insufficient_text" yaml:"insufficient_text" mapstructure:"insufficient_text"`
+}
+
+// GetRequire returns the minimum balance needed to start, defaulting to Cost.
+func (c *CreditsConfig) GetRequire() int64 {
+	if c.Require > 0 {
+		return c.Require
+	}
+	return c.Cost
+}
+
+// GetChargeOn returns when the cost is deducted, defaulting to ChargeOnComplete.
+func (c *CreditsConfig) GetChargeOn() string {
+	if c.ChargeOn == ChargeOnStart {
+		return ChargeOnStart
+	}
+	return ChargeOnComplete
+}
+
+// GetInsufficientText returns the low-balance message template.
+func (c *CreditsConfig) GetInsufficientText() string {
+	if c.InsufficientText == "" {
+		return DefaultInsufficientCreditsText
+	}
+	return c.InsufficientText
+}
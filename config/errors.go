@@ -18,12 +18,77 @@ var (
 	// ErrInvalidMenu is returned when a menu configuration is malformed.
 	ErrInvalidMenu = errors.New("invalid menu configuration")
 
+	// ErrInvalidTenant is returned when a tenant configuration is malformed.
+	ErrInvalidTenant = errors.New("invalid tenant configuration")
+
+	// ErrInvalidSegment is returned when a segment configuration is malformed.
+	ErrInvalidSegment = errors.New("invalid segment configuration")
+
+	// ErrInvalidVote is returned when a vote configuration is malformed.
+	ErrInvalidVote = errors.New("invalid vote configuration")
+
+	// ErrInvalidRSVP is returned when an RSVP configuration is malformed.
+	ErrInvalidRSVP = errors.New("invalid rsvp configuration")
+
+	// ErrInvalidReminder is returned when the reminder configuration is malformed.
+	ErrInvalidReminder = errors.New("invalid reminder configuration")
+
+	// ErrInvalidTimezone is returned when the time zone configuration is malformed.
+	ErrInvalidTimezone = errors.New("invalid timezone configuration")
+
+	// ErrInvalidLogging is returned when the logging configuration has an unknown level.
+	ErrInvalidLogging = errors.New("invalid logging configuration")
+
+	// ErrInvalidAPIAnomalies is returned when an API anomaly threshold names an
+	// unknown error kind or exceeds 1.
+	ErrInvalidAPIAnomalies = errors.New("invalid api_anomalies configuration")
+
+	// ErrInvalidCancel is returned when the cancel configuration has an unknown command policy.
+	ErrInvalidCancel = errors.New("invalid cancel configuration")
+
+	// ErrInvalidDispatch is returned when the backpressure settings shed an
+	// unknown update class or end backpressure above where it starts, or when
+	// worker pools are unnamed or share a name or class.
+	ErrInvalidDispatch = errors.New("invalid dispatch configuration")
+
+	// ErrInvalidUpdates is returned when the update source is unknown or lacks
+	// its webhook URL and listen address, or its replay file.
+	ErrInvalidUpdates = errors.New("invalid updates configuration")
+
+	// ErrInvalidTicket is returned when a flow files tickets on an unknown
+	// outcome or a ticket sink has no URL.
+	ErrInvalidTicket = errors.New("invalid ticket configuration")
+
+	// ErrInvalidProvider is returned when a provider declared in configuration
+	// has an unknown type, or lacks its URL or label path.
+	ErrInvalidProvider = errors.New("invalid provider configuration")
+
+	// ErrInvalidWebApp is returned when the menu button has an unknown type,
+	// or a Web App menu button lacks its label or HTTPS URL.
+	ErrInvalidWebApp = errors.New("invalid web app configuration")
+
+	// ErrInvalidI18n is returned when a catalog or default language isn't a language tag.
+	ErrInvalidI18n = errors.New("invalid i18n configuration")
+
+	// ErrInvalidTheme is returned when a theme text lacks the format verbs it is given.
+	ErrInvalidTheme = errors.New("invalid theme configuration")
+
+	// ErrInvalidSchema is returned when a flow's data schema is malformed.
+	ErrInvalidSchema = errors.New("invalid data schema")
+
+	// ErrUndeclaredKey is returned when a step of a flow with a data schema
+	// stores a key the schema doesn't declare.
+	ErrUndeclaredKey = errors.New("undeclared data key")
+
 	// ErrFlowNotFound is returned when a referenced flow does not exist.
 	ErrFlowNotFound = errors.New("flow not found")
 
 	// ErrStepNotFound is returned when a referenced step does not exist.
 	ErrStepNotFound = errors.New("step not found")
 
+	// ErrOrphanStep is returned when a step can't be reached from its flow's initial step.
+	ErrOrphanStep = errors.New("step is unreachable")
+
 	// ErrMenuNotFound is returned when a referenced menu does not exist.
 	ErrMenuNotFound = errors.New("menu not found")
 
@@ -1,7 +1,13 @@
 // Package config defines configuration structures for tgwrapper.
 package config
 
-import "time"
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
 
 // FlowConfig defines a conversation flow configuration.
 // A flow represents a multi-step interaction with the user,
@@ -30,6 +36,105 @@ type FlowConfig struct {
 
 	// OnEnd is the name of a hook function to call when the flow ends.
 	OnEnd string `json:"on_end" yaml:"on_end" mapstructure:"on_end"`
+
+	// Credits sets a credit requirement and cost for running the flow.
+	Credits *CreditsConfig `json:"credits" yaml:"credits" mapstructure:"credits"`
+
+	// Cooldown is the minimum time between starts of the flow per user.
+	Cooldown time.Duration `json:"cooldown" yaml:"cooldown" mapstructure:"cooldown"`
+
+	// DailyLimit is the maximum number of starts of the flow per user per UTC day.
+	DailyLimit int `json:"daily_limit" yaml:"daily_limit" mapstructure:"daily_limit"`
+
+	// Roles restricts the flow to users holding any of these roles.
+	Roles []string `json:"roles" yaml:"roles" mapstructure:"roles"`
+
+	// BindUser binds the keyboards of every step to the user running the flow.
+	BindUser bool `json:"bind_user" yaml:"bind_user" mapstructure:"bind_user"`
+
+	// Pin pins the step prompts of the flow until its conversation ends.
+	Pin bool `json:"pin" yaml:"pin" mapstructure:"pin"`
+
+	// Cleanup sets what happens to the flow's intermediate messages when the
+	// conversation ends. Defaults to the bot's flow_cleanup.
+	Cleanup CleanupMode `json:"cleanup" yaml:"cleanup" mapstructure:"cleanup"`
+
+	// Sweep sets what happens to the flow's last keyboard message when the
+	// conversation expires or is cancelled, so its dead buttons don't linger
+	// in the chat. Defaults to the bot's flow_sweep.
+	Sweep CleanupMode `json:"sweep" yaml:"sweep" mapstructure:"sweep"`
+
+	// Schema declares the keys of the flow's data with their types. If set,
+	// stored values are converted to the declared types, Validate rejects
+	// steps storing undeclared keys, and required keys are checked before
+	// the flow completes.
+	Schema []DataFieldConfig `json:"schema" yaml:"schema" mapstructure:"schema"`
+
+	// Rollout releases the flow to part of its users only. Users outside it
+	// get a fallback flow or menu. Everyone is included if nil.
+	Rollout *RolloutConfig `json:"rollout" yaml:"rollout" mapstructure:"rollout"`
+
+	// Checks are cross-field validations of the collected data, run in order
+	// before a step without next_step completes, i.e. before the final
+	// on_complete. The first failing check sends the user back to its step.
+	Checks []FlowCheckConfig `json:"checks" yaml:"checks" mapstructure:"checks"`
+
+	// Ticket files the flow's conversations as tickets in a ticketing system
+	// when they end. No tickets are filed if nil, except on escalation.
+	Ticket *TicketConfig `json:"ticket" yaml:"ticket" mapstructure:"ticket"`
+}
+
+// FlowCheckConfig defines a validation over the whole data of a flow, e.g.
+// "end_date after start_date" or "amount ≤ balance". Exactly one of Validator
+// or Condition should be set; Validator wins if both are.
+type FlowCheckConfig struct {
+	// Validator is the name of a registered flow validator.
+	Validator string `json:"validator" yaml:"validator" mapstructure:"validator"`
+
+	// Condition is a condition expression that must hold, evaluated like
+	// branch conditions, e.g. "data.confirmed == 'yes'".
+	Condition string `json:"condition" yaml:"condition" mapstructure:"condition"`
+
+	// Step is the step the user is sent back to when the check fails.
+	// A validator can choose the step itself; defaults to the final step.
+	Step string `json:"step" yaml:"step" mapstructure:"step"`
+
+	// ErrorMsg explains the failure to the user. Supports template variables.
+	// Defaults to the validator's error; required for conditions.
+	ErrorMsg string `json:"error_msg" yaml:"error_msg" mapstructure:"error_msg"`
+}
+
+// CleanupMode defines what happens to messages of a flow when its conversation
+// ends. As a cleanup it applies to the intermediate messages, i.e. every message
+// the flow produced except the last keyboard message, which is left to the
+// completion handler. As a sweep it applies to the last keyboard message of
+// conversations that expired or were cancelled.
+type CleanupMode string
+
+const (
+	// CleanupNone keeps intermediate messages as they are.
+	CleanupNone CleanupMode = ""
+
+	// CleanupDelete deletes intermediate messages.
+	CleanupDelete CleanupMode = "delete"
+
+	// CleanupStripKeyboards removes the inline keyboards of intermediate
+	// messages, so their stale buttons can't be pressed.
+	CleanupStripKeyboards CleanupMode = "strip_keyboards"
+
+	// CleanupMarkExpired removes the inline keyboard and appends the bot's
+	// expired_footer to the message text. Intermediate messages, whose text
+	// isn't kept, only have their keyboards removed.
+	CleanupMarkExpired CleanupMode = "mark_expired"
+)
+
+// Valid returns true if the mode is known.
+func (m CleanupMode) Valid() bool {
+	switch m {
+	case CleanupNone, CleanupDelete, CleanupStripKeyboards, CleanupMarkExpired:
+		return true
+	}
+	return false
 }
 
 // InputType defines what kind of input a step expects from the user.
@@ -53,6 +158,30 @@ const (
 
 	// InputTypeDocument expects a document upload from the user.
 	InputTypeDocument InputType = "document"
+
+	// InputTypeVoice expects a voice message from the user.
+	// With a transcriber configured, the transcript is validated and used for branching.
+	InputTypeVoice InputType = "voice"
+
+	// InputTypeContact expects a shared contact, usually sent with a
+	// request_contact button of a reply keyboard.
+	InputTypeContact InputType = "contact"
+
+	// InputTypeLocation expects a shared location, usually sent with a
+	// request_location button of a reply keyboard.
+	InputTypeLocation InputType = "location"
+
+	// InputTypeLLM forwards text input to a registered completer and streams
+	// the response into the prompt message. Configure it with StepConfig.LLM.
+	InputTypeLLM InputType = "llm"
+
+	// InputTypePayment sends an invoice and waits for it to be paid.
+	// Configure it with StepConfig.Payment.
+	InputTypePayment InputType = "payment"
+
+	// InputTypeWebApp expects data sent by a Web App, opened with a web_app
+	// button of a reply keyboard.
+	InputTypeWebApp InputType = "web_app"
 )
 
 // StepConfig defines a single step within a conversation flow.
@@ -66,9 +195,17 @@ type StepConfig struct {
 	// Supports template variables like {{.data.key}}.
 	PromptText string `json:"prompt_text" yaml:"prompt_text" mapstructure:"prompt_text"`
 
+	// TextKey is the catalog key of the prompt text in the user's language;
+	// PromptText is shown when no catalog has it. See I18nConfig.
+	TextKey string `json:"text_key" yaml:"text_key" mapstructure:"text_key"`
+
 	// PromptTemplate is an advanced template for complex formatting.
 	PromptTemplate string `json:"prompt_template" yaml:"prompt_template" mapstructure:"prompt_template"`
 
+	// PromptVariants defines alternative prompt texts selected automatically
+	// by retry attempt and by chat type.
+	PromptVariants *PromptVariantsConfig `json:"prompt_variants" yaml:"prompt_variants" mapstructure:"prompt_variants"`
+
 	// Keyboard defines the inline keyboard to display with the prompt.
 	Keyboard *KeyboardConfig `json:"keyboard" yaml:"keyboard" mapstructure:"keyboard"`
 
@@ -78,6 +215,15 @@ type StepConfig struct {
 	// Validation defines input validation rules.
 	Validation *ValidationConfig `json:"validation" yaml:"validation" mapstructure:"validation"`
 
+	// OnValidationFail runs after every input that fails validation, once the
+	// error is shown. Without it, the step prompts again.
+	OnValidationFail *ValidationFailConfig `json:"on_validation_fail" yaml:"on_validation_fail" mapstructure:"on_validation_fail"`
+
+	// OnMaxAttempts runs instead of OnValidationFail once the step received
+	// validation.max_attempts invalid inputs in a row. Without it, the
+	// conversation is cancelled.
+	OnMaxAttempts *ValidationFailConfig `json:"on_max_attempts" yaml:"on_max_attempts" mapstructure:"on_max_attempts"`
+
 	// NextStep is the ID of the next step (for simple linear flows).
 	NextStep string `json:"next_step" yaml:"next_step" mapstructure:"next_step"`
 
@@ -94,13 +240,201 @@ type StepConfig struct {
 	// StoreAs is the key name under which to store user input in conversation data.
 	StoreAs string `json:"store_as" yaml:"store_as" mapstructure:"store_as"`
 
+	// Transform lists transforms applied in order to validated text input before it is stored,
+	// e.g. ["trim", "lower"] or ["parse_date(2006-01-02)"]. Arguments go in parentheses.
+	Transform []string `json:"transform" yaml:"transform" mapstructure:"transform"`
+
+	// Analyzer is the name of a registered photo analyzer run on photo input.
+	// Its extracted text, labels, and data are stored alongside the file ID.
+	Analyzer string `json:"analyzer" yaml:"analyzer" mapstructure:"analyzer"`
+
+	// LLM configures the completer for steps with input type "llm".
+	LLM *LLMStepConfig `json:"llm" yaml:"llm" mapstructure:"llm"`
+
+	// Payment configures the invoice of steps with input type "payment".
+	Payment *PaymentStepConfig `json:"payment" yaml:"payment" mapstructure:"payment"`
+
+	// Computed lists values written into conversation data when the step completes,
+	// in order, so later entries and subsequent prompts and branches can use them.
+	Computed []ComputedConfig `json:"computed" yaml:"computed" mapstructure:"computed"`
+
+	// SubFlow runs another flow in place of this step's prompt. When the
+	// sub-flow's last step completes, this step completes and the flow
+	// continues with its next_step or branches.
+	SubFlow *SubFlowConfig `json:"sub_flow" yaml:"sub_flow" mapstructure:"sub_flow"`
+
+	// Pin pins the step's prompt while the conversation is on this step.
+	// In groups the bot needs the right to pin messages; without it the prompt stays unpinned.
+	Pin bool `json:"pin" yaml:"pin" mapstructure:"pin"`
+
+	// Timeout stops waiting for input after a while: the conversation moves to
+	// the timeout's step, runs its handler, or ends with a notice. The timer
+	// starts each time the step's prompt is shown.
+	Timeout *StepTimeoutConfig `json:"timeout" yaml:"timeout" mapstructure:"timeout"`
+
 	// SkipIf is a condition expression; if true, skip this step.
 	SkipIf string `json:"skip_if" yaml:"skip_if" mapstructure:"skip_if"`
 
-	// ParseMode specifies the Telegram parse mode for the prompt.
+	// ParseMode specifies the Telegram parse mode for the prompt: Markdown,
+	// MarkdownV2, or HTML. Template values, validation errors shown inline, and
+	// LLM responses are escaped for it.
 	ParseMode string `json:"parse_mode" yaml:"parse_mode" mapstructure:"parse_mode"`
 }
 
+// SubFlowConfig defines a call of another flow from a step, so common
+// sequences like entering a wallet address can be reused across flows.
+// Entry requirements of the sub-flow (roles, credits, quotas) are not checked.
+type SubFlowConfig struct {
+	// FlowID is the flow to run.
+	FlowID string `json:"flow_id" yaml:"flow_id" mapstructure:"flow_id"`
+
+	// Namespace isolates the sub-flow's data: it starts with only Params, and on
+	// return its data is stored under this key, e.g. {{.wallet.address}} or
+	// data.wallet.address. If empty, the sub-flow shares the caller's data.
+	Namespace string `json:"namespace" yaml:"namespace" mapstructure:"namespace"`
+
+	// Params are values passed to the sub-flow's data, rendered as templates
+	// with the caller's data, e.g. {"network": "{{.network}}"}.
+	Params map[string]string `json:"params" yaml:"params" mapstructure:"params"`
+}
+
+// DefaultLLMResponseKey is the data key an LLM step's response is stored under by default.
+const DefaultLLMResponseKey = "llm_response"
+
+// LLMStepConfig defines how an "llm" step talks to a completer.
+// Each text input is sent to the completer together with the conversation;
+// the step repeats until the completer reports it is done, then the step
+// completes like any other (computed fields, OnComplete, branches).
+type LLMStepConfig struct {
+	// Completer is the name of a registered completer.
+	Completer string `json:"completer" yaml:"completer" mapstructure:"completer"`
+
+	// SystemPrompt is passed to the completer. Supports template variables.
+	SystemPrompt string `json:"system_prompt" yaml:"system_prompt" mapstructure:"system_prompt"`
+
+	// ResponseKey is the data key the latest response text is stored under.
+	// Defaults to DefaultLLMResponseKey.
+	ResponseKey string `json:"response_key" yaml:"response_key" mapstructure:"response_key"`
+
+	// MaxTurns completes the step after this many inputs even if the completer
+	// has not reported it is done. Zero means no limit.
+	MaxTurns int `json:"max_turns" yaml:"max_turns" mapstructure:"max_turns"`
+}
+
+// GetResponseKey returns the response data key, defaulting to DefaultLLMResponseKey.
+func (l *LLMStepConfig) GetResponseKey() string {
+	if l.ResponseKey != "" {
+		return l.ResponseKey
+	}
+	return DefaultLLMResponseKey
+}
+
+// PaymentStepConfig defines the invoice of a "payment" step. The step sends
+// the invoice after its prompt and completes with input "paid" once it is
+// paid; a declined payment goes to FailureStep instead.
+type PaymentStepConfig struct {
+	// Title is the product name shown on the invoice. Supports template variables.
+	Title string `json:"title" yaml:"title" mapstructure:"title"`
+
+	// Description is the product description shown on the invoice.
+	// Supports template variables.
+	Description string `json:"description" yaml:"description" mapstructure:"description"`
+
+	// Currency is a three-letter ISO 4217 code, or "XTR" for Telegram Stars.
+	Currency string `json:"currency" yaml:"currency" mapstructure:"currency"`
+
+	// Amount is the price in the smallest units of the currency, e.g. cents,
+	// or a number of stars. Supports template variables, e.g. "{{.data.total}}".
+	Amount string `json:"amount" yaml:"amount" mapstructure:"amount"`
+
+	// ProviderToken is the payment provider token from @BotFather.
+	// Not needed for Telegram Stars.
+	ProviderToken string `json:"provider_token" yaml:"provider_token" mapstructure:"provider_token"`
+
+	// PhotoURL is the product photo shown on the invoice.
+	PhotoURL string `json:"photo_url" yaml:"photo_url" mapstructure:"photo_url"`
+
+	// NeedName asks for the user's full name.
+	NeedName bool `json:"need_name" yaml:"need_name" mapstructure:"need_name"`
+
+	// NeedEmail asks for the user's email.
+	NeedEmail bool `json:"need_email" yaml:"need_email" mapstructure:"need_email"`
+
+	// NeedPhone asks for the user's phone number.
+	NeedPhone bool `json:"need_phone" yaml:"need_phone" mapstructure:"need_phone"`
+
+	// NeedShipping asks for a shipping address.
+	NeedShipping bool `json:"need_shipping" yaml:"need_shipping" mapstructure:"need_shipping"`
+
+	// FailureStep is the step shown when the payment is declined, or the
+	// invoice can't be sent. Empty ends the conversation.
+	FailureStep string `json:"failure_step" yaml:"failure_step" mapstructure:"failure_step"`
+}
+
+// Valid returns true if the invoice has a title, a currency, and an amount
+// that is a positive integer or a template.
+func (p *PaymentStepConfig) Valid() bool {
+	if p.Title == "" || p.Currency == "" || p.Amount == "" {
+		return false
+	}
+	if strings.Contains(p.Amount, "{{") {
+		return true
+	}
+	amount, err := strconv.Atoi(p.Amount)
+	return err == nil && amount > 0
+}
+
+// ComputedConfig defines a value computed into conversation data on step completion.
+// Exactly one of Template or Handler should be set; Handler wins if both are.
+type ComputedConfig struct {
+	// Key is the data key the result is stored under.
+	// Keys with the "chat." prefix are written to chat settings.
+	Key string `json:"key" yaml:"key" mapstructure:"key"`
+
+	// Template is rendered like a prompt and stored as a string,
+	// e.g. "{{mul .amount .price | round 2}}".
+	Template string `json:"template" yaml:"template" mapstructure:"template"`
+
+	// Handler is the name of a registered compute function whose result is stored.
+	Handler string `json:"handler" yaml:"handler" mapstructure:"handler"`
+}
+
+// PromptVariantsConfig defines alternative prompt texts for a step.
+// A retry variant takes precedence over a chat type variant; PromptText is
+// used when no variant applies.
+type PromptVariantsConfig struct {
+	// Retry lists prompts shown after invalid input: the first entry after the
+	// first invalid input, the second after the second, and so on. The last entry
+	// repeats for further attempts.
+	Retry []string `json:"retry" yaml:"retry" mapstructure:"retry"`
+
+	// Group is the prompt shown in group chats.
+	Group string `json:"group" yaml:"group" mapstructure:"group"`
+
+	// Private is the prompt shown in private chats.
+	Private string `json:"private" yaml:"private" mapstructure:"private"`
+}
+
+// HasRetry returns true if retry prompts are defined.
+func (p *PromptVariantsConfig) HasRetry() bool {
+	return p != nil && len(p.Retry) > 0
+}
+
+// Select returns the variant for the given number of invalid attempts and chat type.
+// Returns empty string if no variant applies.
+func (p *PromptVariantsConfig) Select(invalidAttempts int, private bool) string {
+	if p == nil {
+		return ""
+	}
+	if invalidAttempts > 0 && len(p.Retry) > 0 {
+		return p.Retry[min(invalidAttempts, len(p.Retry))-1]
+	}
+	if private {
+		return p.Private
+	}
+	return p.Group
+}
+
 // ValidationConfig defines input validation rules for a step.
 type ValidationConfig struct {
 	// Type is the validation type: number, address, email, regex, custom, required.
@@ -126,13 +460,175 @@ type ValidationConfig struct {
 
 	// Custom is the name of a custom validator function.
 	Custom string `json:"custom" yaml:"custom" mapstructure:"custom"`
+
+	// ErrorDisplay overrides how validation errors are shown for this step:
+	// "reply" or "inline". Defaults to the bot-level setting.
+	ErrorDisplay string `json:"error_display" yaml:"error_display" mapstructure:"error_display"`
+
+	// MaxAttempts is the number of invalid inputs in a row after which the
+	// step's on_max_attempts runs. Zero allows unlimited attempts.
+	MaxAttempts int `json:"max_attempts" yaml:"max_attempts" mapstructure:"max_attempts"`
+
+	// MaxSize is the largest file accepted by photo and document steps, e.g.
+	// "5MB" or "500KB", in units of 1024 bytes. No limit if empty.
+	MaxSize string `json:"max_size" yaml:"max_size" mapstructure:"max_size"`
+
+	// AllowedMIME lists the MIME types accepted by document steps, e.g.
+	// "application/pdf", or "image/*" for all images. All types if empty.
+	AllowedMIME []string `json:"allowed_mime" yaml:"allowed_mime" mapstructure:"allowed_mime"`
+
+	// MinDimensions is the smallest photo accepted by photo steps, as
+	// "WIDTHxHEIGHT", e.g. "800x600". No limit if empty.
+	MinDimensions string `json:"min_dimensions" yaml:"min_dimensions" mapstructure:"min_dimensions"`
+}
+
+// sizeUnits are the units of ValidationConfig.MaxSize, longest first.
+var sizeUnits = []struct {
+	suffix string
+	bytes  int64
+}{
+	{"GB", 1 << 30},
+	{"MB", 1 << 20},
+	{"KB", 1 << 10},
+	{"B", 1},
+}
+
+// ParseSize parses a file size such as "5MB", "500 KB", or "1024" (bytes).
+func ParseSize(s string) (int64, error) {
+	number := strings.ToUpper(strings.TrimSpace(s))
+	unit := int64(1)
+	for _, u := range sizeUnits {
+		if strings.HasSuffix(number, u.suffix) {
+			number, unit = strings.TrimSpace(strings.TrimSuffix(number, u.suffix)), u.bytes
+			break
+		}
+	}
+	n, err := strconv.ParseFloat(number, 64)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	return int64(n * float64(unit)), nil
+}
+
+// GetMaxSize returns the largest accepted file in bytes, or 0 if there is no limit.
+func (v *ValidationConfig) GetMaxSize() int64 {
+	if v == nil || v.MaxSize == "" {
+		return 0
+	}
+	size, _ := ParseSize(v.MaxSize)
+	return size
+}
+
+// GetMinDimensions returns the smallest accepted photo width and height,
+// or zeros if there is no limit.
+func (v *ValidationConfig) GetMinDimensions() (width, height int) {
+	if v == nil || v.MinDimensions == "" {
+		return 0, 0
+	}
+	w, h, ok := strings.Cut(strings.ToLower(strings.ReplaceAll(v.MinDimensions, "×", "x")), "x")
+	if !ok {
+		return 0, 0
+	}
+	width, _ = strconv.Atoi(strings.TrimSpace(w))
+	height, _ = strconv.Atoi(strings.TrimSpace(h))
+	return width, height
+}
+
+// AllowsMIME returns true if a document of a MIME type is accepted. Patterns
+// ending in "/*" match all subtypes.
+func (v *ValidationConfig) AllowsMIME(mimeType string) bool {
+	if v == nil || len(v.AllowedMIME) == 0 {
+		return true
+	}
+	mimeType = strings.ToLower(mimeType)
+	for _, allowed := range v.AllowedMIME {
+		allowed = strings.ToLower(allowed)
+		if allowed == mimeType || (strings.HasSuffix(allowed, "/*") && strings.HasPrefix(mimeType, strings.TrimSuffix(allowed, "*"))) {
+			return true
+		}
+	}
+	return false
+}
+
+// validFiles returns true if the file limits parse.
+func (v *ValidationConfig) validFiles() bool {
+	if v == nil {
+		return true
+	}
+	if v.MaxSize != "" && v.GetMaxSize() <= 0 {
+		return false
+	}
+	if w, h := v.GetMinDimensions(); v.MinDimensions != "" && (w <= 0 || h <= 0) {
+		return false
+	}
+	return true
 }
 
+// ValidationFailConfig defines what happens when a step's input fails validation.
+type ValidationFailConfig struct {
+	// NextStep is the step ID to transition to. The validation error is removed.
+	NextStep string `json:"next_step" yaml:"next_step" mapstructure:"next_step"`
+
+	// Handler is the name of a step handler to execute. The handler runs before
+	// the transition and can read the invalid input count with
+	// Conversation.GetInvalidInputs. Without NextStep, the handler is
+	// responsible for what happens next.
+	Handler string `json:"handler" yaml:"handler" mapstructure:"handler"`
+}
+
+// DefaultStepTimeoutText is sent when a step timeout ends a conversation.
+const DefaultStepTimeoutText = "⌛ This conversation timed out. Start again whenever you're ready."
+
+// StepTimeoutConfig defines what happens when a user doesn't answer a step in time.
+// Without NextStep or Handler, the conversation expires and Text is sent.
+type StepTimeoutConfig struct {
+	// After is how long the step waits for input.
+	After time.Duration `json:"after" yaml:"after" mapstructure:"after"`
+
+	// NextStep is the step ID to transition to. The validation error is removed.
+	NextStep string `json:"next_step" yaml:"next_step" mapstructure:"next_step"`
+
+	// Handler is the name of a step handler to execute. The handler runs before
+	// the transition, without an update in its context. Without NextStep, the
+	// handler is responsible for what happens next.
+	Handler string `json:"handler" yaml:"handler" mapstructure:"handler"`
+
+	// Text is sent to the chat when the timeout fires, rendered as a template
+	// with the conversation data. Defaults to DefaultStepTimeoutText when the
+	// conversation expires; otherwise nothing is sent if empty.
+	Text string `json:"text" yaml:"text" mapstructure:"text"`
+}
+
+// Ends returns true if the timeout expires the conversation.
+func (t *StepTimeoutConfig) Ends() bool {
+	return t.NextStep == "" && t.Handler == ""
+}
+
+// GetText returns the text sent when the timeout fires, or "" if none is.
+func (t *StepTimeoutConfig) GetText() string {
+	if t.Text == "" && t.Ends() {
+		return DefaultStepTimeoutText
+	}
+	return t.Text
+}
+
+// Validation error display modes.
+const (
+	// ErrorDisplayReply shows validation errors in a separate reply message.
+	ErrorDisplayReply = "reply"
+
+	// ErrorDisplayInline appends validation errors to the step prompt message,
+	// removing them when the prompt is next rendered after valid input.
+	ErrorDisplayInline = "inline"
+)
+
 // BranchConfig defines a conditional branch for step transitions.
 type BranchConfig struct {
 	// Condition is the expression to evaluate.
 	// Supported formats:
 	// - "data == 'xxx'" - exact match
+	// - "input =~ '^(?P<action>buy|sell) (?P<amount>\d+)$'" - regex match; named
+	//   capture groups are stored into conversation data when the branch matches
 	// - "data.startsWith('prefix:')" - prefix match
 	// - "data.contains('keyword')" - contains match
 	// - "custom:handlerName" - custom condition handler
@@ -141,10 +637,37 @@ type BranchConfig struct {
 	// NextStep is the step ID to transition to when condition is true.
 	NextStep string `json:"next_step" yaml:"next_step" mapstructure:"next_step"`
 
-	// Handler is the name of a handler function to execute when condition is true.
+	// Handler is the name of a step handler to execute when condition is true.
+	// The handler runs before the transition and can read the matched input with
+	// Conversation.LastInput. With NextStep set, the conversation moves on once the
+	// handler succeeds; without it, the handler is responsible for what happens next.
+	// A step's OnComplete handler takes precedence over branches.
 	Handler string `json:"handler" yaml:"handler" mapstructure:"handler"`
 }
 
+// Unquote strips one pair of matching single or double quotes around a value
+// of a branch condition, e.g. the 'buy' of "input == 'buy'".
+func Unquote(s string) string {
+	if len(s) >= 2 && (s[0] == '"' || s[0] == '\'') && s[len(s)-1] == s[0] {
+		return s[1 : len(s)-1]
+	}
+	return s
+}
+
+// ConditionPattern returns the regex of an "input =~" branch condition, and
+// false for other conditions.
+func ConditionPattern(condition string) (string, bool) {
+	rest, ok := strings.CutPrefix(condition, "input")
+	if !ok {
+		return "", false
+	}
+	pattern, ok := strings.CutPrefix(strings.TrimSpace(rest), "=~")
+	if !ok {
+		return "", false
+	}
+	return Unquote(strings.TrimSpace(pattern)), true
+}
+
 // Validate checks if the flow configuration is valid.
 // Returns an error if the flow is missing required fields or has invalid references.
 func (f *FlowConfig) Validate() error {
@@ -160,6 +683,61 @@ func (f *FlowConfig) Validate() error {
 	if _, ok := f.Steps[f.InitialStep]; !ok {
 		return ErrStepNotFound
 	}
+	if !f.Cleanup.Valid() || !f.Sweep.Valid() {
+		return ErrInvalidFlow
+	}
+	if !f.Ticket.Valid() {
+		return ErrInvalidTicket
+	}
+	if err := f.validateSchema(); err != nil {
+		return err
+	}
+	for id, step := range f.Steps {
+		if !ValidParseMode(step.ParseMode) {
+			return fmt.Errorf("%w: flow %q step %q has unknown parse_mode %q", ErrInvalidStep, f.ID, id, step.ParseMode)
+		}
+		if !step.Validation.validFiles() {
+			return fmt.Errorf("%w: flow %q step %q has an invalid max_size or min_dimensions", ErrInvalidStep, f.ID, id)
+		}
+		if v := step.Validation; v != nil && v.Type == "regex" {
+			if _, err := regexp.Compile(v.Pattern); err != nil {
+				return fmt.Errorf("%w: flow %q step %q has an invalid validation pattern: %v", ErrInvalidStep, f.ID, id, err)
+			}
+		}
+		for _, branch := range step.Branches {
+			if pattern, ok := ConditionPattern(branch.Condition); ok {
+				if _, err := regexp.Compile(pattern); err != nil {
+					return fmt.Errorf("%w: flow %q step %q has an invalid branch pattern: %v", ErrInvalidStep, f.ID, id, err)
+				}
+			}
+		}
+		if step.InputType == InputTypePayment {
+			if step.Payment == nil || !step.Payment.Valid() {
+				return fmt.Errorf("%w: flow %q step %q needs a payment title, currency, and amount", ErrInvalidStep, f.ID, id)
+			}
+			if _, ok := f.Steps[step.Payment.FailureStep]; step.Payment.FailureStep != "" && !ok {
+				return fmt.Errorf("%w: %s in flow %s", ErrStepNotFound, step.Payment.FailureStep, f.ID)
+			}
+		}
+		if t := step.Timeout; t != nil {
+			if t.After <= 0 {
+				return fmt.Errorf("%w: flow %q step %q has a timeout without after", ErrInvalidStep, f.ID, id)
+			}
+			if _, ok := f.Steps[t.NextStep]; t.NextStep != "" && !ok {
+				return fmt.Errorf("%w: %s in flow %s", ErrStepNotFound, t.NextStep, f.ID)
+			}
+		}
+	}
+	for _, check := range f.Checks {
+		if check.Validator == "" && (check.Condition == "" || check.ErrorMsg == "") {
+			return ErrInvalidFlow
+		}
+		if check.Step != "" {
+			if _, ok := f.Steps[check.Step]; !ok {
+				return ErrStepNotFound
+			}
+		}
+	}
 	return nil
 }
 
@@ -169,6 +747,22 @@ func (f *FlowConfig) GetStep(stepID string) *StepConfig {
 	return f.Steps[stepID]
 }
 
+// GetCleanup returns the flow's cleanup mode or the provided default if not set.
+func (f *FlowConfig) GetCleanup(defaultMode CleanupMode) CleanupMode {
+	if f.Cleanup != CleanupNone {
+		return f.Cleanup
+	}
+	return defaultMode
+}
+
+// GetSweep returns the flow's sweep mode or the provided default if not set.
+func (f *FlowConfig) GetSweep(defaultMode CleanupMode) CleanupMode {
+	if f.Sweep != CleanupNone {
+		return f.Sweep
+	}
+	return defaultMode
+}
+
 // GetTTL returns the flow's TTL or the provided default if not set.
 func (f *FlowConfig) GetTTL(defaultTTL time.Duration) time.Duration {
 	if f.TTL > 0 {
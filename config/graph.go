@@ -0,0 +1,331 @@
+// Package config defines configuration structures for tgwrapper.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// HandlerLookup reports whether the handlers flows reference by name are
+// registered. HandlerRegistry implements it, and so does the flow engine of
+// a running wrapper.
+type HandlerLookup interface {
+	HasStepHandler(name string) bool
+	HasKeyboardProvider(name string) bool
+	HasValidator(name string) bool
+	HasFlowValidator(name string) bool
+	HasComputeFunc(name string) bool
+}
+
+// HasStepHandler returns true if a step handler is registered under the name.
+func (r *HandlerRegistry) HasStepHandler(name string) bool {
+	_, ok := r.StepHandlers[name]
+	return ok
+}
+
+// HasKeyboardProvider returns true if a keyboard provider is registered under the name.
+func (r *HandlerRegistry) HasKeyboardProvider(name string) bool {
+	_, ok := r.KeyboardProviders[name]
+	return ok
+}
+
+// HasValidator returns true if a validator is registered under the name.
+func (r *HandlerRegistry) HasValidator(name string) bool {
+	_, ok := r.Validators[name]
+	return ok
+}
+
+// HasFlowValidator returns true if a flow validator is registered under the name.
+func (r *HandlerRegistry) HasFlowValidator(name string) bool {
+	_, ok := r.FlowValidators[name]
+	return ok
+}
+
+// HasComputeFunc returns true if a compute function is registered under the name.
+func (r *HandlerRegistry) HasComputeFunc(name string) bool {
+	_, ok := r.ComputeFuncs[name]
+	return ok
+}
+
+// FlowReport lists the problems found by the static validation of flows.
+type FlowReport struct {
+	// Errors are problems that break conversations in chats, e.g. a next_step
+	// naming a missing step. Each wraps ErrStepNotFound, ErrOrphanStep,
+	// ErrHandlerNotFound, ErrProviderNotFound, or ErrValidatorNotFound.
+	Errors []error
+
+	// Warnings are constructs that are likely mistakes but may be intended,
+	// e.g. steps that loop without waiting for input.
+	Warnings []FlowWarning
+}
+
+// FlowWarning is a construct of a flow that is likely a mistake.
+type FlowWarning struct {
+	FlowID  string // Flow the construct is in
+	Message string // What is suspicious, naming the steps involved
+}
+
+// String returns the warning with its flow.
+func (w FlowWarning) String() string {
+	return fmt.Sprintf("flow %q: %s", w.FlowID, w.Message)
+}
+
+// Err returns the errors of the report joined into one, or nil if there are none.
+// errors.Is matches the sentinel of any of them.
+func (r *FlowReport) Err() error {
+	if r == nil || len(r.Errors) == 0 {
+		return nil
+	}
+	return errors.Join(r.Errors...)
+}
+
+// CheckFlows validates the graph of every flow: steps referenced by next_step,
+// branches, on_validation_fail, on_max_attempts, timeouts, payment failure
+// steps, and checks exist; every step is reachable from the initial step or a
+// tenant's initial step; and no steps loop without waiting for input.
+// With handlers, step handlers (including the flow's on_start and on_end),
+// keyboard providers, custom validators, flow validators of checks, and
+// compute functions of computed entries referenced by name must be registered; providers declared in bot.providers
+// count as registered. Problems of all flows are collected, not just the first.
+//
+// Steps reachable only through a handler's transition, e.g. a step handler
+// calling ChangeStep, can't be traced: in flows whose steps run handlers,
+// unreachable steps are warnings instead of errors.
+func (c *Config) CheckFlows(handlers HandlerLookup) *FlowReport {
+	report := &FlowReport{}
+	ids := make([]string, 0, len(c.Flows))
+	for id := range c.Flows {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	for _, id := range ids {
+		flow := c.Flows[id]
+		if flow == nil {
+			continue
+		}
+		roots := []string{flow.InitialStep}
+		for _, tenant := range c.Tenants {
+			if override := tenant.Flows[id]; override != nil && override.InitialStep != "" {
+				roots = append(roots, override.InitialStep)
+			}
+		}
+		flow.checkGraph(report, roots)
+		if handlers != nil {
+			flow.checkHandlers(report, handlers, c.Bot)
+		}
+	}
+	return report
+}
+
+// stepTargets returns the steps a step can move to without code, by the
+// field naming them.
+func (s *StepConfig) stepTargets() map[string]string {
+	targets := make(map[string]string)
+	add := func(field, target string) {
+		if target != "" {
+			targets[field] = target
+		}
+	}
+	add("next_step", s.NextStep)
+	for i, branch := range s.Branches {
+		add(fmt.Sprintf("branches[%d].next_step", i), branch.NextStep)
+	}
+	if s.OnValidationFail != nil {
+		add("on_validation_fail.next_step", s.OnValidationFail.NextStep)
+	}
+	if s.OnMaxAttempts != nil {
+		add("on_max_attempts.next_step", s.OnMaxAttempts.NextStep)
+	}
+	if s.Timeout != nil {
+		add("timeout.next_step", s.Timeout.NextStep)
+	}
+	if s.Payment != nil {
+		add("payment.failure_step", s.Payment.FailureStep)
+	}
+	return targets
+}
+
+// stepHandlers returns the step handlers a step runs, by the field naming them.
+func (s *StepConfig) stepHandlers() map[string]string {
+	names := make(map[string]string)
+	add := func(field, name string) {
+		if name != "" {
+			names[field] = name
+		}
+	}
+	add("on_enter", s.OnEnter)
+	add("on_complete", s.OnComplete)
+	for i, branch := range s.Branches {
+		add(fmt.Sprintf("branches[%d].handler", i), branch.Handler)
+	}
+	if s.OnValidationFail != nil {
+		add("on_validation_fail.handler", s.OnValidationFail.Handler)
+	}
+	if s.OnMaxAttempts != nil {
+		add("on_max_attempts.handler", s.OnMaxAttempts.Handler)
+	}
+	if s.Timeout != nil {
+		add("timeout.handler", s.Timeout.Handler)
+	}
+	return names
+}
+
+// checkGraph reports missing step references, unreachable steps, and loops
+// of steps without input.
+func (f *FlowConfig) checkGraph(report *FlowReport, roots []string) {
+	ids := f.stepIDs()
+	dynamic := false
+	for _, id := range ids {
+		step := f.Steps[id]
+		targets := step.stepTargets()
+		for _, field := range sortedKeys(targets) {
+			if _, ok := f.Steps[targets[field]]; !ok {
+				report.Errors = append(report.Errors, fmt.Errorf("%w: flow %q step %q %s names %q", ErrStepNotFound, f.ID, id, field, targets[field]))
+			}
+		}
+		if len(step.stepHandlers()) > 0 {
+			dynamic = true
+		}
+	}
+	for _, check := range f.Checks {
+		if check.Step != "" {
+			roots = append(roots, check.Step)
+		}
+	}
+
+	// Walk the steps reachable from the roots
+	reached := make(map[string]bool)
+	queue := append([]string(nil), roots...)
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		step, ok := f.Steps[id]
+		if !ok || reached[id] {
+			continue
+		}
+		reached[id] = true
+		for _, target := range step.stepTargets() {
+			queue = append(queue, target)
+		}
+	}
+	for _, id := range ids {
+		if reached[id] {
+			continue
+		}
+		if dynamic {
+			report.Warnings = append(report.Warnings, FlowWarning{FlowID: f.ID, Message: fmt.Sprintf("step %q is only reachable through a handler, if at all", id)})
+		} else {
+			report.Errors = append(report.Errors, fmt.Errorf("%w: flow %q step %q", ErrOrphanStep, f.ID, id))
+		}
+	}
+
+	for _, loop := range f.inputlessLoops() {
+		report.Warnings = append(report.Warnings, FlowWarning{FlowID: f.ID, Message: fmt.Sprintf("steps %s loop without waiting for input", strings.Join(loop, " → "))})
+	}
+}
+
+// inputlessLoops returns the loops of steps with input type "none", which
+// advance on their own and so would cycle forever unless a branch or skip_if
+// breaks out. Each loop is listed from its first step, ending where it began.
+func (f *FlowConfig) inputlessLoops() [][]string {
+	const (
+		unvisited = iota
+		onPath
+		done
+	)
+	state := make(map[string]int)
+	var path []string
+	var loops [][]string
+
+	var visit func(id string)
+	visit = func(id string) {
+		step, ok := f.Steps[id]
+		if !ok || step.InputType != InputTypeNone || state[id] == done {
+			return
+		}
+		if state[id] == onPath {
+			for i, p := range path {
+				if p == id {
+					loop := append(append([]string(nil), path[i:]...), id)
+					loops = append(loops, loop)
+					break
+				}
+			}
+			return
+		}
+		state[id] = onPath
+		path = append(path, id)
+		targets := step.stepTargets()
+		for _, field := range sortedKeys(targets) {
+			visit(targets[field])
+		}
+		path = path[:len(path)-1]
+		state[id] = done
+	}
+	for _, id := range f.stepIDs() {
+		visit(id)
+	}
+	return loops
+}
+
+// checkHandlers reports step handlers, keyboard providers, custom validators,
+// flow validators, and compute functions that aren't registered.
+func (f *FlowConfig) checkHandlers(report *FlowReport, handlers HandlerLookup, bot *BotConfig) {
+	missingInFlow := func(err error, field, name string) {
+		report.Errors = append(report.Errors, fmt.Errorf("%w: flow %q %s names %q", err, f.ID, field, name))
+	}
+	missing := func(err error, id, field, name string) {
+		report.Errors = append(report.Errors, fmt.Errorf("%w: flow %q step %q %s names %q", err, f.ID, id, field, name))
+	}
+	if f.OnStart != "" && !handlers.HasStepHandler(f.OnStart) {
+		missingInFlow(ErrHandlerNotFound, "on_start", f.OnStart)
+	}
+	if f.OnEnd != "" && !handlers.HasStepHandler(f.OnEnd) {
+		missingInFlow(ErrHandlerNotFound, "on_end", f.OnEnd)
+	}
+	for i, check := range f.Checks {
+		if check.Validator != "" && !handlers.HasFlowValidator(check.Validator) {
+			missingInFlow(ErrValidatorNotFound, fmt.Sprintf("checks[%d].validator", i), check.Validator)
+		}
+	}
+	for _, id := range f.stepIDs() {
+		step := f.Steps[id]
+		names := step.stepHandlers()
+		for _, field := range sortedKeys(names) {
+			if !handlers.HasStepHandler(names[field]) {
+				missing(ErrHandlerNotFound, id, field, names[field])
+			}
+		}
+		if kb := step.Keyboard; kb != nil && kb.Provider != "" && !handlers.HasKeyboardProvider(kb.Provider) {
+			if bot == nil || bot.Providers[kb.Provider] == nil {
+				missing(ErrProviderNotFound, id, "keyboard.provider", kb.Provider)
+			}
+		}
+		if v := step.Validation; v != nil && v.Custom != "" && !handlers.HasValidator(v.Custom) {
+			missing(ErrValidatorNotFound, id, "validation.custom", v.Custom)
+		}
+		for i, entry := range step.Computed {
+			if entry.Handler != "" && !handlers.HasComputeFunc(entry.Handler) {
+				missing(ErrHandlerNotFound, id, fmt.Sprintf("computed[%d].handler", i), entry.Handler)
+			}
+		}
+	}
+}
+
+// stepIDs returns the IDs of the flow's steps in order, for stable reports.
+func (f *FlowConfig) stepIDs() []string {
+	return sortedKeys(f.Steps)
+}
+
+// sortedKeys returns the keys of a map in order.
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -25,6 +25,12 @@ type HandlerRegistry struct {
 	// Validators maps validator names to their implementations.
 	Validators map[string]ValidatorFunc
 
+	// FlowValidators maps flow validator names, referenced by flow checks, to their implementations.
+	FlowValidators map[string]FlowValidatorFunc
+
+	// ComputeFuncs maps compute function names, referenced by computed entries, to their implementations.
+	ComputeFuncs map[string]ComputeFunc
+
 	// AuthFunc is the authentication function for user authorization.
 	AuthFunc AuthFunc
 
@@ -55,6 +61,12 @@ type KeyboardProviderFunc func(ctx context.Context, conv interface{}) []ButtonDa
 // ValidatorFunc is the function signature for custom validators.
 type ValidatorFunc func(value string, conv interface{}) error
 
+// FlowValidatorFunc is the function signature for cross-field flow validators.
+type FlowValidatorFunc func(ctx context.Context, conv interface{}) error
+
+// ComputeFunc is the function signature for functions of computed entries.
+type ComputeFunc func(ctx context.Context, conv interface{}) (interface{}, error)
+
 // AuthFunc is the function signature for authentication.
 type AuthFunc func(ctx context.Context, userID int64, username string) bool
 
@@ -72,6 +84,8 @@ func NewHandlerRegistry() *HandlerRegistry {
 		StepHandlers:      make(map[string]StepHandlerFunc),
 		KeyboardProviders: make(map[string]KeyboardProviderFunc),
 		Validators:        make(map[string]ValidatorFunc),
+		FlowValidators:    make(map[string]FlowValidatorFunc),
+		ComputeFuncs:      make(map[string]ComputeFunc),
 	}
 }
 
@@ -105,6 +119,18 @@ func (r *HandlerRegistry) RegisterValidator(name string, validator ValidatorFunc
 	return r
 }
 
+// RegisterFlowValidator registers a cross-field flow validator by name.
+func (r *HandlerRegistry) RegisterFlowValidator(name string, validator FlowValidatorFunc) *HandlerRegistry {
+	r.FlowValidators[name] = validator
+	return r
+}
+
+// RegisterComputeFunc registers a compute function by name.
+func (r *HandlerRegistry) RegisterComputeFunc(name string, fn ComputeFunc) *HandlerRegistry {
+	r.ComputeFuncs[name] = fn
+	return r
+}
+
 // SetAuthFunc sets the authentication function.
 func (r *HandlerRegistry) SetAuthFunc(fn AuthFunc) *HandlerRegistry {
 	r.AuthFunc = fn
@@ -0,0 +1,71 @@
+// Package config defines configuration structures for tgwrapper.
+package config
+
+import "strings"
+
+// I18nConfig defines message catalogs for translating menus, flow prompts,
+// and buttons that reference a text_key. Each user sees them in the language
+// they chose with the built-in language flow, or else the one reported by
+// Telegram, falling back to the default language.
+type I18nConfig struct {
+	// Enabled turns on the language command.
+	Enabled bool `json:"enabled" yaml:"enabled" mapstructure:"enabled"`
+
+	// Command starts the language flow, without the leading slash. Defaults to "language".
+	Command string `json:"command" yaml:"command" mapstructure:"command"`
+
+	// Default is the language of users whose language has no catalog. Defaults to "en".
+	Default string `json:"default" yaml:"default" mapstructure:"default"`
+
+	// Dir is a directory of catalog files named after their locale, such as
+	// de.yaml or pt-BR.json, relative to the configuration file.
+	Dir string `json:"dir" yaml:"dir" mapstructure:"dir"`
+
+	// Messages are inline catalogs: messages by key, keyed by locale.
+	// They take precedence over catalog files.
+	Messages map[string]map[string]string `json:"messages" yaml:"messages" mapstructure:"messages"`
+}
+
+// Validate checks if the localization configuration is valid.
+func (i *I18nConfig) Validate() error {
+	for locale := range i.Messages {
+		if !validLocale(locale) {
+			return ErrInvalidI18n
+		}
+	}
+	if i.Default != "" && !validLocale(i.Default) {
+		return ErrInvalidI18n
+	}
+	return nil
+}
+
+// validLocale returns true if s looks like a language tag such as "de" or "pt-BR".
+func validLocale(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, part := range strings.FieldsFunc(s, func(r rune) bool { return r == '-' || r == '_' }) {
+		for _, r := range part {
+			if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9') {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// GetCommand returns the language command, defaulting to "language".
+func (i *I18nConfig) GetCommand() string {
+	if i.Command == "" {
+		return "language"
+	}
+	return i.Command
+}
+
+// GetDefault returns the default language, defaulting to "en".
+func (i *I18nConfig) GetDefault() string {
+	if i.Default == "" {
+		return "en"
+	}
+	return i.Default
+}
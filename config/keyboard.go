@@ -1,6 +1,8 @@
 // Package config defines configuration structures for tgwrapper.
 package config
 
+import "time"
+
 // KeyboardType defines the type of keyboard to display.
 type KeyboardType string
 
@@ -13,12 +15,18 @@ const (
 
 	// KeyboardTypeMixed combines static buttons with dynamic buttons.
 	KeyboardTypeMixed KeyboardType = "mixed"
+
+	// KeyboardTypeReply shows the static buttons as a reply keyboard in place
+	// of the user's letter keyboard. Pressing a button sends its text as input;
+	// request_contact and request_location buttons share the user's phone
+	// number or location natively.
+	KeyboardTypeReply KeyboardType = "reply"
 )
 
 // KeyboardConfig defines the keyboard configuration for a step or menu.
 // Supports static buttons, dynamic generation, and navigation helpers.
 type KeyboardConfig struct {
-	// Type specifies the keyboard type: static, dynamic, or mixed.
+	// Type specifies the keyboard type: static, dynamic, mixed, or reply.
 	Type KeyboardType `json:"type" yaml:"type" mapstructure:"type"`
 
 	// Buttons defines static button rows.
@@ -45,35 +53,78 @@ type KeyboardConfig struct {
 	// ProviderArgs specifies keys from conversation data to pass to the provider.
 	ProviderArgs []string `json:"provider_args" yaml:"provider_args" mapstructure:"provider_args"`
 
+	// FallbackButtons are the dynamic buttons shown when the provider returns
+	// no buttons, or fails (errors, times out, or its circuit is open) and has
+	// no earlier result to reuse.
+	FallbackButtons []ButtonData `json:"fallback_buttons" yaml:"fallback_buttons" mapstructure:"fallback_buttons"`
+
+	// EmptyState is shown when the provider returns no buttons, instead of a
+	// prompt with only navigation buttons. Takes precedence over FallbackButtons
+	// for empty results.
+	EmptyState *EmptyStateConfig `json:"empty_state" yaml:"empty_state" mapstructure:"empty_state"`
+
+	// LoadingAfter is how long to wait for the provider before showing the prompt
+	// with a loading placeholder row; the real buttons are edited in once they
+	// arrive. 0 waits for the provider without a placeholder.
+	LoadingAfter time.Duration `json:"loading_after" yaml:"loading_after" mapstructure:"loading_after"`
+
+	// LoadingText is the label of the loading placeholder button.
+	// Defaults to the theme's loading text, "⏳ Loading…" unless overridden.
+	LoadingText string `json:"loading_text" yaml:"loading_text" mapstructure:"loading_text"`
+
 	// CallbackPrefix is prepended to dynamic button callback data.
 	// Useful for routing callbacks to the correct handler.
 	CallbackPrefix string `json:"callback_prefix" yaml:"callback_prefix" mapstructure:"callback_prefix"`
 
-	// BackText customizes the back button text.
+	// BackText customizes the back button text. Defaults to the theme's.
 	BackText string `json:"back_text" yaml:"back_text" mapstructure:"back_text"`
 
-	// MainText customizes the main menu button text.
+	// MainText customizes the main menu button text. Defaults to the theme's.
 	MainText string `json:"main_text" yaml:"main_text" mapstructure:"main_text"`
 
-	// CancelText customizes the cancel button text.
+	// CancelText customizes the cancel button text. Defaults to the theme's.
 	CancelText string `json:"cancel_text" yaml:"cancel_text" mapstructure:"cancel_text"`
 
 	// Inline specifies whether to use inline keyboard (default true).
-	// If false, uses reply keyboard instead.
+	// If false, uses reply keyboard instead, like Type "reply".
 	Inline *bool `json:"inline" yaml:"inline" mapstructure:"inline"`
 
 	// OneTime makes the reply keyboard disappear after use.
-	// Only applies when Inline is false.
+	// Only applies to reply keyboards.
 	OneTime bool `json:"one_time" yaml:"one_time" mapstructure:"one_time"`
 
 	// Resize enables auto-resize for reply keyboard.
-	// Only applies when Inline is false.
+	// Only applies to reply keyboards.
 	Resize bool `json:"resize" yaml:"resize" mapstructure:"resize"`
+
+	// Placeholder is the hint shown in the input field while the reply keyboard is active.
+	// Only applies to reply keyboards.
+	Placeholder string `json:"placeholder" yaml:"placeholder" mapstructure:"placeholder"`
+
+	// BindUser binds the keyboard to the user running the flow, so in group
+	// chats other users can't press its buttons. FlowConfig.BindUser binds
+	// the keyboards of all steps.
+	BindUser bool `json:"bind_user" yaml:"bind_user" mapstructure:"bind_user"`
+}
+
+// EmptyStateConfig defines what a dynamic keyboard or menu shows when it has
+// no items, e.g. "You have no alerts yet" with a button to create one.
+type EmptyStateConfig struct {
+	// Text replaces the step prompt or menu text and is rendered like it.
+	// If empty, the original text is kept.
+	Text string `json:"text" yaml:"text" mapstructure:"text"`
+
+	// Buttons are button rows shown in place of the missing items,
+	// above the navigation buttons.
+	Buttons [][]ButtonConfig `json:"buttons" yaml:"buttons" mapstructure:"buttons"`
 }
 
 // IsInline returns true if this is an inline keyboard.
-// Defaults to true if Inline is nil.
+// Returns false for the reply type, and defaults to true if Inline is nil.
 func (k *KeyboardConfig) IsInline() bool {
+	if k.Type == KeyboardTypeReply {
+		return false
+	}
 	if k.Inline == nil {
 		return true // Default to inline keyboard
 	}
@@ -116,6 +167,15 @@ func (k *KeyboardConfig) GetCancelText() string {
 	return k.CancelText
 }
 
+// GetLoadingText returns the label of the loading placeholder button.
+// Returns a default text if not customized.
+func (k *KeyboardConfig) GetLoadingText() string {
+	if k.LoadingText == "" {
+		return "⏳ Loading…"
+	}
+	return k.LoadingText
+}
+
 // NeedsDynamicData returns true if the keyboard requires dynamic button data.
 func (k *KeyboardConfig) NeedsDynamicData() bool {
 	return k.Type == KeyboardTypeDynamic || k.Type == KeyboardTypeMixed
@@ -1,6 +1,22 @@
 // Package config defines configuration structures for tgwrapper.
 package config
 
+// ForkMode defines how a shared group menu opens its targets for the user
+// who pressed a button.
+type ForkMode string
+
+const (
+	// ForkNone edits the menu message in place, like any other menu.
+	ForkNone ForkMode = ""
+
+	// ForkReply posts the target as a new message in the group, bound to the
+	// user who pressed the button and deleted after the fork TTL.
+	ForkReply ForkMode = "reply"
+
+	// ForkDM sends the target to the private chat of the user who pressed the button.
+	ForkDM ForkMode = "dm"
+)
+
 // MenuConfig defines a menu configuration.
 // Menus are standalone message templates with buttons that can be displayed
 // at any time, independent of conversation flows.
@@ -13,6 +29,10 @@ type MenuConfig struct {
 	// Supports Markdown/HTML based on ParseMode.
 	Text string `json:"text" yaml:"text" mapstructure:"text"`
 
+	// TextKey is the catalog key of the text in the user's language; Text is
+	// shown when no catalog has it. See I18nConfig.
+	TextKey string `json:"text_key" yaml:"text_key" mapstructure:"text_key"`
+
 	// Buttons defines button rows as a 2D array.
 	// Each inner array represents a row of buttons.
 	Buttons [][]ButtonConfig `json:"buttons" yaml:"buttons" mapstructure:"buttons"`
@@ -21,10 +41,32 @@ type MenuConfig struct {
 	// Each page can have its own set of buttons.
 	Pages []PageConfig `json:"pages" yaml:"pages" mapstructure:"pages"`
 
+	// EmptyState is shown when conditions hide every button of the menu (or of
+	// the shown page), instead of a menu without buttons.
+	EmptyState *EmptyStateConfig `json:"empty_state" yaml:"empty_state" mapstructure:"empty_state"`
+
 	// Condition is an expression that determines when this menu should be shown.
 	Condition string `json:"condition" yaml:"condition" mapstructure:"condition"`
 
+	// Roles restricts the menu to users holding any of these roles.
+	Roles []string `json:"roles" yaml:"roles" mapstructure:"roles"`
+
+	// BindUser binds the menu's keyboard to the user who opened it, so in
+	// group chats other users can't press its buttons.
+	BindUser bool `json:"bind_user" yaml:"bind_user" mapstructure:"bind_user"`
+
+	// Fork makes the menu a shared group menu: its menu and flow buttons open
+	// their target in a separate message for the user who pressed them, so
+	// several members can use the same pinned menu at once. Ignored in private chats.
+	Fork ForkMode `json:"fork" yaml:"fork" mapstructure:"fork"`
+
+	// Pin pins the menu's message while it shows this menu. It is unpinned once
+	// the message shows a menu or step prompt without pin. In groups the bot
+	// needs the right to pin messages; without it the menu stays unpinned.
+	Pin bool `json:"pin" yaml:"pin" mapstructure:"pin"`
+
 	// ParseMode specifies the text formatting: Markdown, MarkdownV2, or HTML.
+	// Template values in the text are escaped for it; see ValidParseMode.
 	ParseMode string `json:"parse_mode" yaml:"parse_mode" mapstructure:"parse_mode"`
 }
 
@@ -33,6 +75,10 @@ type ButtonConfig struct {
 	// Text is the button label shown to users.
 	Text string `json:"text" yaml:"text" mapstructure:"text"`
 
+	// TextKey is the catalog key of the label in the user's language; Text is
+	// shown when no catalog has it.
+	TextKey string `json:"text_key" yaml:"text_key" mapstructure:"text_key"`
+
 	// Callback is the callback data sent when button is pressed.
 	// Mutually exclusive with URL, FlowID, and MenuID.
 	Callback string `json:"callback" yaml:"callback" mapstructure:"callback"`
@@ -54,6 +100,40 @@ type ButtonConfig struct {
 
 	// Condition is an expression that determines when this button should be shown.
 	Condition string `json:"condition" yaml:"condition" mapstructure:"condition"`
+
+	// Roles restricts the button to users holding any of these roles.
+	// The button is hidden from other users and rejected if they press it anyway.
+	Roles []string `json:"roles" yaml:"roles" mapstructure:"roles"`
+
+	// RequestContact makes a reply keyboard button share the user's phone number.
+	// Only applies to reply keyboards in private chats.
+	RequestContact bool `json:"request_contact" yaml:"request_contact" mapstructure:"request_contact"`
+
+	// RequestLocation makes a reply keyboard button share the user's location.
+	// Only applies to reply keyboards in private chats.
+	RequestLocation bool `json:"request_location" yaml:"request_location" mapstructure:"request_location"`
+
+	// RequestPoll makes a reply keyboard button let the user create a poll:
+	// "quiz", "regular", or "any". Only applies to reply keyboards in private chats.
+	RequestPoll string `json:"request_poll" yaml:"request_poll" mapstructure:"request_poll"`
+
+	// WebApp is the URL of a Web App the button opens. Data the Web App sends
+	// with Telegram.WebApp.sendData arrives as a message, and only from reply
+	// keyboard buttons in private chats.
+	WebApp string `json:"web_app" yaml:"web_app" mapstructure:"web_app"`
+}
+
+// CallbackData returns the callback data the button sends, or "" for URL and Web App buttons.
+func (b ButtonConfig) CallbackData() string {
+	switch {
+	case b.URL != "", b.WebApp != "":
+		return ""
+	case b.FlowID != "":
+		return "flow:" + b.FlowID
+	case b.MenuID != "":
+		return "menu:" + b.MenuID
+	}
+	return b.Callback
 }
 
 // PageConfig defines a page within a paginated menu.
@@ -82,9 +162,30 @@ func (m *MenuConfig) Validate() error {
 	if m.Text == "" && len(m.Buttons) == 0 && len(m.Pages) == 0 {
 		return ErrInvalidMenu
 	}
+	switch m.Fork {
+	case ForkNone, ForkReply, ForkDM:
+	default:
+		return ErrInvalidMenu
+	}
+	if m.Fork != ForkNone && m.BindUser {
+		return ErrInvalidMenu // A shared menu can't belong to one user
+	}
+	if !ValidParseMode(m.ParseMode) {
+		return ErrInvalidMenu
+	}
 	return nil
 }
 
+// ValidParseMode returns true if mode is empty, for plain text, or one of
+// Telegram's parse modes: Markdown, MarkdownV2, or HTML.
+func ValidParseMode(mode string) bool {
+	switch mode {
+	case "", "Markdown", "MarkdownV2", "HTML":
+		return true
+	}
+	return false
+}
+
 // GetButtons returns the buttons for a specific page or the default buttons.
 // If pageID is empty or not found, returns the main Buttons.
 func (m *MenuConfig) GetButtons(pageID string) [][]ButtonConfig {
@@ -0,0 +1,82 @@
+// Package config defines configuration structures for tgwrapper.
+package config
+
+import (
+	"strings"
+	"time"
+)
+
+// Keyboard provider types for ProviderConfig.Type.
+const (
+	// ProviderTypeHTTPJSON lists buttons from the JSON response of an HTTP GET request.
+	ProviderTypeHTTPJSON = "http_json"
+)
+
+// ProviderConfig declares a dynamic keyboard provider in configuration, so
+// simple keyboards backed by an API need no Go code.
+//
+// Example:
+//
+//	providers:
+//	    getCryptoPrices:
+//	        type: http_json
+//	        url: "https://api.coingecko.com/api/v3/coins/markets?vs_currency={{urlquery .currency}}"
+//	        items: "$[*]"
+//	        text: "$.name"
+//	        callback: "$.id"
+//	        cache_ttl: 1m
+type ProviderConfig struct {
+	// Type is the provider type. Only "http_json" is supported.
+	Type string `json:"type" yaml:"type" mapstructure:"type"`
+
+	// URL is requested with GET. Supports template variables; use urlquery
+	// to escape values, e.g. "?q={{urlquery .query}}".
+	URL string `json:"url" yaml:"url" mapstructure:"url"`
+
+	// Headers are sent with every request, e.g. Authorization.
+	// Supports template variables.
+	Headers map[string]string `json:"headers" yaml:"headers" mapstructure:"headers"`
+
+	// Items is the JSONPath of the items in the response, one button each,
+	// e.g. "$.data.coins[*]". Defaults to "$[*]", a top-level array.
+	Items string `json:"items" yaml:"items" mapstructure:"items"`
+
+	// Text is the JSONPath of the button label within an item, e.g. "$.name". Required.
+	Text string `json:"text" yaml:"text" mapstructure:"text"`
+
+	// Callback is the JSONPath of the callback data within an item.
+	// Defaults to the label.
+	Callback string `json:"callback" yaml:"callback" mapstructure:"callback"`
+
+	// CacheTTL is how long responses are cached by URL. 0 requests the URL on every render.
+	CacheTTL time.Duration `json:"cache_ttl" yaml:"cache_ttl" mapstructure:"cache_ttl"`
+
+	// Timeout is the timeout of requests. Defaults to 10s.
+	Timeout time.Duration `json:"timeout" yaml:"timeout" mapstructure:"timeout"`
+
+	// PageSize is the number of buttons on a page; longer lists get previous
+	// and next buttons. Defaults to 10.
+	PageSize int `json:"page_size" yaml:"page_size" mapstructure:"page_size"`
+}
+
+// GetItems returns the JSONPath of the items in the response.
+func (p *ProviderConfig) GetItems() string {
+	if p == nil || p.Items == "" {
+		return "$[*]"
+	}
+	return p.Items
+}
+
+// Valid returns true if the provider has a known type, a URL, a label path,
+// and JSONPaths starting with "$".
+func (p *ProviderConfig) Valid() bool {
+	if p == nil || p.Type != ProviderTypeHTTPJSON || p.URL == "" || p.Text == "" {
+		return false
+	}
+	for _, path := range []string{p.GetItems(), p.Text, p.Callback} {
+		if path != "" && !strings.HasPrefix(path, "$") {
+			return false
+		}
+	}
+	return p.CacheTTL >= 0 && p.Timeout >= 0 && p.PageSize >= 0
+}
@@ -0,0 +1,54 @@
+// Package config defines configuration structures for tgwrapper.
+package config
+
+// DefaultReferralStatsText is the default reply to the referral stats command.
+// It is rendered as a template with the referral namespace.
+const DefaultReferralStatsText = "🤝 Invite friends\n\nYour link: {{.referral.link}}\nFriends invited: {{.referral.count}}"
+
+// ReferralConfig defines referral tracking.
+// Users get a personal deep link; new users who open it are attributed to the
+// referrer, and attribution is persisted in the store.
+type ReferralConfig struct {
+	// Enabled turns on referral attribution and the stats command.
+	Enabled bool `json:"enabled" yaml:"enabled" mapstructure:"enabled"`
+
+	// Command is the command that shows the user's referral link and stats,
+	// without the leading slash. Defaults to "referrals".
+	Command string `json:"command" yaml:"command" mapstructure:"command"`
+
+	// PayloadPrefix is the /start payload prefix marking referral codes. Defaults to "ref_".
+	PayloadPrefix string `json:"payload_prefix" yaml:"payload_prefix" mapstructure:"payload_prefix"`
+
+	// StatsText is the reply to the stats command, rendered as a template.
+	// Available values: {{.referral.link}}, {{.referral.code}}, {{.referral.count}},
+	// {{.referral.referred_by}}. Defaults to DefaultReferralStatsText.
+	StatsText string `json:"stats_text" yaml:"stats_text" mapstructure:"stats_text"`
+
+	// ShareText is the message prefilled when the user presses the Share button.
+	// If empty, the Share button is not shown.
+	ShareText string `json:"share_text" yaml:"share_text" mapstructure:"share_text"`
+}
+
+// GetCommand returns the stats command, defaulting to "referrals".
+func (r *ReferralConfig) GetCommand() string {
+	if r.Command == "" {
+		return "referrals"
+	}
+	return r.Command
+}
+
+// GetPayloadPrefix returns the referral payload prefix, defaulting to "ref_".
+func (r *ReferralConfig) GetPayloadPrefix() string {
+	if r.PayloadPrefix == "" {
+		return "ref_"
+	}
+	return r.PayloadPrefix
+}
+
+// GetStatsText returns the stats reply template, defaulting to DefaultReferralStatsText.
+func (r *ReferralConfig) GetStatsText() string {
+	if r.StatsText == "" {
+		return DefaultReferralStatsText
+	}
+	return r.StatsText
+}
@@ -0,0 +1,66 @@
+// Package config defines configuration structures for tgwrapper.
+package config
+
+import "time"
+
+// DefaultSnoozeOptions are the snooze buttons of delivered reminders if none are configured.
+var DefaultSnoozeOptions = []time.Duration{10 * time.Minute, time.Hour, 24 * time.Hour}
+
+// ReminderConfig defines user reminders. Users create them with a built-in
+// flow (what and when); they are persisted in the store and delivered with
+// Snooze and Done buttons.
+type ReminderConfig struct {
+	// Enabled turns on the reminder flow and commands.
+	Enabled bool `json:"enabled" yaml:"enabled" mapstructure:"enabled"`
+
+	// Command starts the reminder flow, without the leading slash. Defaults to "remind".
+	Command string `json:"command" yaml:"command" mapstructure:"command"`
+
+	// ListCommand lists the user's pending reminders with buttons to cancel them,
+	// without the leading slash. Defaults to "reminders".
+	ListCommand string `json:"list_command" yaml:"list_command" mapstructure:"list_command"`
+
+	// SnoozeOptions are the delays offered by the Snooze buttons of a delivered
+	// reminder. Defaults to DefaultSnoozeOptions.
+	SnoozeOptions []time.Duration `json:"snooze_options" yaml:"snooze_options" mapstructure:"snooze_options"`
+
+	// MaxPending limits the pending reminders per user. 0 is unlimited.
+	MaxPending int `json:"max_pending" yaml:"max_pending" mapstructure:"max_pending"`
+}
+
+// Validate checks if the reminder configuration is valid.
+func (r *ReminderConfig) Validate() error {
+	if r.MaxPending < 0 {
+		return ErrInvalidReminder
+	}
+	for _, d := range r.SnoozeOptions {
+		if d <= 0 {
+			return ErrInvalidReminder
+		}
+	}
+	return nil
+}
+
+// GetCommand returns the command starting the reminder flow, defaulting to "remind".
+func (r *ReminderConfig) GetCommand() string {
+	if r.Command == "" {
+		return "remind"
+	}
+	return r.Command
+}
+
+// GetListCommand returns the command listing pending reminders, defaulting to "reminders".
+func (r *ReminderConfig) GetListCommand() string {
+	if r.ListCommand == "" {
+		return "reminders"
+	}
+	return r.ListCommand
+}
+
+// GetSnoozeOptions returns the snooze delays, defaulting to DefaultSnoozeOptions.
+func (r *ReminderConfig) GetSnoozeOptions() []time.Duration {
+	if len(r.SnoozeOptions) == 0 {
+		return DefaultSnoozeOptions
+	}
+	return r.SnoozeOptions
+}
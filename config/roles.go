@@ -0,0 +1,131 @@
+// Package config defines configuration structures for tgwrapper.
+package config
+
+import (
+	"slices"
+	"strings"
+)
+
+// Built-in role names.
+const (
+	// RoleAdmin passes every role check.
+	RoleAdmin = "admin"
+
+	// RoleOperator is held by admin panel operators and members of the operator role.
+	RoleOperator = "operator"
+
+	// RoleUser is held by every user.
+	RoleUser = "user"
+)
+
+// DefaultForbiddenText is the reply shown when a user lacks the role for a
+// command, menu, button, or flow.
+const DefaultForbiddenText = "⛔ You don't have access to this."
+
+// RoleConfig lists the members of a role.
+type RoleConfig struct {
+	// Users lists the user IDs holding the role.
+	Users []int64 `json:"users" yaml:"users" mapstructure:"users"`
+
+	// Usernames lists the usernames holding the role, without the leading @.
+	// Usernames can change; prefer user IDs for privileged roles.
+	Usernames []string `json:"usernames" yaml:"usernames" mapstructure:"usernames"`
+}
+
+// Has returns true if the user is a member of the role.
+func (r *RoleConfig) Has(userID int64, username string) bool {
+	if r == nil {
+		return false
+	}
+	if slices.Contains(r.Users, userID) {
+		return true
+	}
+	if username == "" {
+		return false
+	}
+	return slices.ContainsFunc(r.Usernames, func(name string) bool {
+		return strings.EqualFold(strings.TrimPrefix(name, "@"), username)
+	})
+}
+
+// UserRoles returns the roles the configuration assigns to a user: "user",
+// every role listing the user, and "operator" for admin panel operators.
+func (c *Config) UserRoles(userID int64, username string) []string {
+	roles := []string{RoleUser}
+	for name, role := range c.Roles {
+		if name != RoleUser && role.Has(userID, username) {
+			roles = append(roles, name)
+		}
+	}
+	if c.Admin != nil && c.Admin.IsOperator(userID) && !slices.Contains(roles, RoleOperator) {
+		roles = append(roles, RoleOperator)
+	}
+	return roles
+}
+
+// HasRole returns true if roles satisfy required: required is empty, roles
+// contain "admin", or roles contain any of the required roles.
+func HasRole(roles, required []string) bool {
+	if len(required) == 0 || slices.Contains(roles, RoleAdmin) {
+		return true
+	}
+	return slices.ContainsFunc(required, func(role string) bool {
+		return slices.Contains(roles, role)
+	})
+}
+
+// CallbackAllowed returns true if roles may send callback data: they satisfy
+// the roles of the menu or flow it opens, and of the menu and step buttons
+// sending it unless one of those buttons is open to everyone.
+func (c *Config) CallbackAllowed(data string, roles []string) bool {
+	if id, ok := strings.CutPrefix(data, "menu:"); ok {
+		if m := c.Menus[id]; m != nil && !HasRole(roles, m.Roles) {
+			return false
+		}
+	}
+	if id, ok := strings.CutPrefix(data, "flow:"); ok {
+		if f := c.Flows[id]; f != nil && !HasRole(roles, f.Roles) {
+			return false
+		}
+	}
+
+	var required []string
+	for _, row := range c.buttonRows() {
+		for _, btn := range row {
+			if btn.CallbackData() != data {
+				continue
+			}
+			if len(btn.Roles) == 0 {
+				return true
+			}
+			required = append(required, btn.Roles...)
+		}
+	}
+	return HasRole(roles, required)
+}
+
+// buttonRows returns the button rows of every menu and step keyboard.
+func (c *Config) buttonRows() [][]ButtonConfig {
+	var rows [][]ButtonConfig
+	for _, m := range c.Menus {
+		rows = append(rows, m.Buttons...)
+		for _, page := range m.Pages {
+			rows = append(rows, page.Buttons...)
+		}
+		if m.EmptyState != nil {
+			rows = append(rows, m.EmptyState.Buttons...)
+		}
+	}
+	for _, f := range c.Flows {
+		for _, step := range f.Steps {
+			if step.Keyboard == nil {
+				continue
+			}
+			rows = append(rows, step.Keyboard.Buttons...)
+			if step.Keyboard.EmptyState != nil {
+				rows = append(rows, step.Keyboard.EmptyState.Buttons...)
+			}
+		}
+	}
+	return rows
+}
@@ -0,0 +1,79 @@
+// Package config defines configuration structures for tgwrapper.
+package config
+
+import "time"
+
+// RSVPConfig defines an event signup sheet: a message with Going, Maybe, and
+// No buttons listing who answered what.
+type RSVPConfig struct {
+	// ID is the unique identifier for this RSVP configuration.
+	ID string `json:"id" yaml:"id" mapstructure:"id"`
+
+	// Title is the event name shown above the attendee list.
+	Title string `json:"title" yaml:"title" mapstructure:"title"`
+
+	// Description is optional text shown under the title, e.g. the time and place.
+	Description string `json:"description" yaml:"description" mapstructure:"description"`
+
+	// Capacity limits the number of going users. 0 is unlimited.
+	Capacity int `json:"capacity" yaml:"capacity" mapstructure:"capacity"`
+
+	// Waitlist queues users who want to go to a full event; they get places
+	// in order as going users change their answer. Without it, Going is
+	// rejected once the event is full.
+	Waitlist bool `json:"waitlist" yaml:"waitlist" mapstructure:"waitlist"`
+
+	// Roles restricts responding to users holding any of these roles.
+	Roles []string `json:"roles" yaml:"roles" mapstructure:"roles"`
+
+	// MembersOnly restricts responding to members of the chat the sheet is posted in.
+	MembersOnly bool `json:"members_only" yaml:"members_only" mapstructure:"members_only"`
+
+	// Duration closes signups automatically after this time.
+	// Zero keeps them open until they are closed with CloseRSVP.
+	Duration time.Duration `json:"duration" yaml:"duration" mapstructure:"duration"`
+
+	// GoingText customizes the Going button label.
+	GoingText string `json:"going_text" yaml:"going_text" mapstructure:"going_text"`
+
+	// MaybeText customizes the Maybe button label.
+	MaybeText string `json:"maybe_text" yaml:"maybe_text" mapstructure:"maybe_text"`
+
+	// NoText customizes the No button label.
+	NoText string `json:"no_text" yaml:"no_text" mapstructure:"no_text"`
+}
+
+// Validate checks if the RSVP configuration is valid.
+func (r *RSVPConfig) Validate() error {
+	if r.ID == "" || r.Title == "" || r.Capacity < 0 || r.Duration < 0 {
+		return ErrInvalidRSVP
+	}
+	return nil
+}
+
+// GetGoingText returns the Going button label.
+// Returns a default emoji text if not customized.
+func (r *RSVPConfig) GetGoingText() string {
+	if r.GoingText == "" {
+		return "✅ Going"
+	}
+	return r.GoingText
+}
+
+// GetMaybeText returns the Maybe button label.
+// Returns a default emoji text if not customized.
+func (r *RSVPConfig) GetMaybeText() string {
+	if r.MaybeText == "" {
+		return "🤔 Maybe"
+	}
+	return r.MaybeText
+}
+
+// GetNoText returns the No button label.
+// Returns a default emoji text if not customized.
+func (r *RSVPConfig) GetNoText() string {
+	if r.NoText == "" {
+		return "❌ No"
+	}
+	return r.NoText
+}
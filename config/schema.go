@@ -0,0 +1,131 @@
+package config
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// DataType is the type of a declared data key.
+type DataType string
+
+const (
+	// DataTypeString is text.
+	DataTypeString DataType = "string"
+
+	// DataTypeNumber is a floating-point number, stored as float64.
+	DataTypeNumber DataType = "number"
+
+	// DataTypeInt is a whole number, stored as int.
+	DataTypeInt DataType = "int"
+
+	// DataTypeBool is true or false; text input accepts true/false, yes/no, and 1/0.
+	DataTypeBool DataType = "bool"
+
+	// DataTypeTime is a point in time, stored as time.Time; text input
+	// accepts RFC 3339 and "2006-01-02" dates.
+	DataTypeTime DataType = "time"
+
+	// DataTypeList is a list of values.
+	DataTypeList DataType = "list"
+
+	// DataTypeMap is a map of values, e.g. the data of a namespaced sub-flow.
+	DataTypeMap DataType = "map"
+
+	// DataTypeAny accepts any value.
+	DataTypeAny DataType = "any"
+)
+
+// Valid returns true if the type is known.
+func (t DataType) Valid() bool {
+	switch t {
+	case DataTypeString, DataTypeNumber, DataTypeInt, DataTypeBool, DataTypeTime, DataTypeList, DataTypeMap, DataTypeAny:
+		return true
+	}
+	return false
+}
+
+// DataFieldConfig declares a key of a flow's data.
+type DataFieldConfig struct {
+	// Key is the data key, as used in store_as, computed keys, and templates.
+	Key string `json:"key" yaml:"key" mapstructure:"key"`
+
+	// Type is the type values are converted to when stored. Defaults to "any".
+	Type DataType `json:"type" yaml:"type" mapstructure:"type"`
+
+	// Required keys must be set before the flow completes; a missing key
+	// sends the user back to the step storing it.
+	Required bool `json:"required" yaml:"required" mapstructure:"required"`
+}
+
+// GetType returns the declared type, defaulting to DataTypeAny.
+func (d *DataFieldConfig) GetType() DataType {
+	if d.Type == "" {
+		return DataTypeAny
+	}
+	return d.Type
+}
+
+// Field returns the schema declaration of a data key, or nil if the flow
+// has no schema or doesn't declare the key.
+func (f *FlowConfig) Field(key string) *DataFieldConfig {
+	for i := range f.Schema {
+		if f.Schema[i].Key == key {
+			return &f.Schema[i]
+		}
+	}
+	return nil
+}
+
+// StepStoring returns the ID of a step storing a data key, or "" if none does.
+func (f *FlowConfig) StepStoring(key string) string {
+	for _, id := range slices.Sorted(maps.Keys(f.Steps)) {
+		for _, stored := range f.Steps[id].storedKeys() {
+			if stored == key {
+				return id
+			}
+		}
+	}
+	return ""
+}
+
+// storedKeys returns the data keys a step stores by configuration: its
+// store_as key, its computed keys, and its sub-flow namespace. Chat settings
+// are not part of the flow's data.
+func (s *StepConfig) storedKeys() []string {
+	var keys []string
+	if s.StoreAs != "" {
+		keys = append(keys, s.StoreAs)
+	}
+	for _, c := range s.Computed {
+		keys = append(keys, c.Key)
+	}
+	if s.SubFlow != nil && s.SubFlow.Namespace != "" {
+		keys = append(keys, s.SubFlow.Namespace)
+	}
+	return keys
+}
+
+// validateSchema checks the schema's declarations and that every step
+// stores declared keys only, catching typos such as "userNmae".
+func (f *FlowConfig) validateSchema() error {
+	if len(f.Schema) == 0 {
+		return nil
+	}
+	seen := make(map[string]bool, len(f.Schema))
+	for _, field := range f.Schema {
+		if field.Key == "" || seen[field.Key] || !field.GetType().Valid() {
+			return fmt.Errorf("%w: flow %q key %q", ErrInvalidSchema, f.ID, field.Key)
+		}
+		seen[field.Key] = true
+	}
+	for _, id := range slices.Sorted(maps.Keys(f.Steps)) {
+		for _, key := range f.Steps[id].storedKeys() {
+			if !strings.HasPrefix(key, "chat.") && !seen[key] {
+				return fmt.Errorf("%w: flow %q step %q stores %q", ErrUndeclaredKey, f.ID, id, key)
+			}
+		}
+	}
+	return nil
+}
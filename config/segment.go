@@ -0,0 +1,39 @@
+// Package config defines configuration structures for tgwrapper.
+package config
+
+import "time"
+
+// SegmentConfig defines a named group of users selected from the user registry.
+// All configured criteria must match; empty criteria are ignored.
+// Segments are usable as broadcast audiences.
+type SegmentConfig struct {
+	// ID is the unique identifier for this segment.
+	ID string `json:"id" yaml:"id" mapstructure:"id"`
+
+	// Languages matches users whose Telegram language code is one of the listed codes.
+	// Regional variants match their base language ("en" matches "en-US").
+	Languages []string `json:"languages" yaml:"languages" mapstructure:"languages"`
+
+	// SeenWithin matches users active within this duration.
+	SeenWithin time.Duration `json:"seen_within" yaml:"seen_within" mapstructure:"seen_within"`
+
+	// NotSeenWithin matches users inactive for at least this duration.
+	NotSeenWithin time.Duration `json:"not_seen_within" yaml:"not_seen_within" mapstructure:"not_seen_within"`
+
+	// Attributes matches users whose custom attributes equal all listed values.
+	Attributes map[string]interface{} `json:"attributes" yaml:"attributes" mapstructure:"attributes"`
+
+	// CompletedFlows matches users who completed all listed flows.
+	CompletedFlows []string `json:"completed_flows" yaml:"completed_flows" mapstructure:"completed_flows"`
+
+	// NotCompletedFlows matches users who completed none of the listed flows.
+	NotCompletedFlows []string `json:"not_completed_flows" yaml:"not_completed_flows" mapstructure:"not_completed_flows"`
+}
+
+// Validate checks if the segment configuration is valid.
+func (s *SegmentConfig) Validate() error {
+	if s.ID == "" {
+		return ErrInvalidSegment
+	}
+	return nil
+}
@@ -0,0 +1,174 @@
+// Package config defines configuration structures for tgwrapper.
+package config
+
+import "time"
+
+// TenantConfig defines a set of per-chat overrides layered on top of the base configuration.
+// A tenant applies to one or more chats (typically a community group) and replaces
+// selected menus, texts, and flow parameters without duplicating the whole config.
+// Anything not overridden falls back to the base configuration.
+type TenantConfig struct {
+	// ID is the unique identifier for this tenant.
+	ID string `json:"id" yaml:"id" mapstructure:"id"`
+
+	// ChatIDs lists the chats this tenant applies to.
+	ChatIDs []int64 `json:"chat_ids" yaml:"chat_ids" mapstructure:"chat_ids"`
+
+	// MainMenuID overrides the main menu shown in the tenant's chats.
+	MainMenuID string `json:"main_menu_id" yaml:"main_menu_id" mapstructure:"main_menu_id"`
+
+	// Menus overrides menus by ID. A tenant menu fully replaces the base menu
+	// with the same ID, and may also define menus that only exist for this tenant.
+	Menus map[string]*MenuConfig `json:"menus" yaml:"menus" mapstructure:"menus"`
+
+	// Texts overrides individual texts by key.
+	// Use MenuTextKey and StepTextKey to build keys:
+	// "menu.<menu_id>" for menu texts and "flow.<flow_id>.<step_id>" for step prompts.
+	Texts map[string]string `json:"texts" yaml:"texts" mapstructure:"texts"`
+
+	// Flows overrides flow parameters by flow ID.
+	Flows map[string]*FlowOverrideConfig `json:"flows" yaml:"flows" mapstructure:"flows"`
+
+	// Environment overrides environment variables for the tenant's chats.
+	Environment map[string]interface{} `json:"environment" yaml:"environment" mapstructure:"environment"`
+}
+
+// FlowOverrideConfig defines per-tenant parameters for a flow.
+type FlowOverrideConfig struct {
+	// InitialStep overrides the step the flow starts at.
+	InitialStep string `json:"initial_step" yaml:"initial_step" mapstructure:"initial_step"`
+
+	// TTL overrides the conversation time-to-live for the flow.
+	TTL time.Duration `json:"ttl" yaml:"ttl" mapstructure:"ttl"`
+
+	// Params are seeded into the conversation data when the flow starts,
+	// making them available to prompts, conditions, and handlers.
+	Params map[string]interface{} `json:"params" yaml:"params" mapstructure:"params"`
+}
+
+// MenuTextKey returns the TenantConfig.Texts key for a menu's text.
+func MenuTextKey(menuID string) string {
+	return "menu." + menuID
+}
+
+// StepTextKey returns the TenantConfig.Texts key for a step's prompt text.
+func StepTextKey(flowID, stepID string) string {
+	return "flow." + flowID + "." + stepID
+}
+
+// Validate checks if the tenant configuration is valid.
+func (t *TenantConfig) Validate() error {
+	if t.ID == "" || len(t.ChatIDs) == 0 {
+		return ErrInvalidTenant
+	}
+	for _, menu := range t.Menus {
+		if err := menu.Validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// HasChat returns true if the tenant applies to the given chat.
+func (t *TenantConfig) HasChat(chatID int64) bool {
+	for _, id := range t.ChatIDs {
+		if id == chatID {
+			return true
+		}
+	}
+	return false
+}
+
+// TenantFor returns the tenant that applies to the given chat.
+// Validate rejects chats in several tenants; should a chat be in several
+// anyway, the tenant with the first ID in order applies.
+// Returns nil if the chat has no tenant overrides.
+func (c *Config) TenantFor(chatID int64) *TenantConfig {
+	for _, id := range sortedKeys(c.Tenants) {
+		if t := c.Tenants[id]; t != nil && t.HasChat(chatID) {
+			return t
+		}
+	}
+	return nil
+}
+
+// ResolveMenu returns the menu configuration for a chat, honoring tenant overrides.
+// Returns nil if the menu doesn't exist for the chat.
+func (c *Config) ResolveMenu(chatID int64, menuID string) *MenuConfig {
+	if t := c.TenantFor(chatID); t != nil {
+		if menu, ok := t.Menus[menuID]; ok {
+			return menu
+		}
+	}
+	return c.GetMenu(menuID)
+}
+
+// ResolveMainMenuID returns the main menu ID for a chat, honoring tenant overrides.
+func (c *Config) ResolveMainMenuID(chatID int64) string {
+	if t := c.TenantFor(chatID); t != nil && t.MainMenuID != "" {
+		return t.MainMenuID
+	}
+	return c.MainMenuID
+}
+
+// ResolveText returns the tenant override for a text key, or fallback if none is set.
+func (c *Config) ResolveText(chatID int64, key, fallback string) string {
+	if t := c.TenantFor(chatID); t != nil {
+		if text, ok := t.Texts[key]; ok {
+			return text
+		}
+	}
+	return fallback
+}
+
+// ResolveFlow returns the flow configuration for a chat with tenant parameters applied.
+// The base flow is never modified; a copy is returned when overrides exist.
+// Returns nil if the flow doesn't exist.
+func (c *Config) ResolveFlow(chatID int64, flowID string) *FlowConfig {
+	flow := c.GetFlow(flowID)
+	if flow == nil {
+		return nil
+	}
+
+	t := c.TenantFor(chatID)
+	if t == nil {
+		return flow
+	}
+	override, ok := t.Flows[flowID]
+	if !ok || override == nil {
+		return flow
+	}
+
+	resolved := *flow
+	if override.InitialStep != "" {
+		resolved.InitialStep = override.InitialStep
+	}
+	if override.TTL > 0 {
+		resolved.TTL = override.TTL
+	}
+	return &resolved
+}
+
+// FlowParams returns the tenant parameters for a flow in a chat.
+// Returns nil if no parameters are configured.
+func (c *Config) FlowParams(chatID int64, flowID string) map[string]interface{} {
+	t := c.TenantFor(chatID)
+	if t == nil {
+		return nil
+	}
+	if override, ok := t.Flows[flowID]; ok && override != nil {
+		return override.Params
+	}
+	return nil
+}
+
+// GetEnvFor retrieves an environment variable for a chat, honoring tenant overrides.
+// Returns nil if the key doesn't exist.
+func (c *Config) GetEnvFor(chatID int64, key string) interface{} {
+	if t := c.TenantFor(chatID); t != nil {
+		if v, ok := t.Environment[key]; ok {
+			return v
+		}
+	}
+	return c.GetEnv(key)
+}
@@ -0,0 +1,158 @@
+// Package config defines configuration structures for tgwrapper.
+package config
+
+import "strings"
+
+// ThemeConfig overrides the built-in texts of navigation buttons, pagination,
+// message decorations, and default validation messages, to rebrand or
+// translate the built-in UI. Texts left empty keep their defaults.
+//
+// Example:
+//
+//	theme:
+//	    strings:
+//	        back: "◀ Return"
+//	    locales:
+//	        de:
+//	            back: "◀ Zurück"
+//	            main_menu: "🏠 Hauptmenü"
+type ThemeConfig struct {
+	// Strings override the built-in texts for all users.
+	Strings StringsConfig `json:"strings" yaml:"strings" mapstructure:"strings"`
+
+	// Locales override the texts for users by base language of their Telegram
+	// language code, e.g. "de" for "de-AT", on top of Strings.
+	Locales map[string]StringsConfig `json:"locales" yaml:"locales" mapstructure:"locales"`
+}
+
+// StringsConfig defines built-in texts. It mirrors core.Strings field for
+// field, so it converts to it directly.
+type StringsConfig struct {
+	// Back is the label of back buttons.
+	Back string `json:"back" yaml:"back" mapstructure:"back"`
+
+	// MainMenu is the label of main menu buttons.
+	MainMenu string `json:"main_menu" yaml:"main_menu" mapstructure:"main_menu"`
+
+	// Cancel is the label of cancel buttons.
+	Cancel string `json:"cancel" yaml:"cancel" mapstructure:"cancel"`
+
+	// Loading is the label of the placeholder button shown while buttons load.
+	Loading string `json:"loading" yaml:"loading" mapstructure:"loading"`
+
+	// PrevPage is the label of the previous page button.
+	PrevPage string `json:"prev_page" yaml:"prev_page" mapstructure:"prev_page"`
+
+	// NextPage is the label of the next page button.
+	NextPage string `json:"next_page" yaml:"next_page" mapstructure:"next_page"`
+
+	// PageIndicator formats the page indicator with two %d verbs for the
+	// current and total pages, e.g. "Page %d of %d".
+	PageIndicator string `json:"page_indicator" yaml:"page_indicator" mapstructure:"page_indicator"`
+
+	// Separator is the line used to separate message sections.
+	Separator string `json:"separator" yaml:"separator" mapstructure:"separator"`
+
+	// InvalidNumber is the default error of number steps given no number.
+	InvalidNumber string `json:"invalid_number" yaml:"invalid_number" mapstructure:"invalid_number"`
+
+	// NumberTooSmall is the default error of number steps given a number
+	// below min, with a %s verb for the minimum.
+	NumberTooSmall string `json:"number_too_small" yaml:"number_too_small" mapstructure:"number_too_small"`
+
+	// NumberTooLarge is the default error of number steps given a number
+	// above max, with a %s verb for the maximum.
+	NumberTooLarge string `json:"number_too_large" yaml:"number_too_large" mapstructure:"number_too_large"`
+
+	// InvalidAddress is the default error of address steps.
+	InvalidAddress string `json:"invalid_address" yaml:"invalid_address" mapstructure:"invalid_address"`
+
+	// InvalidEmail is the default error of email steps.
+	InvalidEmail string `json:"invalid_email" yaml:"invalid_email" mapstructure:"invalid_email"`
+
+	// InvalidFormat is the default error of regex steps.
+	InvalidFormat string `json:"invalid_format" yaml:"invalid_format" mapstructure:"invalid_format"`
+
+	// InvalidValue is the error of input that doesn't convert to the type
+	// declared in the flow's schema.
+	InvalidValue string `json:"invalid_value" yaml:"invalid_value" mapstructure:"invalid_value"`
+
+	// Required is the error shown on the step storing a required key that is
+	// missing when the flow completes.
+	Required string `json:"required" yaml:"required" mapstructure:"required"`
+
+	// FileTooLarge is the default error of photo and document steps given a
+	// file above max_size, with a %s verb for the limit.
+	FileTooLarge string `json:"file_too_large" yaml:"file_too_large" mapstructure:"file_too_large"`
+
+	// FileTypeNotAllowed is the default error of document steps given a file
+	// whose MIME type isn't in allowed_mime.
+	FileTypeNotAllowed string `json:"file_type_not_allowed" yaml:"file_type_not_allowed" mapstructure:"file_type_not_allowed"`
+
+	// ImageTooSmall is the default error of photo steps given a photo below
+	// min_dimensions, with a %s verb for them.
+	ImageTooSmall string `json:"image_too_small" yaml:"image_too_small" mapstructure:"image_too_small"`
+}
+
+// Validate checks if the theme configuration is valid.
+func (t *ThemeConfig) Validate() error {
+	if err := t.Strings.Validate(); err != nil {
+		return err
+	}
+	for _, s := range t.Locales {
+		if err := s.Validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Validate checks if the texts are valid.
+func (s StringsConfig) Validate() error {
+	if s.PageIndicator != "" && strings.Count(s.PageIndicator, "%d") != 2 {
+		return ErrInvalidTheme
+	}
+	for _, msg := range []string{s.NumberTooSmall, s.NumberTooLarge, s.FileTooLarge, s.ImageTooSmall} {
+		if msg != "" && strings.Count(msg, "%s") != 1 {
+			return ErrInvalidTheme
+		}
+	}
+	return nil
+}
+
+// For returns the overrides for a language tag such as "de-AT": the locale's
+// texts where set, and the global ones otherwise.
+func (t *ThemeConfig) For(languageCode string) StringsConfig {
+	s := t.Strings
+	lang, _, _ := strings.Cut(strings.ToLower(languageCode), "-")
+	l, ok := t.Locales[lang]
+	if !ok {
+		return s
+	}
+	for _, f := range []struct{ dst, src *string }{
+		{&s.Back, &l.Back},
+		{&s.MainMenu, &l.MainMenu},
+		{&s.Cancel, &l.Cancel},
+		{&s.Loading, &l.Loading},
+		{&s.PrevPage, &l.PrevPage},
+		{&s.NextPage, &l.NextPage},
+		{&s.PageIndicator, &l.PageIndicator},
+		{&s.Separator, &l.Separator},
+		{&s.InvalidNumber, &l.InvalidNumber},
+		{&s.NumberTooSmall, &l.NumberTooSmall},
+		{&s.NumberTooLarge, &l.NumberTooLarge},
+		{&s.InvalidAddress, &l.InvalidAddress},
+		{&s.InvalidEmail, &l.InvalidEmail},
+		{&s.InvalidFormat, &l.InvalidFormat},
+		{&s.InvalidValue, &l.InvalidValue},
+		{&s.Required, &l.Required},
+		{&s.FileTooLarge, &l.FileTooLarge},
+		{&s.FileTypeNotAllowed, &l.FileTypeNotAllowed},
+		{&s.ImageTooSmall, &l.ImageTooSmall},
+	} {
+		if *f.src != "" {
+			*f.dst = *f.src
+		}
+	}
+	return s
+}
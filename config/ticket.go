@@ -0,0 +1,93 @@
+// Package config defines configuration structures for tgwrapper.
+package config
+
+import (
+	"slices"
+	"time"
+)
+
+// DefaultTicketSink is the name of the sink used by flows that don't name one.
+const DefaultTicketSink = "default"
+
+// TicketOutcomes are the conversation outcomes that can file tickets.
+var TicketOutcomes = []string{"completed", "cancelled", "expired", "ended"}
+
+// TicketConfig files a flow's conversations as tickets, with their
+// transcript and data, when they end.
+//
+// Example:
+//
+//	ticket:
+//	    sink: helpdesk
+//	    on: [completed, expired]
+//	    subject: "Support request from {{.name}}"
+//	    tags: [telegram, support]
+type TicketConfig struct {
+	// Sink is the name of the sink tickets are created in: one registered with
+	// RegisterTicketSink, or one of bot.ticket_sinks. Defaults to "default".
+	Sink string `json:"sink" yaml:"sink" mapstructure:"sink"`
+
+	// On lists the outcomes filing a ticket: completed, cancelled, expired, or
+	// ended. Defaults to completed. Escalations always file one.
+	On []string `json:"on" yaml:"on" mapstructure:"on"`
+
+	// Subject is the ticket's subject, rendered as a template with the
+	// conversation's data. Defaults to the flow's name.
+	Subject string `json:"subject" yaml:"subject" mapstructure:"subject"`
+
+	// Tags are added to every ticket of the flow.
+	Tags []string `json:"tags" yaml:"tags" mapstructure:"tags"`
+}
+
+// GetSink returns the name of the sink tickets are created in.
+func (t *TicketConfig) GetSink() string {
+	if t == nil || t.Sink == "" {
+		return DefaultTicketSink
+	}
+	return t.Sink
+}
+
+// Files returns true if conversations ending with an outcome file a ticket.
+func (t *TicketConfig) Files(outcome string) bool {
+	if t == nil {
+		return false
+	}
+	if len(t.On) == 0 {
+		return outcome == "completed"
+	}
+	return slices.Contains(t.On, outcome)
+}
+
+// Valid returns true if all outcomes are known.
+func (t *TicketConfig) Valid() bool {
+	if t == nil {
+		return true
+	}
+	for _, outcome := range t.On {
+		if !slices.Contains(TicketOutcomes, outcome) {
+			return false
+		}
+	}
+	return true
+}
+
+// TicketSinkConfig defines a sink posting tickets as JSON to an HTTP endpoint.
+type TicketSinkConfig struct {
+	// URL is the endpoint tickets are posted to. Required.
+	URL string `json:"url" yaml:"url" mapstructure:"url"`
+
+	// Headers are sent with every request, e.g. Authorization.
+	Headers map[string]string `json:"headers" yaml:"headers" mapstructure:"headers"`
+
+	// IDField is the dot-separated path of the ticket ID in the JSON
+	// response, e.g. "ticket.id". Defaults to "id".
+	IDField string `json:"id_field" yaml:"id_field" mapstructure:"id_field"`
+
+	// Timeout is the timeout of requests. Defaults to 15s.
+	Timeout time.Duration `json:"timeout" yaml:"timeout" mapstructure:"timeout"`
+}
+
+// Valid returns true if the sink has a URL.
+func (s *TicketSinkConfig) Valid() bool {
+	return s != nil && s.URL != ""
+}
@@ -0,0 +1,38 @@
+// Package config defines configuration structures for tgwrapper.
+package config
+
+import "github.com/0xVanfer/tg-listener/tz"
+
+// TimezoneConfig defines per-user time zones. Users pick theirs with a built-in
+// flow (region, then zone, or a typed zone, UTC offset, or current local time);
+// it is persisted in the user registry and used to render and parse times.
+// Other flows can ask for it with a sub_flow step calling the built-in flow.
+type TimezoneConfig struct {
+	// Enabled turns on the time zone command.
+	Enabled bool `json:"enabled" yaml:"enabled" mapstructure:"enabled"`
+
+	// Command starts the time zone flow, without the leading slash. Defaults to "timezone".
+	Command string `json:"command" yaml:"command" mapstructure:"command"`
+
+	// Default is the time zone of users who haven't chosen one, as accepted by
+	// tz.Load. Defaults to UTC.
+	Default string `json:"default" yaml:"default" mapstructure:"default"`
+}
+
+// Validate checks if the time zone configuration is valid.
+func (t *TimezoneConfig) Validate() error {
+	if t.Default != "" {
+		if _, err := tz.Load(t.Default); err != nil {
+			return ErrInvalidTimezone
+		}
+	}
+	return nil
+}
+
+// GetCommand returns the time zone command, defaulting to "timezone".
+func (t *TimezoneConfig) GetCommand() string {
+	if t.Command == "" {
+		return "timezone"
+	}
+	return t.Command
+}
@@ -0,0 +1,62 @@
+// Package config defines configuration structures for tgwrapper.
+package config
+
+import "time"
+
+// MaxVoteOptionIDLength is the maximum length of vote option IDs, which are
+// part of the callback data of the vote buttons.
+const MaxVoteOptionIDLength = 32
+
+// VoteConfig defines a vote cast with buttons. Each option is a button showing
+// its live tally; every eligible user has one ballot.
+type VoteConfig struct {
+	// ID is the unique identifier for this vote configuration.
+	ID string `json:"id" yaml:"id" mapstructure:"id"`
+
+	// Question is the text shown above the tally.
+	Question string `json:"question" yaml:"question" mapstructure:"question"`
+
+	// Options are the choices, one button each.
+	Options []VoteOptionConfig `json:"options" yaml:"options" mapstructure:"options"`
+
+	// Roles restricts voting to users holding any of these roles.
+	Roles []string `json:"roles" yaml:"roles" mapstructure:"roles"`
+
+	// MembersOnly restricts voting to members of the chat the vote is posted in.
+	MembersOnly bool `json:"members_only" yaml:"members_only" mapstructure:"members_only"`
+
+	// Duration closes the vote automatically after this time.
+	// Zero keeps it open until it is closed with CloseVote.
+	Duration time.Duration `json:"duration" yaml:"duration" mapstructure:"duration"`
+
+	// Anonymous hides who voted for which option; otherwise voters are listed under each option.
+	Anonymous bool `json:"anonymous" yaml:"anonymous" mapstructure:"anonymous"`
+
+	// AllowChange lets voters switch their ballot to another option while the vote is open.
+	AllowChange bool `json:"allow_change" yaml:"allow_change" mapstructure:"allow_change"`
+}
+
+// VoteOptionConfig defines a choice of a vote.
+type VoteOptionConfig struct {
+	// ID identifies the option in ballots and results, at most MaxVoteOptionIDLength bytes.
+	ID string `json:"id" yaml:"id" mapstructure:"id"`
+
+	// Text is the option's button label.
+	Text string `json:"text" yaml:"text" mapstructure:"text"`
+}
+
+// Validate checks if the vote configuration is valid.
+// A vote needs a question and at least two options with unique IDs.
+func (v *VoteConfig) Validate() error {
+	if v.ID == "" || v.Question == "" || len(v.Options) < 2 || v.Duration < 0 {
+		return ErrInvalidVote
+	}
+	seen := make(map[string]bool, len(v.Options))
+	for _, o := range v.Options {
+		if o.ID == "" || o.Text == "" || len(o.ID) > MaxVoteOptionIDLength || seen[o.ID] {
+			return ErrInvalidVote
+		}
+		seen[o.ID] = true
+	}
+	return nil
+}
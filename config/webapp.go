@@ -0,0 +1,86 @@
+// Package config defines configuration structures for tgwrapper.
+package config
+
+import (
+	"strings"
+	"time"
+)
+
+// Menu button types for MenuButtonConfig.Type.
+const (
+	// MenuButtonCommands opens the bot's command list.
+	MenuButtonCommands = "commands"
+
+	// MenuButtonWebApp opens a Web App.
+	MenuButtonWebApp = "web_app"
+
+	// MenuButtonDefault leaves the menu button to Telegram's default.
+	MenuButtonDefault = "default"
+)
+
+// DefaultWebAppInitDataTTL is how long Web App init data is accepted after
+// Telegram signed it.
+const DefaultWebAppInitDataTTL = 24 * time.Hour
+
+// WebAppConfig defines the bot's Web App integration.
+//
+// Example:
+//
+//	web_app:
+//	    menu_button:
+//	        type: web_app
+//	        text: "🛒 Shop"
+//	        url: "https://shop.example.com"
+//	    init_data_ttl: 1h
+type WebAppConfig struct {
+	// MenuButton is set as the menu button of every private chat on start.
+	// The menu button is left unchanged if nil.
+	MenuButton *MenuButtonConfig `json:"menu_button" yaml:"menu_button" mapstructure:"menu_button"`
+
+	// InitDataTTL is how long init data is accepted after Telegram signed it,
+	// limiting the replay of leaked init data. Defaults to 24h.
+	InitDataTTL time.Duration `json:"init_data_ttl" yaml:"init_data_ttl" mapstructure:"init_data_ttl"`
+}
+
+// GetInitDataTTL returns how long init data is accepted after Telegram signed it.
+func (c *WebAppConfig) GetInitDataTTL() time.Duration {
+	if c == nil || c.InitDataTTL <= 0 {
+		return DefaultWebAppInitDataTTL
+	}
+	return c.InitDataTTL
+}
+
+// Valid returns true if the menu button is valid and the TTL isn't negative.
+func (c *WebAppConfig) Valid() bool {
+	if c == nil {
+		return true
+	}
+	return c.InitDataTTL >= 0 && (c.MenuButton == nil || c.MenuButton.Valid())
+}
+
+// MenuButtonConfig defines the button next to the input field of private chats.
+type MenuButtonConfig struct {
+	// Type is "commands", "web_app", or "default".
+	Type string `json:"type" yaml:"type" mapstructure:"type"`
+
+	// Text is the label of a Web App menu button.
+	Text string `json:"text" yaml:"text" mapstructure:"text"`
+
+	// URL is the HTTPS address of the Web App a Web App menu button opens.
+	URL string `json:"url" yaml:"url" mapstructure:"url"`
+}
+
+// Valid returns true if the type is known and a Web App button has a label
+// and an HTTPS URL.
+func (b *MenuButtonConfig) Valid() bool {
+	if b == nil {
+		return false
+	}
+	switch b.Type {
+	case MenuButtonCommands, MenuButtonDefault:
+		return true
+	case MenuButtonWebApp:
+		return b.Text != "" && strings.HasPrefix(b.URL, "https://")
+	}
+	return false
+}
@@ -0,0 +1,29 @@
+package conv
+
+import (
+	"github.com/0xVanfer/tg-listener/breaker"
+	"github.com/0xVanfer/tg-listener/config"
+)
+
+// Breakers returns the circuit breakers of step handlers and keyboard providers.
+func (e *FlowEngine) Breakers() *breaker.Set {
+	return e.breakers
+}
+
+// breaker returns the circuit breaker guarding a handler, or nil if it has none.
+func (e *FlowEngine) breaker(name string) *breaker.Breaker {
+	e.mu.RLock()
+	var cfg *config.BreakerConfig
+	if e.config != nil && e.config.Bot != nil {
+		cfg = e.config.Bot.CircuitBreaker
+	}
+	e.mu.RUnlock()
+	if !cfg.Guards(name) {
+		return nil
+	}
+	return e.breakers.Get(name, breaker.Settings{
+		Threshold: cfg.Threshold,
+		Cooldown:  cfg.Cooldown,
+		Probes:    cfg.Probes,
+	})
+}
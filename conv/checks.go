@@ -0,0 +1,113 @@
+package conv
+
+import (
+	"context"
+	"errors"
+
+	"github.com/0xVanfer/tg-listener/config"
+	"github.com/0xVanfer/tg-listener/core"
+)
+
+// FlowValidator checks the data of a whole flow before its final step
+// completes, e.g. that an end date follows a start date. An error sends the
+// user back to a step with the error as explanation; return StepError to
+// choose the step.
+type FlowValidator func(ctx context.Context, conv *Conversation) error
+
+// StepError is a flow validation error naming the step whose input is at fault.
+type StepError struct {
+	StepID string // Step the user is sent back to
+	Err    error  // Explanation shown to the user
+}
+
+// Error returns the explanation.
+func (e *StepError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the explanation.
+func (e *StepError) Unwrap() error {
+	return e.Err
+}
+
+// InvalidStep returns a StepError sending the user back to a step with a message.
+//
+// Example:
+//
+//	wrapper.RegisterFlowValidator("dateOrder", func(ctx context.Context, c *conv.Conversation) error {
+//		start, _ := c.Get("start_date")
+//		end, _ := c.Get("end_date")
+//		if !end.(time.Time).After(start.(time.Time)) {
+//			return conv.InvalidStep("enter_end", "The end date must be after the start date")
+//		}
+//		return nil
+//	})
+func InvalidStep(stepID, message string) error {
+	return &StepError{StepID: stepID, Err: errors.New(message)}
+}
+
+// RegisterFlowValidator registers a cross-field flow validator by name.
+// Flows reference it in a check's validator field.
+func (e *FlowEngine) RegisterFlowValidator(name string, validator FlowValidator) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.flowValidators[name] = validator
+}
+
+// GetFlowValidator retrieves a registered flow validator by name.
+func (e *FlowEngine) GetFlowValidator(name string) FlowValidator {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.flowValidators[name]
+}
+
+// CheckFlow checks that the required keys of the conversation's flow are
+// set, then runs its checks in order, and returns the first failure: the step
+// to send the user back to and the explanation. A missing key sends the user
+// back to the step storing it. Checks naming an unregistered validator are skipped.
+func (e *FlowEngine) CheckFlow(ctx context.Context, conv *Conversation) (string, error) {
+	flow := e.GetFlow(conv.FlowID)
+	if flow == nil {
+		return "", nil
+	}
+	if key, stepID := e.checkRequired(conv); key != "" {
+		if stepID == "" {
+			stepID = conv.StepID
+		}
+		return stepID, &StepError{StepID: stepID, Err: errors.New(core.StringsFrom(ctx).Required)}
+	}
+	for _, check := range flow.Checks {
+		err := e.runCheck(ctx, conv, check)
+		if err == nil {
+			continue
+		}
+		stepID := check.Step
+		var stepErr *StepError
+		if errors.As(err, &stepErr) && flow.GetStep(stepErr.StepID) != nil {
+			stepID = stepErr.StepID
+		}
+		if stepID == "" {
+			stepID = conv.StepID
+		}
+		if check.ErrorMsg != "" {
+			err = errors.New(e.RenderText(ctx, conv, check.ErrorMsg))
+		}
+		return stepID, err
+	}
+	return "", nil
+}
+
+// runCheck runs a single flow check.
+func (e *FlowEngine) runCheck(ctx context.Context, conv *Conversation, check config.FlowCheckConfig) error {
+	if check.Validator != "" {
+		validator := e.GetFlowValidator(check.Validator)
+		if validator == nil {
+			return nil
+		}
+		return validator(ctx, conv)
+	}
+	if check.Condition != "" && !e.EvaluateCondition(ctx, conv, check.Condition) {
+		return errors.New(check.ErrorMsg)
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+// Package conv provides the flow engine for executing conversation flows.
+package conv
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/0xVanfer/tg-listener/core"
+)
+
+// ComputeFunc is a function type for computing a conversation data value.
+// Called when a step with a computed entry referencing it completes.
+type ComputeFunc func(ctx context.Context, conv *Conversation) (interface{}, error)
+
+// templateFuncs are the functions available in prompt and computed templates.
+// Arithmetic functions accept numbers or numeric strings; non-numeric values count as 0.
+// sym and flag insert symbols from the core catalog, e.g. {{sym "status.ok"}} or {{flag .country}}.
+// raw prints a value as markup in texts with a parse mode, which escape values otherwise.
+var templateFuncs = template.FuncMap{
+	"add": func(a, b interface{}) float64 { return toNumber(a) + toNumber(b) },
+	"sub": func(a, b interface{}) float64 { return toNumber(a) - toNumber(b) },
+	"mul": func(a, b interface{}) float64 { return toNumber(a) * toNumber(b) },
+	"div": func(a, b interface{}) float64 {
+		d := toNumber(b)
+		if d == 0 {
+			return 0
+		}
+		return toNumber(a) / d
+	},
+	"round": func(places int, v interface{}) float64 {
+		p := math.Pow(10, float64(places))
+		return math.Round(toNumber(v)*p) / p
+	},
+	"sym":  core.Symbol,
+	"flag": core.Flag,
+	"raw":  func(v interface{}) interface{} { return v },
+}
+
+// toNumber converts a loosely typed value to a float64, returning 0 if it isn't numeric.
+func toNumber(v interface{}) float64 {
+	switch n := v.(type) {
+	case float64:
+		return n
+	case float32:
+		return float64(n)
+	case int:
+		return float64(n)
+	case int64:
+		return float64(n)
+	case int32:
+		return float64(n)
+	}
+	f, _ := strconv.ParseFloat(strings.TrimSpace(formatValue(v)), 64)
+	return f
+}
+
+// RegisterComputeFunc registers a compute function by name.
+// The function is called when a step's computed entry has a matching handler.
+func (e *FlowEngine) RegisterComputeFunc(name string, fn ComputeFunc) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.computeFuncs[name] = fn
+}
+
+// GetComputeFunc retrieves a compute function by name.
+func (e *FlowEngine) GetComputeFunc(name string) ComputeFunc {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.computeFuncs[name]
+}
+
+// ApplyComputed evaluates the current step's computed entries in order and stores
+// their results. Entries whose handler is not registered are skipped, and so
+// are entries whose handler isn't marked preview-safe in previews.
+// Returns the first handler error; entries after it are not evaluated.
+func (e *FlowEngine) ApplyComputed(ctx context.Context, conv *Conversation) error {
+	step := e.GetStep(conv.FlowID, conv.StepID)
+	if step == nil {
+		return nil
+	}
+
+	for _, entry := range step.Computed {
+		if entry.Key == "" {
+			continue
+		}
+
+		var value interface{}
+		if entry.Handler != "" {
+			fn := e.GetComputeFunc(entry.Handler)
+			if fn == nil || (conv.IsPreview() && !e.IsPreviewSafe(entry.Handler)) {
+				continue
+			}
+			v, err := fn(ctx, conv)
+			if err != nil {
+				return fmt.Errorf("computed %s: %w", entry.Key, err)
+			}
+			value = v
+		} else {
+			value = strings.TrimSpace(e.RenderText(ctx, conv, entry.Template))
+		}
+
+		if err := e.StoreInput(ctx, conv, entry.Key, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -4,8 +4,15 @@ package conv
 
 import (
 	"context"
+	"fmt"
+	"slices"
+	"strconv"
 	"sync"
 	"time"
+
+	"github.com/go-viper/mapstructure/v2"
+
+	"github.com/0xVanfer/tg-listener/store"
 )
 
 // ConversationState represents the current state of a conversation.
@@ -22,8 +29,29 @@ const (
 	StateCompleted
 	// StateCancelled indicates the conversation was cancelled by the user.
 	StateCancelled
+	// StateExpired indicates the conversation ended because its TTL elapsed.
+	StateExpired
 )
 
+// String returns the lowercase name of the state, e.g. "waiting".
+func (s ConversationState) String() string {
+	switch s {
+	case StateIdle:
+		return "idle"
+	case StateWaiting:
+		return "waiting"
+	case StateProcessing:
+		return "processing"
+	case StateCompleted:
+		return "completed"
+	case StateCancelled:
+		return "cancelled"
+	case StateExpired:
+		return "expired"
+	}
+	return "unknown"
+}
+
 // Conversation represents a conversation session with a user.
 // It tracks the current flow, step, collected data, and session metadata.
 type Conversation struct {
@@ -35,19 +63,43 @@ type Conversation struct {
 	State         ConversationState      // Current conversation state
 	Data          map[string]interface{} // Key-value storage for collected data
 	KeyboardMsgID int                    // Message ID of the last keyboard message (for editing)
+	KeyboardText  string                 // Text of the last keyboard message, kept to mark it expired
+	ReplyKeyboard bool                   // Whether a step's reply keyboard is shown in the chat
+	MessageIDs    []int                  // IDs of the bot messages the conversation produced, oldest first
+	ErrorMsgID    int                    // Message ID of the current validation error reply (0 if none)
+	ErrorText     string                 // Validation error rendered inline on the step prompt
+	InvalidInputs int                    // Consecutive invalid inputs on the current step
+	LastErrorAt   time.Time              // When the validation error display was last updated
+	StepDeadline  time.Time              // When the current step times out; zero if it doesn't
+	StepTimeoutID string                 // ID of the scheduled task timing out the current step; empty if none
 	CreatedAt     time.Time              // Timestamp when conversation was created
 	UpdatedAt     time.Time              // Timestamp of last update
 	ExpiresAt     time.Time              // Expiration timestamp for auto-cleanup
 	History       []HistoryEntry         // History of steps and inputs
+	Callers       []CallFrame            // Calling flows suspended by sub-flows, innermost last
+	Preview       bool                   // Whether the conversation is a sandboxed flow preview
 
-	mu sync.RWMutex // Mutex for thread-safe operations
+	version int64        // Number of times the conversation was persisted
+	mu      sync.RWMutex // Mutex for thread-safe operations
 }
 
 // HistoryEntry represents a single step in the conversation history.
 type HistoryEntry struct {
-	StepID    string    // ID of the step that was executed
-	Input     string    // User input received at this step
-	Timestamp time.Time // When this entry was recorded
+	StepID    string    `json:"step_id"`          // ID of the step that was executed
+	Input     string    `json:"input"`            // User input received at this step
+	Output    string    `json:"output,omitempty"` // Response shown for the input (LLM steps)
+	Timestamp time.Time `json:"timestamp"`        // When this entry was recorded
+}
+
+// MaxCallDepth is the maximum number of nested sub-flow calls in a conversation.
+const MaxCallDepth = 8
+
+// CallFrame is a calling flow suspended while a sub-flow runs.
+type CallFrame struct {
+	FlowID    string                 `json:"flow_id"`             // Flow of the calling step
+	StepID    string                 `json:"step_id"`             // Step that called the sub-flow
+	Namespace string                 `json:"namespace,omitempty"` // Key the sub-flow's data is returned under; empty if shared
+	Data      map[string]interface{} `json:"data,omitempty"`      // Caller's data while a namespaced sub-flow runs
 }
 
 // NewConversation creates a new conversation session.
@@ -93,6 +145,18 @@ func (c *Conversation) Get(key string) (interface{}, bool) {
 	return v, ok
 }
 
+// CopyData returns a shallow copy of the conversation data.
+// Safe to read and iterate without holding the conversation lock.
+func (c *Conversation) CopyData() map[string]interface{} {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	data := make(map[string]interface{}, len(c.Data))
+	for k, v := range c.Data {
+		data[k] = v
+	}
+	return data
+}
+
 // GetString retrieves a string value from the conversation data.
 // Returns empty string if key doesn't exist or value is not a string.
 func (c *Conversation) GetString(key string) string {
@@ -124,6 +188,99 @@ func (c *Conversation) GetInt(key string) int {
 	return 0
 }
 
+// GetFloat retrieves a numeric value from the conversation data as float64.
+// Handles float64, int, and int64 types. Returns 0 if not found or invalid type.
+func (c *Conversation) GetFloat(key string) float64 {
+	v, ok := c.Get(key)
+	if !ok {
+		return 0
+	}
+	switch n := v.(type) {
+	case float64:
+		return n
+	case int:
+		return float64(n)
+	case int64:
+		return float64(n)
+	}
+	return 0
+}
+
+// GetBool retrieves a boolean value from the conversation data.
+// Returns false if not found or invalid type.
+func (c *Conversation) GetBool(key string) bool {
+	v, ok := c.Get(key)
+	if !ok {
+		return false
+	}
+	b, _ := v.(bool)
+	return b
+}
+
+// GetTime retrieves a time value from the conversation data.
+// Handles time.Time and RFC 3339 strings, as restored from persisted
+// conversations. Returns the zero time if not found or invalid type.
+func (c *Conversation) GetTime(key string) time.Time {
+	v, ok := c.Get(key)
+	if !ok {
+		return time.Time{}
+	}
+	switch t := v.(type) {
+	case time.Time:
+		return t
+	case string:
+		parsed, _ := time.Parse(time.RFC3339, t)
+		return parsed
+	}
+	return time.Time{}
+}
+
+// GetStringSlice retrieves a list of strings from the conversation data.
+// Handles []string and []interface{}, as restored from persisted conversations,
+// formatting elements that aren't strings. Returns nil if not found or invalid type.
+func (c *Conversation) GetStringSlice(key string) []string {
+	v, ok := c.Get(key)
+	if !ok {
+		return nil
+	}
+	switch l := v.(type) {
+	case []string:
+		return slices.Clone(l)
+	case []interface{}:
+		out := make([]string, len(l))
+		for i, e := range l {
+			if s, ok := e.(string); ok {
+				out[i] = s
+			} else {
+				out[i] = fmt.Sprint(e)
+			}
+		}
+		return out
+	}
+	return nil
+}
+
+// Bind copies the conversation data into the struct out points to, typically
+// in a flow's OnComplete handler. Fields are matched to keys by their
+// mapstructure tag, or case-insensitively by name. Values are converted
+// loosely: text input such as "42" fills numeric fields, RFC 3339 strings
+// fill time.Time fields, and duration strings such as "90m" fill
+// time.Duration fields. Keys without a field are ignored.
+func (c *Conversation) Bind(out interface{}) error {
+	decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		DecodeHook: mapstructure.ComposeDecodeHookFunc(
+			mapstructure.StringToTimeHookFunc(time.RFC3339),
+			mapstructure.StringToTimeDurationHookFunc(),
+		),
+		WeaklyTypedInput: true,
+		Result:           out,
+	})
+	if err != nil {
+		return err
+	}
+	return decoder.Decode(c.CopyData())
+}
+
 // SetStep updates the current step ID.
 // Thread-safe and automatically updates the UpdatedAt timestamp.
 func (c *Conversation) SetStep(stepID string) {
@@ -133,12 +290,191 @@ func (c *Conversation) SetStep(stepID string) {
 	c.UpdatedAt = time.Now()
 }
 
+// SetStepDeadline sets when the current step times out; zero clears it.
+func (c *Conversation) SetStepDeadline(at time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.StepDeadline = at
+}
+
+// GetStepDeadline returns when the current step times out, zero if it doesn't.
+func (c *Conversation) GetStepDeadline() time.Time {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.StepDeadline
+}
+
+// SetStepTimeoutID sets the ID of the scheduled task timing out the current
+// step; empty clears it.
+func (c *Conversation) SetStepTimeoutID(id string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.StepTimeoutID = id
+}
+
+// GetStepTimeoutID returns the ID of the scheduled task timing out the
+// current step, empty if none is armed.
+func (c *Conversation) GetStepTimeoutID() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.StepTimeoutID
+}
+
+// MaxTrackedMessages is the maximum number of message IDs tracked per
+// conversation; the oldest are forgotten first.
+const MaxTrackedMessages = 100
+
 // SetKeyboardMsgID sets the message ID of the keyboard message.
 // Used for editing the keyboard message instead of sending new ones.
+// The message is tracked as produced by the conversation.
 func (c *Conversation) SetKeyboardMsgID(msgID int) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 	c.KeyboardMsgID = msgID
+	c.trackMessage(msgID)
+}
+
+// SetKeyboardText records the text last shown in the keyboard message.
+func (c *Conversation) SetKeyboardText(text string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.KeyboardText = text
+}
+
+// GetKeyboardText returns the text last shown in the keyboard message.
+func (c *Conversation) GetKeyboardText() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.KeyboardText
+}
+
+// SetReplyKeyboard records whether a step's reply keyboard is shown in the chat.
+func (c *Conversation) SetReplyKeyboard(shown bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.ReplyKeyboard = shown
+}
+
+// HasReplyKeyboard returns true if a step's reply keyboard is shown in the chat.
+func (c *Conversation) HasReplyKeyboard() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.ReplyKeyboard
+}
+
+// TrackMessage records a bot message produced by the conversation, e.g. one
+// sent by a step handler, so it is cleaned up with the conversation.
+func (c *Conversation) TrackMessage(msgID int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.trackMessage(msgID)
+}
+
+// trackMessage records a message ID once. Callers must hold c.mu.
+func (c *Conversation) trackMessage(msgID int) {
+	if msgID <= 0 || slices.Contains(c.MessageIDs, msgID) {
+		return
+	}
+	c.MessageIDs = append(c.MessageIDs, msgID)
+	if len(c.MessageIDs) > MaxTrackedMessages {
+		c.MessageIDs = slices.Delete(c.MessageIDs, 0, len(c.MessageIDs)-MaxTrackedMessages)
+	}
+}
+
+// UntrackMessage forgets a message, e.g. after it was deleted.
+func (c *Conversation) UntrackMessage(msgID int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.MessageIDs = slices.DeleteFunc(c.MessageIDs, func(id int) bool { return id == msgID })
+}
+
+// OwnsMessage returns true if the conversation produced the message.
+func (c *Conversation) OwnsMessage(msgID int) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return slices.Contains(c.MessageIDs, msgID)
+}
+
+// IntermediateMessages returns the messages produced by the conversation
+// other than the current keyboard message, oldest first.
+func (c *Conversation) IntermediateMessages() []int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	ids := make([]int, 0, len(c.MessageIDs))
+	for _, id := range c.MessageIDs {
+		if id != c.KeyboardMsgID {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// RecordInvalidInput counts an invalid input on the current step and returns the
+// number of consecutive invalid inputs. wait is how long until the error display
+// may be updated again if it was updated less than throttle ago, so bursts of bad
+// input collapse into one update; it is zero if the display is updated now.
+func (c *Conversation) RecordInvalidInput(throttle time.Duration) (count int, wait time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.InvalidInputs++
+	now := time.Now()
+	if !c.LastErrorAt.IsZero() && now.Sub(c.LastErrorAt) < throttle {
+		return c.InvalidInputs, throttle - now.Sub(c.LastErrorAt)
+	}
+	c.LastErrorAt = now
+	return c.InvalidInputs, 0
+}
+
+// RefreshInvalidInput marks the error display as updated now and returns the
+// number of consecutive invalid inputs, for showing an error held back by the throttle.
+func (c *Conversation) RefreshInvalidInput() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.LastErrorAt = time.Now()
+	return c.InvalidInputs
+}
+
+// GetInvalidInputs returns the number of consecutive invalid inputs on the current step.
+func (c *Conversation) GetInvalidInputs() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.InvalidInputs
+}
+
+// SetErrorMsgID sets the message ID of the validation error reply.
+func (c *Conversation) SetErrorMsgID(msgID int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.ErrorMsgID = msgID
+	c.trackMessage(msgID)
+}
+
+// SetErrorText sets the validation error rendered inline on the step prompt.
+func (c *Conversation) SetErrorText(text string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.ErrorText = text
+}
+
+// GetErrorText returns the validation error rendered inline on the step prompt.
+func (c *Conversation) GetErrorText() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.ErrorText
+}
+
+// ClearInvalidInput resets the invalid input state after valid input.
+// Returns the ID of the validation error message to remove (0 if none).
+func (c *Conversation) ClearInvalidInput() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	msgID := c.ErrorMsgID
+	c.MessageIDs = slices.DeleteFunc(c.MessageIDs, func(id int) bool { return id == msgID })
+	c.ErrorMsgID = 0
+	c.ErrorText = ""
+	c.InvalidInputs = 0
+	c.LastErrorAt = time.Time{}
+	return msgID
 }
 
 // AddHistory adds a new entry to the conversation history.
@@ -153,6 +489,46 @@ func (c *Conversation) AddHistory(stepID, input string) {
 	})
 }
 
+// LastInput returns the input of the most recent history entry.
+// Branch and completion handlers use it to read the input that completed the step.
+// Returns empty string if there is no history.
+func (c *Conversation) LastInput() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if len(c.History) == 0 {
+		return ""
+	}
+	return c.History[len(c.History)-1].Input
+}
+
+// SetLastOutput records the response shown for the most recent history entry.
+func (c *Conversation) SetLastOutput(output string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.History) > 0 {
+		c.History[len(c.History)-1].Output = output
+	}
+}
+
+// StepTranscript returns the trailing history entries recorded at the given step,
+// i.e. the inputs of the current visit to it, oldest first.
+func (c *Conversation) StepTranscript(stepID string) []HistoryEntry {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	i := len(c.History)
+	for i > 0 && c.History[i-1].StepID == stepID {
+		i--
+	}
+	return append([]HistoryEntry(nil), c.History[i:]...)
+}
+
+// Transcript returns a copy of the whole history, oldest first.
+func (c *Conversation) Transcript() []HistoryEntry {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return append([]HistoryEntry(nil), c.History...)
+}
+
 // GetPreviousStep returns the step ID before the current one.
 // Useful for implementing back navigation. Returns empty string if no previous step.
 func (c *Conversation) GetPreviousStep() string {
@@ -164,6 +540,79 @@ func (c *Conversation) GetPreviousStep() string {
 	return ""
 }
 
+// CallFlow suspends the current flow and continues at a step of a sub-flow.
+// With a namespace, the sub-flow gets its own data starting with params;
+// otherwise params are added to the shared data.
+func (c *Conversation) CallFlow(frame CallFrame, flowID, stepID string, params map[string]interface{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if frame.Namespace != "" {
+		frame.Data = c.Data
+		c.Data = make(map[string]interface{}, len(params))
+	}
+	for k, v := range params {
+		c.Data[k] = v
+	}
+	c.Callers = append(c.Callers, frame)
+	c.FlowID = flowID
+	c.StepID = stepID
+	c.UpdatedAt = time.Now()
+}
+
+// ReturnFromFlow resumes the innermost calling flow at the step that called
+// the sub-flow. With keep, a namespaced sub-flow's data is stored under its
+// namespace; otherwise it is discarded. Returns false if no sub-flow is running.
+func (c *Conversation) ReturnFromFlow(keep bool) (CallFrame, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.Callers) == 0 {
+		return CallFrame{}, false
+	}
+	frame := c.Callers[len(c.Callers)-1]
+	c.Callers = c.Callers[:len(c.Callers)-1]
+	if frame.Namespace != "" {
+		sub := c.Data
+		c.Data = frame.Data
+		if c.Data == nil {
+			c.Data = make(map[string]interface{})
+		}
+		if keep {
+			c.Data[frame.Namespace] = sub
+		}
+		frame.Data = nil
+	}
+	c.FlowID = frame.FlowID
+	c.StepID = frame.StepID
+	c.UpdatedAt = time.Now()
+	return frame, true
+}
+
+// RootFlowID returns the flow the conversation was started with, which is
+// the current flow unless a sub-flow is running.
+func (c *Conversation) RootFlowID() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if len(c.Callers) > 0 {
+		return c.Callers[0].FlowID
+	}
+	return c.FlowID
+}
+
+// CallDepth returns the number of sub-flows currently running.
+func (c *Conversation) CallDepth() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return len(c.Callers)
+}
+
+// IsPreview returns true if the conversation is a sandboxed flow preview,
+// started with Manager.StartPreview.
+func (c *Conversation) IsPreview() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.Preview
+}
+
 // IsExpired checks if the conversation has expired.
 // Returns true if current time is after the expiration time.
 func (c *Conversation) IsExpired() bool {
@@ -181,6 +630,13 @@ func (c *Conversation) Refresh(ttl time.Duration) {
 	c.UpdatedAt = time.Now()
 }
 
+// GetState returns the current conversation state.
+func (c *Conversation) GetState() ConversationState {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.State
+}
+
 // Complete marks the conversation as successfully completed.
 func (c *Conversation) Complete() {
 	c.mu.Lock()
@@ -197,10 +653,22 @@ func (c *Conversation) Cancel() {
 	c.UpdatedAt = time.Now()
 }
 
+// Expire marks the conversation as ended by its TTL or a step timeout.
+func (c *Conversation) Expire() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.State = StateExpired
+	c.UpdatedAt = time.Now()
+}
+
 // conversationKey generates a unique key for the conversation.
-// Uses combination of user ID and chat ID for uniqueness.
-func conversationKey(userID, chatID int64) string {
-	return string(rune(userID)) + ":" + string(rune(chatID))
+// Uses combination of user ID and chat ID, plus the topic ID if it is not 0.
+func conversationKey(userID, chatID int64, topicID int) string {
+	key := strconv.FormatInt(userID, 10) + ":" + strconv.FormatInt(chatID, 10)
+	if topicID != 0 {
+		key += ":" + strconv.Itoa(topicID)
+	}
+	return key
 }
 
 // Manager handles conversation lifecycle and provides
@@ -208,6 +676,8 @@ func conversationKey(userID, chatID int64) string {
 type Manager struct {
 	conversations map[string]*Conversation // Active conversations indexed by key
 	defaultTTL    time.Duration            // Default time-to-live for new conversations
+	store         store.Store              // Optional persistence for conversations
+	topicScoped   bool                     // Whether each forum topic has its own conversation
 	mu            sync.RWMutex             // Mutex for thread-safe operations
 
 	// Lifecycle callback functions
@@ -228,6 +698,31 @@ func NewManager(defaultTTL time.Duration) *Manager {
 	}
 }
 
+// SetTopicScoped scopes conversations per topic, so a user can run separate
+// flows in different forum topics of the same group. By default a user has one
+// conversation per chat, and topic IDs passed to the manager are ignored.
+func (m *Manager) SetTopicScoped(scoped bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.topicScoped = scoped
+}
+
+// scopeTopic returns the topic ID that identifies a conversation:
+// the given topic if conversations are scoped per topic, otherwise 0.
+func (m *Manager) scopeTopic(topicID int) int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if !m.topicScoped {
+		return 0
+	}
+	return topicID
+}
+
+// keyOf returns the key of a conversation.
+func (m *Manager) keyOf(c *Conversation) string {
+	return conversationKey(c.UserID, c.ChatID, m.scopeTopic(c.TopicID))
+}
+
 // SetOnStart sets the callback function for when a conversation starts.
 func (m *Manager) SetOnStart(fn func(ctx context.Context, c *Conversation)) {
 	m.onStart = fn
@@ -244,7 +739,8 @@ func (m *Manager) SetOnStepChange(fn func(ctx context.Context, c *Conversation,
 }
 
 // Start begins a new conversation for a user in a chat.
-// If a conversation already exists for this user/chat, it will be ended first.
+// If a conversation already exists for this user/chat (and topic, if
+// conversations are scoped per topic), it will be ended first.
 // Parameters:
 //   - ctx: Context for cancellation and callbacks
 //   - userID: Telegram user ID
@@ -254,104 +750,197 @@ func (m *Manager) SetOnStepChange(fn func(ctx context.Context, c *Conversation,
 //   - initialStep: Starting step ID
 //   - ttl: Time-to-live (uses default if <= 0)
 func (m *Manager) Start(ctx context.Context, userID, chatID int64, topicID int, flowID, initialStep string, ttl time.Duration) (*Conversation, error) {
+	return m.start(ctx, userID, chatID, topicID, flowID, initialStep, ttl, false)
+}
+
+// StartPreview begins a preview conversation, which runs a flow as a sandbox:
+// step handlers not marked safe with FlowEngine.MarkPreviewSafe are skipped,
+// and the onStart callback isn't called. Like Start, it supersedes the
+// user's current conversation.
+func (m *Manager) StartPreview(ctx context.Context, userID, chatID int64, topicID int, flowID, initialStep string, ttl time.Duration) (*Conversation, error) {
+	return m.start(ctx, userID, chatID, topicID, flowID, initialStep, ttl, true)
+}
+
+// start begins a new conversation, or a preview conversation if preview is set.
+func (m *Manager) start(ctx context.Context, userID, chatID int64, topicID int, flowID, initialStep string, ttl time.Duration, preview bool) (*Conversation, error) {
 	if ttl <= 0 {
 		ttl = m.defaultTTL
 	}
 
-	key := conversationKey(userID, chatID)
+	scope := m.scopeTopic(topicID)
+	key := conversationKey(userID, chatID, scope)
+
+	// Pick up a conversation persisted by another instance, so it is superseded too
+	if st := m.getStore(); st != nil {
+		m.mu.RLock()
+		cached := m.conversations[key]
+		m.mu.RUnlock()
+		m.sync(ctx, st, key, cached, userID, chatID, scope)
+	}
 
 	m.mu.Lock()
-	// End existing conversation if present
+	// End existing conversation if present; it was superseded, not completed
 	if existing, ok := m.conversations[key]; ok {
+		existing.Cancel()
 		if m.onEnd != nil {
 			m.onEnd(ctx, existing)
 		}
 	}
 
 	conv := NewConversation(userID, chatID, topicID, flowID, initialStep, ttl)
+	conv.Preview = preview
 	m.conversations[key] = conv
 	m.mu.Unlock()
 
-	if m.onStart != nil {
+	if err := m.Save(ctx, conv); err != nil {
+		return nil, err
+	}
+
+	if m.onStart != nil && !preview {
 		m.onStart(ctx, conv)
 	}
 
 	return conv, nil
 }
 
-// Get retrieves an active conversation for a user/chat.
+// Get retrieves an active conversation for a user/chat outside forum topics.
 // Returns nil if no conversation exists or if it has expired.
+// See GetIn for conversations scoped per topic.
 func (m *Manager) Get(userID, chatID int64) *Conversation {
-	key := conversationKey(userID, chatID)
+	return m.GetIn(userID, chatID, 0)
+}
+
+// GetIn retrieves an active conversation for a user/chat in a topic.
+// The topic is ignored unless conversations are scoped per topic.
+// Returns nil if no conversation exists or if it has expired.
+// With a store set, conversations persisted before a restart or by another
+// instance are resumed.
+func (m *Manager) GetIn(userID, chatID int64, topicID int) *Conversation {
+	scope := m.scopeTopic(topicID)
+	key := conversationKey(userID, chatID, scope)
 
 	m.mu.RLock()
-	conv, ok := m.conversations[key]
+	conv := m.conversations[key]
+	st := m.store
 	m.mu.RUnlock()
 
-	if !ok {
+	if st != nil {
+		conv = m.sync(context.Background(), st, key, conv, userID, chatID, scope)
+	}
+	if conv == nil {
 		return nil
 	}
 
 	// Auto-cleanup expired conversations
 	if conv.IsExpired() {
-		m.End(context.Background(), userID, chatID)
+		conv.Expire()
+		m.EndIn(context.Background(), userID, chatID, topicID)
 		return nil
 	}
 
 	return conv
 }
 
-// End terminates a conversation and removes it from the manager.
-// Triggers the onEnd callback if set.
+// Cached returns true if a conversation for a user/chat in a topic is cached
+// and not expired. Unlike GetIn it never reads the store, so it is cheap
+// enough to call for every update, but misses conversations not yet resumed.
+func (m *Manager) Cached(userID, chatID int64, topicID int) bool {
+	key := conversationKey(userID, chatID, m.scopeTopic(topicID))
+	m.mu.RLock()
+	conv := m.conversations[key]
+	m.mu.RUnlock()
+	return conv != nil && !conv.IsExpired()
+}
+
+// End terminates a conversation outside forum topics and removes it from the manager.
+// See EndIn for conversations scoped per topic.
 func (m *Manager) End(ctx context.Context, userID, chatID int64) {
-	key := conversationKey(userID, chatID)
+	m.EndIn(ctx, userID, chatID, 0)
+}
+
+// EndIn terminates a conversation in a topic and removes it from the manager.
+// The topic is ignored unless conversations are scoped per topic.
+// The conversation is marked completed unless it was cancelled or expired.
+// Triggers the onEnd callback if set.
+func (m *Manager) EndIn(ctx context.Context, userID, chatID int64, topicID int) {
+	scope := m.scopeTopic(topicID)
+	key := conversationKey(userID, chatID, scope)
 
 	m.mu.Lock()
 	conv, ok := m.conversations[key]
 	if ok {
 		delete(m.conversations, key)
 	}
+	st := m.store
 	m.mu.Unlock()
 
+	if st != nil {
+		_ = st.Delete(ctx, storeKey(userID, chatID, scope))
+	}
+
+	if ok {
+		if state := conv.GetState(); state != StateCancelled && state != StateExpired {
+			conv.Complete()
+		}
+	}
+
 	if ok && m.onEnd != nil {
 		m.onEnd(ctx, conv)
 	}
 }
 
-// ChangeStep changes the current step of a conversation.
-// Triggers the onStepChange callback with old and new step IDs.
+// ChangeStep changes the current step of a conversation outside forum topics.
+// See ChangeStepIn for conversations scoped per topic.
 func (m *Manager) ChangeStep(ctx context.Context, userID, chatID int64, newStep string) {
-	conv := m.Get(userID, chatID)
+	m.ChangeStepIn(ctx, userID, chatID, 0, newStep)
+}
+
+// ChangeStepIn changes the current step of a conversation in a topic.
+// The topic is ignored unless conversations are scoped per topic.
+// Triggers the onStepChange callback with old and new step IDs.
+func (m *Manager) ChangeStepIn(ctx context.Context, userID, chatID int64, topicID int, newStep string) {
+	conv := m.GetIn(userID, chatID, topicID)
 	if conv == nil {
 		return
 	}
 
 	oldStep := conv.StepID
 	conv.SetStep(newStep)
+	_ = m.Save(ctx, conv)
 
 	if m.onStepChange != nil {
 		m.onStepChange(ctx, conv, oldStep, newStep)
 	}
 }
 
-// Cleanup removes all expired conversations.
+// Cleanup removes all expired conversations and marks them expired.
 // Returns the number of conversations that were cleaned up.
 func (m *Manager) Cleanup(ctx context.Context) int {
 	m.mu.Lock()
-	defer m.mu.Unlock()
-
-	count := 0
+	st := m.store
+	var expired []*Conversation
 	for key, conv := range m.conversations {
 		if conv.IsExpired() {
-			if m.onEnd != nil {
-				m.onEnd(ctx, conv)
-			}
+			expired = append(expired, conv)
 			delete(m.conversations, key)
-			count++
 		}
 	}
+	m.mu.Unlock()
+
+	if st != nil {
+		for _, conv := range expired {
+			_ = st.Delete(ctx, storeKey(conv.UserID, conv.ChatID, m.scopeTopic(conv.TopicID)))
+		}
+		expired = append(expired, m.cleanupStored(ctx, st)...)
+	}
 
-	return count
+	for _, conv := range expired {
+		conv.Expire()
+		if m.onEnd != nil {
+			m.onEnd(ctx, conv)
+		}
+	}
+	return len(expired)
 }
 
 // StartCleanupTask starts a background goroutine that periodically cleans up expired conversations.
@@ -382,3 +971,16 @@ func (m *Manager) Count() int {
 	defer m.mu.RUnlock()
 	return len(m.conversations)
 }
+
+// CountByFlow returns the number of active conversations per flow ID.
+func (m *Manager) CountByFlow() map[string]int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	counts := make(map[string]int)
+	for _, conv := range m.conversations {
+		conv.mu.RLock()
+		counts[conv.FlowID]++
+		conv.mu.RUnlock()
+	}
+	return counts
+}
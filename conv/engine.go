@@ -4,14 +4,26 @@ package conv
 import (
 	"context"
 	"errors"
+	"fmt"
 	"regexp"
 	"strconv"
 	"strings"
 	"sync"
+	"text/template"
+	"time"
 
+	"github.com/0xVanfer/tg-listener/breaker"
 	"github.com/0xVanfer/tg-listener/config"
+	"github.com/0xVanfer/tg-listener/core"
+	"github.com/0xVanfer/tg-listener/latency"
+	"github.com/0xVanfer/tg-listener/store"
 )
 
+// ChatSettingPrefix marks condition references, template keys, and StoreAs targets
+// that address persistent chat settings instead of conversation data.
+// For example, "chat.notifications" refers to the chat's "notifications" setting.
+const ChatSettingPrefix = "chat."
+
 // StepHandler is a function type for handling step completion.
 // Called when a step is completed to perform custom business logic.
 type StepHandler func(ctx context.Context, conv *Conversation) error
@@ -20,6 +32,17 @@ type StepHandler func(ctx context.Context, conv *Conversation) error
 // Called when a step needs dynamically generated buttons.
 type KeyboardProvider func(ctx context.Context, conv *Conversation) []config.ButtonData
 
+// FallibleKeyboardProvider is a keyboard provider that can report failure,
+// e.g. when its upstream API is down. On error the step shows fallback buttons.
+type FallibleKeyboardProvider func(ctx context.Context, conv *Conversation) ([]config.ButtonData, error)
+
+// ProviderErrorFunc is called when a keyboard provider fails: it returned an
+// error, panicked, timed out, or was skipped by its open circuit breaker.
+type ProviderErrorFunc func(ctx context.Context, conv *Conversation, provider string, err error)
+
+// ErrStepNotFound is returned when a flow or step referenced by ID does not exist.
+var ErrStepNotFound = errors.New("flow step not found")
+
 // Validator is a function type for custom input validation.
 // Called to validate user input with custom rules.
 type Validator func(value string, conv *Conversation) error
@@ -28,14 +51,42 @@ type Validator func(value string, conv *Conversation) error
 // Used for evaluating complex conditions in flow branching.
 type ConditionEvaluator func(ctx context.Context, conv *Conversation, condition string) bool
 
+// ValueNamespace resolves the values of a named reference namespace.
+// Registered namespaces are addressable in conditions as "<name>.key"
+// and in templates as {{.<name>.key}}.
+type ValueNamespace func(ctx context.Context, conv *Conversation) map[string]interface{}
+
+// ChatSettingsStore provides access to persistent chat-level settings.
+// The wrapper implements it on top of its pluggable store so that
+// conditions, templates, and StoreAs can address "chat.*" values.
+type ChatSettingsStore interface {
+	// ChatSettings returns all settings for a chat.
+	ChatSettings(ctx context.Context, chatID int64) map[string]interface{}
+
+	// SetChatSetting stores a single setting for a chat.
+	SetChatSetting(ctx context.Context, chatID int64, name string, value interface{}) error
+}
+
 // FlowEngine manages flow execution, step handlers, and validation.
 // It provides the core logic for multi-step conversation flows.
 type FlowEngine struct {
-	config             *config.Config              // Configuration containing flow definitions
-	stepHandlers       map[string]StepHandler      // Registered step completion handlers
-	keyboardProviders  map[string]KeyboardProvider // Registered dynamic keyboard providers
-	validators         map[string]Validator        // Registered custom validators
-	conditionEvaluator ConditionEvaluator          // Custom condition evaluator
+	config             *config.Config                      // Configuration containing flow definitions
+	stepHandlers       map[string]StepHandler              // Registered step completion handlers
+	keyboardProviders  map[string]FallibleKeyboardProvider // Registered dynamic keyboard providers
+	validators         map[string]Validator                // Registered custom validators
+	flowValidators     map[string]FlowValidator            // Registered cross-field flow validators
+	conditionEvaluator ConditionEvaluator                  // Custom condition evaluator
+	chatSettings       ChatSettingsStore                   // Persistent chat settings (optional)
+	namespaces         map[string]ValueNamespace           // Additional reference namespaces by name
+	transforms         map[string]Transform                // Input transforms by name, including built-ins
+	computeFuncs       map[string]ComputeFunc              // Registered compute functions for computed fields
+	completers         map[string]Completer                // Registered completers for LLM steps
+	latencyObserver    latency.Observer                    // Receives handler call durations
+	keyboardCache      map[string][]config.ButtonData      // Latest keyboard provider results for timeout fallback
+	breakers           *breaker.Set                        // Circuit breakers of guarded handlers
+	onProviderError    ProviderErrorFunc                   // Called when a keyboard provider fails
+	previewSafe        map[string]bool                     // Step handlers that also run in flow previews
+	patterns           map[string]*regexp.Regexp           // Compiled branch and validation regexes; nil for invalid ones
 
 	mu sync.RWMutex // Mutex for thread-safe operations
 }
@@ -45,8 +96,17 @@ func NewFlowEngine(cfg *config.Config) *FlowEngine {
 	return &FlowEngine{
 		config:            cfg,
 		stepHandlers:      make(map[string]StepHandler),
-		keyboardProviders: make(map[string]KeyboardProvider),
+		keyboardProviders: make(map[string]FallibleKeyboardProvider),
 		validators:        make(map[string]Validator),
+		flowValidators:    make(map[string]FlowValidator),
+		previewSafe:       make(map[string]bool),
+		namespaces:        make(map[string]ValueNamespace),
+		transforms:        builtinTransforms(),
+		computeFuncs:      make(map[string]ComputeFunc),
+		completers:        make(map[string]Completer),
+		keyboardCache:     make(map[string][]config.ButtonData),
+		patterns:          make(map[string]*regexp.Regexp),
+		breakers:          breaker.NewSet(),
 	}
 }
 
@@ -55,6 +115,41 @@ func (e *FlowEngine) SetConfig(cfg *config.Config) {
 	e.mu.Lock()
 	defer e.mu.Unlock()
 	e.config = cfg
+	clear(e.patterns)
+}
+
+// compile returns the compiled regex of a pattern from the configuration,
+// compiling each pattern once. Returns nil if the pattern is invalid.
+func (e *FlowEngine) compile(pattern string) *regexp.Regexp {
+	e.mu.RLock()
+	re, ok := e.patterns[pattern]
+	e.mu.RUnlock()
+	if ok {
+		return re
+	}
+	re, _ = regexp.Compile(pattern)
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.patterns[pattern] = re
+	return re
+}
+
+// SetLatencyObserver sets the observer of step handler, keyboard provider,
+// and completer call durations. Pass nil to stop observing.
+func (e *FlowEngine) SetLatencyObserver(observer latency.Observer) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.latencyObserver = observer
+}
+
+// observeLatency reports the duration of a handler call started at start.
+func (e *FlowEngine) observeLatency(ctx context.Context, name string, start time.Time) {
+	e.mu.RLock()
+	observer := e.latencyObserver
+	e.mu.RUnlock()
+	if observer != nil {
+		observer(ctx, name, time.Since(start))
+	}
 }
 
 // RegisterStepHandler registers a step completion handler by name.
@@ -68,11 +163,26 @@ func (e *FlowEngine) RegisterStepHandler(name string, handler StepHandler) {
 // RegisterKeyboardProvider registers a dynamic keyboard data provider.
 // The provider will be called when a step's keyboard has type "dynamic" and matching Provider field.
 func (e *FlowEngine) RegisterKeyboardProvider(name string, provider KeyboardProvider) {
+	e.RegisterFallibleKeyboardProvider(name, func(ctx context.Context, conv *Conversation) ([]config.ButtonData, error) {
+		return provider(ctx, conv), nil
+	})
+}
+
+// RegisterFallibleKeyboardProvider registers a dynamic keyboard data provider
+// that can fail. When it returns an error, the step shows fallback buttons.
+func (e *FlowEngine) RegisterFallibleKeyboardProvider(name string, provider FallibleKeyboardProvider) {
 	e.mu.Lock()
 	defer e.mu.Unlock()
 	e.keyboardProviders[name] = provider
 }
 
+// SetProviderErrorHandler sets the function called when a keyboard provider fails.
+func (e *FlowEngine) SetProviderErrorHandler(fn ProviderErrorFunc) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.onProviderError = fn
+}
+
 // RegisterValidator registers a custom validator by name.
 // The validator will be called when validation type is "custom" with matching Custom field.
 func (e *FlowEngine) RegisterValidator(name string, validator Validator) {
@@ -89,6 +199,67 @@ func (e *FlowEngine) SetConditionEvaluator(evaluator ConditionEvaluator) {
 	e.conditionEvaluator = evaluator
 }
 
+// SetChatSettingsStore sets the store used to resolve "chat.*" references.
+func (e *FlowEngine) SetChatSettingsStore(s ChatSettingsStore) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.chatSettings = s
+}
+
+// RegisterNamespace registers an additional reference namespace by name.
+// For example, a "flag" namespace makes "flag.beta" usable in conditions.
+// The names "data", "chat", and "env" are reserved.
+func (e *FlowEngine) RegisterNamespace(name string, ns ValueNamespace) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.namespaces[name] = ns
+}
+
+// getNamespace returns a registered namespace by name.
+func (e *FlowEngine) getNamespace(name string) ValueNamespace {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.namespaces[name]
+}
+
+// getConfig returns the current configuration.
+func (e *FlowEngine) getConfig() *config.Config {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.config
+}
+
+// getChatSettings returns all settings for a chat, or nil if no store is set.
+func (e *FlowEngine) getChatSettings(ctx context.Context, chatID int64) map[string]interface{} {
+	e.mu.RLock()
+	s := e.chatSettings
+	e.mu.RUnlock()
+	if s == nil {
+		return nil
+	}
+	return s.ChatSettings(ctx, chatID)
+}
+
+// conversationChatSettings returns the settings of a conversation's chat. In
+// previews, "chat.*" values the flow stored are kept in the conversation data
+// and override the chat's settings.
+func (e *FlowEngine) conversationChatSettings(ctx context.Context, conv *Conversation) map[string]interface{} {
+	settings := e.getChatSettings(ctx, conv.ChatID)
+	if !conv.IsPreview() {
+		return settings
+	}
+	merged := make(map[string]interface{}, len(settings))
+	for k, v := range settings {
+		merged[k] = v
+	}
+	for k, v := range conv.CopyData() {
+		if name, ok := strings.CutPrefix(k, ChatSettingPrefix); ok {
+			merged[name] = v
+		}
+	}
+	return merged
+}
+
 // GetStepHandler retrieves a registered step handler by name.
 func (e *FlowEngine) GetStepHandler(name string) StepHandler {
 	e.mu.RLock()
@@ -97,7 +268,20 @@ func (e *FlowEngine) GetStepHandler(name string) StepHandler {
 }
 
 // GetKeyboardProvider retrieves a registered keyboard provider by name.
+// Errors of fallible providers are dropped; use GetDynamicKeyboardData for fallbacks.
 func (e *FlowEngine) GetKeyboardProvider(name string) KeyboardProvider {
+	provider := e.getFallibleKeyboardProvider(name)
+	if provider == nil {
+		return nil
+	}
+	return func(ctx context.Context, conv *Conversation) []config.ButtonData {
+		data, _ := provider(ctx, conv)
+		return data
+	}
+}
+
+// getFallibleKeyboardProvider retrieves a registered keyboard provider by name.
+func (e *FlowEngine) getFallibleKeyboardProvider(name string) FallibleKeyboardProvider {
 	e.mu.RLock()
 	defer e.mu.RUnlock()
 	return e.keyboardProviders[name]
@@ -110,6 +294,31 @@ func (e *FlowEngine) GetValidator(name string) Validator {
 	return e.validators[name]
 }
 
+// HasStepHandler returns true if a step handler is registered under the name.
+func (e *FlowEngine) HasStepHandler(name string) bool {
+	return e.GetStepHandler(name) != nil
+}
+
+// HasKeyboardProvider returns true if a keyboard provider is registered under the name.
+func (e *FlowEngine) HasKeyboardProvider(name string) bool {
+	return e.getFallibleKeyboardProvider(name) != nil
+}
+
+// HasValidator returns true if a validator is registered under the name.
+func (e *FlowEngine) HasValidator(name string) bool {
+	return e.GetValidator(name) != nil
+}
+
+// HasFlowValidator returns true if a flow validator is registered under the name.
+func (e *FlowEngine) HasFlowValidator(name string) bool {
+	return e.GetFlowValidator(name) != nil
+}
+
+// HasComputeFunc returns true if a compute function is registered under the name.
+func (e *FlowEngine) HasComputeFunc(name string) bool {
+	return e.GetComputeFunc(name) != nil
+}
+
 // GetFlow retrieves a flow configuration by ID.
 func (e *FlowEngine) GetFlow(flowID string) *config.FlowConfig {
 	e.mu.RLock()
@@ -129,29 +338,95 @@ func (e *FlowEngine) GetStep(flowID, stepID string) *config.StepConfig {
 	return flow.GetStep(stepID)
 }
 
+// PromptVariant returns the prompt variant of the conversation's current step
+// for its invalid attempts and chat type (private when the chat is the user's own).
+// Returns false if the step has no applicable variant.
+func (e *FlowEngine) PromptVariant(conv *Conversation) (string, bool) {
+	step := e.GetStep(conv.FlowID, conv.StepID)
+	if step == nil || step.PromptVariants == nil {
+		return "", false
+	}
+	conv.mu.RLock()
+	attempts := conv.InvalidInputs
+	conv.mu.RUnlock()
+	text := step.PromptVariants.Select(attempts, conv.ChatID == conv.UserID)
+	return text, text != ""
+}
+
 // ValidateInput validates user input against the step's validation rules.
 // Returns an error if validation fails, nil if valid or no validation configured.
-func (e *FlowEngine) ValidateInput(conv *Conversation, input string) error {
+// Steps without an error message fail with the default messages of the
+// built-in texts in ctx (see core.StringsFrom), in the user's language.
+func (e *FlowEngine) ValidateInput(ctx context.Context, conv *Conversation, input string) error {
 	step := e.GetStep(conv.FlowID, conv.StepID)
 	if step == nil {
 		return nil
 	}
+	return e.validate(ctx, step.Validation, conv, input)
+}
 
-	if step.Validation == nil {
-		return nil
+// Validate checks input against the validation rules of a step without a live
+// conversation, e.g. to pre-check web form fields or test configs with the
+// same rules the bot enforces. Custom validators receive a conversation in the
+// step with no data. Returns ErrStepNotFound if the flow has no such step,
+// nil if the input is valid or the step has no validation.
+func (e *FlowEngine) Validate(ctx context.Context, flowID, stepID, input string) error {
+	step := e.GetStep(flowID, stepID)
+	if step == nil {
+		return fmt.Errorf("%w: %s/%s", ErrStepNotFound, flowID, stepID)
 	}
+	return e.validate(ctx, step.Validation, NewConversation(0, 0, 0, flowID, stepID, 0), input)
+}
+
+// FileInput describes a photo or document sent to a step, for ValidateFile.
+type FileInput struct {
+	Size     int64  // File size in bytes, 0 if unknown
+	MIMEType string // MIME type of documents
+	Width    int    // Width of photos in pixels
+	Height   int    // Height of photos in pixels
+}
 
+// ValidateFile checks a file sent to a photo or document step against the
+// step's file limits: max_size, allowed_mime for documents, and
+// min_dimensions for photos. Steps without an error message fail with the
+// default messages of the built-in texts in ctx.
+func (e *FlowEngine) ValidateFile(ctx context.Context, conv *Conversation, file FileInput) error {
+	step := e.GetStep(conv.FlowID, conv.StepID)
+	if step == nil || step.Validation == nil {
+		return nil
+	}
 	validation := step.Validation
+	msgs := core.StringsFrom(ctx)
+
+	if max := validation.GetMaxSize(); max > 0 && file.Size > max {
+		return errors.New(getErrorMsg(validation.ErrorMsg, fmt.Sprintf(msgs.FileTooLarge, validation.MaxSize)))
+	}
+	if step.InputType == config.InputTypeDocument && !validation.AllowsMIME(file.MIMEType) {
+		return errors.New(getErrorMsg(validation.ErrorMsg, msgs.FileTypeNotAllowed))
+	}
+	if w, h := validation.GetMinDimensions(); step.InputType == config.InputTypePhoto && (file.Width < w || file.Height < h) {
+		return errors.New(getErrorMsg(validation.ErrorMsg, fmt.Sprintf(msgs.ImageTooSmall, validation.MinDimensions)))
+	}
+	return nil
+}
+
+// validate checks input against validation rules.
+func (e *FlowEngine) validate(ctx context.Context, validation *config.ValidationConfig, conv *Conversation, input string) error {
+	if validation == nil {
+		return nil
+	}
+
+	msgs := core.StringsFrom(ctx)
 
 	switch validation.Type {
 	case "number":
-		return e.validateNumber(input, validation)
+		return e.validateNumber(input, validation, msgs)
 	case "address":
-		return e.validateAddress(input, validation)
+		return e.validateAddress(input, validation, msgs)
 	case "email":
-		return e.validateEmail(input, validation)
+		return e.validateEmail(input, validation, msgs)
 	case "regex":
-		return e.validateRegex(input, validation)
+		return e.validateRegex(input, validation, msgs)
 	case "custom":
 		return e.validateCustom(input, validation, conv)
 	default:
@@ -160,23 +435,23 @@ func (e *FlowEngine) ValidateInput(conv *Conversation, input string) error {
 }
 
 // validateNumber validates numeric input with optional min/max constraints.
-func (e *FlowEngine) validateNumber(input string, validation *config.ValidationConfig) error {
+func (e *FlowEngine) validateNumber(input string, validation *config.ValidationConfig, msgs core.Strings) error {
 	num, err := strconv.ParseFloat(input, 64)
 	if err != nil {
-		return errors.New(getErrorMsg(validation.ErrorMsg, "Please enter a valid number"))
+		return errors.New(getErrorMsg(validation.ErrorMsg, msgs.InvalidNumber))
 	}
 
 	if validation.Min != "" {
 		min, err := strconv.ParseFloat(validation.Min, 64)
 		if err == nil && num < min {
-			return errors.New(getErrorMsg(validation.ErrorMsg, "Number cannot be less than "+validation.Min))
+			return errors.New(getErrorMsg(validation.ErrorMsg, fmt.Sprintf(msgs.NumberTooSmall, validation.Min)))
 		}
 	}
 
 	if validation.Max != "" {
 		max, err := strconv.ParseFloat(validation.Max, 64)
 		if err == nil && num > max {
-			return errors.New(getErrorMsg(validation.ErrorMsg, "Number cannot be greater than "+validation.Max))
+			return errors.New(getErrorMsg(validation.ErrorMsg, fmt.Sprintf(msgs.NumberTooLarge, validation.Max)))
 		}
 	}
 
@@ -192,9 +467,9 @@ func getErrorMsg(configured, defaultMsg string) string {
 }
 
 // validateAddress validates Ethereum-style addresses (0x + 40 hex chars).
-func (e *FlowEngine) validateAddress(input string, validation *config.ValidationConfig) error {
+func (e *FlowEngine) validateAddress(input string, validation *config.ValidationConfig, msgs core.Strings) error {
 	if len(input) != 42 || !strings.HasPrefix(input, "0x") {
-		return errors.New(getErrorMsg(validation.ErrorMsg, "Please enter a valid Ethereum address"))
+		return errors.New(getErrorMsg(validation.ErrorMsg, msgs.InvalidAddress))
 	}
 
 	// Check if it contains valid hexadecimal characters
@@ -203,7 +478,7 @@ func (e *FlowEngine) validateAddress(input string, validation *config.Validation
 		// Address is too long for single uint64, check character by character
 		for _, c := range input[2:] {
 			if !((c >= '0' && c <= '9') || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')) {
-				return errors.New(getErrorMsg(validation.ErrorMsg, "Please enter a valid Ethereum address"))
+				return errors.New(getErrorMsg(validation.ErrorMsg, msgs.InvalidAddress))
 			}
 		}
 	}
@@ -212,28 +487,28 @@ func (e *FlowEngine) validateAddress(input string, validation *config.Validation
 }
 
 // validateEmail validates email format using regex.
-func (e *FlowEngine) validateEmail(input string, validation *config.ValidationConfig) error {
+func (e *FlowEngine) validateEmail(input string, validation *config.ValidationConfig, msgs core.Strings) error {
 	pattern := `^[a-zA-Z0-9._%+-]+@[a-zA-Z0-9.-]+\.[a-zA-Z]{2,}$`
 	matched, _ := regexp.MatchString(pattern, input)
 	if !matched {
-		return errors.New(getErrorMsg(validation.ErrorMsg, "Please enter a valid email address"))
+		return errors.New(getErrorMsg(validation.ErrorMsg, msgs.InvalidEmail))
 	}
 	return nil
 }
 
 // validateRegex validates input against a custom regex pattern.
-func (e *FlowEngine) validateRegex(input string, validation *config.ValidationConfig) error {
+func (e *FlowEngine) validateRegex(input string, validation *config.ValidationConfig, msgs core.Strings) error {
 	if validation.Pattern == "" {
 		return nil
 	}
 
-	matched, err := regexp.MatchString(validation.Pattern, input)
-	if err != nil {
+	re := e.compile(validation.Pattern)
+	if re == nil {
 		return errors.New("Validation rule configuration error")
 	}
 
-	if !matched {
-		return errors.New(getErrorMsg(validation.ErrorMsg, "Input format is incorrect"))
+	if !re.MatchString(input) {
+		return errors.New(getErrorMsg(validation.ErrorMsg, msgs.InvalidFormat))
 	}
 
 	return nil
@@ -266,23 +541,23 @@ func (e *FlowEngine) EvaluateCondition(ctx context.Context, conv *Conversation,
 	}
 
 	// Fall back to simple built-in condition evaluation
-	return e.simpleEvaluate(conv, condition)
+	return e.simpleEvaluate(ctx, conv, condition)
 }
 
 // simpleEvaluate provides basic condition evaluation.
-// Supports simple equality (==) and inequality (!=) comparisons.
-func (e *FlowEngine) simpleEvaluate(conv *Conversation, condition string) bool {
+// Supports simple equality (==) and inequality (!=) comparisons, and bare
+// references (optionally negated with !) as truthiness checks.
+// References may address conversation data (data.key), chat settings (chat.key),
+// or environment variables (env.key).
+func (e *FlowEngine) simpleEvaluate(ctx context.Context, conv *Conversation, condition string) bool {
 	// Support simple data.key == "value" format
 	parts := strings.Split(condition, "==")
 	if len(parts) == 2 {
 		key := strings.TrimSpace(parts[0])
 		expected := strings.Trim(strings.TrimSpace(parts[1]), "\"'")
 
-		// Remove data. prefix
-		key = strings.TrimPrefix(key, "data.")
-
-		actual := conv.GetString(key)
-		return actual == expected
+		actual, _ := e.LookupValue(ctx, conv, key)
+		return formatValue(actual) == expected
 	}
 
 	// Support data.key != "value" format
@@ -291,15 +566,180 @@ func (e *FlowEngine) simpleEvaluate(conv *Conversation, condition string) bool {
 		key := strings.TrimSpace(parts[0])
 		expected := strings.Trim(strings.TrimSpace(parts[1]), "\"'")
 
-		key = strings.TrimPrefix(key, "data.")
+		actual, _ := e.LookupValue(ctx, conv, key)
+		return formatValue(actual) != expected
+	}
 
-		actual := conv.GetString(key)
-		return actual != expected
+	// Support bare references such as chat.key or !chat.key
+	ref := strings.TrimSpace(condition)
+	negate := strings.HasPrefix(ref, "!")
+	ref = strings.TrimSpace(strings.TrimPrefix(ref, "!"))
+	if e.isReference(ref) {
+		v, ok := e.LookupValue(ctx, conv, ref)
+		return (ok && store.Truthy(v)) != negate
 	}
 
 	return true
 }
 
+// isReference returns true if s addresses a value namespace.
+func (e *FlowEngine) isReference(s string) bool {
+	if strings.HasPrefix(s, "data.") || strings.HasPrefix(s, ChatSettingPrefix) || strings.HasPrefix(s, "env.") {
+		return true
+	}
+	if name, _, ok := strings.Cut(s, "."); ok {
+		return e.getNamespace(name) != nil
+	}
+	return false
+}
+
+// LookupValue resolves a reference to a value.
+// Supported forms are "chat.key" (chat settings), "env.key" (environment,
+// honoring tenant overrides), "<namespace>.key" for registered namespaces,
+// and "data.key" or a bare key (conversation data). Keys of data returned by
+// namespaced sub-flows are addressed as "<namespace>.key".
+// Returns the value and a boolean indicating if it exists.
+func (e *FlowEngine) LookupValue(ctx context.Context, conv *Conversation, ref string) (interface{}, bool) {
+	switch {
+	case strings.HasPrefix(ref, ChatSettingPrefix):
+		v, ok := e.conversationChatSettings(ctx, conv)[strings.TrimPrefix(ref, ChatSettingPrefix)]
+		return v, ok
+	case strings.HasPrefix(ref, "env."):
+		cfg := e.getConfig()
+		if cfg == nil {
+			return nil, false
+		}
+		v := cfg.GetEnvFor(conv.ChatID, strings.TrimPrefix(ref, "env."))
+		return v, v != nil
+	}
+
+	if name, key, ok := strings.Cut(ref, "."); ok {
+		if ns := e.getNamespace(name); ns != nil {
+			v, ok := ns(ctx, conv)[key]
+			return v, ok
+		}
+	}
+	key := strings.TrimPrefix(ref, "data.")
+	if v, ok := conv.Get(key); ok {
+		return v, true
+	}
+
+	// Data returned by a namespaced sub-flow is a map, e.g. wallet.address
+	if name, field, ok := strings.Cut(key, "."); ok {
+		if m, ok := conv.Get(name); ok {
+			if fields, ok := m.(map[string]interface{}); ok {
+				v, ok := fields[field]
+				return v, ok
+			}
+		}
+	}
+	return nil, false
+}
+
+// formatValue formats a loosely typed value for string comparison.
+func formatValue(v interface{}) string {
+	if v == nil {
+		return ""
+	}
+	if s, ok := v.(string); ok {
+		return s
+	}
+	return fmt.Sprint(v)
+}
+
+// StoreInput stores a value collected by a step.
+// Keys with the "chat." prefix are written to persistent chat settings,
+// so settings flows can be built from ordinary steps; other keys go to conversation data.
+// In previews, "chat." keys stay in the conversation data as well.
+// Keys declared in the flow's schema are converted to their type first; values
+// that don't convert return a TypeError and are not stored.
+func (e *FlowEngine) StoreInput(ctx context.Context, conv *Conversation, key string, value interface{}) error {
+	if strings.HasPrefix(key, ChatSettingPrefix) && !conv.IsPreview() {
+		e.mu.RLock()
+		s := e.chatSettings
+		e.mu.RUnlock()
+		if s != nil {
+			return s.SetChatSetting(ctx, conv.ChatID, strings.TrimPrefix(key, ChatSettingPrefix), value)
+		}
+	}
+	value, err := e.typed(ctx, conv, key, value)
+	if err != nil {
+		return err
+	}
+	conv.Set(key, value)
+	return nil
+}
+
+// RenderText renders template expressions in a text using text/template.
+// Conversation data is available at the root ({{.key}}) and under .data,
+// chat settings under .chat, environment variables under .env, and
+// registered namespaces under their names. Arithmetic helpers (add, sub, mul,
+// div, round) are available as functions.
+// If ctx carries a parse mode (see core.WithParseMode), printed values are
+// escaped for it unless passed through raw.
+// Missing values render as empty strings; texts that fail to parse are returned unchanged.
+func (e *FlowEngine) RenderText(ctx context.Context, conv *Conversation, text string) string {
+	if !strings.Contains(text, "{{") {
+		return text
+	}
+
+	mode := core.ParseModeFrom(ctx)
+	tmpl, err := template.New("text").Funcs(templateFuncs).Funcs(escapeFuncs(mode)).Parse(text)
+	if err != nil {
+		return text
+	}
+	if mode != "" {
+		for _, t := range tmpl.Templates() {
+			escapeActions(t.Tree.Root)
+		}
+	}
+
+	var buf strings.Builder
+	if err := tmpl.Execute(&buf, e.templateData(ctx, conv)); err != nil {
+		return text
+	}
+
+	// Missing map keys render as "<no value>"; show them as empty instead
+	return strings.ReplaceAll(buf.String(), "<no value>", "")
+}
+
+// templateData builds the data passed to text templates.
+func (e *FlowEngine) templateData(ctx context.Context, conv *Conversation) map[string]interface{} {
+	data := conv.CopyData()
+
+	env := make(map[string]interface{})
+	if cfg := e.getConfig(); cfg != nil {
+		for k, v := range cfg.Environment {
+			env[k] = v
+		}
+		if t := cfg.TenantFor(conv.ChatID); t != nil {
+			for k, v := range t.Environment {
+				env[k] = v
+			}
+		}
+	}
+
+	root := make(map[string]interface{}, len(data)+3)
+	for k, v := range data {
+		root[k] = v
+	}
+
+	e.mu.RLock()
+	namespaces := make(map[string]ValueNamespace, len(e.namespaces))
+	for name, ns := range e.namespaces {
+		namespaces[name] = ns
+	}
+	e.mu.RUnlock()
+	for name, ns := range namespaces {
+		root[name] = ns(ctx, conv)
+	}
+
+	root["data"] = data
+	root["chat"] = e.conversationChatSettings(ctx, conv)
+	root["env"] = env
+	return root
+}
+
 // DetermineNextStep determines the next step based on input and branch conditions.
 // Evaluates branch conditions in order and returns the first matching next step,
 // or falls back to the default NextStep if no branches match.
@@ -310,19 +750,38 @@ func (e *FlowEngine) DetermineNextStep(ctx context.Context, conv *Conversation,
 	}
 
 	// Check branch conditions
-	for _, branch := range step.Branches {
-		if e.evaluateBranchCondition(conv, branch.Condition, input) {
-			return branch.NextStep
-		}
+	if branch := e.MatchBranch(ctx, conv, input); branch != nil {
+		return branch.NextStep
 	}
 
 	// Return default next step
 	return step.NextStep
 }
 
+// MatchBranch returns the first branch of the current step whose condition matches the input.
+// Returns nil if no branch matches.
+func (e *FlowEngine) MatchBranch(ctx context.Context, conv *Conversation, input string) *config.BranchConfig {
+	step := e.GetStep(conv.FlowID, conv.StepID)
+	if step == nil {
+		return nil
+	}
+
+	for i := range step.Branches {
+		if e.evaluateBranchCondition(ctx, conv, step.Branches[i].Condition, input) {
+			return &step.Branches[i]
+		}
+	}
+	return nil
+}
+
 // evaluateBranchCondition evaluates a branch condition against the input.
-// Supports input == "xxx" format and general condition evaluation.
-func (e *FlowEngine) evaluateBranchCondition(conv *Conversation, condition, input string) bool {
+// Supports input == "xxx" format, input =~ "regex" format, and general condition evaluation.
+func (e *FlowEngine) evaluateBranchCondition(ctx context.Context, conv *Conversation, condition, input string) bool {
+	// Support input =~ "regex" format
+	if pattern, ok := config.ConditionPattern(condition); ok {
+		return e.matchBranchPattern(conv, pattern, input)
+	}
+
 	// Support input == "xxx" format
 	if strings.HasPrefix(condition, "input") {
 		parts := strings.Split(condition, "==")
@@ -333,25 +792,130 @@ func (e *FlowEngine) evaluateBranchCondition(conv *Conversation, condition, inpu
 	}
 
 	// Use general condition evaluation
-	return e.simpleEvaluate(conv, condition)
+	return e.simpleEvaluate(ctx, conv, condition)
+}
+
+// matchBranchPattern matches input against a branch regex. On a match, named
+// capture groups are stored into conversation data, so "buy 5 ETH" matched by
+// "^(?P<action>buy|sell) (?P<amount>\d+) (?P<asset>\w+)$" sets action, amount, and asset.
+// Invalid patterns never match; Validate rejects them when the config is loaded.
+func (e *FlowEngine) matchBranchPattern(conv *Conversation, pattern, input string) bool {
+	re := e.compile(pattern)
+	if re == nil {
+		return false
+	}
+	match := re.FindStringSubmatch(input)
+	if match == nil {
+		return false
+	}
+	for i, name := range re.SubexpNames() {
+		if i > 0 && name != "" {
+			conv.Set(name, match[i])
+		}
+	}
+	return true
 }
 
 // ExecuteStepHandler executes a registered step handler by name.
-// Returns nil if no handler is registered for the given name.
+// Returns nil if no handler is registered for the given name or the
+// conversation is a preview and the handler isn't marked safe, an error
+// wrapping ErrHandlerTimeout if the handler exceeds its configured timeout,
+// and one wrapping breaker.ErrOpen if its circuit breaker skipped the call.
 func (e *FlowEngine) ExecuteStepHandler(ctx context.Context, conv *Conversation, handlerName string) error {
 	handler := e.GetStepHandler(handlerName)
-	if handler == nil {
+	if handler == nil || (conv.IsPreview() && !e.IsPreviewSafe(handlerName)) {
 		return nil
 	}
-	return handler(ctx, conv)
+	name := "step:" + handlerName
+	b := e.breaker(name)
+	if b != nil && !b.Allow(time.Now()) {
+		return fmt.Errorf("%s: %w", name, breaker.ErrOpen)
+	}
+	defer e.observeLatency(ctx, name, time.Now())
+
+	var handlerErr error
+	err := fmt.Errorf("%s: %w", name, ErrHandlerTimeout)
+	if runWithTimeout(ctx, e.handlerTimeout(name), func(ctx context.Context) {
+		handlerErr = handler(ctx, conv)
+	}) {
+		err = handlerErr
+	}
+	if b != nil {
+		if err != nil {
+			b.Failure(time.Now())
+		} else {
+			b.Success()
+		}
+	}
+	return err
 }
 
 // GetDynamicKeyboardData retrieves dynamic keyboard data from a registered provider.
-// Returns nil if no provider is registered for the given name.
+// Returns nil if no provider is registered for the given name. If the provider
+// fails (returns an error, panics, exceeds its configured timeout, or its circuit
+// breaker is open), the provider error handler is called and the provider's
+// latest result or the keyboard's fallback buttons are returned instead.
+// An empty result is kept if the keyboard has an empty state and replaced by
+// the fallback buttons otherwise.
 func (e *FlowEngine) GetDynamicKeyboardData(ctx context.Context, conv *Conversation, providerName string) []config.ButtonData {
-	provider := e.GetKeyboardProvider(providerName)
+	provider := e.getFallibleKeyboardProvider(providerName)
 	if provider == nil {
 		return nil
 	}
-	return provider(ctx, conv)
+	data, err := e.callKeyboardProvider(ctx, conv, providerName, provider)
+	if err != nil {
+		e.providerError(ctx, conv, providerName, err)
+		return e.keyboardFallback(conv, providerName)
+	}
+	e.cacheKeyboardData(providerName, data)
+	if len(data) == 0 && !e.hasEmptyState(conv) {
+		return e.fallbackButtons(conv)
+	}
+	return data
+}
+
+// callKeyboardProvider calls a provider under its circuit breaker and timeout.
+// Panics in the provider are returned as errors.
+func (e *FlowEngine) callKeyboardProvider(ctx context.Context, conv *Conversation, providerName string, provider FallibleKeyboardProvider) ([]config.ButtonData, error) {
+	name := "keyboard:" + providerName
+	b := e.breaker(name)
+	if b != nil && !b.Allow(time.Now()) {
+		return nil, fmt.Errorf("%s: %w", name, breaker.ErrOpen)
+	}
+	defer e.observeLatency(ctx, name, time.Now())
+
+	var data []config.ButtonData
+	var providerErr error
+	err := fmt.Errorf("%s: %w", name, ErrHandlerTimeout)
+	if runWithTimeout(ctx, e.handlerTimeout(name), func(ctx context.Context) {
+		defer func() {
+			if p := recover(); p != nil {
+				providerErr = fmt.Errorf("%s: panic: %v", name, p)
+			}
+		}()
+		data, providerErr = provider(ctx, conv)
+	}) {
+		err = providerErr
+	}
+	if b != nil {
+		if err != nil {
+			b.Failure(time.Now())
+		} else {
+			b.Success()
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// providerError passes a keyboard provider failure to the provider error handler.
+func (e *FlowEngine) providerError(ctx context.Context, conv *Conversation, providerName string, err error) {
+	e.mu.RLock()
+	fn := e.onProviderError
+	e.mu.RUnlock()
+	if fn != nil {
+		fn(ctx, conv, providerName, err)
+	}
 }
@@ -0,0 +1,66 @@
+package conv
+
+import (
+	"fmt"
+	"text/template"
+	"text/template/parse"
+
+	"github.com/0xVanfer/tg-listener/core"
+)
+
+// escapeFunc is the template function appended to every action of a text
+// rendered for a parse mode.
+const escapeFunc = "_escape"
+
+// rawFunc is the template function marking an action's output as markup,
+// e.g. {{raw .bio_html}}, so it is not escaped.
+const rawFunc = "raw"
+
+// escapeFuncs returns the template functions escaping values for a parse mode.
+func escapeFuncs(mode string) template.FuncMap {
+	return template.FuncMap{
+		escapeFunc: func(v interface{}) string {
+			if v == nil {
+				return ""
+			}
+			return core.Escape(mode, fmt.Sprint(v))
+		},
+	}
+}
+
+// escapeActions makes every action printing a value in a template tree escape
+// its output, like html/template does, so values like user input can't break
+// or inject formatting. Actions ending in raw are left as they are.
+func escapeActions(node parse.Node) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, child := range n.Nodes {
+			escapeActions(child)
+		}
+	case *parse.ActionNode:
+		if len(n.Pipe.Decl) > 0 || len(n.Pipe.Cmds) == 0 {
+			return
+		}
+		last := n.Pipe.Cmds[len(n.Pipe.Cmds)-1]
+		if id, ok := last.Args[0].(*parse.IdentifierNode); ok && id.Ident == rawFunc {
+			return
+		}
+		n.Pipe.Cmds = append(n.Pipe.Cmds, &parse.CommandNode{
+			NodeType: parse.NodeCommand,
+			Pos:      n.Pos,
+			Args:     []parse.Node{parse.NewIdentifier(escapeFunc).SetPos(n.Pos)},
+		})
+	case *parse.IfNode:
+		escapeActions(n.List)
+		escapeActions(n.ElseList)
+	case *parse.RangeNode:
+		escapeActions(n.List)
+		escapeActions(n.ElseList)
+	case *parse.WithNode:
+		escapeActions(n.List)
+		escapeActions(n.ElseList)
+	}
+}
@@ -0,0 +1,57 @@
+package conv
+
+import (
+	"sort"
+	"time"
+)
+
+// Filter selects conversations in Manager.Find. Zero fields match every conversation.
+type Filter struct {
+	FlowID  string        // Flow the conversation is currently in
+	StepID  string        // Step the conversation is waiting on
+	UserID  int64         // User taking part in the conversation
+	ChatID  int64         // Chat the conversation takes place in
+	MinAge  time.Duration // Minimum time since the conversation started
+	MinIdle time.Duration // Minimum time since the conversation was last updated
+	Limit   int           // Maximum number of results; 0 for all
+}
+
+// Match returns true if a conversation view passes the filter.
+func (f Filter) Match(v View) bool {
+	now := time.Now()
+	switch {
+	case f.FlowID != "" && v.FlowID != f.FlowID:
+		return false
+	case f.StepID != "" && v.StepID != f.StepID:
+		return false
+	case f.UserID != 0 && v.UserID != f.UserID:
+		return false
+	case f.ChatID != 0 && v.ChatID != f.ChatID:
+		return false
+	case f.MinAge > 0 && now.Sub(v.CreatedAt) < f.MinAge:
+		return false
+	case f.MinIdle > 0 && now.Sub(v.UpdatedAt) < f.MinIdle:
+		return false
+	}
+	return true
+}
+
+// Find returns views of the active conversations passing the filter, the
+// longest idle first. Like Range, it visits only conversations cached by this
+// instance and holds no lock while filtering.
+func (m *Manager) Find(f Filter) []View {
+	var views []View
+	m.Range(func(v View) bool {
+		if f.Match(v) {
+			views = append(views, v)
+		}
+		return true
+	})
+	sort.SliceStable(views, func(i, j int) bool {
+		return views[i].UpdatedAt.Before(views[j].UpdatedAt)
+	})
+	if f.Limit > 0 && len(views) > f.Limit {
+		views = views[:f.Limit]
+	}
+	return views
+}
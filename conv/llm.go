@@ -0,0 +1,145 @@
+// Package conv provides the flow engine for executing conversation flows.
+package conv
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrNoCompleter is returned when an LLM step names a completer that is not registered.
+var ErrNoCompleter = errors.New("completer not registered")
+
+// CompletionRequest is the context passed to a completer for one LLM step turn.
+type CompletionRequest struct {
+	// Conversation is the conversation being served; its data and history are readable.
+	Conversation *Conversation
+
+	// System is the step's rendered system prompt.
+	System string
+
+	// Input is the user's text for this turn.
+	Input string
+
+	// Transcript holds the earlier turns of the current visit to the step,
+	// oldest first, with the responses shown for them in Output.
+	Transcript []HistoryEntry
+}
+
+// CompletionResult is a completer's answer for one turn.
+type CompletionResult struct {
+	// Text is the response shown to the user.
+	Text string
+
+	// Data holds structured results stored into conversation data,
+	// available to subsequent prompts and branch conditions.
+	Data map[string]interface{}
+
+	// Done completes the step; otherwise the step waits for another input.
+	Done bool
+}
+
+// StreamFunc receives the response text accumulated so far while a completion streams.
+type StreamFunc func(partial string)
+
+// Completer produces responses for LLM steps. Implementations wrap any model
+// or service; stream may be called any number of times before returning,
+// and may be ignored by completers that don't stream.
+type Completer interface {
+	Complete(ctx context.Context, req *CompletionRequest, stream StreamFunc) (*CompletionResult, error)
+}
+
+// CompleterFunc adapts a function to a Completer.
+type CompleterFunc func(ctx context.Context, req *CompletionRequest, stream StreamFunc) (*CompletionResult, error)
+
+// Complete calls f(ctx, req, stream).
+func (f CompleterFunc) Complete(ctx context.Context, req *CompletionRequest, stream StreamFunc) (*CompletionResult, error) {
+	return f(ctx, req, stream)
+}
+
+// RegisterCompleter registers a completer by name.
+// The completer is used by LLM steps whose configuration references the name.
+func (e *FlowEngine) RegisterCompleter(name string, completer Completer) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.completers[name] = completer
+}
+
+// GetCompleter retrieves a completer by name.
+func (e *FlowEngine) GetCompleter(name string) Completer {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.completers[name]
+}
+
+// Complete runs one turn of the current LLM step for input, which must already be
+// recorded in the conversation history. The response is stored under the step's
+// response key and as the output of the turn, and structured results are stored
+// into conversation data. Returns whether the step is done, either because the
+// completer said so or because the step's MaxTurns was reached. In previews,
+// completers not marked preview-safe aren't called and the step is done.
+func (e *FlowEngine) Complete(ctx context.Context, conv *Conversation, input string, stream StreamFunc) (bool, error) {
+	step := e.GetStep(conv.FlowID, conv.StepID)
+	if step == nil || step.LLM == nil {
+		return true, nil
+	}
+
+	completer := e.GetCompleter(step.LLM.Completer)
+	if completer == nil {
+		return false, ErrNoCompleter
+	}
+	if conv.IsPreview() && !e.IsPreviewSafe(step.LLM.Completer) {
+		// Previews don't call models; the step answers with a placeholder and ends
+		text := "🧪 " + step.LLM.Completer + " is not called in previews."
+		conv.Set(step.LLM.GetResponseKey(), text)
+		conv.SetLastOutput(text)
+		return true, nil
+	}
+
+	transcript := conv.StepTranscript(conv.StepID)
+	turns := len(transcript)
+	if turns > 0 {
+		// The current input is the last entry
+		transcript = transcript[:turns-1]
+	}
+
+	if stream == nil {
+		stream = func(string) {}
+	}
+	start := time.Now()
+	result, err := completer.Complete(ctx, &CompletionRequest{
+		Conversation: conv,
+		System:       e.RenderText(ctx, conv, step.LLM.SystemPrompt),
+		Input:        input,
+		Transcript:   transcript,
+	}, stream)
+	e.observeLatency(ctx, "llm:"+step.LLM.Completer, start)
+	if err != nil {
+		return false, err
+	}
+	if result == nil {
+		result = &CompletionResult{}
+	}
+
+	for k, v := range result.Data {
+		if err := e.StoreInput(ctx, conv, k, v); err != nil {
+			return false, err
+		}
+	}
+	conv.Set(step.LLM.GetResponseKey(), result.Text)
+	conv.SetLastOutput(result.Text)
+
+	return result.Done || (step.LLM.MaxTurns > 0 && turns >= step.LLM.MaxTurns), nil
+}
+
+// LLMResponse returns the latest response of the conversation's current LLM step,
+// shown in place of its prompt while the step is being answered.
+// Returns false on a fresh visit to the step or for other step types.
+func (e *FlowEngine) LLMResponse(conv *Conversation) (string, bool) {
+	step := e.GetStep(conv.FlowID, conv.StepID)
+	if step == nil || step.LLM == nil || len(conv.StepTranscript(conv.StepID)) == 0 {
+		return "", false
+	}
+	text := conv.GetString(step.LLM.GetResponseKey())
+	return text, text != ""
+}
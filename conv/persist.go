@@ -0,0 +1,223 @@
+// Package conv provides conversation persistence.
+package conv
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/0xVanfer/tg-listener/store"
+)
+
+// conversationPrefix is the store key prefix for persisted conversations.
+const conversationPrefix = "conv:"
+
+// conversationJSON is the serialized form of a Conversation.
+type conversationJSON struct {
+	UserID        int64                  `json:"user_id"`
+	ChatID        int64                  `json:"chat_id"`
+	TopicID       int                    `json:"topic_id,omitempty"`
+	FlowID        string                 `json:"flow_id"`
+	StepID        string                 `json:"step_id"`
+	State         ConversationState      `json:"state"`
+	Data          map[string]interface{} `json:"data"`
+	KeyboardMsgID int                    `json:"keyboard_msg_id,omitempty"`
+	KeyboardText  string                 `json:"keyboard_text,omitempty"`
+	ReplyKeyboard bool                   `json:"reply_keyboard,omitempty"`
+	MessageIDs    []int                  `json:"message_ids,omitempty"`
+	ErrorMsgID    int                    `json:"error_msg_id,omitempty"`
+	ErrorText     string                 `json:"error_text,omitempty"`
+	InvalidInputs int                    `json:"invalid_inputs,omitempty"`
+	LastErrorAt   time.Time              `json:"last_error_at"`
+	StepDeadline  time.Time              `json:"step_deadline"`
+	StepTimeoutID string                 `json:"step_timeout_id,omitempty"`
+	CreatedAt     time.Time              `json:"created_at"`
+	UpdatedAt     time.Time              `json:"updated_at"`
+	ExpiresAt     time.Time              `json:"expires_at"`
+	History       []HistoryEntry         `json:"history"`
+	Callers       []CallFrame            `json:"callers,omitempty"`
+	Preview       bool                   `json:"preview,omitempty"`
+	Version       int64                  `json:"version"`
+}
+
+// MarshalJSON encodes the conversation. Data values go through JSON as well,
+// so after decoding numbers are float64 and structs are maps.
+func (c *Conversation) MarshalJSON() ([]byte, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return json.Marshal(conversationJSON{
+		UserID:        c.UserID,
+		ChatID:        c.ChatID,
+		TopicID:       c.TopicID,
+		FlowID:        c.FlowID,
+		StepID:        c.StepID,
+		State:         c.State,
+		Data:          c.Data,
+		KeyboardMsgID: c.KeyboardMsgID,
+		KeyboardText:  c.KeyboardText,
+		ReplyKeyboard: c.ReplyKeyboard,
+		MessageIDs:    c.MessageIDs,
+		ErrorMsgID:    c.ErrorMsgID,
+		ErrorText:     c.ErrorText,
+		InvalidInputs: c.InvalidInputs,
+		LastErrorAt:   c.LastErrorAt,
+		StepDeadline:  c.StepDeadline,
+		StepTimeoutID: c.StepTimeoutID,
+		CreatedAt:     c.CreatedAt,
+		UpdatedAt:     c.UpdatedAt,
+		ExpiresAt:     c.ExpiresAt,
+		History:       c.History,
+		Callers:       c.Callers,
+		Preview:       c.Preview,
+		Version:       c.version,
+	})
+}
+
+// UnmarshalJSON decodes a conversation encoded with MarshalJSON.
+func (c *Conversation) UnmarshalJSON(data []byte) error {
+	var v conversationJSON
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	if v.Data == nil {
+		v.Data = make(map[string]interface{})
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.UserID = v.UserID
+	c.ChatID = v.ChatID
+	c.TopicID = v.TopicID
+	c.FlowID = v.FlowID
+	c.StepID = v.StepID
+	c.State = v.State
+	c.Data = v.Data
+	c.KeyboardMsgID = v.KeyboardMsgID
+	c.KeyboardText = v.KeyboardText
+	c.ReplyKeyboard = v.ReplyKeyboard
+	c.MessageIDs = v.MessageIDs
+	c.ErrorMsgID = v.ErrorMsgID
+	c.ErrorText = v.ErrorText
+	c.InvalidInputs = v.InvalidInputs
+	c.LastErrorAt = v.LastErrorAt
+	c.StepDeadline = v.StepDeadline
+	c.StepTimeoutID = v.StepTimeoutID
+	c.CreatedAt = v.CreatedAt
+	c.UpdatedAt = v.UpdatedAt
+	c.ExpiresAt = v.ExpiresAt
+	c.History = v.History
+	c.Callers = v.Callers
+	c.Preview = v.Preview
+	c.version = v.Version
+	return nil
+}
+
+// storeKey returns the store key of a persisted conversation.
+func storeKey(userID, chatID int64, topicID int) string {
+	return conversationPrefix + conversationKey(userID, chatID, topicID)
+}
+
+// SetStore enables persistence of conversations in the given store, so they
+// survive restarts and can be shared by multiple bot instances. Conversations
+// are saved when they start, change step, and after each handled update, and
+// loaded on access when another instance saved a newer version.
+// Pass nil to keep conversations in memory only.
+func (m *Manager) SetStore(s store.Store) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.store = s
+}
+
+// getStore returns the persistence store, or nil if conversations are in memory only.
+func (m *Manager) getStore() store.Store {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.store
+}
+
+// Save persists a conversation if a store is set and the conversation is still
+// active. Ended conversations are not saved, so a late save cannot revive them.
+func (m *Manager) Save(ctx context.Context, c *Conversation) error {
+	st := m.getStore()
+	if st == nil {
+		return nil
+	}
+	scope := m.scopeTopic(c.TopicID)
+	m.mu.RLock()
+	active := m.conversations[conversationKey(c.UserID, c.ChatID, scope)] == c
+	m.mu.RUnlock()
+	if !active {
+		return nil
+	}
+
+	c.mu.Lock()
+	c.version++
+	c.mu.Unlock()
+	return store.PutJSON(ctx, st, storeKey(c.UserID, c.ChatID, scope), c)
+}
+
+// load reads a persisted conversation.
+// Returns nil without error if the conversation is not persisted.
+func (m *Manager) load(ctx context.Context, st store.Store, userID, chatID int64, topicID int) (*Conversation, error) {
+	c := &Conversation{}
+	if err := store.GetJSON(ctx, st, storeKey(userID, chatID, topicID), c); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return c, nil
+}
+
+// sync reconciles the cached conversation of a user/chat with the store:
+// a newer persisted version replaces the cached one, and a cached conversation
+// whose persisted copy is gone was ended by another instance. On store errors
+// the cached conversation is kept.
+func (m *Manager) sync(ctx context.Context, st store.Store, key string, cached *Conversation, userID, chatID int64, topicID int) *Conversation {
+	stored, err := m.load(ctx, st, userID, chatID, topicID)
+	if err != nil {
+		return cached
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	switch {
+	case stored == nil && cached != nil:
+		delete(m.conversations, key)
+		return nil
+	case stored == nil:
+		return nil
+	case cached == nil || stored.version > cached.version:
+		m.conversations[key] = stored
+		return stored
+	default:
+		return cached
+	}
+}
+
+// cleanupStored removes expired persisted conversations that are not cached,
+// e.g. ones left behind by a restart. Returns the ended conversations.
+func (m *Manager) cleanupStored(ctx context.Context, st store.Store) []*Conversation {
+	keys, err := st.List(ctx, conversationPrefix)
+	if err != nil {
+		return nil
+	}
+	var ended []*Conversation
+	for _, key := range keys {
+		c := &Conversation{}
+		if err := store.GetJSON(ctx, st, key, c); err != nil || !c.IsExpired() {
+			continue
+		}
+		memKey := m.keyOf(c)
+		m.mu.RLock()
+		_, cached := m.conversations[memKey]
+		m.mu.RUnlock()
+		if cached {
+			continue
+		}
+		if err := st.Delete(ctx, key); err == nil {
+			ended = append(ended, c)
+		}
+	}
+	return ended
+}
@@ -0,0 +1,20 @@
+package conv
+
+// MarkPreviewSafe marks step handlers, compute functions, and LLM completers
+// as free of side effects, e.g. handlers that only compute or look up values,
+// so they also run in flow previews. Others are skipped in previews.
+func (e *FlowEngine) MarkPreviewSafe(names ...string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, name := range names {
+		e.previewSafe[name] = true
+	}
+}
+
+// IsPreviewSafe returns true if a step handler, compute function, or LLM
+// completer runs in flow previews.
+func (e *FlowEngine) IsPreviewSafe(name string) bool {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.previewSafe[name]
+}
@@ -0,0 +1,258 @@
+package conv
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"go/format"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/0xVanfer/tg-listener/config"
+	"github.com/0xVanfer/tg-listener/core"
+)
+
+// ErrTypeMismatch is returned when a stored value doesn't convert to the type
+// declared in the flow's schema.
+var ErrTypeMismatch = errors.New("value does not match the declared type")
+
+// TypeError reports a value that doesn't convert to its key's declared type.
+// Its message is the user-facing validation error.
+type TypeError struct {
+	Key   string          // Data key
+	Type  config.DataType // Declared type
+	Value interface{}     // Rejected value
+	msg   string          // Validation error shown to the user
+}
+
+// Error returns the validation error shown to the user.
+func (e *TypeError) Error() string {
+	return e.msg
+}
+
+// Unwrap returns ErrTypeMismatch.
+func (e *TypeError) Unwrap() error {
+	return ErrTypeMismatch
+}
+
+// field returns the schema declaration of a key of a flow's data, or nil.
+func (e *FlowEngine) field(flowID, key string) *config.DataFieldConfig {
+	flow := e.GetFlow(flowID)
+	if flow == nil {
+		return nil
+	}
+	return flow.Field(key)
+}
+
+// typed converts a value stored under a key to the type declared in the
+// conversation's flow. Undeclared keys keep their values.
+func (e *FlowEngine) typed(ctx context.Context, conv *Conversation, key string, value interface{}) (interface{}, error) {
+	field := e.field(conv.FlowID, key)
+	if field == nil {
+		return value, nil
+	}
+	v, ok := convert(field.GetType(), value)
+	if !ok {
+		msgs := core.StringsFrom(ctx)
+		msg := msgs.InvalidValue
+		if t := field.GetType(); t == config.DataTypeNumber || t == config.DataTypeInt {
+			msg = msgs.InvalidNumber
+		}
+		return nil, &TypeError{Key: key, Type: field.GetType(), Value: value, msg: msg}
+	}
+	return v, nil
+}
+
+// convert converts a value to a data type, parsing text input.
+func convert(t config.DataType, value interface{}) (interface{}, bool) {
+	if value == nil {
+		return nil, true
+	}
+	s, isString := value.(string)
+	s = strings.TrimSpace(s)
+	switch t {
+	case config.DataTypeString:
+		if isString {
+			return value, true
+		}
+		if _, ok := value.(fmt.Stringer); ok {
+			return fmt.Sprint(value), true
+		}
+		return nil, false
+	case config.DataTypeNumber:
+		if isString {
+			f, err := strconv.ParseFloat(s, 64)
+			return f, err == nil
+		}
+		f, ok := toFloat(value)
+		return f, ok
+	case config.DataTypeInt:
+		if isString {
+			n, err := strconv.Atoi(s)
+			return n, err == nil
+		}
+		f, ok := toFloat(value)
+		if !ok || f != float64(int(f)) {
+			return nil, false
+		}
+		return int(f), true
+	case config.DataTypeBool:
+		if b, ok := value.(bool); ok {
+			return b, true
+		}
+		switch strings.ToLower(s) {
+		case "true", "yes", "1":
+			return true, isString
+		case "false", "no", "0":
+			return false, isString
+		}
+		return nil, false
+	case config.DataTypeTime:
+		if tm, ok := value.(time.Time); ok {
+			return tm, true
+		}
+		return parseTime(s)
+	case config.DataTypeList:
+		k := reflect.TypeOf(value).Kind()
+		return value, k == reflect.Slice || k == reflect.Array
+	case config.DataTypeMap:
+		return value, reflect.TypeOf(value).Kind() == reflect.Map
+	default:
+		return value, true
+	}
+}
+
+// toFloat converts a numeric value to float64.
+func toFloat(value interface{}) (float64, bool) {
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), true
+	}
+	return 0, false
+}
+
+// parseTime parses an RFC 3339 time or a "2006-01-02" date.
+func parseTime(s string) (interface{}, bool) {
+	for _, layout := range []string{time.RFC3339, time.DateOnly} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, true
+		}
+	}
+	return nil, false
+}
+
+// checkRequired returns the first required key of the conversation's flow
+// that is missing, with the step storing it, or "" if all are set.
+func (e *FlowEngine) checkRequired(conv *Conversation) (key, stepID string) {
+	flow := e.GetFlow(conv.FlowID)
+	if flow == nil {
+		return "", ""
+	}
+	for _, field := range flow.Schema {
+		if !field.Required {
+			continue
+		}
+		if v, ok := conv.Get(field.Key); ok && v != nil && v != "" {
+			continue
+		}
+		return field.Key, flow.StepStoring(field.Key)
+	}
+	return "", ""
+}
+
+// GenerateAccessors returns Go source declaring, for each flow with a
+// schema, a type with a typed getter per declared key, so handlers read
+// conversation data without type assertions or key typos. Run it from a
+// go:generate program and write the result to a file of package pkg.
+//
+// For a flow "order" declaring "amount" as a number, the generated code is:
+//
+//	type OrderData struct{ *conv.Conversation }
+//
+//	func (d OrderData) Amount() float64 { return d.GetFloat("amount") }
+func GenerateAccessors(pkg string, flows ...*config.FlowConfig) ([]byte, error) {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "// Code generated from the flow schemas by conv.GenerateAccessors. DO NOT EDIT.\n\n")
+	fmt.Fprintf(&buf, "package %s\n\n", pkg)
+
+	imports := `"github.com/0xVanfer/tg-listener/conv"`
+	for _, flow := range flows {
+		if slices.ContainsFunc(flow.Schema, func(f config.DataFieldConfig) bool { return f.GetType() == config.DataTypeTime }) {
+			imports = "\"time\"\n\n" + imports
+			break
+		}
+	}
+	fmt.Fprintf(&buf, "import (\n%s\n)\n", imports)
+
+	for _, flow := range flows {
+		if len(flow.Schema) == 0 {
+			continue
+		}
+		name := exportedName(flow.ID) + "Data"
+		fmt.Fprintf(&buf, "\n// %s reads the data of the %q flow.\n", name, flow.ID)
+		fmt.Fprintf(&buf, "type %s struct{ *conv.Conversation }\n", name)
+		for _, field := range flow.Schema {
+			goType, expr := accessor(field)
+			fmt.Fprintf(&buf, "\n// %s returns the %q value.\n", exportedName(field.Key), field.Key)
+			fmt.Fprintf(&buf, "func (d %s) %s() %s {\n", name, exportedName(field.Key), goType)
+			fmt.Fprintf(&buf, "\t%s\n}\n", fmt.Sprintf(expr, strconv.Quote(field.Key)))
+		}
+	}
+	return format.Source(buf.Bytes())
+}
+
+// accessor returns the Go type and getter body, with a %s verb for the key,
+// of a declared key.
+func accessor(field config.DataFieldConfig) (string, string) {
+	switch field.GetType() {
+	case config.DataTypeString:
+		return "string", "return d.GetString(%s)"
+	case config.DataTypeNumber:
+		return "float64", "return d.GetFloat(%s)"
+	case config.DataTypeInt:
+		return "int", "return d.GetInt(%s)"
+	case config.DataTypeBool:
+		return "bool", "return d.GetBool(%s)"
+	case config.DataTypeTime:
+		return "time.Time", "return d.GetTime(%s)"
+	case config.DataTypeList:
+		return "[]interface{}", "v, _ := d.Get(%s)\n\tl, _ := v.([]interface{})\n\treturn l"
+	case config.DataTypeMap:
+		return "map[string]interface{}", "v, _ := d.Get(%s)\n\tm, _ := v.(map[string]interface{})\n\treturn m"
+	default:
+		return "interface{}", "v, _ := d.Get(%s)\n\treturn v"
+	}
+}
+
+// exportedName converts a key such as "start_date" or "wallet.address" to
+// an exported Go name such as "StartDate" or "WalletAddress".
+func exportedName(key string) string {
+	var b strings.Builder
+	upper := true
+	for _, r := range key {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			upper = true
+			continue
+		}
+		if upper {
+			r = unicode.ToUpper(r)
+			upper = false
+		}
+		b.WriteRune(r)
+	}
+	name := b.String()
+	if name == "" || unicode.IsDigit(rune(name[0])) {
+		name = "X" + name
+	}
+	return name
+}
@@ -0,0 +1,85 @@
+package conv
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/0xVanfer/tg-listener/config"
+)
+
+// ErrHandlerTimeout is returned when a step handler does not return within its timeout.
+var ErrHandlerTimeout = errors.New("handler timed out")
+
+// handlerTimeout returns the configured timeout of a handler, or 0 if it has none.
+func (e *FlowEngine) handlerTimeout(name string) time.Duration {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	if e.config == nil || e.config.Bot == nil {
+		return 0
+	}
+	return e.config.Bot.Timeouts.Timeout(name)
+}
+
+// runWithTimeout calls fn with a context that expires after timeout and reports
+// whether fn returned in time. On timeout it returns without waiting; fn keeps
+// running in the background and should stop once its context is done.
+// A panic in fn is re-raised in the caller if fn returned in time and dropped
+// otherwise. A timeout of 0 or less calls fn directly.
+func runWithTimeout(ctx context.Context, timeout time.Duration, fn func(ctx context.Context)) bool {
+	if timeout <= 0 {
+		fn(ctx)
+		return true
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	done := make(chan any, 1)
+	go func() {
+		defer func() { done <- recover() }()
+		fn(ctx)
+	}()
+	select {
+	case p := <-done:
+		if p != nil {
+			panic(p)
+		}
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// cacheKeyboardData remembers the latest result of a keyboard provider.
+func (e *FlowEngine) cacheKeyboardData(providerName string, data []config.ButtonData) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.keyboardCache[providerName] = data
+}
+
+// keyboardFallback returns the buttons shown when a provider failed: its latest
+// non-empty result if there is one, otherwise the step keyboard's fallback buttons.
+func (e *FlowEngine) keyboardFallback(conv *Conversation, providerName string) []config.ButtonData {
+	e.mu.RLock()
+	cached := e.keyboardCache[providerName]
+	e.mu.RUnlock()
+	if len(cached) > 0 {
+		return cached
+	}
+	return e.fallbackButtons(conv)
+}
+
+// fallbackButtons returns the fallback buttons of the conversation's step keyboard.
+func (e *FlowEngine) fallbackButtons(conv *Conversation) []config.ButtonData {
+	step := e.GetStep(conv.FlowID, conv.StepID)
+	if step == nil || step.Keyboard == nil {
+		return nil
+	}
+	return step.Keyboard.FallbackButtons
+}
+
+// hasEmptyState returns true if the conversation's step keyboard has an empty state.
+func (e *FlowEngine) hasEmptyState(conv *Conversation) bool {
+	step := e.GetStep(conv.FlowID, conv.StepID)
+	return step != nil && step.Keyboard != nil && step.Keyboard.EmptyState != nil
+}
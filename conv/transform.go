@@ -0,0 +1,152 @@
+// Package conv provides the flow engine for executing conversation flows.
+package conv
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/sha3"
+)
+
+// Transform is a function type for transforming input before it is stored.
+// It receives the current value and the argument given in parentheses in the
+// step config (empty if none), and returns the transformed value.
+type Transform func(value interface{}, arg string) (interface{}, error)
+
+// Built-in transform names.
+const (
+	TransformTrim               = "trim"
+	TransformLower              = "lower"
+	TransformUpper              = "upper"
+	TransformCollapseWhitespace = "collapse_whitespace"
+	TransformParseNumber        = "parse_number"
+	TransformParseDate          = "parse_date"
+	TransformChecksumAddress    = "checksum_address"
+)
+
+// builtinTransforms returns the transforms available to every engine.
+func builtinTransforms() map[string]Transform {
+	return map[string]Transform{
+		TransformTrim:               stringTransform(strings.TrimSpace),
+		TransformLower:              stringTransform(strings.ToLower),
+		TransformUpper:              stringTransform(strings.ToUpper),
+		TransformCollapseWhitespace: stringTransform(func(s string) string { return strings.Join(strings.Fields(s), " ") }),
+		TransformParseNumber:        parseNumber,
+		TransformParseDate:          parseDate,
+		TransformChecksumAddress:    checksumAddress,
+	}
+}
+
+// stringTransform adapts a string function to a Transform.
+func stringTransform(fn func(string) string) Transform {
+	return func(value interface{}, _ string) (interface{}, error) {
+		return fn(formatValue(value)), nil
+	}
+}
+
+// parseNumber converts the value to a float64.
+// Surrounding whitespace, thousands separators, and underscores are ignored.
+func parseNumber(value interface{}, _ string) (interface{}, error) {
+	s := strings.TrimSpace(formatValue(value))
+	s = strings.NewReplacer(",", "", "_", "").Replace(s)
+	num, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return nil, errors.New("Please enter a valid number")
+	}
+	return num, nil
+}
+
+// parseDate converts the value to a time.Time using the Go layout in arg.
+// Defaults to "2006-01-02" if no layout is given.
+func parseDate(value interface{}, layout string) (interface{}, error) {
+	if layout == "" {
+		layout = time.DateOnly
+	}
+	t, err := time.Parse(layout, strings.TrimSpace(formatValue(value)))
+	if err != nil {
+		return nil, fmt.Errorf("Please enter a date in the format %s", layout)
+	}
+	return t, nil
+}
+
+// checksumAddress converts an Ethereum address to its EIP-55 checksum form.
+func checksumAddress(value interface{}, _ string) (interface{}, error) {
+	s := strings.TrimSpace(formatValue(value))
+	if len(s) != 42 || !strings.HasPrefix(strings.ToLower(s), "0x") {
+		return nil, errors.New("Please enter a valid Ethereum address")
+	}
+	addr := strings.ToLower(s[2:])
+	if _, err := hex.DecodeString(addr); err != nil {
+		return nil, errors.New("Please enter a valid Ethereum address")
+	}
+
+	hash := sha3.NewLegacyKeccak256()
+	hash.Write([]byte(addr))
+	digest := hex.EncodeToString(hash.Sum(nil))
+
+	out := []byte(addr)
+	for i, c := range out {
+		if c >= 'a' && c <= 'f' && digest[i] >= '8' {
+			out[i] = c - 'a' + 'A'
+		}
+	}
+	return "0x" + string(out), nil
+}
+
+// parseTransformSpec splits a transform spec like "parse_date(2006-01-02)"
+// into its name and argument.
+func parseTransformSpec(spec string) (name, arg string) {
+	spec = strings.TrimSpace(spec)
+	open := strings.IndexByte(spec, '(')
+	if open < 0 || !strings.HasSuffix(spec, ")") {
+		return spec, ""
+	}
+	return strings.TrimSpace(spec[:open]), spec[open+1 : len(spec)-1]
+}
+
+// RegisterTransform registers a custom transform by name.
+// A custom transform replaces a built-in one with the same name.
+func (e *FlowEngine) RegisterTransform(name string, transform Transform) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.transforms[name] = transform
+}
+
+// GetTransform retrieves a transform by name.
+func (e *FlowEngine) GetTransform(name string) Transform {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.transforms[name]
+}
+
+// TransformInput applies the current step's transforms to input in order.
+// Returns the input unchanged if the step has no transforms. Unknown transforms
+// are skipped; a failing transform returns its error for display to the user.
+func (e *FlowEngine) TransformInput(conv *Conversation, input string) (interface{}, error) {
+	step := e.GetStep(conv.FlowID, conv.StepID)
+	if step == nil || len(step.Transform) == 0 {
+		return input, nil
+	}
+
+	var value interface{} = input
+	for _, spec := range step.Transform {
+		name, arg := parseTransformSpec(spec)
+		transform := e.GetTransform(name)
+		if transform == nil {
+			continue
+		}
+		v, err := transform(value, arg)
+		if err != nil {
+			if step.Validation != nil && step.Validation.ErrorMsg != "" {
+				return nil, errors.New(step.Validation.ErrorMsg)
+			}
+			return nil, err
+		}
+		value = v
+	}
+	return value, nil
+}
@@ -0,0 +1,131 @@
+package conv
+
+import (
+	"sort"
+	"time"
+)
+
+// View is a read-only copy of a conversation at one point in time.
+// It shares no state with the conversation, so it can be read and kept
+// without locking while handlers keep changing the conversation.
+type View struct {
+	UserID        int64                  // User ID of the participant
+	ChatID        int64                  // Chat ID where the conversation takes place
+	TopicID       int                    // Topic ID for group topic support
+	FlowID        string                 // Flow ID being executed
+	StepID        string                 // Step ID within the flow
+	State         ConversationState      // Conversation state
+	Data          map[string]interface{} // Shallow copy of the collected data
+	KeyboardMsgID int                    // Message ID of the last keyboard message
+	InvalidInputs int                    // Consecutive invalid inputs on the current step
+	StepDeadline  time.Time              // When the current step times out; zero if it doesn't
+	CreatedAt     time.Time              // Timestamp when conversation was created
+	UpdatedAt     time.Time              // Timestamp of last update
+	ExpiresAt     time.Time              // Expiration timestamp for auto-cleanup
+	History       []HistoryEntry         // History of steps and inputs
+	Callers       []CallFrame            // Copies of the calling flows suspended by sub-flows, innermost last
+	Preview       bool                   // Whether the conversation is a sandboxed flow preview
+}
+
+// IsExpired checks if the conversation had expired when the view was taken
+// or has since.
+func (v View) IsExpired() bool {
+	return time.Now().After(v.ExpiresAt)
+}
+
+// RootFlowID returns the flow the conversation was started with.
+func (v View) RootFlowID() string {
+	if len(v.Callers) > 0 {
+		return v.Callers[0].FlowID
+	}
+	return v.FlowID
+}
+
+// View returns a read-only copy of the conversation.
+// Thread-safe for concurrent access.
+func (c *Conversation) View() View {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	callers := make([]CallFrame, len(c.Callers))
+	for i, frame := range c.Callers {
+		frame.Data = copyMap(frame.Data)
+		callers[i] = frame
+	}
+	return View{
+		UserID:        c.UserID,
+		ChatID:        c.ChatID,
+		TopicID:       c.TopicID,
+		FlowID:        c.FlowID,
+		StepID:        c.StepID,
+		State:         c.State,
+		Data:          copyMap(c.Data),
+		KeyboardMsgID: c.KeyboardMsgID,
+		InvalidInputs: c.InvalidInputs,
+		StepDeadline:  c.StepDeadline,
+		CreatedAt:     c.CreatedAt,
+		UpdatedAt:     c.UpdatedAt,
+		ExpiresAt:     c.ExpiresAt,
+		History:       append([]HistoryEntry(nil), c.History...),
+		Callers:       callers,
+		Preview:       c.Preview,
+	}
+}
+
+// copyMap returns a shallow copy of a data map, nil if it is nil.
+func copyMap(m map[string]interface{}) map[string]interface{} {
+	if m == nil {
+		return nil
+	}
+	cp := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		cp[k] = v
+	}
+	return cp
+}
+
+// active returns the conversations cached by the manager, ordered by user,
+// chat, and topic. The manager lock is only held while collecting them.
+func (m *Manager) active() []*Conversation {
+	m.mu.RLock()
+	convs := make([]*Conversation, 0, len(m.conversations))
+	for _, c := range m.conversations {
+		convs = append(convs, c)
+	}
+	m.mu.RUnlock()
+
+	sort.Slice(convs, func(i, j int) bool {
+		a, b := convs[i], convs[j]
+		if a.UserID != b.UserID {
+			return a.UserID < b.UserID
+		}
+		if a.ChatID != b.ChatID {
+			return a.ChatID < b.ChatID
+		}
+		return a.TopicID < b.TopicID
+	})
+	return convs
+}
+
+// Range calls fn with a view of each active conversation, ordered by user,
+// chat, and topic, until fn returns false. No lock is held while fn runs, so
+// fn may do I/O, e.g. send a debug listing, and may call the manager.
+// Conversations started or ended during the iteration may be missed; only
+// conversations cached by this instance are visited.
+func (m *Manager) Range(fn func(v View) bool) {
+	for _, c := range m.active() {
+		if !fn(c.View()) {
+			return
+		}
+	}
+}
+
+// Snapshot returns views of all active conversations, ordered by user, chat,
+// and topic.
+func (m *Manager) Snapshot() []View {
+	convs := m.active()
+	views := make([]View, len(convs))
+	for i, c := range convs {
+		views[i] = c.View()
+	}
+	return views
+}
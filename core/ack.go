@@ -0,0 +1,148 @@
+package core
+
+import (
+	"context"
+	"sync"
+
+	"github.com/mymmrac/telego"
+)
+
+// Default labels shown on acknowledged buttons.
+const (
+	// AckPending is shown on a pressed button while its action runs.
+	AckPending = "⏳"
+
+	// AckDone is shown on a pressed button once its action has succeeded.
+	AckDone = "✅"
+)
+
+// ButtonAck is the instant feedback shown on a pressed inline button while the
+// action behind it runs. Settle it once with Restore, Done, or Replace.
+type ButtonAck struct {
+	bot       *Bot                         // Bot used to edit the keyboard
+	chatID    int64                        // Chat of the keyboard message
+	messageID int                          // Keyboard message ID
+	keyboard  *telego.InlineKeyboardMarkup // Keyboard as it was when pressed
+	row, col  int                          // Position of the pressed button
+	settled   bool                         // Whether the keyboard was restored or replaced
+
+	mu sync.Mutex // Mutex for thread-safe settling
+}
+
+// AckButton answers a callback query and immediately replaces the text of the
+// pressed button with label (AckPending if empty), so slow actions give instant
+// feedback. The rest of the keyboard stays as it was.
+// Returns nil if the message or the pressed button can't be found; the methods
+// of a nil ButtonAck do nothing.
+func (b *Bot) AckButton(ctx context.Context, query telego.CallbackQuery, label string) *ButtonAck {
+	_ = b.AnswerCallback(ctx, query.ID, "")
+
+	msg, ok := query.Message.(*telego.Message)
+	if !ok || msg.ReplyMarkup == nil {
+		return nil
+	}
+	// Long callback data was sent as a token; the query carries the resolved data
+	sent, err := b.shortenData(ctx, query.Data)
+	if err != nil {
+		sent = query.Data
+	}
+	a := &ButtonAck{bot: b, chatID: msg.Chat.ID, messageID: msg.MessageID, keyboard: msg.ReplyMarkup, row: -1}
+	for i, row := range msg.ReplyMarkup.InlineKeyboard {
+		for j, btn := range row {
+			if btn.CallbackData == query.Data || btn.CallbackData == sent {
+				a.row, a.col = i, j
+				break
+			}
+		}
+		if a.row >= 0 {
+			break
+		}
+	}
+	if a.row < 0 {
+		return nil
+	}
+
+	if label == "" {
+		label = AckPending
+	}
+	_, _ = b.EditKeyboard(ctx, a.chatID, a.messageID, a.withLabel(label))
+	return a
+}
+
+// withLabel returns a copy of the original keyboard with the pressed button relabeled.
+func (a *ButtonAck) withLabel(label string) *telego.InlineKeyboardMarkup {
+	rows := make([][]telego.InlineKeyboardButton, len(a.keyboard.InlineKeyboard))
+	for i, row := range a.keyboard.InlineKeyboard {
+		rows[i] = append([]telego.InlineKeyboardButton(nil), row...)
+	}
+	rows[a.row][a.col].Text = label
+	return &telego.InlineKeyboardMarkup{InlineKeyboard: rows}
+}
+
+// settle marks the acknowledgment settled, reporting whether it was pending.
+func (a *ButtonAck) settle() bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.settled {
+		return false
+	}
+	a.settled = true
+	return true
+}
+
+// Settled reports whether the keyboard has been restored or replaced.
+func (a *ButtonAck) Settled() bool {
+	if a == nil {
+		return true
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.settled
+}
+
+// Restore puts the original keyboard back, e.g. after the action failed.
+func (a *ButtonAck) Restore(ctx context.Context) error {
+	if a == nil || !a.settle() {
+		return nil
+	}
+	_, err := a.bot.EditKeyboard(ctx, a.chatID, a.messageID, a.keyboard)
+	return err
+}
+
+// Done shows label (AckDone if empty) on the pressed button and keeps the rest
+// of the original keyboard.
+func (a *ButtonAck) Done(ctx context.Context, label string) error {
+	if a == nil || !a.settle() {
+		return nil
+	}
+	if label == "" {
+		label = AckDone
+	}
+	_, err := a.bot.EditKeyboard(ctx, a.chatID, a.messageID, a.withLabel(label))
+	return err
+}
+
+// Replace swaps the keyboard for a new one. Pass nil to remove it.
+// Call it instead of editing the keyboard message directly, so the
+// acknowledgment isn't restored over the new keyboard.
+func (a *ButtonAck) Replace(ctx context.Context, keyboard *telego.InlineKeyboardMarkup) error {
+	if a == nil || !a.settle() {
+		return nil
+	}
+	_, err := a.bot.EditKeyboard(ctx, a.chatID, a.messageID, keyboard)
+	return err
+}
+
+// ackKey is the context key holding the acknowledgment of the pressed button.
+type ackKey struct{}
+
+// WithButtonAck returns a context carrying a button acknowledgment.
+func WithButtonAck(ctx context.Context, a *ButtonAck) context.Context {
+	return context.WithValue(ctx, ackKey{}, a)
+}
+
+// ButtonAckFrom returns the button acknowledgment carried by ctx, or nil.
+func ButtonAckFrom(ctx context.Context) *ButtonAck {
+	a, _ := ctx.Value(ackKey{}).(*ButtonAck)
+	return a
+}
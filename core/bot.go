@@ -9,6 +9,7 @@ import (
 	"sync"
 
 	"github.com/mymmrac/telego"
+	ta "github.com/mymmrac/telego/telegoapi"
 	"github.com/mymmrac/telego/telegoutil"
 )
 
@@ -37,19 +38,42 @@ func NewChat(chatID int64, topicID int) Chat {
 type Bot struct {
 	bot      *telego.Bot  // Underlying telego bot instance
 	authFunc AuthFunc     // Authentication function for user filtering
-	mu       sync.RWMutex // Mutex for thread-safe auth function access
+	username string       // Cached bot username for deep links
+	onSent   SentFunc     // Observer of messages sent by the bot
+	onFail   FailureFunc  // Observer of failed requests
+	onReq    RequestFunc  // Observer of every request attempt
+	limiter  *Limiter     // Send queue pacing outgoing messages
+	payloads PayloadStore // Store shortening long callback data; nil sends it unchanged
+	mu       sync.RWMutex // Mutex for thread-safe auth function and username access
 }
 
+// SentFunc observes messages sent by the bot, e.g. to log them for retention.
+type SentFunc func(msg *telego.Message)
+
+// FailureFunc observes requests to a chat that failed after all retries, e.g.
+// to warn about a failing API.
+type FailureFunc func(ctx context.Context, chatID int64, err error)
+
+// RequestFunc observes every attempt of a request to a chat, with its error
+// or nil on success, e.g. to watch API error rates.
+type RequestFunc func(ctx context.Context, chatID int64, err error)
+
+// MaxDeleteBatch is the maximum number of messages deleted per deleteMessages request.
+const MaxDeleteBatch = 100
+
 // NewBot creates a new Bot instance with the given token.
 // Returns an error if the token is invalid or bot creation fails.
+// Requests made with a context from WithCapture are answered without
+// reaching Telegram.
 func NewBot(token string) (*Bot, error) {
-	bot, err := telego.NewBot(token)
+	bot, err := telego.NewBot(token, telego.WithAPICaller(captureCaller{next: ta.DefaultFastHTTPCaller}))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create bot: %w", err)
 	}
 
 	return &Bot{
-		bot: bot,
+		bot:     bot,
+		limiter: NewLimiter(Limits{}),
 	}, nil
 }
 
@@ -85,6 +109,7 @@ func (b *Bot) Telego() *telego.Bot {
 
 // SendMessage sends a text message to the specified chat.
 // Supports MessageThreadID for group topics and message entities for formatting.
+// Without entities, the text is parsed in the parse mode of ctx, see WithParseMode.
 // Link previews are disabled by default following Telegram best practices.
 func (b *Bot) SendMessage(ctx context.Context, chatID int64, topicID int, text string, entities ...telego.MessageEntity) (*telego.Message, error) {
 	if b.bot == nil {
@@ -94,7 +119,7 @@ func (b *Bot) SendMessage(ctx context.Context, chatID int64, topicID int, text s
 	params := &telego.SendMessageParams{
 		ChatID:    telegoutil.ID(chatID),
 		Text:      text,
-		ParseMode: "", // Don't set ParseMode when using entities
+		ParseMode: parseMode(ctx, entities),
 		LinkPreviewOptions: &telego.LinkPreviewOptions{
 			IsDisabled: true, // Disable link preview
 		},
@@ -108,7 +133,105 @@ func (b *Bot) SendMessage(ctx context.Context, chatID int64, topicID int, text s
 		params.Entities = entities
 	}
 
-	return b.bot.SendMessage(ctx, params)
+	return b.sent(b.paced(ctx, chatID, func() (*telego.Message, error) {
+		return b.bot.SendMessage(ctx, params)
+	}))
+}
+
+// SetSentObserver sets a function called with every message sent through
+// the Send* methods. Pass nil to remove the observer.
+func (b *Bot) SetSentObserver(fn SentFunc) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.onSent = fn
+}
+
+// SetFailureObserver sets a function called for every request to a chat that
+// failed after all retries.
+func (b *Bot) SetFailureObserver(fn FailureFunc) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.onFail = fn
+}
+
+// SetRequestObserver sets a function called for every attempt of a request
+// to a chat sent through the send queue, retries included.
+func (b *Bot) SetRequestObserver(fn RequestFunc) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.onReq = fn
+}
+
+// requested passes a request attempt to the request observer. Captured
+// requests, which never reach Telegram, are not passed.
+func (b *Bot) requested(ctx context.Context, chatID int64, err error) {
+	if captured(ctx) != nil {
+		return
+	}
+	b.mu.RLock()
+	fn := b.onReq
+	b.mu.RUnlock()
+	if fn != nil {
+		fn(ctx, chatID, err)
+	}
+}
+
+// failed passes a failed request to the failure observer.
+func (b *Bot) failed(ctx context.Context, chatID int64, err error) {
+	b.mu.RLock()
+	fn := b.onFail
+	b.mu.RUnlock()
+	if fn != nil {
+		fn(ctx, chatID, err)
+	}
+}
+
+// sent passes a successfully sent message to the observer. Captured
+// messages, which have ID 0, are not passed.
+func (b *Bot) sent(msg *telego.Message, err error) (*telego.Message, error) {
+	if err != nil || msg == nil || msg.MessageID == 0 {
+		return msg, err
+	}
+	b.mu.RLock()
+	fn := b.onSent
+	b.mu.RUnlock()
+	if fn != nil {
+		fn(msg)
+	}
+	return msg, nil
+}
+
+// SendReply sends a text message as a reply to another message in the same chat.
+// If the replied-to message no longer exists, the message is sent without the reply.
+func (b *Bot) SendReply(ctx context.Context, chatID int64, topicID int, replyTo int, text string, entities ...telego.MessageEntity) (*telego.Message, error) {
+	if b.bot == nil {
+		return nil, nil
+	}
+
+	params := &telego.SendMessageParams{
+		ChatID:    telegoutil.ID(chatID),
+		Text:      text,
+		ParseMode: parseMode(ctx, entities),
+		LinkPreviewOptions: &telego.LinkPreviewOptions{
+			IsDisabled: true,
+		},
+		ReplyParameters: &telego.ReplyParameters{
+			MessageID:                replyTo,
+			AllowSendingWithoutReply: true,
+		},
+	}
+
+	if topicID > 0 {
+		params.MessageThreadID = topicID
+	}
+
+	if len(entities) > 0 {
+		params.Entities = entities
+	}
+
+	return b.sent(b.paced(ctx, chatID, func() (*telego.Message, error) {
+		return b.bot.SendMessage(ctx, params)
+	}))
 }
 
 // SendMessageWithKeyboard sends a message with an inline keyboard.
@@ -121,7 +244,7 @@ func (b *Bot) SendMessageWithKeyboard(ctx context.Context, chatID int64, topicID
 	params := &telego.SendMessageParams{
 		ChatID:    telegoutil.ID(chatID),
 		Text:      text,
-		ParseMode: "", // Don't set ParseMode when using entities
+		ParseMode: parseMode(ctx, entities),
 		LinkPreviewOptions: &telego.LinkPreviewOptions{
 			IsDisabled: true,
 		},
@@ -136,10 +259,152 @@ func (b *Bot) SendMessageWithKeyboard(ctx context.Context, chatID int64, topicID
 	}
 
 	if keyboard != nil {
-		params.ReplyMarkup = keyboard
+		markup, err := b.shortenKeyboard(ctx, keyboard)
+		if err != nil {
+			return nil, err
+		}
+		params.ReplyMarkup = markup
+	}
+
+	return b.sent(b.paced(ctx, chatID, func() (*telego.Message, error) {
+		return b.bot.SendMessage(ctx, params)
+	}))
+}
+
+// SendLongMessage sends text of any length, split with SplitMessage into
+// parts within Telegram's limit and sent in order. The keyboard, if any, is
+// attached to the last part. Returns the IDs of the sent messages in order;
+// on error, those of the parts sent before it.
+func (b *Bot) SendLongMessage(ctx context.Context, chatID int64, topicID int, text string, keyboard *telego.InlineKeyboardMarkup, entities ...telego.MessageEntity) ([]int, error) {
+	parts := SplitMessage(text, entities)
+	ids := make([]int, 0, len(parts))
+	for i, part := range parts {
+		var kb *telego.InlineKeyboardMarkup
+		if i == len(parts)-1 {
+			kb = keyboard
+		}
+		msg, err := b.SendMessageWithKeyboard(ctx, chatID, topicID, part.Text, kb, part.Entities...)
+		if err != nil {
+			return ids, err
+		}
+		if msg != nil {
+			ids = append(ids, msg.MessageID)
+		}
+	}
+	return ids, nil
+}
+
+// SendPhoto sends a photo with an optional caption to the specified chat.
+// Use telegoutil.FileFromBytes to upload generated images or FileFromID to resend.
+func (b *Bot) SendPhoto(ctx context.Context, chatID int64, topicID int, photo telego.InputFile, caption string, entities ...telego.MessageEntity) (*telego.Message, error) {
+	if b.bot == nil {
+		return nil, nil
+	}
+
+	params := &telego.SendPhotoParams{
+		ChatID:  telegoutil.ID(chatID),
+		Photo:   photo,
+		Caption: caption,
+	}
+
+	if topicID > 0 {
+		params.MessageThreadID = topicID
+	}
+
+	if len(entities) > 0 {
+		params.CaptionEntities = entities
+	}
+
+	return b.sent(b.paced(ctx, chatID, func() (*telego.Message, error) {
+		return b.bot.SendPhoto(ctx, params)
+	}))
+}
+
+// SendPhotoWithKeyboard sends a photo with a caption and an inline keyboard.
+func (b *Bot) SendPhotoWithKeyboard(ctx context.Context, chatID int64, topicID int, photo telego.InputFile, caption string, keyboard *telego.InlineKeyboardMarkup, entities ...telego.MessageEntity) (*telego.Message, error) {
+	if b.bot == nil {
+		return nil, nil
+	}
+
+	params := &telego.SendPhotoParams{
+		ChatID:  telegoutil.ID(chatID),
+		Photo:   photo,
+		Caption: caption,
+	}
+
+	if topicID > 0 {
+		params.MessageThreadID = topicID
+	}
+
+	if len(entities) > 0 {
+		params.CaptionEntities = entities
+	}
+
+	if keyboard != nil {
+		markup, err := b.shortenKeyboard(ctx, keyboard)
+		if err != nil {
+			return nil, err
+		}
+		params.ReplyMarkup = markup
 	}
 
-	return b.bot.SendMessage(ctx, params)
+	return b.sent(b.paced(ctx, chatID, func() (*telego.Message, error) {
+		return b.bot.SendPhoto(ctx, params)
+	}))
+}
+
+// EditPhoto replaces the photo, caption, and keyboard of an existing photo message.
+func (b *Bot) EditPhoto(ctx context.Context, chatID int64, messageID int, photo telego.InputFile, caption string, keyboard *telego.InlineKeyboardMarkup, entities ...telego.MessageEntity) (*telego.Message, error) {
+	if b.bot == nil {
+		return nil, nil
+	}
+
+	markup, err := b.shortenKeyboard(ctx, keyboard)
+	if err != nil {
+		return nil, err
+	}
+
+	params := &telego.EditMessageMediaParams{
+		ChatID:    telegoutil.ID(chatID),
+		MessageID: messageID,
+		Media: &telego.InputMediaPhoto{
+			Type:            telego.MediaTypePhoto,
+			Media:           photo,
+			Caption:         caption,
+			CaptionEntities: entities,
+		},
+		ReplyMarkup: markup,
+	}
+
+	return b.paced(ctx, chatID, func() (*telego.Message, error) {
+		return b.bot.EditMessageMedia(ctx, params)
+	})
+}
+
+// SendDocument sends a file as a document with an optional caption to the specified chat.
+// Use telegoutil.FileFromBytes to upload generated files.
+func (b *Bot) SendDocument(ctx context.Context, chatID int64, topicID int, document telego.InputFile, caption string, entities ...telego.MessageEntity) (*telego.Message, error) {
+	if b.bot == nil {
+		return nil, nil
+	}
+
+	params := &telego.SendDocumentParams{
+		ChatID:   telegoutil.ID(chatID),
+		Document: document,
+		Caption:  caption,
+	}
+
+	if topicID > 0 {
+		params.MessageThreadID = topicID
+	}
+
+	if len(entities) > 0 {
+		params.CaptionEntities = entities
+	}
+
+	return b.sent(b.paced(ctx, chatID, func() (*telego.Message, error) {
+		return b.bot.SendDocument(ctx, params)
+	}))
 }
 
 // EditMessage edits the text of an existing message.
@@ -153,7 +418,7 @@ func (b *Bot) EditMessage(ctx context.Context, chatID int64, messageID int, text
 		ChatID:    telegoutil.ID(chatID),
 		MessageID: messageID,
 		Text:      text,
-		ParseMode: "",
+		ParseMode: parseMode(ctx, entities),
 		LinkPreviewOptions: &telego.LinkPreviewOptions{
 			IsDisabled: true,
 		},
@@ -163,7 +428,9 @@ func (b *Bot) EditMessage(ctx context.Context, chatID int64, messageID int, text
 		params.Entities = entities
 	}
 
-	return b.bot.EditMessageText(ctx, params)
+	return b.paced(ctx, chatID, func() (*telego.Message, error) {
+		return b.bot.EditMessageText(ctx, params)
+	})
 }
 
 // EditMessageWithKeyboard edits both text and keyboard of an existing message.
@@ -177,7 +444,7 @@ func (b *Bot) EditMessageWithKeyboard(ctx context.Context, chatID int64, message
 		ChatID:    telegoutil.ID(chatID),
 		MessageID: messageID,
 		Text:      text,
-		ParseMode: "",
+		ParseMode: parseMode(ctx, entities),
 		LinkPreviewOptions: &telego.LinkPreviewOptions{
 			IsDisabled: true,
 		},
@@ -188,10 +455,16 @@ func (b *Bot) EditMessageWithKeyboard(ctx context.Context, chatID int64, message
 	}
 
 	if keyboard != nil {
-		params.ReplyMarkup = keyboard
+		markup, err := b.shortenKeyboard(ctx, keyboard)
+		if err != nil {
+			return nil, err
+		}
+		params.ReplyMarkup = markup
 	}
 
-	return b.bot.EditMessageText(ctx, params)
+	return b.paced(ctx, chatID, func() (*telego.Message, error) {
+		return b.bot.EditMessageText(ctx, params)
+	})
 }
 
 // EditKeyboard edits only the keyboard of an existing message.
@@ -201,13 +474,20 @@ func (b *Bot) EditKeyboard(ctx context.Context, chatID int64, messageID int, key
 		return nil, nil
 	}
 
+	markup, err := b.shortenKeyboard(ctx, keyboard)
+	if err != nil {
+		return nil, err
+	}
+
 	params := &telego.EditMessageReplyMarkupParams{
 		ChatID:      telegoutil.ID(chatID),
 		MessageID:   messageID,
-		ReplyMarkup: keyboard,
+		ReplyMarkup: markup,
 	}
 
-	return b.bot.EditMessageReplyMarkup(ctx, params)
+	return b.paced(ctx, chatID, func() (*telego.Message, error) {
+		return b.bot.EditMessageReplyMarkup(ctx, params)
+	})
 }
 
 // DeleteMessage deletes a message from the chat.
@@ -222,6 +502,28 @@ func (b *Bot) DeleteMessage(ctx context.Context, chatID int64, messageID int) er
 	})
 }
 
+// DeleteMessages deletes multiple messages in a chat, in batches of MaxDeleteBatch.
+// Messages that can't be found are skipped by Telegram. Note that outside of
+// channels and chats where the bot is an admin, only messages sent less than
+// 48 hours ago can be deleted.
+func (b *Bot) DeleteMessages(ctx context.Context, chatID int64, messageIDs []int) error {
+	if b.bot == nil {
+		return nil
+	}
+
+	for start := 0; start < len(messageIDs); start += MaxDeleteBatch {
+		end := min(start+MaxDeleteBatch, len(messageIDs))
+		err := b.bot.DeleteMessages(ctx, &telego.DeleteMessagesParams{
+			ChatID:     telegoutil.ID(chatID),
+			MessageIDs: messageIDs[start:end],
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // AnswerCallback responds to a callback query.
 // Must be called for every callback query to prevent loading indicators.
 func (b *Bot) AnswerCallback(ctx context.Context, callbackID string, text string) error {
@@ -261,6 +563,15 @@ func (b *Bot) SetMyCommands(ctx context.Context, commands []telego.BotCommand) e
 	})
 }
 
+// DeleteMyCommands removes the bot's command list from Telegram.
+func (b *Bot) DeleteMyCommands(ctx context.Context) error {
+	if b.bot == nil {
+		return nil
+	}
+
+	return b.bot.DeleteMyCommands(ctx, nil)
+}
+
 // GetMe retrieves information about the bot itself.
 func (b *Bot) GetMe(ctx context.Context) (*telego.User, error) {
 	if b.bot == nil {
@@ -269,6 +580,28 @@ func (b *Bot) GetMe(ctx context.Context) (*telego.User, error) {
 	return b.bot.GetMe(ctx)
 }
 
+// IsChatMember returns true if a user is in a chat: its owner, an admin, a
+// member, or a restricted member. Users who left or were banned are not.
+func (b *Bot) IsChatMember(ctx context.Context, chatID, userID int64) (bool, error) {
+	if b.bot == nil {
+		return false, nil
+	}
+	member, err := b.bot.GetChatMember(ctx, &telego.GetChatMemberParams{
+		ChatID: telegoutil.ID(chatID),
+		UserID: userID,
+	})
+	if err != nil {
+		return false, err
+	}
+	switch m := member.(type) {
+	case *telego.ChatMemberOwner, *telego.ChatMemberAdministrator, *telego.ChatMemberMember:
+		return true, nil
+	case *telego.ChatMemberRestricted:
+		return m.IsMember, nil
+	}
+	return false, nil
+}
+
 // SendTo sends a message to the specified Chat.
 // Convenience method that accepts a Chat struct.
 func (b *Bot) SendTo(ctx context.Context, chat Chat, text string, entities ...telego.MessageEntity) (*telego.Message, error) {
@@ -4,9 +4,12 @@ package core
 import (
 	"fmt"
 	"strings"
+	"time"
 	"unicode/utf16"
 
 	"github.com/mymmrac/telego"
+
+	"github.com/0xVanfer/tg-listener/tz"
 )
 
 // Builder is a message builder for constructing formatted Telegram messages.
@@ -15,15 +18,45 @@ import (
 type Builder struct {
 	text     strings.Builder        // Text content accumulator
 	entities []telego.MessageEntity // Formatting entities
+	locale   Locale                 // Locale of numbers, amounts, and dates
+	location *time.Location         // Time zone of dates; nil is UTC
+	strings  Strings                // Built-in texts, such as the separator line
+	tr       Translator             // Translator of Tr; nil shows keys
 }
 
 // NewBuilder creates a new message builder instance.
 func NewBuilder() *Builder {
 	return &Builder{
 		entities: make([]telego.MessageEntity, 0),
+		locale:   DefaultLocale,
+		strings:  DefaultStrings,
 	}
 }
 
+// WithLocale sets the locale used by Number, Money, and DateTime.
+func (b *Builder) WithLocale(l Locale) *Builder {
+	b.locale = l
+	return b
+}
+
+// WithStrings sets the built-in texts used by Separator.
+func (b *Builder) WithStrings(s Strings) *Builder {
+	b.strings = s
+	return b
+}
+
+// WithTranslator sets the translator used by Tr.
+func (b *Builder) WithTranslator(t Translator) *Builder {
+	b.tr = t
+	return b
+}
+
+// In sets the time zone used by DateTime.
+func (b *Builder) In(loc *time.Location) *Builder {
+	b.location = loc
+	return b
+}
+
 // getCurrentOffset returns the current UTF-16 offset.
 // Telegram uses UTF-16 for entity offset calculation.
 func (b *Builder) getCurrentOffset() int {
@@ -211,7 +244,77 @@ func (b *Builder) NumberedList(items ...string) *Builder {
 
 // Separator appends a horizontal separator line.
 func (b *Builder) Separator() *Builder {
-	b.Line("━━━━━━━━━━━━━━━")
+	b.Line(b.strings.Separator)
+	return b
+}
+
+// Time appends a time formatted in a time zone, e.g. "Mar 3 14:30 CET".
+// A nil zone is UTC; use Wrapper.UserLocation for a user's zone.
+func (b *Builder) Time(t time.Time, loc *time.Location) *Builder {
+	b.Text(tz.Format(t, loc))
+	return b
+}
+
+// Symbol appends the symbol with a semantic name from the Symbols catalog,
+// e.g. "status.ok" or "flag.de". Unknown names append nothing.
+func (b *Builder) Symbol(name string) *Builder {
+	b.Text(Symbol(name))
+	return b
+}
+
+// Flag appends the flag emoji of an ISO 3166-1 alpha-2 country code.
+func (b *Builder) Flag(isoCode string) *Builder {
+	b.Text(Flag(isoCode))
+	return b
+}
+
+// Tr appends the message with a key in the builder's language, formatted with
+// args as by fmt.Sprintf if any are given. Without a translator, or if no
+// catalog has the key, the key itself is appended.
+func (b *Builder) Tr(key string, args ...interface{}) *Builder {
+	msg, ok := "", false
+	if b.tr != nil {
+		msg, ok = b.tr(key)
+	}
+	if !ok {
+		msg = key
+	}
+	if len(args) > 0 {
+		msg = fmt.Sprintf(msg, args...)
+	}
+	b.Text(msg)
+	return b
+}
+
+// Number appends a number with the locale's separators, e.g. "1,234.5" or "1.234,5".
+func (b *Builder) Number(n float64) *Builder {
+	b.Text(b.locale.FormatNumber(n, -1))
+	return b
+}
+
+// Money appends an amount in a currency given by its ISO code, with the
+// currency's fraction digits, e.g. "$1,234.50" or "1.234,50 €".
+func (b *Builder) Money(amount float64, currency string) *Builder {
+	b.Text(b.locale.FormatMoney(amount, currency))
+	return b
+}
+
+// DateTime appends a date and time in the locale's format and the builder's
+// time zone, e.g. "Mar 3, 2026 14:30 CET" or "03.03.2026 14:30 CET".
+func (b *Builder) DateTime(t time.Time) *Builder {
+	b.Text(b.locale.FormatDateTime(t, b.location))
+	return b
+}
+
+// Append appends preformatted text, shifting its entities to the current offset.
+// Use it to embed the output of another Builder's Build.
+func (b *Builder) Append(text string, entities []telego.MessageEntity) *Builder {
+	offset := b.getCurrentOffset()
+	for _, e := range entities {
+		e.Offset += offset
+		b.entities = append(b.entities, e)
+	}
+	b.text.WriteString(text)
 	return b
 }
 
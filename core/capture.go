@@ -0,0 +1,138 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	ta "github.com/mymmrac/telego/telegoapi"
+)
+
+// APICall is a Telegram API request recorded by a Capture.
+type APICall struct {
+	Method string         `json:"method"`           // API method, e.g. "sendMessage"
+	Params map[string]any `json:"params,omitempty"` // Request parameters; nil for file uploads
+}
+
+// Capture records the Telegram API requests made with a context, see
+// WithCapture and WithRecording. Read requests (get* methods) are neither
+// recorded nor captured.
+type Capture struct {
+	deliver bool       // Whether recorded requests are still sent to Telegram
+	calls   []APICall  // Recorded requests, in the order they were made
+	mu      sync.Mutex // Mutex for thread-safe call access
+}
+
+// captureCtxKey is the context key of the active Capture.
+type captureCtxKey struct{}
+
+// WithCapture returns a context whose requests are recorded instead of sent:
+// they succeed with placeholder results, and sent messages have ID 0. Use it
+// to run code against production updates without it reaching users.
+func WithCapture(ctx context.Context) (context.Context, *Capture) {
+	c := &Capture{}
+	return context.WithValue(ctx, captureCtxKey{}, c), c
+}
+
+// WithRecording returns a context whose requests are sent as usual and
+// recorded as well.
+func WithRecording(ctx context.Context) (context.Context, *Capture) {
+	c := &Capture{deliver: true}
+	return context.WithValue(ctx, captureCtxKey{}, c), c
+}
+
+// Calls returns the requests recorded so far.
+func (c *Capture) Calls() []APICall {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]APICall(nil), c.calls...)
+}
+
+// record adds a request to the capture.
+func (c *Capture) record(call APICall) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.calls = append(c.calls, call)
+}
+
+// captured returns the capture of ctx if its requests must not be sent.
+func captured(ctx context.Context) *Capture {
+	c, _ := ctx.Value(captureCtxKey{}).(*Capture)
+	if c == nil || c.deliver {
+		return nil
+	}
+	return c
+}
+
+// captureCaller records the requests of contexts with a Capture before
+// passing them on, or answers them itself if they are captured.
+type captureCaller struct {
+	next ta.Caller // Caller sending requests to Telegram
+}
+
+// Call implements ta.Caller.
+func (cc captureCaller) Call(ctx context.Context, url string, data *ta.RequestData) (*ta.Response, error) {
+	c, _ := ctx.Value(captureCtxKey{}).(*Capture)
+	method := path.Base(url)
+	if c == nil || strings.HasPrefix(method, "get") {
+		return cc.next.Call(ctx, url, data)
+	}
+
+	var params map[string]any
+	if data != nil && data.Buffer != nil && strings.HasPrefix(data.ContentType, "application/json") {
+		_ = json.Unmarshal(data.Buffer.Bytes(), &params)
+	}
+	c.record(APICall{Method: method, Params: params})
+	if c.deliver {
+		return cc.next.Call(ctx, url, data)
+	}
+	return &ta.Response{Ok: true, Result: placeholderResult(method, params)}, nil
+}
+
+// placeholderResult returns the result of a captured request: a message for
+// methods sending or editing one, true for everything else.
+func placeholderResult(method string, params map[string]any) json.RawMessage {
+	switch {
+	case method == "sendChatAction":
+		return json.RawMessage("true")
+	case method == "copyMessage":
+		return json.RawMessage(`{"message_id":0}`)
+	case method == "copyMessages" || method == "forwardMessages":
+		return json.RawMessage("[]")
+	case method == "sendMediaGroup":
+		return json.RawMessage("[" + string(placeholderMessage(params)) + "]")
+	case strings.HasPrefix(method, "send") || strings.HasPrefix(method, "edit") || method == "forwardMessage":
+		return placeholderMessage(params)
+	default:
+		return json.RawMessage("true")
+	}
+}
+
+// placeholderMessage returns a message standing for one sent or edited by a
+// captured request.
+func placeholderMessage(params map[string]any) json.RawMessage {
+	msg := map[string]any{
+		"message_id": params["message_id"],
+		"date":       time.Now().Unix(),
+		"text":       params["text"],
+		"caption":    params["caption"],
+	}
+	if msg["message_id"] == nil {
+		msg["message_id"] = 0
+	}
+	if chatID, ok := params["chat_id"].(float64); ok {
+		chatType := "private"
+		if chatID < 0 {
+			chatType = "supergroup"
+		}
+		msg["chat"] = map[string]any{"id": chatID, "type": chatType}
+	}
+	if topicID, ok := params["message_thread_id"]; ok {
+		msg["message_thread_id"] = topicID
+	}
+	raw, _ := json.Marshal(msg)
+	return raw
+}
@@ -0,0 +1,42 @@
+// Package core provides classification of Telegram API errors.
+package core
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	ta "github.com/mymmrac/telego/telegoapi"
+)
+
+// blockedDescriptions are the error descriptions Telegram returns for chats
+// the bot can no longer message.
+var blockedDescriptions = []string{
+	"bot was blocked by the user",
+	"user is deactivated",
+	"bot was kicked",
+	"bot is not a member",
+	"chat not found",
+	"have no rights to send a message",
+	"bot can't initiate conversation",
+}
+
+// IsBlockedError returns true if Telegram rejected a message because the
+// recipient can't be reached: the user blocked the bot or deleted their
+// account, or the bot was removed from the chat. Retrying won't help.
+func IsBlockedError(err error) bool {
+	var apiErr *ta.Error
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	if apiErr.ErrorCode != http.StatusForbidden && apiErr.ErrorCode != http.StatusBadRequest {
+		return false
+	}
+	description := strings.ToLower(apiErr.Description)
+	for _, s := range blockedDescriptions {
+		if strings.Contains(description, s) {
+			return true
+		}
+	}
+	return false
+}
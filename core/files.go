@@ -0,0 +1,93 @@
+// Package core provides core functionality for Telegram Bot operations.
+package core
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+
+	"github.com/mymmrac/telego"
+)
+
+// MaxDownloadSize is the largest file the Bot API lets bots download (20 MB).
+const MaxDownloadSize = 20 << 20
+
+// ErrFileTooLarge is returned when a file exceeds the size limit of a download.
+var ErrFileTooLarge = errors.New("file is too large to download")
+
+// GetFile resolves a file sent to the bot by its file ID, returning its size
+// and its path on Telegram's servers, valid for at least an hour.
+func (b *Bot) GetFile(ctx context.Context, fileID string) (*telego.File, error) {
+	if b.bot == nil {
+		return nil, fmt.Errorf("bot is not initialized")
+	}
+	return b.bot.GetFile(ctx, &telego.GetFileParams{FileID: fileID})
+}
+
+// DownloadFile downloads a file sent to the bot by its file ID.
+// Files larger than MaxDownloadSize cannot be downloaded.
+func (b *Bot) DownloadFile(ctx context.Context, fileID string) ([]byte, error) {
+	var buf bytes.Buffer
+	if _, err := b.DownloadFileTo(ctx, fileID, &buf, MaxDownloadSize); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// DownloadFileTo streams a file sent to the bot by its file ID to w, and
+// returns the number of bytes written. Files larger than maxSize, or
+// MaxDownloadSize if maxSize is 0 or above it, fail with an error wrapping
+// ErrFileTooLarge, before downloading if Telegram reports their size and
+// otherwise once the limit is passed, leaving part of the file in w.
+// Canceling ctx aborts the download.
+func (b *Bot) DownloadFileTo(ctx context.Context, fileID string, w io.Writer, maxSize int64) (int64, error) {
+	if maxSize <= 0 || maxSize > MaxDownloadSize {
+		maxSize = MaxDownloadSize
+	}
+
+	file, err := b.GetFile(ctx, fileID)
+	if err != nil {
+		return 0, err
+	}
+	if int64(file.FileSize) > maxSize {
+		return 0, fmt.Errorf("%w: %d bytes", ErrFileTooLarge, file.FileSize)
+	}
+
+	// The download URL contains the bot token, so errors must not include it
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, b.bot.FileDownloadURL(file.FilePath), nil)
+	if err != nil {
+		return 0, fmt.Errorf("download file: %w", withoutURL(err))
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("download file: %w", withoutURL(err))
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("download failed: %s", resp.Status)
+	}
+
+	// Read one byte past the limit to tell files at the limit from larger ones
+	n, err := io.Copy(w, io.LimitReader(resp.Body, maxSize+1))
+	if err != nil {
+		return n, err
+	}
+	if n > maxSize {
+		return n, fmt.Errorf("%w: over %d bytes", ErrFileTooLarge, maxSize)
+	}
+	return n, nil
+}
+
+// withoutURL returns the cause of a URL error, dropping the URL it names.
+func withoutURL(err error) error {
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		return urlErr.Err
+	}
+	return err
+}
@@ -0,0 +1,77 @@
+package core
+
+import (
+	"context"
+	"strings"
+
+	"github.com/mymmrac/telego"
+)
+
+// parseModeKey is the context key holding the parse mode of sent texts.
+type parseModeKey struct{}
+
+// WithParseMode returns a context whose text messages are sent and edited
+// with a Telegram parse mode (telego.ModeMarkdownV2, telego.ModeHTML, or
+// telego.ModeMarkdown), e.g. for prompts authored with formatting in the
+// configuration. Texts sent with entities ignore it. An empty mode sends
+// plain text.
+func WithParseMode(ctx context.Context, mode string) context.Context {
+	return context.WithValue(ctx, parseModeKey{}, mode)
+}
+
+// ParseModeFrom returns the parse mode of texts sent with ctx, or "" for plain text.
+func ParseModeFrom(ctx context.Context) string {
+	mode, _ := ctx.Value(parseModeKey{}).(string)
+	return mode
+}
+
+// parseMode returns the parse mode of a text sent with ctx and entities.
+// Entities and a parse mode are mutually exclusive; entities win.
+func parseMode(ctx context.Context, entities []telego.MessageEntity) string {
+	if len(entities) > 0 {
+		return ""
+	}
+	return ParseModeFrom(ctx)
+}
+
+// markdownV2Escaper escapes the characters reserved in MarkdownV2.
+var markdownV2Escaper = strings.NewReplacer(
+	`\`, `\\`, "_", `\_`, "*", `\*`, "[", `\[`, "]", `\]`, "(", `\(`, ")", `\)`,
+	"~", `\~`, "`", "\\`", ">", `\>`, "#", `\#`, "+", `\+`, "-", `\-`, "=", `\=`,
+	"|", `\|`, "{", `\{`, "}", `\}`, ".", `\.`, "!", `\!`,
+)
+
+// markdownEscaper escapes the characters reserved in legacy Markdown.
+var markdownEscaper = strings.NewReplacer("_", `\_`, "*", `\*`, "`", "\\`", "[", `\[`)
+
+// htmlEscaper escapes the characters reserved in Telegram HTML.
+var htmlEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", `"`, "&quot;")
+
+// EscapeMarkdownV2 escapes text for use in a MarkdownV2 message.
+func EscapeMarkdownV2(text string) string {
+	return markdownV2Escaper.Replace(text)
+}
+
+// EscapeMarkdown escapes text for use in a legacy Markdown message.
+func EscapeMarkdown(text string) string {
+	return markdownEscaper.Replace(text)
+}
+
+// EscapeHTML escapes text for use in an HTML message.
+func EscapeHTML(text string) string {
+	return htmlEscaper.Replace(text)
+}
+
+// Escape escapes text for use in a message with a parse mode.
+// Text is returned unchanged for plain text or an unknown mode.
+func Escape(mode, text string) string {
+	switch mode {
+	case telego.ModeMarkdownV2:
+		return EscapeMarkdownV2(text)
+	case telego.ModeMarkdown:
+		return EscapeMarkdown(text)
+	case telego.ModeHTML:
+		return EscapeHTML(text)
+	}
+	return text
+}
@@ -0,0 +1,174 @@
+// Package core provides inline mode functionality.
+package core
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/mymmrac/telego"
+	"github.com/mymmrac/telego/telegoutil"
+)
+
+// MaxInlineResults is the maximum number of results per answerInlineQuery request.
+const MaxInlineResults = 50
+
+// InlineAnswer holds the options of an inline query answer.
+type InlineAnswer struct {
+	CacheTime  time.Duration                    // How long Telegram may cache the results; zero uses Telegram's 300s
+	IsPersonal bool                             // Cache the results only for the user who sent the query
+	NextOffset string                           // Offset sent with the next query for more results; empty if there are none
+	Button     *telego.InlineQueryResultsButton // Button shown above the results, e.g. to open the bot
+}
+
+// AnswerInlineQuery answers an inline query with the given results.
+// At most MaxInlineResults results are allowed; use InlineResultBuilder.Page to paginate.
+func (b *Bot) AnswerInlineQuery(ctx context.Context, queryID string, results []telego.InlineQueryResult, answer InlineAnswer) error {
+	if b.bot == nil {
+		return nil
+	}
+
+	if results == nil {
+		results = []telego.InlineQueryResult{} // Telegram requires the field even without results
+	}
+
+	for _, result := range results {
+		if article, ok := result.(*telego.InlineQueryResultArticle); ok && article.ReplyMarkup != nil {
+			markup, err := b.shortenKeyboard(ctx, article.ReplyMarkup)
+			if err != nil {
+				return err
+			}
+			article.ReplyMarkup = markup
+		}
+	}
+
+	return b.bot.AnswerInlineQuery(ctx, &telego.AnswerInlineQueryParams{
+		InlineQueryID: queryID,
+		Results:       results,
+		CacheTime:     int(answer.CacheTime / time.Second),
+		IsPersonal:    answer.IsPersonal,
+		NextOffset:    answer.NextOffset,
+		Button:        answer.Button,
+	})
+}
+
+// InlineResultBuilder provides a fluent interface for building inline query results.
+// Results added with an empty ID are numbered in the order they are added.
+type InlineResultBuilder struct {
+	results []telego.InlineQueryResult
+}
+
+// NewInlineResults creates a new inline result builder instance.
+func NewInlineResults() *InlineResultBuilder {
+	return &InlineResultBuilder{
+		results: make([]telego.InlineQueryResult, 0),
+	}
+}
+
+// id returns the given result ID, or the index of the next result if it is empty.
+func (rb *InlineResultBuilder) id(id string) string {
+	if id != "" {
+		return id
+	}
+	return strconv.Itoa(len(rb.results))
+}
+
+// Add adds a result built with telego or telegoutil directly.
+func (rb *InlineResultBuilder) Add(result telego.InlineQueryResult) *InlineResultBuilder {
+	rb.results = append(rb.results, result)
+	return rb
+}
+
+// Article adds an article result that sends a text message when chosen.
+// The description is shown under the title in the result list.
+func (rb *InlineResultBuilder) Article(id, title, description, text string, entities ...telego.MessageEntity) *InlineResultBuilder {
+	return rb.ArticleWithKeyboard(id, title, description, text, nil, entities...)
+}
+
+// ArticleWithKeyboard adds an article result that sends a text message with an inline keyboard when chosen.
+func (rb *InlineResultBuilder) ArticleWithKeyboard(id, title, description, text string, keyboard *telego.InlineKeyboardMarkup, entities ...telego.MessageEntity) *InlineResultBuilder {
+	content := telegoutil.TextMessage(text)
+	content.LinkPreviewOptions = &telego.LinkPreviewOptions{IsDisabled: true}
+	if len(entities) > 0 {
+		content.Entities = entities
+	}
+
+	result := telegoutil.ResultArticle(rb.id(id), title, content)
+	result.Description = description
+	result.ReplyMarkup = keyboard
+	return rb.Add(result)
+}
+
+// Photo adds a photo result from a URL. The photo must be a JPEG; thumbnailURL may equal photoURL.
+func (rb *InlineResultBuilder) Photo(id, photoURL, thumbnailURL, caption string, entities ...telego.MessageEntity) *InlineResultBuilder {
+	result := telegoutil.ResultPhoto(rb.id(id), photoURL, thumbnailURL)
+	result.Caption = caption
+	if len(entities) > 0 {
+		result.CaptionEntities = entities
+	}
+	return rb.Add(result)
+}
+
+// CachedPhoto adds a photo result from a file already on Telegram's servers.
+func (rb *InlineResultBuilder) CachedPhoto(id, fileID, caption string, entities ...telego.MessageEntity) *InlineResultBuilder {
+	result := telegoutil.ResultCachedPhoto(rb.id(id), fileID)
+	result.Caption = caption
+	if len(entities) > 0 {
+		result.CaptionEntities = entities
+	}
+	return rb.Add(result)
+}
+
+// Document adds a document result from a URL.
+// Only "application/pdf" and "application/zip" are allowed as mimeType.
+func (rb *InlineResultBuilder) Document(id, title, documentURL, mimeType, caption string, entities ...telego.MessageEntity) *InlineResultBuilder {
+	result := telegoutil.ResultDocument(rb.id(id), title, documentURL, mimeType)
+	result.Caption = caption
+	if len(entities) > 0 {
+		result.CaptionEntities = entities
+	}
+	return rb.Add(result)
+}
+
+// CachedDocument adds a document result from a file already on Telegram's servers.
+func (rb *InlineResultBuilder) CachedDocument(id, title, fileID, caption string, entities ...telego.MessageEntity) *InlineResultBuilder {
+	result := telegoutil.ResultCachedDocument(rb.id(id), title, fileID)
+	result.Caption = caption
+	if len(entities) > 0 {
+		result.CaptionEntities = entities
+	}
+	return rb.Add(result)
+}
+
+// Len returns the number of results added so far.
+func (rb *InlineResultBuilder) Len() int {
+	return len(rb.results)
+}
+
+// Build returns all results.
+func (rb *InlineResultBuilder) Build() []telego.InlineQueryResult {
+	return rb.results
+}
+
+// Page returns the results starting at offset, an InlineQuery.Offset, and the
+// offset of the next page, or an empty offset on the last page.
+// The page size is capped at MaxInlineResults.
+func (rb *InlineResultBuilder) Page(offset string, size int) ([]telego.InlineQueryResult, string) {
+	if size <= 0 || size > MaxInlineResults {
+		size = MaxInlineResults
+	}
+	start, err := strconv.Atoi(offset)
+	if err != nil || start < 0 {
+		start = 0
+	}
+	if start >= len(rb.results) {
+		return []telego.InlineQueryResult{}, ""
+	}
+
+	end := min(start+size, len(rb.results))
+	next := ""
+	if end < len(rb.results) {
+		next = strconv.Itoa(end)
+	}
+	return rb.results[start:end], next
+}
@@ -2,6 +2,9 @@
 package core
 
 import (
+	"fmt"
+	"strconv"
+
 	"github.com/mymmrac/telego"
 	"github.com/mymmrac/telego/telegoutil"
 )
@@ -16,20 +19,31 @@ const (
 	CallbackCancel = "cancel"
 	// CallbackPage is the prefix for pagination callbacks.
 	CallbackPage = "page:"
+	// CallbackNoop is the data of buttons that do nothing, like page indicators.
+	CallbackNoop = "noop"
 )
 
 // KeyboardBuilder provides a fluent interface for building inline keyboards.
 type KeyboardBuilder struct {
-	rows [][]telego.InlineKeyboardButton
+	rows    [][]telego.InlineKeyboardButton
+	strings Strings // Texts of navigation and pagination buttons
 }
 
 // NewKeyboard creates a new keyboard builder instance.
 func NewKeyboard() *KeyboardBuilder {
 	return &KeyboardBuilder{
-		rows: make([][]telego.InlineKeyboardButton, 0),
+		rows:    make([][]telego.InlineKeyboardButton, 0),
+		strings: DefaultStrings,
 	}
 }
 
+// WithStrings sets the texts of navigation and pagination buttons,
+// e.g. StringsFrom(ctx) for the user being handled.
+func (kb *KeyboardBuilder) WithStrings(s Strings) *KeyboardBuilder {
+	kb.strings = s
+	return kb
+}
+
 // Row adds a row of buttons to the keyboard.
 func (kb *KeyboardBuilder) Row(buttons ...telego.InlineKeyboardButton) *KeyboardBuilder {
 	if len(buttons) > 0 {
@@ -62,28 +76,40 @@ func (kb *KeyboardBuilder) Grid(buttons []telego.InlineKeyboardButton, columns i
 }
 
 // Back adds a back navigation button.
+// An empty text uses the builder's Strings.
 func (kb *KeyboardBuilder) Back(text string) *KeyboardBuilder {
 	if text == "" {
-		text = "⬅️ Back"
+		text = kb.strings.Back
 	}
 	return kb.Row(Button(text, CallbackBack))
 }
 
 // MainMenu adds a main menu navigation button.
+// An empty text uses the builder's Strings.
 func (kb *KeyboardBuilder) MainMenu(text string) *KeyboardBuilder {
 	if text == "" {
-		text = "🏠 Main Menu"
+		text = kb.strings.MainMenu
 	}
 	return kb.Row(Button(text, CallbackMainMenu))
 }
 
+// Cancel adds a cancel button.
+// An empty text uses the builder's Strings.
+func (kb *KeyboardBuilder) Cancel(text string) *KeyboardBuilder {
+	if text == "" {
+		text = kb.strings.Cancel
+	}
+	return kb.Row(Button(text, CallbackCancel))
+}
+
 // Navigation adds a row with both back and main menu buttons.
+// Empty texts use the builder's Strings.
 func (kb *KeyboardBuilder) Navigation(backText, mainText string) *KeyboardBuilder {
 	if backText == "" {
-		backText = "⬅️ Back"
+		backText = kb.strings.Back
 	}
 	if mainText == "" {
-		mainText = "🏠 Main Menu"
+		mainText = kb.strings.MainMenu
 	}
 	return kb.Row(
 		Button(backText, CallbackBack),
@@ -101,18 +127,18 @@ func (kb *KeyboardBuilder) Pagination(currentPage, totalPages int, prefix string
 
 	// Previous page button
 	if currentPage > 1 {
-		buttons = append(buttons, Button("⬅️", prefix+string(rune('0'+currentPage-1))))
+		buttons = append(buttons, Button(kb.strings.PrevPage, prefix+strconv.Itoa(currentPage-1)))
 	}
 
 	// Page indicator
 	buttons = append(buttons, Button(
-		string(rune('0'+currentPage))+"/"+string(rune('0'+totalPages)),
-		"noop",
+		fmt.Sprintf(kb.strings.PageIndicator, currentPage, totalPages),
+		CallbackNoop,
 	))
 
 	// Next page button
 	if currentPage < totalPages {
-		buttons = append(buttons, Button("➡️", prefix+string(rune('0'+currentPage+1))))
+		buttons = append(buttons, Button(kb.strings.NextPage, prefix+strconv.Itoa(currentPage+1)))
 	}
 
 	if len(buttons) > 0 {
@@ -162,6 +188,8 @@ func BuildFromConfig(buttons [][]ButtonConfig) *telego.InlineKeyboardMarkup {
 		for _, btn := range row {
 			if btn.URL != "" {
 				rowButtons = append(rowButtons, URLButton(btn.Text, btn.URL))
+			} else if btn.WebApp != "" {
+				rowButtons = append(rowButtons, WebAppButton(btn.Text, btn.WebApp))
 			} else if btn.Callback != "" {
 				rowButtons = append(rowButtons, Button(btn.Text, btn.Callback))
 			} else if btn.FlowID != "" {
@@ -184,6 +212,7 @@ type ButtonConfig struct {
 	Text     string // Button display text
 	Callback string // Callback data
 	URL      string // URL for link buttons
+	WebApp   string // URL of a Web App to open
 	FlowID   string // Flow ID to start
 	MenuID   string // Menu ID to navigate to
 }
@@ -0,0 +1,92 @@
+// Package core provides core functionality for Telegram Bot operations.
+package core
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mymmrac/telego"
+)
+
+// Username returns the bot's username, fetching it once with GetMe and caching it.
+func (b *Bot) Username(ctx context.Context) (string, error) {
+	b.mu.RLock()
+	username := b.username
+	b.mu.RUnlock()
+	if username != "" {
+		return username, nil
+	}
+
+	me, err := b.GetMe(ctx)
+	if err != nil {
+		return "", err
+	}
+	if me == nil {
+		return "", fmt.Errorf("bot is not initialized")
+	}
+
+	b.mu.Lock()
+	b.username = me.Username
+	b.mu.Unlock()
+	return me.Username, nil
+}
+
+// DeepLink returns a t.me link that opens a private chat with the bot and sends
+// "/start <payload>". Payloads may contain up to 64 characters A-Z, a-z, 0-9, _ and -.
+func (b *Bot) DeepLink(ctx context.Context, payload string) (string, error) {
+	username, err := b.Username(ctx)
+	if err != nil {
+		return "", err
+	}
+	return "https://t.me/" + username + "?start=" + payload, nil
+}
+
+// CreateInviteLink creates an additional invite link for a chat.
+// The bot must be an administrator with the right to invite users.
+// The name (up to 32 characters) is reported back in chat_member updates of users
+// joining through the link. Use memberLimit 0 and a zero expires for no limits.
+func (b *Bot) CreateInviteLink(ctx context.Context, chatID int64, name string, memberLimit int, expires time.Time) (*telego.ChatInviteLink, error) {
+	if b.bot == nil {
+		return nil, nil
+	}
+
+	params := &telego.CreateChatInviteLinkParams{
+		ChatID:      telego.ChatID{ID: chatID},
+		Name:        name,
+		MemberLimit: memberLimit,
+	}
+	if !expires.IsZero() {
+		params.ExpireDate = expires.Unix()
+	}
+	return b.bot.CreateChatInviteLink(ctx, params)
+}
+
+// RevokeInviteLink revokes an invite link created by the bot.
+func (b *Bot) RevokeInviteLink(ctx context.Context, chatID int64, link string) error {
+	if b.bot == nil {
+		return nil
+	}
+
+	_, err := b.bot.RevokeChatInviteLink(ctx, &telego.RevokeChatInviteLinkParams{
+		ChatID:     telego.ChatID{ID: chatID},
+		InviteLink: link,
+	})
+	return err
+}
+
+// StartPayload extracts the deep-link payload from a "/start <payload>" message text.
+// Returns empty string if the text is not a /start command or has no payload.
+func StartPayload(text string) string {
+	command, payload, ok := strings.Cut(strings.TrimSpace(text), " ")
+	if !ok {
+		return ""
+	}
+	// Commands in groups may be addressed as /start@botname
+	command, _, _ = strings.Cut(command, "@")
+	if command != "/start" {
+		return ""
+	}
+	return strings.TrimSpace(payload)
+}
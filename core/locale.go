@@ -0,0 +1,116 @@
+// Package core provides locale-aware formatting of numbers, amounts, and dates.
+package core
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// nbsp is a non-breaking space, used as a separator so amounts don't wrap.
+const nbsp = "\u00a0"
+
+// Locale describes how numbers, amounts, and dates are written in a language.
+type Locale struct {
+	Language    string // Base language, e.g. "en"
+	Decimal     string // Decimal separator
+	Group       string // Thousands separator
+	SymbolAfter bool   // Whether currency symbols follow the amount ("1.234,50 €")
+	DateLayout  string // Layout of dates with time
+}
+
+// Locales are the built-in locales by base language.
+var Locales = map[string]Locale{
+	"en": {Language: "en", Decimal: ".", Group: ",", DateLayout: "Jan 2, 2006 15:04 MST"},
+	"de": {Language: "de", Decimal: ",", Group: ".", SymbolAfter: true, DateLayout: "02.01.2006 15:04 MST"},
+	"es": {Language: "es", Decimal: ",", Group: ".", SymbolAfter: true, DateLayout: "02/01/2006 15:04 MST"},
+	"fr": {Language: "fr", Decimal: ",", Group: nbsp, SymbolAfter: true, DateLayout: "02/01/2006 15:04 MST"},
+	"it": {Language: "it", Decimal: ",", Group: ".", SymbolAfter: true, DateLayout: "02/01/2006 15:04 MST"},
+	"pt": {Language: "pt", Decimal: ",", Group: ".", DateLayout: "02/01/2006 15:04 MST"},
+	"ru": {Language: "ru", Decimal: ",", Group: nbsp, SymbolAfter: true, DateLayout: "02.01.2006 15:04 MST"},
+	"uk": {Language: "uk", Decimal: ",", Group: nbsp, SymbolAfter: true, DateLayout: "02.01.2006 15:04 MST"},
+	"ja": {Language: "ja", Decimal: ".", Group: ",", DateLayout: "2006/01/02 15:04 MST"},
+	"zh": {Language: "zh", Decimal: ".", Group: ",", DateLayout: "2006/01/02 15:04 MST"},
+}
+
+// DefaultLocale is used for languages without a built-in locale.
+var DefaultLocale = Locales["en"]
+
+// LocaleFor returns the locale of an IETF language tag such as "de-AT",
+// as reported by Telegram, falling back to DefaultLocale.
+func LocaleFor(languageCode string) Locale {
+	lang, _, _ := strings.Cut(strings.ToLower(languageCode), "-")
+	if l, ok := Locales[lang]; ok {
+		return l
+	}
+	return DefaultLocale
+}
+
+// currencySymbols maps ISO currency codes to their symbols.
+// Other codes are written after the amount, e.g. "12.50 USDT".
+var currencySymbols = map[string]string{
+	"USD": "$", "EUR": "€", "GBP": "£", "JPY": "¥", "CNY": "¥", "KRW": "₩",
+	"INR": "₹", "RUB": "₽", "UAH": "₴", "TRY": "₺", "BRL": "R$", "BTC": "₿",
+}
+
+// currencyDigits maps currency codes to their fraction digits if not 2.
+var currencyDigits = map[string]int{
+	"JPY": 0, "KRW": 0, "BTC": 8, "ETH": 8,
+}
+
+// FormatNumber formats a number with the locale's separators and the given
+// number of fraction digits; -1 uses the fewest digits that represent n exactly.
+func (l Locale) FormatNumber(n float64, digits int) string {
+	s := strconv.FormatFloat(n, 'f', digits, 64)
+	sign := ""
+	if strings.HasPrefix(s, "-") {
+		sign, s = "-", s[1:]
+	}
+	whole, frac, _ := strings.Cut(s, ".")
+
+	var b strings.Builder
+	b.WriteString(sign)
+	for i, r := range whole {
+		if i > 0 && (len(whole)-i)%3 == 0 {
+			b.WriteString(l.Group)
+		}
+		b.WriteRune(r)
+	}
+	if frac != "" {
+		b.WriteString(l.Decimal)
+		b.WriteString(frac)
+	}
+	return b.String()
+}
+
+// FormatMoney formats an amount in a currency given by its ISO code, e.g.
+// "$1,234.50" in English or "1.234,50 €" in German.
+func (l Locale) FormatMoney(amount float64, currency string) string {
+	currency = strings.ToUpper(currency)
+	digits, ok := currencyDigits[currency]
+	if !ok {
+		digits = 2
+	}
+	symbol, ok := currencySymbols[currency]
+	if !ok {
+		return l.FormatNumber(amount, digits) + nbsp + currency
+	}
+
+	value := l.FormatNumber(amount, digits)
+	if l.SymbolAfter {
+		return value + nbsp + symbol
+	}
+	if rest, negative := strings.CutPrefix(value, "-"); negative {
+		return "-" + symbol + rest
+	}
+	return symbol + value
+}
+
+// FormatDateTime formats a time with the locale's date layout in a time zone.
+// A nil zone is UTC.
+func (l Locale) FormatDateTime(t time.Time, loc *time.Location) string {
+	if loc == nil {
+		loc = time.UTC
+	}
+	return t.In(loc).Format(l.DateLayout)
+}
@@ -0,0 +1,155 @@
+// Package core provides core functionality for Telegram Bot operations.
+package core
+
+import (
+	"context"
+	"io"
+
+	"github.com/mymmrac/telego"
+	"github.com/mymmrac/telego/telegoutil"
+)
+
+// FileFromID returns an input file that resends a file already on Telegram's servers.
+func FileFromID(fileID string) telego.InputFile {
+	return telegoutil.FileFromID(fileID)
+}
+
+// FileFromURL returns an input file that Telegram downloads from a URL.
+func FileFromURL(url string) telego.InputFile {
+	return telegoutil.FileFromURL(url)
+}
+
+// FileFromReader returns an input file uploaded from a reader.
+// The name is shown for documents and determines the file type Telegram assumes.
+func FileFromReader(r io.Reader, name string) telego.InputFile {
+	return telegoutil.FileFromReader(r, name)
+}
+
+// SendVideo sends a video with an optional caption to the specified chat.
+func (b *Bot) SendVideo(ctx context.Context, chatID int64, topicID int, video telego.InputFile, caption string, entities ...telego.MessageEntity) (*telego.Message, error) {
+	if b.bot == nil {
+		return nil, nil
+	}
+
+	params := &telego.SendVideoParams{
+		ChatID:  telegoutil.ID(chatID),
+		Video:   video,
+		Caption: caption,
+	}
+
+	if topicID > 0 {
+		params.MessageThreadID = topicID
+	}
+
+	if len(entities) > 0 {
+		params.CaptionEntities = entities
+	}
+
+	return b.sent(b.paced(ctx, chatID, func() (*telego.Message, error) {
+		return b.bot.SendVideo(ctx, params)
+	}))
+}
+
+// SendAudio sends an audio file with an optional caption to the specified chat.
+// Telegram shows it in the music player; use SendDocument for other audio formats.
+func (b *Bot) SendAudio(ctx context.Context, chatID int64, topicID int, audio telego.InputFile, caption string, entities ...telego.MessageEntity) (*telego.Message, error) {
+	if b.bot == nil {
+		return nil, nil
+	}
+
+	params := &telego.SendAudioParams{
+		ChatID:  telegoutil.ID(chatID),
+		Audio:   audio,
+		Caption: caption,
+	}
+
+	if topicID > 0 {
+		params.MessageThreadID = topicID
+	}
+
+	if len(entities) > 0 {
+		params.CaptionEntities = entities
+	}
+
+	return b.sent(b.paced(ctx, chatID, func() (*telego.Message, error) {
+		return b.bot.SendAudio(ctx, params)
+	}))
+}
+
+// SendAnimation sends an animation (GIF or soundless video) with an optional caption to the specified chat.
+func (b *Bot) SendAnimation(ctx context.Context, chatID int64, topicID int, animation telego.InputFile, caption string, entities ...telego.MessageEntity) (*telego.Message, error) {
+	if b.bot == nil {
+		return nil, nil
+	}
+
+	params := &telego.SendAnimationParams{
+		ChatID:    telegoutil.ID(chatID),
+		Animation: animation,
+		Caption:   caption,
+	}
+
+	if topicID > 0 {
+		params.MessageThreadID = topicID
+	}
+
+	if len(entities) > 0 {
+		params.CaptionEntities = entities
+	}
+
+	return b.sent(b.paced(ctx, chatID, func() (*telego.Message, error) {
+		return b.bot.SendAnimation(ctx, params)
+	}))
+}
+
+// EditMessageCaption edits the caption of an existing media message.
+// Telegram removes the inline keyboard unless it is passed again.
+func (b *Bot) EditMessageCaption(ctx context.Context, chatID int64, messageID int, caption string, keyboard *telego.InlineKeyboardMarkup, entities ...telego.MessageEntity) (*telego.Message, error) {
+	if b.bot == nil {
+		return nil, nil
+	}
+
+	markup, err := b.shortenKeyboard(ctx, keyboard)
+	if err != nil {
+		return nil, err
+	}
+
+	params := &telego.EditMessageCaptionParams{
+		ChatID:      telegoutil.ID(chatID),
+		MessageID:   messageID,
+		Caption:     caption,
+		ReplyMarkup: markup,
+	}
+
+	if len(entities) > 0 {
+		params.CaptionEntities = entities
+	}
+
+	return b.paced(ctx, chatID, func() (*telego.Message, error) {
+		return b.bot.EditMessageCaption(ctx, params)
+	})
+}
+
+// EditMessageMedia replaces the media of an existing media message, e.g. with
+// a telego.InputMediaVideo built from FileFromURL. The new media carries its own caption.
+// Telegram removes the inline keyboard unless it is passed again.
+func (b *Bot) EditMessageMedia(ctx context.Context, chatID int64, messageID int, media telego.InputMedia, keyboard *telego.InlineKeyboardMarkup) (*telego.Message, error) {
+	if b.bot == nil {
+		return nil, nil
+	}
+
+	markup, err := b.shortenKeyboard(ctx, keyboard)
+	if err != nil {
+		return nil, err
+	}
+
+	params := &telego.EditMessageMediaParams{
+		ChatID:      telegoutil.ID(chatID),
+		MessageID:   messageID,
+		Media:       media,
+		ReplyMarkup: markup,
+	}
+
+	return b.paced(ctx, chatID, func() (*telego.Message, error) {
+		return b.bot.EditMessageMedia(ctx, params)
+	})
+}
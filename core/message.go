@@ -13,30 +13,38 @@ const MaxMessageLength = 4096
 // SplitMessage splits a long message into multiple parts.
 // It preserves message entity offsets correctly across splits.
 // Attempts to split at newlines for cleaner breaks.
+// Lengths and offsets are counted in UTF-16 code units, like Telegram does,
+// and surrogate pairs are never split.
 func SplitMessage(text string, entities []telego.MessageEntity) []MessagePart {
 	// If text is within limit, return as single part
-	textRunes := []rune(text)
-	if len(textRunes) <= MaxMessageLength {
+	units := utf16.Encode([]rune(text))
+	if len(units) <= MaxMessageLength {
 		return []MessagePart{{Text: text, Entities: entities}}
 	}
 
 	var parts []MessagePart
 	start := 0
 
-	for start < len(textRunes) {
-		end := min(start+MaxMessageLength, len(textRunes))
+	for start < len(units) {
+		end := min(start+MaxMessageLength, len(units))
 
-		// Try to split at a newline for cleaner breaks
-		if end < len(textRunes) {
+		if end < len(units) {
+			// Try to split at a newline for cleaner breaks
+			split := end
 			for i := end - 1; i > start+MaxMessageLength/2; i-- {
-				if textRunes[i] == '\n' {
-					end = i + 1
+				if units[i] == '\n' {
+					split = i + 1
 					break
 				}
 			}
+			// Keep surrogate pairs together
+			if split == end && utf16.IsSurrogate(rune(units[end-1])) && units[end-1] < 0xdc00 {
+				split--
+			}
+			end = split
 		}
 
-		partText := string(textRunes[start:end])
+		partText := string(utf16.Decode(units[start:end]))
 		partEntities := adjustEntitiesForPart(entities, start, end)
 
 		parts = append(parts, MessagePart{
@@ -0,0 +1,71 @@
+package core
+
+import (
+	"context"
+
+	"github.com/mymmrac/telego"
+)
+
+// MaxCallbackData is the maximum length of callback data accepted by Telegram, in bytes.
+const MaxCallbackData = 64
+
+// PayloadStore shortens callback data that exceeds MaxCallbackData, e.g. by
+// storing it behind a token; see payload.Registry.
+type PayloadStore interface {
+	// Shorten returns data unchanged if it fits, and a short stand-in otherwise.
+	Shorten(ctx context.Context, data string) (string, error)
+}
+
+// SetPayloadStore sets the store shortening long callback data of inline
+// keyboards before they are sent. Pass nil to send callback data unchanged.
+func (b *Bot) SetPayloadStore(s PayloadStore) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.payloads = s
+}
+
+// shortenData returns callback data as sent, shortened by the payload store if too long.
+func (b *Bot) shortenData(ctx context.Context, data string) (string, error) {
+	b.mu.RLock()
+	s := b.payloads
+	b.mu.RUnlock()
+	if s == nil || len(data) <= MaxCallbackData {
+		return data, nil
+	}
+	return s.Shorten(ctx, data)
+}
+
+// shortenKeyboard returns a copy of keyboard with long callback data shortened
+// by the payload store, or keyboard itself if nothing needs shortening.
+func (b *Bot) shortenKeyboard(ctx context.Context, keyboard *telego.InlineKeyboardMarkup) (*telego.InlineKeyboardMarkup, error) {
+	if keyboard == nil {
+		return nil, nil
+	}
+	var rows [][]telego.InlineKeyboardButton
+	for i, row := range keyboard.InlineKeyboard {
+		for j, btn := range row {
+			if len(btn.CallbackData) <= MaxCallbackData {
+				continue
+			}
+			data, err := b.shortenData(ctx, btn.CallbackData)
+			if err != nil {
+				return nil, err
+			}
+			if data == btn.CallbackData {
+				continue
+			}
+			if rows == nil {
+				// Copy on first change; callers may reuse their keyboard
+				rows = make([][]telego.InlineKeyboardButton, len(keyboard.InlineKeyboard))
+				for k, r := range keyboard.InlineKeyboard {
+					rows[k] = append([]telego.InlineKeyboardButton(nil), r...)
+				}
+			}
+			rows[i][j].CallbackData = data
+		}
+	}
+	if rows == nil {
+		return keyboard, nil
+	}
+	return &telego.InlineKeyboardMarkup{InlineKeyboard: rows}, nil
+}
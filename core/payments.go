@@ -0,0 +1,102 @@
+// Package core provides payment functionality.
+package core
+
+import (
+	"context"
+
+	"github.com/mymmrac/telego"
+	"github.com/mymmrac/telego/telegoutil"
+)
+
+// CurrencyStars is the currency of payments in Telegram Stars, which need no
+// payment provider token.
+const CurrencyStars = "XTR"
+
+// Invoice holds the contents and options of an invoice.
+type Invoice struct {
+	Title         string                       // Product name, 1-32 characters
+	Description   string                       // Product description, 1-255 characters
+	Payload       string                       // Bot-defined data passed back with the payment, up to 128 bytes
+	ProviderToken string                       // Payment provider token from @BotFather; empty for CurrencyStars
+	Currency      string                       // Three-letter ISO 4217 code, or CurrencyStars
+	Prices        []telego.LabeledPrice        // Price breakdown, in the smallest units of the currency (e.g. cents)
+	PhotoURL      string                       // Product photo shown on the invoice
+	NeedName      bool                         // Ask for the user's full name
+	NeedEmail     bool                         // Ask for the user's email
+	NeedPhone     bool                         // Ask for the user's phone number
+	NeedShipping  bool                         // Ask for a shipping address
+	IsFlexible    bool                         // The price depends on the shipping method; shipping queries must be answered
+	Keyboard      *telego.InlineKeyboardMarkup // Buttons below the invoice; the first one must be a pay button
+}
+
+// Price returns a price breakdown of a single item, in the smallest units of
+// the currency.
+func Price(label string, amount int) []telego.LabeledPrice {
+	return []telego.LabeledPrice{{Label: label, Amount: amount}}
+}
+
+// SendInvoice sends an invoice to the specified chat.
+func (b *Bot) SendInvoice(ctx context.Context, chatID int64, topicID int, invoice Invoice) (*telego.Message, error) {
+	if b.bot == nil {
+		return nil, nil
+	}
+
+	params := &telego.SendInvoiceParams{
+		ChatID:              telegoutil.ID(chatID),
+		Title:               invoice.Title,
+		Description:         invoice.Description,
+		Payload:             invoice.Payload,
+		ProviderToken:       invoice.ProviderToken,
+		Currency:            invoice.Currency,
+		Prices:              invoice.Prices,
+		PhotoURL:            invoice.PhotoURL,
+		NeedName:            invoice.NeedName,
+		NeedEmail:           invoice.NeedEmail,
+		NeedPhoneNumber:     invoice.NeedPhone,
+		NeedShippingAddress: invoice.NeedShipping,
+		IsFlexible:          invoice.IsFlexible,
+		ReplyMarkup:         invoice.Keyboard,
+	}
+
+	if topicID > 0 {
+		params.MessageThreadID = topicID
+	}
+
+	return b.sent(b.paced(ctx, chatID, func() (*telego.Message, error) {
+		return b.bot.SendInvoice(ctx, params)
+	}))
+}
+
+// AnswerPreCheckoutQuery confirms that the order of a pre-checkout query can
+// be fulfilled, or declines it with a message shown to the user if errorMessage
+// is not empty. Telegram expects the answer within 10 seconds.
+func (b *Bot) AnswerPreCheckoutQuery(ctx context.Context, queryID string, errorMessage string) error {
+	if b.bot == nil {
+		return nil
+	}
+
+	return b.bot.AnswerPreCheckoutQuery(ctx, &telego.AnswerPreCheckoutQueryParams{
+		PreCheckoutQueryID: queryID,
+		Ok:                 errorMessage == "",
+		ErrorMessage:       errorMessage,
+	})
+}
+
+// AnswerShippingQuery replies to a shipping query of a flexible invoice with
+// the available shipping options, or declines it with a message shown to the
+// user (e.g. delivery to the address isn't possible) if errorMessage is not empty.
+func (b *Bot) AnswerShippingQuery(ctx context.Context, queryID string, options []telego.ShippingOption, errorMessage string) error {
+	if b.bot == nil {
+		return nil
+	}
+
+	params := &telego.AnswerShippingQueryParams{
+		ShippingQueryID: queryID,
+		Ok:              errorMessage == "",
+		ErrorMessage:    errorMessage,
+	}
+	if params.Ok {
+		params.ShippingOptions = options
+	}
+	return b.bot.AnswerShippingQuery(ctx, params)
+}
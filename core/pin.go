@@ -0,0 +1,52 @@
+// Package core provides core functionality for Telegram Bot operations.
+package core
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/mymmrac/telego"
+	ta "github.com/mymmrac/telego/telegoapi"
+	"github.com/mymmrac/telego/telegoutil"
+)
+
+// PinMessage pins a message in a chat. With silent, members are not notified.
+// In groups the bot needs the can_pin_messages right; see IsPermissionError.
+func (b *Bot) PinMessage(ctx context.Context, chatID int64, messageID int, silent bool) error {
+	if b.bot == nil {
+		return nil
+	}
+
+	return b.bot.PinChatMessage(ctx, &telego.PinChatMessageParams{
+		ChatID:              telegoutil.ID(chatID),
+		MessageID:           messageID,
+		DisableNotification: silent,
+	})
+}
+
+// UnpinMessage unpins a message in a chat.
+func (b *Bot) UnpinMessage(ctx context.Context, chatID int64, messageID int) error {
+	if b.bot == nil {
+		return nil
+	}
+
+	return b.bot.UnpinChatMessage(ctx, &telego.UnpinChatMessageParams{
+		ChatID:    telegoutil.ID(chatID),
+		MessageID: messageID,
+	})
+}
+
+// IsPermissionError returns true if Telegram rejected a request because the
+// bot lacks the admin rights for it, e.g. to pin messages in a group.
+func IsPermissionError(err error) bool {
+	var apiErr *ta.Error
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	if apiErr.ErrorCode == http.StatusForbidden {
+		return true
+	}
+	return apiErr.ErrorCode == http.StatusBadRequest && strings.Contains(strings.ToLower(apiErr.Description), "not enough rights")
+}
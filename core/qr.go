@@ -0,0 +1,40 @@
+// Package core provides core functionality for Telegram Bot operations.
+package core
+
+import (
+	"context"
+
+	"github.com/mymmrac/telego"
+	"github.com/mymmrac/telego/telegoutil"
+	qrcode "github.com/skip2/go-qrcode"
+)
+
+// DefaultQRCodeSize is the default width and height of rendered QR codes in pixels.
+const DefaultQRCodeSize = 512
+
+// QRCode renders content (a deep link, address, invoice URI, ...) as a PNG QR code.
+// A size of 0 uses DefaultQRCodeSize.
+func QRCode(content string, size int) ([]byte, error) {
+	if size <= 0 {
+		size = DefaultQRCodeSize
+	}
+	return qrcode.Encode(content, qrcode.Medium, size)
+}
+
+// SendQRCode renders content as a QR code and sends it as a photo.
+// The caption is taken from the builder, keeping its formatting; pass nil for no caption.
+func (b *Bot) SendQRCode(ctx context.Context, chatID int64, topicID int, content string, caption *Builder) (*telego.Message, error) {
+	png, err := QRCode(content, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		text     string
+		entities []telego.MessageEntity
+	)
+	if caption != nil {
+		text, entities = caption.Build()
+	}
+	return b.SendPhoto(ctx, chatID, topicID, telegoutil.FileFromBytes(png, "qr.png"), text, entities...)
+}
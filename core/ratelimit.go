@@ -0,0 +1,288 @@
+// Package core provides core functionality for Telegram Bot operations.
+package core
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/mymmrac/telego"
+	ta "github.com/mymmrac/telego/telegoapi"
+)
+
+// Default send limits, matching the limits Telegram enforces on bots.
+const (
+	// DefaultGlobalRate is the number of messages per second sent across all chats.
+	DefaultGlobalRate = 30
+
+	// DefaultChatInterval is the time between messages to the same chat once its burst is used.
+	DefaultChatInterval = time.Second
+
+	// DefaultChatBurst is the number of messages sent to a chat without pacing,
+	// so interactive replies and menu edits are not delayed.
+	DefaultChatBurst = 3
+
+	// DefaultMaxRetries is how often a request rejected with 429 Too Many Requests is retried.
+	DefaultMaxRetries = 3
+)
+
+// Limits configure the pacing of outgoing messages. Zero values use the defaults.
+type Limits struct {
+	GlobalRate   int           // Messages per second across all chats
+	ChatInterval time.Duration // Time between messages to one chat after its burst
+	ChatBurst    int           // Messages sent to one chat without waiting
+	MaxRetries   int           // Retries of a request rejected with 429
+}
+
+// withDefaults returns the limits with zero values replaced by defaults.
+func (l Limits) withDefaults() Limits {
+	if l.GlobalRate <= 0 {
+		l.GlobalRate = DefaultGlobalRate
+	}
+	if l.ChatInterval <= 0 {
+		l.ChatInterval = DefaultChatInterval
+	}
+	if l.ChatBurst <= 0 {
+		l.ChatBurst = DefaultChatBurst
+	}
+	if l.MaxRetries <= 0 {
+		l.MaxRetries = DefaultMaxRetries
+	}
+	return l
+}
+
+// QueueStats are the metrics of the send queue.
+type QueueStats struct {
+	Pending     int    // Requests waiting for a send slot
+	Chats       int    // Chats with waiting requests
+	Sent        uint64 // Requests passed to Telegram, including retries
+	Retried     uint64 // Requests retried after a 429
+	RateLimited uint64 // 429 responses received
+	Delayed     uint64 // Requests that had to wait for a send slot
+}
+
+// bucket is a token bucket: it holds up to burst tokens and refills at rate tokens per second.
+type bucket struct {
+	tokens float64   // Tokens left; negative while requests are queued
+	last   time.Time // When tokens was last updated
+}
+
+// reserve takes a token at now and returns how long the caller must wait for it.
+func (b *bucket) reserve(now time.Time, rate, burst float64) time.Duration {
+	if b.last.IsZero() {
+		b.tokens = burst
+	} else {
+		b.tokens = min(burst, b.tokens+now.Sub(b.last).Seconds()*rate)
+	}
+	b.last = now
+	b.tokens--
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / rate * float64(time.Second))
+}
+
+// block empties the bucket so the next token is available only after d.
+func (b *bucket) block(now time.Time, d time.Duration, rate float64) {
+	b.tokens = min(b.tokens, 1-d.Seconds()*rate)
+	b.last = now
+}
+
+// Limiter paces outgoing requests with a global bucket and one bucket per chat.
+// Requests to a chat are queued in the order they reserve a slot.
+type Limiter struct {
+	limits  Limits            // Rates and retry count
+	global  bucket            // Bucket shared by all chats
+	chats   map[int64]*bucket // Buckets by chat ID
+	waiting map[int64]int     // Waiting requests by chat ID
+	stats   QueueStats        // Counters reported by Stats
+
+	mu sync.Mutex // Mutex for thread-safe bucket and counter access
+}
+
+// NewLimiter creates a limiter with the given limits.
+func NewLimiter(limits Limits) *Limiter {
+	return &Limiter{
+		limits:  limits.withDefaults(),
+		chats:   make(map[int64]*bucket),
+		waiting: make(map[int64]int),
+	}
+}
+
+// SetLimits replaces the limits. Requests already waiting keep their slot.
+func (l *Limiter) SetLimits(limits Limits) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.limits = limits.withDefaults()
+}
+
+// chatRate returns the per-chat refill rate in tokens per second. Callers must hold l.mu.
+func (l *Limiter) chatRate() float64 {
+	return float64(time.Second) / float64(l.limits.ChatInterval)
+}
+
+// chat returns the bucket of a chat, dropping idle buckets first. Callers must hold l.mu.
+func (l *Limiter) chat(now time.Time, chatID int64) *bucket {
+	b, ok := l.chats[chatID]
+	if ok {
+		return b
+	}
+	if len(l.chats) >= 1024 {
+		full := time.Duration(float64(l.limits.ChatBurst) / l.chatRate() * float64(time.Second))
+		for id, c := range l.chats {
+			if l.waiting[id] == 0 && now.Sub(c.last) > full {
+				delete(l.chats, id)
+			}
+		}
+	}
+	b = &bucket{}
+	l.chats[chatID] = b
+	return b
+}
+
+// Wait blocks until a request to the chat may be sent or ctx is done.
+func (l *Limiter) Wait(ctx context.Context, chatID int64) error {
+	now := time.Now()
+	l.mu.Lock()
+	delay := max(
+		l.global.reserve(now, float64(l.limits.GlobalRate), float64(l.limits.GlobalRate)),
+		l.chat(now, chatID).reserve(now, l.chatRate(), float64(l.limits.ChatBurst)),
+	)
+	if delay > 0 {
+		l.stats.Delayed++
+		l.stats.Pending++
+		l.waiting[chatID]++
+	}
+	l.mu.Unlock()
+
+	if delay > 0 {
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+		select {
+		case <-ctx.Done():
+		case <-timer.C:
+		}
+
+		l.mu.Lock()
+		l.stats.Pending--
+		if l.waiting[chatID]--; l.waiting[chatID] <= 0 {
+			delete(l.waiting, chatID)
+		}
+		l.mu.Unlock()
+
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+	}
+
+	l.mu.Lock()
+	l.stats.Sent++
+	l.mu.Unlock()
+	return nil
+}
+
+// Backoff holds back requests for d after Telegram answered 429 to a request
+// to a chat. Telegram's flood control isn't only per chat, so requests to
+// every chat wait, not only those to the chat that was rejected.
+func (l *Limiter) Backoff(chatID int64, d time.Duration) {
+	now := time.Now()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.stats.RateLimited++
+	l.global.block(now, d, float64(l.limits.GlobalRate))
+	l.chat(now, chatID).block(now, d, l.chatRate())
+}
+
+// Stats returns the queue metrics.
+func (l *Limiter) Stats() QueueStats {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	stats := l.stats
+	stats.Chats = len(l.waiting)
+	return stats
+}
+
+// retried counts a retried request.
+func (l *Limiter) retried() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.stats.Retried++
+}
+
+// maxRetries returns the retry count for 429 responses.
+func (l *Limiter) maxRetries() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.limits.MaxRetries
+}
+
+// RetryAfter returns how long Telegram asked to wait if err is a 429 Too Many Requests response.
+func RetryAfter(err error) (time.Duration, bool) {
+	var apiErr *ta.Error
+	if !errors.As(err, &apiErr) || apiErr.ErrorCode != http.StatusTooManyRequests {
+		return 0, false
+	}
+	if apiErr.Parameters == nil || apiErr.Parameters.RetryAfter <= 0 {
+		return 0, true
+	}
+	return time.Duration(apiErr.Parameters.RetryAfter) * time.Second, true
+}
+
+// SetLimits replaces the limits used to pace outgoing messages.
+func (b *Bot) SetLimits(limits Limits) {
+	if b.limiter == nil {
+		return
+	}
+	b.limiter.SetLimits(limits)
+}
+
+// QueueStats returns the metrics of the outgoing message queue.
+func (b *Bot) QueueStats() QueueStats {
+	if b.limiter == nil {
+		return QueueStats{}
+	}
+	return b.limiter.Stats()
+}
+
+// paced sends a request to a chat through the send queue and passes a
+// final failure to the failure observer.
+func (b *Bot) paced(ctx context.Context, chatID int64, send func() (*telego.Message, error)) (*telego.Message, error) {
+	msg, err := b.pace(ctx, chatID, send)
+	if err != nil {
+		b.failed(ctx, chatID, err)
+	}
+	return msg, err
+}
+
+// pace sends a request through the send queue. Requests rejected with 429
+// are retried after the requested delay, or with exponential backoff if
+// Telegram gave none, until MaxRetries is reached. Captured requests skip the
+// queue.
+func (b *Bot) pace(ctx context.Context, chatID int64, send func() (*telego.Message, error)) (*telego.Message, error) {
+	if b.limiter == nil || captured(ctx) != nil {
+		msg, err := send()
+		b.requested(ctx, chatID, err)
+		return msg, err
+	}
+	for attempt := 0; ; attempt++ {
+		if err := b.limiter.Wait(ctx, chatID); err != nil {
+			return nil, err
+		}
+		msg, err := send()
+		b.requested(ctx, chatID, err)
+		retryAfter, limited := RetryAfter(err)
+		if !limited {
+			return msg, err
+		}
+		if retryAfter <= 0 {
+			retryAfter = time.Second << attempt
+		}
+		b.limiter.Backoff(chatID, retryAfter)
+		if attempt >= b.limiter.maxRetries() {
+			return msg, err
+		}
+		b.limiter.retried()
+	}
+}
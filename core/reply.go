@@ -0,0 +1,171 @@
+// Package core provides reply keyboard building functionality.
+package core
+
+import (
+	"context"
+
+	"github.com/mymmrac/telego"
+	"github.com/mymmrac/telego/telegoutil"
+)
+
+// Poll types accepted by PollButton.
+const (
+	// PollTypeQuiz lets the user create quizzes only.
+	PollTypeQuiz = "quiz"
+	// PollTypeRegular lets the user create regular polls only.
+	PollTypeRegular = "regular"
+)
+
+// ReplyKeyboardBuilder provides a fluent interface for building reply keyboards,
+// which replace the user's letter keyboard and send their button text as a message.
+type ReplyKeyboardBuilder struct {
+	rows        [][]telego.KeyboardButton
+	oneTime     bool
+	resize      bool
+	placeholder string
+}
+
+// NewReplyKeyboard creates a new reply keyboard builder instance.
+func NewReplyKeyboard() *ReplyKeyboardBuilder {
+	return &ReplyKeyboardBuilder{
+		rows: make([][]telego.KeyboardButton, 0),
+	}
+}
+
+// Row adds a row of buttons to the keyboard.
+func (kb *ReplyKeyboardBuilder) Row(buttons ...telego.KeyboardButton) *ReplyKeyboardBuilder {
+	if len(buttons) > 0 {
+		kb.rows = append(kb.rows, buttons)
+	}
+	return kb
+}
+
+// Text adds a single text button as a new row.
+func (kb *ReplyKeyboardBuilder) Text(text string) *ReplyKeyboardBuilder {
+	return kb.Row(TextButton(text))
+}
+
+// Contact adds a button that shares the user's phone number as a new row.
+func (kb *ReplyKeyboardBuilder) Contact(text string) *ReplyKeyboardBuilder {
+	return kb.Row(ContactButton(text))
+}
+
+// Location adds a button that shares the user's location as a new row.
+func (kb *ReplyKeyboardBuilder) Location(text string) *ReplyKeyboardBuilder {
+	return kb.Row(LocationButton(text))
+}
+
+// Poll adds a button that lets the user create a poll as a new row.
+func (kb *ReplyKeyboardBuilder) Poll(text, pollType string) *ReplyKeyboardBuilder {
+	return kb.Row(PollButton(text, pollType))
+}
+
+// OneTime hides the keyboard after a button is pressed.
+// The user can still bring it back with the keyboard icon.
+func (kb *ReplyKeyboardBuilder) OneTime() *ReplyKeyboardBuilder {
+	kb.oneTime = true
+	return kb
+}
+
+// Resize fits the keyboard height to its buttons instead of the letter keyboard's.
+func (kb *ReplyKeyboardBuilder) Resize() *ReplyKeyboardBuilder {
+	kb.resize = true
+	return kb
+}
+
+// Placeholder sets the hint shown in the input field while the keyboard is active.
+func (kb *ReplyKeyboardBuilder) Placeholder(text string) *ReplyKeyboardBuilder {
+	kb.placeholder = text
+	return kb
+}
+
+// Build constructs and returns the ReplyKeyboardMarkup.
+// Returns nil if no buttons were added.
+func (kb *ReplyKeyboardBuilder) Build() *telego.ReplyKeyboardMarkup {
+	if len(kb.rows) == 0 {
+		return nil
+	}
+	return &telego.ReplyKeyboardMarkup{
+		Keyboard:              kb.rows,
+		OneTimeKeyboard:       kb.oneTime,
+		ResizeKeyboard:        kb.resize,
+		InputFieldPlaceholder: kb.placeholder,
+	}
+}
+
+// TextButton creates a reply button that sends its text as a message.
+func TextButton(text string) telego.KeyboardButton {
+	return telegoutil.KeyboardButton(text)
+}
+
+// ContactButton creates a reply button that shares the user's phone number.
+// Only works in private chats.
+func ContactButton(text string) telego.KeyboardButton {
+	return telegoutil.KeyboardButton(text).WithRequestContact()
+}
+
+// LocationButton creates a reply button that shares the user's location.
+// Only works in private chats.
+func LocationButton(text string) telego.KeyboardButton {
+	return telegoutil.KeyboardButton(text).WithRequestLocation()
+}
+
+// PollButton creates a reply button that lets the user create a poll and send it.
+// pollType is PollTypeQuiz, PollTypeRegular, or "" for any poll. Only works in private chats.
+func PollButton(text, pollType string) telego.KeyboardButton {
+	return telegoutil.KeyboardButton(text).WithRequestPoll(&telego.KeyboardButtonPollType{Type: pollType})
+}
+
+// RemoveKeyboard returns the markup that removes the current reply keyboard.
+func RemoveKeyboard() *telego.ReplyKeyboardRemove {
+	return telegoutil.ReplyKeyboardRemove()
+}
+
+// SendMessageWithMarkup sends a message with any reply markup: an inline keyboard,
+// a reply keyboard, a keyboard removal, or a forced reply.
+// Reply keyboards can only be attached when sending, not by editing a message.
+func (b *Bot) SendMessageWithMarkup(ctx context.Context, chatID int64, topicID int, text string, markup telego.ReplyMarkup, entities ...telego.MessageEntity) (*telego.Message, error) {
+	if b.bot == nil {
+		return nil, nil
+	}
+
+	if keyboard, ok := markup.(*telego.InlineKeyboardMarkup); ok && keyboard != nil {
+		shortened, err := b.shortenKeyboard(ctx, keyboard)
+		if err != nil {
+			return nil, err
+		}
+		markup = shortened
+	}
+
+	params := &telego.SendMessageParams{
+		ChatID:      telegoutil.ID(chatID),
+		Text:        text,
+		ParseMode:   parseMode(ctx, entities),
+		ReplyMarkup: markup,
+		LinkPreviewOptions: &telego.LinkPreviewOptions{
+			IsDisabled: true,
+		},
+	}
+
+	if topicID > 0 {
+		params.MessageThreadID = topicID
+	}
+
+	if len(entities) > 0 {
+		params.Entities = entities
+	}
+
+	return b.sent(b.paced(ctx, chatID, func() (*telego.Message, error) {
+		return b.bot.SendMessage(ctx, params)
+	}))
+}
+
+// ClearReplyKeyboard removes the reply keyboard shown in a chat without leaving
+// a message behind: a placeholder carrying the removal is sent and deleted again.
+func (b *Bot) ClearReplyKeyboard(ctx context.Context, chatID int64, topicID int) error {
+	msg, err := b.SendMessageWithMarkup(ctx, chatID, topicID, "…", RemoveKeyboard())
+	if err != nil || msg == nil {
+		return err
+	}
+	return b.DeleteMessage(ctx, chatID, msg.MessageID)
+}
@@ -0,0 +1,41 @@
+package core
+
+import (
+	"context"
+	"sync"
+)
+
+// rolesKey is the context key holding the role lookup of the user being handled.
+type rolesKey struct{}
+
+// rolesLookup is the role lookup of a user.
+type rolesLookup struct {
+	userID int64
+	lookup func() []string
+}
+
+// WithRoles returns a context carrying a lookup of the current user's roles.
+// The lookup runs at most once, when the roles are first needed.
+func WithRoles(ctx context.Context, userID int64, lookup func() []string) context.Context {
+	return context.WithValue(ctx, rolesKey{}, rolesLookup{userID: userID, lookup: sync.OnceValue(lookup)})
+}
+
+// RolesFrom returns the roles of the user being handled, or nil if ctx
+// carries no role lookup, e.g. outside update handling.
+func RolesFrom(ctx context.Context) []string {
+	r, ok := ctx.Value(rolesKey{}).(rolesLookup)
+	if !ok {
+		return nil
+	}
+	return r.lookup()
+}
+
+// RolesFor returns the roles of a user if it is the user being handled, or
+// nil otherwise, so callers can fall back to looking them up.
+func RolesFor(ctx context.Context, userID int64) []string {
+	r, ok := ctx.Value(rolesKey{}).(rolesLookup)
+	if !ok || r.userID != userID {
+		return nil
+	}
+	return r.lookup()
+}
@@ -0,0 +1,136 @@
+// Package core provides a catalog of commonly used symbols by semantic name.
+package core
+
+import "strings"
+
+// Symbols maps semantic names to emoji and symbols, so handlers and configs
+// reference "status.ok" or "arrow.up" instead of pasting emoji. Bots can add
+// or override entries during initialization, before messages are built.
+//
+// Besides these names, "flag.<iso>" resolves to the flag of an ISO 3166-1
+// alpha-2 country code, e.g. "flag.de".
+var Symbols = map[string]string{
+	// Status
+	"status.ok":      "✅",
+	"status.error":   "❌",
+	"status.warning": "⚠️",
+	"status.info":    "ℹ️",
+	"status.pending": "⏳",
+	"status.blocked": "🚫",
+	"status.new":     "🆕",
+
+	// Status dots
+	"dot.green":  "🟢",
+	"dot.yellow": "🟡",
+	"dot.orange": "🟠",
+	"dot.red":    "🔴",
+	"dot.blue":   "🔵",
+	"dot.purple": "🟣",
+	"dot.white":  "⚪",
+	"dot.black":  "⚫",
+
+	// Arrows and trends
+	"arrow.up":         "⬆️",
+	"arrow.down":       "⬇️",
+	"arrow.left":       "⬅️",
+	"arrow.right":      "➡️",
+	"arrow.up_right":   "↗️",
+	"arrow.down_right": "↘️",
+	"arrow.refresh":    "🔄",
+	"trend.up":         "📈",
+	"trend.down":       "📉",
+
+	// Navigation and actions
+	"nav.back":        "◀️",
+	"nav.next":        "▶️",
+	"nav.home":        "🏠",
+	"nav.menu":        "☰",
+	"nav.close":       "✖️",
+	"action.edit":     "✏️",
+	"action.trash":    "🗑",
+	"action.add":      "➕",
+	"action.remove":   "➖",
+	"action.search":   "🔍",
+	"action.settings": "⚙️",
+
+	// Objects
+	"lock":     "🔒",
+	"unlock":   "🔓",
+	"key":      "🔑",
+	"bell":     "🔔",
+	"bell.off": "🔕",
+	"clock":    "🕐",
+	"calendar": "📅",
+	"pin":      "📌",
+	"link":     "🔗",
+	"money":    "💰",
+	"wallet":   "👛",
+	"chart":    "📊",
+	"fire":     "🔥",
+	"star":     "⭐",
+	"gift":     "🎁",
+	"rocket":   "🚀",
+
+	// Chains
+	"chain.bitcoin":  "₿",
+	"chain.ethereum": "Ξ",
+	"chain.solana":   "◎",
+	"chain.ton":      "💎",
+	"chain.tron":     "🔺",
+	"chain.bsc":      "🟡",
+	"chain.polygon":  "🟣",
+	"chain.arbitrum": "🔵",
+	"chain.base":     "🔵",
+
+	// Tokens
+	"token.btc":  "₿",
+	"token.eth":  "Ξ",
+	"token.sol":  "◎",
+	"token.ton":  "💎",
+	"token.usdt": "💵",
+	"token.usdc": "💵",
+	"token.dai":  "💵",
+	"token.doge": "🐕",
+}
+
+// Symbol returns the symbol with a semantic name, or "" if the name is unknown.
+// Names are case-insensitive; "flag.<iso>" names resolve with Flag.
+func Symbol(name string) string {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if code, ok := strings.CutPrefix(name, "flag."); ok {
+		return Flag(code)
+	}
+	return Symbols[name]
+}
+
+// Flag returns the flag emoji of an ISO 3166-1 alpha-2 country code such as
+// "DE" or "us", or "" if the code isn't two letters.
+func Flag(isoCode string) string {
+	code := strings.ToUpper(strings.TrimSpace(isoCode))
+	if len(code) != 2 {
+		return ""
+	}
+	var flag strings.Builder
+	for _, c := range code {
+		if c < 'A' || c > 'Z' {
+			return ""
+		}
+		// Regional indicator symbols start at U+1F1E6 for "A"
+		flag.WriteRune(0x1F1E6 + c - 'A')
+	}
+	return flag.String()
+}
+
+// StatusDot returns a green dot if ok is true, or a red dot otherwise.
+func StatusDot(ok bool) string {
+	if ok {
+		return Symbols["dot.green"]
+	}
+	return Symbols["dot.red"]
+}
+
+// TokenSymbol returns the symbol of a token ticker such as "ETH", or "" if
+// the catalog has none.
+func TokenSymbol(ticker string) string {
+	return Symbol("token." + ticker)
+}
@@ -0,0 +1,116 @@
+// Package core provides the built-in texts of keyboards and messages.
+package core
+
+import (
+	"context"
+	"strings"
+	"sync"
+)
+
+// Strings are the built-in texts of navigation buttons, pagination, message
+// decorations, and default validation messages. Deployments override them to
+// rebrand or translate the built-in UI; see config.ThemeConfig.
+type Strings struct {
+	Back          string // Label of back buttons
+	MainMenu      string // Label of main menu buttons
+	Cancel        string // Label of cancel buttons
+	Loading       string // Label of the placeholder button shown while buttons load
+	PrevPage      string // Label of the previous page button
+	NextPage      string // Label of the next page button
+	PageIndicator string // Format of the page indicator, given the current and total pages
+	Separator     string // Line appended by Builder.Separator
+
+	// Default validation messages, used by steps without an error_msg
+	InvalidNumber  string // Input of number steps that isn't a number
+	NumberTooSmall string // Number below the step's minimum, formatted with it
+	NumberTooLarge string // Number above the step's maximum, formatted with it
+	InvalidAddress string // Input of address steps that isn't an Ethereum address
+	InvalidEmail   string // Input of email steps that isn't an email address
+	InvalidFormat  string // Input not matching a regex step's pattern
+	InvalidValue   string // Input that doesn't convert to the type declared in the flow's schema
+	Required       string // Required key missing when the flow completes, shown on the step storing it
+
+	// Default file validation messages of photo and document steps
+	FileTooLarge       string // File above the step's max_size, formatted with it
+	FileTypeNotAllowed string // Document whose MIME type isn't in the step's allowed_mime
+	ImageTooSmall      string // Photo below the step's min_dimensions, formatted with them
+}
+
+// DefaultStrings are the built-in English texts.
+var DefaultStrings = Strings{
+	Back:          "⬅️ Back",
+	MainMenu:      "🏠 Main Menu",
+	Cancel:        "❌ Cancel",
+	Loading:       "⏳ Loading…",
+	PrevPage:      "⬅️",
+	NextPage:      "➡️",
+	PageIndicator: "%d/%d",
+	Separator:     "━━━━━━━━━━━━━━━",
+
+	InvalidNumber:  "Please enter a valid number",
+	NumberTooSmall: "Number cannot be less than %s",
+	NumberTooLarge: "Number cannot be greater than %s",
+	InvalidAddress: "Please enter a valid Ethereum address",
+	InvalidEmail:   "Please enter a valid email address",
+	InvalidFormat:  "Input format is incorrect",
+	InvalidValue:   "Please enter a valid value",
+	Required:       "Please answer this question",
+
+	FileTooLarge:       "File is too large, the limit is %s",
+	FileTypeNotAllowed: "This file type is not accepted",
+	ImageTooSmall:      "Image is too small, it must be at least %s pixels",
+}
+
+// Override returns s with the non-empty texts of o replacing its own.
+func (s Strings) Override(o Strings) Strings {
+	for _, f := range []struct{ dst, src *string }{
+		{&s.Back, &o.Back},
+		{&s.MainMenu, &o.MainMenu},
+		{&s.Cancel, &o.Cancel},
+		{&s.Loading, &o.Loading},
+		{&s.PrevPage, &o.PrevPage},
+		{&s.NextPage, &o.NextPage},
+		{&s.PageIndicator, &o.PageIndicator},
+		{&s.Separator, &o.Separator},
+		{&s.InvalidNumber, &o.InvalidNumber},
+		{&s.NumberTooSmall, &o.NumberTooSmall},
+		{&s.NumberTooLarge, &o.NumberTooLarge},
+		{&s.InvalidAddress, &o.InvalidAddress},
+		{&s.InvalidEmail, &o.InvalidEmail},
+		{&s.InvalidFormat, &o.InvalidFormat},
+		{&s.InvalidValue, &o.InvalidValue},
+		{&s.Required, &o.Required},
+		{&s.FileTooLarge, &o.FileTooLarge},
+		{&s.FileTypeNotAllowed, &o.FileTypeNotAllowed},
+		{&s.ImageTooSmall, &o.ImageTooSmall},
+	} {
+		if strings.TrimSpace(*f.src) != "" {
+			*f.dst = *f.src
+		}
+	}
+	return s
+}
+
+// stringsKey is the context key holding the built-in texts for the user being handled.
+type stringsKey struct{}
+
+// WithStrings returns a context carrying the built-in texts for the current user.
+func WithStrings(ctx context.Context, s Strings) context.Context {
+	return WithStringsFunc(ctx, func() Strings { return s })
+}
+
+// WithStringsFunc returns a context carrying a lookup of the built-in texts
+// for the current user. The lookup runs at most once, when the texts are
+// first needed.
+func WithStringsFunc(ctx context.Context, lookup func() Strings) context.Context {
+	return context.WithValue(ctx, stringsKey{}, sync.OnceValue(lookup))
+}
+
+// StringsFrom returns the built-in texts for the user being handled, or
+// DefaultStrings if ctx carries none, e.g. outside update handling.
+func StringsFrom(ctx context.Context) Strings {
+	if lookup, ok := ctx.Value(stringsKey{}).(func() Strings); ok {
+		return lookup()
+	}
+	return DefaultStrings
+}
@@ -0,0 +1,89 @@
+// Package core provides core functionality for Telegram Bot operations.
+package core
+
+import "sync"
+
+// DefaultThreadSize is the default number of messages tracked per thread.
+const DefaultThreadSize = 20
+
+// ThreadMessage is a bot message tracked in a thread.
+type ThreadMessage struct {
+	ChatID    int64 // Chat the message was sent to
+	MessageID int   // Message ID within the chat
+}
+
+// ThreadTracker tracks the bot's most recent messages per logical thread
+// (e.g. per conversation), so intermediate messages can be replied to and
+// cleaned up together. Tracking is in memory; only the last N messages of each
+// thread are kept.
+type ThreadTracker struct {
+	size    int                        // Maximum messages tracked per thread
+	threads map[string][]ThreadMessage // Tracked messages by thread key, oldest first
+
+	mu sync.Mutex // Mutex for thread-safe operations
+}
+
+// NewThreadTracker creates a tracker keeping the last size messages per thread.
+// A size of 0 uses DefaultThreadSize.
+func NewThreadTracker(size int) *ThreadTracker {
+	if size <= 0 {
+		size = DefaultThreadSize
+	}
+	return &ThreadTracker{size: size, threads: make(map[string][]ThreadMessage)}
+}
+
+// Track records a message as the latest in a thread, forgetting the oldest
+// message if the thread is full.
+func (t *ThreadTracker) Track(thread string, chatID int64, messageID int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	msgs := append(t.threads[thread], ThreadMessage{ChatID: chatID, MessageID: messageID})
+	if len(msgs) > t.size {
+		msgs = msgs[len(msgs)-t.size:]
+	}
+	t.threads[thread] = msgs
+}
+
+// Last returns the latest message of a thread.
+// Returns false if the thread has no tracked messages.
+func (t *ThreadTracker) Last(thread string) (ThreadMessage, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	msgs := t.threads[thread]
+	if len(msgs) == 0 {
+		return ThreadMessage{}, false
+	}
+	return msgs[len(msgs)-1], true
+}
+
+// Messages returns the tracked messages of a thread, oldest first.
+func (t *ThreadTracker) Messages(thread string) []ThreadMessage {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]ThreadMessage(nil), t.threads[thread]...)
+}
+
+// Trim forgets all but the last keep messages of a thread and returns the forgotten ones.
+func (t *ThreadTracker) Trim(thread string, keep int) []ThreadMessage {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	msgs := t.threads[thread]
+	if len(msgs) <= keep {
+		return nil
+	}
+	cut := len(msgs) - max(keep, 0)
+	removed := append([]ThreadMessage(nil), msgs[:cut]...)
+	if cut == len(msgs) {
+		delete(t.threads, thread)
+	} else {
+		t.threads[thread] = append([]ThreadMessage(nil), msgs[cut:]...)
+	}
+	return removed
+}
+
+// Forget stops tracking a thread without deleting its messages.
+func (t *ThreadTracker) Forget(thread string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.threads, thread)
+}
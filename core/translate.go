@@ -0,0 +1,61 @@
+// Package core provides the language and translations of the user being handled.
+package core
+
+import (
+	"context"
+	"sync"
+)
+
+// Translator looks up the message with a key in a user's language.
+// Returns false if no catalog has the key.
+type Translator func(key string) (string, bool)
+
+// languageKey and translatorKey are the context keys holding the language
+// and translator of the user being handled.
+type (
+	languageKey   struct{}
+	translatorKey struct{}
+)
+
+// WithLanguage returns a context carrying a lookup of the current user's
+// language, such as "de" or "pt-BR". The lookup runs at most once, when the
+// language is first needed.
+func WithLanguage(ctx context.Context, lookup func() string) context.Context {
+	return context.WithValue(ctx, languageKey{}, sync.OnceValue(lookup))
+}
+
+// LanguageFrom returns the language of the user being handled, or "" if ctx
+// carries no language lookup, e.g. outside update handling.
+func LanguageFrom(ctx context.Context) string {
+	lookup, _ := ctx.Value(languageKey{}).(func() string)
+	if lookup == nil {
+		return ""
+	}
+	return lookup()
+}
+
+// WithTranslator returns a context carrying the translator of the current user.
+func WithTranslator(ctx context.Context, t Translator) context.Context {
+	return context.WithValue(ctx, translatorKey{}, t)
+}
+
+// TranslatorFrom returns the translator of the user being handled, or nil if
+// ctx carries none.
+func TranslatorFrom(ctx context.Context) Translator {
+	t, _ := ctx.Value(translatorKey{}).(Translator)
+	return t
+}
+
+// Translate looks up the message with a key in the language of the user being
+// handled. Returns false if the key is empty, ctx carries no translator, or no
+// catalog has the key.
+func Translate(ctx context.Context, key string) (string, bool) {
+	if key == "" {
+		return "", false
+	}
+	t := TranslatorFrom(ctx)
+	if t == nil {
+		return "", false
+	}
+	return t(key)
+}
@@ -0,0 +1,186 @@
+// Package core provides core functionality for Telegram Bot operations.
+package core
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mymmrac/telego"
+	"github.com/mymmrac/telego/telegoutil"
+)
+
+// Errors of Web App init data validation.
+var (
+	// ErrInvalidInitData is returned when init data is malformed or its hash
+	// doesn't match, e.g. it was forged or signed for another bot.
+	ErrInvalidInitData = errors.New("invalid web app init data")
+
+	// ErrInitDataExpired is returned when init data was signed longer ago than allowed.
+	ErrInitDataExpired = errors.New("web app init data expired")
+)
+
+// WebAppUser is a user in Web App init data.
+type WebAppUser struct {
+	ID           int64  `json:"id"`
+	IsBot        bool   `json:"is_bot,omitempty"`
+	FirstName    string `json:"first_name"`
+	LastName     string `json:"last_name,omitempty"`
+	Username     string `json:"username,omitempty"`
+	LanguageCode string `json:"language_code,omitempty"`
+	IsPremium    bool   `json:"is_premium,omitempty"`
+	PhotoURL     string `json:"photo_url,omitempty"`
+}
+
+// WebAppInitData is the validated init data of a Web App, sent by its page to
+// the bot's backend to prove who opened it.
+type WebAppInitData struct {
+	QueryID      string      // Query ID for answering with AnswerWebAppQuery; empty unless opened from an inline button
+	User         *WebAppUser // User who opened the Web App
+	Receiver     *WebAppUser // Chat partner of the user, for Web Apps opened from the attachment menu
+	ChatType     string      // Type of the chat the Web App was opened from
+	ChatInstance string      // Global identifier of the chat the Web App was opened from
+	StartParam   string      // Value of the startapp parameter of the link that opened the Web App
+	AuthDate     time.Time   // When Telegram signed the init data
+	Values       url.Values  // All fields, including ones not parsed above
+}
+
+// ValidateWebAppInitData checks that init data, the value of
+// Telegram.WebApp.initData, was signed by Telegram for the bot with the token,
+// and was signed within maxAge; maxAge <= 0 accepts any age.
+// Returns ErrInvalidInitData or ErrInitDataExpired if not.
+func ValidateWebAppInitData(token, initData string, maxAge time.Duration) (*WebAppInitData, error) {
+	values, err := url.ParseQuery(initData)
+	if err != nil {
+		return nil, ErrInvalidInitData
+	}
+	hash := values.Get("hash")
+	if hash == "" {
+		return nil, ErrInvalidInitData
+	}
+
+	// The data-check string is every other field as key=value, sorted by key
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		if k != "hash" {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	lines := make([]string, len(keys))
+	for i, k := range keys {
+		lines[i] = k + "=" + values.Get(k)
+	}
+
+	secret := hmacSHA256([]byte("WebAppData"), []byte(token))
+	expected := hmacSHA256(secret, []byte(strings.Join(lines, "\n")))
+	actual, err := hex.DecodeString(hash)
+	if err != nil || !hmac.Equal(expected, actual) {
+		return nil, ErrInvalidInitData
+	}
+
+	authDate, err := strconv.ParseInt(values.Get("auth_date"), 10, 64)
+	if err != nil {
+		return nil, ErrInvalidInitData
+	}
+	data := &WebAppInitData{
+		QueryID:      values.Get("query_id"),
+		ChatType:     values.Get("chat_type"),
+		ChatInstance: values.Get("chat_instance"),
+		StartParam:   values.Get("start_param"),
+		AuthDate:     time.Unix(authDate, 0),
+		Values:       values,
+	}
+	if maxAge > 0 && time.Since(data.AuthDate) > maxAge {
+		return nil, ErrInitDataExpired
+	}
+	if data.User, err = webAppUser(values.Get("user")); err != nil {
+		return nil, ErrInvalidInitData
+	}
+	if data.Receiver, err = webAppUser(values.Get("receiver")); err != nil {
+		return nil, ErrInvalidInitData
+	}
+	return data, nil
+}
+
+// ValidateWebAppInitData checks that init data was signed by Telegram for this
+// bot within maxAge. See the package function ValidateWebAppInitData.
+func (b *Bot) ValidateWebAppInitData(initData string, maxAge time.Duration) (*WebAppInitData, error) {
+	if b.bot == nil {
+		return nil, fmt.Errorf("bot is not initialized")
+	}
+	return ValidateWebAppInitData(b.bot.Token(), initData, maxAge)
+}
+
+// webAppUser decodes a user field of init data, or returns nil if it's empty.
+func webAppUser(field string) (*WebAppUser, error) {
+	if field == "" {
+		return nil, nil
+	}
+	var user WebAppUser
+	if err := json.Unmarshal([]byte(field), &user); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// hmacSHA256 returns the HMAC-SHA256 of data with the key.
+func hmacSHA256(key, data []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(data)
+	return mac.Sum(nil)
+}
+
+// WebAppReplyButton creates a reply button that opens a Web App. Data the Web
+// App sends with Telegram.WebApp.sendData arrives as a message with
+// web_app_data. Only works in private chats.
+func WebAppReplyButton(text, url string) telego.KeyboardButton {
+	return telegoutil.KeyboardButton(text).WithWebApp(&telego.WebAppInfo{URL: url})
+}
+
+// WebApp adds a button that opens a Web App as a new row.
+func (kb *ReplyKeyboardBuilder) WebApp(text, url string) *ReplyKeyboardBuilder {
+	return kb.Row(WebAppReplyButton(text, url))
+}
+
+// SetWebAppMenuButton sets the menu button of a private chat to open a Web App.
+// chatID 0 sets the default menu button of all private chats.
+func (b *Bot) SetWebAppMenuButton(ctx context.Context, chatID int64, text, url string) error {
+	return b.setMenuButton(ctx, chatID, &telego.MenuButtonWebApp{
+		Type:   telego.ButtonTypeWebApp,
+		Text:   text,
+		WebApp: telego.WebAppInfo{URL: url},
+	})
+}
+
+// SetCommandsMenuButton sets the menu button of a private chat to open the
+// bot's command list. chatID 0 sets the default of all private chats.
+func (b *Bot) SetCommandsMenuButton(ctx context.Context, chatID int64) error {
+	return b.setMenuButton(ctx, chatID, &telego.MenuButtonCommands{Type: telego.ButtonTypeCommands})
+}
+
+// ResetMenuButton restores Telegram's default menu button of a private chat.
+// chatID 0 resets the default of all private chats.
+func (b *Bot) ResetMenuButton(ctx context.Context, chatID int64) error {
+	return b.setMenuButton(ctx, chatID, &telego.MenuButtonDefault{Type: telego.ButtonTypeDefault})
+}
+
+// setMenuButton sets the menu button of a private chat, or the default one for chatID 0.
+func (b *Bot) setMenuButton(ctx context.Context, chatID int64, button telego.MenuButton) error {
+	if b.bot == nil {
+		return nil
+	}
+	return b.bot.SetChatMenuButton(ctx, &telego.SetChatMenuButtonParams{
+		ChatID:     chatID,
+		MenuButton: button,
+	})
+}
@@ -0,0 +1,132 @@
+package core
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// signInitData returns init data with the fields signed for the bot token as
+// Telegram signs it: the hash is the HMAC-SHA256 of the sorted key=value lines,
+// keyed with the HMAC-SHA256 of the token keyed with "WebAppData".
+func signInitData(token string, fields map[string]string) string {
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	lines := make([]string, len(keys))
+	values := url.Values{}
+	for i, k := range keys {
+		lines[i] = k + "=" + fields[k]
+		values.Set(k, fields[k])
+	}
+
+	secret := hmac.New(sha256.New, []byte("WebAppData"))
+	secret.Write([]byte(token))
+	mac := hmac.New(sha256.New, secret.Sum(nil))
+	mac.Write([]byte(strings.Join(lines, "\n")))
+	values.Set("hash", hex.EncodeToString(mac.Sum(nil)))
+	return values.Encode()
+}
+
+func TestValidateWebAppInitData(t *testing.T) {
+	const token = "123456:ABC-DEF"
+	authDate := strconv.FormatInt(time.Now().Add(-time.Minute).Unix(), 10)
+	oldDate := strconv.FormatInt(time.Now().Add(-48*time.Hour).Unix(), 10)
+	user := `{"id":42,"first_name":"Ada","username":"ada"}`
+
+	tests := []struct {
+		name     string
+		initData string
+		maxAge   time.Duration
+		wantErr  error
+		wantUser int64
+	}{
+		{
+			name:     "valid",
+			initData: signInitData(token, map[string]string{"auth_date": authDate, "query_id": "AAH", "user": user}),
+			maxAge:   time.Hour,
+			wantUser: 42,
+		},
+		{
+			name:     "valid without user",
+			initData: signInitData(token, map[string]string{"auth_date": authDate, "chat_type": "private"}),
+			maxAge:   time.Hour,
+		},
+		{
+			name:     "signed for another bot",
+			initData: signInitData("654321:XYZ", map[string]string{"auth_date": authDate, "user": user}),
+			maxAge:   time.Hour,
+			wantErr:  ErrInvalidInitData,
+		},
+		{
+			name:     "tampered field",
+			initData: strings.Replace(signInitData(token, map[string]string{"auth_date": authDate, "user": user}), "%22id%22%3A42", "%22id%22%3A43", 1),
+			maxAge:   time.Hour,
+			wantErr:  ErrInvalidInitData,
+		},
+		{
+			name:     "added field",
+			initData: signInitData(token, map[string]string{"auth_date": authDate}) + "&start_param=admin",
+			maxAge:   time.Hour,
+			wantErr:  ErrInvalidInitData,
+		},
+		{
+			name:     "missing hash",
+			initData: "auth_date=" + authDate,
+			wantErr:  ErrInvalidInitData,
+		},
+		{
+			name:     "malformed hash",
+			initData: "auth_date=" + authDate + "&hash=zz",
+			wantErr:  ErrInvalidInitData,
+		},
+		{
+			name:     "missing auth date",
+			initData: signInitData(token, map[string]string{"user": user}),
+			wantErr:  ErrInvalidInitData,
+		},
+		{
+			name:     "malformed user",
+			initData: signInitData(token, map[string]string{"auth_date": authDate, "user": "{"}),
+			wantErr:  ErrInvalidInitData,
+		},
+		{
+			name:     "expired",
+			initData: signInitData(token, map[string]string{"auth_date": oldDate, "user": user}),
+			maxAge:   24 * time.Hour,
+			wantErr:  ErrInitDataExpired,
+		},
+		{
+			name:     "any age",
+			initData: signInitData(token, map[string]string{"auth_date": oldDate, "user": user}),
+			wantUser: 42,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := ValidateWebAppInitData(token, tt.initData, tt.maxAge)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ValidateWebAppInitData error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			var userID int64
+			if data.User != nil {
+				userID = data.User.ID
+			}
+			if userID != tt.wantUser {
+				t.Errorf("user ID = %d, want %d", userID, tt.wantUser)
+			}
+		})
+	}
+}
@@ -0,0 +1,136 @@
+package tgwrapper
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strconv"
+
+	"github.com/0xVanfer/tg-listener/config"
+	"github.com/0xVanfer/tg-listener/conv"
+	"github.com/0xVanfer/tg-listener/eventlog"
+	"github.com/0xVanfer/tg-listener/ledger"
+)
+
+// Ledger returns the points/credits ledger.
+//
+// Example:
+//
+//	_, _ = wrapper.Ledger().Earn(ctx, userID, 100, "daily bonus")
+//	balance, _ := wrapper.Ledger().UserBalance(ctx, userID)
+func (w *Wrapper) Ledger() *ledger.Ledger {
+	w.storeMu.RLock()
+	defer w.storeMu.RUnlock()
+	return w.ledger
+}
+
+// setupCredits exposes user balances to conditions and templates as credits.balance.
+func (w *Wrapper) setupCredits() {
+	w.flowEngine.RegisterNamespace("credits", func(ctx context.Context, c *conv.Conversation) map[string]interface{} {
+		userID := c.UserID
+		if userID == 0 && c.ChatID > 0 {
+			// Private chat IDs are user IDs
+			userID = c.ChatID
+		}
+		if userID == 0 {
+			return nil
+		}
+		balance, err := w.Ledger().UserBalance(ctx, userID)
+		if err != nil {
+			return nil
+		}
+		return map[string]interface{}{"balance": balance}
+	})
+}
+
+// checkFlowCredits returns a FlowDeniedError if the user cannot afford the flow.
+func (w *Wrapper) checkFlowCredits(ctx context.Context, userID, chatID int64, flow *config.FlowConfig) error {
+	credits := flow.Credits
+	if credits == nil || credits.GetRequire() <= 0 {
+		return nil
+	}
+
+	balance, err := w.Ledger().UserBalance(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if balance >= credits.GetRequire() {
+		return nil
+	}
+	return w.insufficientCredits(ctx, userID, chatID, flow, balance)
+}
+
+// insufficientCredits builds the FlowDeniedError shown when a user cannot afford a flow.
+func (w *Wrapper) insufficientCredits(ctx context.Context, userID, chatID int64, flow *config.FlowConfig, balance int64) error {
+	c := conv.NewConversation(userID, chatID, 0, flow.ID, "", 0)
+	c.Set("required", flow.Credits.GetRequire())
+	c.Set("balance", balance)
+	return &FlowDeniedError{
+		FlowID: flow.ID,
+		Text:   w.flowEngine.RenderText(ctx, c, flow.Credits.GetInsufficientText()),
+	}
+}
+
+// creditsRef is the ledger reference of a conversation's charge, so it is
+// applied at most once.
+func creditsRef(c *conv.Conversation, flow *config.FlowConfig) string {
+	return "flow:" + flow.ID + ":" + strconv.FormatInt(c.UserID, 10) + ":" + strconv.FormatInt(c.CreatedAt.UnixNano(), 10)
+}
+
+// chargeFlowCredits deducts a flow's cost from the conversation's user.
+// The charge is keyed to the conversation, so it is applied at most once.
+func (w *Wrapper) chargeFlowCredits(ctx context.Context, c *conv.Conversation, flow *config.FlowConfig) error {
+	credits := flow.Credits
+	if credits == nil || credits.Cost <= 0 {
+		return nil
+	}
+
+	_, err := w.Ledger().Post(ctx, ledger.Transaction{
+		Postings: []ledger.Posting{
+			{Account: ledger.UserAccount(c.UserID), Amount: -credits.Cost},
+			{Account: ledger.SystemSpent, Amount: credits.Cost},
+		},
+		Memo: "flow " + flow.ID,
+		Ref:  creditsRef(c, flow),
+	})
+	return w.creditsError(ctx, c, flow, err)
+}
+
+// holdFlowCredits reserves the cost of a flow charged on completion when it
+// starts, so users can't complete flows they can't pay for.
+func (w *Wrapper) holdFlowCredits(ctx context.Context, c *conv.Conversation, flow *config.FlowConfig) error {
+	credits := flow.Credits
+	if credits == nil || credits.Cost <= 0 {
+		return nil
+	}
+	_, err := w.Ledger().Hold(ctx, c.UserID, credits.Cost, creditsRef(c, flow), "flow "+flow.ID)
+	return w.creditsError(ctx, c, flow, err)
+}
+
+// settleFlowCredits spends the credits held for a conversation if its flow
+// completed, and returns them to the user otherwise. Failures are logged and
+// recorded, since the conversation is over and nothing can be shown in it.
+func (w *Wrapper) settleFlowCredits(ctx context.Context, c *conv.Conversation, flow *config.FlowConfig) {
+	if flow.Credits == nil || flow.Credits.Cost <= 0 {
+		return
+	}
+	var err error
+	if c.GetState() == conv.StateCompleted {
+		_, err = w.Ledger().Settle(ctx, creditsRef(c, flow), "flow "+flow.ID)
+	} else {
+		_, err = w.Ledger().Release(ctx, c.UserID, creditsRef(c, flow), "flow "+flow.ID+" not completed")
+	}
+	if err != nil {
+		log.Printf("[Credits] Failed to settle flow %s for user %d: %v", flow.ID, c.UserID, err)
+		w.recordEvent(ctx, eventlog.Event{Type: eventlog.TypeError, UserID: c.UserID, ChatID: c.ChatID, FlowID: c.FlowID, StepID: c.StepID, Detail: "credits", Error: err.Error()})
+	}
+}
+
+// creditsError turns ErrInsufficientFunds into the FlowDeniedError shown to the user.
+func (w *Wrapper) creditsError(ctx context.Context, c *conv.Conversation, flow *config.FlowConfig, err error) error {
+	if errors.Is(err, ledger.ErrInsufficientFunds) {
+		balance, _ := w.Ledger().UserBalance(ctx, c.UserID)
+		return w.insufficientCredits(ctx, c.UserID, c.ChatID, flow, balance)
+	}
+	return err
+}
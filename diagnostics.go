@@ -0,0 +1,209 @@
+package tgwrapper
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mymmrac/telego"
+
+	"github.com/0xVanfer/tg-listener/anomaly"
+	"github.com/0xVanfer/tg-listener/core"
+	"github.com/0xVanfer/tg-listener/eventlog"
+)
+
+// Limits of the conversation dump of the debug command.
+const (
+	maxDebugData    = 3000 // Characters of the data JSON shown
+	maxDebugHistory = 10   // Most recent history entries shown
+)
+
+// eventCounts counts router and flow events since the start, whether the
+// event log is enabled or not.
+type eventCounts struct {
+	counts map[eventlog.Type]int64 // Events by type
+	mu     sync.Mutex              // Mutex for thread-safe counts access
+}
+
+// add counts an event.
+func (c *eventCounts) add(typ eventlog.Type) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.counts == nil {
+		c.counts = make(map[eventlog.Type]int64)
+	}
+	c.counts[typ]++
+}
+
+// get returns the number of events of a type.
+func (c *eventCounts) get(typ eventlog.Type) int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.counts[typ]
+}
+
+// registerDiagnostics registers the operator commands reporting runtime stats
+// and dumping conversation state.
+func (w *Wrapper) registerDiagnostics() {
+	admin := w.Config().Admin
+	w.router.RegisterCommand(admin.GetStatsCommand(), func(ctx context.Context, msg telego.Message) error {
+		if !w.IsOperator(ctx, msg.From.ID) {
+			return nil
+		}
+		text, entities := w.buildRuntimeStats()
+		_, err := w.bot.SendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, text, entities...)
+		return err
+	})
+
+	w.router.RegisterCommand(admin.GetDebugCommand(), func(ctx context.Context, msg telego.Message) error {
+		if !w.IsOperator(ctx, msg.From.ID) {
+			return nil
+		}
+		text, entities := w.buildConversationDump(admin.GetDebugCommand(), msg, strings.Fields(msg.Text)[1:])
+		_, err := w.bot.SendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, text, entities...)
+		return err
+	})
+	w.router.ExemptCommand(admin.GetDebugCommand())
+}
+
+// buildRuntimeStats renders conversation, update, dispatch, and Telegram API
+// statistics since the start.
+func (w *Wrapper) buildRuntimeStats() (string, []telego.MessageEntity) {
+	b := core.NewBuilder()
+	b.Header("📊 Runtime Stats")
+	b.KeyValue("Uptime", w.uptime().String())
+	b.KeyValue("Active conversations", strconv.Itoa(w.convManager.Count()))
+	b.KeyValue("Maintenance", onOff(w.maintenance.Load()))
+
+	b.Ln().SubHeader("Updates")
+	b.KeyValue("Received", strconv.FormatInt(w.eventCounts.get(eventlog.TypeUpdate), 10))
+	b.KeyValue("Commands", strconv.FormatInt(w.eventCounts.get(eventlog.TypeCommand), 10))
+	b.KeyValue("Callbacks", strconv.FormatInt(w.eventCounts.get(eventlog.TypeCallback), 10))
+	b.KeyValue("Unhandled", strconv.FormatInt(w.eventCounts.get(eventlog.TypeUnhandled), 10))
+	b.KeyValue("Handler errors", strconv.FormatInt(w.eventCounts.get(eventlog.TypeError), 10))
+	if w.dispatcher != nil {
+		s := w.dispatcher.Stats()
+		b.KeyValue("Waiting", strconv.Itoa(s.Depth()))
+		b.KeyValue("Running", fmt.Sprintf("%d/%d", s.Running, s.Workers))
+		if s.Shed > 0 || s.Pressured {
+			b.KeyValue("Shed", fmt.Sprintf("%d (backpressure %s)", s.Shed, onOff(s.Pressured)))
+		}
+	}
+
+	requests, errs := w.anomalies.Counts(time.Now())
+	b.Ln().SubHeader("Telegram API (last " + w.Config().Bot.APIAnomalies.GetWindow().String() + ")")
+	b.KeyValue("Requests", strconv.Itoa(requests))
+	failed := 0
+	kinds := make([]string, 0, len(errs))
+	for kind, n := range errs {
+		failed += n
+		kinds = append(kinds, string(kind))
+	}
+	rate := 0.0
+	if requests > 0 {
+		rate = float64(failed) / float64(requests) * 100
+	}
+	b.KeyValue("Error rate", fmt.Sprintf("%.1f%% (%d failed)", rate, failed))
+	sort.Strings(kinds)
+	for _, kind := range kinds {
+		b.KeyValue("  "+kind, strconv.Itoa(errs[anomaly.Kind(kind)]))
+	}
+	return b.Build()
+}
+
+// buildConversationDump renders the state of the operator's conversation in
+// the chat, or with the arguments "<user> [chat]" another user's.
+func (w *Wrapper) buildConversationDump(command string, msg telego.Message, args []string) (string, []telego.MessageEntity) {
+	b := core.NewBuilder()
+	userID, chatID, topicID := msg.From.ID, msg.Chat.ID, msg.MessageThreadID
+	if len(args) > 2 {
+		b.Text("Usage: ").Code("/" + command + " [user] [chat]")
+		return b.Build()
+	}
+	if len(args) > 0 {
+		id, err := strconv.ParseInt(args[0], 10, 64)
+		if err != nil {
+			b.Text("❌ Invalid user ID: ").Code(args[0])
+			return b.Build()
+		}
+		userID, chatID, topicID = id, id, 0
+	}
+	if len(args) > 1 {
+		id, err := strconv.ParseInt(args[1], 10, 64)
+		if err != nil {
+			b.Text("❌ Invalid chat ID: ").Code(args[1])
+			return b.Build()
+		}
+		chatID = id
+	}
+
+	c := w.convManager.GetIn(userID, chatID, topicID)
+	if c == nil {
+		b.Text(fmt.Sprintf("No active conversation for user %d in chat %d.", userID, chatID))
+		return b.Build()
+	}
+	v := c.View()
+
+	b.Header("🐞 Conversation")
+	b.KeyValueCode("User", strconv.FormatInt(v.UserID, 10))
+	b.KeyValueCode("Chat", strconv.FormatInt(v.ChatID, 10))
+	if v.TopicID != 0 {
+		b.KeyValueCode("Topic", strconv.Itoa(v.TopicID))
+	}
+	b.KeyValueCode("Flow", v.FlowID)
+	b.KeyValueCode("Step", v.StepID)
+	b.KeyValue("State", v.State.String())
+	for i := len(v.Callers) - 1; i >= 0; i-- {
+		b.KeyValueCode("Called from", v.Callers[i].FlowID+"/"+v.Callers[i].StepID)
+	}
+	b.KeyValue("Started", time.Since(v.CreatedAt).Round(time.Second).String()+" ago")
+	b.KeyValue("Idle", time.Since(v.UpdatedAt).Round(time.Second).String())
+	b.KeyValue("Expires in", time.Until(v.ExpiresAt).Round(time.Second).String())
+	if !v.StepDeadline.IsZero() {
+		b.KeyValue("Step times out in", time.Until(v.StepDeadline).Round(time.Second).String())
+	}
+	if v.InvalidInputs > 0 {
+		b.KeyValue("Invalid inputs", strconv.Itoa(v.InvalidInputs))
+	}
+	if v.Preview {
+		b.KeyValue("Preview", "yes")
+	}
+
+	data, err := json.MarshalIndent(v.Data, "", "  ")
+	if err != nil {
+		data = []byte(fmt.Sprint(v.Data))
+	}
+	dump := string(data)
+	if runes := []rune(dump); len(runes) > maxDebugData {
+		dump = string(runes[:maxDebugData]) + "\n…"
+	}
+	b.Ln().SubHeader("Data")
+	b.Pre(dump, "json")
+
+	if len(v.History) > 0 {
+		b.Ln().SubHeader("History")
+		history := v.History
+		if len(history) > maxDebugHistory {
+			b.Line(fmt.Sprintf("…%d earlier", len(history)-maxDebugHistory))
+			history = history[len(history)-maxDebugHistory:]
+		}
+		for _, h := range history {
+			b.Text("• ").Code(h.StepID).Line(fmt.Sprintf(" %q, %s ago", shorten(h.Input, 40), time.Since(h.Timestamp).Round(time.Second)))
+		}
+	}
+	return b.Build()
+}
+
+// shorten cuts text longer than n characters.
+func shorten(text string, n int) string {
+	runes := []rune(text)
+	if len(runes) > n {
+		return string(runes[:n]) + "…"
+	}
+	return text
+}
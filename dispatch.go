@@ -0,0 +1,132 @@
+package tgwrapper
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/mymmrac/telego"
+
+	"github.com/0xVanfer/tg-listener/alert"
+	"github.com/0xVanfer/tg-listener/config"
+	"github.com/0xVanfer/tg-listener/core"
+	"github.com/0xVanfer/tg-listener/dispatch"
+)
+
+// BackpressureFunc is called when backpressure on update processing starts or
+// ends, see bot.dispatch.backpressure.
+type BackpressureFunc func(ctx context.Context, stats dispatch.Stats)
+
+// OnBackpressure sets a callback function that is called when backpressure on
+// update processing starts or ends, e.g. to scale out or pause broadcasts
+// before latency explodes. It replaces the default of logging the change and
+// warning the warning chat when backpressure starts.
+func (w *Wrapper) OnBackpressure(fn BackpressureFunc) {
+	w.onBackpressure = fn
+}
+
+// dispatchUpdates creates the dispatcher configured in bot.dispatch, makes the
+// router wait for it, and returns the updates queued through it.
+func (w *Wrapper) dispatchUpdates(ctx context.Context, updates <-chan telego.Update) <-chan telego.Update {
+	cfg := w.Config().Bot.Dispatch
+	w.dispatcher = dispatch.New(cfg.GetWorkers(), cfg.IsOrdered())
+	w.dispatcher.SetPolicy(dispatchPolicy(cfg, cfg.GetWorkers()))
+	w.dispatcher.SetPools(dispatchPools(cfg))
+	w.dispatcher.SetActiveFunc(w.answersConversation)
+	w.dispatcher.SetPressureHook(func(stats dispatch.Stats) {
+		w.backpressureChanged(context.WithoutCancel(ctx), stats)
+	})
+	w.router.SetDispatcher(w.dispatcher)
+	return w.dispatcher.Feed(ctx, updates)
+}
+
+// dispatchPolicy converts the backpressure configuration for the dispatcher.
+func dispatchPolicy(cfg *config.DispatchConfig, workers int) dispatch.Policy {
+	var bp *config.BackpressureConfig
+	if cfg != nil {
+		bp = cfg.Backpressure
+	}
+	policy := dispatch.Policy{
+		HighWater: bp.GetHighWater(workers),
+		LowWater:  bp.GetLowWater(workers),
+		Shed:      make(map[string]bool),
+	}
+	if bp != nil {
+		policy.PrioritizeCallbacks = bp.PrioritizeCallbacks
+		for _, class := range bp.Shed {
+			policy.Shed[class] = true
+		}
+	}
+	return policy
+}
+
+// dispatchPools converts the worker pool configuration for the dispatcher.
+func dispatchPools(cfg *config.DispatchConfig) []dispatch.Pool {
+	if cfg == nil {
+		return nil
+	}
+	pools := make([]dispatch.Pool, 0, len(cfg.Pools))
+	for _, p := range cfg.Pools {
+		pools = append(pools, dispatch.Pool{Name: p.Name, Workers: p.Workers, Classes: p.Classes})
+	}
+	return pools
+}
+
+// answersConversation returns true if a message's sender is in a conversation
+// in its chat, so it runs in the pool of conversation input.
+func (w *Wrapper) answersConversation(update telego.Update) bool {
+	msg := update.Message
+	return msg != nil && msg.From != nil && w.convManager.Cached(msg.From.ID, msg.Chat.ID, msg.MessageThreadID)
+}
+
+// backpressureChanged calls the backpressure hook, or by default logs the
+// change and sends a warning when backpressure starts.
+func (w *Wrapper) backpressureChanged(ctx context.Context, stats dispatch.Stats) {
+	if fn := w.onBackpressure; fn != nil {
+		fn(ctx, stats)
+		return
+	}
+	if !stats.Pressured {
+		log.Printf("[Dispatch] Backpressure ended: %d updates waiting, %d shed", stats.Depth(), stats.Shed)
+		return
+	}
+	log.Printf("[Dispatch] Backpressure started: %d updates waiting, %d of %d workers busy",
+		stats.Depth(), stats.Running, stats.Workers)
+
+	chat := w.warningChat(alert.LevelWarning)
+	if chat == nil {
+		return
+	}
+	msg := core.NewBuilder().
+		Text("🐢 Update processing is saturated").Ln().
+		Text(fmt.Sprintf("%d updates waiting, %d of %d workers busy", stats.Depth(), stats.Running, stats.Workers))
+	if cfg := w.Config().Bot.Dispatch; cfg != nil && cfg.Backpressure != nil && len(cfg.Backpressure.Shed) > 0 {
+		msg.Ln().Text("Shedding: ").Code(strings.Join(cfg.Backpressure.Shed, ", "))
+	}
+	text, entities := msg.Build()
+	ctx = context.WithValue(ctx, logCtxKey{}, true)
+	if _, err := w.bot.SendMessage(ctx, chat.ChatID, chat.TopicID, text, entities...); err != nil {
+		log.Printf("[Dispatch] Failed to send backpressure warning: %v", err)
+	}
+}
+
+// PendingUpdates returns the number of updates waiting behind earlier updates
+// of the same user and chat, and the number of updates being processed.
+// Returns zeros before Start.
+func (w *Wrapper) PendingUpdates() (queued, running int) {
+	if w.dispatcher == nil {
+		return 0, 0
+	}
+	return w.dispatcher.Pending()
+}
+
+// DispatchStats returns the load of update processing: queue depth, busy
+// workers, shed updates, and whether backpressure is active. Returns zeros
+// before Start.
+func (w *Wrapper) DispatchStats() dispatch.Stats {
+	if w.dispatcher == nil {
+		return dispatch.Stats{}
+	}
+	return w.dispatcher.Stats()
+}
@@ -0,0 +1,182 @@
+package dispatch
+
+import (
+	"strings"
+
+	"github.com/mymmrac/telego"
+)
+
+// Update classes, as listed in Policy.Shed and Pool.Classes. Class returns
+// all but ClassConversation, which depends on the conversations of the
+// dispatcher's ActiveFunc.
+const (
+	ClassCommand       = "command"              // Message starting with a slash
+	ClassMessage       = "message"              // Other message in a private chat
+	ClassGroupMessage  = "group_message"        // Other message in a group or supergroup
+	ClassConversation  = "conversation"         // Other message answering an active conversation
+	ClassEditedMessage = "edited_message"       // Edited message
+	ClassChannelPost   = "channel_post"         // New or edited channel post
+	ClassCallback      = "callback"             // Callback query
+	ClassInlineQuery   = "inline_query"         // Inline query
+	ClassInlineResult  = "chosen_inline_result" // Chosen inline result
+	ClassMember        = "member"               // my_chat_member or chat_member update
+	ClassReaction      = "reaction"             // Message reaction or reaction count
+	ClassPoll          = "poll"                 // Poll or poll answer
+	ClassPayment       = "payment"              // Pre-checkout or shipping query
+	ClassOther         = "other"                // Any other update
+)
+
+// Classes lists all update classes.
+var Classes = []string{
+	ClassCommand, ClassMessage, ClassGroupMessage, ClassConversation, ClassEditedMessage, ClassChannelPost,
+	ClassCallback, ClassInlineQuery, ClassInlineResult, ClassMember, ClassReaction,
+	ClassPoll, ClassPayment, ClassOther,
+}
+
+// Class returns the class of an update.
+func Class(update telego.Update) string {
+	switch {
+	case update.Message != nil:
+		switch {
+		case strings.HasPrefix(update.Message.Text, "/"):
+			return ClassCommand
+		case update.Message.Chat.Type == telego.ChatTypeGroup || update.Message.Chat.Type == telego.ChatTypeSupergroup:
+			return ClassGroupMessage
+		}
+		return ClassMessage
+	case update.EditedMessage != nil:
+		return ClassEditedMessage
+	case update.ChannelPost != nil, update.EditedChannelPost != nil:
+		return ClassChannelPost
+	case update.CallbackQuery != nil:
+		return ClassCallback
+	case update.InlineQuery != nil:
+		return ClassInlineQuery
+	case update.ChosenInlineResult != nil:
+		return ClassInlineResult
+	case update.MyChatMember != nil, update.ChatMember != nil:
+		return ClassMember
+	case update.MessageReaction != nil, update.MessageReactionCount != nil:
+		return ClassReaction
+	case update.Poll != nil, update.PollAnswer != nil:
+		return ClassPoll
+	case update.PreCheckoutQuery != nil, update.ShippingQuery != nil:
+		return ClassPayment
+	}
+	return ClassOther
+}
+
+// Policy controls how the dispatcher behaves when its workers are saturated.
+// Backpressure starts once HighWater updates are waiting, queued behind their
+// lanes or for a worker, and ends once at most LowWater are.
+type Policy struct {
+	HighWater           int             // Waiting updates starting backpressure; 0 disables it
+	LowWater            int             // Waiting updates ending backpressure
+	Shed                map[string]bool // Classes of updates dropped under backpressure
+	PrioritizeCallbacks bool            // Whether callbacks get free workers before other updates
+}
+
+// Stats is a snapshot of the dispatcher's load.
+type Stats struct {
+	Queued    int         // Updates queued behind earlier updates of their lanes
+	Waiting   int         // Updates waiting for a free worker
+	Running   int         // Updates being processed
+	Workers   int         // Maximum number of updates processed at once, over all pools
+	Shed      uint64      // Updates dropped under backpressure since the start
+	Pressured bool        // Whether backpressure is active
+	Pools     []PoolStats // Load of each pool, the shared pool first
+}
+
+// Depth returns the number of updates waiting to be processed.
+func (s Stats) Depth() int {
+	return s.Queued + s.Waiting
+}
+
+// PressureFunc is called when backpressure starts or ends, with the stats at
+// that moment.
+type PressureFunc func(stats Stats)
+
+// SetPolicy sets the backpressure policy.
+func (d *Dispatcher) SetPolicy(policy Policy) {
+	d.mu.Lock()
+	d.policy = policy
+	d.mu.Unlock()
+	d.checkPressure()
+}
+
+// SetPressureHook sets the function notified when backpressure starts or
+// ends. It runs in its own goroutine.
+func (d *Dispatcher) SetPressureHook(fn PressureFunc) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.onPressure = fn
+}
+
+// Stats returns a snapshot of the dispatcher's load.
+func (d *Dispatcher) Stats() Stats {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.stats()
+}
+
+// stats returns a snapshot of the dispatcher's load. Must be called with d.mu held.
+func (d *Dispatcher) stats() Stats {
+	stats := Stats{
+		Queued:    d.behind,
+		Waiting:   d.waiting(),
+		Running:   d.running(),
+		Shed:      d.shed,
+		Pressured: d.pressured,
+		Pools:     []PoolStats{d.shared.stats()},
+	}
+	for _, p := range d.pools {
+		stats.Pools = append(stats.Pools, p.stats())
+	}
+	for _, p := range stats.Pools {
+		stats.Workers += p.Workers
+	}
+	return stats
+}
+
+// shedding returns true if an update of a class must be dropped under
+// backpressure, and counts it.
+func (d *Dispatcher) shedding(class string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if !d.pressured || !d.policy.Shed[class] {
+		return false
+	}
+	d.shed++
+	return true
+}
+
+// priority returns the worker priority of an update, 0 being the highest.
+func (d *Dispatcher) priority(update telego.Update) int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.policy.PrioritizeCallbacks && update.CallbackQuery != nil {
+		return 0
+	}
+	return 1
+}
+
+// checkPressure starts or ends backpressure depending on the number of
+// waiting updates, and notifies the hook of the change.
+func (d *Dispatcher) checkPressure() {
+	d.mu.Lock()
+	depth := d.behind + d.waiting()
+	switch {
+	case !d.pressured && d.policy.HighWater > 0 && depth >= d.policy.HighWater:
+		d.pressured = true
+	case d.pressured && (d.policy.HighWater <= 0 || depth <= d.policy.LowWater):
+		d.pressured = false
+	default:
+		d.mu.Unlock()
+		return
+	}
+	stats, fn := d.stats(), d.onPressure
+	d.mu.Unlock()
+	if fn != nil {
+		go fn(stats)
+	}
+}
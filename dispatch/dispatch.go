@@ -0,0 +1,212 @@
+// Package dispatch orders the processing of updates. Updates of one user in
+// one chat are processed one at a time in the order Telegram sent them, while
+// updates of different users or chats run in parallel, bounded by a number of
+// workers.
+package dispatch
+
+import (
+	"context"
+	"sync"
+
+	"github.com/mymmrac/telego"
+)
+
+// DefaultWorkers is the default number of updates processed at once.
+const DefaultWorkers = 64
+
+// Key identifies the lane of an update: updates with the same key are
+// processed in order.
+type Key struct {
+	UserID int64 // Sender of the update, 0 if none
+	ChatID int64 // Chat of the update, 0 if none
+}
+
+// ticket is the place of a fed update in its lane.
+type ticket struct {
+	key  Key           // Lane of the update
+	turn chan struct{} // Closed when the update is at the head of its lane
+}
+
+// Dispatcher serializes updates per user and chat and bounds the number of
+// updates processed at once.
+type Dispatcher struct {
+	ordered bool                    // Whether updates are serialized per lane
+	shared  *pool                   // Workers of updates of classes without a pool of their own
+	pools   []*pool                 // Dedicated worker pools, in the order they were set
+	poolOf  map[string]*pool        // Dedicated pool of each class
+	active  ActiveFunc              // Reports updates answering an active conversation
+	lanes   map[Key][]chan struct{} // Turns of the queued updates of each lane, head first
+	behind  int                     // Number of updates queued behind the heads of their lanes
+	tickets map[int]ticket          // Tickets of fed updates not yet acquired, by update ID
+
+	policy     Policy       // Backpressure policy
+	pressured  bool         // Whether backpressure is active
+	shed       uint64       // Updates shed under backpressure
+	onPressure PressureFunc // Notified when backpressure starts or ends
+
+	mu sync.Mutex // Mutex for thread-safe access
+}
+
+// New creates a dispatcher processing up to workers updates at once; 0 uses
+// DefaultWorkers. If ordered is false, updates are only bounded, not serialized.
+func New(workers int, ordered bool) *Dispatcher {
+	if workers <= 0 {
+		workers = DefaultWorkers
+	}
+	return &Dispatcher{
+		ordered: ordered,
+		shared:  &pool{name: SharedPool, workers: workers},
+		lanes:   make(map[Key][]chan struct{}),
+		tickets: make(map[int]ticket),
+	}
+}
+
+// KeyOf returns the lane of an update. Returns false for updates without a
+// sender or chat, e.g. polls, which are not serialized.
+func KeyOf(update telego.Update) (Key, bool) {
+	var key Key
+	switch {
+	case update.Message != nil:
+		key.ChatID = update.Message.Chat.ID
+		if update.Message.From != nil {
+			key.UserID = update.Message.From.ID
+		}
+	case update.EditedMessage != nil:
+		key.ChatID = update.EditedMessage.Chat.ID
+		if update.EditedMessage.From != nil {
+			key.UserID = update.EditedMessage.From.ID
+		}
+	case update.CallbackQuery != nil:
+		key.UserID = update.CallbackQuery.From.ID
+		if update.CallbackQuery.Message != nil {
+			key.ChatID = update.CallbackQuery.Message.GetChat().ID
+		}
+	case update.MyChatMember != nil:
+		key = Key{UserID: update.MyChatMember.From.ID, ChatID: update.MyChatMember.Chat.ID}
+	case update.ChatMember != nil:
+		key = Key{UserID: update.ChatMember.From.ID, ChatID: update.ChatMember.Chat.ID}
+	case update.InlineQuery != nil:
+		key.UserID = update.InlineQuery.From.ID
+	case update.ChosenInlineResult != nil:
+		key.UserID = update.ChosenInlineResult.From.ID
+	case update.PreCheckoutQuery != nil:
+		key.UserID = update.PreCheckoutQuery.From.ID
+	case update.ShippingQuery != nil:
+		key.UserID = update.ShippingQuery.From.ID
+	}
+	return key, key != Key{}
+}
+
+// Feed passes updates from in to the returned channel, queueing each in its
+// lane as it passes, so lanes keep the order of in. The returned channel is
+// closed once in is closed or ctx is done.
+func (d *Dispatcher) Feed(ctx context.Context, in <-chan telego.Update) <-chan telego.Update {
+	out := make(chan telego.Update)
+	go func() {
+		defer close(out)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case update, ok := <-in:
+				if !ok {
+					return
+				}
+				if d.shedding(d.classOf(update)) {
+					continue
+				}
+				d.enqueue(update)
+				d.checkPressure()
+				select {
+				case out <- update:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return out
+}
+
+// enqueue appends an update to the end of its lane.
+func (d *Dispatcher) enqueue(update telego.Update) {
+	if !d.ordered {
+		return
+	}
+	key, ok := KeyOf(update)
+	if !ok {
+		return
+	}
+	turn := make(chan struct{})
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.lanes[key] = append(d.lanes[key], turn)
+	if len(d.lanes[key]) == 1 {
+		close(turn)
+	} else {
+		d.behind++
+	}
+	d.tickets[update.UpdateID] = ticket{key: key, turn: turn}
+}
+
+// Acquire waits until an update is at the head of its lane and a worker of
+// its class's pool is free, and returns the function releasing both once the
+// update is processed. Updates that were not fed through Feed only wait for a
+// worker. Under a policy prioritizing callbacks, callback queries get free
+// workers first. Returns the context's error if ctx is done first.
+func (d *Dispatcher) Acquire(ctx context.Context, update telego.Update) (func(), error) {
+	d.mu.Lock()
+	t, queued := d.tickets[update.UpdateID]
+	delete(d.tickets, update.UpdateID)
+	d.mu.Unlock()
+
+	if queued {
+		select {
+		case <-t.turn:
+		case <-ctx.Done():
+			// Give up the turn once it comes, so later updates of the lane proceed
+			go func() {
+				<-t.turn
+				d.advance(t.key)
+			}()
+			return nil, ctx.Err()
+		}
+	}
+
+	p := d.poolFor(d.classOf(update))
+	if err := d.acquireWorker(ctx, p, d.priority(update)); err != nil {
+		if queued {
+			d.advance(t.key)
+		}
+		return nil, err
+	}
+
+	return func() {
+		d.releaseWorker(p)
+		if queued {
+			d.advance(t.key)
+		}
+	}, nil
+}
+
+// advance removes the head of a lane and hands the turn to the next update.
+func (d *Dispatcher) advance(key Key) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	lane := d.lanes[key][1:]
+	if len(lane) == 0 {
+		delete(d.lanes, key)
+		return
+	}
+	d.behind--
+	d.lanes[key] = lane
+	close(lane[0])
+}
+
+// Pending returns the number of updates queued behind earlier updates of
+// their lanes, and the number of updates being processed.
+func (d *Dispatcher) Pending() (queued, running int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.behind, d.running()
+}
@@ -0,0 +1,170 @@
+package dispatch
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mymmrac/telego"
+)
+
+// message returns a message update from a user in a chat.
+func message(id int, userID, chatID int64) telego.Update {
+	return telego.Update{UpdateID: id, Message: &telego.Message{
+		Chat: telego.Chat{ID: chatID},
+		From: &telego.User{ID: userID},
+	}}
+}
+
+// feed passes updates through the dispatcher's Feed, queueing them in their lanes.
+func feed(t *testing.T, d *Dispatcher, updates []telego.Update) {
+	t.Helper()
+	in := make(chan telego.Update, len(updates))
+	for _, u := range updates {
+		in <- u
+	}
+	close(in)
+	n := 0
+	for range d.Feed(context.Background(), in) {
+		n++
+	}
+	if n != len(updates) {
+		t.Fatalf("Feed passed %d updates, want %d", n, len(updates))
+	}
+}
+
+func TestKeyOf(t *testing.T) {
+	tests := []struct {
+		name   string
+		update telego.Update
+		want   Key
+		wantOK bool
+	}{
+		{"message", message(1, 7, 100), Key{UserID: 7, ChatID: 100}, true},
+		{"channel message without sender", telego.Update{Message: &telego.Message{Chat: telego.Chat{ID: 100}}}, Key{ChatID: 100}, true},
+		{"callback", telego.Update{CallbackQuery: &telego.CallbackQuery{
+			From:    telego.User{ID: 7},
+			Message: &telego.Message{Chat: telego.Chat{ID: 100}},
+		}}, Key{UserID: 7, ChatID: 100}, true},
+		{"inline callback", telego.Update{CallbackQuery: &telego.CallbackQuery{From: telego.User{ID: 7}}}, Key{UserID: 7}, true},
+		{"inline query", telego.Update{InlineQuery: &telego.InlineQuery{From: telego.User{ID: 7}}}, Key{UserID: 7}, true},
+		{"poll", telego.Update{Poll: &telego.Poll{ID: "p"}}, Key{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := KeyOf(tt.update)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("KeyOf = %+v, %v, want %+v, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestLaneOrder(t *testing.T) {
+	a, b := Key{UserID: 1, ChatID: 100}, Key{UserID: 2, ChatID: 100}
+	tests := []struct {
+		name    string
+		workers int
+		updates []telego.Update
+		want    map[Key][]int // Update IDs in processing order, by lane
+	}{
+		{
+			name:    "one lane",
+			workers: 4,
+			updates: []telego.Update{message(1, 1, 100), message(2, 1, 100), message(3, 1, 100)},
+			want:    map[Key][]int{a: {1, 2, 3}},
+		},
+		{
+			name:    "interleaved lanes",
+			workers: 4,
+			updates: []telego.Update{message(1, 1, 100), message(2, 2, 100), message(3, 1, 100), message(4, 2, 100), message(5, 1, 100)},
+			want:    map[Key][]int{a: {1, 3, 5}, b: {2, 4}},
+		},
+		{
+			name:    "one worker",
+			workers: 1,
+			updates: []telego.Update{message(1, 1, 100), message(2, 2, 100), message(3, 1, 100), message(4, 2, 100)},
+			want:    map[Key][]int{a: {1, 3}, b: {2, 4}},
+		},
+		{
+			name:    "same user in another chat",
+			workers: 4,
+			updates: []telego.Update{message(1, 1, 100), message(2, 1, 200), message(3, 1, 100)},
+			want:    map[Key][]int{a: {1, 3}, {UserID: 1, ChatID: 200}: {2}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := New(tt.workers, true)
+			feed(t, d, tt.updates)
+
+			var (
+				mu  sync.Mutex
+				got = make(map[Key][]int)
+				wg  sync.WaitGroup
+			)
+			// Acquire in reverse, so only the lanes keep the feed order
+			for i := len(tt.updates) - 1; i >= 0; i-- {
+				update := tt.updates[i]
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+					defer cancel()
+					release, err := d.Acquire(ctx, update)
+					if err != nil {
+						t.Errorf("Acquire(%d) error = %v", update.UpdateID, err)
+						return
+					}
+					key, _ := KeyOf(update)
+					mu.Lock()
+					got[key] = append(got[key], update.UpdateID)
+					mu.Unlock()
+					release()
+				}()
+			}
+			wg.Wait()
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("processing order = %v, want %v", got, tt.want)
+			}
+			if queued, running := d.Pending(); queued != 0 || running != 0 {
+				t.Errorf("Pending = %d, %d, want 0, 0", queued, running)
+			}
+		})
+	}
+}
+
+func TestLaneCancelledTurn(t *testing.T) {
+	d := New(4, true)
+	updates := []telego.Update{message(1, 1, 100), message(2, 1, 100), message(3, 1, 100)}
+	feed(t, d, updates)
+
+	release, err := d.Acquire(context.Background(), updates[0])
+	if err != nil {
+		t.Fatalf("Acquire(1) error = %v", err)
+	}
+
+	// The second update waits for the first and gives up
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := d.Acquire(ctx, updates[1]); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Acquire(2) error = %v, want context.DeadlineExceeded", err)
+	}
+
+	// Its turn is passed on once the first is done
+	release()
+	ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	release, err = d.Acquire(ctx, updates[2])
+	if err != nil {
+		t.Fatalf("Acquire(3) error = %v", err)
+	}
+	release()
+	if queued, running := d.Pending(); queued != 0 || running != 0 {
+		t.Errorf("Pending = %d, %d, want 0, 0", queued, running)
+	}
+}
@@ -0,0 +1,187 @@
+package dispatch
+
+import (
+	"context"
+
+	"github.com/mymmrac/telego"
+)
+
+// SharedPool is the name of the pool running updates of classes without a
+// pool of their own.
+const SharedPool = "shared"
+
+// Pool is a set of workers dedicated to some classes of updates, so a flood
+// of other updates can't delay them.
+type Pool struct {
+	Name    string   // Pool name, reported in stats
+	Workers int      // Number of updates of the pool processed at once
+	Classes []string // Classes of the updates the pool runs
+}
+
+// PoolStats is a snapshot of the load of a worker pool.
+type PoolStats struct {
+	Name    string // Pool name
+	Waiting int    // Updates waiting for a free worker of the pool
+	Running int    // Updates being processed by the pool
+	Workers int    // Maximum number of updates the pool processes at once
+}
+
+// ActiveFunc reports whether a message answers a conversation its sender is
+// in, so it gets the conversation class.
+type ActiveFunc func(update telego.Update) bool
+
+// pool bounds the number of updates of some classes processed at once. Its
+// fields are guarded by the dispatcher's mutex.
+type pool struct {
+	name    string             // Pool name
+	workers int                // Maximum number of updates processed at once
+	busy    int                // Number of updates being processed
+	waiters [2][]chan struct{} // Updates waiting for a worker, priority ones first
+}
+
+// waiting returns the number of updates waiting for a worker of the pool.
+func (p *pool) waiting() int {
+	return len(p.waiters[0]) + len(p.waiters[1])
+}
+
+// stats returns a snapshot of the pool's load.
+func (p *pool) stats() PoolStats {
+	return PoolStats{Name: p.name, Waiting: p.waiting(), Running: p.busy, Workers: p.workers}
+}
+
+// SetPools sets the worker pools dedicated to some classes of updates, e.g.
+// callbacks and conversation input, so they stay responsive while the shared
+// workers are busy with group traffic. Updates of other classes run on the
+// shared workers. Updates already holding a worker of a replaced pool keep it
+// until they are processed.
+func (d *Dispatcher) SetPools(pools []Pool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.pools = nil
+	d.poolOf = make(map[string]*pool)
+	for _, cfg := range pools {
+		workers := cfg.Workers
+		if workers <= 0 {
+			workers = 1
+		}
+		p := &pool{name: cfg.Name, workers: workers}
+		d.pools = append(d.pools, p)
+		for _, class := range cfg.Classes {
+			if _, ok := d.poolOf[class]; !ok {
+				d.poolOf[class] = p
+			}
+		}
+	}
+}
+
+// SetActiveFunc sets the function telling which messages answer an active
+// conversation. Without one, no update gets the conversation class.
+func (d *Dispatcher) SetActiveFunc(fn ActiveFunc) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.active = fn
+}
+
+// classOf returns the class of an update, giving messages that answer an
+// active conversation the conversation class.
+func (d *Dispatcher) classOf(update telego.Update) string {
+	class := Class(update)
+	if class != ClassMessage && class != ClassGroupMessage {
+		return class
+	}
+	d.mu.Lock()
+	active := d.active
+	d.mu.Unlock()
+	if active != nil && active(update) {
+		return ClassConversation
+	}
+	return class
+}
+
+// poolFor returns the pool running updates of a class.
+func (d *Dispatcher) poolFor(class string) *pool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if p, ok := d.poolOf[class]; ok {
+		return p
+	}
+	return d.shared
+}
+
+// acquireWorker waits for a free worker of a pool, behind the updates already
+// waiting with the same or a higher priority (0 is the highest).
+func (d *Dispatcher) acquireWorker(ctx context.Context, p *pool, priority int) error {
+	d.mu.Lock()
+	if p.busy < p.workers && p.waiting() == 0 {
+		p.busy++
+		d.mu.Unlock()
+		return nil
+	}
+	ready := make(chan struct{})
+	p.waiters[priority] = append(p.waiters[priority], ready)
+	d.mu.Unlock()
+	d.checkPressure()
+
+	select {
+	case <-ready:
+		return nil
+	case <-ctx.Done():
+		d.mu.Lock()
+		removed := false
+		for i, w := range p.waiters[priority] {
+			if w == ready {
+				p.waiters[priority] = append(p.waiters[priority][:i:i], p.waiters[priority][i+1:]...)
+				removed = true
+				break
+			}
+		}
+		d.mu.Unlock()
+		if !removed {
+			// A worker was handed over meanwhile; pass it on
+			d.releaseWorker(p)
+		}
+		d.checkPressure()
+		return ctx.Err()
+	}
+}
+
+// releaseWorker hands a worker of a pool over to the next waiting update, or frees it.
+func (d *Dispatcher) releaseWorker(p *pool) {
+	d.mu.Lock()
+	var next chan struct{}
+	for i := range p.waiters {
+		if len(p.waiters[i]) > 0 {
+			next = p.waiters[i][0]
+			p.waiters[i] = p.waiters[i][1:]
+			break
+		}
+	}
+	if next == nil {
+		p.busy--
+	}
+	d.mu.Unlock()
+	if next != nil {
+		close(next)
+	}
+	d.checkPressure()
+}
+
+// waiting returns the number of updates waiting for a worker of any pool.
+// Must be called with d.mu held.
+func (d *Dispatcher) waiting() int {
+	n := d.shared.waiting()
+	for _, p := range d.pools {
+		n += p.waiting()
+	}
+	return n
+}
+
+// running returns the number of updates being processed by any pool. Must be
+// called with d.mu held.
+func (d *Dispatcher) running() int {
+	n := d.shared.busy
+	for _, p := range d.pools {
+		n += p.busy
+	}
+	return n
+}
@@ -0,0 +1,45 @@
+package tgwrapper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/mymmrac/telego"
+
+	"github.com/0xVanfer/tg-listener/core"
+	"github.com/0xVanfer/tg-listener/handler"
+)
+
+// maxPanicStack is the number of stack trace bytes included in panic reports.
+const maxPanicStack = 3000
+
+// OnError sets a callback function that is called when a handler returns an
+// error or panics while handling an update. Panics are passed as *handler.PanicError;
+// they are recovered either way, and a pending callback query is answered.
+func (w *Wrapper) OnError(fn handler.ErrorHandler) {
+	w.onError = fn
+}
+
+// handleError logs recovered panics, reports them to the warning chat if
+// report_panics is set, and passes every error to the user callback.
+func (w *Wrapper) handleError(ctx context.Context, update telego.Update, err error) {
+	var panicErr *handler.PanicError
+	if errors.As(err, &panicErr) {
+		log.Printf("[Panic] update %d: %v\n%s", update.UpdateID, panicErr.Value, panicErr.Stack)
+		if w.Config().Bot.ReportPanics {
+			stack := string(panicErr.Stack)
+			if len(stack) > maxPanicStack {
+				stack = stack[:maxPanicStack] + "…"
+			}
+			msg := core.NewBuilder().Text(fmt.Sprintf("Update %d: %v", update.UpdateID, panicErr.Value)).Ln().Pre(stack, "")
+			if werr := w.Warn(ctx, fmt.Sprintf("panic: %v", panicErr.Value), msg); werr != nil && w.Config().Bot.Debug {
+				log.Printf("[Panic] Failed to report: %v", werr)
+			}
+		}
+	}
+	if w.onError != nil {
+		w.onError(ctx, update, err)
+	}
+}
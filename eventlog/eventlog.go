@@ -0,0 +1,212 @@
+// Package eventlog persists structured router and flow events in a store, so
+// reports like "the bot didn't respond" can be investigated after the fact.
+// Events are bucketed by UTC day, which keeps time range queries and
+// retention pruning cheap.
+package eventlog
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/0xVanfer/tg-listener/store"
+)
+
+// keyPrefix is the store key prefix for events.
+const keyPrefix = "events:"
+
+// dayLayout formats the UTC day an event belongs to.
+const dayLayout = "2006-01-02"
+
+// Type identifies the kind of event.
+type Type string
+
+const (
+	// TypeUpdate is recorded for every update received.
+	TypeUpdate Type = "update"
+
+	// TypeCommand is recorded when a command is dispatched to its handler.
+	TypeCommand Type = "command"
+
+	// TypeCallback is recorded when a callback is dispatched to its handler.
+	TypeCallback Type = "callback"
+
+	// TypeInlineQuery is recorded when an inline query is dispatched to its handler.
+	TypeInlineQuery Type = "inline_query"
+
+	// TypeInlineResult is recorded when a chosen inline result is dispatched to its handler.
+	TypeInlineResult Type = "inline_result"
+
+	// TypeUnhandled is recorded when no handler accepted an update.
+	TypeUnhandled Type = "unhandled"
+
+	// TypeUnauthorized is recorded when the auth function rejected a user.
+	TypeUnauthorized Type = "unauthorized"
+
+	// TypeBlocked is recorded when maintenance mode or a usage limit blocked a user.
+	TypeBlocked Type = "blocked"
+
+	// TypeError is recorded when a handler returned an error.
+	TypeError Type = "error"
+
+	// TypeValidation is recorded when conversation input failed validation.
+	TypeValidation Type = "validation"
+
+	// TypeFlowStarted is recorded when a conversation flow starts.
+	TypeFlowStarted Type = "flow_started"
+
+	// TypeStepCompleted is recorded when a conversation step completes.
+	TypeStepCompleted Type = "step_completed"
+
+	// TypeFlowEnded is recorded when a conversation ends.
+	TypeFlowEnded Type = "flow_ended"
+
+	// TypeForced is recorded when an operator moved or ended a conversation.
+	TypeForced Type = "forced"
+
+	// TypeStepTimeout is recorded when a step timed out waiting for input.
+	TypeStepTimeout Type = "step_timeout"
+
+	// TypeShadowMismatch is recorded when a shadow handler behaved unlike the
+	// handler it shadows.
+	TypeShadowMismatch Type = "shadow_mismatch"
+)
+
+// Event is a recorded router or flow event.
+type Event struct {
+	Type     Type      `json:"type"`
+	At       time.Time `json:"at"`
+	UpdateID int       `json:"update_id,omitempty"` // Update that caused the event, if any
+	UserID   int64     `json:"user_id,omitempty"`
+	ChatID   int64     `json:"chat_id,omitempty"`
+	FlowID   string    `json:"flow_id,omitempty"`
+	StepID   string    `json:"step_id,omitempty"`
+	Detail   string    `json:"detail,omitempty"` // Command, callback data, update kind, or reason
+	Error    string    `json:"error,omitempty"`
+}
+
+// Filter selects events in a query. Zero fields match everything.
+type Filter struct {
+	UserID int64     // Only events of this user
+	ChatID int64     // Only events in this chat
+	FlowID string    // Only events of this flow
+	Types  []Type    // Only events of these types
+	Since  time.Time // Only events at or after this time; defaults to the retention window
+	Until  time.Time // Only events before this time; defaults to now
+	Limit  int       // Maximum number of events, most recent kept; 0 for all
+}
+
+// match returns true if the event passes the filter.
+func (f Filter) match(e *Event) bool {
+	if f.UserID != 0 && e.UserID != f.UserID {
+		return false
+	}
+	if f.ChatID != 0 && e.ChatID != f.ChatID {
+		return false
+	}
+	if f.FlowID != "" && e.FlowID != f.FlowID {
+		return false
+	}
+	if len(f.Types) > 0 {
+		found := false
+		for _, t := range f.Types {
+			if e.Type == t {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return !e.At.Before(f.Since) && e.At.Before(f.Until)
+}
+
+// Log records events in a store and prunes them after the retention period.
+type Log struct {
+	store     store.Store   // Backing store
+	retention time.Duration // Age after which events are pruned
+	seq       uint32        // Sequence disambiguating events recorded in the same nanosecond
+	mu        sync.Mutex    // Mutex for thread-safe sequence access
+}
+
+// NewLog creates an event log persisted in the given store, keeping events
+// for the retention period.
+func NewLog(s store.Store, retention time.Duration) *Log {
+	return &Log{store: s, retention: retention}
+}
+
+// dayPrefix returns the store key prefix for events of a UTC day.
+func dayPrefix(t time.Time) string {
+	return keyPrefix + t.UTC().Format(dayLayout) + ":"
+}
+
+// Record persists an event, stamping it with the current time if At is zero.
+func (l *Log) Record(ctx context.Context, e Event) error {
+	if e.At.IsZero() {
+		e.At = time.Now()
+	}
+	l.mu.Lock()
+	l.seq++
+	seq := l.seq
+	l.mu.Unlock()
+	key := fmt.Sprintf("%s%019d-%08x", dayPrefix(e.At), e.At.UnixNano(), seq)
+	return store.PutJSON(ctx, l.store, key, e)
+}
+
+// Query returns the events matching the filter, oldest first.
+func (l *Log) Query(ctx context.Context, f Filter) ([]Event, error) {
+	if f.Until.IsZero() {
+		f.Until = time.Now()
+	}
+	if f.Since.IsZero() {
+		f.Since = f.Until.Add(-l.retention)
+	}
+
+	var events []Event
+	for day := f.Since.UTC().Truncate(24 * time.Hour); day.Before(f.Until); day = day.Add(24 * time.Hour) {
+		keys, err := l.store.List(ctx, dayPrefix(day))
+		if err != nil {
+			return nil, err
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			var e Event
+			if err := store.GetJSON(ctx, l.store, key, &e); err != nil {
+				if errors.Is(err, store.ErrNotFound) {
+					continue
+				}
+				return nil, err
+			}
+			if f.match(&e) {
+				events = append(events, e)
+			}
+		}
+	}
+	if f.Limit > 0 && len(events) > f.Limit {
+		events = events[len(events)-f.Limit:]
+	}
+	return events, nil
+}
+
+// Prune deletes events older than the retention period. Pruning works in whole
+// UTC days, so events may outlive the retention period by up to a day.
+func (l *Log) Prune(ctx context.Context, now time.Time) error {
+	keys, err := l.store.List(ctx, keyPrefix)
+	if err != nil {
+		return err
+	}
+	cutoff := dayPrefix(now.Add(-l.retention))
+	for _, key := range keys {
+		// Keys sort by day, so whole days before the cutoff day are deleted
+		if key < cutoff {
+			if err := l.store.Delete(ctx, key); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
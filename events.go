@@ -0,0 +1,63 @@
+package tgwrapper
+
+import (
+	"context"
+	"time"
+
+	"github.com/0xVanfer/tg-listener/conv"
+	"github.com/0xVanfer/tg-listener/eventlog"
+	"github.com/0xVanfer/tg-listener/handler"
+)
+
+// Events returns the persisted event log for querying router and flow events,
+// e.g. all events of a user who reports that the bot didn't respond.
+// Events are only recorded while bot.event_log.enabled is set.
+func (w *Wrapper) Events() *eventlog.Log {
+	w.storeMu.RLock()
+	defer w.storeMu.RUnlock()
+	return w.events
+}
+
+// recordEvent persists an event if the event log is enabled, and counts it
+// for the runtime stats and command uses for usage statistics.
+func (w *Wrapper) recordEvent(ctx context.Context, e eventlog.Event) {
+	w.eventCounts.add(e.Type)
+	w.recordUsage(ctx, e)
+	if !w.Config().Bot.EventLog.IsEnabled() {
+		return
+	}
+	if e.At.IsZero() {
+		e.At = time.Now()
+	}
+	if e.UpdateID == 0 {
+		e.UpdateID = handler.UpdateID(ctx)
+	}
+	_ = w.Events().Record(ctx, e)
+}
+
+// recordFlowEvent records a flow lifecycle event of a conversation.
+func (w *Wrapper) recordFlowEvent(ctx context.Context, typ eventlog.Type, c *conv.Conversation, detail string) {
+	w.recordEvent(ctx, eventlog.Event{Type: typ, UserID: c.UserID, ChatID: c.ChatID, FlowID: c.FlowID, StepID: c.StepID, Detail: detail})
+}
+
+// conversationOutcome describes how a conversation ended.
+func conversationOutcome(c *conv.Conversation) string {
+	switch c.GetState() {
+	case conv.StateCompleted:
+		return "completed"
+	case conv.StateCancelled:
+		return "cancelled"
+	case conv.StateExpired:
+		return "expired"
+	default:
+		return "ended"
+	}
+}
+
+// pruneEvents deletes events beyond the event log's retention.
+func (w *Wrapper) pruneEvents(ctx context.Context) error {
+	if !w.Config().Bot.EventLog.IsEnabled() {
+		return nil
+	}
+	return w.Events().Prune(ctx, time.Now())
+}
@@ -0,0 +1,32 @@
+package tgwrapper
+
+import (
+	"log"
+	"strings"
+
+	"github.com/0xVanfer/tg-listener/config"
+)
+
+// CheckFlows validates the flows of the current configuration against the
+// handlers registered so far, including built-in ones and providers declared
+// in bot.providers: every step reference resolves, every step is reachable, and
+// every step handler, keyboard provider, and custom validator named by a step
+// is registered. Start runs it and fails on errors, so call it earlier to list
+// all problems, e.g. in a CI check of the configuration.
+func (w *Wrapper) CheckFlows() *config.FlowReport {
+	return w.Config().CheckFlows(w.flowEngine)
+}
+
+// logFlowWarnings logs the warnings of a flow check in debug mode. Built-in
+// flows, whose IDs start with "_", are skipped: their handlers move between
+// their steps by design.
+func (w *Wrapper) logFlowWarnings(report *config.FlowReport) {
+	if !w.Config().Bot.Debug {
+		return
+	}
+	for _, warning := range report.Warnings {
+		if !strings.HasPrefix(warning.FlowID, "_") {
+			log.Printf("[Flows] %s", warning)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package tgwrapper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+
+	"github.com/mymmrac/telego"
+
+	"github.com/0xVanfer/tg-listener/conv"
+	"github.com/0xVanfer/tg-listener/core"
+	"github.com/0xVanfer/tg-listener/eventlog"
+	"github.com/0xVanfer/tg-listener/handler"
+)
+
+// ErrNoConversation is returned when a user has no active conversation in a chat.
+var ErrNoConversation = errors.New("no active conversation")
+
+// ForceStep moves a user's conversation outside forum topics to another step
+// of its flow and shows that step's prompt, e.g. to unstick a user whose step
+// was removed or wedged by a bug. See ForceStepIn for topic conversations.
+func (w *Wrapper) ForceStep(ctx context.Context, userID, chatID int64, stepID string) error {
+	return w.ForceStepIn(ctx, userID, chatID, 0, stepID)
+}
+
+// ForceStepIn moves a user's conversation in a topic to another step of its
+// flow and shows that step's prompt. Pending validation errors are cleared.
+// The move is logged and recorded in the event log with the operator, if
+// called while handling an update.
+func (w *Wrapper) ForceStepIn(ctx context.Context, userID, chatID int64, topicID int, stepID string) error {
+	c := w.convManager.GetIn(userID, chatID, topicID)
+	if c == nil {
+		return ErrNoConversation
+	}
+	if w.flowEngine.GetStep(c.FlowID, stepID) == nil {
+		return fmt.Errorf("%w: %s in flow %s", conv.ErrStepNotFound, stepID, c.FlowID)
+	}
+
+	from := c.StepID
+	c.ClearInvalidInput()
+	w.convManager.ChangeStepIn(ctx, userID, chatID, topicID, stepID)
+	w.auditForced(ctx, c, "step "+from+" -> "+stepID)
+	return w.showStepPrompt(ctx, c)
+}
+
+// ForceEnd cancels a user's conversation outside forum topics.
+// See ForceEndIn for topic conversations.
+func (w *Wrapper) ForceEnd(ctx context.Context, userID, chatID int64) error {
+	return w.ForceEndIn(ctx, userID, chatID, 0)
+}
+
+// ForceEndIn cancels a user's conversation in a topic, sweeping its messages
+// like any cancelled conversation. The cancellation is logged and recorded in
+// the event log with the operator, if called while handling an update.
+func (w *Wrapper) ForceEndIn(ctx context.Context, userID, chatID int64, topicID int) error {
+	c := w.convManager.GetIn(userID, chatID, topicID)
+	if c == nil {
+		return ErrNoConversation
+	}
+	w.auditForced(ctx, c, "end at "+c.StepID)
+	c.Cancel()
+	w.convManager.EndIn(ctx, userID, chatID, topicID)
+	return nil
+}
+
+// auditForced logs and records an operator action on a conversation.
+func (w *Wrapper) auditForced(ctx context.Context, c *conv.Conversation, action string) {
+	by := "code"
+	if operator := handler.Sender(ctx); operator != nil {
+		by = strconv.FormatInt(operator.ID, 10)
+	}
+	log.Printf("[Admin] %s forced %s of user %d in chat %d (flow %s)", by, action, c.UserID, c.ChatID, c.FlowID)
+	w.recordFlowEvent(ctx, eventlog.TypeForced, c, action+" by "+by)
+}
+
+// handleForceCommand runs "/force step <user> <chat> <step>" and
+// "/force end <user> <chat>" for operators.
+func (w *Wrapper) handleForceCommand(ctx context.Context, msg telego.Message, command string, args []string) error {
+	reply := func(b *core.Builder) error {
+		text, entities := b.Build()
+		_, err := w.bot.SendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, text, entities...)
+		return err
+	}
+	usage := core.NewBuilder().Text("Usage: ").
+		Code("/" + command + " step <user> <chat> <step>").Text(" or ").
+		Code("/" + command + " end <user> <chat>")
+
+	if len(args) < 3 {
+		return reply(usage)
+	}
+	userID, uerr := strconv.ParseInt(args[1], 10, 64)
+	chatID, cerr := strconv.ParseInt(args[2], 10, 64)
+	if uerr != nil || cerr != nil {
+		return reply(usage)
+	}
+
+	var err error
+	switch {
+	case args[0] == "step" && len(args) == 4:
+		err = w.ForceStep(ctx, userID, chatID, args[3])
+	case args[0] == "end" && len(args) == 3:
+		err = w.ForceEnd(ctx, userID, chatID)
+	default:
+		return reply(usage)
+	}
+	if err != nil {
+		return reply(core.NewBuilder().Text("❌ " + err.Error()))
+	}
+	return reply(core.NewBuilder().Text("✅ Done"))
+}
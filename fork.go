@@ -0,0 +1,185 @@
+package tgwrapper
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/mymmrac/telego"
+
+	"github.com/0xVanfer/tg-listener/config"
+	"github.com/0xVanfer/tg-listener/conv"
+	"github.com/0xVanfer/tg-listener/core"
+	"github.com/0xVanfer/tg-listener/handler"
+)
+
+// chatMessage identifies a message in a chat.
+type chatMessage struct {
+	chatID    int64
+	messageID int
+}
+
+// forkConversation identifies a conversation by user and chat.
+type forkConversation struct {
+	userID int64
+	chatID int64
+}
+
+// forkState tracks shared group menus and the per-user forks opened from them.
+type forkState struct {
+	shared map[chatMessage]config.ForkMode // Fork mode of messages showing a shared menu
+	forks  map[chatMessage]*time.Timer     // Deletion timers of fork messages in groups
+	convs  map[forkConversation]bool       // Conversations started from a shared menu
+	mu     sync.Mutex                      // Mutex for thread-safe fork access
+}
+
+// forkCtxKey is the context key marking menus shown as a fork.
+type forkCtxKey struct{}
+
+// isGroupChat returns true for group, supergroup, and channel chat IDs, which are negative.
+func isGroupChat(chatID int64) bool {
+	return chatID < 0
+}
+
+// sharedMode returns the fork mode of a message showing a shared menu, or
+// config.ForkNone if presses on it are handled in place.
+func (w *Wrapper) sharedMode(chatID int64, messageID int) config.ForkMode {
+	w.forks.mu.Lock()
+	defer w.forks.mu.Unlock()
+	return w.forks.shared[chatMessage{chatID, messageID}]
+}
+
+// setShared records the fork mode of the menu a message shows.
+func (w *Wrapper) setShared(chatID int64, messageID int, mode config.ForkMode) {
+	w.forks.mu.Lock()
+	defer w.forks.mu.Unlock()
+	key := chatMessage{chatID, messageID}
+	if mode == config.ForkNone || !isGroupChat(chatID) {
+		delete(w.forks.shared, key)
+		return
+	}
+	if w.forks.shared == nil {
+		w.forks.shared = make(map[chatMessage]config.ForkMode)
+	}
+	w.forks.shared[key] = mode
+}
+
+// isFork returns true if a message is a fork opened from a shared menu.
+func (w *Wrapper) isFork(chatID int64, messageID int) bool {
+	w.forks.mu.Lock()
+	defer w.forks.mu.Unlock()
+	_, ok := w.forks.forks[chatMessage{chatID, messageID}]
+	return ok
+}
+
+// keepFork binds a fork message in a group to its user and schedules its
+// deletion once, when it is first seen. Forks in private chats need neither.
+func (w *Wrapper) keepFork(ctx context.Context, chatID int64, messageID int, userID int64) {
+	if !isGroupChat(chatID) || messageID == 0 {
+		return
+	}
+	w.bindMessage(ctx, chatID, messageID, userID)
+
+	w.forks.mu.Lock()
+	defer w.forks.mu.Unlock()
+	key := chatMessage{chatID, messageID}
+	if _, ok := w.forks.forks[key]; ok {
+		return
+	}
+	if w.forks.forks == nil {
+		w.forks.forks = make(map[chatMessage]*time.Timer)
+	}
+	w.forks.forks[key] = time.AfterFunc(w.Config().Bot.Fork.GetTTL(), func() {
+		w.forks.mu.Lock()
+		delete(w.forks.forks, key)
+		w.forks.mu.Unlock()
+		w.unbindMessage(context.Background(), chatID, messageID)
+		_ = w.bot.DeleteMessage(context.Background(), chatID, messageID)
+	})
+}
+
+// setForkedConversation records whether a conversation was started from a shared menu.
+func (w *Wrapper) setForkedConversation(userID, chatID int64, forked bool) {
+	w.forks.mu.Lock()
+	defer w.forks.mu.Unlock()
+	key := forkConversation{userID, chatID}
+	if !forked {
+		delete(w.forks.convs, key)
+		return
+	}
+	if w.forks.convs == nil {
+		w.forks.convs = make(map[forkConversation]bool)
+	}
+	w.forks.convs[key] = true
+}
+
+// isForkedConversation returns true if a conversation was started from a shared menu.
+func (w *Wrapper) isForkedConversation(c *conv.Conversation) bool {
+	w.forks.mu.Lock()
+	defer w.forks.mu.Unlock()
+	return w.forks.convs[forkConversation{c.UserID, c.ChatID}]
+}
+
+// forkTarget returns the chat and topic a fork of a shared menu is opened in.
+func forkTarget(query telego.CallbackQuery, mode config.ForkMode) (int64, int) {
+	if mode == config.ForkDM {
+		return query.From.ID, 0
+	}
+	return query.Message.GetChat().ID, core.GetTopicID(query.Message)
+}
+
+// forkFailed answers a fork press whose message could not be sent.
+// A direct message usually fails because the user never started the bot.
+func (w *Wrapper) forkFailed(ctx context.Context, query telego.CallbackQuery, mode config.ForkMode) {
+	if mode == config.ForkDM {
+		_ = w.bot.AnswerCallbackWithAlert(ctx, query.ID, w.Config().Bot.Fork.GetDMText())
+		return
+	}
+	_ = w.bot.AnswerCallback(ctx, query.ID, "")
+}
+
+// forkMenu opens a menu from a shared group menu in a new message for the user who pressed the button.
+func (w *Wrapper) forkMenu(ctx context.Context, query telego.CallbackQuery, mode config.ForkMode, menuID string) error {
+	chatID, topicID := forkTarget(query, mode)
+	ctx = context.WithValue(ctx, forkCtxKey{}, true)
+	if _, err := w.menuManager.ShowMenu(ctx, chatID, topicID, menuID, w.menuEvaluator(ctx, chatID, query.From.ID)); err != nil {
+		w.forkFailed(ctx, query, mode)
+		return err
+	}
+	return w.bot.AnswerCallback(ctx, query.ID, "")
+}
+
+// forkFlow starts a flow from a shared group menu in a new message for the user who pressed the button.
+func (w *Wrapper) forkFlow(ctx context.Context, query telego.CallbackQuery, mode config.ForkMode, flowID string) error {
+	chatID, topicID := forkTarget(query, mode)
+	c, err := w.StartConversation(ctx, query.From.ID, chatID, topicID, flowID, 0)
+	if w.notifyFlowDenied(ctx, err, chatID, topicID, query.ID) {
+		return nil
+	}
+	if err != nil {
+		_ = w.bot.AnswerCallback(ctx, query.ID, "")
+		return err
+	}
+
+	w.setForkedConversation(c.UserID, c.ChatID, true)
+	if err := w.showStepPrompt(ctx, c); err != nil {
+		w.forkFailed(ctx, query, mode)
+		if mode == config.ForkDM {
+			w.EndConversation(ctx, c.UserID, c.ChatID)
+		}
+		return err
+	}
+	return w.bot.AnswerCallback(ctx, query.ID, "")
+}
+
+// forkShown keeps a menu shown in a fork bound to its user.
+// Returns false if the message is not a fork.
+func (w *Wrapper) forkShown(ctx context.Context, chatID int64, messageID int) bool {
+	if ctx.Value(forkCtxKey{}) == nil && !w.isFork(chatID, messageID) {
+		return false
+	}
+	if sender := handler.Sender(ctx); sender != nil {
+		w.keepFork(ctx, chatID, messageID, sender.ID)
+	}
+	return true
+}
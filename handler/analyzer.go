@@ -0,0 +1,99 @@
+// Package handler provides message routing and processing functionality.
+package handler
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// DefaultPhotoAnalysisErrorText is shown when a photo cannot be analyzed.
+const DefaultPhotoAnalysisErrorText = "Sorry, I couldn't process the photo. Please try again."
+
+// PhotoAnalysis is the result of analyzing a photo.
+type PhotoAnalysis struct {
+	// Text is text extracted from the photo (OCR, decoded codes).
+	// When set, it is the input branches match against.
+	Text string
+
+	// Labels describe the photo's contents.
+	Labels []string
+
+	// Data holds structured results stored into conversation data,
+	// e.g. a receipt's total and merchant.
+	Data map[string]interface{}
+}
+
+// PhotoAnalyzer extracts information from photos sent to photo steps.
+// Implementations wrap any OCR or vision backend. Errors created with
+// RejectPhoto are shown to the user as validation errors; other errors show
+// DefaultPhotoAnalysisErrorText.
+type PhotoAnalyzer interface {
+	Analyze(ctx context.Context, image []byte) (*PhotoAnalysis, error)
+}
+
+// PhotoAnalyzerFunc adapts a function to a PhotoAnalyzer.
+type PhotoAnalyzerFunc func(ctx context.Context, image []byte) (*PhotoAnalysis, error)
+
+// Analyze calls f(ctx, image).
+func (f PhotoAnalyzerFunc) Analyze(ctx context.Context, image []byte) (*PhotoAnalysis, error) {
+	return f(ctx, image)
+}
+
+// PhotoRejectedError is returned by analyzers to reject a photo with a message
+// shown to the user, e.g. "No receipt found in the photo".
+type PhotoRejectedError struct {
+	Text string // Message shown to the user
+}
+
+// Error returns the rejection message.
+func (e *PhotoRejectedError) Error() string {
+	return e.Text
+}
+
+// RejectPhoto returns a PhotoRejectedError with the given user-facing message.
+func RejectPhoto(text string) error {
+	return &PhotoRejectedError{Text: text}
+}
+
+// RegisterPhotoAnalyzer registers a photo analyzer by name.
+// The analyzer runs for photo steps whose analyzer field matches the name.
+func (r *Router) RegisterPhotoAnalyzer(name string, analyzer PhotoAnalyzer) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.photoAnalyzers[name] = analyzer
+}
+
+// analyzePhoto downloads a photo and runs the named analyzer on it.
+// Returns an error suitable for display to the user on failure.
+func (r *Router) analyzePhoto(ctx context.Context, name, fileID string) (*PhotoAnalysis, error) {
+	r.mu.RLock()
+	analyzer := r.photoAnalyzers[name]
+	r.mu.RUnlock()
+	if analyzer == nil {
+		r.logDebug("Photo analyzer %s not registered", name)
+		return &PhotoAnalysis{}, nil
+	}
+
+	image, err := r.bot.DownloadFile(ctx, fileID)
+	if err != nil {
+		r.logDebug("Photo download error: %v", err)
+		return nil, errors.New(DefaultPhotoAnalysisErrorText)
+	}
+
+	start := time.Now()
+	result, err := analyzer.Analyze(ctx, image)
+	r.observeLatency(ctx, "analyzer:"+name, start)
+	if err != nil {
+		var rejected *PhotoRejectedError
+		if errors.As(err, &rejected) {
+			return nil, rejected
+		}
+		r.logDebug("Photo analyzer error: %v", err)
+		return nil, errors.New(DefaultPhotoAnalysisErrorText)
+	}
+	if result == nil {
+		result = &PhotoAnalysis{}
+	}
+	return result, nil
+}
@@ -0,0 +1,124 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/mymmrac/telego"
+
+	"github.com/0xVanfer/tg-listener/config"
+	"github.com/0xVanfer/tg-listener/store"
+)
+
+// DefaultBindingTTL is how long a keyboard stays bound to its user after it was last shown.
+const DefaultBindingTTL = 24 * time.Hour
+
+// bindingKeyPrefix is the store key prefix of keyboard bindings.
+const bindingKeyPrefix = "binding:"
+
+// binding is the user a message's keyboard is bound to.
+type binding struct {
+	UserID  int64     `json:"user_id"` // User allowed to press the keyboard
+	Expires time.Time `json:"expires"` // When the binding lapses
+}
+
+// bindingKey returns the store key of the binding of a message.
+func bindingKey(chatID int64, messageID int) string {
+	return bindingKeyPrefix + strconv.FormatInt(chatID, 10) + ":" + strconv.Itoa(messageID)
+}
+
+// SetBindingStore sets the store keyboard bindings are persisted in, so
+// bound keyboards stay bound across restarts and on every instance sharing
+// the store. Bindings default to an in-memory store.
+func (r *Router) SetBindingStore(s store.Store) {
+	r.bindMu.Lock()
+	defer r.bindMu.Unlock()
+	r.bindings = s
+}
+
+// bindingStore returns the store keyboard bindings are persisted in.
+func (r *Router) bindingStore() store.Store {
+	r.bindMu.RLock()
+	defer r.bindMu.RUnlock()
+	return r.bindings
+}
+
+// BindMessage binds the keyboard of a message to a user: callbacks from other
+// users get the not_your_menu_text alert instead of being dispatched.
+// Binding again replaces the user and extends the binding by DefaultBindingTTL.
+func (r *Router) BindMessage(ctx context.Context, chatID int64, messageID int, userID int64) error {
+	b := binding{UserID: userID, Expires: time.Now().Add(DefaultBindingTTL)}
+	return store.PutJSON(ctx, r.bindingStore(), bindingKey(chatID, messageID), b)
+}
+
+// UnbindMessage lets every user press the keyboard of a message again.
+// Messages without a binding are left alone, so unbinding costs a store
+// write only when a binding exists.
+func (r *Router) UnbindMessage(ctx context.Context, chatID int64, messageID int) error {
+	st := r.bindingStore()
+	key := bindingKey(chatID, messageID)
+	if _, err := st.Get(ctx, key); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return nil
+		}
+		return err
+	}
+	return st.Delete(ctx, key)
+}
+
+// PruneBindings deletes expired keyboard bindings and returns how many were
+// deleted. Expired bindings are ignored when checking presses, so pruning
+// only bounds the store.
+func (r *Router) PruneBindings(ctx context.Context) (int, error) {
+	st := r.bindingStore()
+	keys, err := st.List(ctx, bindingKeyPrefix)
+	if err != nil {
+		return 0, err
+	}
+	now := time.Now()
+	pruned := 0
+	for _, key := range keys {
+		var b binding
+		if err := store.GetJSON(ctx, st, key, &b); err != nil {
+			continue
+		}
+		if now.After(b.Expires) {
+			if err := st.Delete(ctx, key); err != nil {
+				return pruned, err
+			}
+			pruned++
+		}
+	}
+	return pruned, nil
+}
+
+// boundElsewhere returns true if the keyboard pressed in a callback query is
+// bound to another user. If the binding can't be read, the press is refused,
+// so a failing store doesn't open bound keyboards to everyone.
+func (r *Router) boundElsewhere(ctx context.Context, query telego.CallbackQuery) bool {
+	if query.Message == nil {
+		return false
+	}
+	var b binding
+	key := bindingKey(query.Message.GetChat().ID, query.Message.GetMessageID())
+	if err := store.GetJSON(ctx, r.bindingStore(), key, &b); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return false
+		}
+		r.logDebug("Binding lookup error: %v", err)
+		return true
+	}
+	return time.Now().Before(b.Expires) && b.UserID != query.From.ID
+}
+
+// notYourMenuText returns the alert for users pressing a keyboard bound to someone else.
+func (r *Router) notYourMenuText() string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if r.config == nil || r.config.Bot == nil {
+		return config.DefaultNotYourMenuText
+	}
+	return r.config.Bot.GetNotYourMenuText()
+}
@@ -0,0 +1,123 @@
+package handler
+
+import (
+	"context"
+	"strings"
+
+	"github.com/mymmrac/telego"
+
+	"github.com/0xVanfer/tg-listener/config"
+	"github.com/0xVanfer/tg-listener/conv"
+	"github.com/0xVanfer/tg-listener/eventlog"
+)
+
+// maxQueuedCommands is the number of commands held per conversation by the
+// "queue" policy; older ones are dropped first.
+const maxQueuedCommands = 5
+
+// queueKey identifies the conversation commands are held for.
+type queueKey struct {
+	userID  int64
+	chatID  int64
+	topicID int
+}
+
+// queuedCommand is a command held until a conversation ends.
+type queuedCommand struct {
+	updateID int            // ID of the update that carried the command
+	msg      telego.Message // The command message
+}
+
+// cancelConfig returns the cancel command and command policy configuration.
+func (r *Router) cancelConfig() *config.CancelConfig {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if r.config == nil || r.config.Bot == nil {
+		return nil
+	}
+	return r.config.Bot.Cancel
+}
+
+// ExemptCommand lets a command run during conversations whatever the command
+// policy, without canceling them, e.g. a diagnostic command that inspects
+// the conversation.
+func (r *Router) ExemptCommand(command string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.exempt == nil {
+		r.exempt = make(map[string]bool)
+	}
+	r.exempt[strings.TrimPrefix(command, "/")] = true
+}
+
+// isExempt returns true if a command runs during conversations whatever the policy.
+func (r *Router) isExempt(command string) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.exempt[command]
+}
+
+// admitCommand applies the command policy to a command sent during a
+// conversation: it cancels the conversation, or drops or holds the command
+// with a reply. Returns false if the command must not run now.
+func (r *Router) admitCommand(ctx context.Context, msg telego.Message, command string) bool {
+	cfg := r.cancelConfig()
+	policy := cfg.GetCommandPolicy()
+	if policy == config.CommandPolicyRun || cfg.Allows(command) || r.isExempt(command) {
+		return true
+	}
+	c := r.convManager.GetIn(msg.From.ID, msg.Chat.ID, msg.MessageThreadID)
+	if c == nil {
+		return true
+	}
+
+	switch policy {
+	case config.CommandPolicyCancel:
+		r.logDebug("Command /%s cancels the conversation of user %d", command, msg.From.ID)
+		c.Cancel()
+		r.convManager.EndIn(ctx, c.UserID, c.ChatID, c.TopicID)
+		return true
+	case config.CommandPolicyQueue:
+		r.queueCommand(ctx, c, msg)
+		r.recordMessageEvent(ctx, eventlog.TypeBlocked, msg, "queued /"+command, nil)
+		_, _ = r.bot.SendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, cfg.GetQueuedText())
+		return false
+	default:
+		r.recordMessageEvent(ctx, eventlog.TypeBlocked, msg, "busy /"+command, nil)
+		_, _ = r.bot.SendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, cfg.GetBusyText())
+		return false
+	}
+}
+
+// queueCommand holds a command until the conversation ends.
+func (r *Router) queueCommand(ctx context.Context, c *conv.Conversation, msg telego.Message) {
+	key := queueKey{userID: c.UserID, chatID: c.ChatID, topicID: c.TopicID}
+	r.queueMu.Lock()
+	defer r.queueMu.Unlock()
+	if r.queued == nil {
+		r.queued = make(map[queueKey][]queuedCommand)
+	}
+	held := append(r.queued[key], queuedCommand{updateID: UpdateID(ctx), msg: msg})
+	if len(held) > maxQueuedCommands {
+		held = held[len(held)-maxQueuedCommands:]
+	}
+	r.queued[key] = held
+}
+
+// RunQueuedCommands runs the commands held for a conversation by the "queue"
+// policy, in the order they were sent. Call it once the conversation ended.
+// The commands run with their sender's roles and language, but without
+// middlewares.
+func (r *Router) RunQueuedCommands(ctx context.Context, c *conv.Conversation) {
+	key := queueKey{userID: c.UserID, chatID: c.ChatID, topicID: c.TopicID}
+	r.queueMu.Lock()
+	held := r.queued[key]
+	delete(r.queued, key)
+	r.queueMu.Unlock()
+
+	for _, q := range held {
+		update := telego.Update{UpdateID: q.updateID, Message: &q.msg}
+		ctx := context.WithValue(context.WithValue(ctx, updateIDKey{}, q.updateID), updateKey{}, update)
+		r.handleCommand(r.withStrings(r.withLanguage(r.withRoles(ctx, update), update)), q.msg)
+	}
+}
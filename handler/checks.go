@@ -0,0 +1,32 @@
+package handler
+
+import (
+	"context"
+
+	"github.com/0xVanfer/tg-listener/config"
+	"github.com/0xVanfer/tg-listener/conv"
+	"github.com/0xVanfer/tg-listener/eventlog"
+)
+
+// checkFlow runs the checks of the conversation's flow before it completes.
+// On failure the user is sent back to the offending step with the
+// explanation, shown like validation errors, and false is returned.
+func (r *Router) checkFlow(ctx context.Context, c *conv.Conversation, userID int64) bool {
+	stepID, err := r.flowEngine.CheckFlow(ctx, c)
+	if err == nil {
+		return true
+	}
+	r.logDebug("Flow check failed for user %d, back to step %s: %v", userID, stepID, err)
+	r.recordConvEvent(ctx, eventlog.TypeValidation, c, "check", err)
+
+	r.clearValidationError(ctx, c)
+	r.convManager.ChangeStepIn(ctx, userID, c.ChatID, c.TopicID, stepID)
+	text := "❌ " + err.Error()
+	if r.errorDisplay(c) == config.ErrorDisplayInline {
+		c.SetErrorText(text)
+	} else if sent, err := r.bot.SendMessage(ctx, c.ChatID, c.TopicID, text); err == nil && sent != nil {
+		c.SetErrorMsgID(sent.MessageID)
+	}
+	r.displayStep(ctx, c)
+	return false
+}
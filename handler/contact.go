@@ -0,0 +1,202 @@
+// Package handler provides message routing and processing functionality.
+package handler
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/mymmrac/telego"
+
+	"github.com/0xVanfer/tg-listener/config"
+	"github.com/0xVanfer/tg-listener/conv"
+	"github.com/0xVanfer/tg-listener/eventlog"
+)
+
+// DefaultForeignContactText is shown when a contact step receives someone else's contact.
+const DefaultForeignContactText = "Please share your own contact with the button below."
+
+// ContactHandler is a function type for handling shared contacts.
+type ContactHandler func(ctx context.Context, msg telego.Message) error
+
+// LocationHandler is a function type for handling shared locations.
+type LocationHandler func(ctx context.Context, msg telego.Message) error
+
+// SetContactHandler sets the handler for shared contacts outside contact steps.
+func (r *Router) SetContactHandler(handler ContactHandler) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.contactHandler = handler
+}
+
+// SetLocationHandler sets the handler for shared locations outside location steps.
+func (r *Router) SetLocationHandler(handler LocationHandler) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.locationHandler = handler
+}
+
+// handleContact processes shared contacts.
+func (r *Router) handleContact(ctx context.Context, msg telego.Message) {
+	if msg.From == nil {
+		return
+	}
+
+	// Authentication check
+	if !r.bot.CheckAuth(ctx, msg.From.ID, msg.From.Username) {
+		r.recordMessageEvent(ctx, eventlog.TypeUnauthorized, msg, "", nil)
+		return
+	}
+
+	// Maintenance mode check
+	if r.blockMessageInMaintenance(ctx, msg) {
+		return
+	}
+
+	r.logDebug("Contact received from user %d", msg.From.ID)
+
+	// Check if user is in a conversation expecting a contact
+	c := r.convManager.GetIn(msg.From.ID, msg.Chat.ID, msg.MessageThreadID)
+	if c != nil {
+		step := r.flowEngine.GetStep(c.FlowID, c.StepID)
+		if step != nil && step.InputType == config.InputTypeContact {
+			r.handleConversationContact(ctx, msg, c)
+			return
+		}
+	}
+
+	// Use contact handler
+	r.mu.RLock()
+	handler := r.contactHandler
+	r.mu.RUnlock()
+
+	if handler != nil {
+		start := time.Now()
+		err := handler(ctx, msg)
+		r.observeLatency(ctx, "contact", start)
+		if err != nil {
+			r.logDebug("Contact handler error: %v", err)
+			r.recordMessageEvent(ctx, eventlog.TypeError, msg, "contact", err)
+		}
+		return
+	}
+	r.recordMessageEvent(ctx, eventlog.TypeUnhandled, msg, "contact", nil)
+}
+
+// handleLocation processes shared locations.
+func (r *Router) handleLocation(ctx context.Context, msg telego.Message) {
+	if msg.From == nil {
+		return
+	}
+
+	// Authentication check
+	if !r.bot.CheckAuth(ctx, msg.From.ID, msg.From.Username) {
+		r.recordMessageEvent(ctx, eventlog.TypeUnauthorized, msg, "", nil)
+		return
+	}
+
+	// Maintenance mode check
+	if r.blockMessageInMaintenance(ctx, msg) {
+		return
+	}
+
+	r.logDebug("Location received from user %d", msg.From.ID)
+
+	// Check if user is in a conversation expecting a location
+	c := r.convManager.GetIn(msg.From.ID, msg.Chat.ID, msg.MessageThreadID)
+	if c != nil {
+		step := r.flowEngine.GetStep(c.FlowID, c.StepID)
+		if step != nil && step.InputType == config.InputTypeLocation {
+			r.handleConversationLocation(ctx, msg, c)
+			return
+		}
+	}
+
+	// Use location handler
+	r.mu.RLock()
+	handler := r.locationHandler
+	r.mu.RUnlock()
+
+	if handler != nil {
+		start := time.Now()
+		err := handler(ctx, msg)
+		r.observeLatency(ctx, "location", start)
+		if err != nil {
+			r.logDebug("Location handler error: %v", err)
+			r.recordMessageEvent(ctx, eventlog.TypeError, msg, "location", err)
+		}
+		return
+	}
+	r.recordMessageEvent(ctx, eventlog.TypeUnhandled, msg, "location", nil)
+}
+
+// handleConversationContact handles shared contacts during a conversation.
+// Only the user's own contact is accepted, as sent by a request_contact button.
+// The phone number goes through the step's validation and transforms, is stored
+// under store_as, and is the input used for branching.
+func (r *Router) handleConversationContact(ctx context.Context, msg telego.Message, c *conv.Conversation) {
+	defer r.saveConversation(ctx, c)
+
+	step := r.flowEngine.GetStep(c.FlowID, c.StepID)
+	if step == nil || msg.Contact == nil {
+		return
+	}
+	contact := msg.Contact
+	if contact.UserID != msg.From.ID {
+		r.failValidation(ctx, msg, c, errors.New(DefaultForeignContactText))
+		return
+	}
+
+	// Validate and normalize the phone number like text input
+	phone := contact.PhoneNumber
+	if err := r.flowEngine.ValidateInput(ctx, c, phone); err != nil {
+		r.failValidation(ctx, msg, c, err)
+		return
+	}
+	value, err := r.flowEngine.TransformInput(c, phone)
+	if err != nil {
+		r.failValidation(ctx, msg, c, err)
+		return
+	}
+	if s, ok := value.(string); ok {
+		phone = s
+	}
+	r.clearValidationError(ctx, c)
+
+	// Store the phone number and contact details
+	if step.StoreAs != "" {
+		c.Set(step.StoreAs, value)
+		c.Set(step.StoreAs+"_first_name", contact.FirstName)
+		c.Set(step.StoreAs+"_last_name", contact.LastName)
+		c.Set(step.StoreAs+"_user_id", contact.UserID)
+	}
+	c.AddHistory(c.StepID, "contact:"+phone)
+
+	r.completeStep(ctx, c, step, msg.From.ID, phone)
+}
+
+// handleConversationLocation handles shared locations during a conversation.
+// The coordinates are stored under store_as as "latitude,longitude", which is
+// also the input used for branching, and as <store_as>_latitude and _longitude.
+func (r *Router) handleConversationLocation(ctx context.Context, msg telego.Message, c *conv.Conversation) {
+	defer r.saveConversation(ctx, c)
+
+	step := r.flowEngine.GetStep(c.FlowID, c.StepID)
+	if step == nil || msg.Location == nil {
+		return
+	}
+	location := msg.Location
+	input := strconv.FormatFloat(location.Latitude, 'f', -1, 64) + "," + strconv.FormatFloat(location.Longitude, 'f', -1, 64)
+	r.clearValidationError(ctx, c)
+
+	// Store the coordinates
+	if step.StoreAs != "" {
+		c.Set(step.StoreAs, input)
+		c.Set(step.StoreAs+"_latitude", location.Latitude)
+		c.Set(step.StoreAs+"_longitude", location.Longitude)
+	}
+	c.AddHistory(c.StepID, "location:"+input)
+
+	r.completeStep(ctx, c, step, msg.From.ID, input)
+}
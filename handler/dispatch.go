@@ -0,0 +1,39 @@
+package handler
+
+import (
+	"context"
+
+	"github.com/mymmrac/telego"
+)
+
+// Dispatcher orders the processing of updates, e.g. a *dispatch.Dispatcher.
+type Dispatcher interface {
+	// Acquire waits until an update may be processed and returns the function
+	// to call once it is. Returns an error if the update must be dropped.
+	Acquire(ctx context.Context, update telego.Update) (func(), error)
+}
+
+// SetDispatcher sets the dispatcher every update waits for before it is
+// handled. Pass nil to handle updates as soon as they arrive.
+func (r *Router) SetDispatcher(d Dispatcher) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.dispatcher = d
+}
+
+// acquire waits for the dispatcher to let an update through.
+// Returns false if the update must be dropped.
+func (r *Router) acquire(ctx context.Context, update telego.Update) (func(), bool) {
+	r.mu.RLock()
+	d := r.dispatcher
+	r.mu.RUnlock()
+	if d == nil {
+		return func() {}, true
+	}
+	release, err := d.Acquire(ctx, update)
+	if err != nil {
+		r.logDebug("Update %d dropped: %v", update.UpdateID, err)
+		return nil, false
+	}
+	return release, true
+}
@@ -0,0 +1,69 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+
+	"github.com/mymmrac/telego"
+
+	"github.com/0xVanfer/tg-listener/eventlog"
+)
+
+// ErrorHandler receives errors returned by handlers and panics recovered while
+// handling an update. Recovered panics are reported as *PanicError.
+type ErrorHandler func(ctx context.Context, update telego.Update, err error)
+
+// PanicError is the error reported for a panic recovered while handling an update.
+type PanicError struct {
+	Value any    // Value passed to panic
+	Stack []byte // Stack trace of the panicking goroutine
+}
+
+// Error returns the panic value as text.
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("panic: %v", e.Value)
+}
+
+// SetErrorHandler sets the handler for handler errors and recovered panics.
+// Pass nil to only log them in debug mode.
+func (r *Router) SetErrorHandler(fn ErrorHandler) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.errorHandler = fn
+}
+
+// reportError passes an error to the error handler together with the update being handled.
+func (r *Router) reportError(ctx context.Context, err error) {
+	r.mu.RLock()
+	fn := r.errorHandler
+	r.mu.RUnlock()
+	if fn == nil {
+		return
+	}
+	update, _ := ctx.Value(updateKey{}).(telego.Update)
+	fn(ctx, update, err)
+}
+
+// recoverPanic recovers a panic raised while handling an update, records and
+// reports it, and answers a pending callback query so the user isn't left with
+// a loading spinner. It must be deferred directly.
+func (r *Router) recoverPanic(ctx context.Context, update telego.Update) {
+	p := recover()
+	if p == nil {
+		return
+	}
+	err := &PanicError{Value: p, Stack: debug.Stack()}
+	r.logDebug("Recovered %v\n%s", err, err.Stack)
+
+	e := updateEvent(update)
+	e.Type = eventlog.TypeError
+	e.Detail = "panic"
+	e.Error = err.Error()
+	r.recordEvent(ctx, e)
+	r.reportError(ctx, err)
+
+	if update.CallbackQuery != nil {
+		_ = r.bot.AnswerCallback(ctx, update.CallbackQuery.ID, "")
+	}
+}
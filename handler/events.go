@@ -0,0 +1,203 @@
+package handler
+
+import (
+	"context"
+	"time"
+
+	"github.com/mymmrac/telego"
+
+	"github.com/0xVanfer/tg-listener/conv"
+	"github.com/0xVanfer/tg-listener/eventlog"
+)
+
+// EventRecorder receives router events, e.g. to persist them in an event log.
+// It is called synchronously and should return quickly.
+type EventRecorder func(ctx context.Context, e eventlog.Event)
+
+// updateIDKey is the context key holding the ID of the update being handled.
+type updateIDKey struct{}
+
+// UpdateID returns the ID of the update being handled, or 0 outside update handling.
+func UpdateID(ctx context.Context) int {
+	id, _ := ctx.Value(updateIDKey{}).(int)
+	return id
+}
+
+// updateKey is the context key holding the update being handled.
+type updateKey struct{}
+
+// Sender returns the user who sent the update being handled, or nil outside
+// update handling and for updates without a sender.
+func Sender(ctx context.Context) *telego.User {
+	update, ok := ctx.Value(updateKey{}).(telego.Update)
+	if !ok {
+		return nil
+	}
+	return updateSender(update)
+}
+
+// CallbackMessageID returns the ID of the message whose button was pressed in
+// the update being handled, or 0 if the update is not a callback query.
+func CallbackMessageID(ctx context.Context) int {
+	update, ok := ctx.Value(updateKey{}).(telego.Update)
+	if !ok || update.CallbackQuery == nil || update.CallbackQuery.Message == nil {
+		return 0
+	}
+	return update.CallbackQuery.Message.GetMessageID()
+}
+
+// updateSender returns the user who sent an update, or nil.
+func updateSender(update telego.Update) *telego.User {
+	switch {
+	case update.Message != nil:
+		return update.Message.From
+	case update.CallbackQuery != nil:
+		return &update.CallbackQuery.From
+	case update.MyChatMember != nil:
+		return &update.MyChatMember.From
+	case update.ChatMember != nil:
+		return &update.ChatMember.From
+	case update.InlineQuery != nil:
+		return &update.InlineQuery.From
+	case update.ChosenInlineResult != nil:
+		return &update.ChosenInlineResult.From
+	case update.PreCheckoutQuery != nil:
+		return &update.PreCheckoutQuery.From
+	case update.ShippingQuery != nil:
+		return &update.ShippingQuery.From
+	}
+	return nil
+}
+
+// SetEventRecorder sets the recorder for router events. Pass nil to stop recording.
+func (r *Router) SetEventRecorder(recorder EventRecorder) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.eventRecorder = recorder
+}
+
+// recordEvent passes an event to the recorder, stamping its time and update ID.
+func (r *Router) recordEvent(ctx context.Context, e eventlog.Event) {
+	r.mu.RLock()
+	recorder := r.eventRecorder
+	r.mu.RUnlock()
+	if recorder == nil {
+		return
+	}
+	e.At = time.Now()
+	if e.UpdateID == 0 {
+		e.UpdateID = UpdateID(ctx)
+	}
+	recorder(ctx, e)
+}
+
+// recordConvEvent records an event of a conversation's current step.
+func (r *Router) recordConvEvent(ctx context.Context, typ eventlog.Type, c *conv.Conversation, detail string, err error) {
+	e := eventlog.Event{Type: typ, UserID: c.UserID, ChatID: c.ChatID, FlowID: c.FlowID, StepID: c.StepID, Detail: detail}
+	if err != nil {
+		e.Error = err.Error()
+	}
+	r.recordEvent(ctx, e)
+	if typ == eventlog.TypeError && err != nil {
+		r.reportError(ctx, err)
+	}
+}
+
+// recordMessageEvent records an event caused by a message.
+func (r *Router) recordMessageEvent(ctx context.Context, typ eventlog.Type, msg telego.Message, detail string, err error) {
+	e := eventlog.Event{Type: typ, ChatID: msg.Chat.ID, Detail: detail}
+	if msg.From != nil {
+		e.UserID = msg.From.ID
+	}
+	if err != nil {
+		e.Error = err.Error()
+	}
+	r.recordEvent(ctx, e)
+	if typ == eventlog.TypeError && err != nil {
+		r.reportError(ctx, err)
+	}
+}
+
+// recordCallbackEvent records an event caused by a callback query.
+func (r *Router) recordCallbackEvent(ctx context.Context, typ eventlog.Type, query telego.CallbackQuery, detail string, err error) {
+	e := eventlog.Event{Type: typ, UserID: query.From.ID, Detail: detail}
+	if query.Message != nil {
+		e.ChatID = query.Message.GetChat().ID
+	}
+	if err != nil {
+		e.Error = err.Error()
+	}
+	r.recordEvent(ctx, e)
+	if typ == eventlog.TypeError && err != nil {
+		r.reportError(ctx, err)
+	}
+}
+
+// recordUserEvent records an event of an update without a chat, like an inline query.
+func (r *Router) recordUserEvent(ctx context.Context, typ eventlog.Type, userID int64, detail string, err error) {
+	e := eventlog.Event{Type: typ, UserID: userID, Detail: detail}
+	if err != nil {
+		e.Error = err.Error()
+	}
+	r.recordEvent(ctx, e)
+	if typ == eventlog.TypeError && err != nil {
+		r.reportError(ctx, err)
+	}
+}
+
+// updateEvent describes a received update.
+func updateEvent(update telego.Update) eventlog.Event {
+	e := eventlog.Event{Type: eventlog.TypeUpdate, UpdateID: update.UpdateID}
+	switch {
+	case update.Message != nil:
+		e.ChatID = update.Message.Chat.ID
+		if update.Message.From != nil {
+			e.UserID = update.Message.From.ID
+		}
+		switch {
+		case len(update.Message.Text) > 0 && update.Message.Text[0] == '/':
+			e.Detail = "command"
+		case len(update.Message.Photo) > 0:
+			e.Detail = "photo"
+		case update.Message.Document != nil:
+			e.Detail = "document"
+		case update.Message.Voice != nil:
+			e.Detail = "voice"
+		case update.Message.SuccessfulPayment != nil:
+			e.Detail = "successful_payment"
+		case update.Message.WebAppData != nil:
+			e.Detail = "web_app_data"
+		default:
+			e.Detail = "message"
+		}
+	case update.CallbackQuery != nil:
+		e.Detail = "callback"
+		e.UserID = update.CallbackQuery.From.ID
+		if update.CallbackQuery.Message != nil {
+			e.ChatID = update.CallbackQuery.Message.GetChat().ID
+		}
+	case update.MyChatMember != nil:
+		e.Detail = "my_chat_member"
+		e.UserID = update.MyChatMember.From.ID
+		e.ChatID = update.MyChatMember.Chat.ID
+	case update.ChatMember != nil:
+		e.Detail = "chat_member"
+		e.UserID = update.ChatMember.From.ID
+		e.ChatID = update.ChatMember.Chat.ID
+	case update.InlineQuery != nil:
+		e.Detail = "inline_query"
+		e.UserID = update.InlineQuery.From.ID
+	case update.ChosenInlineResult != nil:
+		e.Detail = "chosen_inline_result"
+		e.UserID = update.ChosenInlineResult.From.ID
+	case update.PreCheckoutQuery != nil:
+		e.Detail = "pre_checkout_query"
+		e.UserID = update.PreCheckoutQuery.From.ID
+	case update.ShippingQuery != nil:
+		e.Detail = "shipping_query"
+		e.UserID = update.ShippingQuery.From.ID
+	default:
+		e.Detail = "other"
+	}
+	return e
+}
@@ -0,0 +1,111 @@
+// Package handler provides message routing and processing functionality.
+package handler
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/mymmrac/telego"
+
+	"github.com/0xVanfer/tg-listener/core"
+	"github.com/0xVanfer/tg-listener/eventlog"
+)
+
+// InlineQueryHandler is a function type for handling inline queries.
+// It must answer the query with core.Bot.AnswerInlineQuery.
+type InlineQueryHandler func(ctx context.Context, query telego.InlineQuery) error
+
+// ChosenInlineResultHandler is a function type for handling inline results
+// chosen by users. Telegram only sends them if inline feedback is enabled in @BotFather.
+type ChosenInlineResultHandler func(ctx context.Context, result telego.ChosenInlineResult) error
+
+// RegisterInlineQuery registers a handler for inline queries whose text starts
+// with prefix. The longest matching prefix wins; an empty prefix matches every query.
+func (r *Router) RegisterInlineQuery(prefix string, handler InlineQueryHandler) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.inlineHandlers[prefix] = handler
+}
+
+// SetChosenInlineResultHandler sets the handler for chosen inline results.
+func (r *Router) SetChosenInlineResultHandler(handler ChosenInlineResultHandler) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.chosenHandler = handler
+}
+
+// inlineHandler returns the handler for an inline query text and its matched prefix.
+func (r *Router) inlineHandler(text string) (InlineQueryHandler, string) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var handler InlineQueryHandler
+	matched := ""
+	for prefix, h := range r.inlineHandlers {
+		if strings.HasPrefix(text, prefix) && (handler == nil || len(prefix) > len(matched)) {
+			handler = h
+			matched = prefix
+		}
+	}
+	return handler, matched
+}
+
+// handleInlineQuery processes inline queries.
+// Queries from unauthorized users or during maintenance get an empty personal answer.
+func (r *Router) handleInlineQuery(ctx context.Context, query telego.InlineQuery) {
+	// Authentication check
+	if !r.bot.CheckAuth(ctx, query.From.ID, query.From.Username) {
+		r.recordUserEvent(ctx, eventlog.TypeUnauthorized, query.From.ID, "inline_query", nil)
+		_ = r.bot.AnswerInlineQuery(ctx, query.ID, nil, core.InlineAnswer{IsPersonal: true})
+		return
+	}
+
+	// Maintenance mode check
+	if blocked, _ := r.inMaintenance(ctx, query.From.ID); blocked {
+		r.recordUserEvent(ctx, eventlog.TypeBlocked, query.From.ID, "maintenance", nil)
+		_ = r.bot.AnswerInlineQuery(ctx, query.ID, nil, core.InlineAnswer{IsPersonal: true})
+		return
+	}
+
+	r.logDebug("Inline query from user %d: %s", query.From.ID, truncateString(query.Query, 50))
+
+	handler, matched := r.inlineHandler(query.Query)
+	if handler == nil {
+		r.recordUserEvent(ctx, eventlog.TypeUnhandled, query.From.ID, "inline_query", nil)
+		return
+	}
+
+	r.recordUserEvent(ctx, eventlog.TypeInlineQuery, query.From.ID, matched, nil)
+	start := time.Now()
+	err := handler(ctx, query)
+	r.observeLatency(ctx, "inline:"+matched, start)
+	if err != nil {
+		r.logDebug("Inline query handler error: %v", err)
+		r.recordUserEvent(ctx, eventlog.TypeError, query.From.ID, "inline:"+matched, err)
+	}
+}
+
+// handleChosenInlineResult processes chosen inline results.
+func (r *Router) handleChosenInlineResult(ctx context.Context, result telego.ChosenInlineResult) {
+	// Authentication check
+	if !r.bot.CheckAuth(ctx, result.From.ID, result.From.Username) {
+		r.recordUserEvent(ctx, eventlog.TypeUnauthorized, result.From.ID, "chosen_inline_result", nil)
+		return
+	}
+
+	r.mu.RLock()
+	handler := r.chosenHandler
+	r.mu.RUnlock()
+	if handler == nil {
+		return
+	}
+
+	r.recordUserEvent(ctx, eventlog.TypeInlineResult, result.From.ID, result.ResultID, nil)
+	start := time.Now()
+	err := handler(ctx, result)
+	r.observeLatency(ctx, "inline_result", start)
+	if err != nil {
+		r.logDebug("Chosen inline result handler error: %v", err)
+		r.recordUserEvent(ctx, eventlog.TypeError, result.From.ID, "inline_result", err)
+	}
+}
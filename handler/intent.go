@@ -0,0 +1,107 @@
+// Package handler provides message routing and processing functionality.
+package handler
+
+import (
+	"context"
+	"time"
+
+	"github.com/mymmrac/telego"
+
+	"github.com/0xVanfer/tg-listener/eventlog"
+)
+
+// Intent is the routing decision for a free-text message.
+// Exactly one of FlowID, MenuID, or Handler should be set; they are tried in that order.
+type Intent struct {
+	// FlowID is the flow to start for the user.
+	FlowID string
+
+	// Data is seeded into the conversation data when FlowID starts a flow,
+	// e.g. entities extracted from the message.
+	Data map[string]interface{}
+
+	// MenuID is the menu to show.
+	MenuID string
+
+	// Handler is invoked with the message.
+	Handler MessageHandler
+}
+
+// IntentResolver maps non-command text received outside a conversation to an intent.
+// It is the integration point for keyword, NLU, or LLM based routing.
+// Returning a nil intent passes the message on to the default message handler.
+type IntentResolver interface {
+	ResolveIntent(ctx context.Context, msg telego.Message) (*Intent, error)
+}
+
+// IntentResolverFunc adapts a function to an IntentResolver.
+type IntentResolverFunc func(ctx context.Context, msg telego.Message) (*Intent, error)
+
+// ResolveIntent calls f(ctx, msg).
+func (f IntentResolverFunc) ResolveIntent(ctx context.Context, msg telego.Message) (*Intent, error) {
+	return f(ctx, msg)
+}
+
+// IntentDispatcher starts flows and shows menus for resolved intents.
+// Used internally so the wrapper can act on intents the router cannot handle itself.
+type IntentDispatcher func(ctx context.Context, msg telego.Message, intent *Intent) error
+
+// SetIntentResolver sets the resolver consulted for free-text messages outside conversations.
+// Pass nil to disable intent routing.
+func (r *Router) SetIntentResolver(resolver IntentResolver) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.intentResolver = resolver
+}
+
+// SetIntentDispatcher sets the function that starts flows and shows menus for intents.
+func (r *Router) SetIntentDispatcher(fn IntentDispatcher) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.intentDispatcher = fn
+}
+
+// routeIntent resolves and acts on the intent of a free-text message.
+// Returns true if the message was handled.
+func (r *Router) routeIntent(ctx context.Context, msg telego.Message) bool {
+	r.mu.RLock()
+	resolver := r.intentResolver
+	dispatch := r.intentDispatcher
+	r.mu.RUnlock()
+
+	if resolver == nil {
+		return false
+	}
+
+	start := time.Now()
+	intent, err := resolver.ResolveIntent(ctx, msg)
+	r.observeLatency(ctx, "intent", start)
+	if err != nil {
+		r.logDebug("Intent resolver error: %v", err)
+		r.recordMessageEvent(ctx, eventlog.TypeError, msg, "intent", err)
+		return false
+	}
+	if intent == nil {
+		return false
+	}
+
+	if intent.FlowID == "" && intent.MenuID == "" {
+		if intent.Handler == nil {
+			return false
+		}
+		if err := intent.Handler(ctx, msg); err != nil {
+			r.logDebug("Intent handler error: %v", err)
+			r.recordMessageEvent(ctx, eventlog.TypeError, msg, "intent", err)
+		}
+		return true
+	}
+
+	if dispatch == nil {
+		return false
+	}
+	if err := dispatch(ctx, msg, intent); err != nil {
+		r.logDebug("Intent dispatch error: %v", err)
+		r.recordMessageEvent(ctx, eventlog.TypeError, msg, "intent", err)
+	}
+	return true
+}
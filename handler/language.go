@@ -0,0 +1,58 @@
+package handler
+
+import (
+	"context"
+
+	"github.com/mymmrac/telego"
+
+	"github.com/0xVanfer/tg-listener/core"
+)
+
+// LanguageResolver returns the language of a user, e.g. one they chose over
+// the language code reported by Telegram. It is called at most once per update.
+type LanguageResolver func(ctx context.Context, user *telego.User) string
+
+// TranslateFunc looks up the message with a key in a language.
+// Returns false if no catalog has the key.
+type TranslateFunc func(language, key string) (string, bool)
+
+// SetLanguageResolver sets the lookup of users' languages. Pass nil to use
+// the language code reported by Telegram.
+func (r *Router) SetLanguageResolver(resolver LanguageResolver) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.languageResolver = resolver
+}
+
+// SetTranslator sets the message lookup exposed to handlers as the
+// core.Translator of the update's sender. Pass nil to disable translations.
+func (r *Router) SetTranslator(fn TranslateFunc) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.translate = fn
+}
+
+// withLanguage tags a context with a lazy lookup of the language of the
+// update's sender, and with a translator into it.
+func (r *Router) withLanguage(ctx context.Context, update telego.Update) context.Context {
+	from := updateSender(update)
+	if from == nil {
+		return ctx
+	}
+	r.mu.RLock()
+	resolver, translate := r.languageResolver, r.translate
+	r.mu.RUnlock()
+
+	tagged := core.WithLanguage(ctx, func() string {
+		if resolver != nil {
+			return resolver(ctx, from)
+		}
+		return from.LanguageCode
+	})
+	if translate == nil {
+		return tagged
+	}
+	return core.WithTranslator(tagged, func(key string) (string, bool) {
+		return translate(core.LanguageFrom(tagged), key)
+	})
+}
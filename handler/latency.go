@@ -0,0 +1,27 @@
+package handler
+
+import (
+	"context"
+	"time"
+
+	"github.com/0xVanfer/tg-listener/latency"
+)
+
+// SetLatencyObserver sets the observer of handler call durations: commands,
+// callbacks, default media and message handlers, intent resolution, photo
+// analyzers, and transcription. Pass nil to stop observing.
+func (r *Router) SetLatencyObserver(observer latency.Observer) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.latencyObserver = observer
+}
+
+// observeLatency reports the duration of a handler call started at start.
+func (r *Router) observeLatency(ctx context.Context, name string, start time.Time) {
+	r.mu.RLock()
+	observer := r.latencyObserver
+	r.mu.RUnlock()
+	if observer != nil {
+		observer(ctx, name, time.Since(start))
+	}
+}
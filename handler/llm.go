@@ -0,0 +1,71 @@
+// Package handler provides message routing and processing functionality.
+package handler
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/mymmrac/telego"
+
+	"github.com/0xVanfer/tg-listener/breaker"
+	"github.com/0xVanfer/tg-listener/conv"
+	"github.com/0xVanfer/tg-listener/eventlog"
+)
+
+// DefaultCompletionErrorText is shown when a completer fails to answer an LLM step.
+const DefaultCompletionErrorText = "Sorry, I couldn't answer that. Please try again."
+
+// streamEditInterval is the minimum time between edits of the prompt message
+// while a completion streams, keeping within Telegram's edit rate limits.
+const streamEditInterval = time.Second
+
+// runCompletion runs one turn of an LLM step, streaming the response into the
+// step's prompt message. Returns true if the step is done and should complete;
+// otherwise the prompt is re-rendered with the response and the step waits for more input.
+// If the completer fails, the prompt is restored and the user is told so.
+func (r *Router) runCompletion(ctx context.Context, msg telego.Message, c *conv.Conversation, input string) bool {
+	var (
+		mu       sync.Mutex
+		lastEdit time.Time
+		msgID    = c.KeyboardMsgID
+	)
+	stream := func(partial string) {
+		mu.Lock()
+		defer mu.Unlock()
+		if partial == "" || time.Since(lastEdit) < streamEditInterval {
+			return
+		}
+		lastEdit = time.Now()
+		if msgID > 0 {
+			_, _ = r.bot.EditMessage(ctx, c.ChatID, msgID, partial)
+			return
+		}
+		if sent, err := r.bot.SendMessage(ctx, c.ChatID, c.TopicID, partial); err == nil && sent != nil {
+			msgID = sent.MessageID
+			c.SetKeyboardMsgID(msgID)
+		}
+	}
+
+	done, err := r.flowEngine.Complete(ctx, c, input, stream)
+	if err != nil {
+		r.logDebug("Completion error: %v", err)
+		r.recordConvEvent(ctx, eventlog.TypeError, c, "llm", err)
+		// Replace any partially streamed text with the prompt
+		r.displayStep(ctx, c)
+		if errors.Is(err, conv.ErrHandlerTimeout) || errors.Is(err, breaker.ErrOpen) {
+			r.reportUnavailable(ctx, c, err)
+		} else {
+			r.reportValidationError(ctx, msg, c, errors.New(DefaultCompletionErrorText))
+		}
+		return false
+	}
+	if done {
+		return true
+	}
+
+	// Show the full response with the step keyboard
+	r.displayStep(ctx, c)
+	return false
+}
@@ -0,0 +1,129 @@
+// Package handler provides message routing and processing functionality.
+package handler
+
+import (
+	"context"
+	"time"
+
+	"github.com/mymmrac/telego"
+
+	"github.com/0xVanfer/tg-listener/eventlog"
+)
+
+// MemberEventKind is the kind of a chat membership change.
+type MemberEventKind string
+
+// Membership changes routed to member handlers.
+const (
+	// BotAddedToGroup is a change adding the bot to a group, supergroup, or channel.
+	BotAddedToGroup MemberEventKind = "bot_added"
+
+	// BotKicked is a change removing the bot from a group, supergroup, or
+	// channel, whether it was banned or removed.
+	BotKicked MemberEventKind = "bot_kicked"
+
+	// UserJoined is a user joining a chat the bot administers.
+	UserJoined MemberEventKind = "user_joined"
+
+	// UserLeft is a user leaving, or being removed from, a chat the bot administers.
+	UserLeft MemberEventKind = "user_left"
+)
+
+// MemberEvent is a chat membership change of the bot or another user.
+type MemberEvent struct {
+	Kind   MemberEventKind          // Kind of change
+	Chat   telego.Chat              // Chat the membership changed in
+	User   telego.User              // Member whose status changed: the bot, or the user
+	From   telego.User              // User who made the change, e.g. the admin adding the bot
+	Update telego.ChatMemberUpdated // The raw update
+}
+
+// MemberHandler is a function type for handling chat membership changes.
+type MemberHandler func(ctx context.Context, event MemberEvent) error
+
+// RegisterMemberHandler registers a handler for a kind of membership change.
+// Handlers of the same kind run in the order they were registered.
+//
+// Changes of the bot itself (my_chat_member updates) are always sent by
+// Telegram; changes of other users (chat_member updates) are only sent to bots
+// that administer the chat and request them, see WantsChatMembers. Changes in
+// private chats, i.e. users blocking or unblocking the bot, are not routed.
+func (r *Router) RegisterMemberHandler(kind MemberEventKind, handler MemberHandler) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.memberHandlers == nil {
+		r.memberHandlers = make(map[MemberEventKind][]MemberHandler)
+	}
+	r.memberHandlers[kind] = append(r.memberHandlers[kind], handler)
+}
+
+// WantsChatMembers returns true if handlers for UserJoined or UserLeft are
+// registered, so chat_member updates must be requested.
+func (r *Router) WantsChatMembers() bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return len(r.memberHandlers[UserJoined]) > 0 || len(r.memberHandlers[UserLeft]) > 0
+}
+
+// memberEvent classifies a membership change. Returns false for changes
+// that are not routed, e.g. promotions or changes in private chats.
+func memberEvent(update telego.ChatMemberUpdated, self bool) (MemberEvent, bool) {
+	event := MemberEvent{
+		Chat:   update.Chat,
+		User:   update.NewChatMember.MemberUser(),
+		From:   update.From,
+		Update: update,
+	}
+	if update.Chat.Type == telego.ChatTypePrivate {
+		return event, false
+	}
+	wasMember := update.OldChatMember.MemberIsMember()
+	isMember := update.NewChatMember.MemberIsMember()
+	switch {
+	case wasMember == isMember:
+		return event, false
+	case self && isMember:
+		event.Kind = BotAddedToGroup
+	case self:
+		event.Kind = BotKicked
+	case isMember:
+		event.Kind = UserJoined
+	default:
+		event.Kind = UserLeft
+	}
+	return event, true
+}
+
+// handleMemberUpdate processes my_chat_member (self is true) and chat_member updates.
+func (r *Router) handleMemberUpdate(ctx context.Context, update telego.ChatMemberUpdated, self bool) {
+	event, ok := memberEvent(update, self)
+	if !ok {
+		return
+	}
+	r.logDebug("Member event %s: user %d in chat %d", event.Kind, event.User.ID, event.Chat.ID)
+
+	r.mu.RLock()
+	handlers := r.memberHandlers[event.Kind]
+	r.mu.RUnlock()
+	if len(handlers) == 0 {
+		return
+	}
+
+	detail := "member:" + string(event.Kind)
+	for _, handler := range handlers {
+		start := time.Now()
+		err := handler(ctx, event)
+		r.observeLatency(ctx, detail, start)
+		if err != nil {
+			r.logDebug("Member handler error: %v", err)
+			r.recordEvent(ctx, eventlog.Event{
+				Type:   eventlog.TypeError,
+				UserID: event.User.ID,
+				ChatID: event.Chat.ID,
+				Detail: detail,
+				Error:  err.Error(),
+			})
+			r.reportError(ctx, err)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package handler
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mymmrac/telego"
+
+	"github.com/0xVanfer/tg-listener/config"
+)
+
+// MarkOneShot marks callback data prefixes as one-shot buttons, in addition to
+// those listed in the one_shot configuration. See config.OneShotConfig.
+func (r *Router) MarkOneShot(prefixes ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.oneShot = append(r.oneShot, prefixes...)
+}
+
+// oneShotConfig returns the configured one-shot settings, or nil.
+func (r *Router) oneShotConfig() *config.OneShotConfig {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if r.config == nil || r.config.Bot == nil {
+		return nil
+	}
+	return r.config.Bot.OneShot
+}
+
+// isOneShot reports whether callback data belongs to a one-shot button.
+func (r *Router) isOneShot(data string) bool {
+	if r.oneShotConfig().Matches(data) {
+		return true
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, prefix := range r.oneShot {
+		if strings.HasPrefix(data, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// claimAction consumes a one-shot button press. It returns the key of the
+// consumed press, "" for buttons that aren't one-shot, and false if the same
+// button on the same message was already pressed within the TTL.
+func (r *Router) claimAction(query telego.CallbackQuery) (string, bool) {
+	if !r.isOneShot(query.Data) {
+		return "", true
+	}
+	key := query.InlineMessageID
+	if query.Message != nil {
+		key = fmt.Sprintf("%d:%d", query.Message.GetChat().ID, query.Message.GetMessageID())
+	}
+	key += ":" + query.Data
+
+	now := time.Now()
+	r.actionMu.Lock()
+	defer r.actionMu.Unlock()
+	for k, expires := range r.consumed {
+		if now.After(expires) {
+			delete(r.consumed, k)
+		}
+	}
+	if _, ok := r.consumed[key]; ok {
+		return key, false
+	}
+	r.consumed[key] = now.Add(r.oneShotConfig().GetTTL())
+	return key, true
+}
+
+// releaseAction releases a consumed press so the button can be pressed again,
+// e.g. after its handler failed.
+func (r *Router) releaseAction(key string) {
+	if key == "" {
+		return
+	}
+	r.actionMu.Lock()
+	defer r.actionMu.Unlock()
+	delete(r.consumed, key)
+}
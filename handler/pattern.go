@@ -0,0 +1,108 @@
+package handler
+
+import (
+	"context"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// patternParam matches the {name} placeholders of callback patterns.
+var patternParam = regexp.MustCompile(`\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// patternHandler is a callback handler matched by a regular expression.
+type patternHandler struct {
+	key     string          // Pattern or expression, naming the handler in events and shadows
+	re      *regexp.Regexp  // Expression matching the whole callback data
+	handler CallbackHandler // Handler to run
+}
+
+// callbackParamsKey is the context key holding the parameters extracted from
+// the callback data being handled.
+type callbackParamsKey struct{}
+
+// CallbackParams returns the parameters extracted from the callback data by
+// the pattern or expression the handler was registered with, by name.
+// Unnamed groups of expressions are keyed by their index, starting at "1".
+// Returns nil for handlers registered otherwise.
+func CallbackParams(ctx context.Context) map[string]string {
+	params, _ := ctx.Value(callbackParamsKey{}).(map[string]string)
+	return params
+}
+
+// CallbackParam returns a parameter extracted from the callback data, or an
+// empty string, see CallbackParams.
+func CallbackParam(ctx context.Context, name string) string {
+	return CallbackParams(ctx)[name]
+}
+
+// CompilePattern compiles a callback pattern such as "item:{id}:{action}" to
+// an expression matching the whole data, where each {name} matches one or more
+// characters and the rest matches literally.
+func CompilePattern(pattern string) *regexp.Regexp {
+	var expr strings.Builder
+	expr.WriteString("^")
+	last := 0
+	for _, loc := range patternParam.FindAllStringSubmatchIndex(pattern, -1) {
+		expr.WriteString(regexp.QuoteMeta(pattern[last:loc[0]]))
+		expr.WriteString("(?P<" + pattern[loc[2]:loc[3]] + ">.+?)")
+		last = loc[1]
+	}
+	expr.WriteString(regexp.QuoteMeta(pattern[last:]))
+	expr.WriteString("$")
+	return regexp.MustCompile(expr.String())
+}
+
+// RegisterCallbackPattern registers a callback handler for data matching a
+// pattern such as "item:{id}:{action}", with the values of the placeholders
+// available through CallbackParam. Patterns and expressions are tried after
+// exact matches and before prefixes, in the order they were registered.
+func (r *Router) RegisterCallbackPattern(pattern string, handler CallbackHandler) {
+	r.registerPattern(pattern, CompilePattern(pattern), handler)
+}
+
+// RegisterCallbackRegex registers a callback handler for data matching a
+// regular expression, see RegisterCallbackPattern. The expression must match
+// the whole data; its named and unnamed groups are available through
+// CallbackParam.
+func (r *Router) RegisterCallbackRegex(re *regexp.Regexp, handler CallbackHandler) {
+	r.registerPattern(re.String(), re, handler)
+}
+
+// registerPattern adds a pattern handler, replacing one with the same key.
+func (r *Router) registerPattern(key string, re *regexp.Regexp, handler CallbackHandler) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, p := range r.patternHandlers {
+		if p.key == key {
+			r.patternHandlers[i].handler = handler
+			return
+		}
+	}
+	r.patternHandlers = append(r.patternHandlers, patternHandler{key: key, re: re, handler: handler})
+}
+
+// matchPattern returns the first pattern handler matching the whole data,
+// with its key and the extracted parameters.
+func (r *Router) matchPattern(data string) (CallbackHandler, string, map[string]string) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, p := range r.patternHandlers {
+		loc := p.re.FindStringSubmatchIndex(data)
+		if loc == nil || loc[0] != 0 || loc[1] != len(data) {
+			continue
+		}
+		params := make(map[string]string)
+		for i, name := range p.re.SubexpNames() {
+			if i == 0 || loc[2*i] < 0 {
+				continue
+			}
+			if name == "" {
+				name = strconv.Itoa(i)
+			}
+			params[name] = data[loc[2*i]:loc[2*i+1]]
+		}
+		return p.handler, p.key, params
+	}
+	return nil, "", nil
+}
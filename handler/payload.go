@@ -0,0 +1,58 @@
+package handler
+
+import (
+	"context"
+
+	"github.com/mymmrac/telego"
+
+	"github.com/0xVanfer/tg-listener/signing"
+)
+
+// PayloadResolver returns the callback data a token stands for, and other
+// callback data unchanged; see payload.Registry.Resolve.
+type PayloadResolver func(ctx context.Context, data string) (string, error)
+
+// SetPayloadResolver sets the lookup of tokens standing for long callback
+// data, so handlers and flows receive the original data. Pass nil to
+// dispatch callback data as is.
+func (r *Router) SetPayloadResolver(resolver PayloadResolver) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.payloadResolver = resolver
+}
+
+// resolvePayload returns the callback data a token stands for.
+func (r *Router) resolvePayload(ctx context.Context, data string) (string, error) {
+	r.mu.RLock()
+	resolver := r.payloadResolver
+	r.mu.RUnlock()
+	if resolver == nil {
+		return data, nil
+	}
+	return resolver(ctx, data)
+}
+
+// decodeCallback returns the original data of a callback query. Unsigned
+// tokens are resolved before the signature check, so sensitive data behind
+// them still requires a signature; signed tokens, as made by signing long
+// data, are resolved after it. On failure it returns the check that failed.
+func (r *Router) decodeCallback(ctx context.Context, query telego.CallbackQuery) (string, string, error) {
+	signed := signing.IsSigned(query.Data)
+	if !signed {
+		data, err := r.resolvePayload(ctx, query.Data)
+		if err != nil {
+			return "", "payload", err
+		}
+		query.Data = data
+	}
+	data, err := r.verifyCallback(query)
+	if err != nil {
+		return "", "signature", err
+	}
+	if signed {
+		if data, err = r.resolvePayload(ctx, data); err != nil {
+			return "", "payload", err
+		}
+	}
+	return data, "", nil
+}
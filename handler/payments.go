@@ -0,0 +1,297 @@
+// Package handler provides message routing and processing functionality.
+package handler
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mymmrac/telego"
+
+	"github.com/0xVanfer/tg-listener/config"
+	"github.com/0xVanfer/tg-listener/conv"
+	"github.com/0xVanfer/tg-listener/eventlog"
+)
+
+// Payment step inputs, used for branching.
+const (
+	PaymentPaid = "paid" // Input of payment steps whose invoice was paid
+)
+
+// Conversation data keys of payment steps.
+const (
+	InvoicePayloadKey  = "_invoice_payload"  // Payload of the invoice sent by the current payment step
+	InvoiceAmountKey   = "_invoice_amount"   // Total amount of the invoice, in the smallest units of its currency
+	InvoiceCurrencyKey = "_invoice_currency" // Currency of the invoice
+	PaymentErrorKey    = "_payment_error"    // Why the latest payment was declined
+)
+
+// invoicePayloadPrefix prefixes the payloads of invoices sent by payment steps.
+const invoicePayloadPrefix = "step:"
+
+// DefaultInvoiceExpiredText declines payments of invoices whose payment step
+// the conversation has left.
+const DefaultInvoiceExpiredText = "This invoice has expired."
+
+// DefaultPaymentDeclinedText declines payments of users who may not use the bot.
+const DefaultPaymentDeclinedText = "This payment can't be accepted."
+
+// DefaultShippingUnavailableText declines shipping queries when no shipping handler is set.
+const DefaultShippingUnavailableText = "Shipping is not available."
+
+// PreCheckoutHandler confirms that the order of a pre-checkout query can be
+// fulfilled, e.g. that the item is in stock. Returning an error declines the
+// payment, showing the error text to the user.
+type PreCheckoutHandler func(ctx context.Context, query telego.PreCheckoutQuery) error
+
+// ShippingHandler returns the shipping options for the address of a shipping
+// query. Returning an error declines the query, showing the error text to the user.
+type ShippingHandler func(ctx context.Context, query telego.ShippingQuery) ([]telego.ShippingOption, error)
+
+// PaymentHandler is a function type for handling successful payments outside payment steps.
+type PaymentHandler func(ctx context.Context, msg telego.Message) error
+
+// SetPreCheckoutHandler sets the handler confirming orders before payment.
+// Without one, orders of invoices sent from code are confirmed, and orders of
+// payment steps are confirmed while the conversation is on the step.
+func (r *Router) SetPreCheckoutHandler(handler PreCheckoutHandler) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.preCheckoutHandler = handler
+}
+
+// SetShippingHandler sets the handler answering shipping queries of flexible invoices.
+func (r *Router) SetShippingHandler(handler ShippingHandler) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.shippingHandler = handler
+}
+
+// SetPaymentHandler sets the handler for successful payments outside payment steps.
+func (r *Router) SetPaymentHandler(handler PaymentHandler) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.paymentHandler = handler
+}
+
+// NewInvoicePayload returns the payload of an invoice sent by a conversation's
+// payment step, and stores it with the invoice's amount and currency, so the
+// payment is matched to the step and checked against the invoice.
+func NewInvoicePayload(c *conv.Conversation, amount int, currency string) (string, error) {
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	payload := invoicePayloadPrefix + strconv.FormatInt(c.ChatID, 10) + ":" + strconv.Itoa(c.TopicID) + ":" + hex.EncodeToString(buf)
+	c.Set(InvoicePayloadKey, payload)
+	c.Set(InvoiceAmountKey, amount)
+	c.Set(InvoiceCurrencyKey, currency)
+	return payload, nil
+}
+
+// parseInvoicePayload extracts the chat and topic of a payment step's invoice payload.
+// Returns false for payloads of invoices sent from code.
+func parseInvoicePayload(payload string) (int64, int, bool) {
+	rest, ok := strings.CutPrefix(payload, invoicePayloadPrefix)
+	if !ok {
+		return 0, 0, false
+	}
+	parts := strings.Split(rest, ":")
+	if len(parts) != 3 {
+		return 0, 0, false
+	}
+	chatID, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	topicID, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return 0, 0, false
+	}
+	return chatID, topicID, true
+}
+
+// paymentConversation returns the conversation whose current payment step
+// sent the invoice with the payload, or nil.
+func (r *Router) paymentConversation(userID int64, payload string) *conv.Conversation {
+	chatID, topicID, ok := parseInvoicePayload(payload)
+	if !ok {
+		return nil
+	}
+	c := r.convManager.GetIn(userID, chatID, topicID)
+	if c == nil || c.GetString(InvoicePayloadKey) != payload {
+		return nil
+	}
+	step := r.flowEngine.GetStep(c.FlowID, c.StepID)
+	if step == nil || step.InputType != config.InputTypePayment {
+		return nil
+	}
+	return c
+}
+
+// handlePreCheckout confirms or declines the order of a pre-checkout query.
+// Invoices of payment steps are declined once the conversation left the step,
+// or if the amount or currency differ from the invoice; a declined payment
+// moves the conversation to the step's failure step.
+func (r *Router) handlePreCheckout(ctx context.Context, query telego.PreCheckoutQuery) {
+	if !r.bot.CheckAuth(ctx, query.From.ID, query.From.Username) {
+		r.recordUserEvent(ctx, eventlog.TypeUnauthorized, query.From.ID, "pre_checkout", nil)
+		_ = r.bot.AnswerPreCheckoutQuery(ctx, query.ID, DefaultPaymentDeclinedText)
+		return
+	}
+	r.logDebug("Pre-checkout query from user %d: %d %s", query.From.ID, query.TotalAmount, query.Currency)
+
+	var c *conv.Conversation
+	if _, _, ok := parseInvoicePayload(query.InvoicePayload); ok {
+		c = r.paymentConversation(query.From.ID, query.InvoicePayload)
+		if c == nil || c.GetInt(InvoiceAmountKey) != query.TotalAmount || c.GetString(InvoiceCurrencyKey) != query.Currency {
+			r.recordUserEvent(ctx, eventlog.TypeBlocked, query.From.ID, "invoice_expired", nil)
+			_ = r.bot.AnswerPreCheckoutQuery(ctx, query.ID, DefaultInvoiceExpiredText)
+			return
+		}
+	}
+
+	r.mu.RLock()
+	handler := r.preCheckoutHandler
+	r.mu.RUnlock()
+
+	if handler != nil {
+		start := time.Now()
+		err := handler(ctx, query)
+		r.observeLatency(ctx, "pre_checkout", start)
+		if err != nil {
+			r.logDebug("Pre-checkout declined: %v", err)
+			_ = r.bot.AnswerPreCheckoutQuery(ctx, query.ID, err.Error())
+			if c != nil {
+				r.FailPayment(ctx, c, err)
+			} else {
+				r.recordUserEvent(ctx, eventlog.TypeBlocked, query.From.ID, "pre_checkout", err)
+			}
+			return
+		}
+	}
+	if err := r.bot.AnswerPreCheckoutQuery(ctx, query.ID, ""); err != nil {
+		r.logDebug("Pre-checkout answer error: %v", err)
+		r.recordUserEvent(ctx, eventlog.TypeError, query.From.ID, "pre_checkout", err)
+	}
+}
+
+// handleShippingQuery answers a shipping query with the shipping handler's options.
+func (r *Router) handleShippingQuery(ctx context.Context, query telego.ShippingQuery) {
+	r.mu.RLock()
+	handler := r.shippingHandler
+	r.mu.RUnlock()
+
+	if handler == nil {
+		r.recordUserEvent(ctx, eventlog.TypeUnhandled, query.From.ID, "shipping_query", nil)
+		_ = r.bot.AnswerShippingQuery(ctx, query.ID, nil, DefaultShippingUnavailableText)
+		return
+	}
+
+	start := time.Now()
+	options, err := handler(ctx, query)
+	r.observeLatency(ctx, "shipping", start)
+	if err != nil {
+		r.logDebug("Shipping query declined: %v", err)
+		_ = r.bot.AnswerShippingQuery(ctx, query.ID, nil, err.Error())
+		return
+	}
+	declined := ""
+	if len(options) == 0 {
+		declined = DefaultShippingUnavailableText
+	}
+	if err := r.bot.AnswerShippingQuery(ctx, query.ID, options, declined); err != nil {
+		r.logDebug("Shipping answer error: %v", err)
+		r.recordUserEvent(ctx, eventlog.TypeError, query.From.ID, "shipping_query", err)
+	}
+}
+
+// handleSuccessfulPayment processes messages reporting a successful payment.
+// Payments of a payment step complete the step; others go to the payment handler.
+func (r *Router) handleSuccessfulPayment(ctx context.Context, msg telego.Message) {
+	if msg.From == nil {
+		return
+	}
+	payment := msg.SuccessfulPayment
+	r.logDebug("Payment of %d %s received from user %d", payment.TotalAmount, payment.Currency, msg.From.ID)
+
+	if c := r.paymentConversation(msg.From.ID, payment.InvoicePayload); c != nil {
+		r.handleConversationPayment(ctx, msg, c)
+		return
+	}
+
+	r.mu.RLock()
+	handler := r.paymentHandler
+	r.mu.RUnlock()
+
+	if handler != nil {
+		start := time.Now()
+		err := handler(ctx, msg)
+		r.observeLatency(ctx, "payment", start)
+		if err != nil {
+			r.logDebug("Payment handler error: %v", err)
+			r.recordMessageEvent(ctx, eventlog.TypeError, msg, "payment", err)
+		}
+		return
+	}
+	r.recordMessageEvent(ctx, eventlog.TypeUnhandled, msg, "payment", nil)
+}
+
+// handleConversationPayment completes a payment step with input "paid".
+// The Telegram charge ID is stored under store_as, with the amount, currency,
+// and payment provider's charge ID as <store_as>_amount, _currency, and
+// _provider_charge_id.
+func (r *Router) handleConversationPayment(ctx context.Context, msg telego.Message, c *conv.Conversation) {
+	defer r.saveConversation(ctx, c)
+
+	step := r.flowEngine.GetStep(c.FlowID, c.StepID)
+	if step == nil {
+		return
+	}
+	payment := msg.SuccessfulPayment
+	c.Set(InvoicePayloadKey, "")
+
+	if step.StoreAs != "" {
+		c.Set(step.StoreAs, payment.TelegramPaymentChargeID)
+		c.Set(step.StoreAs+"_amount", payment.TotalAmount)
+		c.Set(step.StoreAs+"_currency", payment.Currency)
+		c.Set(step.StoreAs+"_provider_charge_id", payment.ProviderPaymentChargeID)
+	}
+	c.AddHistory(c.StepID, "payment:"+payment.TelegramPaymentChargeID)
+
+	r.completeStep(ctx, c, step, msg.From.ID, PaymentPaid)
+}
+
+// FailPayment ends a payment step whose payment was declined or whose invoice
+// couldn't be sent: the reason is stored under PaymentErrorKey and the
+// conversation moves to the step's failure step, or ends without one.
+func (r *Router) FailPayment(ctx context.Context, c *conv.Conversation, err error) {
+	defer r.saveConversation(ctx, c)
+
+	step := r.flowEngine.GetStep(c.FlowID, c.StepID)
+	if step == nil || step.Payment == nil {
+		return
+	}
+	r.recordConvEvent(ctx, eventlog.TypeValidation, c, "payment_failed", err)
+	c.Set(InvoicePayloadKey, "")
+	c.Set(PaymentErrorKey, errorText(err))
+
+	if step.Payment.FailureStep == "" {
+		c.Cancel()
+		r.convManager.EndIn(ctx, c.UserID, c.ChatID, c.TopicID)
+		return
+	}
+	r.convManager.ChangeStepIn(ctx, c.UserID, c.ChatID, c.TopicID, step.Payment.FailureStep)
+	r.displayStep(ctx, c)
+}
+
+// errorText returns the text of an error, or "" for nil.
+func errorText(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
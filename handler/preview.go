@@ -0,0 +1,35 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/0xVanfer/tg-listener/conv"
+	"github.com/0xVanfer/tg-listener/core"
+)
+
+// finishPreview ends a preview conversation whose flow reached its end and
+// shows the data it collected, in place of the skipped completion handler.
+func (r *Router) finishPreview(ctx context.Context, c *conv.Conversation, userID int64) {
+	data := c.CopyData()
+	keys := make([]string, 0, len(data))
+	for key := range data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	b := core.NewBuilder().Header("🧪 Preview of " + c.FlowID + " finished")
+	if len(keys) == 0 {
+		b.Line("No data collected.")
+	}
+	for _, key := range keys {
+		b.KeyValue(key, fmt.Sprint(data[key]))
+	}
+	text, entities := b.Build()
+
+	r.convManager.EndIn(ctx, userID, c.ChatID, c.TopicID)
+	if _, err := r.bot.SendLongMessage(ctx, c.ChatID, c.TopicID, text, nil, entities...); err != nil {
+		r.logDebug("Preview summary error: %v", err)
+	}
+}
@@ -0,0 +1,44 @@
+// Package handler provides message routing and processing functionality.
+package handler
+
+import (
+	"bytes"
+	"context"
+	"image"
+	_ "image/jpeg" // Telegram delivers photos as JPEG
+	_ "image/png"
+)
+
+// DefaultNoQRCodeText is shown when a photo sent to a QR code step contains no QR code.
+const DefaultNoQRCodeText = "No QR code found in the photo. Please try again."
+
+// QRDecoder finds and decodes the QR codes in an image, returning their contents.
+// No decoder is built in, as the library only renders QR codes; wrap one such
+// as gozxing or zbar. Returning no contents rejects the photo.
+type QRDecoder func(img image.Image) ([]string, error)
+
+// QRCodeAnalyzer returns a photo analyzer preset that decodes QR codes with decoder,
+// which is required.
+// The first code's content becomes the analysis text (and so the branch input),
+// all contents are stored as "qr_codes" in conversation data, and photos without
+// a QR code are rejected with noCodeText (DefaultNoQRCodeText if empty).
+func QRCodeAnalyzer(decoder QRDecoder, noCodeText string) PhotoAnalyzer {
+	if noCodeText == "" {
+		noCodeText = DefaultNoQRCodeText
+	}
+	return PhotoAnalyzerFunc(func(_ context.Context, data []byte) (*PhotoAnalysis, error) {
+		img, _, err := image.Decode(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		codes, err := decoder(img)
+		if err != nil || len(codes) == 0 {
+			return nil, RejectPhoto(noCodeText)
+		}
+		return &PhotoAnalysis{
+			Text:   codes[0],
+			Labels: []string{"qr_code"},
+			Data:   map[string]interface{}{"qr_codes": codes},
+		}, nil
+	})
+}
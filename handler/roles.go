@@ -0,0 +1,95 @@
+package handler
+
+import (
+	"context"
+	"slices"
+
+	"github.com/mymmrac/telego"
+
+	"github.com/0xVanfer/tg-listener/config"
+	"github.com/0xVanfer/tg-listener/core"
+)
+
+// RoleProvider returns roles of a user in addition to those assigned in the
+// configuration, e.g. from a database. It is called at most once per update.
+type RoleProvider func(ctx context.Context, userID int64, username string) ([]string, error)
+
+// SetRoleProvider sets the provider for dynamic role lookup. Pass nil to use
+// only the roles assigned in the configuration.
+func (r *Router) SetRoleProvider(provider RoleProvider) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.roleProvider = provider
+}
+
+// Roles returns the roles of a user: those assigned in the configuration plus
+// those of the role provider. Provider errors are logged in debug mode and
+// leave the configured roles only.
+func (r *Router) Roles(ctx context.Context, userID int64, username string) []string {
+	r.mu.RLock()
+	cfg := r.config
+	provider := r.roleProvider
+	r.mu.RUnlock()
+
+	roles := []string{config.RoleUser}
+	if cfg != nil {
+		roles = cfg.UserRoles(userID, username)
+	}
+	if provider == nil {
+		return roles
+	}
+	extra, err := provider(ctx, userID, username)
+	if err != nil {
+		r.logDebug("Role provider error for user %d: %v", userID, err)
+		return roles
+	}
+	for _, role := range extra {
+		if !slices.Contains(roles, role) {
+			roles = append(roles, role)
+		}
+	}
+	return roles
+}
+
+// withRoles tags a context with a lazy lookup of the roles of the update's sender.
+func (r *Router) withRoles(ctx context.Context, update telego.Update) context.Context {
+	from := updateSender(update)
+	if from == nil {
+		return ctx
+	}
+	return core.WithRoles(ctx, from.ID, func() []string {
+		return r.Roles(ctx, from.ID, from.Username)
+	})
+}
+
+// commandAllowed returns true if the user being handled holds a role required
+// by the command's configuration.
+func (r *Router) commandAllowed(ctx context.Context, command string) bool {
+	r.mu.RLock()
+	cfg := r.config
+	r.mu.RUnlock()
+	if cfg == nil || cfg.Bot == nil {
+		return true
+	}
+	cmd := cfg.Bot.GetCommand(command)
+	return cmd == nil || config.HasRole(core.RolesFrom(ctx), cmd.Roles)
+}
+
+// callbackAllowed returns true if the user being handled holds a role required
+// for callback data. See config.Config.CallbackAllowed.
+func (r *Router) callbackAllowed(ctx context.Context, data string) bool {
+	r.mu.RLock()
+	cfg := r.config
+	r.mu.RUnlock()
+	return cfg == nil || cfg.CallbackAllowed(data, core.RolesFrom(ctx))
+}
+
+// forbiddenText returns the reply for users lacking a required role.
+func (r *Router) forbiddenText() string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if r.config == nil || r.config.Bot == nil {
+		return config.DefaultForbiddenText
+	}
+	return r.config.Bot.GetForbiddenText()
+}
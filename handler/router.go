@@ -5,16 +5,23 @@ package handler
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/mymmrac/telego"
 	th "github.com/mymmrac/telego/telegohandler"
 
+	"github.com/0xVanfer/tg-listener/breaker"
 	"github.com/0xVanfer/tg-listener/config"
 	"github.com/0xVanfer/tg-listener/conv"
 	"github.com/0xVanfer/tg-listener/core"
+	"github.com/0xVanfer/tg-listener/eventlog"
+	"github.com/0xVanfer/tg-listener/latency"
+	"github.com/0xVanfer/tg-listener/store"
 )
 
 // CommandHandler is a function type for handling bot commands.
@@ -36,7 +43,8 @@ type PhotoHandler func(ctx context.Context, msg telego.Message) error
 type DocumentHandler func(ctx context.Context, msg telego.Message) error
 
 // Middleware is a function type for request middleware.
-// Middleware can intercept and modify request handling.
+// Middleware can intercept and modify request handling: it may change the
+// context passed to next, skip next to drop the update, or recover from panics in it.
 type Middleware func(next Handler) Handler
 
 // Handler is a generic handler function type for processing updates.
@@ -46,6 +54,21 @@ type Handler func(ctx context.Context, update telego.Update) error
 // Used internally to trigger step display from the wrapper.
 type StepDisplayFunc func(ctx context.Context, c *conv.Conversation) error
 
+// UpdateObserver is notified of every routed update before it is dispatched.
+// Observers cannot block or modify the update; use Middleware for that.
+type UpdateObserver func(ctx context.Context, update telego.Update)
+
+// UsageCheck decides whether a user may run a command now.
+// It returns false and the reply to show when the use is denied (e.g. by a cooldown).
+type UsageCheck func(ctx context.Context, userID int64, command string) (bool, string)
+
+// MaintenanceCheck reports whether updates from a user should be blocked
+// because the bot is in maintenance mode.
+type MaintenanceCheck func(ctx context.Context, userID int64) bool
+
+// DefaultMaintenanceText is the reply shown to users while the bot is in maintenance mode.
+const DefaultMaintenanceText = "🚧 The bot is under maintenance. Please try again later."
+
 // Router handles message routing and dispatching to appropriate handlers.
 // It supports commands, callbacks, messages, middleware, and conversation flows.
 type Router struct {
@@ -54,17 +77,68 @@ type Router struct {
 	convManager *conv.Manager    // Conversation manager
 	flowEngine  *conv.FlowEngine // Flow engine for conversation flows
 
-	commandHandlers  map[string]CommandHandler  // Command handlers by command name
-	callbackHandlers map[string]CallbackHandler // Callback handlers by exact match
-	prefixHandlers   map[string]CallbackHandler // Callback handlers by prefix match
-	messageHandler   MessageHandler             // Default message handler
-	photoHandler     PhotoHandler               // Photo message handler
-	documentHandler  DocumentHandler            // Document message handler
-	middlewares      []Middleware               // Middleware chain
+	commandHandlers    map[string]CommandHandler           // Command handlers by command name
+	callbackHandlers   map[string]CallbackHandler          // Callback handlers by exact match
+	prefixHandlers     map[string]CallbackHandler          // Callback handlers by prefix match
+	configured         ConfiguredRoutes                    // Command and callback handlers built from the configuration
+	patternHandlers    []patternHandler                    // Callback handlers by pattern or expression, in registration order
+	messageHandler     MessageHandler                      // Default message handler
+	photoHandler       PhotoHandler                        // Photo message handler
+	documentHandler    DocumentHandler                     // Document message handler
+	voiceHandler       VoiceHandler                        // Voice message handler
+	contactHandler     ContactHandler                      // Shared contact handler
+	locationHandler    LocationHandler                     // Shared location handler
+	webAppDataHandler  WebAppDataHandler                   // Web App data handler outside web_app steps
+	paymentHandler     PaymentHandler                      // Successful payment handler outside payment steps
+	preCheckoutHandler PreCheckoutHandler                  // Confirms orders before payment
+	shippingHandler    ShippingHandler                     // Answers shipping queries of flexible invoices
+	inlineHandlers     map[string]InlineQueryHandler       // Inline query handlers by query prefix
+	chosenHandler      ChosenInlineResultHandler           // Chosen inline result handler
+	memberHandlers     map[MemberEventKind][]MemberHandler // Membership change handlers by kind
+	middlewares        []Middleware                        // Middleware chain
+	observers          []UpdateObserver                    // Observers notified of every update
 
 	stepDisplayFunc StepDisplayFunc // Function to display step prompts
 	debug           bool            // Enable debug logging
 
+	maintenanceCheck MaintenanceCheck // Reports whether a user is blocked by maintenance mode
+	maintenanceText  string           // Reply shown to users blocked by maintenance mode
+	usageCheck       UsageCheck       // Enforces command cooldowns and quotas
+
+	intentResolver   IntentResolver   // Routes free-text messages outside conversations
+	intentDispatcher IntentDispatcher // Starts flows and shows menus for resolved intents
+	transcriber      Transcriber      // Transcribes voice input for voice steps
+
+	photoAnalyzers map[string]PhotoAnalyzer // Photo analyzers by name
+
+	oneShot        []string             // Callback data prefixes of one-shot buttons set from code
+	signedPrefixes []string             // Callback data prefixes that require a signature
+	consumed       map[string]time.Time // Expiry of consumed one-shot presses by message and data
+	actionMu       sync.Mutex           // Mutex for consumed presses
+
+	bindings store.Store  // Persisted users keyboards are bound to, by message
+	bindMu   sync.RWMutex // Mutex for the binding store
+
+	queued  map[queueKey][]queuedCommand // Commands held until conversations end
+	queueMu sync.Mutex                   // Mutex for held commands
+	exempt  map[string]bool              // Commands run during conversations whatever the policy
+
+	heldErrors map[queueKey]*heldError // Latest validation errors held back by the throttle
+	errorMu    sync.Mutex              // Mutex for held validation errors
+
+	shadowCommands  map[string]CommandHandler  // Shadow handlers by command name
+	shadowCallbacks map[string]CallbackHandler // Shadow handlers by callback data or prefix
+	shadowReporter  ShadowReporter             // Receives shadow reports
+
+	eventRecorder    EventRecorder    // Records router events for postmortems
+	latencyObserver  latency.Observer // Receives handler call durations
+	errorHandler     ErrorHandler     // Receives handler errors and recovered panics
+	roleProvider     RoleProvider     // Looks up roles beyond the configured ones
+	languageResolver LanguageResolver // Looks up the language of users
+	translate        TranslateFunc    // Looks up messages in a language
+	payloadResolver  PayloadResolver  // Resolves tokens standing for long callback data
+	dispatcher       Dispatcher       // Orders the processing of updates
+
 	mu sync.RWMutex // Mutex for thread-safe operations
 }
 
@@ -83,6 +157,10 @@ func NewRouter(bot *core.Bot, cfg *config.Config, convManager *conv.Manager, flo
 		commandHandlers:  make(map[string]CommandHandler),
 		callbackHandlers: make(map[string]CallbackHandler),
 		prefixHandlers:   make(map[string]CallbackHandler),
+		inlineHandlers:   make(map[string]InlineQueryHandler),
+		photoAnalyzers:   make(map[string]PhotoAnalyzer),
+		consumed:         make(map[string]time.Time),
+		bindings:         store.NewMemoryStore(),
 	}
 }
 
@@ -108,6 +186,27 @@ func (r *Router) SetStepDisplayFunc(fn StepDisplayFunc) {
 	r.stepDisplayFunc = fn
 }
 
+// SetMaintenanceCheck sets the function deciding whether a user is blocked by maintenance mode.
+// Blocked users get text as a reply to commands and private messages and as an alert on callbacks.
+// If text is empty, DefaultMaintenanceText is used.
+func (r *Router) SetMaintenanceCheck(fn MaintenanceCheck, text string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if text == "" {
+		text = DefaultMaintenanceText
+	}
+	r.maintenanceCheck = fn
+	r.maintenanceText = text
+}
+
+// SetUsageCheck sets the function enforcing command cooldowns and usage quotas.
+// It runs after authentication and maintenance checks, before the command handler.
+func (r *Router) SetUsageCheck(fn UsageCheck) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.usageCheck = fn
+}
+
 // FlowEngine returns the flow engine instance.
 func (r *Router) FlowEngine() *conv.FlowEngine {
 	return r.flowEngine
@@ -119,13 +218,26 @@ func (r *Router) ConvManager() *conv.Manager {
 }
 
 // Use adds a middleware to the router's middleware chain.
-// Middlewares are executed in the order they are added.
+// The chain wraps the dispatch of every update (commands, callbacks, text,
+// photos, documents, voice, and unrouted update types). Middlewares are executed
+// in the order they are added: the first added is the outermost, so it sees the
+// update first and the result of the rest of the chain last. The chain runs after
+// update observers and before maintenance, authorization, and usage checks.
+// Middlewares added while an update is being handled apply from the next update on.
 func (r *Router) Use(middleware Middleware) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 	r.middlewares = append(r.middlewares, middleware)
 }
 
+// AddUpdateObserver adds an observer notified of every routed update.
+// Observers run synchronously in the order they are added, before dispatch.
+func (r *Router) AddUpdateObserver(observer UpdateObserver) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.observers = append(r.observers, observer)
+}
+
 // RegisterCommand registers a handler for a specific command.
 // The command parameter can be with or without leading slash.
 // Example: RegisterCommand("menu", handler) or RegisterCommand("/menu", handler)
@@ -153,6 +265,29 @@ func (r *Router) RegisterCallbackPrefix(prefix string, handler CallbackHandler)
 	r.prefixHandlers[prefix] = handler
 }
 
+// ConfiguredRoutes are the command and callback handlers built from the
+// configuration's commands and callbacks. They take precedence over handlers
+// registered in code for the same command or callback data.
+type ConfiguredRoutes struct {
+	Commands  map[string]CommandHandler  // Command handlers by command name
+	Callbacks map[string]CallbackHandler // Callback handlers by exact match
+	Prefixes  map[string]CallbackHandler // Callback handlers by prefix match
+}
+
+// SetConfiguredRoutes replaces the handlers built from the configuration as a
+// whole, so commands and callbacks removed from a reloaded configuration stop
+// routing and changed ones take their new action.
+func (r *Router) SetConfiguredRoutes(routes ConfiguredRoutes) {
+	commands := make(map[string]CommandHandler, len(routes.Commands))
+	for command, handler := range routes.Commands {
+		commands[strings.TrimPrefix(command, "/")] = handler
+	}
+	routes.Commands = commands
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.configured = routes
+}
+
 // SetMessageHandler sets the default handler for text messages.
 // This handler is called when no conversation is active and no other handler matches.
 func (r *Router) SetMessageHandler(handler MessageHandler) {
@@ -178,6 +313,31 @@ func (r *Router) SetDocumentHandler(handler DocumentHandler) {
 // SetupHandler configures the telegohandler with routing rules.
 // This method sets up all message, callback, and media handlers.
 func (r *Router) SetupHandler(bh *th.BotHandler) {
+	// Wait for the update's turn, then notify observers before any route runs,
+	// tagging the context with the update, and run the middleware chain around
+	// the dispatch. Panics anywhere below, middlewares included, are recovered
+	// and reported to the error handler
+	bh.Use(func(ctx *th.Context, update telego.Update) error {
+		release, ok := r.acquire(ctx.Context(), update)
+		if !ok {
+			return nil
+		}
+		defer release()
+		ctx = ctx.WithValue(updateIDKey{}, update.UpdateID).WithValue(updateKey{}, update)
+		ctx = ctx.WithContext(r.withStrings(r.withLanguage(r.withRoles(ctx.Context(), update), update)))
+		defer r.recoverPanic(ctx, update)
+		r.recordEvent(ctx, updateEvent(update))
+		r.notifyObservers(ctx, update)
+		dispatch := func(c context.Context, update telego.Update) error {
+			return ctx.WithContext(c).Next(update)
+		}
+		if err := r.chain(dispatch)(ctx, update); err != nil {
+			r.logDebug("Middleware error: %v", err)
+			r.reportError(ctx, err)
+		}
+		return nil
+	})
+
 	// Command handler - matches messages starting with /
 	bh.HandleMessage(func(ctx *th.Context, message telego.Message) error {
 		r.handleCommand(ctx, message)
@@ -194,6 +354,26 @@ func (r *Router) SetupHandler(bh *th.BotHandler) {
 		return nil
 	})
 
+	// Inline query handlers
+	bh.HandleInlineQuery(func(ctx *th.Context, query telego.InlineQuery) error {
+		r.handleInlineQuery(ctx, query)
+		return nil
+	})
+	bh.HandleChosenInlineResult(func(ctx *th.Context, result telego.ChosenInlineResult) error {
+		r.handleChosenInlineResult(ctx, result)
+		return nil
+	})
+
+	// Membership change handlers, of the bot and of other users
+	bh.HandleMyChatMemberUpdated(func(ctx *th.Context, update telego.ChatMemberUpdated) error {
+		r.handleMemberUpdate(ctx, update, true)
+		return nil
+	})
+	bh.HandleChatMemberUpdated(func(ctx *th.Context, update telego.ChatMemberUpdated) error {
+		r.handleMemberUpdate(ctx, update, false)
+		return nil
+	})
+
 	// Photo message handler
 	bh.HandleMessage(func(ctx *th.Context, message telego.Message) error {
 		r.handlePhoto(ctx, message)
@@ -210,6 +390,54 @@ func (r *Router) SetupHandler(bh *th.BotHandler) {
 		return update.Message != nil && update.Message.Document != nil
 	})
 
+	// Voice message handler
+	bh.HandleMessage(func(ctx *th.Context, message telego.Message) error {
+		r.handleVoice(ctx, message)
+		return nil
+	}, func(_ context.Context, update telego.Update) bool {
+		return update.Message != nil && update.Message.Voice != nil
+	})
+
+	// Shared contact handler
+	bh.HandleMessage(func(ctx *th.Context, message telego.Message) error {
+		r.handleContact(ctx, message)
+		return nil
+	}, func(_ context.Context, update telego.Update) bool {
+		return update.Message != nil && update.Message.Contact != nil
+	})
+
+	// Shared location handler
+	bh.HandleMessage(func(ctx *th.Context, message telego.Message) error {
+		r.handleLocation(ctx, message)
+		return nil
+	}, func(_ context.Context, update telego.Update) bool {
+		return update.Message != nil && update.Message.Location != nil
+	})
+
+	// Web App data handler
+	bh.HandleMessage(func(ctx *th.Context, message telego.Message) error {
+		r.handleWebAppData(ctx, message)
+		return nil
+	}, func(_ context.Context, update telego.Update) bool {
+		return update.Message != nil && update.Message.WebAppData != nil
+	})
+
+	// Payment handlers: pre-checkout and shipping queries, and successful payments
+	bh.HandlePreCheckoutQuery(func(ctx *th.Context, query telego.PreCheckoutQuery) error {
+		r.handlePreCheckout(ctx, query)
+		return nil
+	})
+	bh.HandleShippingQuery(func(ctx *th.Context, query telego.ShippingQuery) error {
+		r.handleShippingQuery(ctx, query)
+		return nil
+	})
+	bh.HandleMessage(func(ctx *th.Context, message telego.Message) error {
+		r.handleSuccessfulPayment(ctx, message)
+		return nil
+	}, func(_ context.Context, update telego.Update) bool {
+		return update.Message != nil && update.Message.SuccessfulPayment != nil
+	})
+
 	// Regular text message handler
 	bh.HandleMessage(func(ctx *th.Context, message telego.Message) error {
 		r.handleMessage(ctx, message)
@@ -231,6 +459,54 @@ func (r *Router) logDebug(format string, args ...interface{}) {
 	}
 }
 
+// chain wraps a handler in the registered middlewares, the first added outermost.
+func (r *Router) chain(h Handler) Handler {
+	r.mu.RLock()
+	middlewares := r.middlewares
+	r.mu.RUnlock()
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		h = middlewares[i](h)
+	}
+	return h
+}
+
+// notifyObservers passes an update to all registered observers.
+func (r *Router) notifyObservers(ctx context.Context, update telego.Update) {
+	r.mu.RLock()
+	observers := r.observers
+	r.mu.RUnlock()
+	for _, observer := range observers {
+		observer(ctx, update)
+	}
+}
+
+// inMaintenance returns true if the user is blocked by maintenance mode,
+// along with the text to show them.
+func (r *Router) inMaintenance(ctx context.Context, userID int64) (bool, string) {
+	r.mu.RLock()
+	fn := r.maintenanceCheck
+	text := r.maintenanceText
+	r.mu.RUnlock()
+	if fn == nil || !fn(ctx, userID) {
+		return false, ""
+	}
+	r.logDebug("User %d blocked by maintenance mode", userID)
+	return true, text
+}
+
+// blockMessageInMaintenance checks maintenance mode for a message and replies
+// in private chats. Returns true if the message should not be processed.
+func (r *Router) blockMessageInMaintenance(ctx context.Context, msg telego.Message) bool {
+	blocked, text := r.inMaintenance(ctx, msg.From.ID)
+	if blocked {
+		r.recordMessageEvent(ctx, eventlog.TypeBlocked, msg, "maintenance", nil)
+	}
+	if blocked && msg.Chat.Type == telego.ChatTypePrivate {
+		_, _ = r.bot.SendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, text)
+	}
+	return blocked
+}
+
 // handleCommand processes incoming commands.
 func (r *Router) handleCommand(ctx context.Context, msg telego.Message) {
 	if msg.Text == "" || msg.Text[0] != '/' {
@@ -251,20 +527,61 @@ func (r *Router) handleCommand(ctx context.Context, msg telego.Message) {
 	// Authentication check
 	if !r.bot.CheckAuth(ctx, msg.From.ID, msg.From.Username) {
 		r.logDebug("User %d not authorized", msg.From.ID)
+		r.recordMessageEvent(ctx, eventlog.TypeUnauthorized, msg, "/"+command, nil)
+		return
+	}
+
+	// Maintenance mode check
+	if blocked, text := r.inMaintenance(ctx, msg.From.ID); blocked {
+		r.recordMessageEvent(ctx, eventlog.TypeBlocked, msg, "maintenance", nil)
+		_, _ = r.bot.SendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, text)
+		return
+	}
+
+	// Role check
+	if !r.commandAllowed(ctx, command) {
+		r.logDebug("User %d lacks a role for /%s", msg.From.ID, command)
+		r.recordMessageEvent(ctx, eventlog.TypeUnauthorized, msg, "/"+command, nil)
+		_, _ = r.bot.SendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, r.forbiddenText())
 		return
 	}
 
 	// Look up handler
 	r.mu.RLock()
-	handler, ok := r.commandHandlers[command]
+	handler, ok := r.configured.Commands[command]
+	if !ok {
+		handler, ok = r.commandHandlers[command]
+	}
+	usageCheck := r.usageCheck
 	r.mu.RUnlock()
 
 	if ok {
-		if err := handler(ctx, msg); err != nil {
+		// Commands sent during a conversation follow the command policy
+		if !r.admitCommand(ctx, msg, command) {
+			return
+		}
+
+		// Cooldown and quota check
+		if usageCheck != nil {
+			if allowed, text := usageCheck(ctx, msg.From.ID, command); !allowed {
+				r.logDebug("User %d limited on command /%s", msg.From.ID, command)
+				r.recordMessageEvent(ctx, eventlog.TypeBlocked, msg, "limit /"+command, nil)
+				_, _ = r.bot.SendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, text)
+				return
+			}
+		}
+
+		r.recordMessageEvent(ctx, eventlog.TypeCommand, msg, "/"+command, nil)
+		start := time.Now()
+		err := r.runCommand(ctx, command, handler, msg)
+		r.observeLatency(ctx, "cmd:"+command, start)
+		if err != nil {
 			r.logDebug("Command handler error: %v", err)
+			r.recordMessageEvent(ctx, eventlog.TypeError, msg, "/"+command, err)
 		}
 	} else {
 		r.logDebug("No handler found for command: /%s", command)
+		r.recordMessageEvent(ctx, eventlog.TypeUnhandled, msg, "/"+command, nil)
 	}
 }
 
@@ -272,13 +589,52 @@ func (r *Router) handleCommand(ctx context.Context, msg telego.Message) {
 func (r *Router) handleCallback(ctx context.Context, query telego.CallbackQuery) {
 	// Authentication check
 	if !r.bot.CheckAuth(ctx, query.From.ID, query.From.Username) {
+		r.recordCallbackEvent(ctx, eventlog.TypeUnauthorized, query, query.Data, nil)
 		_ = r.bot.AnswerCallback(ctx, query.ID, "")
 		return
 	}
 
-	data := query.Data
+	// Maintenance mode check
+	if blocked, text := r.inMaintenance(ctx, query.From.ID); blocked {
+		r.recordCallbackEvent(ctx, eventlog.TypeBlocked, query, "maintenance", nil)
+		_ = r.bot.AnswerCallbackWithAlert(ctx, query.ID, text)
+		return
+	}
+
+	// Signature check for signed and sensitive callbacks, and lookup of tokens
+	// standing for long data; handlers receive the original data
+	data, reason, err := r.decodeCallback(ctx, query)
+	if err != nil {
+		r.logDebug("Callback %s error: %v", reason, err)
+		r.recordCallbackEvent(ctx, eventlog.TypeBlocked, query, reason, err)
+		_ = r.bot.AnswerCallbackWithAlert(ctx, query.ID, DefaultInvalidSignatureText)
+		return
+	}
+	query.Data = data
 	r.logDebug("Callback received: %s from user %d", data, query.From.ID)
 
+	// Keyboards bound to another user
+	if r.boundElsewhere(ctx, query) {
+		r.recordCallbackEvent(ctx, eventlog.TypeBlocked, query, "not_your_menu", nil)
+		_ = r.bot.AnswerCallbackWithAlert(ctx, query.ID, r.notYourMenuText())
+		return
+	}
+
+	// Role check for restricted menus, flows, and buttons
+	if !r.callbackAllowed(ctx, data) {
+		r.recordCallbackEvent(ctx, eventlog.TypeUnauthorized, query, data, nil)
+		_ = r.bot.AnswerCallbackWithAlert(ctx, query.ID, r.forbiddenText())
+		return
+	}
+
+	// Double-submit check for one-shot buttons
+	action, ok := r.claimAction(query)
+	if !ok {
+		r.recordCallbackEvent(ctx, eventlog.TypeBlocked, query, "one_shot", nil)
+		_ = r.bot.AnswerCallbackWithAlert(ctx, query.ID, r.oneShotConfig().GetText())
+		return
+	}
+
 	// Handle built-in navigation callbacks
 	switch data {
 	case core.CallbackMainMenu:
@@ -291,42 +647,80 @@ func (r *Router) handleCallback(ctx context.Context, query telego.CallbackQuery)
 
 	// Check for exact match handler
 	r.mu.RLock()
-	handler, ok := r.callbackHandlers[data]
+	handler, ok := r.configured.Callbacks[data]
+	if !ok {
+		handler, ok = r.callbackHandlers[data]
+	}
 	r.mu.RUnlock()
 
 	if ok {
-		if err := handler(ctx, query); err != nil {
+		r.recordCallbackEvent(ctx, eventlog.TypeCallback, query, data, nil)
+		start := time.Now()
+		err := r.runCallback(ctx, data, handler, query)
+		r.observeLatency(ctx, "callback:"+data, start)
+		if err != nil {
 			r.logDebug("Callback handler error: %v", err)
+			r.recordCallbackEvent(ctx, eventlog.TypeError, query, data, err)
+			r.releaseAction(action)
+		}
+		return
+	}
+
+	// Check for pattern and expression handlers
+	if handler, key, params := r.matchPattern(data); handler != nil {
+		ctx = context.WithValue(ctx, callbackParamsKey{}, params)
+		r.recordCallbackEvent(ctx, eventlog.TypeCallback, query, data, nil)
+		start := time.Now()
+		err := r.runCallback(ctx, key, handler, query)
+		r.observeLatency(ctx, "callback:"+key, start)
+		if err != nil {
+			r.logDebug("Pattern callback handler error: %v", err)
+			r.recordCallbackEvent(ctx, eventlog.TypeError, query, data, err)
+			r.releaseAction(action)
 		}
 		return
 	}
 
 	// Check for prefix match handler
+	var matched string
 	r.mu.RLock()
-	for prefix, h := range r.prefixHandlers {
-		if strings.HasPrefix(data, prefix) {
-			handler = h
+	for _, prefixes := range []map[string]CallbackHandler{r.configured.Prefixes, r.prefixHandlers} {
+		for prefix, h := range prefixes {
+			if strings.HasPrefix(data, prefix) {
+				handler = h
+				matched = prefix
+				break
+			}
+		}
+		if handler != nil {
 			break
 		}
 	}
 	r.mu.RUnlock()
 
 	if handler != nil {
-		if err := handler(ctx, query); err != nil {
+		r.recordCallbackEvent(ctx, eventlog.TypeCallback, query, data, nil)
+		start := time.Now()
+		err := r.runCallback(ctx, matched, handler, query)
+		r.observeLatency(ctx, "callback:"+matched, start)
+		if err != nil {
 			r.logDebug("Prefix callback handler error: %v", err)
+			r.recordCallbackEvent(ctx, eventlog.TypeError, query, data, err)
+			r.releaseAction(action)
 		}
 		return
 	}
 
 	// Check if user is in a conversation
 	chatID := query.Message.GetChat().ID
-	c := r.convManager.Get(query.From.ID, chatID)
+	c := r.convManager.GetIn(query.From.ID, chatID, core.GetTopicID(query.Message))
 	if c != nil {
 		r.handleConversationCallback(ctx, query, c)
 		return
 	}
 
 	// No matching handler found - answer callback to prevent loading indicator
+	r.recordCallbackEvent(ctx, eventlog.TypeUnhandled, query, data, nil)
 	_ = r.bot.AnswerCallback(ctx, query.ID, "")
 }
 
@@ -338,28 +732,45 @@ func (r *Router) handleMessage(ctx context.Context, msg telego.Message) {
 
 	// Authentication check
 	if !r.bot.CheckAuth(ctx, msg.From.ID, msg.From.Username) {
+		r.recordMessageEvent(ctx, eventlog.TypeUnauthorized, msg, "", nil)
+		return
+	}
+
+	// Maintenance mode check
+	if r.blockMessageInMaintenance(ctx, msg) {
 		return
 	}
 
 	r.logDebug("Message received from user %d: %s", msg.From.ID, truncateString(msg.Text, 50))
 
 	// Check if user is in a conversation
-	c := r.convManager.Get(msg.From.ID, msg.Chat.ID)
+	c := r.convManager.GetIn(msg.From.ID, msg.Chat.ID, msg.MessageThreadID)
 	if c != nil {
 		r.handleConversationMessage(ctx, msg, c)
 		return
 	}
 
+	// Route free text by intent
+	if r.routeIntent(ctx, msg) {
+		return
+	}
+
 	// Use default message handler
 	r.mu.RLock()
 	handler := r.messageHandler
 	r.mu.RUnlock()
 
 	if handler != nil {
-		if err := handler(ctx, msg); err != nil {
+		start := time.Now()
+		err := handler(ctx, msg)
+		r.observeLatency(ctx, "message", start)
+		if err != nil {
 			r.logDebug("Message handler error: %v", err)
+			r.recordMessageEvent(ctx, eventlog.TypeError, msg, "message", err)
 		}
+		return
 	}
+	r.recordMessageEvent(ctx, eventlog.TypeUnhandled, msg, "message", nil)
 }
 
 // handlePhoto processes photo messages.
@@ -370,13 +781,19 @@ func (r *Router) handlePhoto(ctx context.Context, msg telego.Message) {
 
 	// Authentication check
 	if !r.bot.CheckAuth(ctx, msg.From.ID, msg.From.Username) {
+		r.recordMessageEvent(ctx, eventlog.TypeUnauthorized, msg, "", nil)
+		return
+	}
+
+	// Maintenance mode check
+	if r.blockMessageInMaintenance(ctx, msg) {
 		return
 	}
 
 	r.logDebug("Photo received from user %d", msg.From.ID)
 
 	// Check if user is in a conversation expecting photo input
-	c := r.convManager.Get(msg.From.ID, msg.Chat.ID)
+	c := r.convManager.GetIn(msg.From.ID, msg.Chat.ID, msg.MessageThreadID)
 	if c != nil {
 		step := r.flowEngine.GetStep(c.FlowID, c.StepID)
 		if step != nil && step.InputType == config.InputTypePhoto {
@@ -391,10 +808,16 @@ func (r *Router) handlePhoto(ctx context.Context, msg telego.Message) {
 	r.mu.RUnlock()
 
 	if handler != nil {
-		if err := handler(ctx, msg); err != nil {
+		start := time.Now()
+		err := handler(ctx, msg)
+		r.observeLatency(ctx, "photo", start)
+		if err != nil {
 			r.logDebug("Photo handler error: %v", err)
+			r.recordMessageEvent(ctx, eventlog.TypeError, msg, "photo", err)
 		}
+		return
 	}
+	r.recordMessageEvent(ctx, eventlog.TypeUnhandled, msg, "photo", nil)
 }
 
 // handleDocument processes document messages.
@@ -405,13 +828,19 @@ func (r *Router) handleDocument(ctx context.Context, msg telego.Message) {
 
 	// Authentication check
 	if !r.bot.CheckAuth(ctx, msg.From.ID, msg.From.Username) {
+		r.recordMessageEvent(ctx, eventlog.TypeUnauthorized, msg, "", nil)
+		return
+	}
+
+	// Maintenance mode check
+	if r.blockMessageInMaintenance(ctx, msg) {
 		return
 	}
 
 	r.logDebug("Document received from user %d: %s", msg.From.ID, msg.Document.FileName)
 
 	// Check if user is in a conversation expecting document input
-	c := r.convManager.Get(msg.From.ID, msg.Chat.ID)
+	c := r.convManager.GetIn(msg.From.ID, msg.Chat.ID, msg.MessageThreadID)
 	if c != nil {
 		step := r.flowEngine.GetStep(c.FlowID, c.StepID)
 		if step != nil && step.InputType == config.InputTypeDocument {
@@ -426,10 +855,16 @@ func (r *Router) handleDocument(ctx context.Context, msg telego.Message) {
 	r.mu.RUnlock()
 
 	if handler != nil {
-		if err := handler(ctx, msg); err != nil {
+		start := time.Now()
+		err := handler(ctx, msg)
+		r.observeLatency(ctx, "document", start)
+		if err != nil {
 			r.logDebug("Document handler error: %v", err)
+			r.recordMessageEvent(ctx, eventlog.TypeError, msg, "document", err)
 		}
+		return
 	}
+	r.recordMessageEvent(ctx, eventlog.TypeUnhandled, msg, "document", nil)
 }
 
 // handleMainMenu handles returning to the main menu.
@@ -439,7 +874,7 @@ func (r *Router) handleMainMenu(ctx context.Context, query telego.CallbackQuery)
 	chatID := query.Message.GetChat().ID
 
 	// End current conversation if any
-	r.convManager.End(ctx, query.From.ID, chatID)
+	r.convManager.EndIn(ctx, query.From.ID, chatID, core.GetTopicID(query.Message))
 
 	// Trigger internal main menu handler
 	r.mu.RLock()
@@ -456,18 +891,29 @@ func (r *Router) handleBack(ctx context.Context, query telego.CallbackQuery) {
 	_ = r.bot.AnswerCallback(ctx, query.ID, "")
 
 	chatID := query.Message.GetChat().ID
-	c := r.convManager.Get(query.From.ID, chatID)
+	c := r.convManager.GetIn(query.From.ID, chatID, core.GetTopicID(query.Message))
 
 	if c != nil {
+		// Edit the message whose back button was pressed, which may be an
+		// earlier message of the conversation than the last keyboard message
+		if msgID := query.Message.GetMessageID(); msgID != c.KeyboardMsgID && c.OwnsMessage(msgID) {
+			c.SetKeyboardMsgID(msgID)
+		}
+
 		// Get previous step from history
 		prevStep := c.GetPreviousStep()
+
+		// Going back past the first step of a sub-flow leaves it without its data
+		if prevStep != "" && r.flowEngine.GetStep(c.FlowID, prevStep) == nil {
+			c.ReturnFromFlow(false)
+		}
 		if prevStep != "" {
 			// Go back to previous step
-			r.convManager.ChangeStep(ctx, query.From.ID, chatID, prevStep)
+			r.convManager.ChangeStepIn(ctx, query.From.ID, chatID, c.TopicID, prevStep)
 			r.displayStep(ctx, c)
 		} else {
 			// No previous step - end conversation and return to main menu
-			r.convManager.End(ctx, query.From.ID, chatID)
+			r.convManager.EndIn(ctx, query.From.ID, chatID, core.GetTopicID(query.Message))
 			r.handleMainMenu(ctx, query)
 		}
 	} else {
@@ -478,6 +924,8 @@ func (r *Router) handleBack(ctx context.Context, query telego.CallbackQuery) {
 
 // handleConversationCallback handles callback queries during a conversation.
 func (r *Router) handleConversationCallback(ctx context.Context, query telego.CallbackQuery, c *conv.Conversation) {
+	defer r.saveConversation(ctx, c)
+
 	_ = r.bot.AnswerCallback(ctx, query.ID, "")
 
 	step := r.flowEngine.GetStep(c.FlowID, c.StepID)
@@ -491,37 +939,224 @@ func (r *Router) handleConversationCallback(ctx context.Context, query telego.Ca
 		return
 	}
 
+	r.clearValidationError(ctx, c)
+
 	// Store input data
 	if step.StoreAs != "" {
-		c.Set(step.StoreAs, query.Data)
+		if err := r.flowEngine.StoreInput(ctx, c, step.StoreAs, query.Data); err != nil {
+			r.logDebug("Store input error: %v", err)
+		}
 	}
 	c.AddHistory(c.StepID, query.Data)
 
-	// Execute completion handler if specified
-	if step.OnComplete != "" {
-		if err := r.flowEngine.ExecuteStepHandler(ctx, c, step.OnComplete); err != nil {
-			r.logDebug("Step handler error: %v", err)
+	r.completeStep(ctx, c, step, query.From.ID, query.Data)
+}
+
+// reportValidationError shows a validation error for invalid input, either as a
+// reply or inline on the step prompt depending on the configured error display.
+// Repeated errors edit a single message with a repeat counter instead of
+// sending a new message each time. Bursts within the throttle interval are
+// collapsed, and the latest error of a burst is shown once the interval has passed.
+func (r *Router) reportValidationError(ctx context.Context, msg telego.Message, c *conv.Conversation, err error) {
+	throttle := time.Second
+	r.mu.RLock()
+	if r.config != nil && r.config.Bot != nil {
+		throttle = r.config.Bot.GetErrorThrottle()
+	}
+	r.mu.RUnlock()
+	display := r.errorDisplay(c)
+
+	count, wait := c.RecordInvalidInput(throttle)
+	r.recordConvEvent(ctx, eventlog.TypeValidation, c, "", err)
+	if display == config.ErrorDisplayInline {
+		// The invalid input is answered on the prompt itself, so remove it from the chat
+		_ = r.bot.DeleteMessage(ctx, msg.Chat.ID, msg.MessageID)
+	}
+	if wait > 0 {
+		r.logDebug("Validation error throttled for user %d (%d in a row)", msg.From.ID, count)
+		r.holdValidationError(ctx, msg, c, err, wait)
+		return
+	}
+	r.releaseValidationError(c)
+	r.showValidationError(ctx, msg, c, err, count)
+}
+
+// heldError is the latest validation error of a conversation held back by the
+// throttle, shown once the throttle interval has passed.
+type heldError struct {
+	msg    telego.Message // Latest invalid input
+	err    error          // Latest validation error
+	flowID string         // Flow the error belongs to
+	stepID string         // Step the error belongs to
+}
+
+// holdValidationError remembers the latest validation error of a throttled
+// conversation and shows it after wait, unless the input was corrected or the
+// step left in the meantime.
+func (r *Router) holdValidationError(ctx context.Context, msg telego.Message, c *conv.Conversation, err error, wait time.Duration) {
+	key := queueKey{userID: c.UserID, chatID: c.ChatID, topicID: c.TopicID}
+	held := &heldError{msg: msg, err: err, flowID: c.FlowID, stepID: c.StepID}
+
+	r.errorMu.Lock()
+	defer r.errorMu.Unlock()
+	if r.heldErrors == nil {
+		r.heldErrors = make(map[queueKey]*heldError)
+	}
+	_, pending := r.heldErrors[key]
+	r.heldErrors[key] = held
+	if pending {
+		return
+	}
+	ctx = context.WithoutCancel(ctx)
+	time.AfterFunc(wait, func() { r.showHeldError(ctx, key) })
+}
+
+// releaseValidationError drops the held validation error of a conversation,
+// as a newer error is shown right away.
+func (r *Router) releaseValidationError(c *conv.Conversation) {
+	r.errorMu.Lock()
+	defer r.errorMu.Unlock()
+	delete(r.heldErrors, queueKey{userID: c.UserID, chatID: c.ChatID, topicID: c.TopicID})
+}
+
+// showHeldError shows the validation error held for a conversation.
+func (r *Router) showHeldError(ctx context.Context, key queueKey) {
+	r.errorMu.Lock()
+	held := r.heldErrors[key]
+	delete(r.heldErrors, key)
+	r.errorMu.Unlock()
+	if held == nil {
+		return
+	}
+
+	c := r.convManager.GetIn(key.userID, key.chatID, key.topicID)
+	if c == nil || c.FlowID != held.flowID || c.StepID != held.stepID || c.GetInvalidInputs() == 0 {
+		return
+	}
+	defer r.saveConversation(ctx, c)
+	r.showValidationError(ctx, held.msg, c, held.err, c.RefreshInvalidInput())
+}
+
+// showValidationError updates the error display of a conversation with a
+// validation error and the number of invalid inputs in a row.
+func (r *Router) showValidationError(ctx context.Context, msg telego.Message, c *conv.Conversation, err error, count int) {
+	r.mu.RLock()
+	displayStep := r.stepDisplayFunc
+	r.mu.RUnlock()
+	display := r.errorDisplay(c)
+
+	text := "❌ " + err.Error()
+	if count > 1 {
+		text += fmt.Sprintf(" (×%d)", count)
+	}
+
+	// Inline mode: re-render the prompt with the error appended
+	if display == config.ErrorDisplayInline && displayStep != nil {
+		c.SetErrorText(text)
+		if err := displayStep(ctx, c); err != nil {
+			r.logDebug("Step display error: %v", err)
 		}
 		return
 	}
 
-	// Determine and transition to next step
-	nextStep := r.flowEngine.DetermineNextStep(ctx, c, query.Data)
-	if nextStep != "" {
-		r.convManager.ChangeStep(ctx, query.From.ID, c.ChatID, nextStep)
-		r.displayStep(ctx, c)
+	if c.ErrorMsgID > 0 {
+		if _, err := r.bot.EditMessage(ctx, msg.Chat.ID, c.ErrorMsgID, text); err == nil {
+			return
+		}
+	}
+	sent, err := r.bot.SendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, text)
+	if err == nil && sent != nil {
+		c.SetErrorMsgID(sent.MessageID)
+	}
+
+	// Switch the prompt to its retry variant
+	if step := r.flowEngine.GetStep(c.FlowID, c.StepID); step != nil && step.PromptVariants.HasRetry() && displayStep != nil {
+		if err := displayStep(ctx, c); err != nil {
+			r.logDebug("Step display error: %v", err)
+		}
+	}
+}
+
+// errorDisplay returns how validation errors are shown on the conversation's
+// current step: the step's error_display, or the bot's.
+func (r *Router) errorDisplay(c *conv.Conversation) string {
+	if step := r.flowEngine.GetStep(c.FlowID, c.StepID); step != nil && step.Validation != nil && step.Validation.ErrorDisplay != "" {
+		return step.Validation.ErrorDisplay
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if r.config != nil && r.config.Bot != nil && r.config.Bot.ErrorDisplay != "" {
+		return r.config.Bot.ErrorDisplay
+	}
+	return config.ErrorDisplayReply
+}
+
+// failValidation reports invalid input and applies the step's failure
+// branches: on_max_attempts once validation.max_attempts invalid inputs were
+// received in a row, otherwise on_validation_fail. Reaching the limit without
+// on_max_attempts cancels the conversation.
+func (r *Router) failValidation(ctx context.Context, msg telego.Message, c *conv.Conversation, err error) {
+	r.reportValidationError(ctx, msg, c, err)
+
+	step := r.flowEngine.GetStep(c.FlowID, c.StepID)
+	if step == nil {
+		return
+	}
+
+	if step.Validation != nil && step.Validation.MaxAttempts > 0 && c.GetInvalidInputs() >= step.Validation.MaxAttempts {
+		r.logDebug("Max attempts reached on step %s for user %d", c.StepID, msg.From.ID)
+		r.recordConvEvent(ctx, eventlog.TypeValidation, c, "max_attempts", nil)
+		if step.OnMaxAttempts == nil {
+			c.Cancel()
+			r.convManager.EndIn(ctx, msg.From.ID, c.ChatID, c.TopicID)
+			return
+		}
+		r.followValidationFail(ctx, c, step.OnMaxAttempts, msg.From.ID)
+		return
+	}
+
+	if step.OnValidationFail != nil {
+		r.followValidationFail(ctx, c, step.OnValidationFail, msg.From.ID)
+	}
+}
+
+// followValidationFail runs a validation failure branch: its handler, then the
+// transition to its next step.
+func (r *Router) followValidationFail(ctx context.Context, c *conv.Conversation, fail *config.ValidationFailConfig, userID int64) {
+	if fail.Handler != "" {
+		if err := r.flowEngine.ExecuteStepHandler(ctx, c, fail.Handler); err != nil {
+			r.logDebug("Validation failure handler error: %v", err)
+			r.recordConvEvent(ctx, eventlog.TypeError, c, fail.Handler, err)
+			r.reportUnavailable(ctx, c, err)
+			return
+		}
+	}
+	if fail.NextStep == "" {
+		return
+	}
+	r.clearValidationError(ctx, c)
+	r.convManager.ChangeStepIn(ctx, userID, c.ChatID, c.TopicID, fail.NextStep)
+	r.displayStep(ctx, c)
+}
+
+// clearValidationError removes the validation error message after valid input.
+func (r *Router) clearValidationError(ctx context.Context, c *conv.Conversation) {
+	if msgID := c.ClearInvalidInput(); msgID > 0 {
+		_ = r.bot.DeleteMessage(ctx, c.ChatID, msgID)
 	}
 }
 
 // handleConversationMessage handles text messages during a conversation.
 func (r *Router) handleConversationMessage(ctx context.Context, msg telego.Message, c *conv.Conversation) {
+	defer r.saveConversation(ctx, c)
+
 	step := r.flowEngine.GetStep(c.FlowID, c.StepID)
 	if step == nil {
 		return
 	}
 
 	// Verify step accepts text input
-	if step.InputType != config.InputTypeText && step.InputType != config.InputTypeAny {
+	if step.InputType != config.InputTypeText && step.InputType != config.InputTypeAny && step.InputType != config.InputTypeLLM {
 		r.logDebug("Step %s does not accept text input", c.StepID)
 		return
 	}
@@ -529,38 +1164,56 @@ func (r *Router) handleConversationMessage(ctx context.Context, msg telego.Messa
 	input := msg.Text
 
 	// Validate input if validation is configured
-	if err := r.flowEngine.ValidateInput(c, input); err != nil {
-		_, _ = r.bot.SendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "❌ "+err.Error())
+	if err := r.flowEngine.ValidateInput(ctx, c, input); err != nil {
+		r.failValidation(ctx, msg, c, err)
 		return
 	}
 
-	// Store input data
+	// Normalize input with the step's transforms
+	value, err := r.flowEngine.TransformInput(c, input)
+	if err != nil {
+		r.failValidation(ctx, msg, c, err)
+		return
+	}
+	if s, ok := value.(string); ok {
+		input = s
+	}
+	r.clearValidationError(ctx, c)
+
+	// Store input data, keeping formatting entities alongside the text
 	if step.StoreAs != "" {
-		c.Set(step.StoreAs, input)
+		if err := r.flowEngine.StoreInput(ctx, c, step.StoreAs, value); err != nil {
+			// Input that doesn't convert to the type declared in the flow's schema
+			if errors.Is(err, conv.ErrTypeMismatch) {
+				r.failValidation(ctx, msg, c, err)
+				return
+			}
+			r.logDebug("Store input error: %v", err)
+		}
+		if len(msg.Entities) > 0 {
+			c.Set(step.StoreAs+"_entities", msg.Entities)
+		} else if _, ok := c.Get(step.StoreAs + "_entities"); ok {
+			// Drop entities left over from an earlier answer to this step
+			c.Set(step.StoreAs+"_entities", nil)
+		}
 	}
 	c.AddHistory(c.StepID, input)
 
 	// Delete user message to keep chat clean (optional behavior)
 	_ = r.bot.DeleteMessage(ctx, msg.Chat.ID, msg.MessageID)
 
-	// Execute completion handler if specified
-	if step.OnComplete != "" {
-		if err := r.flowEngine.ExecuteStepHandler(ctx, c, step.OnComplete); err != nil {
-			r.logDebug("Step handler error: %v", err)
-		}
+	// LLM steps only complete once the completer is done
+	if step.InputType == config.InputTypeLLM && !r.runCompletion(ctx, msg, c, input) {
 		return
 	}
 
-	// Determine and transition to next step
-	nextStep := r.flowEngine.DetermineNextStep(ctx, c, input)
-	if nextStep != "" {
-		r.convManager.ChangeStep(ctx, msg.From.ID, c.ChatID, nextStep)
-		r.displayStep(ctx, c)
-	}
+	r.completeStep(ctx, c, step, msg.From.ID, input)
 }
 
 // handleConversationPhoto handles photo messages during a conversation.
 func (r *Router) handleConversationPhoto(ctx context.Context, msg telego.Message, c *conv.Conversation) {
+	defer r.saveConversation(ctx, c)
+
 	step := r.flowEngine.GetStep(c.FlowID, c.StepID)
 	if step == nil {
 		return
@@ -572,57 +1225,198 @@ func (r *Router) handleConversationPhoto(ctx context.Context, msg telego.Message
 	}
 	photo := msg.Photo[len(msg.Photo)-1]
 
-	// Store file ID
+	// Check the photo against the step's file limits
+	file := conv.FileInput{Size: int64(photo.FileSize), Width: photo.Width, Height: photo.Height}
+	if err := r.flowEngine.ValidateFile(ctx, c, file); err != nil {
+		r.failValidation(ctx, msg, c, err)
+		return
+	}
+	r.clearValidationError(ctx, c)
+
+	// Analyze the photo if the step names an analyzer
+	input := photo.FileID
+	var analysis *PhotoAnalysis
+	if step.Analyzer != "" {
+		result, err := r.analyzePhoto(ctx, step.Analyzer, photo.FileID)
+		if err != nil {
+			r.failValidation(ctx, msg, c, err)
+			return
+		}
+		r.clearValidationError(ctx, c)
+		analysis = result
+		if analysis.Text != "" {
+			input = analysis.Text
+		}
+	}
+
+	// Store file ID, photo details, and analysis results
 	if step.StoreAs != "" {
 		c.Set(step.StoreAs, photo.FileID)
 		c.Set(step.StoreAs+"_file_id", photo.FileID)
+		c.Set(step.StoreAs+"_file_size", photo.FileSize)
+		c.Set(step.StoreAs+"_width", photo.Width)
+		c.Set(step.StoreAs+"_height", photo.Height)
+		c.Set(step.StoreAs+"_sizes", msg.Photo)
+		storeCaption(c, step.StoreAs, msg)
+		if analysis != nil {
+			c.Set(step.StoreAs+"_text", analysis.Text)
+			c.Set(step.StoreAs+"_labels", analysis.Labels)
+		}
 	}
-	c.AddHistory(c.StepID, "photo:"+photo.FileID)
-
-	// Execute completion handler if specified
-	if step.OnComplete != "" {
-		if err := r.flowEngine.ExecuteStepHandler(ctx, c, step.OnComplete); err != nil {
-			r.logDebug("Step handler error: %v", err)
+	if analysis != nil {
+		for k, v := range analysis.Data {
+			if err := r.flowEngine.StoreInput(ctx, c, k, v); err != nil {
+				r.logDebug("Store input error: %v", err)
+			}
 		}
-		return
 	}
+	c.AddHistory(c.StepID, "photo:"+photo.FileID)
 
-	// Determine and transition to next step
-	nextStep := r.flowEngine.DetermineNextStep(ctx, c, photo.FileID)
-	if nextStep != "" {
-		r.convManager.ChangeStep(ctx, msg.From.ID, c.ChatID, nextStep)
-		r.displayStep(ctx, c)
-	}
+	r.completeStep(ctx, c, step, msg.From.ID, input)
 }
 
 // handleConversationDocument handles document messages during a conversation.
 func (r *Router) handleConversationDocument(ctx context.Context, msg telego.Message, c *conv.Conversation) {
+	defer r.saveConversation(ctx, c)
+
 	step := r.flowEngine.GetStep(c.FlowID, c.StepID)
 	if step == nil || msg.Document == nil {
 		return
 	}
 
+	// Check the document against the step's file limits
+	doc := msg.Document
+	if err := r.flowEngine.ValidateFile(ctx, c, conv.FileInput{Size: doc.FileSize, MIMEType: doc.MimeType}); err != nil {
+		r.failValidation(ctx, msg, c, err)
+		return
+	}
+	r.clearValidationError(ctx, c)
+
 	// Store file info
 	if step.StoreAs != "" {
-		c.Set(step.StoreAs, msg.Document.FileID)
-		c.Set(step.StoreAs+"_file_id", msg.Document.FileID)
-		c.Set(step.StoreAs+"_file_name", msg.Document.FileName)
+		c.Set(step.StoreAs, doc.FileID)
+		c.Set(step.StoreAs+"_file_id", doc.FileID)
+		c.Set(step.StoreAs+"_file_name", doc.FileName)
+		c.Set(step.StoreAs+"_file_size", doc.FileSize)
+		c.Set(step.StoreAs+"_mime_type", doc.MimeType)
+		storeCaption(c, step.StoreAs, msg)
+	}
+	c.AddHistory(c.StepID, "doc:"+doc.FileID)
+
+	r.completeStep(ctx, c, step, msg.From.ID, doc.FileID)
+}
+
+// storeCaption stores the caption of a media message, and its formatting
+// entities, under a step's key.
+func storeCaption(c *conv.Conversation, storeAs string, msg telego.Message) {
+	c.Set(storeAs+"_caption", msg.Caption)
+	if len(msg.CaptionEntities) > 0 {
+		c.Set(storeAs+"_caption_entities", msg.CaptionEntities)
+	} else if _, ok := c.Get(storeAs + "_caption_entities"); ok {
+		// Drop entities left over from an earlier answer to this step
+		c.Set(storeAs+"_caption_entities", nil)
+	}
+}
+
+// completeStep finishes the current step after its input has been stored:
+// computed fields are written, then the completion handler runs or the
+// conversation follows the matching branch (running its handler) or the default next step.
+func (r *Router) completeStep(ctx context.Context, c *conv.Conversation, step *config.StepConfig, userID int64, input string) {
+	r.recordConvEvent(ctx, eventlog.TypeStepCompleted, c, "", nil)
+
+	// Write computed fields into conversation data
+	if err := r.flowEngine.ApplyComputed(ctx, c); err != nil {
+		r.logDebug("Computed field error: %v", err)
+		r.recordConvEvent(ctx, eventlog.TypeError, c, "computed", err)
 	}
-	c.AddHistory(c.StepID, "doc:"+msg.Document.FileID)
 
-	// Execute completion handler if specified
+	// Execute completion handler if specified. A handler that leaves the last
+	// step of a sub-flow in place returns to the calling flow
 	if step.OnComplete != "" {
+		if step.NextStep == "" && !r.checkFlow(ctx, c, userID) {
+			return
+		}
+		stepID := c.StepID
 		if err := r.flowEngine.ExecuteStepHandler(ctx, c, step.OnComplete); err != nil {
 			r.logDebug("Step handler error: %v", err)
+			r.recordConvEvent(ctx, eventlog.TypeError, c, step.OnComplete, err)
+			r.reportUnavailable(ctx, c, err)
+			return
+		}
+		if step.NextStep == "" && c.StepID == stepID && r.convManager.GetIn(userID, c.ChatID, c.TopicID) != nil {
+			r.returnFromSubFlow(ctx, c, userID)
 		}
 		return
 	}
 
-	// Determine and transition to next step
-	nextStep := r.flowEngine.DetermineNextStep(ctx, c, msg.Document.FileID)
+	// Determine the next step, running the matched branch's handler first.
+	// Steps leading nowhere end the flow, so its checks run before
+	nextStep := step.NextStep
+	branch := r.flowEngine.MatchBranch(ctx, c, input)
+	if branch != nil {
+		nextStep = branch.NextStep
+	}
+	if nextStep == "" && !r.checkFlow(ctx, c, userID) {
+		return
+	}
+	if branch != nil && branch.Handler != "" {
+		if err := r.flowEngine.ExecuteStepHandler(ctx, c, branch.Handler); err != nil {
+			r.logDebug("Branch handler error: %v", err)
+			r.recordConvEvent(ctx, eventlog.TypeError, c, branch.Handler, err)
+			r.reportUnavailable(ctx, c, err)
+			return
+		}
+		if branch.NextStep == "" {
+			return
+		}
+	}
 	if nextStep != "" {
-		r.convManager.ChangeStep(ctx, msg.From.ID, c.ChatID, nextStep)
+		r.convManager.ChangeStepIn(ctx, userID, c.ChatID, c.TopicID, nextStep)
 		r.displayStep(ctx, c)
+		return
+	}
+	r.returnFromSubFlow(ctx, c, userID)
+}
+
+// returnFromSubFlow continues the calling flow after the last step of a
+// sub-flow completed: the step that called the sub-flow completes in turn.
+// Outside sub-flows, it finishes previews and does nothing otherwise.
+func (r *Router) returnFromSubFlow(ctx context.Context, c *conv.Conversation, userID int64) {
+	frame, ok := c.ReturnFromFlow(true)
+	if !ok {
+		if c.IsPreview() {
+			r.finishPreview(ctx, c, userID)
+		}
+		return
+	}
+	r.logDebug("Returned from sub-flow to %s/%s for user %d", frame.FlowID, frame.StepID, userID)
+
+	step := r.flowEngine.GetStep(c.FlowID, c.StepID)
+	if step == nil {
+		return
+	}
+	r.completeStep(ctx, c, step, userID, "")
+}
+
+// reportUnavailable apologizes to the user when a step handler timed out or
+// was skipped by its open circuit breaker.
+func (r *Router) reportUnavailable(ctx context.Context, c *conv.Conversation, err error) {
+	if !errors.Is(err, conv.ErrHandlerTimeout) && !errors.Is(err, breaker.ErrOpen) {
+		return
+	}
+	var timeouts *config.TimeoutConfig
+	r.mu.RLock()
+	if r.config != nil && r.config.Bot != nil {
+		timeouts = r.config.Bot.Timeouts
+	}
+	r.mu.RUnlock()
+	_, _ = r.bot.SendMessage(ctx, c.ChatID, c.TopicID, timeouts.GetText())
+}
+
+// saveConversation persists a conversation after its update was handled.
+func (r *Router) saveConversation(ctx context.Context, c *conv.Conversation) {
+	if err := r.convManager.Save(ctx, c); err != nil {
+		r.logDebug("Conversation save error: %v", err)
 	}
 }
 
@@ -635,6 +1429,7 @@ func (r *Router) displayStep(ctx context.Context, c *conv.Conversation) {
 	if fn != nil {
 		if err := fn(ctx, c); err != nil {
 			r.logDebug("Step display error: %v", err)
+			r.recordConvEvent(ctx, eventlog.TypeError, c, "display", err)
 		}
 	}
 }
@@ -0,0 +1,208 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/mymmrac/telego"
+
+	"github.com/0xVanfer/tg-listener/core"
+)
+
+// maxShadowValue is the length beyond which differing values are shortened
+// in shadow reports.
+const maxShadowValue = 80
+
+// ShadowReport compares the requests made by a handler and its shadow for
+// one update.
+type ShadowReport struct {
+	Handler    string         // Handler name, e.g. "cmd:order" or "callback:item:"
+	UpdateID   int            // Update both handlers received
+	UserID     int64          // User who sent the update
+	Primary    []core.APICall // Requests of the primary handler, sent to Telegram
+	Shadow     []core.APICall // Requests of the shadow handler, captured
+	PrimaryErr error          // Error returned by the primary handler
+	ShadowErr  error          // Error returned by the shadow handler, or its recovered panic
+	Diff       []string       // Differences between the requests, one per line
+}
+
+// Matches reports whether the shadow made the same requests as the primary
+// handler and failed only if it did.
+func (r ShadowReport) Matches() bool {
+	return len(r.Diff) == 0 && (r.ShadowErr == nil) == (r.PrimaryErr == nil)
+}
+
+// ShadowReporter receives the report of every update run through a shadow handler.
+type ShadowReporter func(ctx context.Context, report ShadowReport)
+
+// RegisterShadowCommand registers a shadow handler for a command. It receives
+// the same messages as the command's handler, after it, but its requests are
+// captured instead of sent, and compared with the handler's in a ShadowReport.
+// Shadow handlers must not change state the handler relies on.
+func (r *Router) RegisterShadowCommand(command string, handler CommandHandler) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.shadowCommands == nil {
+		r.shadowCommands = make(map[string]CommandHandler)
+	}
+	r.shadowCommands[strings.TrimPrefix(command, "/")] = handler
+}
+
+// RegisterShadowCallback registers a shadow handler for the callback handler
+// registered with the same data or prefix, see RegisterShadowCommand.
+func (r *Router) RegisterShadowCallback(callback string, handler CallbackHandler) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.shadowCallbacks == nil {
+		r.shadowCallbacks = make(map[string]CallbackHandler)
+	}
+	r.shadowCallbacks[callback] = handler
+}
+
+// SetShadowReporter sets the function receiving shadow reports. Without one,
+// reports that don't match are logged.
+func (r *Router) SetShadowReporter(fn ShadowReporter) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.shadowReporter = fn
+}
+
+// runCommand runs a command handler, and its shadow if one is registered.
+func (r *Router) runCommand(ctx context.Context, command string, handler CommandHandler, msg telego.Message) error {
+	r.mu.RLock()
+	shadow := r.shadowCommands[command]
+	r.mu.RUnlock()
+	if shadow == nil {
+		return handler(ctx, msg)
+	}
+	return r.runShadowed(ctx, "cmd:"+command, msg.From.ID,
+		func(ctx context.Context) error { return handler(ctx, msg) },
+		func(ctx context.Context) error { return shadow(ctx, msg) })
+}
+
+// runCallback runs a callback handler registered for key, and its shadow if
+// one is registered.
+func (r *Router) runCallback(ctx context.Context, key string, handler CallbackHandler, query telego.CallbackQuery) error {
+	r.mu.RLock()
+	shadow := r.shadowCallbacks[key]
+	r.mu.RUnlock()
+	if shadow == nil {
+		return handler(ctx, query)
+	}
+	return r.runShadowed(ctx, "callback:"+key, query.From.ID,
+		func(ctx context.Context) error { return handler(ctx, query) },
+		func(ctx context.Context) error { return shadow(ctx, query) })
+}
+
+// runShadowed runs a handler while recording its requests, then runs its
+// shadow in the background with captured requests and reports the comparison.
+func (r *Router) runShadowed(ctx context.Context, name string, userID int64, primary, shadow func(ctx context.Context) error) error {
+	primaryCtx, recorded := core.WithRecording(ctx)
+	err := primary(primaryCtx)
+
+	go func() {
+		shadowCtx, captured := core.WithCapture(context.WithoutCancel(ctx))
+		report := ShadowReport{
+			Handler:    name,
+			UpdateID:   UpdateID(ctx),
+			UserID:     userID,
+			PrimaryErr: err,
+		}
+		func() {
+			defer func() {
+				if rec := recover(); rec != nil {
+					report.ShadowErr = fmt.Errorf("panic: %v", rec)
+				}
+			}()
+			report.ShadowErr = shadow(shadowCtx)
+		}()
+		report.Primary = recorded.Calls()
+		report.Shadow = captured.Calls()
+		report.Diff = diffRequests(report.Primary, report.Shadow)
+		r.reportShadow(context.WithoutCancel(ctx), report)
+	}()
+	return err
+}
+
+// reportShadow passes a shadow report to the reporter, or logs it if it
+// doesn't match and no reporter is set.
+func (r *Router) reportShadow(ctx context.Context, report ShadowReport) {
+	r.mu.RLock()
+	fn := r.shadowReporter
+	r.mu.RUnlock()
+	if fn != nil {
+		fn(ctx, report)
+		return
+	}
+	if report.Matches() {
+		return
+	}
+	diff := strings.Join(report.Diff, "; ")
+	if report.ShadowErr != nil {
+		diff = strings.TrimPrefix(diff+"; shadow error: "+report.ShadowErr.Error(), "; ")
+	}
+	log.Printf("[Shadow] %s differs for update %d: %s", report.Handler, report.UpdateID, diff)
+}
+
+// diffRequests lists the differences between the requests of a handler and
+// its shadow, compared in order: missing and extra requests, other methods,
+// and differing parameters.
+func diffRequests(primary, shadow []core.APICall) []string {
+	var diff []string
+	for i := 0; i < max(len(primary), len(shadow)); i++ {
+		switch {
+		case i >= len(shadow):
+			diff = append(diff, fmt.Sprintf("#%d %s: not made by shadow", i+1, primary[i].Method))
+		case i >= len(primary):
+			diff = append(diff, fmt.Sprintf("#%d %s: only made by shadow", i+1, shadow[i].Method))
+		case primary[i].Method != shadow[i].Method:
+			diff = append(diff, fmt.Sprintf("#%d: %s, shadow made %s", i+1, primary[i].Method, shadow[i].Method))
+		default:
+			for _, key := range paramKeys(primary[i].Params, shadow[i].Params) {
+				want, got := shadowValue(primary[i].Params[key]), shadowValue(shadow[i].Params[key])
+				if want != got {
+					diff = append(diff, fmt.Sprintf("#%d %s %s: %s, shadow %s", i+1, primary[i].Method, key, shorten(want), shorten(got)))
+				}
+			}
+		}
+	}
+	return diff
+}
+
+// paramKeys returns the sorted parameter names of two requests.
+func paramKeys(a, b map[string]any) []string {
+	seen := make(map[string]bool, len(a)+len(b))
+	var keys []string
+	for _, params := range []map[string]any{a, b} {
+		for key := range params {
+			if !seen[key] {
+				seen[key] = true
+				keys = append(keys, key)
+			}
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// shadowValue formats a parameter value for comparison.
+func shadowValue(v any) string {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(raw)
+}
+
+// shorten cuts a value longer than maxShadowValue for reports.
+func shorten(s string) string {
+	runes := []rune(s)
+	if len(runes) > maxShadowValue {
+		return string(runes[:maxShadowValue]) + "…"
+	}
+	return s
+}
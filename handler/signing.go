@@ -0,0 +1,59 @@
+package handler
+
+import (
+	"strings"
+	"time"
+
+	"github.com/mymmrac/telego"
+
+	"github.com/0xVanfer/tg-listener/signing"
+)
+
+// DefaultInvalidSignatureText is the alert shown when a signed callback fails verification.
+const DefaultInvalidSignatureText = "⛔ This button is no longer valid."
+
+// RequireSigned marks callback data prefixes as sensitive: their callbacks are
+// dispatched only with a valid signature for the pressing user.
+func (r *Router) RequireSigned(prefixes ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.signedPrefixes = append(r.signedPrefixes, prefixes...)
+}
+
+// Signer returns the signer for callback data, keyed by the configured callback secret.
+func (r *Router) Signer() *signing.Signer {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if r.config == nil || r.config.Bot == nil {
+		return signing.New(nil)
+	}
+	return signing.New(r.config.Bot.GetCallbackSecret())
+}
+
+// requiresSignature reports whether callback data belongs to a sensitive action.
+func (r *Router) requiresSignature(data string) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, prefix := range r.signedPrefixes {
+		if strings.HasPrefix(data, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// verifyCallback checks the signature of callback data and returns the data
+// without it. Unsigned data passes unless it belongs to a sensitive action.
+func (r *Router) verifyCallback(query telego.CallbackQuery) (string, error) {
+	if !signing.IsSigned(query.Data) {
+		if r.requiresSignature(query.Data) {
+			return "", signing.ErrNotSigned
+		}
+		return query.Data, nil
+	}
+	data, err := r.Signer().Verify(query.Data, query.From.ID, time.Now())
+	if err != nil {
+		return "", err
+	}
+	return data, nil
+}
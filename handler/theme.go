@@ -0,0 +1,27 @@
+package handler
+
+import (
+	"context"
+
+	"github.com/0xVanfer/tg-listener/core"
+)
+
+// Strings returns the built-in texts for a language tag such as "de-AT":
+// core.DefaultStrings with the configured theme's overrides applied.
+func (r *Router) Strings(languageCode string) core.Strings {
+	r.mu.RLock()
+	cfg := r.config
+	r.mu.RUnlock()
+	if cfg == nil || cfg.Theme == nil {
+		return core.DefaultStrings
+	}
+	return core.DefaultStrings.Override(core.Strings(cfg.Theme.For(languageCode)))
+}
+
+// withStrings tags a context with a lazy lookup of the built-in texts in the
+// language of the update's sender.
+func (r *Router) withStrings(ctx context.Context) context.Context {
+	return core.WithStringsFunc(ctx, func() core.Strings {
+		return r.Strings(core.LanguageFrom(ctx))
+	})
+}
@@ -0,0 +1,167 @@
+// Package handler provides message routing and processing functionality.
+package handler
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/mymmrac/telego"
+
+	"github.com/0xVanfer/tg-listener/config"
+	"github.com/0xVanfer/tg-listener/conv"
+	"github.com/0xVanfer/tg-listener/eventlog"
+)
+
+// DefaultTranscriptionErrorText is shown when a voice message cannot be transcribed.
+const DefaultTranscriptionErrorText = "Sorry, I couldn't understand the voice message. Please try again."
+
+// VoiceHandler is a function type for handling voice messages.
+type VoiceHandler func(ctx context.Context, msg telego.Message) error
+
+// Transcriber converts speech to text for voice steps.
+// Implementations wrap any speech-to-text backend; audio is the downloaded
+// voice message (usually OGG/Opus) and mimeType is as reported by Telegram.
+type Transcriber interface {
+	Transcribe(ctx context.Context, audio []byte, mimeType string) (string, error)
+}
+
+// TranscriberFunc adapts a function to a Transcriber.
+type TranscriberFunc func(ctx context.Context, audio []byte, mimeType string) (string, error)
+
+// Transcribe calls f(ctx, audio, mimeType).
+func (f TranscriberFunc) Transcribe(ctx context.Context, audio []byte, mimeType string) (string, error) {
+	return f(ctx, audio, mimeType)
+}
+
+// SetVoiceHandler sets the handler for voice messages outside voice steps.
+func (r *Router) SetVoiceHandler(handler VoiceHandler) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.voiceHandler = handler
+}
+
+// SetTranscriber sets the transcriber used by voice steps.
+// Pass nil to store voice input without a transcript.
+func (r *Router) SetTranscriber(t Transcriber) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.transcriber = t
+}
+
+// handleVoice processes voice messages.
+func (r *Router) handleVoice(ctx context.Context, msg telego.Message) {
+	if msg.From == nil {
+		return
+	}
+
+	// Authentication check
+	if !r.bot.CheckAuth(ctx, msg.From.ID, msg.From.Username) {
+		r.recordMessageEvent(ctx, eventlog.TypeUnauthorized, msg, "", nil)
+		return
+	}
+
+	// Maintenance mode check
+	if r.blockMessageInMaintenance(ctx, msg) {
+		return
+	}
+
+	r.logDebug("Voice received from user %d", msg.From.ID)
+
+	// Check if user is in a conversation expecting voice input
+	c := r.convManager.GetIn(msg.From.ID, msg.Chat.ID, msg.MessageThreadID)
+	if c != nil {
+		step := r.flowEngine.GetStep(c.FlowID, c.StepID)
+		if step != nil && step.InputType == config.InputTypeVoice {
+			r.handleConversationVoice(ctx, msg, c)
+			return
+		}
+	}
+
+	// Use voice handler
+	r.mu.RLock()
+	handler := r.voiceHandler
+	r.mu.RUnlock()
+
+	if handler != nil {
+		start := time.Now()
+		err := handler(ctx, msg)
+		r.observeLatency(ctx, "voice", start)
+		if err != nil {
+			r.logDebug("Voice handler error: %v", err)
+			r.recordMessageEvent(ctx, eventlog.TypeError, msg, "voice", err)
+		}
+		return
+	}
+	r.recordMessageEvent(ctx, eventlog.TypeUnhandled, msg, "voice", nil)
+}
+
+// handleConversationVoice handles voice messages during a conversation.
+// The file ID is always stored; with a transcriber, the transcript goes through
+// the step's validation and transforms, is stored as <store_as>_transcript,
+// and is the input used for branching.
+func (r *Router) handleConversationVoice(ctx context.Context, msg telego.Message, c *conv.Conversation) {
+	defer r.saveConversation(ctx, c)
+
+	step := r.flowEngine.GetStep(c.FlowID, c.StepID)
+	if step == nil || msg.Voice == nil {
+		return
+	}
+	voice := msg.Voice
+
+	r.mu.RLock()
+	transcriber := r.transcriber
+	r.mu.RUnlock()
+
+	input := "voice:" + voice.FileID
+	var transcript interface{}
+	if transcriber != nil {
+		text, err := r.transcribe(ctx, transcriber, voice)
+		if err != nil {
+			r.logDebug("Transcription error: %v", err)
+			r.recordConvEvent(ctx, eventlog.TypeError, c, "transcription", err)
+			r.failValidation(ctx, msg, c, errors.New(DefaultTranscriptionErrorText))
+			return
+		}
+
+		// Validate and normalize the transcript like text input
+		if err := r.flowEngine.ValidateInput(ctx, c, text); err != nil {
+			r.failValidation(ctx, msg, c, err)
+			return
+		}
+		value, err := r.flowEngine.TransformInput(c, text)
+		if err != nil {
+			r.failValidation(ctx, msg, c, err)
+			return
+		}
+		if s, ok := value.(string); ok {
+			text = s
+		}
+		input = text
+		transcript = value
+	}
+	r.clearValidationError(ctx, c)
+
+	// Store file info and transcript
+	if step.StoreAs != "" {
+		c.Set(step.StoreAs, voice.FileID)
+		c.Set(step.StoreAs+"_file_id", voice.FileID)
+		c.Set(step.StoreAs+"_duration", voice.Duration)
+		if transcript != nil {
+			c.Set(step.StoreAs+"_transcript", transcript)
+		}
+	}
+	c.AddHistory(c.StepID, input)
+
+	r.completeStep(ctx, c, step, msg.From.ID, input)
+}
+
+// transcribe downloads a voice message and transcribes it.
+func (r *Router) transcribe(ctx context.Context, transcriber Transcriber, voice *telego.Voice) (string, error) {
+	audio, err := r.bot.DownloadFile(ctx, voice.FileID)
+	if err != nil {
+		return "", err
+	}
+	defer r.observeLatency(ctx, "transcriber", time.Now())
+	return transcriber.Transcribe(ctx, audio, voice.MimeType)
+}
@@ -0,0 +1,104 @@
+// Package handler provides message routing and processing functionality.
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/mymmrac/telego"
+
+	"github.com/0xVanfer/tg-listener/config"
+	"github.com/0xVanfer/tg-listener/conv"
+	"github.com/0xVanfer/tg-listener/eventlog"
+)
+
+// WebAppDataHandler is a function type for handling data sent by Web Apps.
+// The data is in msg.WebAppData.Data, the label of the button that opened
+// the Web App in msg.WebAppData.ButtonText.
+type WebAppDataHandler func(ctx context.Context, msg telego.Message) error
+
+// SetWebAppDataHandler sets the handler for Web App data outside web_app steps.
+func (r *Router) SetWebAppDataHandler(handler WebAppDataHandler) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.webAppDataHandler = handler
+}
+
+// handleWebAppData processes data sent by Web Apps opened from reply keyboard buttons.
+func (r *Router) handleWebAppData(ctx context.Context, msg telego.Message) {
+	if msg.From == nil {
+		return
+	}
+
+	// Authentication check
+	if !r.bot.CheckAuth(ctx, msg.From.ID, msg.From.Username) {
+		r.recordMessageEvent(ctx, eventlog.TypeUnauthorized, msg, "", nil)
+		return
+	}
+
+	// Maintenance mode check
+	if r.blockMessageInMaintenance(ctx, msg) {
+		return
+	}
+
+	r.logDebug("Web App data received from user %d", msg.From.ID)
+
+	// Check if user is in a conversation expecting Web App data
+	c := r.convManager.GetIn(msg.From.ID, msg.Chat.ID, msg.MessageThreadID)
+	if c != nil {
+		step := r.flowEngine.GetStep(c.FlowID, c.StepID)
+		if step != nil && step.InputType == config.InputTypeWebApp {
+			r.handleConversationWebAppData(ctx, msg, c)
+			return
+		}
+	}
+
+	// Use Web App data handler
+	r.mu.RLock()
+	handler := r.webAppDataHandler
+	r.mu.RUnlock()
+
+	if handler != nil {
+		start := time.Now()
+		err := handler(ctx, msg)
+		r.observeLatency(ctx, "web_app", start)
+		if err != nil {
+			r.logDebug("Web App data handler error: %v", err)
+			r.recordMessageEvent(ctx, eventlog.TypeError, msg, "web_app", err)
+		}
+		return
+	}
+	r.recordMessageEvent(ctx, eventlog.TypeUnhandled, msg, "web_app", nil)
+}
+
+// handleConversationWebAppData handles Web App data during a conversation.
+// The data goes through the step's validation and is the input used for
+// branching. It is stored under store_as, decoded if it is JSON so templates
+// can reach its fields, with the button label as <store_as>_button.
+func (r *Router) handleConversationWebAppData(ctx context.Context, msg telego.Message, c *conv.Conversation) {
+	defer r.saveConversation(ctx, c)
+
+	step := r.flowEngine.GetStep(c.FlowID, c.StepID)
+	if step == nil || msg.WebAppData == nil {
+		return
+	}
+	data := msg.WebAppData.Data
+	if err := r.flowEngine.ValidateInput(ctx, c, data); err != nil {
+		r.failValidation(ctx, msg, c, err)
+		return
+	}
+	r.clearValidationError(ctx, c)
+
+	if step.StoreAs != "" {
+		var value interface{}
+		if err := json.Unmarshal([]byte(data), &value); err != nil {
+			value = data
+		}
+		c.Set(step.StoreAs, value)
+		c.Set(step.StoreAs+"_button", msg.WebAppData.ButtonText)
+	}
+	c.AddHistory(c.StepID, "web_app:"+msg.WebAppData.ButtonText)
+
+	r.completeStep(ctx, c, step, msg.From.ID, data)
+}
@@ -0,0 +1,187 @@
+package tgwrapper
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/mymmrac/telego"
+
+	"github.com/0xVanfer/tg-listener/config"
+	"github.com/0xVanfer/tg-listener/conv"
+	"github.com/0xVanfer/tg-listener/core"
+	"github.com/0xVanfer/tg-listener/i18n"
+)
+
+// LanguageFlowID is the ID of the built-in language flow.
+// The flow is installed when an i18n section is configured; other flows can
+// ask for the user's language with a sub_flow step calling it, and read the
+// chosen locale from {{.language}}.
+const LanguageFlowID = "_language"
+
+// Names of the language flow's internal handler and provider.
+const (
+	languageChoices = "_languageChoices"
+	languagePick    = "_languagePick"
+)
+
+// languageFlow returns the configuration of the built-in language flow.
+// Its texts can be translated with the "language.prompt" and "language.done" keys.
+func languageFlow() *config.FlowConfig {
+	return &config.FlowConfig{
+		ID:          LanguageFlowID,
+		Name:        "Language",
+		InitialStep: "language",
+		TTL:         15 * time.Minute,
+		Steps: map[string]*config.StepConfig{
+			"language": {
+				ID:         "language",
+				PromptText: "🌐 Choose your language:",
+				TextKey:    "language.prompt",
+				Keyboard: &config.KeyboardConfig{
+					Type:     config.KeyboardTypeDynamic,
+					Provider: languageChoices,
+					Columns:  2,
+					AddMain:  true,
+				},
+				InputType:  config.InputTypeCallback,
+				StoreAs:    "language",
+				OnComplete: languagePick,
+			},
+		},
+	}
+}
+
+// Catalog returns the message catalogs. Bots can add messages from code with
+// Catalog().Add; configured catalogs are loaded into it by New and reloaded
+// by ReloadConfig.
+func (w *Wrapper) Catalog() *i18n.Catalog {
+	return w.catalog
+}
+
+// UserLanguage returns a user's language: the one they chose with the
+// language flow, the one reported by Telegram, or the default language.
+func (w *Wrapper) UserLanguage(ctx context.Context, userID int64) string {
+	if u, err := w.Users().Get(ctx, userID); err == nil && u.PreferredLanguage() != "" {
+		return u.PreferredLanguage()
+	}
+	return w.catalog.Fallback()
+}
+
+// SetUserLanguage stores a user's language, such as "de" or "pt-BR", overriding
+// the one reported by Telegram. An empty locale reverts to the latter.
+func (w *Wrapper) SetUserLanguage(ctx context.Context, userID int64, locale string) error {
+	return w.Users().SetLocale(ctx, userID, i18n.Normalize(locale))
+}
+
+// Tr returns the message with a key in a user's language, formatted with args
+// as by fmt.Sprintf if any are given. Missing keys return the key itself.
+//
+// Example:
+//
+//	text := wrapper.Tr(ctx, userID, "orders.shipped", orderID)
+func (w *Wrapper) Tr(ctx context.Context, userID int64, key string, args ...interface{}) string {
+	return w.catalog.Translate(w.UserLanguage(ctx, userID), key, args...)
+}
+
+// userTranslator returns a translator into a language for core.Builder.Tr.
+func (w *Wrapper) userTranslator(language string) core.Translator {
+	return func(key string) (string, bool) {
+		return w.catalog.Lookup(language, key)
+	}
+}
+
+// resolveLanguage returns the language of an update's sender for the router.
+func (w *Wrapper) resolveLanguage(ctx context.Context, from *telego.User) string {
+	if u, err := w.Users().Get(ctx, from.ID); err == nil && u.Locale != "" {
+		return u.Locale
+	}
+	if from.LanguageCode != "" {
+		return from.LanguageCode
+	}
+	return w.catalog.Fallback()
+}
+
+// loadCatalogs loads the catalogs of a configuration into a catalog: the
+// files in its directory, then its inline messages.
+func loadCatalogs(catalog *i18n.Catalog, cfg *config.Config) error {
+	if cfg.I18n == nil {
+		return nil
+	}
+	catalog.SetFallback(cfg.I18n.GetDefault())
+	if dir := cfg.I18n.Dir; dir != "" {
+		if !filepath.IsAbs(dir) && cfg.Path() != "" {
+			dir = filepath.Join(filepath.Dir(cfg.Path()), dir)
+		}
+		if err := catalog.LoadDir(dir); err != nil {
+			return err
+		}
+	}
+	for locale, messages := range cfg.I18n.Messages {
+		catalog.Add(locale, messages)
+	}
+	return nil
+}
+
+// languageButtons lists the languages with a catalog by their own names.
+func (w *Wrapper) languageButtons(_ context.Context, _ *conv.Conversation) []config.ButtonData {
+	locales := w.catalog.Locales()
+	buttons := make([]config.ButtonData, 0, len(locales))
+	for _, locale := range locales {
+		buttons = append(buttons, config.ButtonData{Text: w.catalog.Name(locale), Callback: locale})
+	}
+	return buttons
+}
+
+// languagePickStep stores the chosen language. Started on its own, the flow
+// ends with a confirmation in the new language; called as a sub-flow, it
+// returns to the calling flow.
+func (w *Wrapper) languagePickStep(ctx context.Context, c *conv.Conversation) error {
+	locale := i18n.Normalize(c.GetString("language"))
+	if err := w.SetUserLanguage(ctx, c.UserID, locale); err != nil {
+		return err
+	}
+	c.Set("language", locale)
+	if c.CallDepth() > 0 {
+		return nil
+	}
+
+	chatID, topicID, msgID := c.ChatID, c.TopicID, c.KeyboardMsgID
+	w.EndConversationIn(ctx, c.UserID, chatID, topicID)
+	text, ok := w.catalog.Lookup(locale, "language.done")
+	if !ok {
+		text = "✅ Language: %s"
+	}
+	text = fmt.Sprintf(text, w.catalog.Name(locale))
+	if msgID > 0 {
+		_, err := w.bot.EditMessage(ctx, chatID, msgID, text)
+		return err
+	}
+	_, err := w.bot.SendMessage(ctx, chatID, topicID, text)
+	return err
+}
+
+// setupI18n resolves users' languages and translations for the router,
+// registers the language flow's handler and provider, and the language
+// command when enabled.
+func (w *Wrapper) setupI18n() {
+	w.router.SetLanguageResolver(w.resolveLanguage)
+	w.router.SetTranslator(w.catalog.Lookup)
+	w.flowEngine.RegisterKeyboardProvider(languageChoices, w.languageButtons)
+	w.flowEngine.RegisterStepHandler(languagePick, w.languagePickStep)
+
+	if w.Config().I18n == nil || !w.Config().I18n.Enabled {
+		return
+	}
+	w.router.RegisterCommand(w.Config().I18n.GetCommand(), func(ctx context.Context, msg telego.Message) error {
+		c, err := w.StartConversation(ctx, msg.From.ID, msg.Chat.ID, msg.MessageThreadID, LanguageFlowID, 0)
+		if w.notifyFlowDenied(ctx, err, msg.Chat.ID, msg.MessageThreadID, "") {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		return w.showStepPrompt(ctx, c)
+	})
+}
@@ -0,0 +1,238 @@
+// Package i18n provides message catalogs keyed by locale, for translating
+// menus, flow prompts, and messages into the user's language.
+package i18n
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+// NameKey is the catalog key holding a locale's own name, e.g. "Deutsch",
+// shown by language pickers. Locales without it use Names.
+const NameKey = "language.name"
+
+// Names are the native names of common languages by base language.
+var Names = map[string]string{
+	"ar": "العربية",
+	"de": "Deutsch",
+	"en": "English",
+	"es": "Español",
+	"fa": "فارسی",
+	"fr": "Français",
+	"hi": "हिन्दी",
+	"id": "Bahasa Indonesia",
+	"it": "Italiano",
+	"ja": "日本語",
+	"ko": "한국어",
+	"nl": "Nederlands",
+	"pl": "Polski",
+	"pt": "Português",
+	"ru": "Русский",
+	"tr": "Türkçe",
+	"uk": "Українська",
+	"vi": "Tiếng Việt",
+	"zh": "中文",
+}
+
+// Catalog holds translated messages by locale and key. Lookups fall back from
+// a regional locale ("pt-br") to its base language ("pt"), then to the
+// catalog's default locale.
+type Catalog struct {
+	messages map[string]map[string]string // Messages by locale, then key
+	fallback string                       // Default locale
+	mu       sync.RWMutex                 // Mutex for thread-safe access
+}
+
+// NewCatalog creates an empty catalog falling back to the given locale.
+func NewCatalog(fallback string) *Catalog {
+	return &Catalog{
+		messages: make(map[string]map[string]string),
+		fallback: Normalize(fallback),
+	}
+}
+
+// Normalize returns a locale in the catalog's form: lowercase, with "-"
+// separating the region ("pt_BR" becomes "pt-br").
+func Normalize(locale string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(locale), "_", "-"))
+}
+
+// base returns the base language of a normalized locale.
+func base(locale string) string {
+	lang, _, _ := strings.Cut(locale, "-")
+	return lang
+}
+
+// Fallback returns the catalog's default locale.
+func (c *Catalog) Fallback() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.fallback
+}
+
+// SetFallback sets the catalog's default locale.
+func (c *Catalog) SetFallback(locale string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.fallback = Normalize(locale)
+}
+
+// Add adds messages to a locale, replacing existing messages with the same keys.
+func (c *Catalog) Add(locale string, messages map[string]string) {
+	locale = Normalize(locale)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	m := c.messages[locale]
+	if m == nil {
+		m = make(map[string]string, len(messages))
+		c.messages[locale] = m
+	}
+	for k, v := range messages {
+		m[k] = v
+	}
+}
+
+// Parse adds the messages of a YAML or JSON document to a locale. Nested
+// maps are flattened into dotted keys, so {menu: {main: "Menu"}} defines
+// "menu.main". JSON is detected by the format "json"; anything else is YAML.
+func (c *Catalog) Parse(locale string, data []byte, format string) error {
+	var doc map[string]interface{}
+	var err error
+	if strings.EqualFold(strings.TrimPrefix(format, "."), "json") {
+		err = json.Unmarshal(data, &doc)
+	} else {
+		err = yaml.Unmarshal(data, &doc)
+	}
+	if err != nil {
+		return fmt.Errorf("locale %s: %w", locale, err)
+	}
+	messages := make(map[string]string)
+	flatten("", doc, messages)
+	c.Add(locale, messages)
+	return nil
+}
+
+// flatten copies the values of a nested document into messages under dotted keys.
+func flatten(prefix string, doc map[string]interface{}, messages map[string]string) {
+	for k, v := range doc {
+		key := k
+		if prefix != "" {
+			key = prefix + "." + k
+		}
+		switch v := v.(type) {
+		case map[string]interface{}:
+			flatten(key, v, messages)
+		case nil:
+		default:
+			messages[key] = fmt.Sprint(v)
+		}
+	}
+}
+
+// LoadFile adds the messages of a catalog file named after its locale, such
+// as "de.yaml" or "pt-BR.json".
+func (c *Catalog) LoadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	ext := filepath.Ext(path)
+	return c.Parse(strings.TrimSuffix(filepath.Base(path), ext), data, ext)
+}
+
+// LoadDir adds the catalog files (.yaml, .yml, or .json) in a directory.
+func (c *Catalog) LoadDir(dir string) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		switch strings.ToLower(filepath.Ext(e.Name())) {
+		case ".yaml", ".yml", ".json":
+			if err := c.LoadFile(filepath.Join(dir, e.Name())); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Locales returns the locales with messages, sorted.
+func (c *Catalog) Locales() []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	locales := make([]string, 0, len(c.messages))
+	for l := range c.messages {
+		locales = append(locales, l)
+	}
+	sort.Strings(locales)
+	return locales
+}
+
+// Match returns the catalog locale best matching a language code such as
+// "de-AT": the locale itself, its base language, or the default locale.
+func (c *Catalog) Match(languageCode string) string {
+	locale := Normalize(languageCode)
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	for _, l := range []string{locale, base(locale)} {
+		if _, ok := c.messages[l]; ok && l != "" {
+			return l
+		}
+	}
+	return c.fallback
+}
+
+// Lookup returns the message with a key in a locale, falling back to its base
+// language and the default locale. Returns false if none has the key.
+func (c *Catalog) Lookup(locale, key string) (string, bool) {
+	locale = Normalize(locale)
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	for _, l := range []string{locale, base(locale), c.fallback} {
+		if msg, ok := c.messages[l][key]; ok {
+			return msg, true
+		}
+	}
+	return "", false
+}
+
+// Translate returns the message with a key in a locale, formatted with args
+// as by fmt.Sprintf if any are given. Missing keys return the key itself, so
+// they stand out without breaking the message.
+func (c *Catalog) Translate(locale, key string, args ...interface{}) string {
+	msg, ok := c.Lookup(locale, key)
+	if !ok {
+		msg = key
+	}
+	if len(args) > 0 {
+		return fmt.Sprintf(msg, args...)
+	}
+	return msg
+}
+
+// Name returns the display name of a locale: its NameKey message, its
+// language's entry in Names, or the locale itself.
+func (c *Catalog) Name(locale string) string {
+	locale = Normalize(locale)
+	c.mu.RLock()
+	name, ok := c.messages[locale][NameKey]
+	c.mu.RUnlock()
+	if ok {
+		return name
+	}
+	if name, ok := Names[base(locale)]; ok {
+		return name
+	}
+	return locale
+}
@@ -0,0 +1,18 @@
+package tgwrapper
+
+import (
+	"github.com/0xVanfer/tg-listener/handler"
+)
+
+// RegisterInlineQuery registers a handler for inline queries whose text starts
+// with prefix. The longest matching prefix wins; an empty prefix matches every query.
+// Handlers answer with Bot().AnswerInlineQuery, e.g. results built with core.NewInlineResults.
+func (w *Wrapper) RegisterInlineQuery(prefix string, h handler.InlineQueryHandler) {
+	w.router.RegisterInlineQuery(prefix, h)
+}
+
+// OnChosenInlineResult sets a function called when a user picks an inline result.
+// Telegram only reports chosen results if inline feedback is enabled in @BotFather.
+func (w *Wrapper) OnChosenInlineResult(fn handler.ChosenInlineResultHandler) {
+	w.router.SetChosenInlineResultHandler(fn)
+}
@@ -0,0 +1,39 @@
+package tgwrapper
+
+import (
+	"context"
+
+	"github.com/mymmrac/telego"
+
+	"github.com/0xVanfer/tg-listener/handler"
+)
+
+// SetIntentResolver sets the resolver consulted for non-command text when the user
+// has no active conversation. The resolver can start a flow, show a menu, or invoke
+// a handler; a nil intent falls through to the default message handler.
+// Pass nil to disable intent routing.
+func (w *Wrapper) SetIntentResolver(resolver handler.IntentResolver) {
+	w.router.SetIntentResolver(resolver)
+}
+
+// dispatchIntent starts the flow or shows the menu of a resolved intent.
+func (w *Wrapper) dispatchIntent(ctx context.Context, msg telego.Message, intent *handler.Intent) error {
+	chatID := msg.Chat.ID
+	topicID := msg.MessageThreadID
+
+	if intent.FlowID == "" {
+		return w.ShowMenu(ctx, chatID, topicID, intent.MenuID, 0)
+	}
+
+	c, err := w.StartConversation(ctx, msg.From.ID, chatID, topicID, intent.FlowID, 0)
+	if w.notifyFlowDenied(ctx, err, chatID, topicID, "") {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	for k, v := range intent.Data {
+		c.Set(k, v)
+	}
+	return w.showStepPrompt(ctx, c)
+}
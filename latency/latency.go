@@ -0,0 +1,151 @@
+// Package latency records per-handler latency histograms and checks them
+// against latency budgets (SLOs), so slow handlers and the external APIs
+// behind them get noticed.
+package latency
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Observer receives the duration of a handler call. Names are prefixed by
+// handler kind, e.g. "cmd:price", "callback:settings", "step:saveOrder",
+// or "keyboard:getCryptoPrices".
+type Observer func(ctx context.Context, name string, d time.Duration)
+
+// DefaultBuckets are the default histogram bucket upper bounds.
+var DefaultBuckets = []time.Duration{
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	2500 * time.Millisecond,
+	5 * time.Second,
+	10 * time.Second,
+}
+
+// Histogram is a latency histogram of a handler.
+type Histogram struct {
+	Buckets  []time.Duration // Bucket upper bounds, ascending
+	Counts   []uint64        // Calls per bucket; the last entry counts calls above all bounds
+	Count    uint64          // Total calls
+	Sum      time.Duration   // Total time spent
+	Max      time.Duration   // Slowest call
+	Breaches uint64          // Calls that exceeded the budget
+}
+
+// Mean returns the average call duration.
+func (h Histogram) Mean() time.Duration {
+	if h.Count == 0 {
+		return 0
+	}
+	return h.Sum / time.Duration(h.Count)
+}
+
+// Quantile returns an upper bound of the q-quantile (0 < q <= 1) of call durations:
+// the bound of the bucket containing it, or Max for calls above all bounds.
+func (h Histogram) Quantile(q float64) time.Duration {
+	if h.Count == 0 {
+		return 0
+	}
+	rank := uint64(q * float64(h.Count))
+	if rank == 0 {
+		rank = 1
+	}
+	var seen uint64
+	for i, n := range h.Counts {
+		seen += n
+		if seen >= rank {
+			if i < len(h.Buckets) {
+				return min(h.Buckets[i], h.Max)
+			}
+			break
+		}
+	}
+	return h.Max
+}
+
+// observe records a call in the histogram.
+func (h *Histogram) observe(d time.Duration, breach bool) {
+	i := sort.Search(len(h.Buckets), func(i int) bool { return d <= h.Buckets[i] })
+	h.Counts[i]++
+	h.Count++
+	h.Sum += d
+	if d > h.Max {
+		h.Max = d
+	}
+	if breach {
+		h.Breaches++
+	}
+}
+
+// Tracker keeps latency histograms per handler name.
+type Tracker struct {
+	buckets    []time.Duration       // Bucket upper bounds for new histograms
+	histograms map[string]*Histogram // Histograms by handler name
+
+	mu sync.Mutex // Mutex for thread-safe histogram access
+}
+
+// NewTracker creates a tracker with the given bucket upper bounds.
+// Without buckets, DefaultBuckets are used.
+func NewTracker(buckets ...time.Duration) *Tracker {
+	if len(buckets) == 0 {
+		buckets = DefaultBuckets
+	}
+	sorted := append([]time.Duration(nil), buckets...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	return &Tracker{buckets: sorted, histograms: make(map[string]*Histogram)}
+}
+
+// Observe records a call of a handler and returns true if it exceeded the budget.
+// A budget of 0 means the handler has none.
+func (t *Tracker) Observe(name string, d, budget time.Duration) bool {
+	breach := budget > 0 && d > budget
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	h, ok := t.histograms[name]
+	if !ok {
+		h = &Histogram{Buckets: t.buckets, Counts: make([]uint64, len(t.buckets)+1)}
+		t.histograms[name] = h
+	}
+	h.observe(d, breach)
+	return breach
+}
+
+// Histogram returns a copy of a handler's histogram.
+// Returns false if the handler has not been observed.
+func (t *Tracker) Histogram(name string) (Histogram, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	h, ok := t.histograms[name]
+	if !ok {
+		return Histogram{}, false
+	}
+	c := *h
+	c.Counts = append([]uint64(nil), h.Counts...)
+	return c, true
+}
+
+// Snapshot returns copies of all histograms by handler name.
+func (t *Tracker) Snapshot() map[string]Histogram {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	snapshot := make(map[string]Histogram, len(t.histograms))
+	for name, h := range t.histograms {
+		c := *h
+		c.Counts = append([]uint64(nil), h.Counts...)
+		snapshot[name] = c
+	}
+	return snapshot
+}
+
+// Reset discards all histograms.
+func (t *Tracker) Reset() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.histograms = make(map[string]*Histogram)
+}
@@ -0,0 +1,373 @@
+// Package ledger provides a double-entry points/credits ledger backed by a store.
+// Every transaction moves amounts between accounts so that its postings sum to zero,
+// which keeps the total supply auditable: credits are minted from SystemMint and
+// spent into SystemSpent, and every movement is recorded in the journal.
+package ledger
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/0xVanfer/tg-listener/store"
+)
+
+// Store key prefixes for ledger documents.
+const (
+	accountKeyPrefix = "ledger_account:"
+	txKeyPrefix      = "ledger_tx:"
+	refKeyPrefix     = "ledger_ref:"
+	entryKeyPrefix   = "ledger_entry:"
+)
+
+// System accounts. They may carry negative balances; user accounts may not.
+const (
+	SystemMint  = "system:mint"  // Source of earned credits
+	SystemSpent = "system:spent" // Destination of spent credits
+)
+
+// Ledger errors.
+var (
+	// ErrInsufficientFunds is returned when a posting would overdraw a user account.
+	ErrInsufficientFunds = errors.New("insufficient funds")
+
+	// ErrUnbalanced is returned when a transaction's postings do not sum to zero.
+	ErrUnbalanced = errors.New("transaction postings do not balance")
+
+	// ErrInvalidAmount is returned for zero or negative amounts.
+	ErrInvalidAmount = errors.New("amount must be positive")
+)
+
+// Posting is a single movement on one account. Positive amounts credit the
+// account, negative amounts debit it.
+type Posting struct {
+	Account string `json:"account"` // Account name
+	Amount  int64  `json:"amount"`  // Signed amount in the smallest unit
+}
+
+// Transaction is a balanced set of postings recorded in the journal.
+type Transaction struct {
+	ID       string    `json:"id"`            // Journal ID, sortable by time
+	Postings []Posting `json:"postings"`      // Movements, summing to zero
+	Memo     string    `json:"memo"`          // Human-readable description
+	Ref      string    `json:"ref,omitempty"` // Optional idempotency reference
+	At       time.Time `json:"at"`            // When the transaction was posted
+}
+
+// Entry is a transaction as seen from one account, used for statements.
+type Entry struct {
+	TxID    string    `json:"tx_id"`   // Journal ID of the transaction
+	Amount  int64     `json:"amount"`  // Signed amount applied to the account
+	Balance int64     `json:"balance"` // Account balance after the entry
+	Memo    string    `json:"memo"`    // Transaction memo
+	At      time.Time `json:"at"`      // When the transaction was posted
+}
+
+// account is the persisted state of an account. Its entries are stored under
+// their own keys, so posting costs the same however long the history is.
+type account struct {
+	Balance int64 `json:"balance"`
+}
+
+// Ledger records transactions and account balances in a store.
+// Transactions are applied atomically with respect to each other within a
+// process. Stores have no compare-and-swap, so a ledger must be the only
+// writer of its accounts: bot instances sharing a store must not post to
+// the same ledger concurrently, or balances may lose updates.
+type Ledger struct {
+	store store.Store // Backing store
+	seq   uint64      // Counter disambiguating journal IDs within the same nanosecond
+	mu    sync.Mutex  // Serializes transactions
+}
+
+// New creates a ledger persisted in the given store.
+func New(s store.Store) *Ledger {
+	return &Ledger{store: s}
+}
+
+// UserAccount returns the account name for a user.
+func UserAccount(userID int64) string {
+	return "user:" + strconv.FormatInt(userID, 10)
+}
+
+// isSystem returns true for system accounts, which may go negative.
+func isSystem(name string) bool {
+	return strings.HasPrefix(name, "system:")
+}
+
+// loadAccount reads an account, returning an empty one if none exists.
+func (l *Ledger) loadAccount(ctx context.Context, name string) (*account, error) {
+	acc := &account{}
+	err := store.GetJSON(ctx, l.store, accountKeyPrefix+name, acc)
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		return nil, err
+	}
+	return acc, nil
+}
+
+// entryKey returns the store key of an account's entry for a transaction.
+// Journal IDs sort by time, so an account's keys do too.
+func entryKey(name, txID string) string {
+	return entryPrefix(name) + txID
+}
+
+// entryPrefix returns the store key prefix of an account's entries.
+func entryPrefix(name string) string {
+	return entryKeyPrefix + name + ":"
+}
+
+// Post records a balanced transaction and updates all affected accounts.
+// If tx.Ref is set and a transaction with the same reference was already posted,
+// that transaction is returned and nothing is changed, so retries are safe.
+// Returns ErrInsufficientFunds if any user account would go negative.
+// If a store write fails, the writes before it are undone, so the
+// transaction is recorded in full or not at all.
+func (l *Ledger) Post(ctx context.Context, tx Transaction) (*Transaction, error) {
+	var sum int64
+	for _, p := range tx.Postings {
+		sum += p.Amount
+	}
+	if sum != 0 || len(tx.Postings) < 2 {
+		return nil, ErrUnbalanced
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	// Idempotency: return the earlier transaction for a repeated reference
+	if tx.Ref != "" {
+		if data, err := l.store.Get(ctx, refKeyPrefix+tx.Ref); err == nil {
+			return l.transaction(ctx, string(data))
+		} else if !errors.Is(err, store.ErrNotFound) {
+			return nil, err
+		}
+	}
+
+	// Load and check all accounts before writing anything, keeping their stored
+	// documents so a failed write can be rolled back
+	accounts := make(map[string]*account, len(tx.Postings))
+	previous := make(map[string][]byte, len(tx.Postings))
+	for _, p := range tx.Postings {
+		acc, ok := accounts[p.Account]
+		if !ok {
+			data, err := l.store.Get(ctx, accountKeyPrefix+p.Account)
+			acc = &account{}
+			switch {
+			case errors.Is(err, store.ErrNotFound):
+			case err != nil:
+				return nil, err
+			default:
+				if err := json.Unmarshal(data, acc); err != nil {
+					return nil, err
+				}
+				previous[p.Account] = data
+			}
+			accounts[p.Account] = acc
+		}
+		acc.Balance += p.Amount
+	}
+	for name, acc := range accounts {
+		if acc.Balance < 0 && !isSystem(name) {
+			return nil, ErrInsufficientFunds
+		}
+	}
+
+	l.seq++
+	tx.At = time.Now()
+	tx.ID = fmt.Sprintf("%020d-%06d", tx.At.UnixNano(), l.seq%1000000)
+
+	// The journal is written first so balances can always be audited against it.
+	// If any write fails, the ones before it are undone, so a transaction is
+	// applied in full or not at all.
+	var undo []func(ctx context.Context)
+	fail := func(err error) (*Transaction, error) {
+		ctx := context.WithoutCancel(ctx)
+		for i := len(undo) - 1; i >= 0; i-- {
+			undo[i](ctx)
+		}
+		return nil, err
+	}
+	if err := store.PutJSON(ctx, l.store, txKeyPrefix+tx.ID, tx); err != nil {
+		return fail(err)
+	}
+	undo = append(undo, func(ctx context.Context) { _ = l.store.Delete(ctx, txKeyPrefix+tx.ID) })
+	for name, acc := range accounts {
+		var amount int64
+		for _, p := range tx.Postings {
+			if p.Account == name {
+				amount += p.Amount
+			}
+		}
+		key := entryKey(name, tx.ID)
+		entry := Entry{TxID: tx.ID, Amount: amount, Balance: acc.Balance, Memo: tx.Memo, At: tx.At}
+		if err := store.PutJSON(ctx, l.store, key, entry); err != nil {
+			return fail(err)
+		}
+		undo = append(undo, func(ctx context.Context) { _ = l.store.Delete(ctx, key) })
+		if err := store.PutJSON(ctx, l.store, accountKeyPrefix+name, acc); err != nil {
+			return fail(err)
+		}
+		prev, existed := previous[name]
+		undo = append(undo, func(ctx context.Context) {
+			if existed {
+				_ = l.store.Put(ctx, accountKeyPrefix+name, prev)
+			} else {
+				_ = l.store.Delete(ctx, accountKeyPrefix+name)
+			}
+		})
+	}
+	if tx.Ref != "" {
+		if err := l.store.Put(ctx, refKeyPrefix+tx.Ref, []byte(tx.ID)); err != nil {
+			return fail(err)
+		}
+	}
+	return &tx, nil
+}
+
+// HoldAccount returns the account credits are held in for a reference until
+// the hold is settled or released.
+func HoldAccount(ref string) string {
+	return "hold:" + ref
+}
+
+// Hold moves amount from a user into the hold account of ref, reserving it
+// for a purchase that completes later. Holding again under the same ref
+// changes nothing. Returns ErrInsufficientFunds if the balance is too low.
+func (l *Ledger) Hold(ctx context.Context, userID int64, amount int64, ref, memo string) (*Transaction, error) {
+	if amount <= 0 {
+		return nil, ErrInvalidAmount
+	}
+	return l.Post(ctx, Transaction{
+		Postings: []Posting{{Account: UserAccount(userID), Amount: -amount}, {Account: HoldAccount(ref), Amount: amount}},
+		Memo:     memo,
+		Ref:      "hold:" + ref,
+	})
+}
+
+// Settle spends the credits held under ref. Returns nil without a transaction
+// if nothing is held, e.g. because the hold was already settled or released.
+func (l *Ledger) Settle(ctx context.Context, ref, memo string) (*Transaction, error) {
+	return l.closeHold(ctx, ref, SystemSpent, "settle:", memo)
+}
+
+// Release returns the credits held under ref to the user. Returns nil without
+// a transaction if nothing is held, e.g. because the hold was already settled.
+func (l *Ledger) Release(ctx context.Context, userID int64, ref, memo string) (*Transaction, error) {
+	return l.closeHold(ctx, ref, UserAccount(userID), "release:", memo)
+}
+
+// closeHold moves the credits held under ref to an account.
+func (l *Ledger) closeHold(ctx context.Context, ref, to, refPrefix, memo string) (*Transaction, error) {
+	held, err := l.Balance(ctx, HoldAccount(ref))
+	if err != nil || held <= 0 {
+		return nil, err
+	}
+	tx, err := l.Post(ctx, Transaction{
+		Postings: []Posting{{Account: HoldAccount(ref), Amount: -held}, {Account: to, Amount: held}},
+		Memo:     memo,
+		Ref:      refPrefix + ref,
+	})
+	if errors.Is(err, ErrInsufficientFunds) {
+		// Settled or released concurrently
+		return nil, nil
+	}
+	return tx, err
+}
+
+// Transfer moves amount from one account to another.
+func (l *Ledger) Transfer(ctx context.Context, from, to string, amount int64, memo string) (*Transaction, error) {
+	if amount <= 0 {
+		return nil, ErrInvalidAmount
+	}
+	return l.Post(ctx, Transaction{
+		Postings: []Posting{{Account: from, Amount: -amount}, {Account: to, Amount: amount}},
+		Memo:     memo,
+	})
+}
+
+// Earn credits a user with newly minted credits.
+func (l *Ledger) Earn(ctx context.Context, userID int64, amount int64, memo string) (*Transaction, error) {
+	return l.Transfer(ctx, SystemMint, UserAccount(userID), amount, memo)
+}
+
+// Spend debits a user's credits. Returns ErrInsufficientFunds if the balance is too low.
+func (l *Ledger) Spend(ctx context.Context, userID int64, amount int64, memo string) (*Transaction, error) {
+	return l.Transfer(ctx, UserAccount(userID), SystemSpent, amount, memo)
+}
+
+// Balance returns the balance of an account. Unknown accounts have a zero balance.
+func (l *Ledger) Balance(ctx context.Context, name string) (int64, error) {
+	acc, err := l.loadAccount(ctx, name)
+	if err != nil {
+		return 0, err
+	}
+	return acc.Balance, nil
+}
+
+// UserBalance returns a user's balance.
+func (l *Ledger) UserBalance(ctx context.Context, userID int64) (int64, error) {
+	return l.Balance(ctx, UserAccount(userID))
+}
+
+// History returns the most recent entries of an account, newest first.
+// A limit of 0 or less returns all entries.
+func (l *Ledger) History(ctx context.Context, name string, limit int) ([]Entry, error) {
+	keys, err := l.store.List(ctx, entryPrefix(name))
+	if err != nil {
+		return nil, err
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(keys)))
+	if limit > 0 && limit < len(keys) {
+		keys = keys[:limit]
+	}
+	entries := make([]Entry, 0, len(keys))
+	for _, key := range keys {
+		var entry Entry
+		if err := store.GetJSON(ctx, l.store, key, &entry); err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// Transaction retrieves a journal entry by ID.
+// Returns store.ErrNotFound if it does not exist.
+func (l *Ledger) Transaction(ctx context.Context, id string) (*Transaction, error) {
+	return l.transaction(ctx, id)
+}
+
+// transaction reads a journal entry.
+func (l *Ledger) transaction(ctx context.Context, id string) (*Transaction, error) {
+	var tx Transaction
+	if err := store.GetJSON(ctx, l.store, txKeyPrefix+id, &tx); err != nil {
+		return nil, err
+	}
+	return &tx, nil
+}
+
+// Journal returns all transactions in posting order, for audits.
+func (l *Ledger) Journal(ctx context.Context) ([]*Transaction, error) {
+	keys, err := l.store.List(ctx, txKeyPrefix)
+	if err != nil {
+		return nil, err
+	}
+	txs := make([]*Transaction, 0, len(keys))
+	for _, key := range keys {
+		tx, err := l.transaction(ctx, strings.TrimPrefix(key, txKeyPrefix))
+		if err != nil {
+			return nil, err
+		}
+		txs = append(txs, tx)
+	}
+	sort.Slice(txs, func(i, j int) bool {
+		return txs[i].ID < txs[j].ID
+	})
+	return txs, nil
+}
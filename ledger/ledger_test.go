@@ -0,0 +1,137 @@
+package ledger
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/0xVanfer/tg-listener/store"
+)
+
+func TestPost(t *testing.T) {
+	alice, bob := UserAccount(1), UserAccount(2)
+
+	tests := []struct {
+		name     string
+		postings []Posting
+		wantErr  error
+		want     map[string]int64 // Balances after the transaction
+	}{
+		{
+			name:     "transfer",
+			postings: []Posting{{Account: alice, Amount: -30}, {Account: bob, Amount: 30}},
+			want:     map[string]int64{alice: 70, bob: 30, SystemMint: -100},
+		},
+		{
+			name:     "split",
+			postings: []Posting{{Account: alice, Amount: -60}, {Account: bob, Amount: 40}, {Account: SystemSpent, Amount: 20}},
+			want:     map[string]int64{alice: 40, bob: 40, SystemSpent: 20, SystemMint: -100},
+		},
+		{
+			name:     "whole balance",
+			postings: []Posting{{Account: alice, Amount: -100}, {Account: SystemSpent, Amount: 100}},
+			want:     map[string]int64{alice: 0, SystemSpent: 100, SystemMint: -100},
+		},
+		{
+			name:     "same account twice",
+			postings: []Posting{{Account: alice, Amount: -150}, {Account: alice, Amount: 100}, {Account: bob, Amount: 50}},
+			want:     map[string]int64{alice: 50, bob: 50, SystemMint: -100},
+		},
+		{
+			name:     "system account goes negative",
+			postings: []Posting{{Account: SystemSpent, Amount: -50}, {Account: bob, Amount: 50}},
+			want:     map[string]int64{alice: 100, bob: 50, SystemSpent: -50, SystemMint: -100},
+		},
+		{
+			name:     "overdraft",
+			postings: []Posting{{Account: alice, Amount: -101}, {Account: bob, Amount: 101}},
+			wantErr:  ErrInsufficientFunds,
+			want:     map[string]int64{alice: 100, bob: 0, SystemMint: -100},
+		},
+		{
+			name:     "unbalanced",
+			postings: []Posting{{Account: alice, Amount: -10}, {Account: bob, Amount: 5}},
+			wantErr:  ErrUnbalanced,
+			want:     map[string]int64{alice: 100, bob: 0, SystemMint: -100},
+		},
+		{
+			name:     "single posting",
+			postings: []Posting{{Account: alice, Amount: 0}},
+			wantErr:  ErrUnbalanced,
+			want:     map[string]int64{alice: 100, SystemMint: -100},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			l := New(store.NewMemoryStore())
+			if _, err := l.Earn(ctx, 1, 100, "seed"); err != nil {
+				t.Fatalf("Earn error = %v", err)
+			}
+
+			tx, err := l.Post(ctx, Transaction{Postings: tt.postings, Memo: tt.name})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Post error = %v, want %v", err, tt.wantErr)
+			}
+			for name, want := range tt.want {
+				if got, err := l.Balance(ctx, name); err != nil || got != want {
+					t.Errorf("Balance(%s) = %d, %v, want %d", name, got, err, want)
+				}
+			}
+
+			journal, err := l.Journal(ctx)
+			if err != nil {
+				t.Fatalf("Journal error = %v", err)
+			}
+			wantTxs := 2
+			if tt.wantErr != nil {
+				wantTxs = 1
+			}
+			if len(journal) != wantTxs {
+				t.Fatalf("journal has %d transactions, want %d", len(journal), wantTxs)
+			}
+			if tx == nil {
+				return
+			}
+
+			// The account's latest entry records the posting and the new balance
+			history, err := l.History(ctx, alice, 1)
+			if err != nil || len(history) != 1 {
+				t.Fatalf("History = %v, %v", history, err)
+			}
+			var amount int64
+			for _, p := range tt.postings {
+				if p.Account == alice {
+					amount += p.Amount
+				}
+			}
+			if amount != 0 && (history[0].TxID != tx.ID || history[0].Amount != amount || history[0].Balance != tt.want[alice]) {
+				t.Errorf("latest entry = %+v, want amount %d and balance %d of %s", history[0], amount, tt.want[alice], tx.ID)
+			}
+		})
+	}
+}
+
+func TestPostRef(t *testing.T) {
+	ctx := context.Background()
+	l := New(store.NewMemoryStore())
+	tx := Transaction{
+		Postings: []Posting{{Account: SystemMint, Amount: -25}, {Account: UserAccount(1), Amount: 25}},
+		Ref:      "payment:abc",
+	}
+
+	first, err := l.Post(ctx, tx)
+	if err != nil {
+		t.Fatalf("Post error = %v", err)
+	}
+	again, err := l.Post(ctx, tx)
+	if err != nil {
+		t.Fatalf("repeated Post error = %v", err)
+	}
+	if again.ID != first.ID {
+		t.Errorf("repeated Post = %s, want the first transaction %s", again.ID, first.ID)
+	}
+	if got, _ := l.UserBalance(ctx, 1); got != 25 {
+		t.Errorf("UserBalance = %d, want 25", got)
+	}
+}
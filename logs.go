@@ -0,0 +1,245 @@
+package tgwrapper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	ta "github.com/mymmrac/telego/telegoapi"
+
+	"github.com/0xVanfer/tg-listener/alert"
+	"github.com/0xVanfer/tg-listener/config"
+	"github.com/0xVanfer/tg-listener/core"
+	"github.com/0xVanfer/tg-listener/scheduler"
+)
+
+// logFlushJob is the ID of the recurring job posting collected log messages.
+const logFlushJob = "_logs"
+
+// LogLevel is the severity of a log message.
+type LogLevel int
+
+// Log levels, from the most to the least verbose.
+const (
+	// LogDebug is for diagnostics, posted only if logging.level is "debug".
+	LogDebug LogLevel = iota
+
+	// LogInfo is for routine events, posted to the log chat.
+	LogInfo
+
+	// LogWarn is for unexpected events, posted to the warning chat.
+	LogWarn
+
+	// LogError is for failures, posted to the warning chat.
+	LogError
+)
+
+// String returns the level name.
+func (l LogLevel) String() string {
+	switch l {
+	case LogDebug:
+		return config.LogLevelDebug
+	case LogWarn:
+		return config.LogLevelWarn
+	case LogError:
+		return config.LogLevelError
+	default:
+		return config.LogLevelInfo
+	}
+}
+
+// icon returns the symbol prefixed to messages of the level.
+func (l LogLevel) icon() string {
+	switch l {
+	case LogDebug:
+		return "🔍"
+	case LogWarn:
+		return "⚠️"
+	case LogError:
+		return "❌"
+	default:
+		return "ℹ️"
+	}
+}
+
+// parseLogLevel returns the level named by a logging.level value.
+func parseLogLevel(s string) LogLevel {
+	switch s {
+	case config.LogLevelDebug:
+		return LogDebug
+	case config.LogLevelWarn:
+		return LogWarn
+	case config.LogLevelError:
+		return LogError
+	default:
+		return LogInfo
+	}
+}
+
+// logTarget identifies the chat and topic log messages are posted to.
+type logTarget struct {
+	chatID  int64
+	topicID int
+}
+
+// logBatch holds the log messages waiting to be posted to one chat.
+type logBatch struct {
+	msg     *core.Builder // Collected messages, one per line
+	count   int           // Number of collected messages
+	dropped int           // Messages dropped since the batch was full
+}
+
+// logState holds collected log messages and recent API failures.
+type logState struct {
+	batches  map[logTarget]*logBatch // Log messages waiting to be posted, by chat
+	failures map[string][]time.Time  // Times of recent API failures, by warning key
+	mu       sync.Mutex              // Mutex for thread-safe log access
+}
+
+// logCtxKey is the context key marking requests that post logs or warnings,
+// whose failures aren't counted as API failures.
+type logCtxKey struct{}
+
+// logChat returns the chat a log message of the given level is posted to:
+// the log chat for debug and info, the warning chat for warn and error, each
+// falling back to the other.
+func (w *Wrapper) logChat(level LogLevel) *config.ChatConfig {
+	if level >= LogWarn {
+		return w.warningChat(alert.LevelWarning)
+	}
+	return w.warningChat(alert.LevelLog)
+}
+
+// Log posts a message to the log or warning chat, depending on its level.
+// Messages below logging.level are ignored. Messages are collected and posted
+// together every logging.flush_interval; beyond logging.max_batch per chat they
+// are dropped and only counted. Returns an error if no log or warning chat is
+// configured.
+func (w *Wrapper) Log(ctx context.Context, level LogLevel, msg *core.Builder) error {
+	cfg := w.Config().Bot.Logging
+	if level < parseLogLevel(cfg.GetLevel()) {
+		return nil
+	}
+	chat := w.logChat(level)
+	if chat == nil {
+		return fmt.Errorf("no log or warning chat is configured")
+	}
+
+	w.logs.mu.Lock()
+	defer w.logs.mu.Unlock()
+	if w.logs.batches == nil {
+		w.logs.batches = make(map[logTarget]*logBatch)
+	}
+	target := logTarget{chatID: chat.ChatID, topicID: chat.TopicID}
+	batch := w.logs.batches[target]
+	if batch == nil {
+		batch = &logBatch{msg: core.NewBuilder()}
+		w.logs.batches[target] = batch
+	}
+	if batch.count >= cfg.GetMaxBatch() {
+		batch.dropped++
+		return nil
+	}
+	if batch.count > 0 {
+		batch.msg.Ln()
+	}
+	batch.msg.Text(level.icon() + " ").Code(time.Now().UTC().Format("15:04:05")).Text(" ")
+	if msg != nil {
+		text, entities := msg.Build()
+		batch.msg.Append(text, entities)
+	}
+	batch.count++
+	return nil
+}
+
+// Logf posts a plain text message formatted with fmt.Sprintf, see Log.
+func (w *Wrapper) Logf(ctx context.Context, level LogLevel, format string, args ...any) error {
+	return w.Log(ctx, level, core.NewBuilder().Text(fmt.Sprintf(format, args...)))
+}
+
+// FlushLogs posts the collected log messages at once, e.g. before shutting down.
+func (w *Wrapper) FlushLogs(ctx context.Context) {
+	w.logs.mu.Lock()
+	batches := w.logs.batches
+	w.logs.batches = nil
+	w.logs.mu.Unlock()
+
+	ctx = context.WithValue(ctx, logCtxKey{}, true)
+	for target, batch := range batches {
+		if batch.dropped > 0 {
+			batch.msg.Ln().Italic(fmt.Sprintf("(%d more dropped)", batch.dropped))
+		}
+		text, entities := batch.msg.Build()
+		if _, err := w.bot.SendLongMessage(ctx, target.chatID, target.topicID, text, nil, entities...); err != nil {
+			log.Printf("[Logs] Failed to post %d messages to chat %d: %v", batch.count, target.chatID, err)
+		}
+	}
+}
+
+// apiFailed counts a failed request and sends a warning once failures of the
+// same kind reach logging.api_failure_threshold within logging.api_failure_window.
+// Requests to chats that blocked the bot, canceled requests, and the bot's own
+// log and warning posts are ignored.
+func (w *Wrapper) apiFailed(ctx context.Context, chatID int64, err error) {
+	cfg := w.Config().Bot.Logging
+	threshold := cfg.GetAPIFailureThreshold()
+	if threshold == 0 || ctx.Value(logCtxKey{}) != nil || w.warningChat(alert.LevelWarning) == nil {
+		return
+	}
+	if core.IsBlockedError(err) || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return
+	}
+	key := "api: request failed"
+	var apiErr *ta.Error
+	if errors.As(err, &apiErr) {
+		key = fmt.Sprintf("api: %d %s", apiErr.ErrorCode, apiErr.Description)
+	}
+
+	now := time.Now()
+	window := cfg.GetAPIFailureWindow()
+	w.logs.mu.Lock()
+	if w.logs.failures == nil {
+		w.logs.failures = make(map[string][]time.Time)
+	}
+	recent := w.logs.failures[key][:0]
+	for _, at := range w.logs.failures[key] {
+		if now.Sub(at) < window {
+			recent = append(recent, at)
+		}
+	}
+	recent = append(recent, now)
+	if len(recent) < threshold {
+		w.logs.failures[key] = recent
+		w.logs.mu.Unlock()
+		return
+	}
+	delete(w.logs.failures, key)
+	w.logs.mu.Unlock()
+
+	msg := core.NewBuilder().
+		Text(fmt.Sprintf("%d failed Telegram requests within %s, the last to chat ", len(recent), window)).
+		Code(fmt.Sprint(chatID)).Text(":").Ln().
+		Pre(err.Error(), "")
+	go func() {
+		ctx := context.WithValue(context.Background(), logCtxKey{}, true)
+		if werr := w.Warn(ctx, key, msg); werr != nil {
+			log.Printf("[Logs] Failed to warn about API failures: %v", werr)
+		}
+	}()
+}
+
+// setupLogging posts collected log messages periodically and watches for
+// repeated API failures.
+func (w *Wrapper) setupLogging() {
+	w.bot.SetFailureObserver(w.apiFailed)
+	w.scheduleLogFlush()
+}
+
+// scheduleLogFlush (re)arms the job posting collected log messages at the
+// configured interval.
+func (w *Wrapper) scheduleLogFlush() {
+	w.scheduler.Add(logFlushJob, scheduler.Every(w.Config().Bot.Logging.GetFlushInterval()), w.FlushLogs)
+}
@@ -0,0 +1,102 @@
+package tgwrapper
+
+import (
+	"context"
+	"log"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/0xVanfer/tg-listener/conv"
+	"github.com/0xVanfer/tg-listener/handler"
+	"github.com/0xVanfer/tg-listener/store"
+)
+
+// installedChatKeyPrefix is the store key prefix for chats the bot is in.
+const installedChatKeyPrefix = "installed_chat:"
+
+// InstalledChat is a group, supergroup, or channel the bot was added to.
+type InstalledChat struct {
+	ChatID  int64     `json:"chat_id"`
+	Title   string    `json:"title,omitempty"`
+	Type    string    `json:"type"`
+	AddedBy int64     `json:"added_by,omitempty"` // User who added the bot
+	AddedAt time.Time `json:"added_at"`
+}
+
+// RegisterMemberHandler registers a handler for a kind of chat membership
+// change: handler.BotAddedToGroup, handler.BotKicked, handler.UserJoined, or
+// handler.UserLeft. Register UserJoined and UserLeft handlers before Start, so
+// chat_member updates are requested; Telegram only sends them for chats the
+// bot administers.
+//
+// Example:
+//
+//	wrapper.RegisterMemberHandler(handler.UserJoined, func(ctx context.Context, e handler.MemberEvent) error {
+//	    _, err := wrapper.Bot().SendMessage(ctx, e.Chat.ID, 0, "👋 Welcome, "+e.User.FirstName+"!")
+//	    return err
+//	})
+func (w *Wrapper) RegisterMemberHandler(kind handler.MemberEventKind, h handler.MemberHandler) {
+	w.router.RegisterMemberHandler(kind, h)
+}
+
+// InstalledChats returns the chats the bot is in, the earliest added first.
+// Only chats the bot was added to since installation tracking began are known.
+func (w *Wrapper) InstalledChats(ctx context.Context) ([]InstalledChat, error) {
+	keys, err := w.Store().List(ctx, installedChatKeyPrefix)
+	if err != nil {
+		return nil, err
+	}
+	chats := make([]InstalledChat, 0, len(keys))
+	for _, key := range keys {
+		var chat InstalledChat
+		if err := store.GetJSON(ctx, w.Store(), key, &chat); err != nil {
+			continue
+		}
+		chats = append(chats, chat)
+	}
+	sort.Slice(chats, func(i, j int) bool {
+		return chats[i].AddedAt.Before(chats[j].AddedAt)
+	})
+	return chats, nil
+}
+
+// installedChatKey returns the store key of a chat the bot is in.
+func installedChatKey(chatID int64) string {
+	return installedChatKeyPrefix + strconv.FormatInt(chatID, 10)
+}
+
+// botAdded records a chat the bot was added to.
+func (w *Wrapper) botAdded(ctx context.Context, e handler.MemberEvent) error {
+	title := e.Chat.Title
+	if title == "" && e.Chat.Username != "" {
+		title = "@" + e.Chat.Username
+	}
+	log.Printf("[Members] Added to chat %d (%s) by user %d", e.Chat.ID, title, e.From.ID)
+	return store.PutJSON(ctx, w.Store(), installedChatKey(e.Chat.ID), InstalledChat{
+		ChatID:  e.Chat.ID,
+		Title:   title,
+		Type:    e.Chat.Type,
+		AddedBy: e.From.ID,
+		AddedAt: time.Now(),
+	})
+}
+
+// botKicked forgets a chat the bot was removed from and cancels the
+// conversations taking place in it, which can no longer be answered.
+func (w *Wrapper) botKicked(ctx context.Context, e handler.MemberEvent) error {
+	log.Printf("[Members] Removed from chat %d by user %d", e.Chat.ID, e.From.ID)
+	for _, v := range w.convManager.Find(conv.Filter{ChatID: e.Chat.ID}) {
+		if c := w.convManager.GetIn(v.UserID, v.ChatID, v.TopicID); c != nil {
+			c.Cancel()
+			w.convManager.EndIn(ctx, v.UserID, v.ChatID, v.TopicID)
+		}
+	}
+	return w.Store().Delete(ctx, installedChatKey(e.Chat.ID))
+}
+
+// setupMembers tracks the chats the bot is installed in.
+func (w *Wrapper) setupMembers() {
+	w.router.RegisterMemberHandler(handler.BotAddedToGroup, w.botAdded)
+	w.router.RegisterMemberHandler(handler.BotKicked, w.botKicked)
+}
@@ -3,7 +3,10 @@ package menu
 
 import (
 	"context"
+	"strconv"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/mymmrac/telego"
 
@@ -12,81 +15,134 @@ import (
 )
 
 // Menu represents a menu with optional pagination support.
+// Menus are shared by all chats; the page shown in a message is tracked by Manager.
 type Menu struct {
-	Config      *config.MenuConfig // Menu configuration
-	CurrentPage int                // Current page number (1-based)
+	ID     string             // Menu ID used in pagination callbacks
+	Config *config.MenuConfig // Menu configuration
 }
 
 // NewMenu creates a new menu instance from configuration.
 func NewMenu(cfg *config.MenuConfig) *Menu {
 	return &Menu{
-		Config:      cfg,
-		CurrentPage: 1,
+		ID:     cfg.ID,
+		Config: cfg,
 	}
 }
 
+// newMenu creates a menu registered under id, which takes precedence
+// over an empty or different ID in its configuration.
+func newMenu(id string, cfg *config.MenuConfig) *Menu {
+	menu := NewMenu(cfg)
+	menu.ID = id
+	return menu
+}
+
+// PageCallback returns the callback data that shows a page of a menu.
+func PageCallback(menuID string, page int) string {
+	return core.CallbackPage + menuID + ":" + strconv.Itoa(page)
+}
+
+// ParsePageCallback extracts the menu ID and page number from pagination callback data.
+// Returns false if data is not a valid pagination callback.
+func ParsePageCallback(data string) (string, int, bool) {
+	rest, ok := strings.CutPrefix(data, core.CallbackPage)
+	if !ok {
+		return "", 0, false
+	}
+	i := strings.LastIndex(rest, ":")
+	if i <= 0 {
+		return "", 0, false
+	}
+	page, err := strconv.Atoi(rest[i+1:])
+	if err != nil || page < 1 {
+		return "", 0, false
+	}
+	return rest[:i], page, true
+}
+
 // GetText returns the menu text.
 func (m *Menu) GetText() string {
 	return m.Config.Text
 }
 
-// GetKeyboard builds and returns the menu keyboard.
+// GetKeyboard builds and returns the menu keyboard, showing the first page of paginated menus.
 // The evaluator function is used to evaluate button visibility conditions.
 func (m *Menu) GetKeyboard(ctx context.Context, evaluator func(condition string) bool) *telego.InlineKeyboardMarkup {
-	kb := core.NewKeyboard()
+	return m.GetPageKeyboard(ctx, 1, evaluator)
+}
 
-	// If menu has pages, show current page keyboard
-	if len(m.Config.Pages) > 0 {
-		return m.getPageKeyboard(ctx, evaluator)
+// GetPageKeyboard builds and returns the menu keyboard showing a page (1-based)
+// of a paginated menu. Out-of-range pages show the first page; the page is
+// ignored for menus without pages. If conditions hide every button, the
+// empty state's buttons are shown instead.
+func (m *Menu) GetPageKeyboard(ctx context.Context, page int, evaluator func(condition string) bool) *telego.InlineKeyboardMarkup {
+	page = m.clampPage(page)
+	kb := core.NewKeyboard().WithStrings(core.StringsFrom(ctx))
+
+	rows := m.visibleRows(ctx, m.pageButtons(page), evaluator)
+	if len(rows) == 0 && m.Config.EmptyState != nil {
+		rows = m.visibleRows(ctx, m.Config.EmptyState.Buttons, evaluator)
 	}
-
-	// Add regular buttons
-	for _, row := range m.Config.Buttons {
+	for _, row := range rows {
 		var buttons []telego.InlineKeyboardButton
 		for _, btn := range row {
-			// Check button condition
-			if btn.Condition != "" && evaluator != nil && !evaluator(btn.Condition) {
-				continue
+			if text, ok := core.Translate(ctx, btn.TextKey); ok {
+				btn.Text = text
 			}
 			buttons = append(buttons, m.buildButton(btn))
 		}
-		if len(buttons) > 0 {
-			kb.Row(buttons...)
-		}
+		kb.Row(buttons...)
+	}
+
+	// Add pagination navigation
+	if len(m.Config.Pages) > 1 {
+		kb.Pagination(page, len(m.Config.Pages), core.CallbackPage+m.ID+":")
 	}
 
 	return kb.Build()
 }
 
-// getPageKeyboard builds the keyboard for a paginated menu.
-func (m *Menu) getPageKeyboard(ctx context.Context, evaluator func(condition string) bool) *telego.InlineKeyboardMarkup {
-	if m.CurrentPage < 1 || m.CurrentPage > len(m.Config.Pages) {
-		m.CurrentPage = 1
+// IsEmpty returns true if conditions and roles hide every button of a page (1-based).
+func (m *Menu) IsEmpty(ctx context.Context, page int, evaluator func(condition string) bool) bool {
+	return len(m.visibleRows(ctx, m.pageButtons(m.clampPage(page)), evaluator)) == 0
+}
+
+// clampPage returns page if the menu has it, and 1 otherwise.
+func (m *Menu) clampPage(page int) int {
+	if page < 1 || page > len(m.Config.Pages) {
+		return 1
 	}
+	return page
+}
 
-	kb := core.NewKeyboard()
-	page := m.Config.Pages[m.CurrentPage-1]
+// pageButtons returns the button rows of a page, or the menu's buttons if it has no pages.
+func (m *Menu) pageButtons(page int) [][]config.ButtonConfig {
+	if len(m.Config.Pages) == 0 {
+		return m.Config.Buttons
+	}
+	return m.Config.Pages[page-1].Buttons
+}
 
-	// Add buttons for current page
-	for _, row := range page.Buttons {
-		var buttons []telego.InlineKeyboardButton
+// visibleRows returns the rows with buttons hidden by their condition or by
+// roles the user lacks removed, dropping rows left without buttons.
+func (m *Menu) visibleRows(ctx context.Context, rows [][]config.ButtonConfig, evaluator func(condition string) bool) [][]config.ButtonConfig {
+	var visible [][]config.ButtonConfig
+	for _, row := range rows {
+		var buttons []config.ButtonConfig
 		for _, btn := range row {
 			if btn.Condition != "" && evaluator != nil && !evaluator(btn.Condition) {
 				continue
 			}
-			buttons = append(buttons, m.buildButton(btn))
+			if len(btn.Roles) > 0 && !config.HasRole(core.RolesFrom(ctx), btn.Roles) {
+				continue
+			}
+			buttons = append(buttons, btn)
 		}
 		if len(buttons) > 0 {
-			kb.Row(buttons...)
+			visible = append(visible, buttons)
 		}
 	}
-
-	// Add pagination navigation
-	if len(m.Config.Pages) > 1 {
-		kb.Pagination(m.CurrentPage, len(m.Config.Pages), core.CallbackPage)
-	}
-
-	return kb.Build()
+	return visible
 }
 
 // buildButton creates a keyboard button from configuration.
@@ -94,6 +150,9 @@ func (m *Menu) buildButton(btn config.ButtonConfig) telego.InlineKeyboardButton
 	if btn.URL != "" {
 		return core.URLButton(btn.Text, btn.URL)
 	}
+	if btn.WebApp != "" {
+		return core.WebAppButton(btn.Text, btn.WebApp)
+	}
 	if btn.FlowID != "" {
 		return core.Button(btn.Text, "flow:"+btn.FlowID)
 	}
@@ -103,33 +162,54 @@ func (m *Menu) buildButton(btn config.ButtonConfig) telego.InlineKeyboardButton
 	return core.Button(btn.Text, btn.Callback)
 }
 
-// SetPage sets the current page number.
-func (m *Menu) SetPage(page int) {
-	if page >= 1 && page <= len(m.Config.Pages) {
-		m.CurrentPage = page
-	}
+// messageKey identifies a message showing a menu.
+type messageKey struct {
+	chatID    int64
+	messageID int
+}
+
+// pageStateTTL is how long the page shown in a message is remembered after it
+// was last changed. Older messages show the first page when edited to their menu.
+const pageStateTTL = 24 * time.Hour
+
+// pageState is the menu and page shown in a message.
+type pageState struct {
+	menuID  string
+	page    int
+	expires time.Time
 }
 
 // Manager manages menu instances and provides menu display functionality.
 type Manager struct {
-	bot    *core.Bot        // Bot instance for sending messages
-	config *config.Config   // Configuration
-	menus  map[string]*Menu // Menu instances by ID
-	mu     sync.RWMutex     // Mutex for thread-safe operations
+	bot      *core.Bot                // Bot instance for sending messages
+	config   *config.Config           // Configuration
+	menus    map[string]*Menu         // Menu instances by ID
+	pages    map[messageKey]pageState // Pages shown in messages, if not the first
+	swept    time.Time                // When expired pages were last dropped
+	renderer TextRenderer             // Optional renderer for template expressions in menu texts
+	onShown  ShownFunc                // Optional observer of shown menus
+	mu       sync.RWMutex             // Mutex for thread-safe operations
 }
 
+// TextRenderer renders template expressions in a menu text for a specific chat.
+type TextRenderer func(ctx context.Context, chatID int64, text string) string
+
+// ShownFunc is called after a menu was sent or edited into a message.
+type ShownFunc func(ctx context.Context, chatID int64, messageID int, menu *Menu)
+
 // NewManager creates a new menu manager.
 func NewManager(bot *core.Bot, cfg *config.Config) *Manager {
 	m := &Manager{
 		bot:    bot,
 		config: cfg,
 		menus:  make(map[string]*Menu),
+		pages:  make(map[messageKey]pageState),
 	}
 
 	// Initialize all menus from configuration
 	if cfg != nil {
 		for id, menuCfg := range cfg.Menus {
-			m.menus[id] = NewMenu(menuCfg)
+			m.menus[id] = newMenu(id, menuCfg)
 		}
 	}
 
@@ -146,11 +226,36 @@ func (m *Manager) SetConfig(cfg *config.Config) {
 	m.menus = make(map[string]*Menu)
 	if cfg != nil {
 		for id, menuCfg := range cfg.Menus {
-			m.menus[id] = NewMenu(menuCfg)
+			m.menus[id] = newMenu(id, menuCfg)
 		}
 	}
 }
 
+// SetRenderer sets the renderer applied to menu texts before display.
+func (m *Manager) SetRenderer(fn TextRenderer) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.renderer = fn
+}
+
+// SetShownHandler sets the function called after a menu was sent or edited into a message.
+func (m *Manager) SetShownHandler(fn ShownFunc) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.onShown = fn
+}
+
+// shown passes a successfully shown menu to the shown handler.
+func (m *Manager) shown(ctx context.Context, chatID int64, msg *telego.Message, err error, menu *Menu) (*telego.Message, error) {
+	m.mu.RLock()
+	fn := m.onShown
+	m.mu.RUnlock()
+	if err == nil && msg != nil && fn != nil {
+		fn(ctx, chatID, msg.MessageID, menu)
+	}
+	return msg, err
+}
+
 // GetMenu retrieves a menu by ID.
 func (m *Manager) GetMenu(menuID string) *Menu {
 	m.mu.RLock()
@@ -158,55 +263,150 @@ func (m *Manager) GetMenu(menuID string) *Menu {
 	return m.menus[menuID]
 }
 
+// resolveMenu retrieves the menu to display in a chat, honoring tenant overrides.
+// Tenant menus are built on demand since they only apply to specific chats.
+func (m *Manager) resolveMenu(chatID int64, menuID string) *Menu {
+	m.mu.RLock()
+	cfg := m.config
+	m.mu.RUnlock()
+
+	if cfg != nil {
+		if t := cfg.TenantFor(chatID); t != nil {
+			if menuCfg, ok := t.Menus[menuID]; ok {
+				return newMenu(menuID, menuCfg)
+			}
+		}
+	}
+	return m.GetMenu(menuID)
+}
+
+// resolveText returns the menu text for a chat, translated if the menu has a
+// text key, honoring tenant text overrides and the empty state of a page
+// without visible buttons, and rendering template expressions if a renderer is set.
+func (m *Manager) resolveText(ctx context.Context, chatID int64, menuID string, menu *Menu, page int, evaluator func(string) bool) string {
+	m.mu.RLock()
+	cfg := m.config
+	renderer := m.renderer
+	m.mu.RUnlock()
+
+	text := menu.GetText()
+	if translated, ok := core.Translate(ctx, menu.Config.TextKey); ok {
+		text = translated
+	}
+	if empty := menu.Config.EmptyState; empty != nil && empty.Text != "" && menu.IsEmpty(ctx, page, evaluator) {
+		text = empty.Text
+	} else if cfg != nil {
+		text = cfg.ResolveText(chatID, config.MenuTextKey(menuID), text)
+	}
+	if renderer != nil {
+		text = renderer(ctx, chatID, text)
+	}
+	return text
+}
+
+// mainMenuID returns the main menu ID for a chat, honoring tenant overrides.
+func (m *Manager) mainMenuID(chatID int64) string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if m.config == nil {
+		return ""
+	}
+	return m.config.ResolveMainMenuID(chatID)
+}
+
 // ShowMenu displays a menu by sending a new message.
 func (m *Manager) ShowMenu(ctx context.Context, chatID int64, topicID int, menuID string, evaluator func(string) bool) (*telego.Message, error) {
-	menu := m.GetMenu(menuID)
+	menu := m.resolveMenu(chatID, menuID)
 	if menu == nil {
 		return nil, nil
 	}
 
-	text := menu.GetText()
+	ctx = core.WithParseMode(ctx, menu.Config.ParseMode)
+	text := m.resolveText(ctx, chatID, menuID, menu, 1, evaluator)
 	keyboard := menu.GetKeyboard(ctx, evaluator)
 
-	return m.bot.SendMessageWithKeyboard(ctx, chatID, topicID, text, keyboard)
+	msg, err := m.bot.SendMessageWithKeyboard(ctx, chatID, topicID, text, keyboard)
+	return m.shown(ctx, chatID, msg, err, menu)
 }
 
 // EditToMenu edits an existing message to show a menu.
+// If the message already shows a page of the same menu, that page is kept.
 func (m *Manager) EditToMenu(ctx context.Context, chatID int64, messageID int, menuID string, evaluator func(string) bool) (*telego.Message, error) {
-	menu := m.GetMenu(menuID)
+	return m.editToPage(ctx, chatID, messageID, menuID, m.Page(chatID, messageID, menuID), evaluator)
+}
+
+// editToPage edits an existing message to show a page of a menu and records the page.
+func (m *Manager) editToPage(ctx context.Context, chatID int64, messageID int, menuID string, page int, evaluator func(string) bool) (*telego.Message, error) {
+	menu := m.resolveMenu(chatID, menuID)
 	if menu == nil {
 		return nil, nil
 	}
+	page = menu.clampPage(page)
+	m.setPage(chatID, messageID, menuID, page)
 
-	text := menu.GetText()
-	keyboard := menu.GetKeyboard(ctx, evaluator)
+	ctx = core.WithParseMode(ctx, menu.Config.ParseMode)
+	text := m.resolveText(ctx, chatID, menuID, menu, page, evaluator)
+	keyboard := menu.GetPageKeyboard(ctx, page, evaluator)
+
+	msg, err := m.bot.EditMessageWithKeyboard(ctx, chatID, messageID, text, keyboard)
+	return m.shown(ctx, chatID, msg, err, menu)
+}
+
+// Page returns the page (1-based) of a menu shown in a message,
+// or 1 if the message shows another menu or the first page.
+func (m *Manager) Page(chatID int64, messageID int, menuID string) int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	state, ok := m.pages[messageKey{chatID, messageID}]
+	if !ok || state.menuID != menuID || !time.Now().Before(state.expires) {
+		return 1
+	}
+	return state.page
+}
 
-	return m.bot.EditMessageWithKeyboard(ctx, chatID, messageID, text, keyboard)
+// setPage records the page of a menu shown in a message. Only pages after the
+// first are kept, so messages that never paged need no state, and pages expire
+// after pageStateTTL, so the state stays bounded however many messages page.
+func (m *Manager) setPage(chatID int64, messageID int, menuID string, page int) {
+	key := messageKey{chatID, messageID}
+	now := time.Now()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if now.Sub(m.swept) >= time.Minute {
+		for k, state := range m.pages {
+			if !now.Before(state.expires) {
+				delete(m.pages, k)
+			}
+		}
+		m.swept = now
+	}
+	if page <= 1 {
+		delete(m.pages, key)
+		return
+	}
+	m.pages[key] = pageState{menuID: menuID, page: page, expires: now.Add(pageStateTTL)}
 }
 
 // ShowMainMenu displays the main menu by sending a new message.
 func (m *Manager) ShowMainMenu(ctx context.Context, chatID int64, topicID int, evaluator func(string) bool) (*telego.Message, error) {
-	if m.config == nil || m.config.MainMenuID == "" {
+	menuID := m.mainMenuID(chatID)
+	if menuID == "" {
 		return nil, nil
 	}
-	return m.ShowMenu(ctx, chatID, topicID, m.config.MainMenuID, evaluator)
+	return m.ShowMenu(ctx, chatID, topicID, menuID, evaluator)
 }
 
 // EditToMainMenu edits an existing message to show the main menu.
 func (m *Manager) EditToMainMenu(ctx context.Context, chatID int64, messageID int, evaluator func(string) bool) (*telego.Message, error) {
-	if m.config == nil || m.config.MainMenuID == "" {
+	menuID := m.mainMenuID(chatID)
+	if menuID == "" {
 		return nil, nil
 	}
-	return m.EditToMenu(ctx, chatID, messageID, m.config.MainMenuID, evaluator)
+	return m.EditToMenu(ctx, chatID, messageID, menuID, evaluator)
 }
 
-// HandlePageChange handles pagination by changing the current page and refreshing the display.
+// HandlePageChange handles pagination by changing the page shown in a message
+// and refreshing the display. Other messages showing the same menu keep their page.
 func (m *Manager) HandlePageChange(ctx context.Context, chatID int64, messageID int, menuID string, page int, evaluator func(string) bool) (*telego.Message, error) {
-	menu := m.GetMenu(menuID)
-	if menu == nil {
-		return nil, nil
-	}
-
-	menu.SetPage(page)
-	return m.EditToMenu(ctx, chatID, messageID, menuID, evaluator)
+	return m.editToPage(ctx, chatID, messageID, menuID, page, evaluator)
 }
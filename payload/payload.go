@@ -0,0 +1,179 @@
+// Package payload maps callback data longer than Telegram's 64-byte limit to
+// short tokens, so keyboards can carry long IDs. Tokens are persisted with an
+// expiry in a store, which may be shared by several bot instances.
+package payload
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/0xVanfer/tg-listener/store"
+)
+
+// Prefix starts every token, so tokens are told apart from plain callback data.
+const Prefix = "~p:"
+
+// keyPrefix is the store key prefix of tokens.
+const keyPrefix = "payload:"
+
+// MaxDataLength is the maximum length of callback data accepted by Telegram.
+const MaxDataLength = 64
+
+// tokenSize is the number of hash bytes encoded in a token.
+const tokenSize = 12
+
+// DefaultTTL is how long tokens stay valid by default after a keyboard using
+// them was last sent.
+const DefaultTTL = 7 * 24 * time.Hour
+
+// ErrExpired is returned when a token is unknown or its payload expired.
+var ErrExpired = errors.New("callback payload expired")
+
+// entry is a persisted payload.
+type entry struct {
+	Data    string    `json:"data"`
+	Expires time.Time `json:"expires"`
+}
+
+// Registry maps long callback data to tokens.
+// Tokens are derived from the data, so the same data always gets the same
+// token and sending a keyboard again only extends its expiry.
+type Registry struct {
+	store   store.Store          // Backing store of tokens
+	ttl     time.Duration        // Validity of tokens after their last use
+	written map[string]time.Time // Expiry of recently written tokens, to skip rewriting them
+	mu      sync.Mutex           // Mutex for thread-safe access
+}
+
+// New creates a registry persisting tokens in the given store, valid for ttl
+// after their last use; 0 uses DefaultTTL.
+func New(s store.Store, ttl time.Duration) *Registry {
+	if ttl <= 0 {
+		ttl = DefaultTTL
+	}
+	return &Registry{
+		store:   s,
+		ttl:     ttl,
+		written: make(map[string]time.Time),
+	}
+}
+
+// SetStore replaces the backing store, e.g. with a store shared by all
+// instances of a bot, so any instance resolves tokens of the others.
+func (r *Registry) SetStore(s store.Store) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.store = s
+	r.written = make(map[string]time.Time)
+}
+
+// SetTTL sets how long tokens stay valid after their last use.
+func (r *Registry) SetTTL(ttl time.Duration) {
+	if ttl <= 0 {
+		ttl = DefaultTTL
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.ttl = ttl
+}
+
+// IsToken reports whether callback data is a token.
+func IsToken(data string) bool {
+	return strings.HasPrefix(data, Prefix)
+}
+
+// Token returns the token of data, without storing it.
+func Token(data string) string {
+	sum := sha256.Sum256([]byte(data))
+	return Prefix + base64.RawURLEncoding.EncodeToString(sum[:tokenSize])
+}
+
+// Shorten returns data unchanged if it fits into callback data, and a token
+// standing for it otherwise. The token is stored, or its expiry extended.
+func (r *Registry) Shorten(ctx context.Context, data string) (string, error) {
+	if len(data) <= MaxDataLength && !IsToken(data) {
+		return data, nil
+	}
+	return r.Put(ctx, data)
+}
+
+// Put stores data under its token, or extends the token's expiry, and returns
+// the token. Unlike Shorten, it tokenizes short data too, e.g. to keep room
+// for a signature.
+func (r *Registry) Put(ctx context.Context, data string) (string, error) {
+	token := Token(data)
+	now := time.Now()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	// Tokens written recently keep most of their validity; don't rewrite them for every keyboard
+	if expires, ok := r.written[token]; ok && expires.Sub(now) > r.ttl/2 {
+		return token, nil
+	}
+	e := entry{Data: data, Expires: now.Add(r.ttl)}
+	if err := store.PutJSON(ctx, r.store, keyPrefix+token, e); err != nil {
+		return "", err
+	}
+	r.written[token] = e.Expires
+	return token, nil
+}
+
+// Resolve returns the data a token stands for. Data that isn't a token is
+// returned unchanged. Returns ErrExpired for unknown or expired tokens.
+func (r *Registry) Resolve(ctx context.Context, data string) (string, error) {
+	if !IsToken(data) {
+		return data, nil
+	}
+	r.mu.Lock()
+	st := r.store
+	r.mu.Unlock()
+
+	var e entry
+	if err := store.GetJSON(ctx, st, keyPrefix+data, &e); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return "", ErrExpired
+		}
+		return "", err
+	}
+	if time.Now().After(e.Expires) {
+		return "", ErrExpired
+	}
+	return e.Data, nil
+}
+
+// Prune deletes expired tokens and returns how many were deleted.
+func (r *Registry) Prune(ctx context.Context) (int, error) {
+	r.mu.Lock()
+	st := r.store
+	now := time.Now()
+	for token, expires := range r.written {
+		if now.After(expires) {
+			delete(r.written, token)
+		}
+	}
+	r.mu.Unlock()
+
+	keys, err := st.List(ctx, keyPrefix)
+	if err != nil {
+		return 0, err
+	}
+	pruned := 0
+	for _, key := range keys {
+		var e entry
+		if err := store.GetJSON(ctx, st, key, &e); err != nil {
+			continue
+		}
+		if now.After(e.Expires) {
+			if err := st.Delete(ctx, key); err != nil {
+				return pruned, err
+			}
+			pruned++
+		}
+	}
+	return pruned, nil
+}
@@ -0,0 +1,40 @@
+package tgwrapper
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/0xVanfer/tg-listener/payload"
+	"github.com/0xVanfer/tg-listener/scheduler"
+)
+
+// payloadPruneJob is the ID of the recurring job deleting expired callback payloads.
+const payloadPruneJob = "_payloads"
+
+// Payloads returns the registry storing callback data over Telegram's 64-byte
+// limit behind short tokens. Keyboards are shortened when sent and tokens are
+// resolved before handlers run, so bots rarely need it directly.
+func (w *Wrapper) Payloads() *payload.Registry {
+	return w.payloads
+}
+
+// prunePayloads deletes expired callback payloads from the store.
+func (w *Wrapper) prunePayloads(ctx context.Context) {
+	n, err := w.payloads.Prune(ctx)
+	if err != nil {
+		log.Printf("[Payloads] Failed to prune: %v", err)
+		return
+	}
+	if n > 0 && w.Config().Bot.Debug {
+		log.Printf("[Payloads] Pruned %d expired callback payloads", n)
+	}
+}
+
+// setupPayloads shortens long callback data of sent keyboards, resolves
+// tokens of pressed buttons, and prunes expired tokens hourly.
+func (w *Wrapper) setupPayloads() {
+	w.bot.SetPayloadStore(w.payloads)
+	w.router.SetPayloadResolver(w.payloads.Resolve)
+	w.scheduler.Add(payloadPruneJob, scheduler.Every(time.Hour), w.prunePayloads)
+}
@@ -0,0 +1,79 @@
+package tgwrapper
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/0xVanfer/tg-listener/config"
+	"github.com/0xVanfer/tg-listener/conv"
+	"github.com/0xVanfer/tg-listener/core"
+	"github.com/0xVanfer/tg-listener/handler"
+)
+
+// OnPreCheckout sets a function confirming orders before payment, e.g. that
+// the item is still in stock. Returning an error declines the payment with
+// its text; a payment step then moves to its failure_step.
+// Telegram cancels the payment if orders aren't confirmed within 10 seconds.
+func (w *Wrapper) OnPreCheckout(fn handler.PreCheckoutHandler) {
+	w.router.SetPreCheckoutHandler(fn)
+}
+
+// OnShippingQuery sets a function returning the shipping options for the
+// address of a flexible invoice (core.Invoice.IsFlexible).
+func (w *Wrapper) OnShippingQuery(fn handler.ShippingHandler) {
+	w.router.SetShippingHandler(fn)
+}
+
+// OnPayment sets a function called for successful payments of invoices sent
+// from code, with Bot().SendInvoice. Payments of payment steps complete the step.
+func (w *Wrapper) OnPayment(fn handler.PaymentHandler) {
+	w.router.SetPaymentHandler(fn)
+}
+
+// sendStepInvoice sends the invoice of a payment step, rendering its title,
+// description, and amount. An invoice that can't be sent fails the payment.
+// Previews get a placeholder instead of a payable invoice.
+func (w *Wrapper) sendStepInvoice(ctx context.Context, c *conv.Conversation, step *config.StepConfig) {
+	p := step.Payment
+	title := w.flowEngine.RenderText(ctx, c, p.Title)
+	description := w.flowEngine.RenderText(ctx, c, p.Description)
+	if description == "" {
+		description = title // Telegram requires a description
+	}
+	rendered := strings.TrimSpace(w.flowEngine.RenderText(ctx, c, p.Amount))
+	amount, err := strconv.Atoi(rendered)
+	if err != nil || amount <= 0 {
+		w.router.FailPayment(ctx, c, fmt.Errorf("invalid invoice amount %q", rendered))
+		return
+	}
+	if c.IsPreview() {
+		b := core.NewBuilder().Text("🧪 Invoice ").Bold(title).Text(fmt.Sprintf(" for %d %s (smallest units) is not sent in previews.", amount, p.Currency))
+		text, entities := b.Build()
+		_, _ = w.bot.SendMessage(ctx, c.ChatID, c.TopicID, text, entities...)
+		return
+	}
+	payload, err := handler.NewInvoicePayload(c, amount, p.Currency)
+	if err != nil {
+		w.router.FailPayment(ctx, c, err)
+		return
+	}
+
+	_, err = w.bot.SendInvoice(ctx, c.ChatID, c.TopicID, core.Invoice{
+		Title:         title,
+		Description:   description,
+		Payload:       payload,
+		ProviderToken: p.ProviderToken,
+		Currency:      p.Currency,
+		Prices:        core.Price(title, amount),
+		PhotoURL:      p.PhotoURL,
+		NeedName:      p.NeedName,
+		NeedEmail:     p.NeedEmail,
+		NeedPhone:     p.NeedPhone,
+		NeedShipping:  p.NeedShipping,
+	})
+	if err != nil {
+		w.router.FailPayment(ctx, c, fmt.Errorf("invoice not sent: %w", err))
+	}
+}
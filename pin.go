@@ -0,0 +1,92 @@
+package tgwrapper
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/0xVanfer/tg-listener/config"
+	"github.com/0xVanfer/tg-listener/conv"
+	"github.com/0xVanfer/tg-listener/core"
+)
+
+// pinRetryInterval is how long a chat where the bot lacked the right to pin
+// messages is skipped before pinning is tried again.
+const pinRetryInterval = 10 * time.Minute
+
+// pinState tracks the messages pinned for menus and step prompts.
+type pinState struct {
+	pinned map[chatMessage]bool // Messages pinned by the bot
+	denied map[int64]time.Time  // When pinning last failed for lack of rights, by chat ID
+	mu     sync.Mutex           // Mutex for thread-safe pin access
+}
+
+// syncPin pins or unpins a message so its pinned state matches want.
+// Pins are silent. Chats where the bot lacks the right to pin are skipped for
+// pinRetryInterval, leaving the message unpinned.
+func (w *Wrapper) syncPin(ctx context.Context, chatID int64, messageID int, want bool) {
+	if messageID == 0 {
+		return
+	}
+	key := chatMessage{chatID, messageID}
+
+	w.pins.mu.Lock()
+	pinned := w.pins.pinned[key]
+	deniedAt, denied := w.pins.denied[chatID]
+	w.pins.mu.Unlock()
+	if want == pinned || (want && denied && time.Since(deniedAt) < pinRetryInterval) {
+		return
+	}
+
+	var err error
+	if want {
+		err = w.bot.PinMessage(ctx, chatID, messageID, true)
+	} else {
+		err = w.bot.UnpinMessage(ctx, chatID, messageID)
+	}
+
+	w.pins.mu.Lock()
+	defer w.pins.mu.Unlock()
+	if core.IsPermissionError(err) {
+		if w.pins.denied == nil {
+			w.pins.denied = make(map[int64]time.Time)
+		}
+		w.pins.denied[chatID] = time.Now()
+	}
+	if !want {
+		// Forget the pin even if unpinning failed, e.g. because the message is gone
+		delete(w.pins.pinned, key)
+		return
+	}
+	if err != nil {
+		return
+	}
+	if w.pins.pinned == nil {
+		w.pins.pinned = make(map[chatMessage]bool)
+	}
+	w.pins.pinned[key] = true
+	delete(w.pins.denied, chatID)
+}
+
+// pinStepPrompt pins a conversation's keyboard message if its step or flow has
+// pin, and unpins it and earlier messages of the conversation otherwise.
+func (w *Wrapper) pinStepPrompt(ctx context.Context, c *conv.Conversation, step *config.StepConfig) {
+	want := step.Pin
+	for _, flowID := range []string{c.FlowID, c.RootFlowID()} {
+		if flow := w.Config().GetFlow(flowID); flow != nil && flow.Pin {
+			want = true
+		}
+	}
+	for _, id := range c.IntermediateMessages() {
+		w.syncPin(ctx, c.ChatID, id, false)
+	}
+	w.syncPin(ctx, c.ChatID, c.KeyboardMsgID, want)
+}
+
+// unpinConversation unpins the messages of an ended conversation.
+func (w *Wrapper) unpinConversation(ctx context.Context, c *conv.Conversation) {
+	for _, id := range c.IntermediateMessages() {
+		w.syncPin(ctx, c.ChatID, id, false)
+	}
+	w.syncPin(ctx, c.ChatID, c.KeyboardMsgID, false)
+}
@@ -0,0 +1,66 @@
+package tgwrapper
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/mymmrac/telego"
+
+	"github.com/0xVanfer/tg-listener/conv"
+	"github.com/0xVanfer/tg-listener/core"
+	"github.com/0xVanfer/tg-listener/eventlog"
+)
+
+// MarkPreviewSafe marks step handlers, compute functions, and LLM completers
+// as free of side effects, so they also run in flow previews. Others are
+// skipped in previews.
+func (w *Wrapper) MarkPreviewSafe(names ...string) {
+	w.flowEngine.MarkPreviewSafe(names...)
+}
+
+// StartPreview runs a flow for a user as a sandbox and shows its first step,
+// so config authors can click through a new flow before exposing it.
+// Role, credit, and quota checks don't apply, step handlers, compute
+// functions, and LLM completers not marked safe with MarkPreviewSafe are
+// skipped, "chat.*" values stay in the conversation, and the start and end
+// callbacks aren't called. When the flow reaches its end, the collected data is shown.
+func (w *Wrapper) StartPreview(ctx context.Context, userID, chatID int64, topicID int, flowID string) (*conv.Conversation, error) {
+	flow := w.Config().ResolveFlow(chatID, flowID)
+	if flow == nil {
+		return nil, fmt.Errorf("flow %s does not exist", flowID)
+	}
+
+	c, err := w.convManager.StartPreview(ctx, userID, chatID, topicID, flowID, flow.InitialStep, flow.TTL)
+	if err != nil {
+		return nil, err
+	}
+	for key, value := range w.Config().FlowParams(chatID, flowID) {
+		c.Set(key, value)
+	}
+	if err := w.convManager.Save(ctx, c); err != nil {
+		return nil, err
+	}
+
+	w.recordFlowEvent(ctx, eventlog.TypeFlowStarted, c, "preview")
+	return c, w.showStepPrompt(ctx, c)
+}
+
+// handlePreviewCommand runs "/preview <flow>" for operators.
+func (w *Wrapper) handlePreviewCommand(ctx context.Context, msg telego.Message, command string, args []string) error {
+	if len(args) != 1 {
+		text, entities := core.NewBuilder().Text("Usage: ").Code("/" + command + " <flow>").Build()
+		_, err := w.bot.SendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, text, entities...)
+		return err
+	}
+
+	b := core.NewBuilder().Text("🧪 Previewing ").Code(args[0]).Text(". Step handlers not marked safe are skipped.")
+	text, entities := b.Build()
+	if _, err := w.bot.SendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, text, entities...); err != nil {
+		return err
+	}
+	if _, err := w.StartPreview(ctx, msg.From.ID, msg.Chat.ID, msg.MessageThreadID, args[0]); err != nil {
+		_, serr := w.bot.SendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "❌ "+err.Error())
+		return serr
+	}
+	return nil
+}
@@ -0,0 +1,180 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/0xVanfer/tg-listener/config"
+	"github.com/0xVanfer/tg-listener/conv"
+)
+
+// DefaultTimeout is the default timeout of requests of HTTP JSON lists.
+const DefaultTimeout = 10 * time.Second
+
+// maxResponseSize is the largest response of an HTTP JSON list, in bytes.
+const maxResponseSize = 4 << 20
+
+// HTTPJSON lists buttons from the JSON response of an HTTP GET request,
+// picking the items and their labels and callback data with JSONPaths.
+type HTTPJSON struct {
+	URL      string            // URL requested with GET
+	Headers  map[string]string // Request headers, e.g. Authorization
+	Items    string            // JSONPath of the items in the response; defaults to "$[*]"
+	Text     string            // JSONPath of the label within an item, e.g. "$.name"
+	Callback string            // JSONPath of the callback data within an item; defaults to the label
+	TTL      time.Duration     // How long responses are cached by URL and headers; 0 requests on every call
+	Client   *http.Client      // Client sending requests; defaults to one with DefaultTimeout
+
+	// Render renders templates in the URL and header values with the
+	// conversation's data. They are used as they are if nil.
+	Render func(ctx context.Context, c *conv.Conversation, text string) string
+
+	mu    sync.Mutex            // Protects cache
+	cache map[string]cacheEntry // Lists by request
+}
+
+// List requests the URL and returns a button for each item with a label.
+// Responses with a status outside 2xx are errors. It is a ListFunc; the
+// bound argument values are not used.
+func (h *HTTPJSON) List(ctx context.Context, c *conv.Conversation, _ []interface{}) ([]config.ButtonData, error) {
+	render := h.Render
+	if render == nil {
+		render = func(_ context.Context, _ *conv.Conversation, text string) string { return text }
+	}
+	url := render(ctx, c, h.URL)
+	headers := make(map[string]string, len(h.Headers))
+	for k, v := range h.Headers {
+		headers[k] = render(ctx, c, v)
+	}
+
+	key := requestKey(url, headers)
+	if h.TTL > 0 {
+		h.mu.Lock()
+		entry, ok := h.cache[key]
+		h.mu.Unlock()
+		if ok && time.Now().Before(entry.expires) {
+			return entry.items, nil
+		}
+	}
+
+	buttons, err := h.fetch(ctx, url, headers)
+	if err != nil {
+		return nil, err
+	}
+	if h.TTL > 0 {
+		now := time.Now()
+		h.mu.Lock()
+		if h.cache == nil {
+			h.cache = make(map[string]cacheEntry)
+		}
+		for k, e := range h.cache {
+			if !now.Before(e.expires) {
+				delete(h.cache, k)
+			}
+		}
+		h.cache[key] = cacheEntry{items: buttons, expires: now.Add(h.TTL)}
+		h.mu.Unlock()
+	}
+	return buttons, nil
+}
+
+// fetch requests a URL and picks the buttons from its response.
+func (h *HTTPJSON) fetch(ctx context.Context, url string, headers map[string]string) ([]config.ButtonData, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	client := h.Client
+	if client == nil {
+		client = &http.Client{Timeout: DefaultTimeout}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("request failed: %s", resp.Status)
+	}
+
+	// Keep numbers as written, e.g. prices, instead of converting them to floats
+	decoder := json.NewDecoder(io.LimitReader(resp.Body, maxResponseSize))
+	decoder.UseNumber()
+	var doc interface{}
+	if err := decoder.Decode(&doc); err != nil {
+		return nil, fmt.Errorf("invalid JSON response: %w", err)
+	}
+
+	itemsPath := h.Items
+	if itemsPath == "" {
+		itemsPath = "$[*]"
+	}
+	items, err := queryPath(doc, itemsPath)
+	if err != nil {
+		return nil, err
+	}
+	callbackPath := h.Callback
+	if callbackPath == "" {
+		callbackPath = h.Text
+	}
+
+	buttons := make([]config.ButtonData, 0, len(items))
+	for _, item := range items {
+		text, err := firstValue(item, h.Text)
+		if err != nil {
+			return nil, err
+		}
+		if text == "" {
+			continue
+		}
+		callback, err := firstValue(item, callbackPath)
+		if err != nil {
+			return nil, err
+		}
+		buttons = append(buttons, config.ButtonData{Text: text, Callback: callback})
+	}
+	return buttons, nil
+}
+
+// Invalidate drops the cached responses.
+func (h *HTTPJSON) Invalidate() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	clear(h.cache)
+}
+
+// firstValue returns the first value of an item matching a path, formatted as text.
+func firstValue(item interface{}, path string) (string, error) {
+	values, err := queryPath(item, path)
+	if err != nil || len(values) == 0 {
+		return "", err
+	}
+	return formatValue(values[0]), nil
+}
+
+// requestKey identifies a request by its URL and headers, for caching.
+func requestKey(url string, headers map[string]string) string {
+	keys := make([]string, 0, len(headers))
+	for k := range headers {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var b strings.Builder
+	b.WriteString(url)
+	for _, k := range keys {
+		b.WriteString("\n" + k + ": " + headers[k])
+	}
+	return b.String()
+}
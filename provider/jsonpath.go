@@ -0,0 +1,128 @@
+package provider
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// pathSegment is a step of a JSONPath: a member name, an array index, or a
+// wildcard matching every member or element.
+type pathSegment struct {
+	name     string
+	index    int
+	isIndex  bool
+	wildcard bool
+}
+
+// parsePath parses the JSONPath subset providers support: "$" followed by
+// ".name", "['name']", "[n]" (negative counts from the end), ".*", and "[*]".
+func parsePath(path string) ([]pathSegment, error) {
+	rest, ok := strings.CutPrefix(path, "$")
+	if !ok {
+		return nil, fmt.Errorf("path %q does not start with $", path)
+	}
+	var segments []pathSegment
+	for rest != "" {
+		switch rest[0] {
+		case '.':
+			rest = rest[1:]
+			end := strings.IndexAny(rest, ".[")
+			if end < 0 {
+				end = len(rest)
+			}
+			name := rest[:end]
+			if name == "" {
+				return nil, fmt.Errorf("path %q has an empty member name", path)
+			}
+			segments = append(segments, pathSegment{name: name, wildcard: name == "*"})
+			rest = rest[end:]
+		case '[':
+			end := strings.IndexByte(rest, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("path %q has an unclosed bracket", path)
+			}
+			inner := rest[1:end]
+			rest = rest[end+1:]
+			switch {
+			case inner == "*":
+				segments = append(segments, pathSegment{wildcard: true})
+			case len(inner) >= 2 && (inner[0] == '\'' || inner[0] == '"') && inner[len(inner)-1] == inner[0]:
+				segments = append(segments, pathSegment{name: inner[1 : len(inner)-1]})
+			default:
+				index, err := strconv.Atoi(inner)
+				if err != nil {
+					return nil, fmt.Errorf("path %q has an invalid index %q", path, inner)
+				}
+				segments = append(segments, pathSegment{index: index, isIndex: true})
+			}
+		default:
+			return nil, fmt.Errorf("path %q has an unexpected %q", path, rest[0])
+		}
+	}
+	return segments, nil
+}
+
+// queryPath returns the values of a decoded JSON document matching a path.
+// Wildcards match object members in key order.
+func queryPath(doc interface{}, path string) ([]interface{}, error) {
+	segments, err := parsePath(path)
+	if err != nil {
+		return nil, err
+	}
+	values := []interface{}{doc}
+	for _, seg := range segments {
+		var next []interface{}
+		for _, v := range values {
+			switch v := v.(type) {
+			case map[string]interface{}:
+				if seg.wildcard {
+					keys := make([]string, 0, len(v))
+					for k := range v {
+						keys = append(keys, k)
+					}
+					sort.Strings(keys)
+					for _, k := range keys {
+						next = append(next, v[k])
+					}
+				} else if member, ok := v[seg.name]; ok && !seg.isIndex {
+					next = append(next, member)
+				}
+			case []interface{}:
+				switch {
+				case seg.wildcard:
+					next = append(next, v...)
+				case seg.isIndex:
+					i := seg.index
+					if i < 0 {
+						i += len(v)
+					}
+					if i >= 0 && i < len(v) {
+						next = append(next, v[i])
+					}
+				}
+			}
+		}
+		values = next
+	}
+	return values, nil
+}
+
+// formatValue formats a JSON value as button text: strings and numbers as
+// they are, null as empty, and objects and arrays as JSON.
+func formatValue(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	case bool:
+		return strconv.FormatBool(v)
+	}
+	data, _ := json.Marshal(v)
+	return string(data)
+}
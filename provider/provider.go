@@ -0,0 +1,177 @@
+// Package provider adapts lists of items, e.g. the rows of a database table,
+// to dynamic keyboard providers that page through them, cache them, and bind
+// their parameters from conversation data.
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/0xVanfer/tg-listener/config"
+	"github.com/0xVanfer/tg-listener/conv"
+	"github.com/0xVanfer/tg-listener/core"
+)
+
+// DefaultPageSize is the default number of items on a page.
+const DefaultPageSize = 10
+
+// CallbackPage is the callback data prefix of page buttons. The wrapper
+// shows the requested page of the step's keyboard when they are pressed.
+const CallbackPage = "kbpage:"
+
+// ListFunc lists all items of a keyboard, given the values of the
+// conversation data keys it is bound to, in order. Missing keys are nil.
+type ListFunc func(ctx context.Context, c *conv.Conversation, args []interface{}) ([]config.ButtonData, error)
+
+// Options configure a paginated provider.
+type Options struct {
+	Args     []string      // Conversation data keys whose values are passed to the list, in order
+	PageSize int           // Items per page; defaults to DefaultPageSize
+	TTL      time.Duration // How long a list is cached for the same argument values; 0 lists on every render
+}
+
+// cacheEntry is a cached list.
+type cacheEntry struct {
+	items   []config.ButtonData
+	expires time.Time
+}
+
+// Paginated is a keyboard provider showing a page of a list at a time, with
+// previous and next buttons when the list has more than one page.
+type Paginated struct {
+	name string
+	list ListFunc
+	opts Options
+
+	mu    sync.Mutex            // Protects cache
+	cache map[string]cacheEntry // Lists by argument values
+}
+
+// New creates a paginated provider for the list. name must be the name the
+// provider is registered under, as it keys the page shown in the conversation.
+//
+//	products := provider.New("products", provider.SQL(db,
+//	    "SELECT name, id FROM products WHERE category = $1 ORDER BY name"),
+//	    provider.Options{Args: []string{"category"}, PageSize: 8, TTL: time.Minute})
+//	wrapper.RegisterFallibleKeyboardProvider("products", products.Provide)
+func New(name string, list ListFunc, opts Options) *Paginated {
+	if opts.PageSize <= 0 {
+		opts.PageSize = DefaultPageSize
+	}
+	return &Paginated{
+		name:  name,
+		list:  list,
+		opts:  opts,
+		cache: make(map[string]cacheEntry),
+	}
+}
+
+// Provide returns the buttons of the conversation's current page, followed
+// by the page buttons. It is a conv.FallibleKeyboardProvider.
+func (p *Paginated) Provide(ctx context.Context, c *conv.Conversation) ([]config.ButtonData, error) {
+	args := make([]interface{}, len(p.opts.Args))
+	for i, key := range p.opts.Args {
+		args[i], _ = c.Get(key)
+	}
+	items, err := p.items(ctx, c, args)
+	if err != nil {
+		return nil, err
+	}
+
+	pages := (len(items) + p.opts.PageSize - 1) / p.opts.PageSize
+	if pages <= 1 {
+		return items, nil
+	}
+	// Pages past the end, e.g. after the list shrank, show the last one
+	page := min(max(c.GetInt(PageKey(p.name)), 1), pages)
+	start := (page - 1) * p.opts.PageSize
+	end := min(start+p.opts.PageSize, len(items))
+
+	buttons := append([]config.ButtonData(nil), items[start:end]...)
+	strs := core.StringsFrom(ctx)
+	if page > 1 {
+		buttons = append(buttons, config.ButtonData{Text: strs.PrevPage, Callback: PageCallback(p.name, page-1)})
+	}
+	buttons = append(buttons, config.ButtonData{
+		Text:     fmt.Sprintf(strs.PageIndicator, page, pages),
+		Callback: PageCallback(p.name, page),
+	})
+	if page < pages {
+		buttons = append(buttons, config.ButtonData{Text: strs.NextPage, Callback: PageCallback(p.name, page+1)})
+	}
+	return buttons, nil
+}
+
+// items returns the list for the argument values, from the cache if it's fresh.
+func (p *Paginated) items(ctx context.Context, c *conv.Conversation, args []interface{}) ([]config.ButtonData, error) {
+	if p.opts.TTL <= 0 {
+		return p.list(ctx, c, args)
+	}
+	key, err := json.Marshal(args)
+	if err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	entry, ok := p.cache[string(key)]
+	p.mu.Unlock()
+	if ok && time.Now().Before(entry.expires) {
+		return entry.items, nil
+	}
+
+	items, err := p.list(ctx, c, args)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	p.mu.Lock()
+	// Drop expired lists, so argument values seen once don't pile up
+	for k, e := range p.cache {
+		if !now.Before(e.expires) {
+			delete(p.cache, k)
+		}
+	}
+	p.cache[string(key)] = cacheEntry{items: items, expires: now.Add(p.opts.TTL)}
+	p.mu.Unlock()
+	return items, nil
+}
+
+// Invalidate drops the cached lists, e.g. after the table changed.
+func (p *Paginated) Invalidate() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	clear(p.cache)
+}
+
+// PageKey returns the conversation data key holding the page shown by a provider.
+func PageKey(name string) string {
+	return "_page_" + name
+}
+
+// PageCallback returns the callback data that shows a page (1-based) of a provider.
+func PageCallback(name string, page int) string {
+	return CallbackPage + name + ":" + strconv.Itoa(page)
+}
+
+// ParsePageCallback extracts the provider name and page number from page callback data.
+// Returns false if data is not valid page callback data.
+func ParsePageCallback(data string) (string, int, bool) {
+	rest, ok := strings.CutPrefix(data, CallbackPage)
+	if !ok {
+		return "", 0, false
+	}
+	i := strings.LastIndex(rest, ":")
+	if i < 0 {
+		return "", 0, false
+	}
+	page, err := strconv.Atoi(rest[i+1:])
+	if err != nil || page < 1 {
+		return "", 0, false
+	}
+	return rest[:i], page, true
+}
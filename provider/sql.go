@@ -0,0 +1,54 @@
+package provider
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/0xVanfer/tg-listener/config"
+	"github.com/0xVanfer/tg-listener/conv"
+)
+
+// SQL returns a list running a query with the bound argument values as its
+// parameters, in order, so placeholders follow the driver ("$1", "?", ...).
+// Each row is a button: its first column is the label, the second the
+// callback data, and a third, if any, a URL. Rows of one column use the label
+// as callback data. NULL columns are empty.
+func SQL(db *sql.DB, query string) ListFunc {
+	return func(ctx context.Context, _ *conv.Conversation, args []interface{}) ([]config.ButtonData, error) {
+		rows, err := db.QueryContext(ctx, query, args...)
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+
+		columns, err := rows.Columns()
+		if err != nil {
+			return nil, err
+		}
+		if len(columns) == 0 || len(columns) > 3 {
+			return nil, fmt.Errorf("query returns %d columns, want 1 to 3", len(columns))
+		}
+
+		var buttons []config.ButtonData
+		values := make([]sql.NullString, len(columns))
+		dest := make([]interface{}, len(columns))
+		for i := range values {
+			dest[i] = &values[i]
+		}
+		for rows.Next() {
+			if err := rows.Scan(dest...); err != nil {
+				return nil, err
+			}
+			b := config.ButtonData{Text: values[0].String, Callback: values[0].String}
+			if len(values) > 1 {
+				b.Callback = values[1].String
+			}
+			if len(values) > 2 {
+				b.URL = values[2].String
+			}
+			buttons = append(buttons, b)
+		}
+		return buttons, rows.Err()
+	}
+}
@@ -0,0 +1,90 @@
+package tgwrapper
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"sync"
+
+	"github.com/0xVanfer/tg-listener/config"
+	"github.com/0xVanfer/tg-listener/conv"
+	"github.com/0xVanfer/tg-listener/eventlog"
+	"github.com/0xVanfer/tg-listener/provider"
+)
+
+// codeProviders holds the names of keyboard providers registered from code,
+// which take precedence over providers declared in configuration.
+type codeProviders struct {
+	names map[string]bool // Provider names
+	mu    sync.RWMutex    // Mutex for thread-safe operations
+}
+
+// add records a provider registered from code.
+func (p *codeProviders) add(name string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.names == nil {
+		p.names = make(map[string]bool)
+	}
+	p.names[name] = true
+}
+
+// has returns true if a provider was registered from code.
+func (p *codeProviders) has(name string) bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.names[name]
+}
+
+// OnProviderError sets a callback function that is called when a keyboard provider
+// fails: it returned an error, panicked, timed out, or its circuit breaker is open.
+// The step still shows the provider's latest result or the keyboard's fallback_buttons.
+func (w *Wrapper) OnProviderError(fn conv.ProviderErrorFunc) {
+	w.onProviderError = fn
+}
+
+// providerError records a keyboard provider failure in the event log, logs it in
+// debug mode, and passes it to the user callback.
+func (w *Wrapper) providerError(ctx context.Context, c *conv.Conversation, provider string, err error) {
+	w.recordEvent(ctx, eventlog.Event{
+		Type:   eventlog.TypeError,
+		UserID: c.UserID,
+		ChatID: c.ChatID,
+		FlowID: c.FlowID,
+		StepID: c.StepID,
+		Detail: "keyboard:" + provider,
+		Error:  err.Error(),
+	})
+	if w.Config().Bot.Debug {
+		log.Printf("[Provider] %s failed: %v", provider, err)
+	}
+	if w.onProviderError != nil {
+		w.onProviderError(ctx, c, provider, err)
+	}
+}
+
+// installProviders registers the keyboard providers declared in bot.providers,
+// except those registered from code. Reloads replace them, dropping cached responses.
+func (w *Wrapper) installProviders(cfg *config.Config) {
+	for name, pc := range cfg.Bot.Providers {
+		if w.providers.has(name) {
+			continue
+		}
+		timeout := pc.Timeout
+		if timeout == 0 {
+			timeout = provider.DefaultTimeout
+		}
+		source := &provider.HTTPJSON{
+			URL:      pc.URL,
+			Headers:  pc.Headers,
+			Items:    pc.GetItems(),
+			Text:     pc.Text,
+			Callback: pc.Callback,
+			TTL:      pc.CacheTTL,
+			Client:   &http.Client{Timeout: timeout},
+			Render:   w.flowEngine.RenderText,
+		}
+		p := provider.New(name, source.List, provider.Options{PageSize: pc.PageSize})
+		w.flowEngine.RegisterFallibleKeyboardProvider(name, p.Provide)
+	}
+}
@@ -0,0 +1,92 @@
+package tgwrapper
+
+import (
+	"context"
+	"log"
+
+	"github.com/0xVanfer/tg-listener/config"
+	"github.com/0xVanfer/tg-listener/conv"
+	"github.com/0xVanfer/tg-listener/quota"
+)
+
+// Quotas returns the usage limiter enforcing cooldowns and daily limits.
+// Handlers can use it for their own scopes:
+//
+//	d, _ := wrapper.Quotas().Allow(ctx, "price_api", userID, quota.Limits{Cooldown: 10 * time.Second})
+func (w *Wrapper) Quotas() *quota.Limiter {
+	w.storeMu.RLock()
+	defer w.storeMu.RUnlock()
+	return w.quotas
+}
+
+// setupQuotas enforces configured command cooldowns and daily limits in the router.
+func (w *Wrapper) setupQuotas() {
+	w.router.SetUsageCheck(w.checkCommandUsage)
+}
+
+// checkCommandUsage applies a command's cooldown and daily limit. Operators are exempt.
+func (w *Wrapper) checkCommandUsage(ctx context.Context, userID int64, command string) (bool, string) {
+	if w.Config().Bot == nil || w.IsOperator(ctx, userID) {
+		return true, ""
+	}
+	cmd := w.Config().Bot.GetCommand(command)
+	if cmd == nil {
+		return true, ""
+	}
+
+	limits := quota.Limits{Cooldown: cmd.Cooldown, DailyLimit: cmd.DailyLimit}
+	d, err := w.Quotas().Allow(ctx, "cmd:"+command, userID, limits)
+	if err != nil || d.Allowed {
+		// Fail open: a store error should not lock users out
+		return true, ""
+	}
+	return false, w.limitText(ctx, userID, 0, d, limits)
+}
+
+// checkFlowQuota applies a flow's cooldown and daily limit, returning a
+// FlowDeniedError when the user has to wait. Operators are exempt.
+// Returns true if a use was recorded, which refundFlowQuota gives back if
+// the flow then fails to start.
+func (w *Wrapper) checkFlowQuota(ctx context.Context, userID, chatID int64, flow *config.FlowConfig) (bool, error) {
+	limits := quota.Limits{Cooldown: flow.Cooldown, DailyLimit: flow.DailyLimit}
+	if limits.IsZero() || w.IsOperator(ctx, userID) {
+		return false, nil
+	}
+
+	d, err := w.Quotas().Allow(ctx, "flow:"+flow.ID, userID, limits)
+	if err != nil {
+		// Fail open: a store error should not lock users out
+		return false, nil
+	}
+	if d.Allowed {
+		return true, nil
+	}
+	return false, &FlowDeniedError{FlowID: flow.ID, Text: w.limitText(ctx, userID, chatID, d, limits)}
+}
+
+// refundFlowQuota gives back a use of a flow recorded by checkFlowQuota.
+func (w *Wrapper) refundFlowQuota(ctx context.Context, userID int64, flow *config.FlowConfig, recorded bool) {
+	if !recorded {
+		return
+	}
+	if err := w.Quotas().Refund(ctx, "flow:"+flow.ID, userID); err != nil {
+		log.Printf("[Quota] Failed to refund flow %s for user %d: %v", flow.ID, userID, err)
+	}
+}
+
+// limitText renders the reply for a denied use.
+func (w *Wrapper) limitText(ctx context.Context, userID, chatID int64, d quota.Decision, limits quota.Limits) string {
+	bot := w.Config().Bot
+	if bot == nil {
+		bot = config.NewDefaultBotConfig()
+	}
+	text := bot.GetCooldownText()
+	if d.Reason == quota.ReasonDailyLimit {
+		text = bot.GetDailyLimitText()
+	}
+
+	c := conv.NewConversation(userID, chatID, 0, "", "", 0)
+	c.Set("retry_in", quota.FormatDuration(d.RetryAfter))
+	c.Set("limit", limits.DailyLimit)
+	return w.flowEngine.RenderText(ctx, c, text)
+}
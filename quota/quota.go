@@ -0,0 +1,178 @@
+// Package quota enforces per-user cooldowns and daily usage limits with
+// counters persisted in a store, so limits survive restarts.
+package quota
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/0xVanfer/tg-listener/store"
+)
+
+// keyPrefix is the store key prefix for usage counters.
+const keyPrefix = "quota:"
+
+// dayLayout formats the UTC day a daily counter belongs to.
+const dayLayout = "2006-01-02"
+
+// Limits defines the usage limits for a scope.
+type Limits struct {
+	Cooldown   time.Duration // Minimum time between uses; 0 disables
+	DailyLimit int           // Maximum uses per UTC day; 0 disables
+}
+
+// IsZero returns true if no limit is set.
+func (l Limits) IsZero() bool {
+	return l.Cooldown <= 0 && l.DailyLimit <= 0
+}
+
+// Reason tells which limit denied a use.
+type Reason string
+
+const (
+	// ReasonCooldown means the cooldown since the last use has not elapsed.
+	ReasonCooldown Reason = "cooldown"
+
+	// ReasonDailyLimit means the daily limit has been reached.
+	ReasonDailyLimit Reason = "daily_limit"
+)
+
+// Decision is the outcome of a usage check.
+type Decision struct {
+	Allowed    bool          // True if the use was allowed and recorded
+	Reason     Reason        // Which limit denied the use (empty if allowed)
+	RetryAfter time.Duration // How long until the next use is allowed
+	Remaining  int           // Uses left today (-1 if there is no daily limit)
+}
+
+// usage is the persisted counter for a user and scope.
+type usage struct {
+	Last  time.Time `json:"last"`
+	Day   string    `json:"day"`
+	Count int       `json:"count"`
+}
+
+// Limiter checks and records usage against limits.
+type Limiter struct {
+	store store.Store // Backing store
+	mu    sync.Mutex  // Serializes check-and-record cycles
+}
+
+// NewLimiter creates a limiter persisted in the given store.
+func NewLimiter(s store.Store) *Limiter {
+	return &Limiter{store: s}
+}
+
+// usageKey returns the store key for a user's usage in a scope.
+func usageKey(scope string, userID int64) string {
+	return keyPrefix + scope + ":" + strconv.FormatInt(userID, 10)
+}
+
+// Allow checks whether the user may use the scope now and, if so, records the use.
+// Scopes are free-form names such as "cmd:price" or "flow:report".
+func (l *Limiter) Allow(ctx context.Context, scope string, userID int64, limits Limits) (Decision, error) {
+	if limits.IsZero() {
+		return Decision{Allowed: true, Remaining: -1}, nil
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	key := usageKey(scope, userID)
+	var u usage
+	if err := store.GetJSON(ctx, l.store, key, &u); err != nil && !errors.Is(err, store.ErrNotFound) {
+		return Decision{}, err
+	}
+
+	now := time.Now().UTC()
+	today := now.Format(dayLayout)
+	if u.Day != today {
+		u.Day = today
+		u.Count = 0
+	}
+
+	if limits.Cooldown > 0 && !u.Last.IsZero() {
+		if wait := u.Last.Add(limits.Cooldown).Sub(now); wait > 0 {
+			return Decision{Reason: ReasonCooldown, RetryAfter: wait, Remaining: remaining(limits, u.Count)}, nil
+		}
+	}
+	if limits.DailyLimit > 0 && u.Count >= limits.DailyLimit {
+		tomorrow := now.Truncate(24 * time.Hour).Add(24 * time.Hour)
+		return Decision{Reason: ReasonDailyLimit, RetryAfter: tomorrow.Sub(now), Remaining: 0}, nil
+	}
+
+	u.Last = now
+	u.Count++
+	if err := store.PutJSON(ctx, l.store, key, u); err != nil {
+		return Decision{}, err
+	}
+	return Decision{Allowed: true, Remaining: remaining(limits, u.Count)}, nil
+}
+
+// Refund gives back a use recorded today by Allow, e.g. when the action it
+// allowed failed. The cooldown of the refunded use is lifted as well.
+func (l *Limiter) Refund(ctx context.Context, scope string, userID int64) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	key := usageKey(scope, userID)
+	var u usage
+	if err := store.GetJSON(ctx, l.store, key, &u); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return nil
+		}
+		return err
+	}
+	if u.Day != time.Now().UTC().Format(dayLayout) || u.Count == 0 {
+		return nil
+	}
+	u.Count--
+	u.Last = time.Time{}
+	return store.PutJSON(ctx, l.store, key, u)
+}
+
+// Reset clears a user's usage in a scope.
+func (l *Limiter) Reset(ctx context.Context, scope string, userID int64) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.store.Delete(ctx, usageKey(scope, userID))
+}
+
+// remaining returns the uses left today, or -1 without a daily limit.
+func remaining(limits Limits, count int) int {
+	if limits.DailyLimit <= 0 {
+		return -1
+	}
+	if left := limits.DailyLimit - count; left > 0 {
+		return left
+	}
+	return 0
+}
+
+// FormatDuration formats a wait time for users, e.g. "45s", "2m 30s" or "3h 5m".
+// Durations are rounded up to the next second.
+func FormatDuration(d time.Duration) string {
+	if d < time.Second {
+		d = time.Second
+	}
+	d = (d + time.Second - 1).Truncate(time.Second)
+
+	h := int(d / time.Hour)
+	m := int(d % time.Hour / time.Minute)
+	s := int(d % time.Minute / time.Second)
+	switch {
+	case h > 0 && m > 0:
+		return fmt.Sprintf("%dh %dm", h, m)
+	case h > 0:
+		return fmt.Sprintf("%dh", h)
+	case m > 0 && s > 0:
+		return fmt.Sprintf("%dm %ds", m, s)
+	case m > 0:
+		return fmt.Sprintf("%dm", m)
+	}
+	return fmt.Sprintf("%ds", s)
+}
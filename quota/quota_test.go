@@ -0,0 +1,142 @@
+package quota
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/0xVanfer/tg-listener/store"
+)
+
+func TestAllowDayRollover(t *testing.T) {
+	now := time.Now().UTC()
+	today := now.Format(dayLayout)
+	yesterday := now.AddDate(0, 0, -1).Format(dayLayout)
+	daily := Limits{DailyLimit: 3}
+
+	tests := []struct {
+		name          string
+		stored        *usage // Usage recorded before the call; nil for none
+		limits        Limits
+		wantAllowed   bool
+		wantReason    Reason
+		wantRemaining int
+		wantStored    usage // Day and count recorded after the call
+	}{
+		{
+			name:          "first use",
+			limits:        daily,
+			wantAllowed:   true,
+			wantRemaining: 2,
+			wantStored:    usage{Day: today, Count: 1},
+		},
+		{
+			name:          "below the limit today",
+			stored:        &usage{Day: today, Count: 1},
+			limits:        daily,
+			wantAllowed:   true,
+			wantRemaining: 1,
+			wantStored:    usage{Day: today, Count: 2},
+		},
+		{
+			name:          "limit reached today",
+			stored:        &usage{Day: today, Count: 3},
+			limits:        daily,
+			wantReason:    ReasonDailyLimit,
+			wantRemaining: 0,
+			wantStored:    usage{Day: today, Count: 3},
+		},
+		{
+			name:          "limit reached yesterday",
+			stored:        &usage{Day: yesterday, Count: 3},
+			limits:        daily,
+			wantAllowed:   true,
+			wantRemaining: 2,
+			wantStored:    usage{Day: today, Count: 1},
+		},
+		{
+			name:          "limit reached long ago",
+			stored:        &usage{Day: "2020-01-01", Count: 3},
+			limits:        daily,
+			wantAllowed:   true,
+			wantRemaining: 2,
+			wantStored:    usage{Day: today, Count: 1},
+		},
+		{
+			name:          "cooldown across midnight",
+			stored:        &usage{Day: yesterday, Count: 3, Last: now.Add(-time.Minute)},
+			limits:        Limits{Cooldown: time.Hour, DailyLimit: 3},
+			wantReason:    ReasonCooldown,
+			wantRemaining: 3,
+			wantStored:    usage{Day: yesterday, Count: 3},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			s := store.NewMemoryStore()
+			key := usageKey("cmd:price", 7)
+			if tt.stored != nil {
+				if err := store.PutJSON(ctx, s, key, tt.stored); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			d, err := NewLimiter(s).Allow(ctx, "cmd:price", 7, tt.limits)
+			if err != nil {
+				t.Fatalf("Allow error = %v", err)
+			}
+			if d.Allowed != tt.wantAllowed || d.Reason != tt.wantReason || d.Remaining != tt.wantRemaining {
+				t.Errorf("Allow = %+v, want allowed %v, reason %q, remaining %d", d, tt.wantAllowed, tt.wantReason, tt.wantRemaining)
+			}
+			if d.Reason == ReasonDailyLimit && (d.RetryAfter <= 0 || d.RetryAfter > 24*time.Hour) {
+				t.Errorf("RetryAfter = %v, want the time until the next UTC day", d.RetryAfter)
+			}
+
+			var got usage
+			if err := store.GetJSON(ctx, s, key, &got); err != nil {
+				t.Fatal(err)
+			}
+			if got.Day != tt.wantStored.Day || got.Count != tt.wantStored.Count {
+				t.Errorf("stored usage = %s ×%d, want %s ×%d", got.Day, got.Count, tt.wantStored.Day, tt.wantStored.Count)
+			}
+		})
+	}
+}
+
+func TestRefundDayRollover(t *testing.T) {
+	now := time.Now().UTC()
+	today := now.Format(dayLayout)
+	yesterday := now.AddDate(0, 0, -1).Format(dayLayout)
+
+	tests := []struct {
+		name      string
+		stored    usage
+		wantCount int
+	}{
+		{"use of today", usage{Day: today, Count: 2, Last: now}, 1},
+		{"use of yesterday", usage{Day: yesterday, Count: 2, Last: now.Add(-24 * time.Hour)}, 2},
+		{"nothing used", usage{Day: today}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			s := store.NewMemoryStore()
+			key := usageKey("cmd:price", 7)
+			if err := store.PutJSON(ctx, s, key, tt.stored); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := NewLimiter(s).Refund(ctx, "cmd:price", 7); err != nil {
+				t.Fatalf("Refund error = %v", err)
+			}
+			var got usage
+			if err := store.GetJSON(ctx, s, key, &got); err != nil {
+				t.Fatal(err)
+			}
+			if got.Day != tt.stored.Day || got.Count != tt.wantCount {
+				t.Errorf("stored usage = %s ×%d, want %s ×%d", got.Day, got.Count, tt.stored.Day, tt.wantCount)
+			}
+		})
+	}
+}
@@ -0,0 +1,25 @@
+package tgwrapper
+
+import (
+	"github.com/0xVanfer/tg-listener/config"
+	"github.com/0xVanfer/tg-listener/core"
+)
+
+// sendLimits converts the rate limit configuration to send queue limits.
+func sendLimits(cfg *config.RateLimitConfig) core.Limits {
+	if cfg == nil {
+		return core.Limits{}
+	}
+	return core.Limits{
+		GlobalRate:   cfg.GlobalRate,
+		ChatInterval: cfg.ChatInterval,
+		ChatBurst:    cfg.ChatBurst,
+		MaxRetries:   cfg.MaxRetries,
+	}
+}
+
+// QueueStats returns the metrics of the outgoing message queue: waiting
+// requests, sent requests, and 429 responses with their retries.
+func (w *Wrapper) QueueStats() core.QueueStats {
+	return w.bot.QueueStats()
+}
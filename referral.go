@@ -0,0 +1,216 @@
+package tgwrapper
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mymmrac/telego"
+
+	"github.com/0xVanfer/tg-listener/conv"
+	"github.com/0xVanfer/tg-listener/core"
+	"github.com/0xVanfer/tg-listener/referral"
+	"github.com/0xVanfer/tg-listener/store"
+)
+
+// referralInviteKeyPrefix is the store key prefix for cached referral invite links.
+const referralInviteKeyPrefix = "referral_invite:"
+
+// ReferredByAttribute is the user registry attribute holding the referrer's user ID,
+// so segments can target referred users.
+const ReferredByAttribute = "referred_by"
+
+// defaultAllowedUpdates lists the update types Telegram sends when no
+// allowed_updates are given: every type except the opt-in chat_member,
+// message_reaction, and message_reaction_count.
+var defaultAllowedUpdates = []string{
+	telego.MessageUpdates, telego.EditedMessageUpdates, telego.ChannelPostUpdates, telego.EditedChannelPostUpdates,
+	telego.BusinessConnectionUpdates, telego.BusinessMessageUpdates, telego.EditedBusinessMessageUpdates,
+	telego.DeletedBusinessMessagesUpdates, telego.InlineQueryUpdates, telego.ChosenInlineResultUpdates,
+	telego.CallbackQueryUpdates, telego.ShippingQueryUpdates, telego.PreCheckoutQueryUpdates,
+	telego.PurchasedPaidMediaUpdates, telego.PollUpdates, telego.PollAnswerUpdates, telego.MyChatMemberUpdates,
+	telego.ChatJoinRequestUpdates, telego.ChatBoostUpdates, telego.RemovedChatBoostUpdates,
+}
+
+// allowedUpdatesWithChatMember lists the default update types plus chat_member.
+var allowedUpdatesWithChatMember = append(slices.Clone(defaultAllowedUpdates), telego.ChatMemberUpdates)
+
+// Referrals returns the referral tracker.
+func (w *Wrapper) Referrals() *referral.Tracker {
+	w.storeMu.RLock()
+	defer w.storeMu.RUnlock()
+	return w.referrals
+}
+
+// OnReferral sets a callback function that is called when a user is attributed to a referrer,
+// for example to grant a reward.
+func (w *Wrapper) OnReferral(fn func(ctx context.Context, referrerID, userID int64)) {
+	w.onReferral = fn
+}
+
+// referralPrefix returns the configured /start payload prefix for referral codes.
+func (w *Wrapper) referralPrefix() string {
+	if w.Config().Referral == nil {
+		return "ref_"
+	}
+	return w.Config().Referral.GetPayloadPrefix()
+}
+
+// ReferralLink returns the user's personal deep link.
+// New users who open it are attributed to the user when referral tracking is enabled.
+func (w *Wrapper) ReferralLink(ctx context.Context, userID int64) (string, error) {
+	code, err := w.Referrals().Code(ctx, userID)
+	if err != nil {
+		return "", err
+	}
+	return w.bot.DeepLink(ctx, w.referralPrefix()+code)
+}
+
+// ReferralInviteLink returns the user's personal invite link to a group or channel.
+// Users joining through it are attributed to the user. The link is created once
+// and reused; the bot must be an administrator allowed to invite users, and
+// chat_member updates must be received (enabled automatically with referral tracking).
+func (w *Wrapper) ReferralInviteLink(ctx context.Context, userID, chatID int64) (string, error) {
+	key := referralInviteKeyPrefix + strconv.FormatInt(chatID, 10) + ":" + strconv.FormatInt(userID, 10)
+	st := w.Store()
+	if data, err := st.Get(ctx, key); err == nil {
+		return string(data), nil
+	} else if !errors.Is(err, store.ErrNotFound) {
+		return "", err
+	}
+
+	code, err := w.Referrals().Code(ctx, userID)
+	if err != nil {
+		return "", err
+	}
+	link, err := w.bot.CreateInviteLink(ctx, chatID, w.referralPrefix()+code, 0, time.Time{})
+	if err != nil {
+		return "", err
+	}
+	if link == nil {
+		return "", errors.New("bot is not initialized")
+	}
+	if err := st.Put(ctx, key, []byte(link.InviteLink)); err != nil {
+		return "", err
+	}
+	return link.InviteLink, nil
+}
+
+// referralEnabled returns true if referral tracking is configured and enabled.
+func (w *Wrapper) referralEnabled() bool {
+	return w.Config().Referral != nil && w.Config().Referral.Enabled
+}
+
+// setupReferrals registers referral attribution, the referral namespace, and the stats command.
+// Must run before the user registry observer so new users can be recognized.
+func (w *Wrapper) setupReferrals() {
+	if !w.referralEnabled() {
+		return
+	}
+
+	w.router.AddUpdateObserver(w.attributeReferral)
+
+	w.flowEngine.RegisterNamespace("referral", func(ctx context.Context, c *conv.Conversation) map[string]interface{} {
+		userID := c.UserID
+		if userID == 0 && c.ChatID > 0 {
+			// Private chat IDs are user IDs
+			userID = c.ChatID
+		}
+		if userID == 0 {
+			return nil
+		}
+		return w.referralValues(ctx, userID)
+	})
+
+	w.router.RegisterCommand(w.Config().Referral.GetCommand(), func(ctx context.Context, msg telego.Message) error {
+		return w.sendReferralStats(ctx, msg)
+	})
+}
+
+// referralValues returns the template values describing a user's referrals.
+func (w *Wrapper) referralValues(ctx context.Context, userID int64) map[string]interface{} {
+	rec, err := w.Referrals().Get(ctx, userID)
+	if err != nil {
+		return nil
+	}
+	values := map[string]interface{}{
+		"code":        rec.Code,
+		"count":       rec.Count(),
+		"referred_by": rec.ReferredBy,
+	}
+	if link, err := w.bot.DeepLink(ctx, w.referralPrefix()+rec.Code); err == nil {
+		values["link"] = link
+	}
+	return values
+}
+
+// sendReferralStats replies with the user's referral link and stats.
+func (w *Wrapper) sendReferralStats(ctx context.Context, msg telego.Message) error {
+	cfg := w.Config().Referral
+	c := w.contextConversation(msg.From.ID, msg.Chat.ID)
+	text := w.flowEngine.RenderText(ctx, c, w.Config().ResolveText(msg.Chat.ID, "referral.stats", cfg.GetStatsText()))
+
+	var kb *telego.InlineKeyboardMarkup
+	if cfg.ShareText != "" {
+		if link, err := w.ReferralLink(ctx, msg.From.ID); err == nil {
+			shareURL := "https://t.me/share/url?url=" + url.QueryEscape(link) + "&text=" + url.QueryEscape(cfg.ShareText)
+			kb = core.NewKeyboard().URLButton("📤 Share", shareURL).Build()
+		}
+	}
+
+	_, err := w.bot.SendMessageWithKeyboard(ctx, msg.Chat.ID, msg.MessageThreadID, text, kb)
+	return err
+}
+
+// attributeReferral attributes new users arriving through a referral deep link
+// or a referral invite link.
+func (w *Wrapper) attributeReferral(ctx context.Context, update telego.Update) {
+	prefix := w.referralPrefix()
+
+	switch {
+	case update.Message != nil && update.Message.From != nil:
+		payload := core.StartPayload(update.Message.Text)
+		if !strings.HasPrefix(payload, prefix) {
+			return
+		}
+		// Only users the bot has never seen count as referred
+		if _, err := w.Users().Get(ctx, update.Message.From.ID); !errors.Is(err, store.ErrNotFound) {
+			return
+		}
+		w.attributeCode(ctx, update.Message.From.ID, strings.TrimPrefix(payload, prefix), referral.SourceDeepLink)
+
+	case update.ChatMember != nil && update.ChatMember.InviteLink != nil:
+		name := update.ChatMember.InviteLink.Name
+		if !strings.HasPrefix(name, prefix) || !isJoin(update.ChatMember) {
+			return
+		}
+		w.attributeCode(ctx, update.ChatMember.NewChatMember.MemberUser().ID, strings.TrimPrefix(name, prefix), referral.SourceInviteLink)
+	}
+}
+
+// attributeCode attributes a user to the owner of a referral code.
+func (w *Wrapper) attributeCode(ctx context.Context, userID int64, code, source string) {
+	referrerID, err := w.Referrals().Resolve(ctx, code)
+	if err != nil {
+		return
+	}
+	ok, err := w.Referrals().Attribute(ctx, userID, referrerID, source)
+	if err != nil || !ok {
+		return
+	}
+	_ = w.Users().SetAttribute(ctx, userID, ReferredByAttribute, referrerID)
+	if fn := w.onReferral; fn != nil {
+		fn(ctx, referrerID, userID)
+	}
+}
+
+// isJoin returns true if a chat member update represents a user joining the chat.
+func isJoin(update *telego.ChatMemberUpdated) bool {
+	wasMember := update.OldChatMember.MemberIsMember()
+	isMember := update.NewChatMember.MemberIsMember()
+	return !wasMember && isMember
+}
@@ -0,0 +1,199 @@
+// Package referral tracks referral codes and attributes new users to the users
+// who invited them, through deep links or named chat invite links.
+package referral
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/0xVanfer/tg-listener/store"
+)
+
+// Store key prefixes for referral records and the code index.
+const (
+	recordKeyPrefix = "referral:"
+	codeKeyPrefix   = "referral_code:"
+)
+
+// codeAlphabet is the character set for generated codes.
+// Ambiguous characters (0/O, 1/l/I) are left out so codes can be read aloud.
+const codeAlphabet = "abcdefghjkmnpqrstuvwxyz23456789"
+
+// codeLength is the length of generated referral codes.
+const codeLength = 8
+
+// ErrUnknownCode is returned when a referral code does not belong to any user.
+var ErrUnknownCode = errors.New("unknown referral code")
+
+// Sources describing how a referred user arrived.
+const (
+	SourceDeepLink   = "deep_link"   // Opened the referrer's /start link
+	SourceInviteLink = "invite_link" // Joined a chat through the referrer's invite link
+)
+
+// Referee is a user attributed to a referrer.
+type Referee struct {
+	UserID int64     `json:"user_id"` // Referred user ID
+	Source string    `json:"source"`  // How the user arrived (SourceDeepLink or SourceInviteLink)
+	At     time.Time `json:"at"`      // When the user was attributed
+}
+
+// Record is a user's referral state.
+type Record struct {
+	UserID     int64     `json:"user_id"`               // User ID
+	Code       string    `json:"code"`                  // The user's own referral code
+	ReferredBy int64     `json:"referred_by,omitempty"` // Referrer user ID, 0 if none
+	ReferredAt time.Time `json:"referred_at,omitempty"` // When the user was attributed
+	Referred   []Referee `json:"referred,omitempty"`    // Users this user referred, oldest first
+}
+
+// Count returns the number of users referred.
+func (r *Record) Count() int {
+	return len(r.Referred)
+}
+
+// Tracker stores referral codes and attributions in a store.
+type Tracker struct {
+	store store.Store // Backing store
+	mu    sync.Mutex  // Serializes read-modify-write cycles
+}
+
+// NewTracker creates a referral tracker persisted in the given store.
+func NewTracker(s store.Store) *Tracker {
+	return &Tracker{store: s}
+}
+
+// recordKey returns the store key for a user's referral record.
+func recordKey(userID int64) string {
+	return recordKeyPrefix + strconv.FormatInt(userID, 10)
+}
+
+// load reads a user's record, returning an empty one if none exists.
+func (t *Tracker) load(ctx context.Context, userID int64) (*Record, error) {
+	rec := &Record{UserID: userID}
+	err := store.GetJSON(ctx, t.store, recordKey(userID), rec)
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		return nil, err
+	}
+	return rec, nil
+}
+
+// save writes a user's record.
+func (t *Tracker) save(ctx context.Context, rec *Record) error {
+	return store.PutJSON(ctx, t.store, recordKey(rec.UserID), rec)
+}
+
+// ensureCode assigns a new unique code to rec if it has none.
+func (t *Tracker) ensureCode(ctx context.Context, rec *Record) error {
+	if rec.Code != "" {
+		return nil
+	}
+	for {
+		code, err := generateCode()
+		if err != nil {
+			return err
+		}
+		if _, err := t.store.Get(ctx, codeKeyPrefix+code); errors.Is(err, store.ErrNotFound) {
+			rec.Code = code
+			break
+		} else if err != nil {
+			return err
+		}
+	}
+	if err := t.store.Put(ctx, codeKeyPrefix+rec.Code, []byte(strconv.FormatInt(rec.UserID, 10))); err != nil {
+		return err
+	}
+	return t.save(ctx, rec)
+}
+
+// Get returns a user's referral record, assigning a code on first use.
+func (t *Tracker) Get(ctx context.Context, userID int64) (*Record, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	rec, err := t.load(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := t.ensureCode(ctx, rec); err != nil {
+		return nil, err
+	}
+	return rec, nil
+}
+
+// Code returns a user's referral code, assigning one on first use.
+func (t *Tracker) Code(ctx context.Context, userID int64) (string, error) {
+	rec, err := t.Get(ctx, userID)
+	if err != nil {
+		return "", err
+	}
+	return rec.Code, nil
+}
+
+// Resolve returns the user ID owning a referral code.
+// Returns ErrUnknownCode if the code does not exist.
+func (t *Tracker) Resolve(ctx context.Context, code string) (int64, error) {
+	data, err := t.store.Get(ctx, codeKeyPrefix+code)
+	if errors.Is(err, store.ErrNotFound) {
+		return 0, ErrUnknownCode
+	} else if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(string(data), 10, 64)
+}
+
+// Attribute records userID as referred by referrerID.
+// Returns false without error if the user was already attributed, tries to refer
+// themselves, or referred the referrer (which would create a cycle).
+func (t *Tracker) Attribute(ctx context.Context, userID, referrerID int64, source string) (bool, error) {
+	if userID == referrerID || userID == 0 || referrerID == 0 {
+		return false, nil
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	rec, err := t.load(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+	if rec.ReferredBy != 0 {
+		return false, nil
+	}
+	referrer, err := t.load(ctx, referrerID)
+	if err != nil {
+		return false, err
+	}
+	if referrer.ReferredBy == userID {
+		return false, nil
+	}
+
+	now := time.Now()
+	rec.ReferredBy = referrerID
+	rec.ReferredAt = now
+	if err := t.save(ctx, rec); err != nil {
+		return false, err
+	}
+
+	referrer.Referred = append(referrer.Referred, Referee{UserID: userID, Source: source, At: now})
+	if err := t.save(ctx, referrer); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// generateCode returns a random referral code.
+func generateCode() (string, error) {
+	buf := make([]byte, codeLength)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	for i, b := range buf {
+		buf[i] = codeAlphabet[int(b)%len(codeAlphabet)]
+	}
+	return string(buf), nil
+}
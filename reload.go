@@ -0,0 +1,93 @@
+package tgwrapper
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"path/filepath"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/mymmrac/telego"
+
+	"github.com/0xVanfer/tg-listener/config"
+)
+
+// configReloadDelay is how long the watcher waits after the last change to the
+// configuration file before reloading, so an editor's write burst reloads once.
+const configReloadDelay = 500 * time.Millisecond
+
+// botCommands returns the command list to register with Telegram, or nil if
+// command registration is disabled.
+func botCommands(cfg *config.Config) []telego.BotCommand {
+	if cfg == nil || cfg.Bot == nil {
+		return nil
+	}
+	if cfg.Bot.RegisterCommands != nil && !*cfg.Bot.RegisterCommands {
+		return nil
+	}
+	commands := make([]telego.BotCommand, len(cfg.Bot.Commands))
+	for i, cmd := range cfg.Bot.Commands {
+		commands[i] = telego.BotCommand{
+			Command:     cmd.Command,
+			Description: cmd.Description,
+		}
+	}
+	return commands
+}
+
+// WatchConfig reloads the configuration whenever the file it was loaded from
+// changes, until ctx is canceled. Invalid files are logged and ignored, so the
+// current configuration stays in effect. Start calls it when watch_config is set.
+func (w *Wrapper) WatchConfig(ctx context.Context) error {
+	path := w.Config().Path()
+	if path == "" {
+		return fmt.Errorf("configuration was not loaded from a file")
+	}
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to watch configuration: %w", err)
+	}
+	// Watch the directory: editors often replace the file instead of writing it
+	if err := watcher.Add(filepath.Dir(path)); err != nil {
+		_ = watcher.Close()
+		return fmt.Errorf("failed to watch configuration: %w", err)
+	}
+
+	go func() {
+		defer watcher.Close()
+		name := filepath.Clean(path)
+		var timer *time.Timer
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-w.stopChan:
+				return
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if filepath.Clean(event.Name) != name || !event.Has(fsnotify.Write|fsnotify.Create) {
+					continue
+				}
+				if timer != nil {
+					timer.Stop()
+				}
+				timer = time.AfterFunc(configReloadDelay, func() {
+					if err := w.reloadConfigFromFile(); err != nil {
+						log.Printf("[Config] Reload of %s failed: %v", path, err)
+					} else if w.Config().Bot.Debug {
+						log.Printf("[Config] Reloaded %s", path)
+					}
+				})
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				log.Printf("[Config] Watcher error: %v", err)
+			}
+		}
+	}()
+	return nil
+}
@@ -0,0 +1,187 @@
+// Package reminder persists user reminders and their due times, so reminders
+// are delivered, snoozed, and completed across restarts.
+package reminder
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/0xVanfer/tg-listener/store"
+)
+
+// keyPrefix is the store key prefix for reminders.
+const keyPrefix = "reminder:"
+
+// ErrNotFound is returned when a reminder does not exist, e.g. after it was completed.
+var ErrNotFound = errors.New("reminder not found")
+
+// Reminder is the persisted state of a reminder.
+type Reminder struct {
+	ID          string    `json:"id"`
+	UserID      int64     `json:"user_id"`
+	ChatID      int64     `json:"chat_id"`
+	TopicID     int       `json:"topic_id"`
+	Text        string    `json:"text"`
+	DueAt       time.Time `json:"due_at"`
+	CreatedAt   time.Time `json:"created_at"`
+	DeliveredAt time.Time `json:"delivered_at,omitempty"` // Zero until the reminder is delivered; reset by snoozing
+	MessageID   int       `json:"message_id,omitempty"`   // Message of the last delivery
+	Snoozes     int       `json:"snoozes,omitempty"`      // Number of times the reminder was snoozed
+}
+
+// Delivered returns true if the reminder was delivered and awaits Snooze or Done.
+func (r *Reminder) Delivered() bool {
+	return !r.DeliveredAt.IsZero()
+}
+
+// Tracker persists reminders in a store.
+type Tracker struct {
+	store store.Store // Backing store
+	mu    sync.Mutex  // Serializes read-modify-write cycles
+}
+
+// NewTracker creates a reminder tracker persisted in the given store.
+func NewTracker(s store.Store) *Tracker {
+	return &Tracker{store: s}
+}
+
+// NewID returns a random reminder ID, short enough for callback data.
+func NewID() (string, error) {
+	buf := make([]byte, 6)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// Save stores a reminder, replacing any previous reminder with the same ID.
+func (t *Tracker) Save(ctx context.Context, r *Reminder) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return store.PutJSON(ctx, t.store, keyPrefix+r.ID, r)
+}
+
+// Get retrieves a reminder.
+// Returns ErrNotFound if the reminder does not exist.
+func (t *Tracker) Get(ctx context.Context, id string) (*Reminder, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.get(ctx, id)
+}
+
+// get retrieves a reminder without locking.
+func (t *Tracker) get(ctx context.Context, id string) (*Reminder, error) {
+	var r Reminder
+	if err := store.GetJSON(ctx, t.store, keyPrefix+id, &r); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return &r, nil
+}
+
+// MarkDelivered records the delivery of a reminder and the message showing it.
+// Returns false if the reminder was already delivered since it was last due.
+func (t *Tracker) MarkDelivered(ctx context.Context, id string, messageID int, at time.Time) (*Reminder, bool, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	r, err := t.get(ctx, id)
+	if err != nil {
+		return nil, false, err
+	}
+	if r.Delivered() {
+		return r, false, nil
+	}
+	r.DeliveredAt = at
+	r.MessageID = messageID
+	if err := store.PutJSON(ctx, t.store, keyPrefix+id, r); err != nil {
+		return nil, false, err
+	}
+	return r, true, nil
+}
+
+// Snooze moves a reminder's due time to until, so it is delivered again.
+func (t *Tracker) Snooze(ctx context.Context, id string, until time.Time) (*Reminder, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	r, err := t.get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	r.DueAt = until
+	r.DeliveredAt = time.Time{}
+	r.Snoozes++
+	if err := store.PutJSON(ctx, t.store, keyPrefix+id, r); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Complete removes a reminder and returns its last state.
+// Returns ErrNotFound if it was already completed.
+func (t *Tracker) Complete(ctx context.Context, id string) (*Reminder, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	r, err := t.get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := t.store.Delete(ctx, keyPrefix+id); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// List returns all reminders, earliest due first.
+func (t *Tracker) List(ctx context.Context) ([]*Reminder, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	keys, err := t.store.List(ctx, keyPrefix)
+	if err != nil {
+		return nil, err
+	}
+	reminders := make([]*Reminder, 0, len(keys))
+	for _, key := range keys {
+		r, err := t.get(ctx, strings.TrimPrefix(key, keyPrefix))
+		if errors.Is(err, ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		reminders = append(reminders, r)
+	}
+	sort.Slice(reminders, func(i, j int) bool {
+		return reminders[i].DueAt.Before(reminders[j].DueAt)
+	})
+	return reminders, nil
+}
+
+// ListByUser returns the reminders of a user, earliest due first.
+func (t *Tracker) ListByUser(ctx context.Context, userID int64) ([]*Reminder, error) {
+	all, err := t.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var reminders []*Reminder
+	for _, r := range all {
+		if r.UserID == userID {
+			reminders = append(reminders, r)
+		}
+	}
+	return reminders, nil
+}
+
+// Delete removes a reminder.
+func (t *Tracker) Delete(ctx context.Context, id string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.store.Delete(ctx, keyPrefix+id)
+}
@@ -0,0 +1,392 @@
+package tgwrapper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mymmrac/telego"
+
+	"github.com/0xVanfer/tg-listener/config"
+	"github.com/0xVanfer/tg-listener/conv"
+	"github.com/0xVanfer/tg-listener/core"
+	"github.com/0xVanfer/tg-listener/reminder"
+)
+
+// ReminderFlowID is the ID of the built-in reminder flow.
+// The flow is installed when reminders are enabled and started with their command.
+const ReminderFlowID = "_reminder"
+
+// ReminderCallbackPrefix is the callback data prefix of reminder buttons.
+// The data is the prefix, the reminder ID, ":", and the action: "done",
+// "cancel", or "snooze:" followed by the delay in minutes.
+const ReminderCallbackPrefix = "remind:"
+
+// Names of the reminder flow's internal handlers and validators.
+const (
+	reminderCreate = "_reminderCreate"
+	reminderWhen   = "_reminderWhen"
+)
+
+// ErrTooManyReminders is returned when a user reaches the configured limit of pending reminders.
+var ErrTooManyReminders = errors.New("too many pending reminders")
+
+// reminderTimers holds the delivery timers of pending reminders.
+type reminderTimers struct {
+	timers map[string]*time.Timer // Delivery timers by reminder ID
+	mu     sync.Mutex             // Mutex for thread-safe timer access
+}
+
+// reminderFlow returns the configuration of the built-in reminder flow.
+func reminderFlow() *config.FlowConfig {
+	return &config.FlowConfig{
+		ID:          ReminderFlowID,
+		Name:        "Reminder",
+		InitialStep: "text",
+		TTL:         15 * time.Minute,
+		Steps: map[string]*config.StepConfig{
+			"text": {
+				ID:         "text",
+				PromptText: "⏰ What should I remind you about?",
+				Keyboard:   &config.KeyboardConfig{AddMain: true},
+				InputType:  config.InputTypeText,
+				StoreAs:    "text",
+				NextStep:   "when",
+			},
+			"when": {
+				ID:         "when",
+				PromptText: "🗓 When?\n\nPick a time, or enter a delay such as 30m or 2h, or a time in your time zone as " + scheduleLayout + ".",
+				Keyboard: &config.KeyboardConfig{
+					Type: config.KeyboardTypeStatic,
+					Buttons: [][]config.ButtonConfig{
+						{{Text: "In 1 hour", Callback: "1h"}, {Text: "In 3 hours", Callback: "3h"}},
+						{{Text: "Tomorrow", Callback: "24h"}},
+					},
+					AddBack: true,
+					AddMain: true,
+				},
+				InputType:  config.InputTypeAny,
+				Validation: &config.ValidationConfig{Type: "custom", Custom: reminderWhen},
+				StoreAs:    "when",
+				OnComplete: reminderCreate,
+			},
+		},
+	}
+}
+
+// Reminders returns the tracker of pending reminders.
+func (w *Wrapper) Reminders() *reminder.Tracker {
+	w.storeMu.RLock()
+	defer w.storeMu.RUnlock()
+	return w.reminderStates
+}
+
+// remindersEnabled returns true if reminders are configured and enabled.
+func (w *Wrapper) remindersEnabled() bool {
+	return w.Config().Reminders != nil && w.Config().Reminders.Enabled
+}
+
+// CreateReminder schedules a reminder for a user, delivered to the given chat
+// at dueAt with Snooze and Done buttons. Reminders are persisted in the store
+// and delivered after a restart. Returns ErrTooManyReminders if the user has
+// reached the configured limit of pending reminders.
+func (w *Wrapper) CreateReminder(ctx context.Context, userID, chatID int64, topicID int, text string, dueAt time.Time) (*reminder.Reminder, error) {
+	if cfg := w.Config().Reminders; cfg != nil && cfg.MaxPending > 0 {
+		pending, err := w.Reminders().ListByUser(ctx, userID)
+		if err != nil {
+			return nil, err
+		}
+		if len(pending) >= cfg.MaxPending {
+			return nil, ErrTooManyReminders
+		}
+	}
+
+	id, err := reminder.NewID()
+	if err != nil {
+		return nil, err
+	}
+	r := &reminder.Reminder{
+		ID:        id,
+		UserID:    userID,
+		ChatID:    chatID,
+		TopicID:   topicID,
+		Text:      text,
+		DueAt:     dueAt,
+		CreatedAt: time.Now(),
+	}
+	if err := w.Reminders().Save(ctx, r); err != nil {
+		return nil, err
+	}
+	w.scheduleReminder(r)
+	return r, nil
+}
+
+// CancelReminder removes a pending reminder.
+func (w *Wrapper) CancelReminder(ctx context.Context, id string) error {
+	w.cancelReminderTimer(id)
+	_, err := w.Reminders().Complete(ctx, id)
+	return err
+}
+
+// reminderCreateStep creates the reminder collected by the reminder flow and
+// confirms it in place of the flow's prompt.
+func (w *Wrapper) reminderCreateStep(ctx context.Context, c *conv.Conversation) error {
+	dueAt, err := parseScheduleTime(c.GetString("when"), time.Now(), w.UserLocation(ctx, c.UserID))
+	if err != nil {
+		return err
+	}
+	chatID, topicID, msgID := c.ChatID, c.TopicID, c.KeyboardMsgID
+	r, err := w.CreateReminder(ctx, c.UserID, chatID, topicID, c.GetString("text"), dueAt)
+	w.EndConversationIn(ctx, c.UserID, chatID, topicID)
+
+	var text string
+	switch {
+	case errors.Is(err, ErrTooManyReminders):
+		text = "❌ You have too many pending reminders. Cancel some with /" + w.Config().Reminders.GetListCommand() + " first."
+	case err != nil:
+		return err
+	default:
+		text = "✅ I'll remind you on " + w.FormatUserTime(ctx, r.UserID, r.DueAt) + ":\n\n" + r.Text
+	}
+	if msgID > 0 {
+		_, err = w.bot.EditMessage(ctx, chatID, msgID, text)
+		return err
+	}
+	_, err = w.bot.SendMessage(ctx, chatID, topicID, text)
+	return err
+}
+
+// deliverReminder sends a due reminder with its Snooze and Done buttons.
+// A failed delivery is retried on the next Start.
+func (w *Wrapper) deliverReminder(ctx context.Context, id string) {
+	w.cancelReminderTimer(id)
+	r, err := w.Reminders().Get(ctx, id)
+	if err != nil || r.Delivered() {
+		return
+	}
+
+	text, entities := core.NewBuilder().Bold("⏰ Reminder").Ln().Ln().Text(r.Text).Build()
+	msg, err := w.bot.SendMessageWithKeyboard(ctx, r.ChatID, r.TopicID, text, w.reminderKeyboard(r), entities...)
+	if err != nil {
+		return
+	}
+	msgID := 0
+	if msg != nil {
+		msgID = msg.MessageID
+	}
+	_, _, _ = w.Reminders().MarkDelivered(ctx, id, msgID, time.Now())
+}
+
+// reminderKeyboard returns the Snooze and Done buttons of a delivered reminder.
+func (w *Wrapper) reminderKeyboard(r *reminder.Reminder) *telego.InlineKeyboardMarkup {
+	var snooze []telego.InlineKeyboardButton
+	for _, d := range w.snoozeOptions() {
+		data := ReminderCallbackPrefix + r.ID + ":snooze:" + strconv.Itoa(int(d/time.Minute))
+		snooze = append(snooze, core.Button("💤 "+formatSnooze(d), data))
+	}
+	return core.NewKeyboard().
+		Grid(snooze, 3).
+		Button("✅ Done", ReminderCallbackPrefix+r.ID+":done").
+		Build()
+}
+
+// snoozeOptions returns the configured snooze delays.
+func (w *Wrapper) snoozeOptions() []time.Duration {
+	if w.Config().Reminders == nil {
+		return config.DefaultSnoozeOptions
+	}
+	return w.Config().Reminders.GetSnoozeOptions()
+}
+
+// formatSnooze formats a snooze delay compactly, e.g. "10m", "1h", or "1d".
+func formatSnooze(d time.Duration) string {
+	switch {
+	case d%(24*time.Hour) == 0:
+		return strconv.Itoa(int(d/(24*time.Hour))) + "d"
+	case d%time.Hour == 0:
+		return strconv.Itoa(int(d/time.Hour)) + "h"
+	default:
+		return strconv.Itoa(int(d/time.Minute)) + "m"
+	}
+}
+
+// sendReminderList shows a user's pending reminders with buttons to cancel them.
+// If editMsgID is provided (> 0), the existing message is edited.
+func (w *Wrapper) sendReminderList(ctx context.Context, userID, chatID int64, topicID, editMsgID int) error {
+	reminders, err := w.Reminders().ListByUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	var text string
+	var kb *telego.InlineKeyboardMarkup
+	if len(reminders) == 0 {
+		text = "You have no pending reminders."
+		if w.remindersEnabled() {
+			text += " Use /" + w.Config().Reminders.GetCommand() + " to create one."
+		}
+	} else {
+		lines := []string{"⏰ Your reminders", ""}
+		var buttons []telego.InlineKeyboardButton
+		for i, r := range reminders {
+			lines = append(lines, fmt.Sprintf("%d. %s — %s", i+1, w.FormatUserTime(ctx, userID, r.DueAt), r.Text))
+			buttons = append(buttons, core.Button(fmt.Sprintf("❌ Cancel %d", i+1), ReminderCallbackPrefix+r.ID+":cancel"))
+		}
+		text = strings.Join(lines, "\n")
+		kb = core.NewKeyboard().Grid(buttons, 3).Build()
+	}
+
+	if editMsgID > 0 {
+		_, err = w.bot.EditMessageWithKeyboard(ctx, chatID, editMsgID, text, kb)
+		return err
+	}
+	_, err = w.bot.SendMessageWithKeyboard(ctx, chatID, topicID, text, kb)
+	return err
+}
+
+// scheduleReminder arms the delivery timer of a reminder that wasn't delivered yet.
+func (w *Wrapper) scheduleReminder(r *reminder.Reminder) {
+	if r.Delivered() {
+		return
+	}
+	id := r.ID
+	timer := time.AfterFunc(time.Until(r.DueAt), func() {
+		w.deliverReminder(context.Background(), id)
+	})
+
+	w.reminders.mu.Lock()
+	defer w.reminders.mu.Unlock()
+	if w.reminders.timers == nil {
+		w.reminders.timers = make(map[string]*time.Timer)
+	}
+	if old, ok := w.reminders.timers[id]; ok {
+		old.Stop()
+	}
+	w.reminders.timers[id] = timer
+}
+
+// cancelReminderTimer stops the delivery timer of a reminder.
+func (w *Wrapper) cancelReminderTimer(id string) {
+	w.reminders.mu.Lock()
+	defer w.reminders.mu.Unlock()
+	if timer, ok := w.reminders.timers[id]; ok {
+		timer.Stop()
+		delete(w.reminders.timers, id)
+	}
+}
+
+// resumeReminders re-arms the delivery timers of reminders in the store.
+// Reminders that fell due while the bot was down are delivered right away.
+func (w *Wrapper) resumeReminders(ctx context.Context) {
+	reminders, err := w.Reminders().List(ctx)
+	if err != nil {
+		return
+	}
+	for _, r := range reminders {
+		w.scheduleReminder(r)
+	}
+}
+
+// setupReminders registers the reminder flow's handlers, the reminder button
+// callback, and, when reminders are enabled, their commands.
+func (w *Wrapper) setupReminders() {
+	w.flowEngine.RegisterValidator(reminderWhen, func(value string, c *conv.Conversation) error {
+		_, err := parseScheduleTime(value, time.Now(), w.UserLocation(context.Background(), c.UserID))
+		return err
+	})
+	w.flowEngine.RegisterStepHandler(reminderCreate, w.reminderCreateStep)
+	w.router.RegisterCallbackPrefix(ReminderCallbackPrefix, w.handleReminderCallback)
+
+	if !w.remindersEnabled() {
+		return
+	}
+	w.router.RegisterCommand(w.Config().Reminders.GetCommand(), func(ctx context.Context, msg telego.Message) error {
+		c, err := w.StartConversation(ctx, msg.From.ID, msg.Chat.ID, msg.MessageThreadID, ReminderFlowID, 0)
+		if w.notifyFlowDenied(ctx, err, msg.Chat.ID, msg.MessageThreadID, "") {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		return w.showStepPrompt(ctx, c)
+	})
+	w.router.RegisterCommand(w.Config().Reminders.GetListCommand(), func(ctx context.Context, msg telego.Message) error {
+		return w.sendReminderList(ctx, msg.From.ID, msg.Chat.ID, msg.MessageThreadID, 0)
+	})
+}
+
+// handleReminderCallback handles the Snooze, Done, and Cancel buttons of reminders.
+// Only the owner of a reminder may press them.
+func (w *Wrapper) handleReminderCallback(ctx context.Context, query telego.CallbackQuery) error {
+	id, action, _ := strings.Cut(core.ParseCallbackData(query.Data, ReminderCallbackPrefix), ":")
+	chatID := query.Message.GetChat().ID
+	msgID := query.Message.GetMessageID()
+
+	r, err := w.Reminders().Get(ctx, id)
+	if errors.Is(err, reminder.ErrNotFound) {
+		_ = w.bot.AnswerCallback(ctx, query.ID, "This reminder is already done.")
+		if action == "cancel" {
+			return w.sendReminderList(ctx, query.From.ID, chatID, 0, msgID)
+		}
+		_, err = w.bot.EditKeyboard(ctx, chatID, msgID, nil)
+		return err
+	}
+	if err != nil {
+		_ = w.bot.AnswerCallback(ctx, query.ID, "")
+		return err
+	}
+	if r.UserID != query.From.ID {
+		return w.bot.AnswerCallbackWithAlert(ctx, query.ID, "🚫 This reminder belongs to someone else.")
+	}
+
+	switch {
+	case action == "done":
+		if err := w.CancelReminder(ctx, id); err != nil {
+			_ = w.bot.AnswerCallback(ctx, query.ID, "")
+			return err
+		}
+		_ = w.bot.AnswerCallback(ctx, query.ID, "✅ Done")
+		_, err = w.bot.EditMessage(ctx, chatID, msgID, "✅ Done: "+r.Text)
+		return err
+
+	case action == "cancel":
+		if err := w.CancelReminder(ctx, id); err != nil {
+			_ = w.bot.AnswerCallback(ctx, query.ID, "")
+			return err
+		}
+		_ = w.bot.AnswerCallback(ctx, query.ID, "🗑 Reminder cancelled")
+		return w.sendReminderList(ctx, query.From.ID, chatID, 0, msgID)
+
+	case strings.HasPrefix(action, "snooze:"):
+		minutes, err := strconv.Atoi(strings.TrimPrefix(action, "snooze:"))
+		d := time.Duration(minutes) * time.Minute
+		if err != nil || !slices.Contains(w.snoozeOptions(), d) {
+			return w.bot.AnswerCallback(ctx, query.ID, "")
+		}
+		r, err = w.Reminders().Snooze(ctx, id, time.Now().Add(d))
+		if err != nil {
+			_ = w.bot.AnswerCallback(ctx, query.ID, "")
+			return err
+		}
+		w.scheduleReminder(r)
+		_ = w.bot.AnswerCallback(ctx, query.ID, "💤 Snoozed for "+formatSnooze(d))
+		_, err = w.bot.EditMessage(ctx, chatID, msgID, "💤 Snoozed until "+w.FormatUserTime(ctx, r.UserID, r.DueAt)+": "+r.Text)
+		return err
+	}
+	return w.bot.AnswerCallback(ctx, query.ID, "")
+}
+
+// stopReminders stops all delivery timers; they resume from the store on the next Start.
+func (w *Wrapper) stopReminders() {
+	w.reminders.mu.Lock()
+	defer w.reminders.mu.Unlock()
+	for id, timer := range w.reminders.timers {
+		timer.Stop()
+		delete(w.reminders.timers, id)
+	}
+}
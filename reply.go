@@ -0,0 +1,92 @@
+package tgwrapper
+
+import (
+	"context"
+
+	"github.com/mymmrac/telego"
+
+	"github.com/0xVanfer/tg-listener/config"
+	"github.com/0xVanfer/tg-listener/conv"
+	"github.com/0xVanfer/tg-listener/core"
+)
+
+// sendReplyPrompt sends the prompt of a step with a reply keyboard.
+// Reply keyboards can't be edited into a message, so the prompt is always a
+// new message; the previous keyboard message loses its inline buttons, and the
+// next inline step sends a new message too.
+func (w *Wrapper) sendReplyPrompt(ctx context.Context, c *conv.Conversation, step *config.StepConfig, text string) error {
+	var markup telego.ReplyMarkup
+	if kb := replyKeyboard(ctx, step.Keyboard); kb != nil {
+		markup = kb
+	}
+	msg, err := w.bot.SendMessageWithMarkup(ctx, c.ChatID, c.TopicID, text, markup)
+	if err != nil {
+		return err
+	}
+
+	if c.KeyboardMsgID > 0 {
+		_, _ = w.bot.EditKeyboard(ctx, c.ChatID, c.KeyboardMsgID, nil)
+	}
+	c.SetKeyboardMsgID(0)
+	c.SetReplyKeyboard(markup != nil)
+	if msg != nil {
+		c.TrackMessage(msg.MessageID)
+	}
+	return w.convManager.Save(ctx, c)
+}
+
+// clearReplyKeyboard removes the reply keyboard a conversation left in the chat.
+func (w *Wrapper) clearReplyKeyboard(ctx context.Context, c *conv.Conversation) {
+	if !c.HasReplyKeyboard() {
+		return
+	}
+	_ = w.bot.ClearReplyKeyboard(ctx, c.ChatID, c.TopicID)
+	c.SetReplyKeyboard(false)
+}
+
+// replyKeyboard builds a reply keyboard from the static buttons of a keyboard
+// configuration, hiding buttons restricted to roles the user doesn't hold.
+// Returns nil if no buttons remain.
+func replyKeyboard(ctx context.Context, kbCfg *config.KeyboardConfig) *telego.ReplyKeyboardMarkup {
+	kb := core.NewReplyKeyboard()
+	roles := core.RolesFrom(ctx)
+	for _, row := range kbCfg.Buttons {
+		var buttons []telego.KeyboardButton
+		for _, btn := range row {
+			if len(btn.Roles) > 0 && !config.HasRole(roles, btn.Roles) {
+				continue
+			}
+			buttons = append(buttons, replyButton(btn))
+		}
+		kb.Row(buttons...)
+	}
+
+	if kbCfg.OneTime {
+		kb.OneTime()
+	}
+	if kbCfg.Resize {
+		kb.Resize()
+	}
+	if kbCfg.Placeholder != "" {
+		kb.Placeholder(kbCfg.Placeholder)
+	}
+	return kb.Build()
+}
+
+// replyButton creates a reply keyboard button from configuration.
+func replyButton(btn config.ButtonConfig) telego.KeyboardButton {
+	switch {
+	case btn.RequestContact:
+		return core.ContactButton(btn.Text)
+	case btn.RequestLocation:
+		return core.LocationButton(btn.Text)
+	case btn.RequestPoll == "any":
+		return core.PollButton(btn.Text, "")
+	case btn.RequestPoll != "":
+		return core.PollButton(btn.Text, btn.RequestPoll)
+	case btn.WebApp != "":
+		return core.WebAppReplyButton(btn.Text, btn.WebApp)
+	default:
+		return core.TextButton(btn.Text)
+	}
+}
@@ -0,0 +1,59 @@
+package tgwrapper
+
+import (
+	"context"
+
+	"github.com/mymmrac/telego"
+	"github.com/mymmrac/telego/telegoutil"
+
+	"github.com/0xVanfer/tg-listener/core"
+	"github.com/0xVanfer/tg-listener/report"
+)
+
+// ReportOptions configures how SendReport delivers a table.
+type ReportOptions struct {
+	// Format is the document format used when the table is too long for a message.
+	// Defaults to report.FormatCSV.
+	Format report.Format
+
+	// Threshold is the message length above which the table is sent as a document.
+	// Defaults to core.MaxMessageLength; use a negative value to always send a document.
+	Threshold int
+}
+
+// SendReport sends a table as a monospace text message, switching to a document
+// in the configured format when the message would exceed the threshold.
+// Pass nil options for CSV documents above Telegram's message limit.
+func (w *Wrapper) SendReport(ctx context.Context, chatID int64, topicID int, table *report.Table, opts *ReportOptions) (*telego.Message, error) {
+	format := report.FormatCSV
+	threshold := core.MaxMessageLength
+	if opts != nil {
+		if opts.Format != "" {
+			format = opts.Format
+		}
+		if opts.Threshold != 0 {
+			threshold = opts.Threshold
+		}
+	}
+
+	// Send inline while the rendered table fits
+	b := core.NewBuilder()
+	if table.Title != "" {
+		b.BoldLine(table.Title)
+	}
+	text, entities := b.Pre(table.Text(), "").Build()
+	if threshold > 0 && core.UTF16Length(text) <= threshold {
+		return w.bot.SendMessage(ctx, chatID, topicID, text, entities...)
+	}
+
+	data, name, err := table.Render(format)
+	if err != nil {
+		return nil, err
+	}
+	cb := core.NewBuilder()
+	if table.Title != "" {
+		cb.Bold(table.Title)
+	}
+	caption, captionEntities := cb.Build()
+	return w.bot.SendDocument(ctx, chatID, topicID, telegoutil.FileFromBytes(data, name), caption, captionEntities...)
+}
@@ -0,0 +1,280 @@
+// Package report renders tabular data as message text or as CSV, XLSX, and PDF documents.
+package report
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/jung-kurt/gofpdf"
+	"github.com/xuri/excelize/v2"
+)
+
+// Format is a document format for a report.
+type Format string
+
+const (
+	// FormatCSV renders the report as comma-separated values.
+	FormatCSV Format = "csv"
+
+	// FormatXLSX renders the report as an Excel workbook.
+	FormatXLSX Format = "xlsx"
+
+	// FormatPDF renders the report as a paginated PDF with the header repeated on each page.
+	FormatPDF Format = "pdf"
+)
+
+// ErrUnknownFormat is returned when rendering a report in an unsupported format.
+var ErrUnknownFormat = errors.New("unknown report format")
+
+// Table is a tabular dataset with a title and column headers.
+// Cells may hold any value; numbers and times keep their types in XLSX,
+// and are formatted with fmt.Sprint elsewhere.
+type Table struct {
+	Title   string          // Report title, used for the caption and file name
+	Columns []string        // Column headers
+	Rows    [][]interface{} // Row cells, in column order
+}
+
+// NewTable creates an empty table with the given title and columns.
+func NewTable(title string, columns ...string) *Table {
+	return &Table{Title: title, Columns: columns}
+}
+
+// AddRow appends a row of cells.
+func (t *Table) AddRow(cells ...interface{}) *Table {
+	t.Rows = append(t.Rows, cells)
+	return t
+}
+
+// cell formats a cell value as text.
+func cell(v interface{}) string {
+	switch c := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return c
+	case time.Time:
+		return c.Format("2006-01-02 15:04")
+	default:
+		return fmt.Sprint(c)
+	}
+}
+
+// textRow returns the cells of a row formatted as text, padded to the column count.
+func (t *Table) textRow(row []interface{}) []string {
+	n := max(len(t.Columns), len(row))
+	out := make([]string, n)
+	for i := 0; i < len(row); i++ {
+		out[i] = cell(row[i])
+	}
+	return out
+}
+
+// Text renders the table as aligned plain text, suitable for a monospace message block.
+func (t *Table) Text() string {
+	rows := make([][]string, 0, len(t.Rows)+1)
+	rows = append(rows, t.Columns)
+	for _, row := range t.Rows {
+		rows = append(rows, t.textRow(row))
+	}
+
+	var widths []int
+	for _, row := range rows {
+		for i, c := range row {
+			if i >= len(widths) {
+				widths = append(widths, 0)
+			}
+			widths[i] = max(widths[i], utf8.RuneCountInString(c))
+		}
+	}
+
+	var b strings.Builder
+	for r, row := range rows {
+		for i, c := range row {
+			if i > 0 {
+				b.WriteString("  ")
+			}
+			b.WriteString(c)
+			if i < len(row)-1 {
+				b.WriteString(strings.Repeat(" ", widths[i]-utf8.RuneCountInString(c)))
+			}
+		}
+		b.WriteByte('\n')
+		if r == 0 && len(t.Columns) > 0 {
+			total := 0
+			for _, w := range widths {
+				total += w + 2
+			}
+			b.WriteString(strings.Repeat("─", max(total-2, 0)))
+			b.WriteByte('\n')
+		}
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// CSV renders the table as comma-separated values with a header row.
+func (t *Table) CSV() ([]byte, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	if len(t.Columns) > 0 {
+		if err := w.Write(t.Columns); err != nil {
+			return nil, err
+		}
+	}
+	for _, row := range t.Rows {
+		if err := w.Write(t.textRow(row)); err != nil {
+			return nil, err
+		}
+	}
+	w.Flush()
+	return buf.Bytes(), w.Error()
+}
+
+// XLSX renders the table as an Excel workbook with a bold, frozen header row.
+func (t *Table) XLSX() ([]byte, error) {
+	f := excelize.NewFile()
+	defer f.Close()
+
+	sheet := f.GetSheetName(0)
+	for i, col := range t.Columns {
+		name, _ := excelize.CoordinatesToCellName(i+1, 1)
+		if err := f.SetCellValue(sheet, name, col); err != nil {
+			return nil, err
+		}
+	}
+	if len(t.Columns) > 0 {
+		style, err := f.NewStyle(&excelize.Style{Font: &excelize.Font{Bold: true}})
+		if err != nil {
+			return nil, err
+		}
+		last, _ := excelize.CoordinatesToCellName(len(t.Columns), 1)
+		if err := f.SetCellStyle(sheet, "A1", last, style); err != nil {
+			return nil, err
+		}
+		if err := f.SetPanes(sheet, &excelize.Panes{Freeze: true, YSplit: 1, TopLeftCell: "A2", ActivePane: "bottomLeft"}); err != nil {
+			return nil, err
+		}
+	}
+
+	for r, row := range t.Rows {
+		for c, v := range row {
+			name, _ := excelize.CoordinatesToCellName(c+1, r+2)
+			if err := f.SetCellValue(sheet, name, v); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	buf, err := f.WriteToBuffer()
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// PDF renders the table as a landscape A4 PDF. Columns share the page width,
+// long cells are truncated, and the header row repeats on every page.
+// The built-in PDF fonts cover Latin-1; other characters are replaced.
+func (t *Table) PDF() ([]byte, error) {
+	pdf := gofpdf.New("L", "mm", "A4", "")
+	tr := pdf.UnicodeTranslatorFromDescriptor("")
+	pageWidth, _ := pdf.GetPageSize()
+	left, _, right, _ := pdf.GetMargins()
+
+	cols := len(t.Columns)
+	for _, row := range t.Rows {
+		cols = max(cols, len(row))
+	}
+	colWidth := (pageWidth - left - right) / float64(max(cols, 1))
+	const lineHeight = 7.0
+
+	fit := func(s string) string {
+		s = tr(s)
+		if pdf.GetStringWidth(s) <= colWidth-2 {
+			return s
+		}
+		for len(s) > 0 && pdf.GetStringWidth(s+"...") > colWidth-2 {
+			s = s[:len(s)-1]
+		}
+		return s + "..."
+	}
+
+	pdf.SetHeaderFunc(func() {
+		if t.Title != "" {
+			pdf.SetFont("Helvetica", "B", 14)
+			pdf.CellFormat(0, 10, tr(t.Title), "", 1, "L", false, 0, "")
+		}
+		if len(t.Columns) > 0 {
+			pdf.SetFont("Helvetica", "B", 9)
+			pdf.SetFillColor(230, 230, 230)
+			for _, col := range t.Columns {
+				pdf.CellFormat(colWidth, lineHeight, fit(col), "1", 0, "L", true, 0, "")
+			}
+			pdf.Ln(-1)
+		}
+		pdf.SetFont("Helvetica", "", 9)
+	})
+	pdf.SetFooterFunc(func() {
+		pdf.SetY(-12)
+		pdf.SetFont("Helvetica", "", 8)
+		pdf.CellFormat(0, 8, fmt.Sprintf("%d", pdf.PageNo()), "", 0, "C", false, 0, "")
+	})
+	pdf.AddPage()
+
+	for _, row := range t.Rows {
+		for _, c := range t.textRow(row) {
+			pdf.CellFormat(colWidth, lineHeight, fit(c), "1", 0, "L", false, 0, "")
+		}
+		pdf.Ln(-1)
+	}
+
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Render renders the table in the given format and returns the document with a file name.
+func (t *Table) Render(format Format) ([]byte, string, error) {
+	var (
+		data []byte
+		err  error
+	)
+	switch format {
+	case FormatCSV:
+		data, err = t.CSV()
+	case FormatXLSX:
+		data, err = t.XLSX()
+	case FormatPDF:
+		data, err = t.PDF()
+	default:
+		return nil, "", ErrUnknownFormat
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	return data, t.fileName() + "." + string(format), nil
+}
+
+// fileName returns a file name derived from the title.
+func (t *Table) fileName() string {
+	name := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_':
+			return r
+		case r == ' ':
+			return '_'
+		}
+		return -1
+	}, t.Title)
+	if name == "" {
+		return "report"
+	}
+	return name
+}
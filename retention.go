@@ -0,0 +1,138 @@
+package tgwrapper
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/mymmrac/telego"
+
+	"github.com/0xVanfer/tg-listener/config"
+	"github.com/0xVanfer/tg-listener/retention"
+)
+
+// DefaultRetentionInterval is how often retention limits are enforced.
+const DefaultRetentionInterval = 5 * time.Minute
+
+// retentionPolicies holds retention policies set from code.
+type retentionPolicies struct {
+	policies map[int64]retention.Policy // Policies by chat ID
+	mu       sync.RWMutex               // Mutex for thread-safe policy access
+}
+
+// SetRetention limits the bot's own messages kept in a chat: messages older than
+// maxAge, and all but the maxCount most recent ones, are deleted periodically.
+// A zero value disables the respective limit; both zero removes the policy.
+// Policies set here take precedence over the retention of warning_chat and log_chat.
+func (w *Wrapper) SetRetention(chatID int64, maxAge time.Duration, maxCount int) {
+	policy := retention.Policy{MaxAge: maxAge, MaxCount: maxCount}
+	w.retention.mu.Lock()
+	defer w.retention.mu.Unlock()
+	if w.retention.policies == nil {
+		w.retention.policies = make(map[int64]retention.Policy)
+	}
+	if policy.IsZero() {
+		delete(w.retention.policies, chatID)
+		return
+	}
+	w.retention.policies[chatID] = policy
+}
+
+// retentionPolicy returns the retention policy of a chat.
+// Returns false if the chat has no retention limits.
+func (w *Wrapper) retentionPolicy(chatID int64) (retention.Policy, bool) {
+	w.retention.mu.RLock()
+	policy, ok := w.retention.policies[chatID]
+	w.retention.mu.RUnlock()
+	if ok {
+		return policy, true
+	}
+	for _, chat := range []*config.ChatConfig{w.Config().Bot.WarningChat, w.Config().Bot.LogChat} {
+		if chat != nil && chat.ChatID == chatID && chat.Retention != nil {
+			policy = retention.Policy{MaxAge: chat.Retention.MaxAge, MaxCount: chat.Retention.MaxCount}
+			return policy, !policy.IsZero()
+		}
+	}
+	return retention.Policy{}, false
+}
+
+// retentionChats returns the IDs of all chats with retention limits.
+func (w *Wrapper) retentionChats() []int64 {
+	var ids []int64
+	w.retention.mu.RLock()
+	for id := range w.retention.policies {
+		ids = append(ids, id)
+	}
+	w.retention.mu.RUnlock()
+	for _, chat := range []*config.ChatConfig{w.Config().Bot.WarningChat, w.Config().Bot.LogChat} {
+		if chat != nil && chat.ChatID != 0 && chat.Retention != nil {
+			ids = append(ids, chat.ChatID)
+		}
+	}
+	return ids
+}
+
+// recordSent logs a sent message if its chat has retention limits.
+func (w *Wrapper) recordSent(msg *telego.Message) {
+	if _, ok := w.retentionPolicy(msg.Chat.ID); !ok {
+		return
+	}
+	_ = w.retentionLog().Record(context.Background(), msg.Chat.ID, msg.MessageID, time.Unix(msg.Date, 0))
+}
+
+// retentionLog returns the log of messages sent to chats with retention limits.
+func (w *Wrapper) retentionLog() *retention.Log {
+	w.storeMu.RLock()
+	defer w.storeMu.RUnlock()
+	return w.sentLog
+}
+
+// EnforceRetention deletes the bot's messages exceeding the retention limits of
+// all chats. It runs periodically once the bot is started; call it directly to
+// clean up immediately. Only messages sent through the Send* methods while a
+// policy was in place are tracked. Returns the first deletion error.
+func (w *Wrapper) EnforceRetention(ctx context.Context) error {
+	var firstErr error
+	seen := make(map[int64]bool)
+	now := time.Now()
+	for _, chatID := range w.retentionChats() {
+		if seen[chatID] {
+			continue
+		}
+		seen[chatID] = true
+		policy, ok := w.retentionPolicy(chatID)
+		if !ok {
+			continue
+		}
+		ids, err := w.retentionLog().Expire(ctx, chatID, policy, now)
+		if err == nil && len(ids) > 0 {
+			err = w.bot.DeleteMessages(ctx, chatID, ids)
+		}
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// startRetentionTask enforces message retention limits and prunes the event log
+// and usage counts periodically until ctx is done.
+func (w *Wrapper) startRetentionTask(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-w.stopChan:
+				return
+			case <-ticker.C:
+				_ = w.EnforceRetention(ctx)
+				_ = w.pruneEvents(ctx)
+				_ = w.pruneUsage(ctx)
+			}
+		}
+	}()
+}
@@ -0,0 +1,129 @@
+// Package retention keeps a persistent log of the bot's own messages per chat
+// and selects the ones that exceed a chat's age or count limits, so channels
+// that would otherwise grow unbounded can be cleaned up.
+package retention
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/0xVanfer/tg-listener/store"
+)
+
+// keyPrefix is the store key prefix for per-chat message logs.
+const keyPrefix = "retention:"
+
+// Policy limits the bot's messages kept in a chat.
+type Policy struct {
+	MaxAge   time.Duration // Age after which messages expire; 0 disables
+	MaxCount int           // Number of most recent messages to keep; 0 disables
+}
+
+// IsZero returns true if no limit is set.
+func (p Policy) IsZero() bool {
+	return p.MaxAge <= 0 && p.MaxCount <= 0
+}
+
+// Entry is a logged bot message.
+type Entry struct {
+	MessageID int       `json:"id"`
+	SentAt    time.Time `json:"at"`
+}
+
+// Log records the bot's messages per chat in a store.
+type Log struct {
+	store store.Store // Backing store
+	mu    sync.Mutex  // Serializes read-modify-write cycles
+}
+
+// NewLog creates a message log persisted in the given store.
+func NewLog(s store.Store) *Log {
+	return &Log{store: s}
+}
+
+// chatKey returns the store key for a chat's message log.
+func chatKey(chatID int64) string {
+	return keyPrefix + strconv.FormatInt(chatID, 10)
+}
+
+// load reads a chat's log, treating a missing key as an empty log.
+func (l *Log) load(ctx context.Context, chatID int64) ([]Entry, error) {
+	var entries []Entry
+	err := store.GetJSON(ctx, l.store, chatKey(chatID), &entries)
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// Record logs a message sent to a chat.
+func (l *Log) Record(ctx context.Context, chatID int64, messageID int, sentAt time.Time) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	entries, err := l.load(ctx, chatID)
+	if err != nil {
+		return err
+	}
+	entries = append(entries, Entry{MessageID: messageID, SentAt: sentAt})
+	return store.PutJSON(ctx, l.store, chatKey(chatID), entries)
+}
+
+// Entries returns the logged messages of a chat, oldest first.
+func (l *Log) Entries(ctx context.Context, chatID int64) ([]Entry, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.load(ctx, chatID)
+}
+
+// Expire removes the messages exceeding the policy from a chat's log and
+// returns their IDs for deletion. Messages older than MaxAge expire, as do
+// all but the MaxCount most recent ones.
+func (l *Log) Expire(ctx context.Context, chatID int64, policy Policy, now time.Time) ([]int, error) {
+	if policy.IsZero() {
+		return nil, nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	entries, err := l.load(ctx, chatID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Entries are appended in send order, so expired ones form a prefix
+	cut := 0
+	if policy.MaxCount > 0 && len(entries) > policy.MaxCount {
+		cut = len(entries) - policy.MaxCount
+	}
+	if policy.MaxAge > 0 {
+		for cut < len(entries) && now.Sub(entries[cut].SentAt) > policy.MaxAge {
+			cut++
+		}
+	}
+	if cut == 0 {
+		return nil, nil
+	}
+
+	ids := make([]int, cut)
+	for i, e := range entries[:cut] {
+		ids[i] = e.MessageID
+	}
+	if cut == len(entries) {
+		err = l.store.Delete(ctx, chatKey(chatID))
+	} else {
+		err = store.PutJSON(ctx, l.store, chatKey(chatID), entries[cut:])
+	}
+	if err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+// Forget removes a chat's log without deleting its messages.
+func (l *Log) Forget(ctx context.Context, chatID int64) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.store.Delete(ctx, chatKey(chatID))
+}
@@ -0,0 +1,38 @@
+package tgwrapper
+
+import (
+	"context"
+
+	"github.com/0xVanfer/tg-listener/config"
+	"github.com/0xVanfer/tg-listener/core"
+	"github.com/0xVanfer/tg-listener/handler"
+)
+
+// SetRoleProvider sets a function that returns roles of a user in addition to
+// those assigned under roles in the configuration, e.g. from a database.
+// It is called at most once per update, when a role check first needs it.
+func (w *Wrapper) SetRoleProvider(provider handler.RoleProvider) {
+	w.router.SetRoleProvider(provider)
+}
+
+// Roles returns the roles of a user: "user", the configured roles listing the
+// user, and those of the role provider.
+func (w *Wrapper) Roles(ctx context.Context, userID int64, username string) []string {
+	return w.router.Roles(ctx, userID, username)
+}
+
+// checkFlowRoles returns a FlowDeniedError if the user lacks the roles of a flow.
+// The sender's roles are used when userID is the user being handled, otherwise they are looked up by user ID.
+func (w *Wrapper) checkFlowRoles(ctx context.Context, userID int64, flow *config.FlowConfig) error {
+	if len(flow.Roles) == 0 {
+		return nil
+	}
+	roles := core.RolesFor(ctx, userID)
+	if roles == nil {
+		roles = w.Roles(ctx, userID, "")
+	}
+	if config.HasRole(roles, flow.Roles) {
+		return nil
+	}
+	return &FlowDeniedError{FlowID: flow.ID, Text: w.Config().Bot.GetForbiddenText()}
+}
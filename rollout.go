@@ -0,0 +1,37 @@
+package tgwrapper
+
+import (
+	"context"
+	"log"
+
+	"github.com/0xVanfer/tg-listener/config"
+	"github.com/0xVanfer/tg-listener/conv"
+)
+
+// InRollout returns true if a user is included in the rollout of a flow,
+// e.g. to label a beta entry point. Flows without a rollout include everyone.
+func (w *Wrapper) InRollout(userID int64, flowID string) bool {
+	flow := w.Config().GetFlow(flowID)
+	return flow != nil && flow.Rollout.Includes(userID, flow.ID)
+}
+
+// startRolloutFallback gives a user outside a flow's rollout its fallback:
+// the fallback flow is started, or the fallback menu is shown and a
+// FlowDeniedError naming it is returned. Without either, the user is denied.
+func (w *Wrapper) startRolloutFallback(ctx context.Context, userID, chatID int64, topicID int, flow *config.FlowConfig, keyboardMsgID int) (*conv.Conversation, error) {
+	r := flow.Rollout
+	if w.Config().Bot.Debug {
+		log.Printf("[Rollout] User %d is outside the rollout of flow %s", userID, flow.ID)
+	}
+	if r.FallbackFlow != "" {
+		return w.StartConversation(ctx, userID, chatID, topicID, r.FallbackFlow, keyboardMsgID)
+	}
+	denied := &FlowDeniedError{FlowID: flow.ID, Text: w.Config().Bot.GetForbiddenText()}
+	if r.FallbackMenu != "" {
+		if err := w.ShowMenu(ctx, chatID, topicID, r.FallbackMenu, keyboardMsgID); err != nil {
+			return nil, err
+		}
+		denied.MenuID = r.FallbackMenu
+	}
+	return nil, denied
+}
@@ -0,0 +1,330 @@
+package tgwrapper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mymmrac/telego"
+
+	"github.com/0xVanfer/tg-listener/config"
+	"github.com/0xVanfer/tg-listener/core"
+	"github.com/0xVanfer/tg-listener/report"
+	"github.com/0xVanfer/tg-listener/rsvp"
+)
+
+// RSVPCallbackPrefix is the callback data prefix of RSVP buttons.
+// The data is the prefix, the event ID, ":", and the chosen status.
+const RSVPCallbackPrefix = "rsvp:"
+
+// RSVPPromotedFunc is called when a waitlisted user gets a place at an event,
+// e.g. to let them know in a private message.
+type RSVPPromotedFunc func(ctx context.Context, e *rsvp.Event, r rsvp.Response)
+
+// rsvpTimers holds the closing timers of open signup sheets.
+type rsvpTimers struct {
+	timers     map[string]*time.Timer // Closing timers by event ID
+	onPromoted RSVPPromotedFunc       // User callback for promoted waitlisted users
+	mu         sync.Mutex             // Mutex for thread-safe timer access
+}
+
+// RSVPs returns the tracker of event signup sheets.
+func (w *Wrapper) RSVPs() *rsvp.Tracker {
+	w.storeMu.RLock()
+	defer w.storeMu.RUnlock()
+	return w.rsvpEvents
+}
+
+// SetOnRSVPPromoted sets a callback for waitlisted users who get a place.
+func (w *Wrapper) SetOnRSVPPromoted(fn RSVPPromotedFunc) {
+	w.rsvps.mu.Lock()
+	defer w.rsvps.mu.Unlock()
+	w.rsvps.onPromoted = fn
+}
+
+// StartRSVP posts a configured signup sheet to a chat and returns its event.
+// The attendee lists and button counts update with every response. Responses
+// and the signup deadline are persisted in the store, and open sheets close on
+// time after a restart.
+func (w *Wrapper) StartRSVP(ctx context.Context, chatID int64, topicID int, rsvpID string) (*rsvp.Event, error) {
+	cfg := w.Config().GetRSVP(rsvpID)
+	if cfg == nil {
+		return nil, fmt.Errorf("rsvp %q not found", rsvpID)
+	}
+	id, err := rsvp.NewID()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	e := &rsvp.Event{
+		ID:          id,
+		ConfigID:    cfg.ID,
+		ChatID:      chatID,
+		TopicID:     topicID,
+		Title:       cfg.Title,
+		Description: cfg.Description,
+		Capacity:    cfg.Capacity,
+		Waitlist:    cfg.Waitlist,
+		StartedAt:   now,
+	}
+	if cfg.Duration > 0 {
+		e.ClosesAt = now.Add(cfg.Duration)
+	}
+
+	text, entities := rsvpText(e)
+	msg, err := w.bot.SendMessageWithKeyboard(ctx, chatID, topicID, text, w.rsvpKeyboard(e), entities...)
+	if err != nil {
+		return nil, err
+	}
+	if msg != nil {
+		e.MessageID = msg.MessageID
+	}
+	if err := w.RSVPs().Save(ctx, e); err != nil {
+		return nil, err
+	}
+	w.scheduleRSVP(e)
+	return e, nil
+}
+
+// CloseRSVP closes signups and shows the final attendee lists without buttons.
+// Closing a closed sheet returns its event.
+func (w *Wrapper) CloseRSVP(ctx context.Context, id string) (*rsvp.Event, error) {
+	w.cancelRSVP(id)
+	e, closed, err := w.RSVPs().Close(ctx, id, time.Now())
+	if err != nil || !closed {
+		return e, err
+	}
+
+	if e.MessageID > 0 {
+		text, entities := rsvpText(e)
+		_, _ = w.bot.EditMessageWithKeyboard(ctx, e.ChatID, e.MessageID, text, nil, entities...)
+	}
+	return e, nil
+}
+
+// ExportRSVP returns the responses of an event as a report table, going users
+// first, then the waitlist in order, maybe, and not going.
+// Send it as a document with SendReport.
+func (w *Wrapper) ExportRSVP(ctx context.Context, id string) (*report.Table, error) {
+	e, err := w.RSVPs().Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	table := report.NewTable(e.Title, "Name", "User ID", "Status", "Responded")
+	for _, status := range []rsvp.Status{rsvp.StatusGoing, rsvp.StatusWaitlist, rsvp.StatusMaybe, rsvp.StatusNo} {
+		for _, r := range e.With(status) {
+			table.AddRow(r.Name, r.UserID, string(r.Status), r.At)
+		}
+	}
+	return table, nil
+}
+
+// rsvpText renders the title and attendee lists of an event.
+func rsvpText(e *rsvp.Event) (string, []telego.MessageEntity) {
+	b := core.NewBuilder().Bold(e.Title).Ln()
+	if e.Description != "" {
+		b.Text(e.Description).Ln()
+	}
+
+	going := fmt.Sprintf("✅ Going (%d)", len(e.With(rsvp.StatusGoing)))
+	if e.Capacity > 0 {
+		going = fmt.Sprintf("✅ Going (%d/%d)", len(e.With(rsvp.StatusGoing)), e.Capacity)
+	}
+	sections := []struct {
+		title  string
+		status rsvp.Status
+	}{
+		{going, rsvp.StatusGoing},
+		{fmt.Sprintf("⏳ Waitlist (%d)", len(e.With(rsvp.StatusWaitlist))), rsvp.StatusWaitlist},
+		{fmt.Sprintf("🤔 Maybe (%d)", len(e.With(rsvp.StatusMaybe))), rsvp.StatusMaybe},
+		{fmt.Sprintf("❌ Not going (%d)", len(e.With(rsvp.StatusNo))), rsvp.StatusNo},
+	}
+	for _, section := range sections {
+		responses := e.With(section.status)
+		if section.status == rsvp.StatusWaitlist && len(responses) == 0 {
+			continue
+		}
+		b.Ln().Text(section.title)
+		for i, r := range responses {
+			if i == 0 {
+				b.Ln().Text("    ")
+			} else {
+				b.Text(", ")
+			}
+			b.UserMention(r.Name, r.UserID)
+		}
+	}
+
+	b.Ln().Ln()
+	switch {
+	case e.Closed():
+		b.Text("🏁 Signups closed")
+	case e.Full() && !e.Waitlist:
+		b.Text("🔒 Full")
+	case !e.ClosesAt.IsZero():
+		b.Text(fmt.Sprintf("🗓 Signups close %s UTC", e.ClosesAt.UTC().Format("Jan 2 15:04")))
+	default:
+		b.Text("🗓 Signups open")
+	}
+	return b.Build()
+}
+
+// rsvpKeyboard returns the Going, Maybe, and No buttons of an event, each with its count.
+func (w *Wrapper) rsvpKeyboard(e *rsvp.Event) *telego.InlineKeyboardMarkup {
+	cfg := w.Config().GetRSVP(e.ConfigID)
+	if cfg == nil {
+		cfg = &config.RSVPConfig{}
+	}
+	button := func(text string, status rsvp.Status) telego.InlineKeyboardButton {
+		return core.Button(fmt.Sprintf("%s · %d", text, len(e.With(status))), RSVPCallbackPrefix+e.ID+":"+string(status))
+	}
+	return core.NewKeyboard().Row(
+		button(cfg.GetGoingText(), rsvp.StatusGoing),
+		button(cfg.GetMaybeText(), rsvp.StatusMaybe),
+		button(cfg.GetNoText(), rsvp.StatusNo),
+	).Build()
+}
+
+// rsvpAnswer returns the callback answer confirming a user's status.
+func rsvpAnswer(e *rsvp.Event, userID int64, status rsvp.Status) string {
+	switch status {
+	case rsvp.StatusGoing:
+		return "✅ You're going!"
+	case rsvp.StatusWaitlist:
+		for i, r := range e.With(rsvp.StatusWaitlist) {
+			if r.UserID == userID {
+				return fmt.Sprintf("⏳ The event is full. You're #%d on the waitlist.", i+1)
+			}
+		}
+		return "⏳ The event is full. You're on the waitlist."
+	case rsvp.StatusMaybe:
+		return "🤔 Marked as maybe."
+	default:
+		return "Marked as not going."
+	}
+}
+
+// scheduleRSVP arms the closing timer of an open sheet with a deadline.
+func (w *Wrapper) scheduleRSVP(e *rsvp.Event) {
+	if e.Closed() || e.ClosesAt.IsZero() {
+		return
+	}
+	id := e.ID
+	timer := time.AfterFunc(time.Until(e.ClosesAt), func() {
+		_, _ = w.CloseRSVP(context.Background(), id)
+	})
+
+	w.rsvps.mu.Lock()
+	defer w.rsvps.mu.Unlock()
+	if w.rsvps.timers == nil {
+		w.rsvps.timers = make(map[string]*time.Timer)
+	}
+	if old, ok := w.rsvps.timers[id]; ok {
+		old.Stop()
+	}
+	w.rsvps.timers[id] = timer
+}
+
+// cancelRSVP stops the closing timer of a sheet.
+func (w *Wrapper) cancelRSVP(id string) {
+	w.rsvps.mu.Lock()
+	defer w.rsvps.mu.Unlock()
+	if timer, ok := w.rsvps.timers[id]; ok {
+		timer.Stop()
+		delete(w.rsvps.timers, id)
+	}
+}
+
+// resumeRSVPs re-arms the closing timers of sheets open in the store.
+// Sheets whose deadline passed while the bot was down close right away.
+func (w *Wrapper) resumeRSVPs(ctx context.Context) {
+	events, err := w.RSVPs().List(ctx)
+	if err != nil {
+		return
+	}
+	for _, e := range events {
+		w.scheduleRSVP(e)
+	}
+}
+
+// rsvpAllowed checks whether a user may respond: the sheet's roles, and chat
+// membership for sheets restricted to members.
+func (w *Wrapper) rsvpAllowed(ctx context.Context, e *rsvp.Event, user telego.User) (bool, error) {
+	cfg := w.Config().GetRSVP(e.ConfigID)
+	if cfg == nil {
+		return true, nil
+	}
+	return w.eligible(ctx, e.ChatID, user, cfg.Roles, cfg.MembersOnly)
+}
+
+// setupRSVPs registers the RSVP button callback.
+func (w *Wrapper) setupRSVPs() {
+	w.router.RegisterCallbackPrefix(RSVPCallbackPrefix, func(ctx context.Context, query telego.CallbackQuery) error {
+		id, status, _ := strings.Cut(core.ParseCallbackData(query.Data, RSVPCallbackPrefix), ":")
+
+		e, err := w.RSVPs().Get(ctx, id)
+		if errors.Is(err, rsvp.ErrNotFound) {
+			return w.bot.AnswerCallback(ctx, query.ID, "This event no longer exists.")
+		}
+		if err != nil {
+			_ = w.bot.AnswerCallback(ctx, query.ID, "")
+			return err
+		}
+		allowed, err := w.rsvpAllowed(ctx, e, query.From)
+		if err != nil {
+			_ = w.bot.AnswerCallback(ctx, query.ID, "")
+			return err
+		}
+		if !allowed {
+			return w.bot.AnswerCallbackWithAlert(ctx, query.ID, "🚫 You can't sign up for this event.")
+		}
+
+		e, result, err := w.RSVPs().Respond(ctx, id, query.From.ID, userDisplayName(query.From), rsvp.Status(status), time.Now())
+		switch {
+		case errors.Is(err, rsvp.ErrClosed):
+			_ = w.bot.AnswerCallback(ctx, query.ID, "Signups are closed.")
+			if e != nil && e.Expired(time.Now()) {
+				_, err = w.CloseRSVP(ctx, id)
+				return err
+			}
+			return nil
+		case errors.Is(err, rsvp.ErrFull):
+			return w.bot.AnswerCallbackWithAlert(ctx, query.ID, "🔒 The event is full.")
+		case errors.Is(err, rsvp.ErrUnknownStatus):
+			return w.bot.AnswerCallback(ctx, query.ID, "")
+		case err != nil:
+			_ = w.bot.AnswerCallback(ctx, query.ID, "")
+			return err
+		case !result.Changed:
+			return w.bot.AnswerCallback(ctx, query.ID, "Your answer didn't change.")
+		}
+
+		_ = w.bot.AnswerCallback(ctx, query.ID, rsvpAnswer(e, query.From.ID, result.Status))
+		text, entities := rsvpText(e)
+		_, err = w.bot.EditMessageWithKeyboard(ctx, e.ChatID, e.MessageID, text, w.rsvpKeyboard(e), entities...)
+
+		w.rsvps.mu.Lock()
+		fn := w.rsvps.onPromoted
+		w.rsvps.mu.Unlock()
+		if fn != nil {
+			for _, r := range result.Promoted {
+				fn(ctx, e, r)
+			}
+		}
+		return err
+	})
+}
+
+// stopRSVPs stops all closing timers; they resume from the store on the next Start.
+func (w *Wrapper) stopRSVPs() {
+	w.rsvps.mu.Lock()
+	defer w.rsvps.mu.Unlock()
+	for id, timer := range w.rsvps.timers {
+		timer.Stop()
+		delete(w.rsvps.timers, id)
+	}
+}
@@ -0,0 +1,276 @@
+// Package rsvp persists event signup sheets: who is going, maybe, or not going,
+// capacity limits, and waitlists, so attendee lists survive restarts.
+package rsvp
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/0xVanfer/tg-listener/store"
+)
+
+// keyPrefix is the store key prefix for events.
+const keyPrefix = "rsvp:"
+
+var (
+	// ErrNotFound is returned when an event does not exist.
+	ErrNotFound = errors.New("rsvp event not found")
+
+	// ErrClosed is returned when a response is recorded after signups closed.
+	ErrClosed = errors.New("rsvp closed")
+
+	// ErrFull is returned when a user wants to go to a full event without a waitlist.
+	ErrFull = errors.New("rsvp event full")
+
+	// ErrUnknownStatus is returned when a response has a status users can't choose.
+	ErrUnknownStatus = errors.New("unknown rsvp status")
+)
+
+// Status is a user's response to an event.
+type Status string
+
+const (
+	// StatusGoing means the user has a place at the event.
+	StatusGoing Status = "going"
+
+	// StatusWaitlist means the user wants to go but the event is full.
+	// Waitlisted users move up in response order when places free up.
+	StatusWaitlist Status = "waitlist"
+
+	// StatusMaybe means the user might go.
+	StatusMaybe Status = "maybe"
+
+	// StatusNo means the user is not going.
+	StatusNo Status = "no"
+)
+
+// Response records a user's answer.
+type Response struct {
+	UserID int64     `json:"user_id"`
+	Name   string    `json:"name"` // Display name at the time of responding
+	Status Status    `json:"status"`
+	At     time.Time `json:"at"` // When the status last changed; orders the waitlist
+}
+
+// Event is the persisted state of a signup sheet.
+type Event struct {
+	ID          string     `json:"id"`
+	ConfigID    string     `json:"config_id"` // ID of the RSVP configuration it was started from
+	ChatID      int64      `json:"chat_id"`
+	TopicID     int        `json:"topic_id"`
+	MessageID   int        `json:"message_id"` // Message showing the attendee list
+	Title       string     `json:"title"`
+	Description string     `json:"description,omitempty"`
+	Capacity    int        `json:"capacity,omitempty"` // Places for going users; 0 is unlimited
+	Waitlist    bool       `json:"waitlist"`           // Whether users can queue for a full event
+	StartedAt   time.Time  `json:"started_at"`
+	ClosesAt    time.Time  `json:"closes_at,omitempty"` // Signup deadline; zero if closed by hand
+	ClosedAt    time.Time  `json:"closed_at,omitempty"` // When signups closed; zero while open
+	Responses   []Response `json:"responses,omitempty"`
+}
+
+// Result describes the outcome of a response.
+type Result struct {
+	Status   Status     // Status the user ended up with
+	Changed  bool       // Whether the user's status changed
+	Promoted []Response // Waitlisted users who got a place freed by the response
+}
+
+// Closed returns true if signups were closed.
+func (e *Event) Closed() bool {
+	return !e.ClosedAt.IsZero()
+}
+
+// Expired returns true if the signup deadline passed at the given time.
+func (e *Event) Expired(at time.Time) bool {
+	return !e.ClosesAt.IsZero() && !at.Before(e.ClosesAt)
+}
+
+// Full returns true if every place is taken.
+func (e *Event) Full() bool {
+	return e.Capacity > 0 && len(e.With(StatusGoing)) >= e.Capacity
+}
+
+// ResponseOf returns the response of a user, or nil if the user hasn't responded.
+func (e *Event) ResponseOf(userID int64) *Response {
+	for i := range e.Responses {
+		if e.Responses[i].UserID == userID {
+			return &e.Responses[i]
+		}
+	}
+	return nil
+}
+
+// With returns the responses with a status, oldest first.
+func (e *Event) With(status Status) []Response {
+	var responses []Response
+	for _, r := range e.Responses {
+		if r.Status == status {
+			responses = append(responses, r)
+		}
+	}
+	return responses
+}
+
+// promote gives free places to waitlisted users in waitlist order and returns them.
+func (e *Event) promote(at time.Time) []Response {
+	var promoted []Response
+	for !e.Full() {
+		next := -1
+		for i, r := range e.Responses {
+			if r.Status == StatusWaitlist && (next < 0 || r.At.Before(e.Responses[next].At)) {
+				next = i
+			}
+		}
+		if next < 0 {
+			break
+		}
+		e.Responses[next].Status = StatusGoing
+		e.Responses[next].At = at
+		promoted = append(promoted, e.Responses[next])
+	}
+	return promoted
+}
+
+// Tracker persists events in a store.
+type Tracker struct {
+	store store.Store // Backing store
+	mu    sync.Mutex  // Serializes read-modify-write cycles
+}
+
+// NewTracker creates an event tracker persisted in the given store.
+func NewTracker(s store.Store) *Tracker {
+	return &Tracker{store: s}
+}
+
+// NewID returns a random event ID, short enough for callback data.
+func NewID() (string, error) {
+	buf := make([]byte, 6)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// Save stores an event, replacing any previous event with the same ID.
+func (t *Tracker) Save(ctx context.Context, e *Event) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return store.PutJSON(ctx, t.store, keyPrefix+e.ID, e)
+}
+
+// Get retrieves an event.
+// Returns ErrNotFound if the event does not exist.
+func (t *Tracker) Get(ctx context.Context, id string) (*Event, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.get(ctx, id)
+}
+
+// get retrieves an event without locking.
+func (t *Tracker) get(ctx context.Context, id string) (*Event, error) {
+	var e Event
+	if err := store.GetJSON(ctx, t.store, keyPrefix+id, &e); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return &e, nil
+}
+
+// Respond records a user's response: StatusGoing, StatusMaybe, or StatusNo.
+// Going to a full event puts the user on the waitlist, or returns ErrFull if
+// the event has none. A going user changing their answer frees a place for
+// the first waitlisted user.
+func (t *Tracker) Respond(ctx context.Context, id string, userID int64, name string, status Status, at time.Time) (*Event, Result, error) {
+	if status != StatusGoing && status != StatusMaybe && status != StatusNo {
+		return nil, Result{}, ErrUnknownStatus
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	e, err := t.get(ctx, id)
+	if err != nil {
+		return nil, Result{}, err
+	}
+	if e.Closed() || e.Expired(at) {
+		return e, Result{}, ErrClosed
+	}
+
+	r := e.ResponseOf(userID)
+	if r != nil && (r.Status == status || (r.Status == StatusWaitlist && status == StatusGoing)) {
+		return e, Result{Status: r.Status}, nil
+	}
+	if status == StatusGoing && e.Full() {
+		if !e.Waitlist {
+			return e, Result{}, ErrFull
+		}
+		status = StatusWaitlist
+	}
+
+	if r != nil {
+		r.Status, r.Name, r.At = status, name, at
+	} else {
+		e.Responses = append(e.Responses, Response{UserID: userID, Name: name, Status: status, At: at})
+	}
+	result := Result{Status: status, Changed: true, Promoted: e.promote(at)}
+
+	if err := store.PutJSON(ctx, t.store, keyPrefix+id, e); err != nil {
+		return nil, Result{}, err
+	}
+	return e, result, nil
+}
+
+// Close closes signups, so no more responses are accepted.
+// Returns the final event and false if signups were already closed.
+func (t *Tracker) Close(ctx context.Context, id string, at time.Time) (*Event, bool, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	e, err := t.get(ctx, id)
+	if err != nil {
+		return nil, false, err
+	}
+	if e.Closed() {
+		return e, false, nil
+	}
+	e.ClosedAt = at
+	if err := store.PutJSON(ctx, t.store, keyPrefix+id, e); err != nil {
+		return nil, false, err
+	}
+	return e, true, nil
+}
+
+// List returns all events.
+func (t *Tracker) List(ctx context.Context) ([]*Event, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	keys, err := t.store.List(ctx, keyPrefix)
+	if err != nil {
+		return nil, err
+	}
+	events := make([]*Event, 0, len(keys))
+	for _, key := range keys {
+		e, err := t.get(ctx, strings.TrimPrefix(key, keyPrefix))
+		if errors.Is(err, ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, e)
+	}
+	return events, nil
+}
+
+// Delete removes an event.
+func (t *Tracker) Delete(ctx context.Context, id string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.store.Delete(ctx, keyPrefix+id)
+}
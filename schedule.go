@@ -0,0 +1,116 @@
+package tgwrapper
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/mymmrac/telego"
+
+	"github.com/0xVanfer/tg-listener/core"
+	"github.com/0xVanfer/tg-listener/scheduler"
+)
+
+// scheduledSendKind is the delayed task kind of messages sent with SendAt.
+const scheduledSendKind = "_send"
+
+// scheduledSend is the payload of a message sent with SendAt.
+type scheduledSend struct {
+	ChatID   int64                  `json:"chat_id"`
+	TopicID  int                    `json:"topic_id,omitempty"`
+	Text     string                 `json:"text"`
+	Entities []telego.MessageEntity `json:"entities,omitempty"`
+}
+
+// Scheduler returns the scheduler running recurring jobs and delayed tasks.
+// Bots can register their own delayed task kinds with Handle and schedule
+// them with At, e.g. follow-ups carrying conversation data.
+func (w *Wrapper) Scheduler() *scheduler.Scheduler {
+	return w.scheduler
+}
+
+// Schedule runs job on a schedule while the bot is running, and returns the
+// job's ID for Unschedule. The specification is a five-field cron expression,
+// a descriptor such as "@daily", or "@every 30m"; see scheduler.Parse.
+// Recurring jobs are not persisted: register them at startup.
+//
+// Example:
+//
+//	_, err := wrapper.Schedule("TZ=Europe/Berlin 0 9 * * MON-FRI", func(ctx context.Context) {
+//		wrapper.SendReport(ctx, chatID, 0, dailyReport(ctx), nil)
+//	})
+func (w *Wrapper) Schedule(spec string, job scheduler.Job) (string, error) {
+	schedule, err := scheduler.Parse(spec)
+	if err != nil {
+		return "", err
+	}
+	id, err := scheduler.NewID()
+	if err != nil {
+		return "", err
+	}
+	w.scheduler.Add(id, schedule, job)
+	return id, nil
+}
+
+// Unschedule stops a recurring job started with Schedule.
+// Returns false if no job has the ID.
+func (w *Wrapper) Unschedule(id string) bool {
+	return w.scheduler.Remove(id)
+}
+
+// SendAt sends a message built with b to a chat at the given time, and
+// returns the ID of the delayed task for CancelSend. The message is
+// persisted, so it is sent after a restart; messages that fell due while the
+// bot was down are sent on the next Start.
+func (w *Wrapper) SendAt(ctx context.Context, at time.Time, chatID int64, topicID int, b *core.Builder) (string, error) {
+	text, entities := b.Build()
+	task, err := w.scheduler.At(ctx, at, scheduledSendKind, scheduledSend{
+		ChatID:   chatID,
+		TopicID:  topicID,
+		Text:     text,
+		Entities: entities,
+	})
+	if err != nil {
+		return "", err
+	}
+	return task.ID, nil
+}
+
+// CancelSend cancels a message scheduled with SendAt.
+// Returns scheduler.ErrNotFound if it was already sent or cancelled.
+func (w *Wrapper) CancelSend(ctx context.Context, id string) error {
+	return w.scheduler.Cancel(ctx, id)
+}
+
+// sendScheduled sends a message scheduled with SendAt.
+func (w *Wrapper) sendScheduled(ctx context.Context, task *scheduler.Task) error {
+	var msg scheduledSend
+	if err := task.Decode(&msg); err != nil {
+		return err
+	}
+	_, err := w.bot.SendMessage(ctx, msg.ChatID, msg.TopicID, msg.Text, msg.Entities...)
+	return err
+}
+
+// schedulerPanic logs panics of scheduled jobs and reports them like handler panics.
+func (w *Wrapper) schedulerPanic(id string, value interface{}, stack []byte) {
+	log.Printf("[Panic] scheduled %s: %v\n%s", id, value, stack)
+	if !w.Config().Bot.ReportPanics {
+		return
+	}
+	trace := string(stack)
+	if len(trace) > maxPanicStack {
+		trace = trace[:maxPanicStack] + "…"
+	}
+	msg := core.NewBuilder().Text(fmt.Sprintf("Scheduled %s: %v", id, value)).Ln().Pre(trace, "")
+	if err := w.Warn(context.Background(), fmt.Sprintf("panic: %v", value), msg); err != nil && w.Config().Bot.Debug {
+		log.Printf("[Panic] Failed to report: %v", err)
+	}
+}
+
+// setupScheduler registers the scheduler's built-in task handlers.
+func (w *Wrapper) setupScheduler() {
+	w.scheduler.SetPanicHandler(w.schedulerPanic)
+	w.scheduler.Handle(scheduledSendKind, w.sendScheduled)
+}
@@ -0,0 +1,258 @@
+// Package scheduler runs recurring jobs on cron schedules and delayed tasks
+// that are persisted in a store, so they run after a restart.
+package scheduler
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/0xVanfer/tg-listener/tz"
+)
+
+// ErrInvalidSpec is returned when a schedule specification cannot be parsed.
+var ErrInvalidSpec = errors.New("invalid schedule")
+
+// Schedule computes the run times of a recurring job.
+type Schedule interface {
+	// Next returns the first run time after the given time, or the zero time
+	// if there is none.
+	Next(after time.Time) time.Time
+}
+
+// descriptors are the predefined schedules accepted by Parse.
+var descriptors = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// Parse parses a schedule specification:
+//   - a cron expression with five fields: minute, hour, day of month, month,
+//     and day of week, e.g. "30 9 * * MON-FRI". Fields accept "*", lists,
+//     ranges, steps ("*/15"), and month and day names
+//   - a descriptor: @yearly, @monthly, @weekly, @daily, or @hourly
+//   - "@every <duration>", e.g. "@every 90m"
+//
+// Cron expressions and descriptors run in UTC unless prefixed with a time
+// zone as "TZ=Europe/Berlin 0 9 * * *".
+func Parse(spec string) (Schedule, error) {
+	spec = strings.TrimSpace(spec)
+	loc := time.UTC
+	if rest, ok := strings.CutPrefix(spec, "TZ="); ok {
+		name, expr, found := strings.Cut(rest, " ")
+		if !found {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidSpec, spec)
+		}
+		l, err := tz.Load(name)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %q: %v", ErrInvalidSpec, spec, err)
+		}
+		loc, spec = l, strings.TrimSpace(expr)
+	}
+
+	if d, ok := strings.CutPrefix(spec, "@every "); ok {
+		every, err := time.ParseDuration(strings.TrimSpace(d))
+		if err != nil || every < time.Second {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidSpec, spec)
+		}
+		return Every(every), nil
+	}
+	if expr, ok := descriptors[spec]; ok {
+		spec = expr
+	}
+
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("%w: %q: expected 5 fields", ErrInvalidSpec, spec)
+	}
+	c := &cronSchedule{loc: loc}
+	var err error
+	if c.minute, err = parseField(fields[0], 0, 59, nil); err != nil {
+		return nil, err
+	}
+	if c.hour, err = parseField(fields[1], 0, 23, nil); err != nil {
+		return nil, err
+	}
+	if c.dom, err = parseField(fields[2], 1, 31, nil); err != nil {
+		return nil, err
+	}
+	if c.month, err = parseField(fields[3], 1, 12, monthNames); err != nil {
+		return nil, err
+	}
+	if c.dow, err = parseField(fields[4], 0, 7, dayNames); err != nil {
+		return nil, err
+	}
+	// Sunday is both 0 and 7
+	if c.dow&(1<<7) != 0 {
+		c.dow |= 1
+	}
+	c.anyDom = fields[2] == "*" || fields[2] == "?"
+	c.anyDow = fields[4] == "*" || fields[4] == "?"
+	return c, nil
+}
+
+// MustParse is like Parse but panics if the specification is invalid.
+// Use it for specifications fixed in code.
+func MustParse(spec string) Schedule {
+	s, err := Parse(spec)
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+// monthNames and dayNames are the names accepted in the month and day-of-week fields.
+var (
+	monthNames = map[string]int{
+		"JAN": 1, "FEB": 2, "MAR": 3, "APR": 4, "MAY": 5, "JUN": 6,
+		"JUL": 7, "AUG": 8, "SEP": 9, "OCT": 10, "NOV": 11, "DEC": 12,
+	}
+	dayNames = map[string]int{
+		"SUN": 0, "MON": 1, "TUE": 2, "WED": 3, "THU": 4, "FRI": 5, "SAT": 6,
+	}
+)
+
+// parseField parses a cron field into a bit set of the allowed values.
+func parseField(field string, min, max int, names map[string]int) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		expr, stepText, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			var err error
+			if step, err = strconv.Atoi(stepText); err != nil || step <= 0 {
+				return 0, fmt.Errorf("%w: %q", ErrInvalidSpec, field)
+			}
+		}
+
+		lo, hi := min, max
+		if expr != "*" && expr != "?" {
+			first, last, isRange := strings.Cut(expr, "-")
+			var err error
+			if lo, err = fieldValue(first, names); err != nil {
+				return 0, fmt.Errorf("%w: %q", ErrInvalidSpec, field)
+			}
+			hi = lo
+			if isRange {
+				if hi, err = fieldValue(last, names); err != nil {
+					return 0, fmt.Errorf("%w: %q", ErrInvalidSpec, field)
+				}
+			} else if hasStep {
+				hi = max
+			}
+		}
+		if lo < min || hi > max || lo > hi {
+			return 0, fmt.Errorf("%w: %q out of range", ErrInvalidSpec, field)
+		}
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+// fieldValue parses a number or a name in a cron field.
+func fieldValue(s string, names map[string]int) (int, error) {
+	if v, ok := names[strings.ToUpper(s)]; ok {
+		return v, nil
+	}
+	return strconv.Atoi(s)
+}
+
+// cronSchedule is a parsed cron expression.
+type cronSchedule struct {
+	minute, hour, dom, month, dow uint64         // Bit sets of allowed values
+	anyDom, anyDow                bool           // Whether the day fields are unrestricted
+	loc                           *time.Location // Time zone the fields refer to
+}
+
+// Next returns the first matching minute after the given time.
+// Gives up after five years, e.g. for February 30th. Times skipped when
+// clocks go forward don't run; times repeated when clocks go back run once,
+// at their first occurrence.
+func (c *cronSchedule) Next(after time.Time) time.Time {
+	t := after.In(c.loc).Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+
+	for t.Before(limit) {
+		if c.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, c.loc)
+			continue
+		}
+		if !c.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, c.loc)
+			continue
+		}
+		if c.hour&(1<<uint(t.Hour())) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, c.loc)
+			continue
+		}
+		if c.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+		if first, ok := firstOccurrence(t); ok {
+			if first.After(after) {
+				return first
+			}
+			// Ran at the first occurrence already
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+// firstOccurrence returns the first occurrence of the wall clock time of t
+// if t is its second occurrence, in the hour repeated when clocks go back.
+func firstOccurrence(t time.Time) (time.Time, bool) {
+	_, offset := t.Zone()
+	_, before := t.Add(-12 * time.Hour).Zone()
+	if before <= offset {
+		return time.Time{}, false
+	}
+	first := t.Add(-time.Duration(before-offset) * time.Second)
+	if first.Hour() != t.Hour() || first.Minute() != t.Minute() {
+		return time.Time{}, false
+	}
+	return first, true
+}
+
+// dayMatches reports whether a day matches the day-of-month and day-of-week
+// fields. As in cron, if both are restricted, either may match.
+func (c *cronSchedule) dayMatches(t time.Time) bool {
+	dom := c.dom&(1<<uint(t.Day())) != 0
+	dow := c.dow&(1<<uint(t.Weekday())) != 0
+	switch {
+	case c.anyDom && c.anyDow:
+		return true
+	case c.anyDom:
+		return dow
+	case c.anyDow:
+		return dom
+	default:
+		return dom || dow
+	}
+}
+
+// Every returns a schedule running at a fixed interval, starting one
+// interval after the scheduler starts.
+func Every(d time.Duration) Schedule {
+	return everySchedule(d)
+}
+
+// everySchedule runs at a fixed interval.
+type everySchedule time.Duration
+
+// Next returns the time one interval after the given time.
+func (e everySchedule) Next(after time.Time) time.Time {
+	return after.Add(time.Duration(e))
+}
@@ -0,0 +1,169 @@
+package scheduler
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// utc returns a UTC time on the given day and minute.
+func utc(year int, month time.Month, day, hour, minute int) time.Time {
+	return time.Date(year, month, day, hour, minute, 0, 0, time.UTC)
+}
+
+func TestParseInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		spec string
+	}{
+		{"too few fields", "* * * *"},
+		{"too many fields", "* * * * * *"},
+		{"minute out of range", "60 * * * *"},
+		{"hour out of range", "0 24 * * *"},
+		{"day of month zero", "0 0 0 * *"},
+		{"reversed range", "0 0 * * FRI-MON"},
+		{"zero step", "*/0 * * * *"},
+		{"unknown name", "0 0 * FOO *"},
+		{"unknown zone", "TZ=Mars/Olympus 0 9 * * *"},
+		{"zone without expression", "TZ=Europe/Berlin"},
+		{"every below a second", "@every 10ms"},
+		{"every without duration", "@every soon"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Parse(tt.spec); !errors.Is(err, ErrInvalidSpec) {
+				t.Errorf("Parse(%q) error = %v, want ErrInvalidSpec", tt.spec, err)
+			}
+		})
+	}
+}
+
+func TestNext(t *testing.T) {
+	tests := []struct {
+		name  string
+		spec  string
+		after time.Time
+		want  []time.Time // Consecutive run times
+	}{
+		{
+			name:  "step",
+			spec:  "*/15 * * * *",
+			after: utc(2026, 1, 1, 10, 7),
+			want:  []time.Time{utc(2026, 1, 1, 10, 15), utc(2026, 1, 1, 10, 30)},
+		},
+		{
+			name:  "exact minute is not repeated",
+			spec:  "0 * * * *",
+			after: utc(2026, 1, 1, 10, 0),
+			want:  []time.Time{utc(2026, 1, 1, 11, 0)},
+		},
+		{
+			name:  "weekdays skip the weekend",
+			spec:  "30 9 * * MON-FRI",
+			after: utc(2026, 1, 2, 10, 0),
+			want:  []time.Time{utc(2026, 1, 5, 9, 30), utc(2026, 1, 6, 9, 30)},
+		},
+		{
+			name:  "sunday as 7",
+			spec:  "0 0 * * 7",
+			after: utc(2026, 1, 1, 0, 0),
+			want:  []time.Time{utc(2026, 1, 4, 0, 0), utc(2026, 1, 11, 0, 0)},
+		},
+		{
+			name:  "day of month or day of week",
+			spec:  "0 12 13 * FRI",
+			after: utc(2026, 2, 1, 0, 0),
+			want:  []time.Time{utc(2026, 2, 6, 12, 0), utc(2026, 2, 13, 12, 0), utc(2026, 2, 20, 12, 0)},
+		},
+		{
+			name:  "month end",
+			spec:  "0 0 31 * *",
+			after: utc(2026, 1, 31, 0, 0),
+			want:  []time.Time{utc(2026, 3, 31, 0, 0), utc(2026, 5, 31, 0, 0)},
+		},
+		{
+			name:  "leap day",
+			spec:  "0 0 29 FEB *",
+			after: utc(2026, 1, 1, 0, 0),
+			want:  []time.Time{utc(2028, 2, 29, 0, 0)},
+		},
+		{
+			name:  "yearly descriptor",
+			spec:  "@yearly",
+			after: utc(2026, 6, 15, 8, 0),
+			want:  []time.Time{utc(2027, 1, 1, 0, 0), utc(2028, 1, 1, 0, 0)},
+		},
+		{
+			name:  "impossible date",
+			spec:  "0 0 30 2 *",
+			after: utc(2026, 1, 1, 0, 0),
+			want:  []time.Time{{}},
+		},
+		{
+			name:  "every",
+			spec:  "@every 90m",
+			after: utc(2026, 1, 1, 10, 7),
+			want:  []time.Time{utc(2026, 1, 1, 11, 37), utc(2026, 1, 1, 13, 7)},
+		},
+		{
+			name:  "zone",
+			spec:  "TZ=Europe/Berlin 0 9 * * *",
+			after: utc(2026, 1, 1, 12, 0),
+			want:  []time.Time{utc(2026, 1, 2, 8, 0), utc(2026, 1, 3, 8, 0)},
+		},
+		{
+			// Clocks go from 02:00 CET to 03:00 CEST on March 29th, 2026
+			name:  "time skipped when clocks go forward",
+			spec:  "TZ=Europe/Berlin 30 2 * * *",
+			after: utc(2026, 3, 28, 12, 0),
+			want:  []time.Time{utc(2026, 3, 30, 0, 30), utc(2026, 3, 31, 0, 30)},
+		},
+		{
+			name:  "hourly when clocks go forward",
+			spec:  "TZ=Europe/Berlin 0 * * * *",
+			after: utc(2026, 3, 29, 0, 30),
+			want:  []time.Time{utc(2026, 3, 29, 1, 0), utc(2026, 3, 29, 2, 0)},
+		},
+		{
+			// Clocks go from 03:00 CEST back to 02:00 CET on October 25th, 2026
+			name:  "time repeated when clocks go back",
+			spec:  "TZ=Europe/Berlin 30 2 * * *",
+			after: utc(2026, 10, 24, 12, 0),
+			want:  []time.Time{utc(2026, 10, 25, 0, 30), utc(2026, 10, 26, 1, 30)},
+		},
+		{
+			name:  "started within the repeated hour",
+			spec:  "TZ=Europe/Berlin 30 2 * * *",
+			after: utc(2026, 10, 25, 0, 10),
+			want:  []time.Time{utc(2026, 10, 25, 0, 30), utc(2026, 10, 26, 1, 30)},
+		},
+		{
+			name:  "started after the first occurrence",
+			spec:  "TZ=Europe/Berlin 30 2 * * *",
+			after: utc(2026, 10, 25, 1, 10),
+			want:  []time.Time{utc(2026, 10, 26, 1, 30)},
+		},
+		{
+			name:  "hourly when clocks go back",
+			spec:  "TZ=Europe/Berlin 0 * * * *",
+			after: utc(2026, 10, 24, 23, 30),
+			want:  []time.Time{utc(2026, 10, 25, 0, 0), utc(2026, 10, 25, 2, 0)},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := Parse(tt.spec)
+			if err != nil {
+				t.Fatalf("Parse(%q) error = %v", tt.spec, err)
+			}
+			after := tt.after
+			for i, want := range tt.want {
+				got := s.Next(after)
+				if !got.Equal(want) {
+					t.Fatalf("run %d: Next(%v) = %v, want %v", i+1, after.UTC(), got.UTC(), want)
+				}
+				after = got
+			}
+		})
+	}
+}
@@ -0,0 +1,403 @@
+// Package scheduler runs recurring jobs and persisted delayed tasks.
+package scheduler
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/0xVanfer/tg-listener/store"
+)
+
+// keyPrefix is the store key prefix for delayed tasks.
+const keyPrefix = "scheduled:"
+
+var (
+	// ErrNotFound is returned when a delayed task does not exist, e.g. after it ran.
+	ErrNotFound = errors.New("scheduled task not found")
+
+	// ErrNoHandler is returned when a delayed task has a kind without a handler.
+	ErrNoHandler = errors.New("no handler for scheduled task kind")
+)
+
+// Job is a recurring job.
+type Job func(ctx context.Context)
+
+// TaskHandler runs delayed tasks of one kind.
+// Tasks whose handler returns an error are dropped, not retried.
+type TaskHandler func(ctx context.Context, task *Task) error
+
+// PanicFunc is called when a job or task handler panics.
+type PanicFunc func(id string, value interface{}, stack []byte)
+
+// Task is a delayed task persisted until it runs.
+// Tasks outlive the process, so they carry data rather than functions:
+// the handler registered for their kind runs them.
+type Task struct {
+	ID        string          `json:"id"`
+	Kind      string          `json:"kind"` // Selects the handler that runs the task
+	At        time.Time       `json:"at"`
+	Payload   json.RawMessage `json:"payload,omitempty"` // Data for the handler
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// Decode unmarshals the task's payload into v.
+func (t *Task) Decode(v interface{}) error {
+	if len(t.Payload) == 0 {
+		return nil
+	}
+	return json.Unmarshal(t.Payload, v)
+}
+
+// Entry describes a registered recurring job.
+type Entry struct {
+	ID   string    // Job ID
+	Next time.Time // Next run; zero if the scheduler is stopped or the schedule ended
+	Prev time.Time // Last run; zero if the job hasn't run yet
+}
+
+// job is a registered recurring job and its timer.
+type job struct {
+	id       string
+	schedule Schedule
+	run      Job
+	timer    *time.Timer
+	next     time.Time
+	prev     time.Time
+}
+
+// Scheduler runs recurring jobs on schedules and delayed tasks at their time.
+// Recurring jobs are registered from code on every start; delayed tasks are
+// persisted in the store and re-armed by Start after a restart, with those
+// that fell due while the process was down running right away.
+type Scheduler struct {
+	store    store.Store            // Backing store of delayed tasks
+	jobs     map[string]*job        // Recurring jobs by ID
+	handlers map[string]TaskHandler // Delayed task handlers by kind
+	timers   map[string]*time.Timer // Timers of delayed tasks by ID
+	ctx      context.Context        // Context of the running scheduler; nil while stopped
+	onPanic  PanicFunc              // Called when a job or handler panics
+	mu       sync.Mutex             // Mutex for thread-safe access
+}
+
+// New creates a scheduler persisting delayed tasks in the given store.
+func New(s store.Store) *Scheduler {
+	return &Scheduler{
+		store:    s,
+		jobs:     make(map[string]*job),
+		handlers: make(map[string]TaskHandler),
+		timers:   make(map[string]*time.Timer),
+	}
+}
+
+// NewID returns a random task ID.
+func NewID() (string, error) {
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// SetStore replaces the store of delayed tasks.
+// Tasks armed from the previous store keep their timers.
+func (s *Scheduler) SetStore(st store.Store) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.store = st
+}
+
+// SetPanicHandler sets the function called when a job or task handler panics.
+// Panics are recovered either way, and recurring jobs keep their schedule.
+func (s *Scheduler) SetPanicHandler(fn PanicFunc) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.onPanic = fn
+}
+
+// Add registers a recurring job, replacing any job with the same ID.
+// The job runs on the schedule once the scheduler is started.
+func (s *Scheduler) Add(id string, schedule Schedule, run Job) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if old, ok := s.jobs[id]; ok && old.timer != nil {
+		old.timer.Stop()
+	}
+	j := &job{id: id, schedule: schedule, run: run}
+	s.jobs[id] = j
+	if s.ctx != nil {
+		s.armJob(j, time.Now())
+	}
+}
+
+// Remove unregisters a recurring job.
+// Returns false if no job has the ID.
+func (s *Scheduler) Remove(id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	j, ok := s.jobs[id]
+	if !ok {
+		return false
+	}
+	if j.timer != nil {
+		j.timer.Stop()
+	}
+	delete(s.jobs, id)
+	return true
+}
+
+// Entries returns the registered recurring jobs, next run first.
+func (s *Scheduler) Entries() []Entry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entries := make([]Entry, 0, len(s.jobs))
+	for _, j := range s.jobs {
+		entries = append(entries, Entry{ID: j.id, Next: j.next, Prev: j.prev})
+	}
+	sort.Slice(entries, func(i, k int) bool {
+		a, b := entries[i].Next, entries[k].Next
+		if a.Equal(b) {
+			return entries[i].ID < entries[k].ID
+		}
+		// Jobs without a next run go last
+		return !a.IsZero() && (b.IsZero() || a.Before(b))
+	})
+	return entries
+}
+
+// armJob sets the timer of a job for its next run after the given time.
+// Must be called with the lock held.
+func (s *Scheduler) armJob(j *job, after time.Time) {
+	j.next = j.schedule.Next(after)
+	if j.next.IsZero() {
+		j.timer = nil
+		return
+	}
+	j.timer = time.AfterFunc(time.Until(j.next), func() {
+		s.runJob(j)
+	})
+}
+
+// runJob runs a job and arms its next run, unless the job was replaced,
+// removed, or the scheduler stopped meanwhile.
+func (s *Scheduler) runJob(j *job) {
+	s.mu.Lock()
+	ctx := s.ctx
+	if ctx == nil || ctx.Err() != nil || s.jobs[j.id] != j {
+		s.mu.Unlock()
+		return
+	}
+	scheduled := j.next
+	j.prev = time.Now()
+	s.mu.Unlock()
+
+	s.protect(j.id, func() { j.run(ctx) })
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.ctx != nil && s.jobs[j.id] == j {
+		// Runs that took longer than an interval skip the missed runs
+		after := scheduled
+		if now := time.Now(); now.After(after) {
+			after = now
+		}
+		s.armJob(j, after)
+	}
+}
+
+// protect runs fn, recovering panics and reporting them to the panic handler.
+func (s *Scheduler) protect(id string, fn func()) {
+	defer func() {
+		if v := recover(); v != nil {
+			s.mu.Lock()
+			onPanic := s.onPanic
+			s.mu.Unlock()
+			if onPanic != nil {
+				onPanic(id, v, debug.Stack())
+			}
+		}
+	}()
+	fn()
+}
+
+// Handle registers the handler of delayed tasks of a kind, replacing any
+// previous one. Register handlers before Start, so tasks resumed from the
+// store find them.
+func (s *Scheduler) Handle(kind string, handler TaskHandler) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.handlers[kind] = handler
+}
+
+// At persists a delayed task of a kind to run at the given time, with the
+// payload marshaled as JSON. Returns the task; its ID cancels it.
+func (s *Scheduler) At(ctx context.Context, at time.Time, kind string, payload interface{}) (*Task, error) {
+	s.mu.Lock()
+	_, ok := s.handlers[kind]
+	s.mu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrNoHandler, kind)
+	}
+
+	id, err := NewID()
+	if err != nil {
+		return nil, err
+	}
+	task := &Task{ID: id, Kind: kind, At: at, CreatedAt: time.Now()}
+	if payload != nil {
+		if task.Payload, err = json.Marshal(payload); err != nil {
+			return nil, err
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := store.PutJSON(ctx, s.store, keyPrefix+id, task); err != nil {
+		return nil, err
+	}
+	if s.ctx != nil {
+		s.armTask(task)
+	}
+	return task, nil
+}
+
+// After is like At, running the task after a delay.
+func (s *Scheduler) After(ctx context.Context, d time.Duration, kind string, payload interface{}) (*Task, error) {
+	return s.At(ctx, time.Now().Add(d), kind, payload)
+}
+
+// Cancel removes a delayed task before it runs.
+// Returns ErrNotFound if the task doesn't exist, e.g. because it already ran.
+func (s *Scheduler) Cancel(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if timer, ok := s.timers[id]; ok {
+		timer.Stop()
+		delete(s.timers, id)
+	}
+	var task Task
+	if err := store.GetJSON(ctx, s.store, keyPrefix+id, &task); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return ErrNotFound
+		}
+		return err
+	}
+	return s.store.Delete(ctx, keyPrefix+id)
+}
+
+// Tasks returns the pending delayed tasks, earliest first.
+func (s *Scheduler) Tasks(ctx context.Context) ([]*Task, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.tasks(ctx)
+}
+
+// tasks loads the pending delayed tasks without locking.
+func (s *Scheduler) tasks(ctx context.Context) ([]*Task, error) {
+	keys, err := s.store.List(ctx, keyPrefix)
+	if err != nil {
+		return nil, err
+	}
+	tasks := make([]*Task, 0, len(keys))
+	for _, key := range keys {
+		var task Task
+		if err := store.GetJSON(ctx, s.store, key, &task); err != nil {
+			if errors.Is(err, store.ErrNotFound) {
+				continue
+			}
+			return nil, err
+		}
+		if task.ID == "" {
+			task.ID = strings.TrimPrefix(key, keyPrefix)
+		}
+		tasks = append(tasks, &task)
+	}
+	sort.Slice(tasks, func(i, j int) bool {
+		return tasks[i].At.Before(tasks[j].At)
+	})
+	return tasks, nil
+}
+
+// armTask sets the timer of a delayed task.
+// Must be called with the lock held.
+func (s *Scheduler) armTask(task *Task) {
+	if old, ok := s.timers[task.ID]; ok {
+		old.Stop()
+	}
+	s.timers[task.ID] = time.AfterFunc(time.Until(task.At), func() {
+		s.runTask(task)
+	})
+}
+
+// runTask removes a delayed task from the store and runs its handler.
+// The task is removed first, so it runs at most once.
+func (s *Scheduler) runTask(task *Task) {
+	s.mu.Lock()
+	ctx := s.ctx
+	delete(s.timers, task.ID)
+	handler := s.handlers[task.Kind]
+	st := s.store
+	s.mu.Unlock()
+	if ctx == nil || ctx.Err() != nil {
+		return
+	}
+
+	if err := st.Delete(ctx, keyPrefix+task.ID); err != nil {
+		return
+	}
+	if handler == nil {
+		return
+	}
+	s.protect(task.Kind+":"+task.ID, func() { _ = handler(ctx, task) })
+}
+
+// Start arms the recurring jobs and the delayed tasks in the store.
+// Jobs and tasks run with ctx until it is canceled or Stop is called.
+func (s *Scheduler) Start(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.ctx != nil {
+		return nil
+	}
+	s.ctx = ctx
+
+	now := time.Now()
+	for _, j := range s.jobs {
+		s.armJob(j, now)
+	}
+	tasks, err := s.tasks(ctx)
+	if err != nil {
+		return err
+	}
+	for _, task := range tasks {
+		s.armTask(task)
+	}
+	return nil
+}
+
+// Stop stops all timers. Delayed tasks stay in the store and are re-armed by
+// the next Start.
+func (s *Scheduler) Stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ctx = nil
+	for _, j := range s.jobs {
+		if j.timer != nil {
+			j.timer.Stop()
+			j.timer = nil
+		}
+		j.next = time.Time{}
+	}
+	for id, timer := range s.timers {
+		timer.Stop()
+		delete(s.timers, id)
+	}
+}
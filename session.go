@@ -0,0 +1,100 @@
+package tgwrapper
+
+import (
+	"context"
+
+	"github.com/0xVanfer/tg-listener/config"
+	"github.com/0xVanfer/tg-listener/conv"
+	"github.com/0xVanfer/tg-listener/core"
+	"github.com/0xVanfer/tg-listener/handler"
+)
+
+// cleanupConversation deletes or strips the keyboards of the intermediate
+// messages of an ended conversation, as set by its flow's cleanup.
+// The message whose button ended the conversation is kept, since a flow
+// started from it may reuse it.
+func (w *Wrapper) cleanupConversation(ctx context.Context, c *conv.Conversation) {
+	mode := w.flowCleanup(c)
+	if mode == config.CleanupNone {
+		return
+	}
+
+	pressed := handler.CallbackMessageID(ctx)
+	var ids []int
+	for _, id := range c.IntermediateMessages() {
+		if id != pressed {
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) == 0 {
+		return
+	}
+
+	switch mode {
+	case config.CleanupDelete:
+		_ = w.bot.DeleteMessages(ctx, c.ChatID, ids)
+	case config.CleanupStripKeyboards, config.CleanupMarkExpired:
+		for _, id := range ids {
+			_, _ = w.bot.EditKeyboard(ctx, c.ChatID, id, nil)
+		}
+	}
+}
+
+// sweepConversation deletes, strips, or marks expired the last keyboard
+// message of a conversation that expired or was cancelled, as set by its
+// flow's sweep, so its buttons don't linger in the chat after it ended.
+// The message is kept if its button ended the conversation, e.g. to start a new flow in it.
+func (w *Wrapper) sweepConversation(ctx context.Context, c *conv.Conversation) {
+	if state := c.GetState(); state != conv.StateExpired && state != conv.StateCancelled {
+		return
+	}
+	mode := w.flowSweep(c)
+	msgID := c.KeyboardMsgID
+	if mode == config.CleanupNone || msgID <= 0 || msgID == handler.CallbackMessageID(ctx) {
+		return
+	}
+
+	switch mode {
+	case config.CleanupDelete:
+		_ = w.bot.DeleteMessage(ctx, c.ChatID, msgID)
+	case config.CleanupStripKeyboards:
+		_, _ = w.bot.EditKeyboard(ctx, c.ChatID, msgID, nil)
+	case config.CleanupMarkExpired:
+		text := c.GetKeyboardText()
+		if text == "" {
+			_, _ = w.bot.EditKeyboard(ctx, c.ChatID, msgID, nil)
+			return
+		}
+		// The kept text is markup if the prompt was sent in a parse mode
+		mode := w.stepParseMode(c)
+		footer := core.Escape(mode, w.Config().Bot.GetExpiredFooter())
+		_, _ = w.bot.EditMessage(core.WithParseMode(ctx, mode), c.ChatID, msgID, text+"\n\n"+footer)
+	}
+}
+
+// stepParseMode returns the parse mode of the prompt of a conversation's current step.
+func (w *Wrapper) stepParseMode(c *conv.Conversation) string {
+	if step := w.flowEngine.GetStep(c.FlowID, c.StepID); step != nil {
+		return step.ParseMode
+	}
+	return ""
+}
+
+// flowSweep returns the sweep mode of a conversation's flow.
+func (w *Wrapper) flowSweep(c *conv.Conversation) config.CleanupMode {
+	defaultMode := w.Config().Bot.FlowSweep
+	if flow := w.Config().GetFlow(c.RootFlowID()); flow != nil {
+		return flow.GetSweep(defaultMode)
+	}
+	return defaultMode
+}
+
+// flowCleanup returns the cleanup mode of a conversation's flow. Sub-flows
+// are cleaned up as part of the flow that called them.
+func (w *Wrapper) flowCleanup(c *conv.Conversation) config.CleanupMode {
+	defaultMode := w.Config().Bot.FlowCleanup
+	if flow := w.Config().GetFlow(c.RootFlowID()); flow != nil {
+		return flow.GetCleanup(defaultMode)
+	}
+	return defaultMode
+}
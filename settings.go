@@ -0,0 +1,75 @@
+package tgwrapper
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/0xVanfer/tg-listener/conv"
+	"github.com/0xVanfer/tg-listener/store"
+)
+
+// Settings is a persistent map of named values backed by the wrapper's store.
+type Settings = store.Settings
+
+// chatSettingsKeyPrefix is the store key prefix for chat settings documents.
+const chatSettingsKeyPrefix = "chat_settings:"
+
+// ChatSettings returns the persistent settings for a chat.
+// Values are available to conditions as chat.<name> and to templates as {{.chat.<name>}},
+// and steps can write them with store_as: chat.<name>.
+//
+// Example:
+//
+//	_ = wrapper.ChatSettings(chatID).Set(ctx, "notifications", true)
+func (w *Wrapper) ChatSettings(chatID int64) *Settings {
+	if s, ok := w.chatSettings.Load(chatID); ok {
+		return s.(*Settings)
+	}
+	s, _ := w.chatSettings.LoadOrStore(chatID, store.NewSettings(w.Store(), chatSettingsKeyPrefix+strconv.FormatInt(chatID, 10)))
+	return s.(*Settings)
+}
+
+// chatSettingsStore adapts the wrapper's chat settings to conv.ChatSettingsStore.
+type chatSettingsStore struct {
+	w *Wrapper
+}
+
+// ChatSettings returns all settings for a chat, or nil if they cannot be loaded.
+func (s chatSettingsStore) ChatSettings(ctx context.Context, chatID int64) map[string]interface{} {
+	values, err := s.w.ChatSettings(chatID).All(ctx)
+	if err != nil {
+		return nil
+	}
+	return values
+}
+
+// SetChatSetting stores a single setting for a chat.
+func (s chatSettingsStore) SetChatSetting(ctx context.Context, chatID int64, name string, value interface{}) error {
+	return s.w.ChatSettings(chatID).Set(ctx, name, value)
+}
+
+// contextConversation returns the active conversation for a user in a chat,
+// or an empty one carrying only the IDs when none is active.
+// Used to evaluate conditions and render templates outside of flows.
+func (w *Wrapper) contextConversation(userID, chatID int64) *conv.Conversation {
+	if userID != 0 {
+		if c := w.convManager.Get(userID, chatID); c != nil {
+			return c
+		}
+	}
+	return conv.NewConversation(userID, chatID, 0, "", "", 0)
+}
+
+// menuEvaluator returns the condition evaluator used when rendering menus.
+// Conditions can reference chat settings, environment variables, and the
+// user's active conversation data when a user is known.
+func (w *Wrapper) menuEvaluator(ctx context.Context, chatID, userID int64) func(string) bool {
+	return func(condition string) bool {
+		return w.flowEngine.EvaluateCondition(ctx, w.contextConversation(userID, chatID), condition)
+	}
+}
+
+// renderMenuText renders template expressions in menu texts.
+func (w *Wrapper) renderMenuText(ctx context.Context, chatID int64, text string) string {
+	return w.flowEngine.RenderText(ctx, w.contextConversation(0, chatID), text)
+}
@@ -0,0 +1,70 @@
+package tgwrapper
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/0xVanfer/tg-listener/core"
+	"github.com/0xVanfer/tg-listener/eventlog"
+	"github.com/0xVanfer/tg-listener/handler"
+)
+
+// RegisterShadowCommand registers a shadow handler for a command, e.g. a
+// rewrite of its handler to validate against production traffic. The shadow
+// receives the same messages as the command's handler, but its requests are
+// captured instead of sent. Updates where it behaves differently are recorded
+// in the event log and logged as warnings, see SetShadowReporter.
+//
+// Shadow handlers must not change state the command's handler relies on,
+// such as conversations or stored records.
+func (w *Wrapper) RegisterShadowCommand(command string, h handler.CommandHandler) {
+	w.router.RegisterShadowCommand(command, h)
+}
+
+// RegisterShadowCallback registers a shadow handler for the callback handler
+// registered with the same prefix, see RegisterShadowCommand.
+func (w *Wrapper) RegisterShadowCallback(callback string, h handler.CallbackHandler) {
+	w.router.RegisterShadowCallback(callback, h)
+}
+
+// SetShadowReporter replaces the default handling of shadow reports, e.g. to
+// collect matching updates as well.
+func (w *Wrapper) SetShadowReporter(fn handler.ShadowReporter) {
+	w.router.SetShadowReporter(fn)
+}
+
+// shadowReported records a shadow handler that behaved unlike its primary
+// handler and logs the differences.
+func (w *Wrapper) shadowReported(ctx context.Context, report handler.ShadowReport) {
+	if report.Matches() {
+		return
+	}
+	e := eventlog.Event{
+		Type:     eventlog.TypeShadowMismatch,
+		UpdateID: report.UpdateID,
+		UserID:   report.UserID,
+		Detail:   report.Handler,
+	}
+	if report.ShadowErr != nil {
+		e.Error = report.ShadowErr.Error()
+	}
+	w.recordEvent(ctx, e)
+
+	msg := core.NewBuilder().
+		Text("Shadow of ").Code(report.Handler).
+		Text(fmt.Sprintf(" differs for update %d:", report.UpdateID))
+	if len(report.Diff) > 0 {
+		msg.Ln().Pre(strings.Join(report.Diff, "\n"), "")
+	}
+	if report.ShadowErr != nil {
+		msg.Ln().Text("Shadow error: ").Code(report.ShadowErr.Error())
+	}
+	_ = w.Log(ctx, LogWarn, msg)
+}
+
+// setupShadows records and logs shadow handlers behaving unlike their
+// primary handlers.
+func (w *Wrapper) setupShadows() {
+	w.router.SetShadowReporter(w.shadowReported)
+}
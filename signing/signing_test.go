@@ -0,0 +1,147 @@
+package signing
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestVerify(t *testing.T) {
+	signer := New([]byte("secret"))
+	now := time.Unix(1_700_000_000, 0)
+	sign := func(data string, userID int64, ttl time.Duration) string {
+		signed, err := signer.Sign(data, userID, ttl, now)
+		if err != nil {
+			t.Fatalf("Sign(%q) error = %v", data, err)
+		}
+		return signed
+	}
+
+	tests := []struct {
+		name    string
+		signed  string
+		signer  *Signer
+		userID  int64
+		now     time.Time
+		want    string
+		wantErr error
+	}{
+		{
+			name:   "without expiry",
+			signed: sign("buy:42", 7, 0),
+			userID: 7,
+			now:    now.Add(365 * 24 * time.Hour),
+			want:   "buy:42",
+		},
+		{
+			name:   "within ttl",
+			signed: sign("buy:42", 7, time.Minute),
+			userID: 7,
+			now:    now.Add(30 * time.Second),
+			want:   "buy:42",
+		},
+		{
+			name:   "at expiry",
+			signed: sign("buy:42", 7, time.Minute),
+			userID: 7,
+			now:    now.Add(time.Minute),
+			want:   "buy:42",
+		},
+		{
+			name:    "expired",
+			signed:  sign("buy:42", 7, time.Minute),
+			userID:  7,
+			now:     now.Add(time.Minute + time.Second),
+			wantErr: ErrExpired,
+		},
+		{
+			name:    "other user",
+			signed:  sign("buy:42", 7, 0),
+			userID:  8,
+			now:     now,
+			wantErr: ErrInvalid,
+		},
+		{
+			name:    "tampered data",
+			signed:  strings.Replace(sign("buy:42", 7, 0), "42", "43", 1),
+			userID:  7,
+			now:     now,
+			wantErr: ErrInvalid,
+		},
+		{
+			name:    "extended expiry",
+			signed:  strings.Replace(sign("buy:42", 7, time.Minute), separator, separator+"1", 1),
+			userID:  7,
+			now:     now,
+			wantErr: ErrInvalid,
+		},
+		{
+			name:    "other secret",
+			signed:  sign("buy:42", 7, 0),
+			signer:  New([]byte("other")),
+			userID:  7,
+			now:     now,
+			wantErr: ErrInvalid,
+		},
+		{
+			name:   "data with separator",
+			signed: sign("a"+separator+"b", 7, 0),
+			userID: 7,
+			now:    now,
+			want:   "a" + separator + "b",
+		},
+		{
+			name:    "missing mac",
+			signed:  "buy:42" + separator + "0",
+			userID:  7,
+			now:     now,
+			wantErr: ErrInvalid,
+		},
+		{
+			name:    "not signed",
+			signed:  "buy:42",
+			userID:  7,
+			now:     now,
+			wantErr: ErrNotSigned,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := signer
+			if tt.signer != nil {
+				s = tt.signer
+			}
+			got, err := s.Verify(tt.signed, tt.userID, tt.now)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Verify(%q) error = %v, want %v", tt.signed, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Verify(%q) = %q, want %q", tt.signed, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSignTooLong(t *testing.T) {
+	signer := New([]byte("secret"))
+	tests := []struct {
+		name    string
+		data    string
+		wantErr error
+	}{
+		{"fits", strings.Repeat("x", 40), nil},
+		{"too long", strings.Repeat("x", MaxDataLength), ErrTooLong},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			signed, err := signer.Sign(tt.data, 7, time.Hour, time.Now())
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Sign error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && (len(signed) > MaxDataLength || !IsSigned(signed)) {
+				t.Errorf("Sign = %q, want signed data of at most %d bytes", signed, MaxDataLength)
+			}
+		})
+	}
+}
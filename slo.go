@@ -0,0 +1,59 @@
+package tgwrapper
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/0xVanfer/tg-listener/core"
+	"github.com/0xVanfer/tg-listener/latency"
+	"github.com/0xVanfer/tg-listener/quota"
+)
+
+// SlowHandlerFunc is called when a handler call exceeds its latency budget.
+type SlowHandlerFunc func(ctx context.Context, name string, took, budget time.Duration)
+
+// Latency returns the per-handler latency histograms.
+func (w *Wrapper) Latency() *latency.Tracker {
+	return w.latency
+}
+
+// OnSlowHandler sets a callback function that is called when a handler exceeds
+// its budget from bot.slo, replacing the default: the breach is logged and sent
+// through the warning escalation chain with the key "slo:<handler>".
+func (w *Wrapper) OnSlowHandler(fn SlowHandlerFunc) {
+	w.onSlowHandler = fn
+}
+
+// observeLatency records a handler call and fires the slow handler hook on a budget breach.
+func (w *Wrapper) observeLatency(ctx context.Context, name string, d time.Duration) {
+	budget := w.Config().Bot.SLO.Budget(name)
+	if !w.latency.Observe(name, d, budget) {
+		return
+	}
+
+	fn := w.onSlowHandler
+	if fn == nil {
+		fn = w.reportSlowHandler
+	}
+	fn(ctx, name, d, budget)
+}
+
+// reportSlowHandler is the default slow handler hook. The warning is sent in the
+// background so the slow handler's update is not delayed any further.
+func (w *Wrapper) reportSlowHandler(ctx context.Context, name string, took, budget time.Duration) {
+	log.Printf("[SLO] %s took %s (budget %s)", name, took.Round(time.Millisecond), budget)
+	if !w.Config().Bot.HasWarningChat() && !w.Config().Bot.HasLogChat() {
+		return
+	}
+
+	h, _ := w.latency.Histogram(name)
+	msg := core.NewBuilder().
+		Text("Slow handler ").Code(name).
+		Text(fmt.Sprintf(": took %s, budget %s", took.Round(time.Millisecond), quota.FormatDuration(budget))).Ln().
+		Text(fmt.Sprintf("%d of %d calls over budget, p95 ≤ %s", h.Breaches, h.Count, h.Quantile(0.95).Round(time.Millisecond)))
+	go func() {
+		_ = w.Warn(context.WithoutCancel(ctx), "slo:"+name, msg)
+	}()
+}
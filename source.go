@@ -0,0 +1,30 @@
+package tgwrapper
+
+import (
+	"github.com/0xVanfer/tg-listener/config"
+	"github.com/0xVanfer/tg-listener/source"
+)
+
+// SetUpdateSource sets where updates come from, e.g. a source.Bridge
+// consuming updates from a gateway service through a message queue, or a
+// source.Channel in tests. It takes precedence over bot.updates and must be
+// called before Start.
+func (w *Wrapper) SetUpdateSource(src source.Source) {
+	w.source = src
+}
+
+// updateSource returns the source set with SetUpdateSource, or the one
+// configured in bot.updates.
+func (w *Wrapper) updateSource() source.Source {
+	if w.source != nil {
+		return w.source
+	}
+	cfg := w.Config().Bot.Updates
+	switch cfg.GetSource() {
+	case config.UpdateSourceWebhook:
+		return source.Webhook{URL: cfg.WebhookURL, Listen: cfg.Listen, Path: cfg.Path, SecretToken: cfg.SecretToken}
+	case config.UpdateSourceReplay:
+		return source.Replay{Path: cfg.ReplayFile, Interval: cfg.ReplayInterval}
+	}
+	return source.LongPolling{}
+}
@@ -0,0 +1,66 @@
+package source
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/mymmrac/telego"
+)
+
+// maxReplayLine is the longest line of a replay file, in bytes.
+const maxReplayLine = 1 << 20
+
+// Replay delivers the updates of a file with one JSON update per line, e.g.
+// recorded in production, to reproduce a bug or load test handlers. The
+// channel is closed after the last update. allowed is ignored.
+type Replay struct {
+	Path     string        // File to replay
+	Interval time.Duration // Pause between updates; 0 delivers them as fast as they are taken
+}
+
+// Updates opens the file and delivers its updates.
+func (s Replay) Updates(ctx context.Context, _ *telego.Bot, _ []string) (<-chan telego.Update, error) {
+	f, err := os.Open(s.Path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open replay file: %w", err)
+	}
+
+	out := make(chan telego.Update)
+	go func() {
+		defer close(out)
+		defer f.Close()
+		scanner := bufio.NewScanner(f)
+		scanner.Buffer(make([]byte, 0, 64*1024), maxReplayLine)
+		for line := 1; scanner.Scan(); line++ {
+			if len(scanner.Bytes()) == 0 {
+				continue
+			}
+			var update telego.Update
+			if err := json.Unmarshal(scanner.Bytes(), &update); err != nil {
+				log.Printf("[Source] Skipped line %d of %s: %v", line, s.Path, err)
+				continue
+			}
+			select {
+			case out <- update:
+			case <-ctx.Done():
+				return
+			}
+			if s.Interval > 0 {
+				select {
+				case <-time.After(s.Interval):
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			log.Printf("[Source] Failed to read %s: %v", s.Path, err)
+		}
+	}()
+	return out, nil
+}
@@ -0,0 +1,103 @@
+// Package source provides the sources a wrapper receives updates from: long
+// polling, a webhook, a replay file, a Go channel, or a bridge to a message
+// queue fed by a central gateway.
+package source
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+
+	"github.com/mymmrac/telego"
+)
+
+// Source delivers updates to a wrapper.
+type Source interface {
+	// Updates starts delivering updates on the returned channel until ctx is
+	// done or the source is exhausted, then closes the channel. allowed lists
+	// the update types to request from Telegram, or is nil for its default.
+	Updates(ctx context.Context, bot *telego.Bot, allowed []string) (<-chan telego.Update, error)
+}
+
+// Func adapts a function to a Source.
+type Func func(ctx context.Context, bot *telego.Bot, allowed []string) (<-chan telego.Update, error)
+
+// Updates calls f.
+func (f Func) Updates(ctx context.Context, bot *telego.Bot, allowed []string) (<-chan telego.Update, error) {
+	return f(ctx, bot, allowed)
+}
+
+// LongPolling receives updates by polling Telegram with getUpdates. It is the
+// default source.
+type LongPolling struct {
+	Options []telego.LongPollingOption // Options of telego's long polling, e.g. its retry timeout
+}
+
+// Updates starts long polling.
+func (s LongPolling) Updates(ctx context.Context, bot *telego.Bot, allowed []string) (<-chan telego.Update, error) {
+	var params *telego.GetUpdatesParams
+	if allowed != nil {
+		params = &telego.GetUpdatesParams{AllowedUpdates: allowed}
+	}
+	return bot.UpdatesViaLongPolling(ctx, params, s.Options...)
+}
+
+// Channel delivers the updates sent on a Go channel, e.g. by tests. allowed
+// is ignored.
+type Channel <-chan telego.Update
+
+// Updates forwards the channel's updates until it is closed or ctx is done.
+func (s Channel) Updates(ctx context.Context, _ *telego.Bot, _ []string) (<-chan telego.Update, error) {
+	out := make(chan telego.Update)
+	go func() {
+		defer close(out)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case update, ok := <-s:
+				if !ok {
+					return
+				}
+				select {
+				case out <- update:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return out, nil
+}
+
+// Bridge adapts a message queue subscription, e.g. to a NATS subject or a
+// Kafka topic fed by a gateway receiving the updates, to a Source. It must
+// call handle with the JSON of each update, in order, until ctx is done.
+// A message handle returns an error for must not be acknowledged. allowed is
+// ignored: the gateway decides which updates it requests.
+type Bridge func(ctx context.Context, handle func(data []byte) error) error
+
+// Updates runs the subscription, logging its error if it ends before ctx is done.
+func (b Bridge) Updates(ctx context.Context, _ *telego.Bot, _ []string) (<-chan telego.Update, error) {
+	out := make(chan telego.Update)
+	go func() {
+		defer close(out)
+		err := b(ctx, func(data []byte) error {
+			var update telego.Update
+			if err := json.Unmarshal(data, &update); err != nil {
+				log.Printf("[Source] Dropped undecodable update: %v", err)
+				return nil
+			}
+			select {
+			case out <- update:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		})
+		if err != nil && ctx.Err() == nil {
+			log.Printf("[Source] Bridge subscription ended: %v", err)
+		}
+	}()
+	return out, nil
+}
@@ -0,0 +1,80 @@
+package source
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/mymmrac/telego"
+)
+
+// webhookShutdownTimeout bounds the shutdown of the webhook server.
+const webhookShutdownTimeout = 5 * time.Second
+
+// Webhook receives updates Telegram posts to an HTTP server, registering the
+// webhook with Telegram on start.
+type Webhook struct {
+	URL         string // Public HTTPS URL Telegram posts updates to
+	Listen      string // Address the server listens on, e.g. ":8443"
+	Path        string // Path updates are posted to; defaults to the path of URL
+	SecretToken string // Token Telegram sends in every request, checked if set
+}
+
+// Updates registers the webhook and serves it until ctx is done.
+func (s Webhook) Updates(ctx context.Context, bot *telego.Bot, allowed []string) (<-chan telego.Update, error) {
+	path := s.Path
+	if path == "" {
+		path = webhookPath(s.URL)
+	}
+	server := &http.Server{Addr: s.Listen, ReadHeaderTimeout: 10 * time.Second}
+
+	// Handlers outlive the request, so updates must not carry its context
+	register := func(handler telego.WebhookHandler) error {
+		return telego.WebhookHTTPServer(server, path, s.secretTokens()...)(
+			func(ctx context.Context, data []byte) error {
+				return handler(context.WithoutCancel(ctx), data)
+			})
+	}
+	updates, err := bot.UpdatesViaWebhook(ctx, register, telego.WithWebhookSet(ctx, &telego.SetWebhookParams{
+		URL:            s.URL,
+		AllowedUpdates: allowed,
+		SecretToken:    s.SecretToken,
+	}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to set webhook: %w", err)
+	}
+
+	go func() {
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Printf("[Source] Webhook server stopped: %v", err)
+		}
+	}()
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), webhookShutdownTimeout)
+		defer cancel()
+		_ = server.Shutdown(shutdownCtx)
+	}()
+	return updates, nil
+}
+
+// secretTokens returns the secret token to check, if any.
+func (s Webhook) secretTokens() []string {
+	if s.SecretToken == "" {
+		return nil
+	}
+	return []string{s.SecretToken}
+}
+
+// webhookPath returns the path of a webhook URL, "/" if it has none.
+func webhookPath(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Path == "" {
+		return "/"
+	}
+	return u.Path
+}
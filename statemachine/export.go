@@ -0,0 +1,184 @@
+package statemachine
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/0xVanfer/tg-listener/config"
+)
+
+// Export converts the steps of a flow and their transitions to a state
+// machine. Flow settings beyond the steps, e.g. roles, credits, or checks,
+// are not exported. Returns the definition and notes on what it couldn't
+// express, e.g. regex and custom branch conditions, whose branches are left
+// out.
+func Export(flow *config.FlowConfig) (*Definition, []string) {
+	def := &Definition{
+		Comment:        flow.Name,
+		StartAt:        flow.InitialStep,
+		States:         make(map[string]*State),
+		TimeoutSeconds: seconds(flow.TTL),
+	}
+	var notes []string
+	end := ""
+
+	// Export steps in a stable order, so notes and synthetic state names don't vary
+	ids := make([]string, 0, len(flow.Steps))
+	for id := range flow.Steps {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	for _, id := range ids {
+		step := flow.Steps[id]
+		task := &State{Type: TypeTask, Resource: ResourceStep, Parameters: stepParameters(step)}
+		if step.SubFlow != nil {
+			task.Resource = ResourceSubFlowPrefix + step.SubFlow.FlowID
+		}
+		if t := step.Timeout; t != nil {
+			task.TimeoutSeconds = seconds(t.After)
+			if t.NextStep != "" {
+				task.Catch = append(task.Catch, Catcher{ErrorEquals: []string{ErrorTimeout}, Next: t.NextStep})
+			}
+		}
+		if f := step.OnMaxAttempts; f != nil && f.NextStep != "" {
+			task.Catch = append(task.Catch, Catcher{ErrorEquals: []string{ErrorMaxAttempts}, Next: f.NextStep})
+		}
+		if f := step.OnValidationFail; f != nil && f.NextStep != "" {
+			task.Catch = append(task.Catch, Catcher{ErrorEquals: []string{ErrorValidationFailed}, Next: f.NextStep})
+		}
+		def.States[id] = task
+
+		if len(step.Branches) == 0 {
+			task.Next, task.End = step.NextStep, step.NextStep == ""
+			continue
+		}
+
+		// Branches become a Choice state, with a Task state for each branch handler
+		choice := &State{Type: TypeChoice, Default: step.NextStep}
+		for i, b := range step.Branches {
+			next := b.NextStep
+			if b.Handler != "" {
+				name := uniqueName(def, flow, fmt.Sprintf("%s.%d", id, i+1))
+				def.States[name] = &State{Type: TypeTask, Resource: ResourceHandlerPrefix + b.Handler, Next: next, End: next == ""}
+				next = name
+			}
+			if next == "" {
+				if end == "" {
+					end = uniqueName(def, flow, "End")
+					def.States[end] = &State{Type: TypeSucceed}
+				}
+				next = end
+			}
+			rule, ok := choiceOf(b.Condition, next)
+			if !ok {
+				notes = append(notes, fmt.Sprintf("step %s: branch condition %q has no choice rule equivalent and was left out", id, b.Condition))
+				continue
+			}
+			choice.Choices = append(choice.Choices, rule)
+		}
+		if choice.Default == "" {
+			if end == "" {
+				end = uniqueName(def, flow, "End")
+				def.States[end] = &State{Type: TypeSucceed}
+			}
+			choice.Default = end
+		}
+		name := uniqueName(def, flow, id+".choice")
+		def.States[name] = choice
+		task.Next = name
+	}
+	return def, notes
+}
+
+// stepParameters returns the settings of a step other than its transitions,
+// as they are written in configuration, leaving out unset ones.
+func stepParameters(step *config.StepConfig) map[string]interface{} {
+	s := *step
+	s.ID, s.NextStep, s.Branches = "", "", nil
+	// Keep what the step runs on timeouts and failures; where it goes is in Catch
+	if t := s.Timeout; t != nil {
+		s.Timeout = &config.StepTimeoutConfig{Handler: t.Handler, Text: t.Text}
+	}
+	if f := s.OnValidationFail; f != nil {
+		s.OnValidationFail = &config.ValidationFailConfig{Handler: f.Handler}
+	}
+	if f := s.OnMaxAttempts; f != nil {
+		s.OnMaxAttempts = &config.ValidationFailConfig{Handler: f.Handler}
+	}
+
+	data, err := json.Marshal(s)
+	if err != nil {
+		return nil
+	}
+	var params map[string]interface{}
+	if err := json.Unmarshal(data, &params); err != nil {
+		return nil
+	}
+	prune(params)
+	return params
+}
+
+// prune removes unset values from a decoded JSON object, recursively.
+func prune(m map[string]interface{}) {
+	for k, v := range m {
+		switch v := v.(type) {
+		case nil:
+			delete(m, k)
+		case string:
+			if v == "" {
+				delete(m, k)
+			}
+		case bool:
+			if !v {
+				delete(m, k)
+			}
+		case float64:
+			if v == 0 {
+				delete(m, k)
+			}
+		case []interface{}:
+			if pruneAll(v); len(v) == 0 {
+				delete(m, k)
+			}
+		case map[string]interface{}:
+			if prune(v); len(v) == 0 {
+				delete(m, k)
+			}
+		}
+	}
+}
+
+// pruneAll removes unset values from the objects of a decoded JSON array,
+// recursively.
+func pruneAll(values []interface{}) {
+	for _, v := range values {
+		switch v := v.(type) {
+		case map[string]interface{}:
+			prune(v)
+		case []interface{}:
+			pruneAll(v)
+		}
+	}
+}
+
+// uniqueName returns name, or name with underscores appended if a step or
+// state already has it.
+func uniqueName(def *Definition, flow *config.FlowConfig, name string) string {
+	for {
+		_, state := def.States[name]
+		_, step := flow.Steps[name]
+		if !state && !step {
+			return name
+		}
+		name += "_"
+	}
+}
+
+// seconds returns a duration in whole seconds, rounded up.
+func seconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
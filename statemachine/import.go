@@ -0,0 +1,159 @@
+package statemachine
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/0xVanfer/tg-listener/config"
+)
+
+// Import converts a state machine to a flow with the given ID. Task and Pass
+// states become steps, configured by their Parameters; Pass states default
+// to steps without input. Choice states following a step become its
+// branches, Catch rules its timeout and validation failure transitions, and
+// Succeed and Fail states end the flow. Returns an error wrapping
+// ErrUnsupported for Parallel, Map, and Wait states, and for choice rules
+// other than string comparisons.
+func Import(def *Definition, flowID string) (*config.FlowConfig, error) {
+	flow := &config.FlowConfig{
+		ID:          flowID,
+		Name:        def.Comment,
+		InitialStep: def.StartAt,
+		Steps:       make(map[string]*config.StepConfig),
+		TTL:         time.Duration(def.TimeoutSeconds) * time.Second,
+	}
+	if s := def.States[def.StartAt]; s == nil || !isStep(s) {
+		return nil, fmt.Errorf("%w: start state %q is not a step", ErrUnsupported, def.StartAt)
+	}
+
+	for name, s := range def.States {
+		switch s.Type {
+		case TypeTask, TypePass:
+			if !isStep(s) {
+				continue
+			}
+			step, err := importStep(def, name, s)
+			if err != nil {
+				return nil, err
+			}
+			flow.Steps[name] = step
+		case TypeChoice, TypeSucceed, TypeFail:
+		default:
+			return nil, fmt.Errorf("%w: state %q of type %s", ErrUnsupported, name, s.Type)
+		}
+	}
+	if err := flow.Validate(); err != nil {
+		return nil, err
+	}
+	return flow, nil
+}
+
+// isStep returns true if a state becomes a step, rather than running a branch handler.
+func isStep(s *State) bool {
+	return (s.Type == TypeTask && !strings.HasPrefix(s.Resource, ResourceHandlerPrefix)) || s.Type == TypePass
+}
+
+// importStep converts a Task or Pass state to a step.
+func importStep(def *Definition, name string, s *State) (*config.StepConfig, error) {
+	step := &config.StepConfig{}
+	if len(s.Parameters) > 0 {
+		data, err := json.Marshal(s.Parameters)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(data, step); err != nil {
+			return nil, fmt.Errorf("state %q: %w", name, err)
+		}
+	}
+	step.ID = name
+	if step.InputType == "" && s.Type == TypePass {
+		step.InputType = config.InputTypeNone
+	}
+	if flowID, ok := strings.CutPrefix(s.Resource, ResourceSubFlowPrefix); ok && step.SubFlow == nil {
+		step.SubFlow = &config.SubFlowConfig{FlowID: flowID}
+	}
+
+	if s.TimeoutSeconds > 0 {
+		if step.Timeout == nil {
+			step.Timeout = &config.StepTimeoutConfig{}
+		}
+		step.Timeout.After = time.Duration(s.TimeoutSeconds) * time.Second
+	}
+	for _, c := range s.Catch {
+		next, err := stepOf(def, c.Next)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range c.ErrorEquals {
+			switch e {
+			case ErrorTimeout:
+				if step.Timeout != nil {
+					step.Timeout.NextStep = next
+				}
+			case ErrorValidationFailed:
+				if step.OnValidationFail == nil {
+					step.OnValidationFail = &config.ValidationFailConfig{}
+				}
+				step.OnValidationFail.NextStep = next
+			case ErrorMaxAttempts:
+				if step.OnMaxAttempts == nil {
+					step.OnMaxAttempts = &config.ValidationFailConfig{}
+				}
+				step.OnMaxAttempts.NextStep = next
+			}
+		}
+	}
+
+	if s.End {
+		return step, nil
+	}
+	choice := def.States[s.Next]
+	if choice == nil || choice.Type != TypeChoice {
+		next, err := stepOf(def, s.Next)
+		step.NextStep = next
+		return step, err
+	}
+
+	// A Choice state becomes the branches of the step
+	next, err := stepOf(def, choice.Default)
+	if err != nil {
+		return nil, err
+	}
+	step.NextStep = next
+	for _, rule := range choice.Choices {
+		condition, ok := conditionOf(rule)
+		if !ok {
+			return nil, fmt.Errorf("%w: choice rule on %q in state %q", ErrUnsupported, rule.Variable, s.Next)
+		}
+		branch := config.BranchConfig{Condition: condition}
+		target := rule.Next
+		if h := def.States[target]; h != nil && h.Type == TypeTask && !isStep(h) {
+			branch.Handler = strings.TrimPrefix(h.Resource, ResourceHandlerPrefix)
+			target = h.Next
+		}
+		if branch.NextStep, err = stepOf(def, target); err != nil {
+			return nil, err
+		}
+		step.Branches = append(step.Branches, branch)
+	}
+	return step, nil
+}
+
+// stepOf returns the step a transition leads to, "" for ends of the flow.
+func stepOf(def *Definition, name string) (string, error) {
+	if name == "" {
+		return "", nil
+	}
+	s := def.States[name]
+	switch {
+	case s == nil:
+		return "", fmt.Errorf("%w: %s", config.ErrStepNotFound, name)
+	case s.Type == TypeSucceed || s.Type == TypeFail:
+		return "", nil
+	case isStep(s):
+		return name, nil
+	}
+	return "", fmt.Errorf("%w: transition to %s state %q", ErrUnsupported, s.Type, name)
+}
@@ -0,0 +1,150 @@
+// Package statemachine converts flows to and from state machine definitions
+// in the Amazon States Language, the JSON format of AWS Step Functions that
+// many workflow and BPM tools read and write. Steps become Task states whose
+// Parameters carry the step's settings, branches become Choice states, and
+// timeouts and validation failures become Catch rules, so flows can be
+// reviewed in external tools and existing process definitions reused as flows.
+package statemachine
+
+import (
+	"encoding/json"
+	"errors"
+	"regexp"
+	"strings"
+
+	"github.com/0xVanfer/tg-listener/config"
+)
+
+// ErrUnsupported is returned when a definition uses states or choice rules
+// that have no flow equivalent.
+var ErrUnsupported = errors.New("unsupported state machine construct")
+
+// State types.
+const (
+	TypeTask     = "Task"     // Step, or handler run by a branch
+	TypeChoice   = "Choice"   // Branches of the preceding step
+	TypePass     = "Pass"     // Step without input
+	TypeSucceed  = "Succeed"  // End of the flow
+	TypeFail     = "Fail"     // End of the flow
+	TypeParallel = "Parallel" // Not supported
+	TypeMap      = "Map"      // Not supported
+	TypeWait     = "Wait"     // Not supported
+)
+
+// Resources of Task states.
+const (
+	// ResourceStep is the resource of Task states that are steps.
+	ResourceStep = "tg:step"
+
+	// ResourceSubFlowPrefix prefixes the called flow ID in the resource of
+	// steps calling a sub-flow.
+	ResourceSubFlowPrefix = "tg:subflow:"
+
+	// ResourceHandlerPrefix prefixes the handler name in the resource of the
+	// Task states running the handlers of branches.
+	ResourceHandlerPrefix = "tg:handler:"
+)
+
+// Errors caught by Catch rules of steps.
+const (
+	// ErrorTimeout is raised when a step with a timeout isn't answered in time.
+	ErrorTimeout = "States.Timeout"
+
+	// ErrorValidationFailed is raised by input failing a step's validation.
+	ErrorValidationFailed = "tg.ValidationFailed"
+
+	// ErrorMaxAttempts is raised once a step received validation.max_attempts
+	// invalid inputs in a row.
+	ErrorMaxAttempts = "tg.MaxAttempts"
+)
+
+// Definition is a state machine in the Amazon States Language.
+type Definition struct {
+	Comment        string            `json:"Comment,omitempty"`        // Flow name
+	StartAt        string            `json:"StartAt"`                  // Initial step
+	States         map[string]*State `json:"States"`                   // States by name
+	TimeoutSeconds int               `json:"TimeoutSeconds,omitempty"` // Flow TTL
+}
+
+// State is a state of a state machine.
+type State struct {
+	Type           string                 `json:"Type"`
+	Comment        string                 `json:"Comment,omitempty"`
+	Resource       string                 `json:"Resource,omitempty"`       // Task states
+	Parameters     map[string]interface{} `json:"Parameters,omitempty"`     // Step settings of Task and Pass states
+	TimeoutSeconds int                    `json:"TimeoutSeconds,omitempty"` // Step timeout of Task states
+	Catch          []Catcher              `json:"Catch,omitempty"`          // Task states
+	Choices        []Choice               `json:"Choices,omitempty"`        // Choice states
+	Default        string                 `json:"Default,omitempty"`        // Choice states
+	Next           string                 `json:"Next,omitempty"`
+	End            bool                   `json:"End,omitempty"`
+}
+
+// Catcher moves to another state when a Task state raises an error.
+type Catcher struct {
+	ErrorEquals []string `json:"ErrorEquals"`
+	Next        string   `json:"Next"`
+}
+
+// Choice is a rule of a Choice state. Flows support string comparisons of
+// the input ("$.input") or a value ("$.data.key", "$.chat.key", ...), and
+// their negation.
+type Choice struct {
+	Variable     string  `json:"Variable,omitempty"`
+	StringEquals *string `json:"StringEquals,omitempty"`
+	Not          *Choice `json:"Not,omitempty"`
+	Next         string  `json:"Next,omitempty"`
+}
+
+// Parse decodes a definition from JSON.
+func Parse(data []byte) (*Definition, error) {
+	var def Definition
+	if err := json.Unmarshal(data, &def); err != nil {
+		return nil, err
+	}
+	return &def, nil
+}
+
+// conditionPattern matches the branch conditions with a choice rule
+// equivalent: a reference compared to a value.
+var conditionPattern = regexp.MustCompile(`^\s*([\w.]+)\s*(==|!=)\s*(.*?)\s*$`)
+
+// choiceOf returns the choice rule of a branch condition, or false if it has none.
+func choiceOf(condition, next string) (Choice, bool) {
+	m := conditionPattern.FindStringSubmatch(condition)
+	if m == nil || (m[1] == "input" && m[2] == "!=") {
+		return Choice{}, false
+	}
+	value := config.Unquote(m[3])
+	rule := Choice{Variable: variableOf(m[1]), StringEquals: &value}
+	if m[2] == "!=" {
+		return Choice{Not: &rule, Next: next}, true
+	}
+	rule.Next = next
+	return rule, true
+}
+
+// conditionOf returns the branch condition of a choice rule, or false if it has none.
+func conditionOf(rule Choice) (string, bool) {
+	op := "=="
+	if rule.Not != nil {
+		op, rule = "!=", *rule.Not
+	}
+	if rule.StringEquals == nil || rule.Not != nil || !strings.HasPrefix(rule.Variable, "$.") {
+		return "", false
+	}
+	ref := strings.TrimPrefix(rule.Variable, "$.")
+	if ref == "input" && op == "!=" {
+		return "", false
+	}
+	return ref + " " + op + " \"" + *rule.StringEquals + "\"", true
+}
+
+// variableOf returns the choice rule variable of a reference. Bare keys
+// address conversation data.
+func variableOf(ref string) string {
+	if ref != "input" && !strings.Contains(ref, ".") {
+		ref = "data." + ref
+	}
+	return "$." + ref
+}
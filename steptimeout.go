@@ -0,0 +1,132 @@
+package tgwrapper
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/0xVanfer/tg-listener/config"
+	"github.com/0xVanfer/tg-listener/conv"
+	"github.com/0xVanfer/tg-listener/core"
+	"github.com/0xVanfer/tg-listener/eventlog"
+	"github.com/0xVanfer/tg-listener/scheduler"
+)
+
+// stepTimeoutKind is the scheduler task kind firing step timeouts.
+const stepTimeoutKind = "step_timeout"
+
+// stepTimeout is the payload of a step timeout task.
+type stepTimeout struct {
+	UserID   int64     `json:"user_id"`
+	ChatID   int64     `json:"chat_id"`
+	TopicID  int       `json:"topic_id,omitempty"`
+	FlowID   string    `json:"flow_id"`
+	StepID   string    `json:"step_id"`
+	Deadline time.Time `json:"deadline"`
+}
+
+// armStepTimeout starts the timeout of a step whose prompt is about to be
+// shown, cancelling the timeout armed earlier for the conversation, so
+// re-rendering a step or moving to another one leaves a single task.
+func (w *Wrapper) armStepTimeout(ctx context.Context, c *conv.Conversation, step *config.StepConfig) {
+	w.cancelStepTimeout(ctx, c)
+	if step.Timeout == nil || step.Timeout.After <= 0 {
+		c.SetStepDeadline(time.Time{})
+		return
+	}
+	deadline := time.Now().Add(step.Timeout.After)
+	c.SetStepDeadline(deadline)
+	task, err := w.scheduler.At(ctx, deadline, stepTimeoutKind, stepTimeout{
+		UserID:   c.UserID,
+		ChatID:   c.ChatID,
+		TopicID:  c.TopicID,
+		FlowID:   c.FlowID,
+		StepID:   c.StepID,
+		Deadline: deadline,
+	})
+	if err != nil {
+		log.Printf("[Flow] Failed to arm timeout of step %s in flow %s: %v", c.StepID, c.FlowID, err)
+		return
+	}
+	c.SetStepTimeoutID(task.ID)
+	if err := w.convManager.Save(ctx, c); err != nil && w.Config().Bot.Debug {
+		log.Printf("[Flow] Failed to save conversation: %v", err)
+	}
+}
+
+// cancelStepTimeout cancels the timeout task armed for a conversation's
+// current step, if any. The deadline is left to the caller.
+func (w *Wrapper) cancelStepTimeout(ctx context.Context, c *conv.Conversation) {
+	id := c.GetStepTimeoutID()
+	if id == "" {
+		return
+	}
+	c.SetStepTimeoutID("")
+	if err := w.scheduler.Cancel(ctx, id); err != nil && !errors.Is(err, scheduler.ErrNotFound) {
+		log.Printf("[Flow] Failed to cancel timeout of step %s in flow %s: %v", c.StepID, c.FlowID, err)
+	}
+}
+
+// stepTimedOut applies a step's timeout if the user is still on the step it
+// was armed for: the timeout's text is sent, its handler runs, and the
+// conversation moves to its next step. Without either, the conversation expires.
+func (w *Wrapper) stepTimedOut(ctx context.Context, task *scheduler.Task) error {
+	var p stepTimeout
+	if err := task.Decode(&p); err != nil {
+		return err
+	}
+	c := w.convManager.GetIn(p.UserID, p.ChatID, p.TopicID)
+	if c == nil || c.FlowID != p.FlowID || c.StepID != p.StepID || !c.GetStepDeadline().Equal(p.Deadline) {
+		return nil
+	}
+	step := w.flowEngine.GetStep(c.FlowID, c.StepID)
+	if step == nil || step.Timeout == nil {
+		return nil
+	}
+	timeout := step.Timeout
+	c.SetStepDeadline(time.Time{})
+	c.SetStepTimeoutID("")
+	w.recordFlowEvent(ctx, eventlog.TypeStepTimeout, c, timeout.After.String())
+
+	if text := timeout.GetText(); text != "" {
+		ctx := core.WithParseMode(ctx, step.ParseMode)
+		notice := w.flowEngine.RenderText(ctx, c, text)
+		if _, err := w.bot.SendMessage(ctx, c.ChatID, c.TopicID, notice); err != nil {
+			log.Printf("[Flow] Failed to send timeout notice to chat %d: %v", c.ChatID, err)
+		}
+	}
+
+	if timeout.Ends() {
+		c.Expire()
+		w.convManager.EndIn(ctx, c.UserID, c.ChatID, c.TopicID)
+		return nil
+	}
+	if timeout.Handler != "" {
+		if err := w.flowEngine.ExecuteStepHandler(ctx, c, timeout.Handler); err != nil {
+			w.recordEvent(ctx, eventlog.Event{
+				Type:   eventlog.TypeError,
+				UserID: c.UserID,
+				ChatID: c.ChatID,
+				FlowID: c.FlowID,
+				StepID: c.StepID,
+				Detail: timeout.Handler,
+				Error:  err.Error(),
+			})
+			return err
+		}
+	}
+	if timeout.NextStep == "" {
+		return w.convManager.Save(ctx, c)
+	}
+	if msgID := c.ClearInvalidInput(); msgID > 0 {
+		_ = w.bot.DeleteMessage(ctx, c.ChatID, msgID)
+	}
+	w.convManager.ChangeStepIn(ctx, c.UserID, c.ChatID, c.TopicID, timeout.NextStep)
+	return w.showStepPrompt(ctx, c)
+}
+
+// setupStepTimeouts registers the task handler firing step timeouts.
+func (w *Wrapper) setupStepTimeouts() {
+	w.scheduler.Handle(stepTimeoutKind, w.stepTimedOut)
+}
@@ -0,0 +1,87 @@
+// Package store provides the bbolt-backed store implementation.
+package store
+
+import (
+	"bytes"
+	"context"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// boltBucket is the bucket holding all values.
+var boltBucket = []byte("tgwrapper")
+
+// BoltStore is a Store implementation backed by a bbolt database file.
+// Unlike FileStore it keeps all keys in a single file with transactional
+// writes, which scales better to many keys. The file can only be opened by
+// one process at a time.
+type BoltStore struct {
+	db *bolt.DB // Open database
+}
+
+// NewBoltStore opens or creates the bbolt database at path.
+// Returns an error if the file is locked by another process for more than a second.
+func NewBoltStore(path string) (*BoltStore, error) {
+	db, err := bolt.Open(path, 0o644, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, err
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(boltBucket)
+		return err
+	})
+	if err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	return &BoltStore{db: db}, nil
+}
+
+// Close closes the database.
+func (s *BoltStore) Close() error {
+	return s.db.Close()
+}
+
+// Get retrieves the value stored under key.
+func (s *BoltStore) Get(_ context.Context, key string) ([]byte, error) {
+	var data []byte
+	err := s.db.View(func(tx *bolt.Tx) error {
+		v := tx.Bucket(boltBucket).Get([]byte(key))
+		if v == nil {
+			return ErrNotFound
+		}
+		// Values are only valid during the transaction, so copy them out
+		data = append([]byte(nil), v...)
+		return nil
+	})
+	return data, err
+}
+
+// Put stores value under key.
+func (s *BoltStore) Put(_ context.Context, key string, value []byte) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltBucket).Put([]byte(key), value)
+	})
+}
+
+// Delete removes key.
+func (s *BoltStore) Delete(_ context.Context, key string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltBucket).Delete([]byte(key))
+	})
+}
+
+// List returns all keys starting with prefix in sorted order.
+func (s *BoltStore) List(_ context.Context, prefix string) ([]string, error) {
+	var keys []string
+	err := s.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(boltBucket).Cursor()
+		p := []byte(prefix)
+		for k, _ := c.Seek(p); k != nil && bytes.HasPrefix(k, p); k, _ = c.Next() {
+			keys = append(keys, string(k))
+		}
+		return nil
+	})
+	return keys, err
+}
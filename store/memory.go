@@ -0,0 +1,65 @@
+// Package store provides the in-memory store implementation.
+package store
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// MemoryStore is an in-memory Store implementation.
+// Data is lost when the process exits; use it for development and tests.
+type MemoryStore struct {
+	data map[string][]byte // Stored values by key
+	mu   sync.RWMutex      // Mutex for thread-safe operations
+}
+
+// NewMemoryStore creates a new empty in-memory store.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		data: make(map[string][]byte),
+	}
+}
+
+// Get retrieves the value stored under key.
+func (s *MemoryStore) Get(_ context.Context, key string) ([]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	v, ok := s.data[key]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	// Return a copy so callers cannot mutate stored data
+	return append([]byte(nil), v...), nil
+}
+
+// Put stores value under key.
+func (s *MemoryStore) Put(_ context.Context, key string, value []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data[key] = append([]byte(nil), value...)
+	return nil
+}
+
+// Delete removes key.
+func (s *MemoryStore) Delete(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.data, key)
+	return nil
+}
+
+// List returns all keys starting with prefix in sorted order.
+func (s *MemoryStore) List(_ context.Context, prefix string) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var keys []string
+	for k := range s.data {
+		if strings.HasPrefix(k, prefix) {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
@@ -0,0 +1,112 @@
+// Package store provides the Redis-backed store implementation.
+package store
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisStore is a Store implementation backed by Redis, suitable for sharing
+// state between multiple bot instances. All keys are namespaced by a prefix.
+type RedisStore struct {
+	client redis.UniversalClient // Redis client, cluster client, or ring
+	prefix string                // Namespace prepended to every key
+}
+
+// NewRedisStore creates a Redis store using the given client.
+// Keys are stored as prefix+key, e.g. with prefix "mybot:".
+func NewRedisStore(client redis.UniversalClient, prefix string) *RedisStore {
+	return &RedisStore{client: client, prefix: prefix}
+}
+
+// Get retrieves the value stored under key.
+func (s *RedisStore) Get(ctx context.Context, key string) ([]byte, error) {
+	data, err := s.client.Get(ctx, s.prefix+key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrNotFound
+	}
+	return data, err
+}
+
+// Put stores value under key.
+func (s *RedisStore) Put(ctx context.Context, key string, value []byte) error {
+	return s.client.Set(ctx, s.prefix+key, value, 0).Err()
+}
+
+// Delete removes key.
+func (s *RedisStore) Delete(ctx context.Context, key string) error {
+	return s.client.Del(ctx, s.prefix+key).Err()
+}
+
+// List returns all keys starting with prefix in sorted order.
+// Keys are found with SCAN, so listing does not block the server. Cluster
+// clients scan every master and rings every shard, since SCAN only covers
+// the node it runs on.
+func (s *RedisStore) List(ctx context.Context, prefix string) ([]string, error) {
+	match := escapeGlob(s.prefix+prefix) + "*"
+
+	var (
+		keys []string
+		mu   sync.Mutex
+	)
+	scan := func(ctx context.Context, client redis.Cmdable) error {
+		found, err := s.scanKeys(ctx, client, match)
+		if err != nil {
+			return err
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		keys = append(keys, found...)
+		return nil
+	}
+	perNode := func(ctx context.Context, client *redis.Client) error {
+		return scan(ctx, client)
+	}
+
+	var err error
+	switch c := s.client.(type) {
+	case *redis.ClusterClient:
+		err = c.ForEachMaster(ctx, perNode)
+	case *redis.Ring:
+		err = c.ForEachShard(ctx, perNode)
+	default:
+		err = scan(ctx, s.client)
+	}
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// scanKeys returns the keys of one node matching a SCAN MATCH pattern,
+// without the store prefix.
+func (s *RedisStore) scanKeys(ctx context.Context, client redis.Cmdable, match string) ([]string, error) {
+	var keys []string
+	iter := client.Scan(ctx, 0, match, 0).Iterator()
+	for iter.Next(ctx) {
+		keys = append(keys, strings.TrimPrefix(iter.Val(), s.prefix))
+	}
+	if err := iter.Err(); err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// escapeGlob escapes the glob metacharacters of a SCAN MATCH pattern.
+func escapeGlob(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch r {
+		case '*', '?', '[', ']', '\\':
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
@@ -0,0 +1,132 @@
+// Package store provides persistent settings maps.
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// Settings is a persistent map of named values stored as a single JSON document.
+// It is used for chat-level and user-level preferences that should survive restarts.
+// All methods are safe for concurrent use on the same Settings instance.
+type Settings struct {
+	store Store      // Backing store
+	key   string     // Store key holding the JSON document
+	mu    sync.Mutex // Serializes read-modify-write cycles
+}
+
+// NewSettings creates a settings map persisted under key in the given store.
+func NewSettings(s Store, key string) *Settings {
+	return &Settings{
+		store: s,
+		key:   key,
+	}
+}
+
+// load reads the settings document, returning an empty map if none exists.
+func (s *Settings) load(ctx context.Context) (map[string]interface{}, error) {
+	values := make(map[string]interface{})
+	err := GetJSON(ctx, s.store, s.key, &values)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return nil, err
+	}
+	return values, nil
+}
+
+// All returns a copy of all settings values.
+func (s *Settings) All(ctx context.Context) (map[string]interface{}, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.load(ctx)
+}
+
+// Get retrieves a setting value.
+// Returns the value and a boolean indicating if the setting exists.
+func (s *Settings) Get(ctx context.Context, name string) (interface{}, bool) {
+	values, err := s.All(ctx)
+	if err != nil {
+		return nil, false
+	}
+	v, ok := values[name]
+	return v, ok
+}
+
+// GetString retrieves a setting as a string.
+// Non-string values are formatted; returns empty string if not set.
+func (s *Settings) GetString(ctx context.Context, name string) string {
+	v, ok := s.Get(ctx, name)
+	if !ok || v == nil {
+		return ""
+	}
+	if str, ok := v.(string); ok {
+		return str
+	}
+	return fmt.Sprint(v)
+}
+
+// GetBool retrieves a setting as a boolean.
+// Accepts bool values and the strings "true", "on", "yes" and "1".
+func (s *Settings) GetBool(ctx context.Context, name string) bool {
+	v, ok := s.Get(ctx, name)
+	if !ok {
+		return false
+	}
+	return Truthy(v)
+}
+
+// Set stores a setting value.
+func (s *Settings) Set(ctx context.Context, name string, value interface{}) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	values, err := s.load(ctx)
+	if err != nil {
+		return err
+	}
+	values[name] = value
+	return PutJSON(ctx, s.store, s.key, values)
+}
+
+// Toggle flips a boolean setting and returns the new value.
+func (s *Settings) Toggle(ctx context.Context, name string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	values, err := s.load(ctx)
+	if err != nil {
+		return false, err
+	}
+	next := !Truthy(values[name])
+	values[name] = next
+	return next, PutJSON(ctx, s.store, s.key, values)
+}
+
+// Delete removes a setting.
+func (s *Settings) Delete(ctx context.Context, name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	values, err := s.load(ctx)
+	if err != nil {
+		return err
+	}
+	delete(values, name)
+	return PutJSON(ctx, s.store, s.key, values)
+}
+
+// Truthy reports whether a loosely typed value should be treated as true.
+// Accepts bool values, non-zero numbers, and the strings "true", "on", "yes" and "1".
+func Truthy(v interface{}) bool {
+	switch b := v.(type) {
+	case bool:
+		return b
+	case string:
+		return b == "true" || b == "on" || b == "yes" || b == "1"
+	case float64:
+		return b != 0
+	case int:
+		return b != 0
+	case int64:
+		return b != 0
+	}
+	return false
+}
@@ -0,0 +1,50 @@
+// Package store provides pluggable key-value persistence for tgwrapper.
+// Subsystems that need to survive restarts (chat settings, counters, registries)
+// persist their state through the Store interface, so deployments can swap
+// the in-memory default for a file or database backend.
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+)
+
+// ErrNotFound is returned by Store.Get when the key does not exist.
+var ErrNotFound = errors.New("key not found")
+
+// Store is the interface for key-value persistence backends.
+// Implementations must be safe for concurrent use.
+type Store interface {
+	// Get retrieves the value stored under key.
+	// Returns ErrNotFound if the key does not exist.
+	Get(ctx context.Context, key string) ([]byte, error)
+
+	// Put stores value under key, replacing any existing value.
+	Put(ctx context.Context, key string, value []byte) error
+
+	// Delete removes key. Deleting a missing key is not an error.
+	Delete(ctx context.Context, key string) error
+
+	// List returns all keys starting with prefix.
+	List(ctx context.Context, prefix string) ([]string, error)
+}
+
+// GetJSON retrieves the value stored under key and decodes it into v.
+// Returns ErrNotFound if the key does not exist.
+func GetJSON(ctx context.Context, s Store, key string, v interface{}) error {
+	data, err := s.Get(ctx, key)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// PutJSON encodes v as JSON and stores it under key.
+func PutJSON(ctx context.Context, s Store, key string, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return s.Put(ctx, key, data)
+}
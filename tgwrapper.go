@@ -16,17 +16,48 @@ package tgwrapper
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/mymmrac/telego"
 	th "github.com/mymmrac/telego/telegohandler"
 
+	"github.com/0xVanfer/tg-listener/alert"
+	"github.com/0xVanfer/tg-listener/anomaly"
+	"github.com/0xVanfer/tg-listener/breaker"
+	"github.com/0xVanfer/tg-listener/bus"
 	"github.com/0xVanfer/tg-listener/config"
 	"github.com/0xVanfer/tg-listener/conv"
 	"github.com/0xVanfer/tg-listener/core"
+	"github.com/0xVanfer/tg-listener/dispatch"
+	"github.com/0xVanfer/tg-listener/eventlog"
 	"github.com/0xVanfer/tg-listener/handler"
+	"github.com/0xVanfer/tg-listener/i18n"
+	"github.com/0xVanfer/tg-listener/latency"
+	"github.com/0xVanfer/tg-listener/ledger"
 	"github.com/0xVanfer/tg-listener/menu"
+	"github.com/0xVanfer/tg-listener/payload"
+	"github.com/0xVanfer/tg-listener/provider"
+	"github.com/0xVanfer/tg-listener/quota"
+	"github.com/0xVanfer/tg-listener/referral"
+	"github.com/0xVanfer/tg-listener/reminder"
+	"github.com/0xVanfer/tg-listener/retention"
+	"github.com/0xVanfer/tg-listener/rsvp"
+	"github.com/0xVanfer/tg-listener/scheduler"
+	"github.com/0xVanfer/tg-listener/signing"
+	"github.com/0xVanfer/tg-listener/source"
+	"github.com/0xVanfer/tg-listener/store"
+	"github.com/0xVanfer/tg-listener/usage"
+	"github.com/0xVanfer/tg-listener/users"
+	"github.com/0xVanfer/tg-listener/vote"
 )
 
 // Re-export commonly used types and functions for convenience.
@@ -53,6 +84,10 @@ type (
 	KeyboardProviderFunc = config.KeyboardProviderFunc
 	// ValidatorFunc is the function signature for custom validators.
 	ValidatorFunc = config.ValidatorFunc
+	// FlowValidatorFunc is the function signature for cross-field flow validators.
+	FlowValidatorFunc = config.FlowValidatorFunc
+	// ComputeFunc is the function signature for functions of computed entries.
+	ComputeFunc = config.ComputeFunc
 )
 
 // Re-export commonly used callback constants for handling user interactions.
@@ -79,6 +114,10 @@ var (
 	URLButton = core.URLButton
 	// ParseCallbackData extracts the data portion from callback data by removing the prefix.
 	ParseCallbackData = core.ParseCallbackData
+	// CallbackParam returns a parameter extracted by a callback pattern or expression.
+	CallbackParam = handler.CallbackParam
+	// CallbackParams returns all parameters extracted by a callback pattern or expression.
+	CallbackParams = handler.CallbackParams
 	// GetTopicID extracts the message thread ID from a message for group topic support.
 	GetTopicID = core.GetTopicID
 	// NewHandlerRegistry creates a new empty handler registry.
@@ -89,15 +128,71 @@ var (
 // It orchestrates all components including bot, router, menu manager, and conversation engine.
 // Use New() to create a new instance and Start() to begin processing updates.
 type Wrapper struct {
-	bot         *core.Bot        // Core bot instance for Telegram API operations
-	config      *config.Config   // Configuration containing menus, flows, and bot settings
-	router      *handler.Router  // Router for dispatching commands, callbacks, and messages
-	menuManager *menu.Manager    // Manager for menu display and navigation
-	convManager *conv.Manager    // Manager for conversation state and lifecycle
-	flowEngine  *conv.FlowEngine // Engine for processing conversation flows and steps
-
-	botHandler *th.BotHandler // Telego handler for update processing
-	stopChan   chan struct{}  // Channel for signaling graceful shutdown
+	bot          *core.Bot                     // Core bot instance for Telegram API operations
+	config       atomic.Pointer[config.Config] // Configuration containing menus, flows, and bot settings; swapped on reload
+	reloadMu     sync.Mutex                    // Mutex serializing configuration reloads
+	registry     *config.HandlerRegistry       // Registry configured commands and callbacks are looked up in
+	configRoutes bool                          // Whether configured commands and callbacks are routed
+	router       *handler.Router               // Router for dispatching commands, callbacks, and messages
+	menuManager  *menu.Manager                 // Manager for menu display and navigation
+	convManager  *conv.Manager                 // Manager for conversation state and lifecycle
+	flowEngine   *conv.FlowEngine              // Engine for processing conversation flows and steps
+
+	store        store.Store  // Pluggable persistence for settings and other state
+	ownsStore    bool         // Whether the store was opened by New and is closed on Stop
+	storeMu      sync.RWMutex // Mutex for store replacement
+	chatSettings sync.Map     // Cached chat settings by chat ID
+
+	users          *users.Registry      // Registry of users seen by the bot
+	referrals      *referral.Tracker    // Referral codes and attributions
+	ledger         *ledger.Ledger       // Points/credits ledger
+	quotas         *quota.Limiter       // Cooldowns and daily usage limits
+	audiences      broadcastAudiences   // Registered broadcast audiences
+	segments       userSegments         // Defined user segments
+	charts         chartRegistry        // Registered refreshable charts
+	threads        *core.ThreadTracker  // Bot messages per logical thread
+	retention      retentionPolicies    // Retention policies set from code
+	sentLog        *retention.Log       // Bot messages in chats with retention limits
+	alerts         alertTimers          // Escalation timers of pending alerts
+	alertStates    *alert.Tracker       // Critical alert states
+	votes          voteTimers           // Closing timers of open votes
+	voteStates     *vote.Tracker        // Vote states and ballots
+	rsvps          rsvpTimers           // Closing timers of open signup sheets
+	rsvpEvents     *rsvp.Tracker        // Event signup sheets and responses
+	reminders      reminderTimers       // Delivery timers of pending reminders
+	reminderStates *reminder.Tracker    // Pending user reminders
+	forks          forkState            // Shared group menus and their per-user forks
+	pins           pinState             // Messages pinned for menus and step prompts
+	logs           logState             // Collected log messages and recent API failures
+	anomalies      *anomaly.Detector    // Telegram API error rate spikes
+	eventCounts    eventCounts          // Router and flow events since the start
+	events         *eventlog.Log        // Persisted router and flow events
+	usage          *usage.Tracker       // Command usage counts
+	latency        *latency.Tracker     // Per-handler latency histograms
+	scheduler      *scheduler.Scheduler // Recurring jobs and delayed tasks
+	catalog        *i18n.Catalog        // Message catalogs by locale
+	payloads       *payload.Registry    // Long callback data behind short tokens
+	tickets        ticketSinks          // Ticket sinks registered from code
+	providers      codeProviders        // Keyboard providers registered from code
+
+	onConversationEnd func(ctx context.Context, c *conv.Conversation)     // User callback for ended conversations
+	onReferral        func(ctx context.Context, referrerID, userID int64) // User callback for attributed referrals
+	onSlowHandler     SlowHandlerFunc                                     // User callback for latency budget breaches
+	onAPIErrorSpike   APIErrorSpikeFunc                                   // User callback for API error spikes
+	onBackpressure    BackpressureFunc                                    // User callback for backpressure changes
+	onProviderError   conv.ProviderErrorFunc                              // User callback for failed keyboard providers
+	onError           handler.ErrorHandler                                // User callback for handler errors and panics
+
+	maintenance atomic.Bool // Cached maintenance mode state
+	startedAt   time.Time   // Time Start was called, for uptime reporting
+
+	botHandler *th.BotHandler       // Telego handler for update processing
+	source     source.Source        // Update source set from code, overriding bot.updates
+	dispatcher *dispatch.Dispatcher // Orders and bounds the processing of updates
+	busPub     bus.Publisher        // Broker events are published to, nil if none
+	busSub     bus.Subscriber       // Broker outgoing messages are consumed from, nil if none
+	emitter    *bus.Emitter         // Publishes events to busPub in the background
+	stopChan   chan struct{}        // Channel for signaling graceful shutdown
 }
 
 // New creates a new Wrapper instance with the provided configuration.
@@ -124,12 +219,14 @@ func New(cfg *config.Config) (*Wrapper, error) {
 	if cfg.Bot == nil || cfg.Bot.Token == "" {
 		return nil, fmt.Errorf("bot token is not configured")
 	}
+	installBuiltins(cfg)
 
 	// Create the core bot instance
 	bot, err := core.NewBot(cfg.Bot.Token)
 	if err != nil {
 		return nil, err
 	}
+	bot.SetLimits(sendLimits(cfg.Bot.RateLimit))
 
 	// Get TTL from configuration or use default
 	ttl := 30 * time.Minute
@@ -154,21 +251,115 @@ func New(cfg *config.Config) (*Wrapper, error) {
 	// Create menu manager for menu display
 	menuManager := menu.NewManager(bot, cfg)
 
+	// Load the message catalogs
+	catalog := i18n.NewCatalog("en")
+	if err := loadCatalogs(catalog, cfg); err != nil {
+		return nil, fmt.Errorf("failed to load i18n catalogs: %w", err)
+	}
+
+	// Create the store: disk-backed if a directory is configured, in-memory otherwise
+	st, err := openStore(cfg.Bot)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open store: %w", err)
+	}
+	if cfg.Bot.PersistConversations {
+		convManager.SetStore(st)
+	}
+	convManager.SetTopicScoped(cfg.Bot.TopicConversations)
+
 	w := &Wrapper{
-		bot:         bot,
-		config:      cfg,
-		router:      router,
-		menuManager: menuManager,
-		convManager: convManager,
-		flowEngine:  flowEngine,
-		stopChan:    make(chan struct{}),
+		bot:            bot,
+		router:         router,
+		menuManager:    menuManager,
+		convManager:    convManager,
+		flowEngine:     flowEngine,
+		store:          st,
+		ownsStore:      true,
+		users:          users.NewRegistry(st),
+		referrals:      referral.NewTracker(st),
+		ledger:         ledger.New(st),
+		quotas:         quota.NewLimiter(st),
+		threads:        core.NewThreadTracker(0),
+		sentLog:        retention.NewLog(st),
+		alertStates:    alert.NewTracker(st),
+		voteStates:     vote.NewTracker(st),
+		rsvpEvents:     rsvp.NewTracker(st),
+		reminderStates: reminder.NewTracker(st),
+		events:         eventlog.NewLog(st, cfg.Bot.EventLog.GetRetention()),
+		usage:          usage.NewTracker(st, cfg.Bot.UsageStats.GetRetention()),
+		latency:        latency.NewTracker(),
+		scheduler:      scheduler.New(st),
+		catalog:        catalog,
+		payloads:       payload.New(st, cfg.Bot.GetCallbackPayloadTTL()),
+		stopChan:       make(chan struct{}),
 	}
+	w.config.Store(cfg)
+
+	// Expose credit balances to conditions and templates
+	w.setupCredits()
+
+	// Enforce command cooldowns and daily limits
+	w.setupQuotas()
+
+	// Attribute referrals first, while new users are still unknown to the registry
+	w.setupReferrals()
+
+	// Track users and their completed flows for segmentation
+	router.AddUpdateObserver(w.recordUser)
+	convManager.SetOnEnd(w.conversationEnded)
+	w.installSegments(cfg)
+
+	// Register keyboard providers declared in configuration
+	w.installProviders(cfg)
+
+	// Expose chat settings to conditions, templates, and StoreAs
+	flowEngine.SetChatSettingsStore(chatSettingsStore{w: w})
+	menuManager.SetRenderer(w.renderMenuText)
+	menuManager.SetShownHandler(w.menuShown)
 
 	// Register internal callback handlers for built-in functionality
 	w.setupInternalHandlers()
 
+	// Set up feature flags, maintenance mode, the cancel command, and the admin panel
+	w.setupAdminState()
+	w.setupCancelCommand()
+	w.setupAdminPanel()
+	w.setupBroadcastComposer()
+	w.setupCharts()
+	w.setupAlerts()
+	w.setupVotes()
+	w.setupRSVPs()
+	w.setupReminders()
+	w.setupTimezones()
+	w.setupI18n()
+	w.setupScheduler()
+	w.setupPayloads()
+	w.setupBindings()
+	w.setupLogging()
+	w.setupAnomalies()
+	w.setupStepTimeouts()
+	w.setupShadows()
+	w.setupMembers()
+
+	// Log messages sent to chats with retention limits
+	bot.SetSentObserver(w.recordSent)
+
+	// Persist router events when the event log is enabled
+	router.SetEventRecorder(w.recordEvent)
+
+	// Track handler latencies against their budgets
+	router.SetLatencyObserver(w.observeLatency)
+	flowEngine.SetLatencyObserver(w.observeLatency)
+
+	// Record keyboard provider failures before falling back
+	flowEngine.SetProviderErrorHandler(w.providerError)
+
+	// Log and report handler errors and recovered panics
+	w.router.SetErrorHandler(w.handleError)
+
 	// Set up step display function for router
 	w.router.SetStepDisplayFunc(w.showStepPrompt)
+	w.router.SetIntentDispatcher(w.dispatchIntent)
 
 	return w, nil
 }
@@ -202,7 +393,7 @@ func NewWithHandlers(cfg *config.Config, registry *config.HandlerRegistry) (*Wra
 	w.applyHandlerRegistry(registry)
 
 	// Register handlers from configuration
-	w.registerConfiguredHandlers(registry)
+	w.registerConfiguredHandlers(cfg, registry)
 
 	return w, nil
 }
@@ -244,6 +435,22 @@ func (w *Wrapper) applyHandlerRegistry(registry *config.HandlerRegistry) {
 		})
 	}
 
+	// Register flow validators with type conversion
+	for name, validator := range registry.FlowValidators {
+		v := validator // capture loop variable
+		w.flowEngine.RegisterFlowValidator(name, func(ctx context.Context, c *conv.Conversation) error {
+			return v(ctx, c)
+		})
+	}
+
+	// Register compute functions with type conversion
+	for name, fn := range registry.ComputeFuncs {
+		f := fn // capture loop variable
+		w.flowEngine.RegisterComputeFunc(name, func(ctx context.Context, c *conv.Conversation) (interface{}, error) {
+			return f(ctx, c)
+		})
+	}
+
 	// Set conversation lifecycle hooks
 	if registry.OnConversationStart != nil {
 		fn := registry.OnConversationStart
@@ -254,7 +461,7 @@ func (w *Wrapper) applyHandlerRegistry(registry *config.HandlerRegistry) {
 
 	if registry.OnConversationEnd != nil {
 		fn := registry.OnConversationEnd
-		w.convManager.SetOnEnd(func(ctx context.Context, c *conv.Conversation) {
+		w.OnConversationEnd(func(ctx context.Context, c *conv.Conversation) {
 			fn(ctx, c)
 		})
 	}
@@ -267,23 +474,33 @@ func (w *Wrapper) applyHandlerRegistry(registry *config.HandlerRegistry) {
 	}
 }
 
-// registerConfiguredHandlers registers handlers based on configuration.
-// This connects command and callback configurations to their handler implementations.
-func (w *Wrapper) registerConfiguredHandlers(registry *config.HandlerRegistry) {
-	if w.config.Bot == nil {
+// registerConfiguredHandlers routes the configuration's commands and callbacks
+// to their handler implementations or built-in actions. It replaces the routes
+// of the previous configuration, so ReloadConfig calls it again with the
+// registry the wrapper was created with.
+func (w *Wrapper) registerConfiguredHandlers(cfg *config.Config, registry *config.HandlerRegistry) {
+	w.registry = registry
+	w.configRoutes = true
+	routes := handler.ConfiguredRoutes{
+		Commands:  make(map[string]handler.CommandHandler),
+		Callbacks: make(map[string]handler.CallbackHandler),
+		Prefixes:  make(map[string]handler.CallbackHandler),
+	}
+	if cfg.Bot == nil {
+		w.router.SetConfiguredRoutes(routes)
 		return
 	}
 
-	// Register command handlers from configuration
-	for _, cmd := range w.config.Bot.Commands {
+	// Route commands from configuration
+	for _, cmd := range cfg.Bot.Commands {
 		cmdCfg := cmd // capture loop variable
 
 		// If handler is specified, look it up in registry
 		if cmdCfg.Handler != "" && registry != nil {
-			if handler, ok := registry.CommandHandlers[cmdCfg.Handler]; ok {
-				w.router.RegisterCommand(cmdCfg.Command, func(ctx context.Context, msg telego.Message) error {
-					return handler(ctx, msg)
-				})
+			if h, ok := registry.CommandHandlers[cmdCfg.Handler]; ok {
+				routes.Commands[cmdCfg.Command] = func(ctx context.Context, msg telego.Message) error {
+					return h(ctx, msg)
+				}
 				continue
 			}
 		}
@@ -292,48 +509,49 @@ func (w *Wrapper) registerConfiguredHandlers(registry *config.HandlerRegistry) {
 		switch cmdCfg.Action {
 		case "show_menu":
 			target := cmdCfg.Target
-			w.router.RegisterCommand(cmdCfg.Command, func(ctx context.Context, msg telego.Message) error {
-				return w.ShowMainMenu(ctx, msg.Chat.ID, msg.MessageThreadID, 0)
-			})
-			if target != "" && target != "main" {
-				targetID := target
-				w.router.RegisterCommand(cmdCfg.Command, func(ctx context.Context, msg telego.Message) error {
-					return w.ShowMenu(ctx, msg.Chat.ID, msg.MessageThreadID, targetID, 0)
-				})
+			if target == "" || target == "main" {
+				routes.Commands[cmdCfg.Command] = func(ctx context.Context, msg telego.Message) error {
+					return w.ShowMainMenu(ctx, msg.Chat.ID, msg.MessageThreadID, 0)
+				}
+			} else {
+				routes.Commands[cmdCfg.Command] = func(ctx context.Context, msg telego.Message) error {
+					return w.ShowMenu(ctx, msg.Chat.ID, msg.MessageThreadID, target, 0)
+				}
 			}
 		case "start_flow":
 			if cmdCfg.Target != "" {
 				flowID := cmdCfg.Target
-				w.router.RegisterCommand(cmdCfg.Command, func(ctx context.Context, msg telego.Message) error {
+				routes.Commands[cmdCfg.Command] = func(ctx context.Context, msg telego.Message) error {
 					_, err := w.StartConversation(ctx, msg.From.ID, msg.Chat.ID, msg.MessageThreadID, flowID, 0)
+					if w.notifyFlowDenied(ctx, err, msg.Chat.ID, msg.MessageThreadID, "") {
+						return nil
+					}
 					if err != nil {
 						return w.ShowMainMenu(ctx, msg.Chat.ID, msg.MessageThreadID, 0)
 					}
-					c := w.convManager.Get(msg.From.ID, msg.Chat.ID)
+					c := w.convManager.GetIn(msg.From.ID, msg.Chat.ID, msg.MessageThreadID)
 					if c != nil {
 						return w.showStepPrompt(ctx, c)
 					}
 					return nil
-				})
+				}
 			}
 		}
 	}
 
-	// Register callback handlers from configuration
-	for _, cb := range w.config.Callbacks {
+	// Route callbacks from configuration
+	for _, cb := range cfg.Callbacks {
 		cbCfg := cb // capture loop variable
+		callbacks := routes.Callbacks
+		if cbCfg.IsPrefix {
+			callbacks = routes.Prefixes
+		}
 
 		// If handler is specified, look it up in registry
 		if cbCfg.Handler != "" && registry != nil {
-			if handler, ok := registry.CallbackHandlers[cbCfg.Handler]; ok {
-				if cbCfg.IsPrefix {
-					w.router.RegisterCallbackPrefix(cbCfg.Callback, func(ctx context.Context, query telego.CallbackQuery) error {
-						return handler(ctx, query)
-					})
-				} else {
-					w.router.RegisterCallback(cbCfg.Callback, func(ctx context.Context, query telego.CallbackQuery) error {
-						return handler(ctx, query)
-					})
+			if h, ok := registry.CallbackHandlers[cbCfg.Handler]; ok {
+				callbacks[cbCfg.Callback] = func(ctx context.Context, query telego.CallbackQuery) error {
+					return h(ctx, query)
 				}
 				continue
 			}
@@ -344,96 +562,140 @@ func (w *Wrapper) registerConfiguredHandlers(registry *config.HandlerRegistry) {
 		case "show_menu":
 			target := cbCfg.Target
 			answerText := cbCfg.AnswerText
-			if cbCfg.IsPrefix {
-				w.router.RegisterCallbackPrefix(cbCfg.Callback, func(ctx context.Context, query telego.CallbackQuery) error {
-					_ = w.bot.AnswerCallback(ctx, query.ID, answerText)
-					chatID := query.Message.GetChat().ID
-					msgID := query.Message.GetMessageID()
-					if target == "" || target == "main" {
-						_, err := w.menuManager.EditToMainMenu(ctx, chatID, msgID, nil)
-						return err
-					}
-					_, err := w.menuManager.EditToMenu(ctx, chatID, msgID, target, nil)
+			callbacks[cbCfg.Callback] = func(ctx context.Context, query telego.CallbackQuery) error {
+				_ = w.bot.AnswerCallback(ctx, query.ID, answerText)
+				chatID := query.Message.GetChat().ID
+				msgID := query.Message.GetMessageID()
+				evaluator := w.menuEvaluator(ctx, chatID, query.From.ID)
+				if target == "" || target == "main" {
+					_, err := w.menuManager.EditToMainMenu(ctx, chatID, msgID, evaluator)
 					return err
-				})
-			} else {
-				w.router.RegisterCallback(cbCfg.Callback, func(ctx context.Context, query telego.CallbackQuery) error {
-					_ = w.bot.AnswerCallback(ctx, query.ID, answerText)
-					chatID := query.Message.GetChat().ID
-					msgID := query.Message.GetMessageID()
-					if target == "" || target == "main" {
-						_, err := w.menuManager.EditToMainMenu(ctx, chatID, msgID, nil)
-						return err
-					}
-					_, err := w.menuManager.EditToMenu(ctx, chatID, msgID, target, nil)
-					return err
-				})
+				}
+				_, err := w.menuManager.EditToMenu(ctx, chatID, msgID, target, evaluator)
+				return err
 			}
 		case "start_flow":
 			if cbCfg.Target != "" {
 				flowID := cbCfg.Target
 				answerText := cbCfg.AnswerText
-				w.router.RegisterCallback(cbCfg.Callback, func(ctx context.Context, query telego.CallbackQuery) error {
-					_ = w.bot.AnswerCallback(ctx, query.ID, answerText)
+				routes.Callbacks[cbCfg.Callback] = func(ctx context.Context, query telego.CallbackQuery) error {
 					chatID := query.Message.GetChat().ID
 					topicID := core.GetTopicID(query.Message)
 					msgID := query.Message.GetMessageID()
 					_, err := w.StartConversation(ctx, query.From.ID, chatID, topicID, flowID, msgID)
+					if w.notifyFlowDenied(ctx, err, chatID, topicID, query.ID) {
+						return nil
+					}
+					_ = w.bot.AnswerCallback(ctx, query.ID, answerText)
 					if err != nil {
 						return w.ShowMainMenu(ctx, chatID, topicID, msgID)
 					}
-					c := w.convManager.Get(query.From.ID, chatID)
+					c := w.convManager.GetIn(query.From.ID, chatID, topicID)
 					if c != nil {
 						return w.showStepPrompt(ctx, c)
 					}
 					return nil
-				})
+				}
 			}
 		case "answer":
 			answerText := cbCfg.AnswerText
-			w.router.RegisterCallback(cbCfg.Callback, func(ctx context.Context, query telego.CallbackQuery) error {
+			routes.Callbacks[cbCfg.Callback] = func(ctx context.Context, query telego.CallbackQuery) error {
 				return w.bot.AnswerCallback(ctx, query.ID, answerText)
-			})
+			}
 		}
 	}
+	w.router.SetConfiguredRoutes(routes)
 }
 
 // setupInternalHandlers registers internal handlers for built-in callbacks.
-// This includes main menu navigation, menu jumping, flow starting, and step display.
+// This includes main menu navigation, menu jumping, menu pagination, flow starting, and step display.
 func (w *Wrapper) setupInternalHandlers() {
 	// Main menu handler - returns user to the main menu
 	w.router.RegisterCallback(core.CallbackMainMenu+"_internal", func(ctx context.Context, query telego.CallbackQuery) error {
 		chatID := query.Message.GetChat().ID
 		msgID := query.Message.GetMessageID()
-		_, err := w.menuManager.EditToMainMenu(ctx, chatID, msgID, nil)
+		_, err := w.menuManager.EditToMainMenu(ctx, chatID, msgID, w.menuEvaluator(ctx, chatID, query.From.ID))
 		return err
 	})
 
-	// Menu navigation handler - jumps to a specific menu by ID
+	// Menu navigation handler - jumps to a specific menu by ID.
+	// Shared group menus open the menu in a fork instead
 	w.router.RegisterCallbackPrefix("menu:", func(ctx context.Context, query telego.CallbackQuery) error {
-		_ = w.bot.AnswerCallback(ctx, query.ID, "")
 		menuID := core.ParseCallbackData(query.Data, "menu:")
 		chatID := query.Message.GetChat().ID
 		msgID := query.Message.GetMessageID()
-		_, err := w.menuManager.EditToMenu(ctx, chatID, msgID, menuID, nil)
+		if mode := w.sharedMode(chatID, msgID); mode != config.ForkNone {
+			return w.forkMenu(ctx, query, mode, menuID)
+		}
+		_ = w.bot.AnswerCallback(ctx, query.ID, "")
+		_, err := w.menuManager.EditToMenu(ctx, chatID, msgID, menuID, w.menuEvaluator(ctx, chatID, query.From.ID))
 		return err
 	})
 
-	// Flow start handler - initiates a conversation flow
-	w.router.RegisterCallbackPrefix("flow:", func(ctx context.Context, query telego.CallbackQuery) error {
+	// Menu pagination handler - shows another page of a paginated menu in the same message
+	w.router.RegisterCallbackPrefix(core.CallbackPage, func(ctx context.Context, query telego.CallbackQuery) error {
 		_ = w.bot.AnswerCallback(ctx, query.ID, "")
+		menuID, page, ok := menu.ParsePageCallback(query.Data)
+		if !ok {
+			return nil
+		}
+		chatID := query.Message.GetChat().ID
+		msgID := query.Message.GetMessageID()
+		_, err := w.menuManager.HandlePageChange(ctx, chatID, msgID, menuID, page, w.menuEvaluator(ctx, chatID, query.From.ID))
+		return err
+	})
+
+	// Keyboard pagination handler - shows another page of a paginated provider's
+	// buttons on the current step
+	w.router.RegisterCallbackPrefix(provider.CallbackPage, func(ctx context.Context, query telego.CallbackQuery) error {
+		_ = w.bot.AnswerCallback(ctx, query.ID, "")
+		name, page, ok := provider.ParsePageCallback(query.Data)
+		if !ok {
+			return nil
+		}
+		c := w.convManager.GetIn(query.From.ID, query.Message.GetChat().ID, core.GetTopicID(query.Message))
+		if c == nil {
+			return nil
+		}
+		// Ignore page buttons of a step the conversation has left
+		step := w.flowEngine.GetStep(c.FlowID, c.StepID)
+		if step == nil || step.Keyboard == nil || step.Keyboard.Provider != name {
+			return nil
+		}
+		c.Set(provider.PageKey(name), page)
+		if err := w.convManager.Save(ctx, c); err != nil {
+			return err
+		}
+		return w.showStepPrompt(ctx, c)
+	})
+
+	// No-op handler - acknowledges buttons without an action, like page indicators
+	w.router.RegisterCallback(core.CallbackNoop, func(ctx context.Context, query telego.CallbackQuery) error {
+		return w.bot.AnswerCallback(ctx, query.ID, "")
+	})
+
+	// Flow start handler - initiates a conversation flow.
+	// Shared group menus start the flow in a fork instead
+	w.router.RegisterCallbackPrefix("flow:", func(ctx context.Context, query telego.CallbackQuery) error {
 		flowID := core.ParseCallbackData(query.Data, "flow:")
 		chatID := query.Message.GetChat().ID
 		topicID := core.GetTopicID(query.Message)
 		msgID := query.Message.GetMessageID()
+		if mode := w.sharedMode(chatID, msgID); mode != config.ForkNone {
+			return w.forkFlow(ctx, query, mode, flowID)
+		}
 
 		_, err := w.StartConversation(ctx, query.From.ID, chatID, topicID, flowID, msgID)
+		if w.notifyFlowDenied(ctx, err, chatID, topicID, query.ID) {
+			return nil
+		}
+		_ = w.bot.AnswerCallback(ctx, query.ID, "")
 		if err != nil {
 			return w.ShowMainMenu(ctx, chatID, topicID, msgID)
 		}
 
 		// Display the initial step prompt
-		c := w.convManager.Get(query.From.ID, chatID)
+		c := w.convManager.GetIn(query.From.ID, chatID, topicID)
 		if c != nil {
 			return w.showStepPrompt(ctx, c)
 		}
@@ -452,37 +714,44 @@ func (w *Wrapper) setupInternalHandlers() {
 //   - error: Error if starting long polling or handler creation fails
 //
 // The method performs the following operations:
-// 1. Registers bot commands with Telegram (if RegisterCommands is true)
-// 2. Starts long polling for updates
-// 3. Creates and configures the bot handler
-// 4. Starts periodic cleanup of expired conversations
+// 1. Checks that the handlers flows reference are registered (see CheckFlows)
+// 2. Registers bot commands with Telegram (if RegisterCommands is true)
+// 3. Starts long polling for updates
+// 4. Creates and configures the bot handler
+// 5. Starts periodic cleanup of expired conversations
 func (w *Wrapper) Start(ctx context.Context) error {
-	// Determine whether to register commands based on configuration
-	shouldRegister := true
-	if w.config.Bot != nil && w.config.Bot.RegisterCommands != nil {
-		shouldRegister = *w.config.Bot.RegisterCommands
+	// Fail on broken flows now rather than in the middle of a conversation
+	report := w.CheckFlows()
+	if err := report.Err(); err != nil {
+		return fmt.Errorf("invalid flows: %w", err)
 	}
+	w.logFlowWarnings(report)
 
 	// Register bot commands with Telegram
-	if shouldRegister && w.config.Bot != nil && len(w.config.Bot.Commands) > 0 {
-		commands := make([]telego.BotCommand, len(w.config.Bot.Commands))
-		for i, cmd := range w.config.Bot.Commands {
-			commands[i] = telego.BotCommand{
-				Command:     cmd.Command,
-				Description: cmd.Description,
-			}
-		}
+	if commands := botCommands(w.Config()); len(commands) > 0 {
 		_ = w.bot.SetMyCommands(ctx, commands)
 	}
 
-	// Start long polling to receive updates from Telegram
-	updates, err := w.bot.Telego().UpdatesViaLongPolling(ctx, nil)
+	// Set the menu button of private chats, e.g. to open a Web App
+	w.applyMenuButton(ctx, menuButton(w.Config()))
+
+	// Start receiving updates, by default with long polling.
+	// chat_member updates are opt-in and needed to attribute invite-link referrals
+	// and to route members joining and leaving.
+	var allowed []string
+	if w.referralEnabled() || w.router.WantsChatMembers() {
+		allowed = allowedUpdatesWithChatMember
+	}
+	updates, err := w.updateSource().Updates(ctx, w.bot.Telego(), allowed)
 	if err != nil {
-		return fmt.Errorf("failed to start long polling: %w", err)
+		return fmt.Errorf("failed to start receiving updates: %w", err)
 	}
 
-	// Create the bot handler for processing updates
-	w.botHandler, err = th.NewBotHandler(w.bot.Telego(), updates)
+	// Publish updates and consume outgoing messages through the message broker
+	updates = w.startBus(ctx, updates)
+
+	// Create the bot handler for processing updates, one at a time per user and chat
+	w.botHandler, err = th.NewBotHandler(w.bot.Telego(), w.dispatchUpdates(ctx, updates))
 	if err != nil {
 		return fmt.Errorf("failed to create handler: %w", err)
 	}
@@ -493,6 +762,35 @@ func (w *Wrapper) Start(ctx context.Context) error {
 	// Start periodic cleanup task for expired conversations
 	w.convManager.StartCleanupTask(ctx, 5*time.Minute)
 
+	// Start periodic deletion of messages beyond retention limits
+	w.startRetentionTask(ctx, DefaultRetentionInterval)
+
+	// Re-arm escalations of alerts still awaiting acknowledgment
+	w.resumeAlerts(ctx)
+
+	// Re-arm the deadlines of open votes
+	w.resumeVotes(ctx)
+
+	// Re-arm the signup deadlines of open event sheets
+	w.resumeRSVPs(ctx)
+
+	// Re-arm the delivery of pending reminders
+	w.resumeReminders(ctx)
+
+	// Run scheduled jobs and re-arm delayed tasks
+	if err := w.scheduler.Start(ctx); err != nil {
+		return fmt.Errorf("failed to start scheduler: %w", err)
+	}
+
+	w.startedAt = time.Now()
+
+	// Reload the configuration when its file changes
+	if w.Config().Bot.WatchConfig {
+		if err := w.WatchConfig(ctx); err != nil {
+			return err
+		}
+	}
+
 	// Start processing updates in a goroutine
 	go w.botHandler.Start()
 
@@ -501,15 +799,91 @@ func (w *Wrapper) Start(ctx context.Context) error {
 
 // Stop gracefully stops the Wrapper and releases all resources.
 // It stops the bot handler and signals shutdown via the stop channel.
-// Note: Long polling is stopped by canceling the context passed to Start().
+// Note: The update source is stopped by canceling the context passed to Start().
 func (w *Wrapper) Stop() {
 	if w.botHandler != nil {
 		_ = w.botHandler.Stop()
 	}
 	close(w.stopChan)
-	if w.config.Bot.DeleteCommandsOnExit {
+	w.stopAlerts()
+	w.stopVotes()
+	w.stopRSVPs()
+	w.stopReminders()
+	w.scheduler.Stop()
+	w.FlushLogs(context.Background())
+	if w.Config().Bot.DeleteCommandsOnExit {
 		_ = w.Bot().Telego().DeleteMyCommands(context.Background(), nil)
 	}
+	w.storeMu.RLock()
+	if closer, ok := w.store.(io.Closer); ok && w.ownsStore {
+		_ = closer.Close()
+	}
+	w.storeMu.RUnlock()
+}
+
+// ReloadConfig replaces the configuration at runtime without restarting the bot.
+// The new configuration is validated first; on error the current one stays in effect.
+// Menus, flows, conversation settings, and the routes of configured commands
+// and callbacks take effect immediately, and the command list is registered
+// with Telegram again if it changed.
+// Concurrent reloads are applied one at a time.
+func (w *Wrapper) ReloadConfig(cfg *config.Config) error {
+	if cfg == nil || cfg.Bot == nil {
+		return fmt.Errorf("configuration cannot be nil")
+	}
+	w.reloadMu.Lock()
+	defer w.reloadMu.Unlock()
+	installBuiltins(cfg)
+	if err := cfg.Validate(); err != nil {
+		return fmt.Errorf("invalid configuration: %w", err)
+	}
+	report := cfg.CheckFlows(w.flowEngine)
+	if err := report.Err(); err != nil {
+		return fmt.Errorf("invalid configuration: %w", err)
+	}
+	if err := loadCatalogs(w.catalog, cfg); err != nil {
+		return fmt.Errorf("failed to load i18n catalogs: %w", err)
+	}
+
+	old := w.Config()
+	oldCommands := botCommands(old)
+	oldMenuButton := menuButton(old)
+	w.config.Store(cfg)
+	w.logFlowWarnings(report)
+	w.router.SetConfig(cfg)
+	if w.configRoutes {
+		w.registerConfiguredHandlers(cfg, w.registry)
+	}
+	w.menuManager.SetConfig(cfg)
+	w.flowEngine.SetConfig(cfg)
+	w.bot.SetLimits(sendLimits(cfg.Bot.RateLimit))
+	w.payloads.SetTTL(cfg.Bot.GetCallbackPayloadTTL())
+	w.scheduleLogFlush()
+	w.anomalies.SetSettings(anomalySettings(cfg.Bot.APIAnomalies))
+	if w.dispatcher != nil {
+		w.dispatcher.SetPolicy(dispatchPolicy(cfg.Bot.Dispatch, cfg.Bot.Dispatch.GetWorkers()))
+		w.dispatcher.SetPools(dispatchPools(cfg.Bot.Dispatch))
+	}
+	w.installSegments(cfg)
+	w.installProviders(cfg)
+
+	w.storeMu.Lock()
+	w.events = eventlog.NewLog(w.store, cfg.Bot.EventLog.GetRetention())
+	w.usage = usage.NewTracker(w.store, cfg.Bot.UsageStats.GetRetention())
+	w.storeMu.Unlock()
+
+	if commands := botCommands(cfg); !slices.Equal(commands, oldCommands) {
+		ctx := context.Background()
+		if len(commands) == 0 {
+			_ = w.bot.DeleteMyCommands(ctx)
+		} else {
+			_ = w.bot.SetMyCommands(ctx, commands)
+		}
+	}
+	if button := menuButton(cfg); button != nil && (oldMenuButton == nil || *button != *oldMenuButton) {
+		w.applyMenuButton(context.Background(), button)
+	}
+	return nil
 }
 
 // Bot returns the underlying core.Bot instance for direct Telegram API access.
@@ -517,9 +891,72 @@ func (w *Wrapper) Bot() *core.Bot {
 	return w.bot
 }
 
-// Config returns the current configuration.
+// Config returns the current configuration. It is safe to call from handlers
+// while the configuration is reloaded; callers needing several settings to be
+// consistent should read it once.
 func (w *Wrapper) Config() *config.Config {
-	return w.config
+	return w.config.Load()
+}
+
+// Store returns the store used for persistent state.
+func (w *Wrapper) Store() store.Store {
+	w.storeMu.RLock()
+	defer w.storeMu.RUnlock()
+	return w.store
+}
+
+// SetStore replaces the store used for persistent state.
+// Call this before Start; settings obtained earlier keep using the previous store.
+// A store opened from store_dir is closed; the caller owns s and closes it.
+// With persist_conversations enabled, conversations are saved in s as well.
+func (w *Wrapper) SetStore(s store.Store) {
+	w.storeMu.Lock()
+	defer w.storeMu.Unlock()
+	if closer, ok := w.store.(io.Closer); ok && w.ownsStore {
+		_ = closer.Close()
+	}
+	w.store = s
+	w.ownsStore = false
+	w.users = users.NewRegistry(s)
+	w.referrals = referral.NewTracker(s)
+	w.ledger = ledger.New(s)
+	w.quotas = quota.NewLimiter(s)
+	w.sentLog = retention.NewLog(s)
+	w.alertStates = alert.NewTracker(s)
+	w.voteStates = vote.NewTracker(s)
+	w.rsvpEvents = rsvp.NewTracker(s)
+	w.reminderStates = reminder.NewTracker(s)
+	w.scheduler.SetStore(s)
+	w.payloads.SetStore(s)
+	w.router.SetBindingStore(s)
+	w.events = eventlog.NewLog(s, w.Config().Bot.EventLog.GetRetention())
+	w.usage = usage.NewTracker(s, w.Config().Bot.UsageStats.GetRetention())
+	// Drop cached settings bound to the old store; the map itself holds a lock and must not be copied
+	w.chatSettings.Range(func(key, _ interface{}) bool {
+		w.chatSettings.Delete(key)
+		return true
+	})
+	if w.Config().Bot.PersistConversations {
+		w.convManager.SetStore(s)
+	}
+}
+
+// openStore creates the store configured by StoreDir and StoreBackend.
+func openStore(cfg *config.BotConfig) (store.Store, error) {
+	if cfg.StoreDir == "" {
+		return store.NewMemoryStore(), nil
+	}
+	switch cfg.GetStoreBackend() {
+	case config.StoreBackendFile:
+		return store.NewFileStore(cfg.StoreDir)
+	case config.StoreBackendBolt:
+		if err := os.MkdirAll(cfg.StoreDir, 0o755); err != nil {
+			return nil, err
+		}
+		return store.NewBoltStore(filepath.Join(cfg.StoreDir, config.BoltFileName))
+	default:
+		return nil, fmt.Errorf("unknown store backend %q", cfg.StoreBackend)
+	}
 }
 
 // Router returns the message router for registering custom handlers.
@@ -527,6 +964,12 @@ func (w *Wrapper) Router() *handler.Router {
 	return w.router
 }
 
+// Breakers returns the circuit breakers of step handlers and keyboard providers,
+// e.g. to report their states or close a circuit after fixing an upstream.
+func (w *Wrapper) Breakers() *breaker.Set {
+	return w.flowEngine.Breakers()
+}
+
 // SetAuthFunc sets the authentication function for user authorization.
 // The auth function is called before processing any command, callback, or message.
 //
@@ -554,6 +997,71 @@ func (w *Wrapper) RegisterCallback(callback string, h handler.CallbackHandler) {
 	w.router.RegisterCallbackPrefix(callback, h)
 }
 
+// RegisterCallbackPattern registers a callback handler for data matching a
+// pattern with named placeholders, so handlers of composite data don't split
+// it themselves. Read the values with CallbackParam.
+//
+// Parameters:
+//   - pattern: The pattern to match, e.g. "item:{id}:{action}"
+//   - h: The handler function to execute when matching callback is received
+func (w *Wrapper) RegisterCallbackPattern(pattern string, h handler.CallbackHandler) {
+	w.router.RegisterCallbackPattern(pattern, h)
+}
+
+// RegisterCallbackRegex registers a callback handler for data matching a
+// regular expression as a whole. Read its groups with CallbackParam, by name
+// or by index for unnamed groups.
+//
+// Parameters:
+//   - re: The expression to match, e.g. regexp.MustCompile(`^page:(?P<n>\d+)$`)
+//   - h: The handler function to execute when matching callback is received
+func (w *Wrapper) RegisterCallbackRegex(re *regexp.Regexp, h handler.CallbackHandler) {
+	w.router.RegisterCallbackRegex(re, h)
+}
+
+// RegisterOneShotCallback registers a callback handler for a one-shot action
+// button, e.g. an order confirmation. Once the button is pressed, further presses
+// of it on the same message within one_shot.ttl get an "already processed" alert
+// instead of running h again. If h returns an error, the button can be pressed again.
+//
+// Parameters:
+//   - callback: The callback data prefix to match
+//   - h: The handler function to execute when matching callback is received
+func (w *Wrapper) RegisterOneShotCallback(callback string, h handler.CallbackHandler) {
+	w.router.MarkOneShot(callback)
+	w.router.RegisterCallbackPrefix(callback, h)
+}
+
+// RegisterSignedCallback registers a callback handler for a sensitive action.
+// The router dispatches matching callbacks only if their data was signed with
+// SignCallback for the user pressing the button and hasn't expired; other
+// presses get an alert. h receives the data without the signature.
+//
+// Parameters:
+//   - callback: The callback data prefix to match
+//   - h: The handler function to execute when matching callback is received
+func (w *Wrapper) RegisterSignedCallback(callback string, h handler.CallbackHandler) {
+	w.router.RequireSigned(callback)
+	w.router.RegisterCallbackPrefix(callback, h)
+}
+
+// SignCallback signs callback data for a user, so only that user can trigger
+// the button. A positive ttl makes the button expire. The signature takes up to 20
+// bytes of Telegram's 64-byte callback data limit; data too long to carry it
+// is stored behind a token, which is signed instead.
+func (w *Wrapper) SignCallback(data string, userID int64, ttl time.Duration) (string, error) {
+	signer := w.router.Signer()
+	signed, err := signer.Sign(data, userID, ttl, time.Now())
+	if !errors.Is(err, signing.ErrTooLong) {
+		return signed, err
+	}
+	token, err := w.payloads.Put(context.Background(), data)
+	if err != nil {
+		return "", err
+	}
+	return signer.Sign(token, userID, ttl, time.Now())
+}
+
 // RegisterStepHandler registers a handler function for conversation step completion.
 // Step handlers are called when the OnComplete field of a step configuration is set.
 //
@@ -571,9 +1079,19 @@ func (w *Wrapper) RegisterStepHandler(name string, h conv.StepHandler) {
 //   - name: The provider name (referenced in keyboard configuration)
 //   - provider: Function that returns button data for the keyboard
 func (w *Wrapper) RegisterKeyboardProvider(name string, provider conv.KeyboardProvider) {
+	w.providers.add(name)
 	w.flowEngine.RegisterKeyboardProvider(name, provider)
 }
 
+// RegisterFallibleKeyboardProvider registers a dynamic keyboard data provider that
+// can fail. When it returns an error, the step shows the provider's latest result
+// or the keyboard's fallback_buttons, and the provider error hook is called.
+// Providers registered from code replace those declared in bot.providers.
+func (w *Wrapper) RegisterFallibleKeyboardProvider(name string, provider conv.FallibleKeyboardProvider) {
+	w.providers.add(name)
+	w.flowEngine.RegisterFallibleKeyboardProvider(name, provider)
+}
+
 // RegisterValidator registers a custom input validator.
 // Validators are called when a step's validation type is "custom".
 //
@@ -584,8 +1102,87 @@ func (w *Wrapper) RegisterValidator(name string, validator conv.Validator) {
 	w.flowEngine.RegisterValidator(name, validator)
 }
 
-// Use adds a middleware to the router's middleware chain.
-// Middleware are executed in the order they are added.
+// RegisterFlowValidator registers a cross-field flow validator.
+// Validators run over the collected data when a flow's checks name them,
+// before its final step completes.
+//
+// Parameters:
+//   - name: The validator name (referenced in a flow check's validator field)
+//   - validator: Function that returns an error, or conv.InvalidStep, if the data is inconsistent
+func (w *Wrapper) RegisterFlowValidator(name string, validator conv.FlowValidator) {
+	w.flowEngine.RegisterFlowValidator(name, validator)
+}
+
+// ValidateInput checks input against the validation rules of a flow step
+// without a conversation, e.g. to pre-check web form fields with the rules the
+// bot enforces. Default messages are in the language of ctx; tag it with
+// core.WithStrings(ctx, wrapper.Router().Strings(lang)) for other languages.
+// Returns conv.ErrStepNotFound if the flow has no such step.
+//
+// Example:
+//
+//	if err := wrapper.ValidateInput(ctx, "order", "amount", form.Get("amount")); err != nil {
+//		http.Error(rw, err.Error(), http.StatusBadRequest)
+//	}
+func (w *Wrapper) ValidateInput(ctx context.Context, flowID, stepID, input string) error {
+	return w.flowEngine.Validate(ctx, flowID, stepID, input)
+}
+
+// RegisterTransform registers a custom input transform.
+// Transforms are applied to text input listed in a step's transform configuration
+// before it is stored; a custom transform replaces a built-in one with the same name.
+//
+// Parameters:
+//   - name: The transform name (referenced in step transform configuration)
+//   - transform: Function that returns the transformed value, or an error shown to the user
+func (w *Wrapper) RegisterTransform(name string, transform conv.Transform) {
+	w.flowEngine.RegisterTransform(name, transform)
+}
+
+// RegisterComputeFunc registers a function for computed step fields.
+// Compute functions are called when a step completes and one of its computed
+// entries names the function as handler; the result is stored under the entry's key.
+//
+// Parameters:
+//   - name: The function name (referenced in computed entries)
+//   - fn: Function that returns the value to store
+func (w *Wrapper) RegisterComputeFunc(name string, fn conv.ComputeFunc) {
+	w.flowEngine.RegisterComputeFunc(name, fn)
+}
+
+// RegisterCompleter registers a completer for LLM steps.
+// Completers are called for each text input of a step with input type "llm"
+// whose configuration references the name; any model or service can back them.
+//
+// Parameters:
+//   - name: The completer name (referenced in the step's llm configuration)
+//   - completer: Completer that answers the input, optionally streaming partial text
+func (w *Wrapper) RegisterCompleter(name string, completer conv.Completer) {
+	w.flowEngine.RegisterCompleter(name, completer)
+}
+
+// SetTranscriber sets the speech-to-text backend for voice steps.
+// Voice input is downloaded and transcribed, and the transcript is validated,
+// stored as <store_as>_transcript, and used for branching. Pass nil to disable.
+func (w *Wrapper) SetTranscriber(t handler.Transcriber) {
+	w.router.SetTranscriber(t)
+}
+
+// RegisterPhotoAnalyzer registers a photo analyzer for photo steps.
+// Photos sent to steps whose analyzer field matches the name are downloaded and
+// analyzed; extracted text and labels are stored as <store_as>_text and
+// <store_as>_labels, and structured data is stored into conversation data.
+//
+// Parameters:
+//   - name: The analyzer name (referenced in step configuration)
+//   - analyzer: Analyzer that extracts information from the image bytes
+func (w *Wrapper) RegisterPhotoAnalyzer(name string, analyzer handler.PhotoAnalyzer) {
+	w.router.RegisterPhotoAnalyzer(name, analyzer)
+}
+
+// Use adds a middleware to the router's middleware chain, which wraps the
+// dispatch of every update. Middleware are executed in the order they are added,
+// the first added outermost; see Router.Use for the ordering guarantees.
 func (w *Wrapper) Use(middleware handler.Middleware) {
 	w.router.Use(middleware)
 }
@@ -596,8 +1193,9 @@ func (w *Wrapper) OnConversationStart(fn func(ctx context.Context, c *conv.Conve
 }
 
 // OnConversationEnd sets a callback function that is called when a conversation ends.
+// The conversation's State tells whether it was completed or cancelled.
 func (w *Wrapper) OnConversationEnd(fn func(ctx context.Context, c *conv.Conversation)) {
-	w.convManager.SetOnEnd(fn)
+	w.onConversationEnd = fn
 }
 
 // OnStepChange sets a callback function that is called when a conversation step changes.
@@ -605,8 +1203,48 @@ func (w *Wrapper) OnStepChange(fn func(ctx context.Context, c *conv.Conversation
 	w.convManager.SetOnStepChange(fn)
 }
 
+// FlowDeniedError is returned by StartConversation when a user does not meet
+// a flow's entry requirements, such as its roles, credit cost, or cooldown,
+// or is outside its rollout.
+type FlowDeniedError struct {
+	FlowID string // The flow that was denied
+	Text   string // Explanation shown to the user
+	MenuID string // Fallback menu already shown instead of Text, if set
+}
+
+// Error implements the error interface.
+func (e *FlowDeniedError) Error() string {
+	return "flow " + e.FlowID + " denied: " + e.Text
+}
+
+// notifyFlowDenied tells the user why a flow could not start.
+// Answers the callback with an alert if callbackID is set, otherwise replies in the chat.
+// Users who were shown a fallback menu instead are not told.
+// Returns false if err is not a FlowDeniedError.
+func (w *Wrapper) notifyFlowDenied(ctx context.Context, err error, chatID int64, topicID int, callbackID string) bool {
+	var denied *FlowDeniedError
+	if !errors.As(err, &denied) {
+		return false
+	}
+	if denied.MenuID != "" {
+		if callbackID != "" {
+			_ = w.bot.AnswerCallback(ctx, callbackID, "")
+		}
+		return true
+	}
+	if callbackID != "" {
+		_ = w.bot.AnswerCallbackWithAlert(ctx, callbackID, denied.Text)
+	} else {
+		_, _ = w.bot.SendMessage(ctx, chatID, topicID, denied.Text)
+	}
+	return true
+}
+
 // StartConversation initiates a new conversation flow for a user.
 // If the user already has an active conversation, it will be ended first.
+// Returns a *FlowDeniedError if the user does not meet the flow's entry requirements
+// (roles, credits, cooldown, or daily limit). Users outside the flow's rollout
+// get its fallback flow or menu instead.
 //
 // Parameters:
 //   - ctx: Context for cancellation
@@ -620,20 +1258,59 @@ func (w *Wrapper) OnStepChange(fn func(ctx context.Context, c *conv.Conversation
 //   - *conv.Conversation: The started conversation instance
 //   - error: Error if the flow doesn't exist
 func (w *Wrapper) StartConversation(ctx context.Context, userID, chatID int64, topicID int, flowID string, keyboardMsgID int) (*conv.Conversation, error) {
-	flow := w.config.GetFlow(flowID)
+	// Resolve the flow with any tenant overrides for this chat
+	flow := w.Config().ResolveFlow(chatID, flowID)
 	if flow == nil {
 		return nil, fmt.Errorf("flow %s does not exist", flowID)
 	}
 
+	if !flow.Rollout.Includes(userID, flow.ID) {
+		return w.startRolloutFallback(ctx, userID, chatID, topicID, flow, keyboardMsgID)
+	}
+	if err := w.checkFlowRoles(ctx, userID, flow); err != nil {
+		return nil, err
+	}
+	if err := w.checkFlowCredits(ctx, userID, chatID, flow); err != nil {
+		return nil, err
+	}
+	recorded, err := w.checkFlowQuota(ctx, userID, chatID, flow)
+	if err != nil {
+		return nil, err
+	}
+
 	c, err := w.convManager.Start(ctx, userID, chatID, topicID, flowID, flow.InitialStep, flow.TTL)
 	if err != nil {
+		w.refundFlowQuota(ctx, userID, flow, recorded)
 		return nil, err
 	}
 
+	if flow.Credits != nil {
+		charge := w.holdFlowCredits
+		if flow.Credits.GetChargeOn() == config.ChargeOnStart {
+			charge = w.chargeFlowCredits
+		}
+		if err := charge(ctx, c, flow); err != nil {
+			c.Cancel()
+			w.convManager.EndIn(ctx, userID, chatID, topicID)
+			w.refundFlowQuota(ctx, userID, flow, recorded)
+			return nil, err
+		}
+	}
+
+	// Seed tenant flow parameters into the conversation data
+	for key, value := range w.Config().FlowParams(chatID, flowID) {
+		c.Set(key, value)
+	}
+
 	if keyboardMsgID > 0 {
 		c.SetKeyboardMsgID(keyboardMsgID)
 	}
 
+	if err := w.convManager.Save(ctx, c); err != nil {
+		return nil, err
+	}
+
+	w.recordFlowEvent(ctx, eventlog.TypeFlowStarted, c, "")
 	return c, nil
 }
 
@@ -643,31 +1320,52 @@ func (w *Wrapper) GetConversation(userID, chatID int64) *conv.Conversation {
 	return w.convManager.Get(userID, chatID)
 }
 
+// GetConversationIn retrieves an active conversation for a user in a forum topic.
+// The topic is ignored unless topic_conversations is enabled.
+func (w *Wrapper) GetConversationIn(userID, chatID int64, topicID int) *conv.Conversation {
+	return w.convManager.GetIn(userID, chatID, topicID)
+}
+
+// RangeConversations calls fn with a read-only view of each active
+// conversation until fn returns false, e.g. for a dashboard or a debug
+// command. Views are copies, so fn may do I/O without blocking handlers.
+func (w *Wrapper) RangeConversations(fn func(v conv.View) bool) {
+	w.convManager.Range(fn)
+}
+
 // EndConversation terminates an active conversation for a user.
 // This will trigger the OnEnd callback if configured.
 func (w *Wrapper) EndConversation(ctx context.Context, userID, chatID int64) {
 	w.convManager.End(ctx, userID, chatID)
 }
 
+// EndConversationIn terminates an active conversation for a user in a forum topic.
+// The topic is ignored unless topic_conversations is enabled.
+func (w *Wrapper) EndConversationIn(ctx context.Context, userID, chatID int64, topicID int) {
+	w.convManager.EndIn(ctx, userID, chatID, topicID)
+}
+
 // ShowMainMenu displays the main menu to the user.
 // If editMsgID is provided (> 0), the existing message is edited; otherwise, a new message is sent.
 func (w *Wrapper) ShowMainMenu(ctx context.Context, chatID int64, topicID int, editMsgID int) error {
+	evaluator := w.menuEvaluator(ctx, chatID, 0)
 	if editMsgID > 0 {
-		_, err := w.menuManager.EditToMainMenu(ctx, chatID, editMsgID, nil)
+		_, err := w.menuManager.EditToMainMenu(ctx, chatID, editMsgID, evaluator)
 		return err
 	}
-	_, err := w.menuManager.ShowMainMenu(ctx, chatID, topicID, nil)
+	_, err := w.menuManager.ShowMainMenu(ctx, chatID, topicID, evaluator)
 	return err
 }
 
 // ShowMenu displays a specific menu to the user.
 // If editMsgID is provided (> 0), the existing message is edited; otherwise, a new message is sent.
 func (w *Wrapper) ShowMenu(ctx context.Context, chatID int64, topicID int, menuID string, editMsgID int) error {
+	evaluator := w.menuEvaluator(ctx, chatID, 0)
 	if editMsgID > 0 {
-		_, err := w.menuManager.EditToMenu(ctx, chatID, editMsgID, menuID, nil)
+		_, err := w.menuManager.EditToMenu(ctx, chatID, editMsgID, menuID, evaluator)
 		return err
 	}
-	_, err := w.menuManager.ShowMenu(ctx, chatID, topicID, menuID, nil)
+	_, err := w.menuManager.ShowMenu(ctx, chatID, topicID, menuID, evaluator)
 	return err
 }
 
@@ -682,6 +1380,19 @@ func (w *Wrapper) SendToWithKeyboard(ctx context.Context, chatID int64, topicID
 	return w.bot.SendMessageWithKeyboard(ctx, chatID, topicID, text, keyboard, entities...)
 }
 
+// SendLong sends text longer than Telegram's 4096-character limit as several
+// messages, with the keyboard (nil for none) on the last one.
+// Returns the IDs of the sent messages in order.
+func (w *Wrapper) SendLong(ctx context.Context, chatID int64, topicID int, text string, keyboard *telego.InlineKeyboardMarkup, entities ...telego.MessageEntity) ([]int, error) {
+	return w.bot.SendLongMessage(ctx, chatID, topicID, text, keyboard, entities...)
+}
+
+// SendQRCode renders content (a deep link, address, invoice URI, ...) as a QR code
+// and sends it as a photo, with the caption built from the builder (nil for none).
+func (w *Wrapper) SendQRCode(ctx context.Context, chatID int64, topicID int, content string, caption *core.Builder) (*telego.Message, error) {
+	return w.bot.SendQRCode(ctx, chatID, topicID, content, caption)
+}
+
 // EditMessage edits the text of an existing message.
 func (w *Wrapper) EditMessage(ctx context.Context, chatID int64, messageID int, text string, entities ...telego.MessageEntity) (*telego.Message, error) {
 	return w.bot.EditMessage(ctx, chatID, messageID, text, entities...)
@@ -704,15 +1415,15 @@ func (w *Wrapper) AnswerCallback(ctx context.Context, callbackID string, text st
 }
 
 // showStepPrompt displays the prompt for the current conversation step.
-// It builds the keyboard (static and/or dynamic) and either edits the existing
-// keyboard message or sends a new one.
+// It fetches the dynamic buttons if the keyboard needs them and renders the
+// prompt, editing the existing keyboard message or sending a new one.
 //
 // This is an internal method called when:
 // - A new conversation flow starts
 // - The conversation advances to a new step
 // - User navigates back to a previous step
 func (w *Wrapper) showStepPrompt(ctx context.Context, c *conv.Conversation) error {
-	flow := w.config.GetFlow(c.FlowID)
+	flow := w.Config().GetFlow(c.FlowID)
 	if flow == nil {
 		return nil
 	}
@@ -722,42 +1433,142 @@ func (w *Wrapper) showStepPrompt(ctx context.Context, c *conv.Conversation) erro
 		return nil
 	}
 
+	// Steps calling a sub-flow show the sub-flow's first step instead
+	if step.SubFlow != nil {
+		return w.callSubFlow(ctx, c, step)
+	}
+
+	// Start waiting for input, for steps that time out
+	w.armStepTimeout(ctx, c, step)
+
+	// Fetch dynamic button data if required
+	if kbCfg := step.Keyboard; kbCfg != nil && kbCfg.NeedsDynamicData() && kbCfg.Provider != "" {
+		stepID := c.StepID
+		dynamicButtons, err := w.fetchStepButtons(ctx, c, step)
+		if err != nil {
+			return err
+		}
+		// The user may have left the step while a slow provider was loading
+		if c.StepID != stepID {
+			return nil
+		}
+		if err := w.renderStepPrompt(ctx, c, step, dynamicButtons, false); err != nil {
+			return err
+		}
+	} else if err := w.renderStepPrompt(ctx, c, step, nil, false); err != nil {
+		return err
+	}
+
+	// Payment steps send their invoice below the prompt
+	if step.InputType == config.InputTypePayment && step.Payment != nil {
+		w.sendStepInvoice(ctx, c, step)
+	}
+	return nil
+}
+
+// callSubFlow suspends the conversation's flow at a step calling a sub-flow
+// and shows the sub-flow's initial step. The router returns to the calling
+// step once the sub-flow's last step completes.
+func (w *Wrapper) callSubFlow(ctx context.Context, c *conv.Conversation, step *config.StepConfig) error {
+	sub := w.Config().GetFlow(step.SubFlow.FlowID)
+	if sub == nil {
+		return fmt.Errorf("flow %s does not exist", step.SubFlow.FlowID)
+	}
+	if c.CallDepth() >= conv.MaxCallDepth {
+		return fmt.Errorf("sub-flow %s exceeds the maximum call depth of %d", sub.ID, conv.MaxCallDepth)
+	}
+
+	params := make(map[string]interface{}, len(step.SubFlow.Params))
+	for key, value := range step.SubFlow.Params {
+		params[key] = w.flowEngine.RenderText(ctx, c, value)
+	}
+	frame := conv.CallFrame{FlowID: c.FlowID, StepID: c.StepID, Namespace: step.SubFlow.Namespace}
+	c.CallFlow(frame, sub.ID, sub.InitialStep, params)
+	w.recordFlowEvent(ctx, eventlog.TypeFlowStarted, c, "sub_flow")
+	if err := w.convManager.Save(ctx, c); err != nil {
+		return err
+	}
+	return w.showStepPrompt(ctx, c)
+}
+
+// fetchStepButtons gets the dynamic buttons of a step's keyboard. If the provider
+// takes longer than the keyboard's loading_after, the prompt is shown with a
+// loading placeholder in place of the buttons while waiting for them.
+func (w *Wrapper) fetchStepButtons(ctx context.Context, c *conv.Conversation, step *config.StepConfig) ([]config.ButtonData, error) {
+	kbCfg := step.Keyboard
+	if kbCfg.LoadingAfter <= 0 {
+		return w.flowEngine.GetDynamicKeyboardData(ctx, c, kbCfg.Provider), nil
+	}
+
+	result := make(chan []config.ButtonData, 1)
+	go func() {
+		result <- w.flowEngine.GetDynamicKeyboardData(ctx, c, kbCfg.Provider)
+	}()
+	timer := time.NewTimer(kbCfg.LoadingAfter)
+	defer timer.Stop()
+	select {
+	case buttons := <-result:
+		return buttons, nil
+	case <-timer.C:
+	}
+
+	if err := w.renderStepPrompt(ctx, c, step, nil, true); err != nil {
+		return nil, err
+	}
+	return <-result, nil
+}
+
+// renderStepPrompt builds the step keyboard from the static buttons, the
+// dynamic buttons (or a loading placeholder), and the navigation buttons,
+// then either edits the existing keyboard message or sends a new one.
+func (w *Wrapper) renderStepPrompt(ctx context.Context, c *conv.Conversation, step *config.StepConfig, dynamicButtons []config.ButtonData, loading bool) error {
+	// Send the prompt in the step's parse mode; rendered values are escaped for it
+	ctx = core.WithParseMode(ctx, step.ParseMode)
+
 	// Build the keyboard based on step configuration
 	var kb *telego.InlineKeyboardMarkup
-	if step.Keyboard != nil {
+	var emptyState *config.EmptyStateConfig
+	if step.Keyboard != nil && step.Keyboard.IsInline() {
 		kbCfg := step.Keyboard
-
-		// Fetch dynamic button data if required
-		var dynamicButtons []config.ButtonData
-		if kbCfg.NeedsDynamicData() && kbCfg.Provider != "" {
-			provider := w.flowEngine.GetKeyboardProvider(kbCfg.Provider)
-			if provider != nil {
-				dynamicButtons = provider(ctx, c)
-			}
+		if !loading && kbCfg.NeedsDynamicData() && kbCfg.Provider != "" && len(dynamicButtons) == 0 {
+			emptyState = kbCfg.EmptyState
 		}
 
-		// Build the keyboard using the keyboard builder
-		kbBuilder := core.NewKeyboard()
+		// Build the keyboard using the keyboard builder, with the theme's texts
+		strs := core.StringsFrom(ctx)
+		kbBuilder := core.NewKeyboard().WithStrings(strs)
 
-		// Add static buttons from configuration
-		for _, row := range kbCfg.Buttons {
+		// Add static buttons from configuration, then the empty state's buttons
+		rows := kbCfg.Buttons
+		if emptyState != nil {
+			rows = append(slices.Clip(rows), emptyState.Buttons...)
+		}
+		roles := core.RolesFrom(ctx)
+		for _, row := range rows {
 			var buttons []telego.InlineKeyboardButton
 			for _, btn := range row {
-				if btn.URL != "" {
-					buttons = append(buttons, core.URLButton(btn.Text, btn.URL))
-				} else {
-					buttons = append(buttons, core.Button(btn.Text, btn.Callback))
+				if len(btn.Roles) > 0 && !config.HasRole(roles, btn.Roles) {
+					continue
 				}
+				if text, ok := core.Translate(ctx, btn.TextKey); ok {
+					btn.Text = text
+				}
+				buttons = append(buttons, stepButton(btn))
 			}
 			if len(buttons) > 0 {
 				kbBuilder.Row(buttons...)
 			}
 		}
 
-		// Add dynamic buttons in a grid layout
+		// Add dynamic buttons in a grid layout, and the page buttons of
+		// paginated providers in a row below
 		if len(dynamicButtons) > 0 {
-			var buttons []telego.InlineKeyboardButton
+			var buttons, pageButtons []telego.InlineKeyboardButton
 			for _, btn := range dynamicButtons {
+				if _, _, ok := provider.ParsePageCallback(btn.Callback); ok {
+					pageButtons = append(pageButtons, core.Button(btn.Text, btn.Callback))
+					continue
+				}
 				callback := btn.Callback
 				if kbCfg.CallbackPrefix != "" {
 					callback = kbCfg.CallbackPrefix + callback
@@ -765,32 +1576,100 @@ func (w *Wrapper) showStepPrompt(ctx context.Context, c *conv.Conversation) erro
 				buttons = append(buttons, core.Button(btn.Text, callback))
 			}
 			kbBuilder.Grid(buttons, kbCfg.GetColumns())
+			if len(pageButtons) > 0 {
+				kbBuilder.Row(pageButtons...)
+			}
 		}
 
-		// Add navigation buttons (back/main menu)
+		// Hold the place of the dynamic buttons while they load
+		if loading {
+			loadingText := kbCfg.LoadingText
+			if loadingText == "" {
+				loadingText = strs.Loading
+			}
+			kbBuilder.Row(core.Button(loadingText, core.CallbackNoop))
+		}
+
+		// Add navigation buttons (back/main menu/cancel); empty texts use the theme's
 		if kbCfg.AddBack {
-			kbBuilder.Back(kbCfg.GetBackText())
+			kbBuilder.Back(kbCfg.BackText)
 		}
 		if kbCfg.AddMain {
-			kbBuilder.MainMenu(kbCfg.GetMainText())
+			kbBuilder.MainMenu(kbCfg.MainText)
+		}
+		if kbCfg.AddCancel {
+			kbBuilder.Cancel(kbCfg.CancelText)
 		}
 
 		kb = kbBuilder.Build()
 	}
 
+	// Resolve the prompt text in the user's language with any tenant override
+	// for this chat, pick a retry or chat type variant, and render templates
+	prompt := step.PromptText
+	if translated, ok := core.Translate(ctx, step.TextKey); ok {
+		prompt = translated
+	}
+	text := w.Config().ResolveText(c.ChatID, config.StepTextKey(c.FlowID, c.StepID), prompt)
+	if variant, ok := w.flowEngine.PromptVariant(c); ok {
+		text = variant
+	}
+	if emptyState != nil && emptyState.Text != "" {
+		text = emptyState.Text
+	}
+	text = w.flowEngine.RenderText(ctx, c, text)
+
+	// Show the latest response of an LLM step in place of its prompt, unrendered
+	if response, ok := w.flowEngine.LLMResponse(c); ok {
+		text = core.Escape(step.ParseMode, response)
+	}
+
+	// Append a validation error shown inline on the prompt
+	if errText := c.GetErrorText(); errText != "" {
+		text += "\n\n" + core.Escape(step.ParseMode, errText)
+	}
+
+	// Reply keyboards go on a new message; an inline step removes the one shown before
+	if step.Keyboard != nil && !step.Keyboard.IsInline() {
+		return w.sendReplyPrompt(ctx, c, step, text)
+	}
+	w.clearReplyKeyboard(ctx, c)
+
 	// Edit existing keyboard message or send new one
+	c.SetKeyboardText(text)
 	if c.KeyboardMsgID > 0 {
-		_, err := w.bot.EditMessageWithKeyboard(ctx, c.ChatID, c.KeyboardMsgID, step.PromptText, kb)
+		_, err := w.bot.EditMessageWithKeyboard(ctx, c.ChatID, c.KeyboardMsgID, text, kb)
+		w.bindStepKeyboard(ctx, c, step)
+		w.pinStepPrompt(ctx, c, step)
 		return err
 	}
 
 	// Send a new message with the step prompt
-	msg, err := w.bot.SendMessageWithKeyboard(ctx, c.ChatID, c.TopicID, step.PromptText, kb)
+	msg, err := w.bot.SendMessageWithKeyboard(ctx, c.ChatID, c.TopicID, text, kb)
 	if err != nil {
 		return err
 	}
 	if msg != nil {
 		c.SetKeyboardMsgID(msg.MessageID)
+		w.bindStepKeyboard(ctx, c, step)
+		w.pinStepPrompt(ctx, c, step)
+	}
+	return w.convManager.Save(ctx, c)
+}
+
+// stepButton creates a step keyboard button from configuration.
+// Flow and menu buttons use the built-in "flow:" and "menu:" callbacks.
+func stepButton(btn config.ButtonConfig) telego.InlineKeyboardButton {
+	switch {
+	case btn.URL != "":
+		return core.URLButton(btn.Text, btn.URL)
+	case btn.WebApp != "":
+		return core.WebAppButton(btn.Text, btn.WebApp)
+	case btn.FlowID != "":
+		return core.Button(btn.Text, "flow:"+btn.FlowID)
+	case btn.MenuID != "":
+		return core.Button(btn.Text, "menu:"+btn.MenuID)
+	default:
+		return core.Button(btn.Text, btn.Callback)
 	}
-	return nil
 }
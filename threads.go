@@ -0,0 +1,71 @@
+package tgwrapper
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/mymmrac/telego"
+
+	"github.com/0xVanfer/tg-listener/conv"
+	"github.com/0xVanfer/tg-listener/core"
+)
+
+// ConversationThread returns the thread key for a conversation's messages.
+// Threads keyed this way are forgotten when the conversation ends.
+func ConversationThread(c *conv.Conversation) string {
+	return fmt.Sprintf("conv:%d:%d", c.UserID, c.ChatID)
+}
+
+// Threads returns the tracker of the bot's messages per logical thread.
+func (w *Wrapper) Threads() *core.ThreadTracker {
+	return w.threads
+}
+
+// ReplyInThread sends a message into a logical thread. The message replies to
+// the thread's latest message in the same chat, if any, and becomes the thread's
+// latest message itself.
+func (w *Wrapper) ReplyInThread(ctx context.Context, thread string, chatID int64, topicID int, text string, entities ...telego.MessageEntity) (*telego.Message, error) {
+	var msg *telego.Message
+	var err error
+	if last, ok := w.threads.Last(thread); ok && last.ChatID == chatID {
+		msg, err = w.bot.SendReply(ctx, chatID, topicID, last.MessageID, text, entities...)
+	} else {
+		msg, err = w.bot.SendMessage(ctx, chatID, topicID, text, entities...)
+	}
+	if err != nil || msg == nil {
+		return msg, err
+	}
+	w.threads.Track(thread, chatID, msg.MessageID)
+	return msg, nil
+}
+
+// TrackInThread adds an already sent message to a logical thread,
+// e.g. a photo or keyboard message sent outside ReplyInThread.
+func (w *Wrapper) TrackInThread(thread string, msg *telego.Message) {
+	if msg == nil {
+		return
+	}
+	w.threads.Track(thread, msg.Chat.ID, msg.MessageID)
+}
+
+// CollapseThread deletes all but the last keep messages of a thread, keeping
+// group chats tidy after multi-message interactions. Messages are deleted in
+// batches per chat; the first deletion error is returned.
+func (w *Wrapper) CollapseThread(ctx context.Context, thread string, keep int) error {
+	byChat := make(map[int64][]int)
+	var chats []int64
+	for _, m := range w.threads.Trim(thread, keep) {
+		if _, ok := byChat[m.ChatID]; !ok {
+			chats = append(chats, m.ChatID)
+		}
+		byChat[m.ChatID] = append(byChat[m.ChatID], m.MessageID)
+	}
+
+	var firstErr error
+	for _, chatID := range chats {
+		if err := w.bot.DeleteMessages(ctx, chatID, byChat[chatID]); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
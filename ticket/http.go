@@ -0,0 +1,94 @@
+package ticket
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// DefaultTimeout is the default timeout of requests of HTTP sinks.
+const DefaultTimeout = 15 * time.Second
+
+// HTTPSink posts tickets as JSON to an HTTP endpoint, e.g. a ticketing
+// system's API or a small service mapping them to its format.
+type HTTPSink struct {
+	URL     string            // Endpoint tickets are posted to
+	Headers map[string]string // Request headers, e.g. Authorization
+	IDField string            // Dot-separated path of the ticket ID in the JSON response, e.g. "ticket.id"; defaults to "id"
+	Client  *http.Client      // Client sending requests; defaults to one with DefaultTimeout
+
+	// Encode builds the request body from a ticket, to match the payload the
+	// endpoint expects. Defaults to the JSON of the ticket.
+	Encode func(t *Ticket) ([]byte, error)
+}
+
+// Create posts a ticket and returns the ID found in the response.
+// Responses with a status outside 2xx are errors.
+func (s *HTTPSink) Create(ctx context.Context, t *Ticket) (string, error) {
+	encode := s.Encode
+	if encode == nil {
+		encode = func(t *Ticket) ([]byte, error) { return json.Marshal(t) }
+	}
+	body, err := encode(t)
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.URL, bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range s.Headers {
+		req.Header.Set(k, v)
+	}
+
+	client := s.Client
+	if client == nil {
+		client = &http.Client{Timeout: DefaultTimeout}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return "", fmt.Errorf("ticket endpoint returned %s: %s", resp.Status, bytes.TrimSpace(data))
+	}
+	return responseID(data, s.IDField), nil
+}
+
+// responseID returns the value at a dot-separated path of a JSON response,
+// or "" if there is none.
+func responseID(data []byte, path string) string {
+	if path == "" {
+		path = "id"
+	}
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return ""
+	}
+	for _, key := range strings.Split(path, ".") {
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return ""
+		}
+		v = m[key]
+	}
+	switch v := v.(type) {
+	case string:
+		return v
+	case float64:
+		return fmt.Sprintf("%.0f", v)
+	}
+	return ""
+}
@@ -0,0 +1,64 @@
+// Package ticket files conversations as tickets in ticketing systems such as
+// Zendesk or Jira. A ticket carries the conversation's transcript and data;
+// sinks deliver it, either through the generic HTTP/JSON adapter or any
+// implementation of Sink.
+package ticket
+
+import (
+	"context"
+	"time"
+)
+
+// Outcomes that file tickets. OutcomeEscalated is set on tickets filed
+// explicitly while the conversation runs; the others are the ways a
+// conversation ends.
+const (
+	OutcomeCompleted = "completed" // The flow completed
+	OutcomeCancelled = "cancelled" // The user cancelled the conversation
+	OutcomeExpired   = "expired"   // The conversation expired
+	OutcomeEnded     = "ended"     // The conversation ended otherwise, e.g. replaced by another
+	OutcomeEscalated = "escalated" // A handler escalated the conversation
+)
+
+// Outcomes lists the outcomes that end conversations.
+var Outcomes = []string{OutcomeCompleted, OutcomeCancelled, OutcomeExpired, OutcomeEnded}
+
+// Ticket is a conversation filed in a ticketing system.
+type Ticket struct {
+	Subject    string                 `json:"subject"`
+	FlowID     string                 `json:"flow_id"`
+	Outcome    string                 `json:"outcome"`          // See the Outcome constants
+	Reason     string                 `json:"reason,omitempty"` // Why the conversation was escalated
+	Tags       []string               `json:"tags,omitempty"`
+	UserID     int64                  `json:"user_id"`
+	Username   string                 `json:"username,omitempty"`  // Without the leading @
+	FullName   string                 `json:"full_name,omitempty"` // First and last name
+	ChatID     int64                  `json:"chat_id"`
+	TopicID    int                    `json:"topic_id,omitempty"`
+	Transcript []Entry                `json:"transcript"` // Inputs of the conversation, oldest first
+	Data       map[string]interface{} `json:"data,omitempty"`
+	StartedAt  time.Time              `json:"started_at"`
+	CreatedAt  time.Time              `json:"created_at"`
+}
+
+// Entry is an input of a conversation's transcript.
+type Entry struct {
+	StepID string    `json:"step_id"`
+	Input  string    `json:"input"`
+	Output string    `json:"output,omitempty"` // Response shown for the input, e.g. by LLM steps
+	At     time.Time `json:"at"`
+}
+
+// Sink creates tickets in a ticketing system. Create returns the ID of the
+// created ticket, or "" if the system doesn't report one.
+type Sink interface {
+	Create(ctx context.Context, t *Ticket) (string, error)
+}
+
+// SinkFunc adapts a function to a Sink.
+type SinkFunc func(ctx context.Context, t *Ticket) (string, error)
+
+// Create calls f.
+func (f SinkFunc) Create(ctx context.Context, t *Ticket) (string, error) {
+	return f(ctx, t)
+}
@@ -0,0 +1,169 @@
+package tgwrapper
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/0xVanfer/tg-listener/alert"
+	"github.com/0xVanfer/tg-listener/config"
+	"github.com/0xVanfer/tg-listener/conv"
+	"github.com/0xVanfer/tg-listener/core"
+	"github.com/0xVanfer/tg-listener/ticket"
+)
+
+// ticketSinks holds the ticket sinks registered from code.
+type ticketSinks struct {
+	sinks map[string]ticket.Sink // Sinks by name
+	mu    sync.RWMutex           // Mutex for thread-safe operations
+}
+
+// RegisterTicketSink registers a sink tickets are created in, e.g. an adapter
+// of a ticketing system's client. Flows name it in ticket.sink; the sink
+// named "default" serves flows that don't name one. Takes precedence over a
+// sink of the same name in bot.ticket_sinks.
+//
+// Example:
+//
+//	wrapper.RegisterTicketSink("default", ticket.SinkFunc(func(ctx context.Context, t *ticket.Ticket) (string, error) {
+//	    issue, err := jira.CreateIssue(ctx, t.Subject, formatTranscript(t))
+//	    return issue.Key, err
+//	}))
+func (w *Wrapper) RegisterTicketSink(name string, sink ticket.Sink) {
+	w.tickets.mu.Lock()
+	defer w.tickets.mu.Unlock()
+	if w.tickets.sinks == nil {
+		w.tickets.sinks = make(map[string]ticket.Sink)
+	}
+	w.tickets.sinks[name] = sink
+}
+
+// ticketSink returns the sink of a name, registered from code or configured
+// in bot.ticket_sinks, or nil if there is none.
+func (w *Wrapper) ticketSink(name string) ticket.Sink {
+	w.tickets.mu.RLock()
+	sink := w.tickets.sinks[name]
+	w.tickets.mu.RUnlock()
+	if sink != nil {
+		return sink
+	}
+	cfg := w.Config().Bot.TicketSinks[name]
+	if !cfg.Valid() {
+		return nil
+	}
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = ticket.DefaultTimeout
+	}
+	return &ticket.HTTPSink{URL: cfg.URL, Headers: cfg.Headers, IDField: cfg.IDField, Client: &http.Client{Timeout: timeout}}
+}
+
+// Escalate files a conversation as a ticket right away, e.g. when a support
+// flow hands the user over to a human, in the sink of the flow's ticket
+// configuration or the default sink. The ticket ID is stored as "ticket_id"
+// in the conversation's data and returned.
+func (w *Wrapper) Escalate(ctx context.Context, c *conv.Conversation, reason string) (string, error) {
+	flow := w.Config().ResolveFlow(c.ChatID, c.FlowID)
+	var cfg *config.TicketConfig
+	if flow != nil {
+		cfg = flow.Ticket
+	}
+	sink := w.ticketSink(cfg.GetSink())
+	if sink == nil {
+		return "", fmt.Errorf("ticket sink %q not found", cfg.GetSink())
+	}
+	t := w.newTicket(ctx, c, flow, ticket.OutcomeEscalated)
+	t.Reason = reason
+	id, err := sink.Create(ctx, t)
+	if err != nil {
+		return "", err
+	}
+	if id != "" {
+		c.Set("ticket_id", id)
+	}
+	return id, nil
+}
+
+// fileConversationTicket files an ended conversation as a ticket in the
+// background if its flow files tickets on its outcome. Failures are logged
+// and sent to the warning chat.
+func (w *Wrapper) fileConversationTicket(ctx context.Context, c *conv.Conversation) {
+	flow := w.Config().ResolveFlow(c.ChatID, c.FlowID)
+	outcome := conversationOutcome(c)
+	if flow == nil || !flow.Ticket.Files(outcome) {
+		return
+	}
+	name := flow.Ticket.GetSink()
+	sink := w.ticketSink(name)
+	if sink == nil {
+		log.Printf("[Ticket] Sink %q of flow %s not found", name, flow.ID)
+		return
+	}
+
+	t := w.newTicket(ctx, c, flow, outcome)
+	ctx = context.WithoutCancel(ctx)
+	go func() {
+		if _, err := sink.Create(ctx, t); err != nil {
+			w.ticketFailed(ctx, t, err)
+		}
+	}()
+}
+
+// newTicket builds the ticket of a conversation.
+func (w *Wrapper) newTicket(ctx context.Context, c *conv.Conversation, flow *config.FlowConfig, outcome string) *ticket.Ticket {
+	t := &ticket.Ticket{
+		Subject:   c.FlowID,
+		FlowID:    c.FlowID,
+		Outcome:   outcome,
+		UserID:    c.UserID,
+		ChatID:    c.ChatID,
+		TopicID:   c.TopicID,
+		Data:      c.CopyData(),
+		StartedAt: c.CreatedAt,
+		CreatedAt: time.Now(),
+	}
+	if flow != nil {
+		if flow.Name != "" {
+			t.Subject = flow.Name
+		}
+		if cfg := flow.Ticket; cfg != nil {
+			if cfg.Subject != "" {
+				t.Subject = w.flowEngine.RenderText(core.WithParseMode(ctx, ""), c, cfg.Subject)
+			}
+			t.Tags = append(t.Tags, cfg.Tags...)
+		}
+	}
+	for _, h := range c.Transcript() {
+		t.Transcript = append(t.Transcript, ticket.Entry{StepID: h.StepID, Input: h.Input, Output: h.Output, At: h.Timestamp})
+	}
+	if u, err := w.Users().Get(ctx, c.UserID); err == nil && u != nil {
+		t.Username = u.Username
+		t.FullName = strings.TrimSpace(u.FirstName + " " + u.LastName)
+	}
+	return t
+}
+
+// ticketFailed logs a ticket that couldn't be created and reports it to the warning chat.
+func (w *Wrapper) ticketFailed(ctx context.Context, t *ticket.Ticket, err error) {
+	log.Printf("[Ticket] Failed to file %s conversation of user %d in flow %s: %v", t.Outcome, t.UserID, t.FlowID, err)
+
+	chat := w.warningChat(alert.LevelWarning)
+	if chat == nil {
+		return
+	}
+	text, entities := core.NewBuilder().
+		Text("🎫 Failed to file a ticket").Ln().
+		KeyValueCode("Flow", t.FlowID).
+		KeyValueCode("User", strconv.FormatInt(t.UserID, 10)).
+		Text(err.Error()).
+		Build()
+	ctx = context.WithValue(ctx, logCtxKey{}, true)
+	if _, err := w.bot.SendMessage(ctx, chat.ChatID, chat.TopicID, text, entities...); err != nil {
+		log.Printf("[Ticket] Failed to send ticket warning: %v", err)
+	}
+}
@@ -0,0 +1,203 @@
+package tgwrapper
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/mymmrac/telego"
+
+	"github.com/0xVanfer/tg-listener/config"
+	"github.com/0xVanfer/tg-listener/conv"
+	"github.com/0xVanfer/tg-listener/tz"
+)
+
+// TimezoneFlowID is the ID of the built-in time zone flow.
+// The flow is installed when a timezone section is configured; other flows can
+// ask for the user's time zone with a sub_flow step calling it, and read the
+// chosen zone name from {{.timezone}}.
+const TimezoneFlowID = "_timezone"
+
+// Names of the time zone flow's internal handlers, providers, and validators.
+const (
+	timezoneRegions   = "_timezoneRegions"
+	timezoneZones     = "_timezoneZones"
+	timezoneInput     = "_timezoneInput"
+	timezonePick      = "_timezonePick"
+	timezoneRegionPfx = "region:"
+)
+
+// errTimezoneInput is shown when the time zone flow receives an unknown zone.
+var errTimezoneInput = errors.New("Please pick a region, or enter a time zone such as Europe/Berlin, an offset such as UTC+3, or your local time such as 14:30")
+
+// timezoneFlow returns the configuration of the built-in time zone flow.
+func timezoneFlow() *config.FlowConfig {
+	return &config.FlowConfig{
+		ID:          TimezoneFlowID,
+		Name:        "Time Zone",
+		InitialStep: "region",
+		TTL:         15 * time.Minute,
+		Steps: map[string]*config.StepConfig{
+			"region": {
+				ID:         "region",
+				PromptText: "🌍 Where are you?\n\nPick your region, or type your time zone (Europe/Berlin), UTC offset (UTC+3), or current local time (14:30).",
+				Keyboard: &config.KeyboardConfig{
+					Type:     config.KeyboardTypeDynamic,
+					Provider: timezoneRegions,
+					Columns:  2,
+					AddMain:  true,
+				},
+				InputType:  config.InputTypeAny,
+				Validation: &config.ValidationConfig{Type: "custom", Custom: timezoneInput},
+				StoreAs:    "timezone",
+				OnComplete: timezonePick,
+			},
+			"zone": {
+				ID:         "zone",
+				PromptText: "🕐 Pick your time zone:",
+				Keyboard: &config.KeyboardConfig{
+					Type:     config.KeyboardTypeDynamic,
+					Provider: timezoneZones,
+					Columns:  2,
+					AddMain:  true,
+				},
+				InputType:  config.InputTypeAny,
+				Validation: &config.ValidationConfig{Type: "custom", Custom: timezoneInput},
+				StoreAs:    "timezone",
+				OnComplete: timezonePick,
+			},
+		},
+	}
+}
+
+// UserLocation returns a user's time zone: the one they chose, the configured
+// default, or UTC.
+func (w *Wrapper) UserLocation(ctx context.Context, userID int64) *time.Location {
+	if u, err := w.Users().Get(ctx, userID); err == nil {
+		if loc := u.Location(); loc != nil {
+			return loc
+		}
+	}
+	if w.Config().Timezone != nil && w.Config().Timezone.Default != "" {
+		if loc, err := tz.Load(w.Config().Timezone.Default); err == nil {
+			return loc
+		}
+	}
+	return time.UTC
+}
+
+// SetUserTimezone stores a user's time zone, given as an IANA name or a UTC
+// offset as accepted by tz.Load. An empty name reverts to the default.
+func (w *Wrapper) SetUserTimezone(ctx context.Context, userID int64, name string) error {
+	return w.Users().SetTimezone(ctx, userID, name)
+}
+
+// FormatUserTime formats a time in a user's time zone, e.g. "Mar 3 14:30 CET".
+func (w *Wrapper) FormatUserTime(ctx context.Context, userID int64, t time.Time) string {
+	return tz.Format(t, w.UserLocation(ctx, userID))
+}
+
+// resolveTimezone resolves a typed time zone: an IANA name, a UTC offset, or
+// the user's current local time.
+func resolveTimezone(input string, now time.Time) (*time.Location, error) {
+	if loc, err := tz.Load(input); err == nil {
+		return loc, nil
+	}
+	return tz.Detect(input, now)
+}
+
+// timezoneRegionButtons lists the picker's regions.
+func (w *Wrapper) timezoneRegionButtons(_ context.Context, _ *conv.Conversation) []config.ButtonData {
+	buttons := make([]config.ButtonData, 0, len(tz.Regions))
+	for _, r := range tz.Regions {
+		buttons = append(buttons, config.ButtonData{Text: r.Name, Callback: timezoneRegionPfx + r.Name})
+	}
+	return buttons
+}
+
+// timezoneZoneButtons lists the zones of the chosen region with their current offsets.
+func (w *Wrapper) timezoneZoneButtons(_ context.Context, c *conv.Conversation) []config.ButtonData {
+	var buttons []config.ButtonData
+	now := time.Now()
+	if region := tz.FindRegion(c.GetString("region")); region != nil {
+		for _, name := range region.Zones {
+			loc, err := tz.Load(name)
+			if err != nil {
+				continue
+			}
+			buttons = append(buttons, config.ButtonData{Text: tz.Label(loc, now), Callback: name})
+		}
+	}
+	return append(buttons, config.ButtonData{Text: "⬅️ Regions", Callback: timezoneRegionPfx})
+}
+
+// timezonePickStep moves between the picker's steps, or stores the chosen zone.
+// Started on its own, the flow ends with a confirmation; called as a sub-flow,
+// it returns to the calling flow.
+func (w *Wrapper) timezonePickStep(ctx context.Context, c *conv.Conversation) error {
+	input := c.GetString("timezone")
+	if region, ok := strings.CutPrefix(input, timezoneRegionPfx); ok {
+		next := "zone"
+		if region == "" {
+			next = "region"
+		}
+		c.Set("region", region)
+		w.convManager.ChangeStepIn(ctx, c.UserID, c.ChatID, c.TopicID, next)
+		return w.showStepPrompt(ctx, c)
+	}
+
+	now := time.Now()
+	loc, err := resolveTimezone(input, now)
+	if err != nil {
+		return err
+	}
+	if err := w.SetUserTimezone(ctx, c.UserID, loc.String()); err != nil {
+		return err
+	}
+	c.Set("timezone", loc.String())
+	if c.CallDepth() > 0 {
+		return nil
+	}
+
+	chatID, topicID, msgID := c.ChatID, c.TopicID, c.KeyboardMsgID
+	w.EndConversationIn(ctx, c.UserID, chatID, topicID)
+	text := "✅ Your time zone is " + tz.Label(loc, now) + ". Local time: " + now.In(loc).Format("15:04")
+	if msgID > 0 {
+		_, err = w.bot.EditMessage(ctx, chatID, msgID, text)
+		return err
+	}
+	_, err = w.bot.SendMessage(ctx, chatID, topicID, text)
+	return err
+}
+
+// setupTimezones registers the time zone flow's handlers, providers, and
+// validator, and the time zone command when enabled.
+func (w *Wrapper) setupTimezones() {
+	w.flowEngine.RegisterValidator(timezoneInput, func(value string, _ *conv.Conversation) error {
+		if strings.HasPrefix(value, timezoneRegionPfx) {
+			return nil
+		}
+		if _, err := resolveTimezone(value, time.Now()); err != nil {
+			return errTimezoneInput
+		}
+		return nil
+	})
+	w.flowEngine.RegisterKeyboardProvider(timezoneRegions, w.timezoneRegionButtons)
+	w.flowEngine.RegisterKeyboardProvider(timezoneZones, w.timezoneZoneButtons)
+	w.flowEngine.RegisterStepHandler(timezonePick, w.timezonePickStep)
+
+	if w.Config().Timezone == nil || !w.Config().Timezone.Enabled {
+		return
+	}
+	w.router.RegisterCommand(w.Config().Timezone.GetCommand(), func(ctx context.Context, msg telego.Message) error {
+		c, err := w.StartConversation(ctx, msg.From.ID, msg.Chat.ID, msg.MessageThreadID, TimezoneFlowID, 0)
+		if w.notifyFlowDenied(ctx, err, msg.Chat.ID, msg.MessageThreadID, "") {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		return w.showStepPrompt(ctx, c)
+	})
+}
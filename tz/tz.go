@@ -0,0 +1,197 @@
+// Package tz resolves and formats user time zones: IANA names, UTC offsets,
+// and offsets detected from a user's current local time.
+package tz
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	// Embed the time zone database so zones resolve on hosts without one
+	_ "time/tzdata"
+)
+
+// Layout is the default layout of times formatted for users.
+const Layout = "Jan 2 15:04 MST"
+
+// ErrUnknownZone is returned when a time zone cannot be resolved.
+var ErrUnknownZone = errors.New("unknown time zone")
+
+// Region groups time zones for pickers.
+type Region struct {
+	Name  string   // Display name of the region
+	Zones []string // IANA zone names, west to east
+}
+
+// Regions are the regions and zones offered by time zone pickers.
+// Users in other zones can enter the zone name or a UTC offset.
+var Regions = []Region{
+	{Name: "North America", Zones: []string{
+		"America/Anchorage", "America/Los_Angeles", "America/Denver", "America/Phoenix",
+		"America/Chicago", "America/Mexico_City", "America/New_York", "America/Toronto", "America/Halifax",
+	}},
+	{Name: "South America", Zones: []string{
+		"America/Bogota", "America/Lima", "America/Caracas", "America/Santiago", "America/Sao_Paulo", "America/Argentina/Buenos_Aires",
+	}},
+	{Name: "Europe", Zones: []string{
+		"Europe/Lisbon", "Europe/London", "Europe/Madrid", "Europe/Paris", "Europe/Berlin", "Europe/Rome",
+		"Europe/Warsaw", "Europe/Kyiv", "Europe/Athens", "Europe/Istanbul", "Europe/Moscow",
+	}},
+	{Name: "Africa", Zones: []string{
+		"Africa/Casablanca", "Africa/Lagos", "Africa/Cairo", "Africa/Johannesburg", "Africa/Nairobi",
+	}},
+	{Name: "Asia", Zones: []string{
+		"Asia/Dubai", "Asia/Tehran", "Asia/Karachi", "Asia/Kolkata", "Asia/Dhaka", "Asia/Bangkok",
+		"Asia/Jakarta", "Asia/Singapore", "Asia/Hong_Kong", "Asia/Shanghai", "Asia/Seoul", "Asia/Tokyo",
+	}},
+	{Name: "Oceania", Zones: []string{
+		"Australia/Perth", "Australia/Adelaide", "Australia/Brisbane", "Australia/Sydney", "Pacific/Auckland", "Pacific/Honolulu",
+	}},
+}
+
+// FindRegion returns the region with the given name, or nil if none matches.
+func FindRegion(name string) *Region {
+	for i := range Regions {
+		if strings.EqualFold(Regions[i].Name, name) {
+			return &Regions[i]
+		}
+	}
+	return nil
+}
+
+// Load resolves a time zone given as an IANA name ("Europe/Berlin") or as a
+// UTC offset ("UTC+3", "GMT-04:30", "+0530"). Offsets become fixed zones named
+// like "UTC+03:00". Returns ErrUnknownZone if the zone cannot be resolved.
+func Load(name string) (*time.Location, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, ErrUnknownZone
+	}
+	if offset, ok := parseOffset(name); ok {
+		return Fixed(offset), nil
+	}
+	if strings.EqualFold(name, "UTC") || strings.EqualFold(name, "GMT") || strings.EqualFold(name, "Z") {
+		return time.UTC, nil
+	}
+	// time.LoadLocation also accepts "Local", which depends on the host
+	if name == "Local" {
+		return nil, ErrUnknownZone
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, ErrUnknownZone
+	}
+	return loc, nil
+}
+
+// Fixed returns a fixed zone with the given offset from UTC, named like "UTC+05:30".
+func Fixed(offset time.Duration) *time.Location {
+	if offset == 0 {
+		return time.UTC
+	}
+	return time.FixedZone(formatOffset(offset), int(offset/time.Second))
+}
+
+// parseOffset parses a UTC offset such as "UTC+3", "GMT-04:30", "+05:30", or "+0530".
+// Offsets must be within ±14 hours.
+func parseOffset(s string) (time.Duration, bool) {
+	upper := strings.ToUpper(s)
+	for _, prefix := range []string{"UTC", "GMT"} {
+		upper = strings.TrimPrefix(upper, prefix)
+	}
+	upper = strings.TrimSpace(upper)
+	if upper == "" || (upper[0] != '+' && upper[0] != '-') {
+		return 0, false
+	}
+	sign := time.Duration(1)
+	if upper[0] == '-' {
+		sign = -1
+	}
+	rest := upper[1:]
+
+	hours, minutes := rest, "0"
+	if h, m, ok := strings.Cut(rest, ":"); ok {
+		hours, minutes = h, m
+	} else if len(rest) == 4 {
+		hours, minutes = rest[:2], rest[2:]
+	}
+	h, err := strconv.Atoi(hours)
+	if err != nil || h < 0 || h > 14 {
+		return 0, false
+	}
+	m, err := strconv.Atoi(minutes)
+	if err != nil || m < 0 || m >= 60 || (h == 14 && m > 0) {
+		return 0, false
+	}
+	return sign * (time.Duration(h)*time.Hour + time.Duration(m)*time.Minute), true
+}
+
+// formatOffset formats an offset from UTC as "UTC+05:30".
+func formatOffset(offset time.Duration) string {
+	sign := "+"
+	if offset < 0 {
+		sign = "-"
+		offset = -offset
+	}
+	return fmt.Sprintf("UTC%s%02d:%02d", sign, int(offset/time.Hour), int(offset%time.Hour/time.Minute))
+}
+
+// Detect returns a fixed zone from a user's current local time, entered as
+// "15:04" or "3:04pm", by comparing it with now. The offset is rounded to the
+// nearest 15 minutes, which covers every zone in use.
+func Detect(local string, now time.Time) (*time.Location, error) {
+	local = strings.ToLower(strings.ReplaceAll(strings.TrimSpace(local), " ", ""))
+	var t time.Time
+	var err error
+	for _, layout := range []string{"15:04", "3:04pm", "3pm"} {
+		if t, err = time.Parse(layout, local); err == nil {
+			break
+		}
+	}
+	if err != nil {
+		return nil, ErrUnknownZone
+	}
+
+	utc := now.UTC()
+	offset := time.Duration(t.Hour()-utc.Hour())*time.Hour + time.Duration(t.Minute()-utc.Minute())*time.Minute
+	// Local dates may differ from UTC by a day
+	for offset > 14*time.Hour {
+		offset -= 24 * time.Hour
+	}
+	for offset < -12*time.Hour {
+		offset += 24 * time.Hour
+	}
+	offset = offset.Round(15 * time.Minute)
+	return Fixed(offset), nil
+}
+
+// Offset returns the offset of a zone from UTC at the given time, formatted as "UTC+01:00".
+func Offset(loc *time.Location, at time.Time) string {
+	_, seconds := at.In(loc).Zone()
+	if seconds == 0 {
+		return "UTC"
+	}
+	return formatOffset(time.Duration(seconds) * time.Second)
+}
+
+// Label returns a short display name for a zone with its current offset,
+// e.g. "Berlin (UTC+01:00)" or "UTC+05:30".
+func Label(loc *time.Location, at time.Time) string {
+	name := loc.String()
+	offset := Offset(loc, at)
+	if name == offset || name == "UTC" {
+		return offset
+	}
+	city := name[strings.LastIndex(name, "/")+1:]
+	return strings.ReplaceAll(city, "_", " ") + " (" + offset + ")"
+}
+
+// Format formats a time in a zone with Layout. A nil zone is UTC.
+func Format(t time.Time, loc *time.Location) string {
+	if loc == nil {
+		loc = time.UTC
+	}
+	return t.In(loc).Format(Layout)
+}
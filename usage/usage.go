@@ -0,0 +1,179 @@
+// Package usage counts command uses per user in hourly buckets persisted in a
+// store, for usage statistics and leaderboards. Buckets are pruned after a
+// retention period, so counts over any recent time range stay cheap to sum.
+package usage
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/0xVanfer/tg-listener/store"
+)
+
+// keyPrefix is the store key prefix for usage buckets.
+const keyPrefix = "usage:"
+
+// hourLayout formats the UTC hour a bucket belongs to.
+const hourLayout = "2006-01-02T15"
+
+// bucket is the persisted usage of one UTC hour.
+type bucket struct {
+	Commands map[string]map[int64]int `json:"commands"` // Uses by command and user
+}
+
+// CommandCount is the usage of a command.
+type CommandCount struct {
+	Command string `json:"command"`
+	Uses    int    `json:"uses"`  // Number of times the command was used
+	Users   int    `json:"users"` // Number of distinct users who used it
+}
+
+// UserCount is the usage of a user across commands.
+type UserCount struct {
+	UserID int64 `json:"user_id"`
+	Uses   int   `json:"uses"` // Number of commands the user sent
+}
+
+// Stats is the usage over a time range.
+type Stats struct {
+	Since    time.Time      `json:"since"`    // Start of the range, truncated to the hour
+	Uses     int            `json:"uses"`     // Total number of command uses
+	Commands []CommandCount `json:"commands"` // Commands, the most used first
+	Users    []UserCount    `json:"users"`    // Users, the most active first
+}
+
+// TopCommands returns the n most used commands, or all if n is 0.
+func (s Stats) TopCommands(n int) []CommandCount {
+	if n > 0 && len(s.Commands) > n {
+		return s.Commands[:n]
+	}
+	return s.Commands
+}
+
+// TopUsers returns the n most active users, or all if n is 0.
+func (s Stats) TopUsers(n int) []UserCount {
+	if n > 0 && len(s.Users) > n {
+		return s.Users[:n]
+	}
+	return s.Users
+}
+
+// Tracker records command uses in a store and prunes them after the retention period.
+type Tracker struct {
+	store     store.Store   // Backing store
+	retention time.Duration // Age after which buckets are pruned
+	mu        sync.Mutex    // Serializes read-modify-write cycles of buckets
+}
+
+// NewTracker creates a usage tracker persisted in the given store, keeping
+// counts for the retention period.
+func NewTracker(s store.Store, retention time.Duration) *Tracker {
+	return &Tracker{store: s, retention: retention}
+}
+
+// bucketKey returns the store key of the bucket for a time.
+func bucketKey(t time.Time) string {
+	return keyPrefix + t.UTC().Format(hourLayout)
+}
+
+// Record counts a use of a command, without the leading slash, by a user.
+func (t *Tracker) Record(ctx context.Context, command string, userID int64) error {
+	command = strings.TrimPrefix(command, "/")
+	key := bucketKey(time.Now())
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var b bucket
+	if err := store.GetJSON(ctx, t.store, key, &b); err != nil && !errors.Is(err, store.ErrNotFound) {
+		return err
+	}
+	if b.Commands == nil {
+		b.Commands = make(map[string]map[int64]int)
+	}
+	if b.Commands[command] == nil {
+		b.Commands[command] = make(map[int64]int)
+	}
+	b.Commands[command][userID]++
+	return store.PutJSON(ctx, t.store, key, b)
+}
+
+// Stats sums the usage since a time, which is truncated to the hour.
+func (t *Tracker) Stats(ctx context.Context, since time.Time) (Stats, error) {
+	stats := Stats{Since: since.UTC().Truncate(time.Hour)}
+	keys, err := t.store.List(ctx, keyPrefix)
+	if err != nil {
+		return stats, err
+	}
+
+	uses := make(map[string]int)
+	users := make(map[string]map[int64]bool)
+	byUser := make(map[int64]int)
+	first := bucketKey(stats.Since)
+	for _, key := range keys {
+		if key < first {
+			continue
+		}
+		var b bucket
+		if err := store.GetJSON(ctx, t.store, key, &b); err != nil {
+			if errors.Is(err, store.ErrNotFound) {
+				continue
+			}
+			return stats, err
+		}
+		for command, counts := range b.Commands {
+			if users[command] == nil {
+				users[command] = make(map[int64]bool)
+			}
+			for userID, n := range counts {
+				uses[command] += n
+				users[command][userID] = true
+				byUser[userID] += n
+				stats.Uses += n
+			}
+		}
+	}
+
+	for command, n := range uses {
+		stats.Commands = append(stats.Commands, CommandCount{Command: command, Uses: n, Users: len(users[command])})
+	}
+	sort.Slice(stats.Commands, func(i, j int) bool {
+		a, b := stats.Commands[i], stats.Commands[j]
+		if a.Uses != b.Uses {
+			return a.Uses > b.Uses
+		}
+		return a.Command < b.Command
+	})
+	for userID, n := range byUser {
+		stats.Users = append(stats.Users, UserCount{UserID: userID, Uses: n})
+	}
+	sort.Slice(stats.Users, func(i, j int) bool {
+		a, b := stats.Users[i], stats.Users[j]
+		if a.Uses != b.Uses {
+			return a.Uses > b.Uses
+		}
+		return a.UserID < b.UserID
+	})
+	return stats, nil
+}
+
+// Prune deletes buckets older than the retention period.
+func (t *Tracker) Prune(ctx context.Context, now time.Time) error {
+	keys, err := t.store.List(ctx, keyPrefix)
+	if err != nil {
+		return err
+	}
+	cutoff := bucketKey(now.Add(-t.retention))
+	for _, key := range keys {
+		// Keys sort by hour, so whole hours before the cutoff hour are deleted
+		if key < cutoff {
+			if err := t.store.Delete(ctx, key); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
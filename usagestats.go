@@ -0,0 +1,100 @@
+package tgwrapper
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/0xVanfer/tg-listener/core"
+	"github.com/0xVanfer/tg-listener/eventlog"
+	"github.com/0xVanfer/tg-listener/usage"
+)
+
+// UsageStats returns the command usage since a time, truncated to the hour:
+// uses by command and by user, the most used first. Uses are only counted
+// while bot.usage_stats.enabled is set.
+//
+// Example:
+//
+//	stats, _ := wrapper.UsageStats(ctx, time.Now().Add(-24*time.Hour))
+//	for _, c := range stats.TopCommands(5) {
+//	    fmt.Printf("/%s: %d uses by %d users\n", c.Command, c.Uses, c.Users)
+//	}
+func (w *Wrapper) UsageStats(ctx context.Context, since time.Time) (usage.Stats, error) {
+	w.storeMu.RLock()
+	tracker := w.usage
+	w.storeMu.RUnlock()
+	return tracker.Stats(ctx, since)
+}
+
+// UsageReport builds a message listing the total command uses since a time,
+// the top commands, and the most active users, bot.usage_stats.top of each.
+func (w *Wrapper) UsageReport(ctx context.Context, since time.Time) (*core.Builder, error) {
+	stats, err := w.UsageStats(ctx, since)
+	if err != nil {
+		return nil, err
+	}
+	top := w.Config().Bot.UsageStats.GetTop()
+
+	b := core.NewBuilder()
+	b.Header("📈 Command Usage")
+	b.KeyValue("Since", stats.Since.Format("2006-01-02 15:04")+" UTC")
+	b.KeyValue("Commands used", strconv.Itoa(stats.Uses))
+	b.KeyValue("Active users", strconv.Itoa(len(stats.Users)))
+
+	if commands := stats.TopCommands(top); len(commands) > 0 {
+		items := make([]string, len(commands))
+		for i, c := range commands {
+			items[i] = fmt.Sprintf("/%s — %d uses, %d users", c.Command, c.Uses, c.Users)
+		}
+		b.Ln().SubHeader("Top commands").NumberedList(items...)
+	}
+	if users := stats.TopUsers(top); len(users) > 0 {
+		items := make([]string, len(users))
+		for i, u := range users {
+			items[i] = fmt.Sprintf("%s — %d uses", w.userLabel(ctx, u.UserID), u.Uses)
+		}
+		b.Ln().SubHeader("Most active users").NumberedList(items...)
+	}
+	return b, nil
+}
+
+// userLabel names a user for reports: their username, or their name and ID.
+func (w *Wrapper) userLabel(ctx context.Context, userID int64) string {
+	id := strconv.FormatInt(userID, 10)
+	u, err := w.Users().Get(ctx, userID)
+	if err != nil {
+		return id
+	}
+	if u.Username != "" {
+		return "@" + u.Username
+	}
+	if name := strings.TrimSpace(u.FirstName + " " + u.LastName); name != "" {
+		return name + " (" + id + ")"
+	}
+	return id
+}
+
+// recordUsage counts a handled command if usage statistics are enabled.
+func (w *Wrapper) recordUsage(ctx context.Context, e eventlog.Event) {
+	if e.Type != eventlog.TypeCommand || e.UserID == 0 || !w.Config().Bot.UsageStats.IsEnabled() {
+		return
+	}
+	w.storeMu.RLock()
+	tracker := w.usage
+	w.storeMu.RUnlock()
+	_ = tracker.Record(ctx, e.Detail, e.UserID)
+}
+
+// pruneUsage deletes usage counts beyond their retention.
+func (w *Wrapper) pruneUsage(ctx context.Context) error {
+	if !w.Config().Bot.UsageStats.IsEnabled() {
+		return nil
+	}
+	w.storeMu.RLock()
+	tracker := w.usage
+	w.storeMu.RUnlock()
+	return tracker.Prune(ctx, time.Now())
+}
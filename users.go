@@ -0,0 +1,208 @@
+package tgwrapper
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/mymmrac/telego"
+
+	"github.com/0xVanfer/tg-listener/config"
+	"github.com/0xVanfer/tg-listener/conv"
+	"github.com/0xVanfer/tg-listener/core"
+	"github.com/0xVanfer/tg-listener/eventlog"
+	"github.com/0xVanfer/tg-listener/users"
+)
+
+// AllUsersSegment is the built-in segment containing every registered user.
+const AllUsersSegment = "all"
+
+// userSegments holds defined segments by name.
+type userSegments struct {
+	predicates map[string]users.Predicate // Segments defined in code
+	configured map[string]users.Predicate // Segments declared in the configuration, replaced as a whole on reload
+	mu         sync.RWMutex
+}
+
+// Users returns the user registry.
+// Users are recorded automatically on every update; bots can attach custom
+// attributes with SetAttribute for use in segments.
+func (w *Wrapper) Users() *users.Registry {
+	w.storeMu.RLock()
+	defer w.storeMu.RUnlock()
+	return w.users
+}
+
+// UserBuilder returns a message builder for a user: it translates Tr keys
+// into their language, formats numbers, amounts, and dates in its locale and
+// in their time zone, and uses the theme's texts for their language.
+//
+// Example:
+//
+//	text, entities := wrapper.UserBuilder(ctx, userID).
+//		Tr("balance.label").Money(1234.5, "EUR").Ln().
+//		Text("As of ").DateTime(time.Now()).
+//		Build()
+func (w *Wrapper) UserBuilder(ctx context.Context, userID int64) *core.Builder {
+	language := w.UserLanguage(ctx, userID)
+	return core.NewBuilder().
+		WithLocale(core.LocaleFor(language)).
+		WithStrings(w.router.Strings(language)).
+		WithTranslator(w.userTranslator(language)).
+		In(w.UserLocation(ctx, userID))
+}
+
+// DefineSegment defines a named user segment and registers it as a broadcast audience.
+// Defining a segment with an existing name replaces it; segments defined in
+// code take precedence over those declared in the configuration.
+//
+// Example:
+//
+//	wrapper.DefineSegment("lapsed_en", users.And(
+//		users.Language("en"),
+//		users.NotSeenWithin(30*24*time.Hour),
+//		users.CompletedFlow("onboarding"),
+//	))
+func (w *Wrapper) DefineSegment(name string, p users.Predicate) {
+	w.segments.mu.Lock()
+	if w.segments.predicates == nil {
+		w.segments.predicates = make(map[string]users.Predicate)
+	}
+	w.segments.predicates[name] = p
+	w.segments.mu.Unlock()
+
+	w.RegisterBroadcastTarget(name, func(ctx context.Context) ([]int64, error) {
+		return w.SegmentUserIDs(ctx, name)
+	})
+}
+
+// Segments returns the names of all defined segments, in code and in the
+// configuration, in sorted order.
+func (w *Wrapper) Segments() []string {
+	w.segments.mu.RLock()
+	defer w.segments.mu.RUnlock()
+	names := make([]string, 0, len(w.segments.predicates)+len(w.segments.configured))
+	for name := range w.segments.predicates {
+		names = append(names, name)
+	}
+	for name := range w.segments.configured {
+		if _, ok := w.segments.predicates[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// configuredSegments returns the names of the segments declared in the
+// configuration and not overridden in code.
+func (w *Wrapper) configuredSegments() []string {
+	w.segments.mu.RLock()
+	defer w.segments.mu.RUnlock()
+	var names []string
+	for name := range w.segments.configured {
+		if _, ok := w.segments.predicates[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// segment returns the predicate of a defined segment.
+func (w *Wrapper) segment(name string) (users.Predicate, error) {
+	w.segments.mu.RLock()
+	p, ok := w.segments.predicates[name]
+	if !ok {
+		p, ok = w.segments.configured[name]
+	}
+	w.segments.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("segment %s is not defined", name)
+	}
+	return p, nil
+}
+
+// SegmentUserIDs returns the IDs of the users in a segment.
+// User IDs double as private chat IDs, so the result can be passed to Broadcast.
+func (w *Wrapper) SegmentUserIDs(ctx context.Context, name string) ([]int64, error) {
+	p, err := w.segment(name)
+	if err != nil {
+		return nil, err
+	}
+	return w.Users().QueryIDs(ctx, p)
+}
+
+// SegmentCount returns the number of users in a segment,
+// for previewing the reach of a broadcast before sending it.
+func (w *Wrapper) SegmentCount(ctx context.Context, name string) (int, error) {
+	p, err := w.segment(name)
+	if err != nil {
+		return 0, err
+	}
+	return w.Users().Count(ctx, p)
+}
+
+// installSegments defines the built-in segment and replaces the segments
+// declared in the configuration with those of cfg, so segments removed from
+// it are no longer defined or offered as broadcast audiences.
+func (w *Wrapper) installSegments(cfg *config.Config) {
+	w.DefineSegment(AllUsersSegment, users.All())
+	configured := make(map[string]users.Predicate, len(cfg.Segments))
+	for id, segment := range cfg.Segments {
+		configured[id] = users.FromConfig(segment)
+	}
+	w.segments.mu.Lock()
+	w.segments.configured = configured
+	w.segments.mu.Unlock()
+}
+
+// recordUser records the sender of an update in the user registry.
+func (w *Wrapper) recordUser(ctx context.Context, update telego.Update) {
+	var from *telego.User
+	switch {
+	case update.Message != nil:
+		from = update.Message.From
+	case update.CallbackQuery != nil:
+		from = &update.CallbackQuery.From
+	case update.EditedMessage != nil:
+		from = update.EditedMessage.From
+	case update.InlineQuery != nil:
+		from = &update.InlineQuery.From
+	}
+	if from == nil {
+		return
+	}
+	_ = w.Users().Touch(ctx, *from)
+}
+
+// conversationEnded records completed flows in the user registry, settles
+// the credits held by flows billed on completion, and forwards the event to the OnConversationEnd callback.
+func (w *Wrapper) conversationEnded(ctx context.Context, c *conv.Conversation) {
+	w.recordFlowEvent(ctx, eventlog.TypeFlowEnded, c, conversationOutcome(c))
+	w.cancelStepTimeout(ctx, c)
+	// Previews have no effects beyond the chat
+	preview := c.IsPreview()
+	if c.GetState() == conv.StateCompleted && c.FlowID != "" && !preview {
+		_ = w.Users().MarkFlowCompleted(ctx, c.UserID, c.FlowID)
+	}
+	if flow := w.Config().GetFlow(c.FlowID); flow != nil && flow.Credits != nil && flow.Credits.GetChargeOn() == config.ChargeOnComplete && !preview {
+		w.settleFlowCredits(ctx, c, flow)
+	}
+	if fn := w.onConversationEnd; fn != nil && !preview {
+		fn(ctx, c)
+	}
+	if !preview {
+		w.publishConversation(c)
+		w.fileConversationTicket(ctx, c)
+	}
+	w.unpinConversation(ctx, c)
+	w.clearReplyKeyboard(ctx, c)
+	w.cleanupConversation(ctx, c)
+	w.sweepConversation(ctx, c)
+	// Forget after the callback, which may still collapse the thread
+	w.threads.Forget(ConversationThread(c))
+	w.setForkedConversation(c.UserID, c.ChatID, false)
+	// Run the commands held while the conversation was active
+	w.router.RunQueuedCommands(ctx, c)
+}
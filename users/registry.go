@@ -0,0 +1,261 @@
+package users
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mymmrac/telego"
+
+	"github.com/0xVanfer/tg-listener/store"
+	"github.com/0xVanfer/tg-listener/tz"
+)
+
+// keyPrefix is the store key prefix for user entries.
+const keyPrefix = "user:"
+
+// DefaultTouchInterval is how often an unchanged user's LastSeen is persisted.
+const DefaultTouchInterval = time.Minute
+
+// Registry is a persistent registry of users backed by a store.
+// Users are recorded automatically as they interact with the bot.
+type Registry struct {
+	store         store.Store         // Backing store
+	touchInterval time.Duration       // Minimum interval between LastSeen writes
+	touched       map[int64]time.Time // Last persisted LastSeen by user ID, within the touch interval
+	swept         time.Time           // When touched entries older than the touch interval were last dropped
+	mu            sync.Mutex          // Serializes read-modify-write cycles
+}
+
+// NewRegistry creates a user registry persisted in the given store.
+func NewRegistry(s store.Store) *Registry {
+	return &Registry{
+		store:         s,
+		touchInterval: DefaultTouchInterval,
+		touched:       make(map[int64]time.Time),
+	}
+}
+
+// SetTouchInterval sets how often an unchanged user's LastSeen is persisted.
+// Lower values make last_seen queries more precise at the cost of more writes.
+func (r *Registry) SetTouchInterval(d time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.touchInterval = d
+}
+
+// userKey returns the store key for a user.
+func userKey(userID int64) string {
+	return keyPrefix + strconv.FormatInt(userID, 10)
+}
+
+// load reads a user entry. Returns store.ErrNotFound if the user is unknown.
+func (r *Registry) load(ctx context.Context, userID int64) (*User, error) {
+	var u User
+	if err := store.GetJSON(ctx, r.store, userKey(userID), &u); err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
+
+// Get retrieves a user. Returns store.ErrNotFound if the user is unknown.
+func (r *Registry) Get(ctx context.Context, userID int64) (*User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.load(ctx, userID)
+}
+
+// Touch records activity from a Telegram user, creating the entry on first sight.
+// Profile changes are persisted immediately; LastSeen alone is persisted at most
+// once per touch interval to keep store writes low.
+func (r *Registry) Touch(ctx context.Context, from telego.User) error {
+	if from.ID == 0 || from.IsBot {
+		return nil
+	}
+	now := time.Now()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.sweepTouched(now)
+
+	u, err := r.load(ctx, from.ID)
+	if errors.Is(err, store.ErrNotFound) {
+		u = newUser(from, now)
+	} else if err != nil {
+		return err
+	} else if !u.applyProfile(from) && !u.Blocked() && now.Sub(r.touched[from.ID]) < r.touchInterval {
+		return nil
+	}
+
+	// A user who is active again has unblocked the bot
+	u.BlockedAt = time.Time{}
+	u.LastSeen = now
+	r.touched[from.ID] = now
+	return store.PutJSON(ctx, r.store, userKey(u.ID), u)
+}
+
+// sweepTouched drops the LastSeen writes older than the touch interval at
+// most once per interval: they no longer hold back a write, and dropping them
+// keeps the map bounded by the users active within the interval. Callers must hold r.mu.
+func (r *Registry) sweepTouched(now time.Time) {
+	if now.Sub(r.swept) < r.touchInterval {
+		return
+	}
+	for id, at := range r.touched {
+		if now.Sub(at) >= r.touchInterval {
+			delete(r.touched, id)
+		}
+	}
+	r.swept = now
+}
+
+// Update applies fn to a user entry and persists the result.
+// Unknown users are created with only their ID set.
+func (r *Registry) Update(ctx context.Context, userID int64, fn func(u *User)) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	u, err := r.load(ctx, userID)
+	if errors.Is(err, store.ErrNotFound) {
+		u = &User{ID: userID, FirstSeen: time.Now()}
+	} else if err != nil {
+		return err
+	}
+
+	fn(u)
+	u.ID = userID
+	return store.PutJSON(ctx, r.store, userKey(userID), u)
+}
+
+// SetAttribute sets a custom attribute on a user.
+// Attributes are available to segment predicates.
+func (r *Registry) SetAttribute(ctx context.Context, userID int64, key string, value interface{}) error {
+	return r.Update(ctx, userID, func(u *User) {
+		if u.Attributes == nil {
+			u.Attributes = make(map[string]interface{})
+		}
+		u.Attributes[key] = value
+	})
+}
+
+// DeleteAttribute removes a custom attribute from a user.
+func (r *Registry) DeleteAttribute(ctx context.Context, userID int64, key string) error {
+	return r.Update(ctx, userID, func(u *User) {
+		delete(u.Attributes, key)
+	})
+}
+
+// SetTimezone stores the time zone of a user, given as accepted by tz.Load.
+// An empty name clears it. Returns tz.ErrUnknownZone for unknown zones.
+func (r *Registry) SetTimezone(ctx context.Context, userID int64, name string) error {
+	if name != "" {
+		loc, err := tz.Load(name)
+		if err != nil {
+			return err
+		}
+		name = loc.String()
+	}
+	return r.Update(ctx, userID, func(u *User) {
+		u.Timezone = name
+	})
+}
+
+// SetLocale stores the language chosen by a user, overriding the language
+// code reported by Telegram. An empty locale reverts to the latter.
+func (r *Registry) SetLocale(ctx context.Context, userID int64, locale string) error {
+	return r.Update(ctx, userID, func(u *User) {
+		u.Locale = locale
+	})
+}
+
+// MarkBlocked records that a user blocked the bot, so broadcasts skip them
+// until they are seen again.
+func (r *Registry) MarkBlocked(ctx context.Context, userID int64, at time.Time) error {
+	return r.Update(ctx, userID, func(u *User) {
+		u.BlockedAt = at
+	})
+}
+
+// MarkFlowCompleted records that a user completed a flow.
+func (r *Registry) MarkFlowCompleted(ctx context.Context, userID int64, flowID string) error {
+	return r.Update(ctx, userID, func(u *User) {
+		if u.CompletedFlows == nil {
+			u.CompletedFlows = make(map[string]time.Time)
+		}
+		u.CompletedFlows[flowID] = time.Now()
+	})
+}
+
+// Delete removes a user from the registry.
+func (r *Registry) Delete(ctx context.Context, userID int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.touched, userID)
+	return r.store.Delete(ctx, userKey(userID))
+}
+
+// Range calls fn for every registered user until fn returns false.
+// Entries that fail to load are skipped.
+func (r *Registry) Range(ctx context.Context, fn func(u *User) bool) error {
+	keys, err := r.store.List(ctx, keyPrefix)
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		userID, err := strconv.ParseInt(strings.TrimPrefix(key, keyPrefix), 10, 64)
+		if err != nil {
+			continue
+		}
+		u, err := r.Get(ctx, userID)
+		if err != nil {
+			continue
+		}
+		if !fn(u) {
+			return nil
+		}
+	}
+	return nil
+}
+
+// Query returns all users matching the predicate.
+// A nil predicate matches every user.
+func (r *Registry) Query(ctx context.Context, p Predicate) ([]*User, error) {
+	var result []*User
+	err := r.Range(ctx, func(u *User) bool {
+		if p == nil || p(u) {
+			result = append(result, u)
+		}
+		return true
+	})
+	return result, err
+}
+
+// QueryIDs returns the IDs of all users matching the predicate.
+func (r *Registry) QueryIDs(ctx context.Context, p Predicate) ([]int64, error) {
+	var ids []int64
+	err := r.Range(ctx, func(u *User) bool {
+		if p == nil || p(u) {
+			ids = append(ids, u.ID)
+		}
+		return true
+	})
+	return ids, err
+}
+
+// Count returns the number of users matching the predicate.
+func (r *Registry) Count(ctx context.Context, p Predicate) (int, error) {
+	count := 0
+	err := r.Range(ctx, func(u *User) bool {
+		if p == nil || p(u) {
+			count++
+		}
+		return true
+	})
+	return count, err
+}
@@ -0,0 +1,177 @@
+package users
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/0xVanfer/tg-listener/config"
+)
+
+// Predicate reports whether a user belongs to a segment.
+type Predicate func(u *User) bool
+
+// All matches every user.
+func All() Predicate {
+	return func(*User) bool { return true }
+}
+
+// Language matches users whose language is one of the given codes.
+// Codes without a region match any regional variant ("en" matches "en-US").
+func Language(codes ...string) Predicate {
+	return func(u *User) bool {
+		for _, code := range codes {
+			code = strings.ToLower(code)
+			if strings.EqualFold(u.PreferredLanguage(), code) || (!strings.Contains(code, "-") && u.Language() == code) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// SeenWithin matches users active within d.
+func SeenWithin(d time.Duration) Predicate {
+	return func(u *User) bool {
+		return time.Since(u.LastSeen) <= d
+	}
+}
+
+// NotSeenWithin matches users inactive for at least d.
+func NotSeenWithin(d time.Duration) Predicate {
+	return func(u *User) bool {
+		return time.Since(u.LastSeen) >= d
+	}
+}
+
+// SeenBetween matches users last active between from and to.
+func SeenBetween(from, to time.Time) Predicate {
+	return func(u *User) bool {
+		return !u.LastSeen.Before(from) && !u.LastSeen.After(to)
+	}
+}
+
+// HasAttribute matches users that have the attribute set.
+func HasAttribute(key string) Predicate {
+	return func(u *User) bool {
+		_, ok := u.Attribute(key)
+		return ok
+	}
+}
+
+// AttributeEquals matches users whose attribute equals value.
+// Numbers are compared numerically regardless of type (attributes read back from
+// the store are float64); other values by their formatted representation.
+func AttributeEquals(key string, value interface{}) Predicate {
+	return func(u *User) bool {
+		v, ok := u.Attribute(key)
+		return ok && valuesEqual(v, value)
+	}
+}
+
+// valuesEqual compares two attribute values.
+func valuesEqual(a, b interface{}) bool {
+	fa, aNum := toFloat(a)
+	fb, bNum := toFloat(b)
+	if aNum && bNum {
+		return fa == fb
+	}
+	return fmt.Sprint(a) == fmt.Sprint(b)
+}
+
+// toFloat converts numeric values to float64.
+func toFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case int:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case uint64:
+		return float64(n), true
+	case float32:
+		return float64(n), true
+	case float64:
+		return n, true
+	}
+	return 0, false
+}
+
+// AttributeMatches matches users whose attribute satisfies fn.
+func AttributeMatches(key string, fn func(value interface{}) bool) Predicate {
+	return func(u *User) bool {
+		v, ok := u.Attribute(key)
+		return ok && fn(v)
+	}
+}
+
+// Reachable matches users who haven't blocked the bot.
+func Reachable() Predicate {
+	return func(u *User) bool {
+		return !u.Blocked()
+	}
+}
+
+// CompletedFlow matches users who completed the given flow.
+func CompletedFlow(flowID string) Predicate {
+	return func(u *User) bool {
+		return u.HasCompleted(flowID)
+	}
+}
+
+// And matches users matching all predicates.
+func And(predicates ...Predicate) Predicate {
+	return func(u *User) bool {
+		for _, p := range predicates {
+			if !p(u) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// Or matches users matching any predicate.
+func Or(predicates ...Predicate) Predicate {
+	return func(u *User) bool {
+		for _, p := range predicates {
+			if p(u) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// Not matches users not matching the predicate.
+func Not(p Predicate) Predicate {
+	return func(u *User) bool {
+		return !p(u)
+	}
+}
+
+// FromConfig builds a predicate from a segment configuration.
+// All configured criteria must match.
+func FromConfig(cfg *config.SegmentConfig) Predicate {
+	var predicates []Predicate
+	if len(cfg.Languages) > 0 {
+		predicates = append(predicates, Language(cfg.Languages...))
+	}
+	if cfg.SeenWithin > 0 {
+		predicates = append(predicates, SeenWithin(cfg.SeenWithin))
+	}
+	if cfg.NotSeenWithin > 0 {
+		predicates = append(predicates, NotSeenWithin(cfg.NotSeenWithin))
+	}
+	for key, value := range cfg.Attributes {
+		predicates = append(predicates, AttributeEquals(key, value))
+	}
+	for _, flowID := range cfg.CompletedFlows {
+		predicates = append(predicates, CompletedFlow(flowID))
+	}
+	for _, flowID := range cfg.NotCompletedFlows {
+		predicates = append(predicates, Not(CompletedFlow(flowID)))
+	}
+	return And(predicates...)
+}
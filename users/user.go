@@ -0,0 +1,111 @@
+// Package users provides a persistent registry of the users who interact with the bot,
+// and segment queries over it for targeting broadcasts and notifications.
+package users
+
+import (
+	"strings"
+	"time"
+
+	"github.com/mymmrac/telego"
+
+	"github.com/0xVanfer/tg-listener/tz"
+)
+
+// User is a registry entry describing a user seen by the bot.
+type User struct {
+	ID           int64  `json:"id"`                 // Telegram user ID (also the private chat ID)
+	Username     string `json:"username"`           // Username without the leading @
+	FirstName    string `json:"first_name"`         // First name
+	LastName     string `json:"last_name"`          // Last name
+	LanguageCode string `json:"language_code"`      // IETF language tag reported by Telegram
+	Locale       string `json:"locale,omitempty"`   // Language chosen by the user; takes precedence over LanguageCode
+	Timezone     string `json:"timezone,omitempty"` // Time zone chosen by the user, see tz.Load
+
+	FirstSeen time.Time `json:"first_seen"`           // When the user was first seen
+	LastSeen  time.Time `json:"last_seen"`            // When the user was last seen
+	BlockedAt time.Time `json:"blocked_at,omitempty"` // When a message found the bot blocked; cleared when the user is seen again
+
+	Attributes     map[string]interface{} `json:"attributes,omitempty"`      // Custom attributes set by the bot
+	CompletedFlows map[string]time.Time   `json:"completed_flows,omitempty"` // Completion time by flow ID
+}
+
+// newUser creates a registry entry from a Telegram user.
+func newUser(from telego.User, now time.Time) *User {
+	u := &User{
+		ID:        from.ID,
+		FirstSeen: now,
+	}
+	u.applyProfile(from)
+	return u
+}
+
+// applyProfile copies profile fields from a Telegram user.
+// Returns true if any field changed.
+func (u *User) applyProfile(from telego.User) bool {
+	changed := u.Username != from.Username ||
+		u.FirstName != from.FirstName ||
+		u.LastName != from.LastName ||
+		(from.LanguageCode != "" && u.LanguageCode != from.LanguageCode)
+
+	u.Username = from.Username
+	u.FirstName = from.FirstName
+	u.LastName = from.LastName
+	if from.LanguageCode != "" {
+		u.LanguageCode = from.LanguageCode
+	}
+	return changed
+}
+
+// PreferredLanguage returns the language the user chose, or else the language
+// code reported by Telegram.
+func (u *User) PreferredLanguage() string {
+	if u.Locale != "" {
+		return u.Locale
+	}
+	return u.LanguageCode
+}
+
+// Language returns the base language of the user's preferred language ("en" for "en-US").
+func (u *User) Language() string {
+	lang, _, _ := strings.Cut(u.PreferredLanguage(), "-")
+	return strings.ToLower(lang)
+}
+
+// Location returns the user's time zone, or nil if the user hasn't chosen one.
+func (u *User) Location() *time.Location {
+	if u.Timezone == "" {
+		return nil
+	}
+	loc, err := tz.Load(u.Timezone)
+	if err != nil {
+		return nil
+	}
+	return loc
+}
+
+// Attribute retrieves a custom attribute.
+// Returns the value and a boolean indicating if the attribute exists.
+func (u *User) Attribute(key string) (interface{}, bool) {
+	v, ok := u.Attributes[key]
+	return v, ok
+}
+
+// Blocked returns true if the user blocked the bot and hasn't been seen since.
+func (u *User) Blocked() bool {
+	return !u.BlockedAt.IsZero()
+}
+
+// HasCompleted returns true if the user has completed the given flow.
+func (u *User) HasCompleted(flowID string) bool {
+	_, ok := u.CompletedFlows[flowID]
+	return ok
+}
+
+// DisplayName returns the user's full name, falling back to the username.
+func (u *User) DisplayName() string {
+	name := strings.TrimSpace(u.FirstName + " " + u.LastName)
+	if name == "" && u.Username != "" {
+		return "@" + u.Username
+	}
+	return name
+}
@@ -0,0 +1,264 @@
+// Package vote persists votes cast with buttons: their options, one ballot per
+// user, and deadlines, so tallies survive restarts.
+package vote
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/0xVanfer/tg-listener/store"
+)
+
+// keyPrefix is the store key prefix for vote states.
+const keyPrefix = "vote:"
+
+var (
+	// ErrNotFound is returned when a vote does not exist.
+	ErrNotFound = errors.New("vote not found")
+
+	// ErrClosed is returned when a ballot is cast after the vote closed.
+	ErrClosed = errors.New("vote closed")
+
+	// ErrAlreadyVoted is returned when a user votes again in a vote that
+	// doesn't allow changing ballots.
+	ErrAlreadyVoted = errors.New("already voted")
+
+	// ErrUnknownOption is returned when a ballot names an option the vote doesn't have.
+	ErrUnknownOption = errors.New("unknown vote option")
+)
+
+// Option is a choice of a vote.
+type Option struct {
+	ID   string `json:"id"`
+	Text string `json:"text"`
+}
+
+// Ballot records a user's choice.
+type Ballot struct {
+	UserID   int64     `json:"user_id"`
+	Name     string    `json:"name"` // Display name at the time of voting
+	OptionID string    `json:"option_id"`
+	At       time.Time `json:"at"`
+}
+
+// State is the persisted state of a vote.
+type State struct {
+	ID          string    `json:"id"`
+	ConfigID    string    `json:"config_id"` // ID of the vote configuration it was started from
+	ChatID      int64     `json:"chat_id"`
+	TopicID     int       `json:"topic_id"`
+	MessageID   int       `json:"message_id"` // Message showing the tally
+	Question    string    `json:"question"`
+	Options     []Option  `json:"options"`
+	Anonymous   bool      `json:"anonymous"`    // Hide who voted for what
+	AllowChange bool      `json:"allow_change"` // Voters may switch to another option
+	StartedAt   time.Time `json:"started_at"`
+	ClosesAt    time.Time `json:"closes_at,omitempty"` // Deadline; zero if the vote is closed by hand
+	ClosedAt    time.Time `json:"closed_at,omitempty"` // When the vote was closed; zero while open
+	Ballots     []Ballot  `json:"ballots,omitempty"`
+}
+
+// Closed returns true if the vote was closed.
+func (s *State) Closed() bool {
+	return !s.ClosedAt.IsZero()
+}
+
+// Expired returns true if the vote's deadline passed at the given time.
+func (s *State) Expired(at time.Time) bool {
+	return !s.ClosesAt.IsZero() && !at.Before(s.ClosesAt)
+}
+
+// Option returns the option with the given ID.
+func (s *State) Option(id string) (Option, bool) {
+	for _, o := range s.Options {
+		if o.ID == id {
+			return o, true
+		}
+	}
+	return Option{}, false
+}
+
+// BallotOf returns the ballot of a user, or nil if the user hasn't voted.
+func (s *State) BallotOf(userID int64) *Ballot {
+	for i := range s.Ballots {
+		if s.Ballots[i].UserID == userID {
+			return &s.Ballots[i]
+		}
+	}
+	return nil
+}
+
+// Tally returns the number of ballots per option ID.
+func (s *State) Tally() map[string]int {
+	tally := make(map[string]int, len(s.Options))
+	for _, b := range s.Ballots {
+		tally[b.OptionID]++
+	}
+	return tally
+}
+
+// Voters returns the ballots cast for an option, oldest first.
+func (s *State) Voters(optionID string) []Ballot {
+	var ballots []Ballot
+	for _, b := range s.Ballots {
+		if b.OptionID == optionID {
+			ballots = append(ballots, b)
+		}
+	}
+	return ballots
+}
+
+// Winners returns the options with the most ballots, in option order.
+// Returns nil if nobody voted.
+func (s *State) Winners() []Option {
+	tally := s.Tally()
+	best := 0
+	for _, n := range tally {
+		best = max(best, n)
+	}
+	if best == 0 {
+		return nil
+	}
+	var winners []Option
+	for _, o := range s.Options {
+		if tally[o.ID] == best {
+			winners = append(winners, o)
+		}
+	}
+	return winners
+}
+
+// Tracker persists vote states in a store.
+type Tracker struct {
+	store store.Store // Backing store
+	mu    sync.Mutex  // Serializes read-modify-write cycles
+}
+
+// NewTracker creates a vote tracker persisted in the given store.
+func NewTracker(s store.Store) *Tracker {
+	return &Tracker{store: s}
+}
+
+// NewID returns a random vote ID, short enough for callback data.
+func NewID() (string, error) {
+	buf := make([]byte, 6)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// Save stores a vote state, replacing any previous state with the same ID.
+func (t *Tracker) Save(ctx context.Context, s *State) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return store.PutJSON(ctx, t.store, keyPrefix+s.ID, s)
+}
+
+// Get retrieves a vote state.
+// Returns ErrNotFound if the vote does not exist.
+func (t *Tracker) Get(ctx context.Context, id string) (*State, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.get(ctx, id)
+}
+
+// get retrieves a vote state without locking.
+func (t *Tracker) get(ctx context.Context, id string) (*State, error) {
+	var s State
+	if err := store.GetJSON(ctx, t.store, keyPrefix+id, &s); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return &s, nil
+}
+
+// Cast records a user's ballot. Each user has one ballot; voting again
+// switches it to the new option if the vote allows changes.
+// Returns the updated state and false if the user had already chosen the option.
+func (t *Tracker) Cast(ctx context.Context, id string, userID int64, name, optionID string, at time.Time) (*State, bool, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	s, err := t.get(ctx, id)
+	if err != nil {
+		return nil, false, err
+	}
+	if s.Closed() || s.Expired(at) {
+		return s, false, ErrClosed
+	}
+	if _, ok := s.Option(optionID); !ok {
+		return s, false, ErrUnknownOption
+	}
+
+	if b := s.BallotOf(userID); b != nil {
+		if b.OptionID == optionID {
+			return s, false, nil
+		}
+		if !s.AllowChange {
+			return s, false, ErrAlreadyVoted
+		}
+		b.OptionID, b.Name, b.At = optionID, name, at
+	} else {
+		s.Ballots = append(s.Ballots, Ballot{UserID: userID, Name: name, OptionID: optionID, At: at})
+	}
+
+	if err := store.PutJSON(ctx, t.store, keyPrefix+id, s); err != nil {
+		return nil, false, err
+	}
+	return s, true, nil
+}
+
+// Close closes a vote, so no more ballots are accepted.
+// Returns the final state and false if the vote was already closed.
+func (t *Tracker) Close(ctx context.Context, id string, at time.Time) (*State, bool, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	s, err := t.get(ctx, id)
+	if err != nil {
+		return nil, false, err
+	}
+	if s.Closed() {
+		return s, false, nil
+	}
+	s.ClosedAt = at
+	if err := store.PutJSON(ctx, t.store, keyPrefix+id, s); err != nil {
+		return nil, false, err
+	}
+	return s, true, nil
+}
+
+// List returns all vote states.
+func (t *Tracker) List(ctx context.Context) ([]*State, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	keys, err := t.store.List(ctx, keyPrefix)
+	if err != nil {
+		return nil, err
+	}
+	states := make([]*State, 0, len(keys))
+	for _, key := range keys {
+		s, err := t.get(ctx, strings.TrimPrefix(key, keyPrefix))
+		if errors.Is(err, ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		states = append(states, s)
+	}
+	return states, nil
+}
+
+// Delete removes a vote state.
+func (t *Tracker) Delete(ctx context.Context, id string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.store.Delete(ctx, keyPrefix+id)
+}
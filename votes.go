@@ -0,0 +1,299 @@
+package tgwrapper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mymmrac/telego"
+
+	"github.com/0xVanfer/tg-listener/config"
+	"github.com/0xVanfer/tg-listener/core"
+	"github.com/0xVanfer/tg-listener/vote"
+)
+
+// VoteCallbackPrefix is the callback data prefix of vote buttons.
+// The data is the prefix, the vote ID, ":", and the option ID.
+const VoteCallbackPrefix = "vote:"
+
+// VoteClosedFunc is called when a vote closes, with its final state.
+type VoteClosedFunc func(ctx context.Context, s *vote.State)
+
+// voteTimers holds the closing timers of open votes.
+type voteTimers struct {
+	timers  map[string]*time.Timer // Closing timers by vote ID
+	onClose VoteClosedFunc         // User callback for closed votes
+	mu      sync.Mutex             // Mutex for thread-safe timer access
+}
+
+// Votes returns the tracker of vote states.
+func (w *Wrapper) Votes() *vote.Tracker {
+	w.storeMu.RLock()
+	defer w.storeMu.RUnlock()
+	return w.voteStates
+}
+
+// SetOnVoteClosed sets a callback for closed votes, e.g. to act on the winning option.
+func (w *Wrapper) SetOnVoteClosed(fn VoteClosedFunc) {
+	w.votes.mu.Lock()
+	defer w.votes.mu.Unlock()
+	w.votes.onClose = fn
+}
+
+// StartVote posts a configured vote to a chat and returns its state.
+// The tally on its buttons updates with every ballot. Ballots and the closing
+// deadline are persisted in the store, and open votes close on time after a restart.
+func (w *Wrapper) StartVote(ctx context.Context, chatID int64, topicID int, voteID string) (*vote.State, error) {
+	cfg := w.Config().GetVote(voteID)
+	if cfg == nil {
+		return nil, fmt.Errorf("vote %q not found", voteID)
+	}
+	id, err := vote.NewID()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	s := &vote.State{
+		ID:          id,
+		ConfigID:    cfg.ID,
+		ChatID:      chatID,
+		TopicID:     topicID,
+		Question:    cfg.Question,
+		Anonymous:   cfg.Anonymous,
+		AllowChange: cfg.AllowChange,
+		StartedAt:   now,
+	}
+	for _, o := range cfg.Options {
+		s.Options = append(s.Options, vote.Option{ID: o.ID, Text: o.Text})
+	}
+	if cfg.Duration > 0 {
+		s.ClosesAt = now.Add(cfg.Duration)
+	}
+
+	text, entities := voteText(s)
+	msg, err := w.bot.SendMessageWithKeyboard(ctx, chatID, topicID, text, voteKeyboard(s), entities...)
+	if err != nil {
+		return nil, err
+	}
+	if msg != nil {
+		s.MessageID = msg.MessageID
+	}
+	if err := w.Votes().Save(ctx, s); err != nil {
+		return nil, err
+	}
+	w.scheduleVote(s)
+	return s, nil
+}
+
+// CloseVote closes a vote, shows its final tally without buttons, and calls
+// the SetOnVoteClosed callback. Closing a closed vote returns its state.
+func (w *Wrapper) CloseVote(ctx context.Context, id string) (*vote.State, error) {
+	w.cancelVote(id)
+	s, closed, err := w.Votes().Close(ctx, id, time.Now())
+	if err != nil || !closed {
+		return s, err
+	}
+
+	if s.MessageID > 0 {
+		text, entities := voteText(s)
+		_, _ = w.bot.EditMessageWithKeyboard(ctx, s.ChatID, s.MessageID, text, nil, entities...)
+	}
+
+	w.votes.mu.Lock()
+	fn := w.votes.onClose
+	w.votes.mu.Unlock()
+	if fn != nil {
+		fn(ctx, s)
+	}
+	return s, nil
+}
+
+// voteText renders the question and tally of a vote. Unless the vote is
+// anonymous, voters are listed under their option.
+func voteText(s *vote.State) (string, []telego.MessageEntity) {
+	tally := s.Tally()
+	total := len(s.Ballots)
+
+	b := core.NewBuilder().Bold(s.Question).Ln()
+	for _, o := range s.Options {
+		percent := 0
+		if total > 0 {
+			percent = tally[o.ID] * 100 / total
+		}
+		b.Ln().Text(fmt.Sprintf("%s — %d (%d%%)", o.Text, tally[o.ID], percent))
+		if s.Anonymous {
+			continue
+		}
+		for i, ballot := range s.Voters(o.ID) {
+			if i == 0 {
+				b.Ln().Text("    ")
+			} else {
+				b.Text(", ")
+			}
+			b.UserMention(ballot.Name, ballot.UserID)
+		}
+	}
+
+	b.Ln().Ln()
+	switch {
+	case s.Closed():
+		b.Text(fmt.Sprintf("🏁 Closed · Votes: %d", total))
+		if winners := s.Winners(); len(winners) > 0 {
+			names := make([]string, len(winners))
+			for i, o := range winners {
+				names[i] = o.Text
+			}
+			b.Ln().Text("🏆 ").Bold(strings.Join(names, ", "))
+		}
+	case !s.ClosesAt.IsZero():
+		b.Text(fmt.Sprintf("🗳 Votes: %d · open until %s UTC", total, s.ClosesAt.UTC().Format("Jan 2 15:04")))
+	default:
+		b.Text(fmt.Sprintf("🗳 Votes: %d", total))
+	}
+	return b.Build()
+}
+
+// voteKeyboard returns the option buttons of a vote, each with its tally.
+func voteKeyboard(s *vote.State) *telego.InlineKeyboardMarkup {
+	tally := s.Tally()
+	kb := core.NewKeyboard()
+	for _, o := range s.Options {
+		kb.Row(core.Button(fmt.Sprintf("%s · %d", o.Text, tally[o.ID]), VoteCallbackPrefix+s.ID+":"+o.ID))
+	}
+	return kb.Build()
+}
+
+// scheduleVote arms the closing timer of an open vote with a deadline.
+func (w *Wrapper) scheduleVote(s *vote.State) {
+	if s.Closed() || s.ClosesAt.IsZero() {
+		return
+	}
+	id := s.ID
+	timer := time.AfterFunc(time.Until(s.ClosesAt), func() {
+		_, _ = w.CloseVote(context.Background(), id)
+	})
+
+	w.votes.mu.Lock()
+	defer w.votes.mu.Unlock()
+	if w.votes.timers == nil {
+		w.votes.timers = make(map[string]*time.Timer)
+	}
+	if old, ok := w.votes.timers[id]; ok {
+		old.Stop()
+	}
+	w.votes.timers[id] = timer
+}
+
+// cancelVote stops the closing timer of a vote.
+func (w *Wrapper) cancelVote(id string) {
+	w.votes.mu.Lock()
+	defer w.votes.mu.Unlock()
+	if timer, ok := w.votes.timers[id]; ok {
+		timer.Stop()
+		delete(w.votes.timers, id)
+	}
+}
+
+// resumeVotes re-arms the closing timers of votes open in the store.
+// Votes whose deadline passed while the bot was down close right away.
+func (w *Wrapper) resumeVotes(ctx context.Context) {
+	states, err := w.Votes().List(ctx)
+	if err != nil {
+		return
+	}
+	for _, s := range states {
+		w.scheduleVote(s)
+	}
+}
+
+// voteAllowed checks whether a user may vote: the vote's roles, and chat
+// membership for votes restricted to members.
+func (w *Wrapper) voteAllowed(ctx context.Context, s *vote.State, user telego.User) (bool, error) {
+	cfg := w.Config().GetVote(s.ConfigID)
+	if cfg == nil {
+		return true, nil
+	}
+	return w.eligible(ctx, s.ChatID, user, cfg.Roles, cfg.MembersOnly)
+}
+
+// eligible checks whether a user holds any of the given roles, if any, and
+// with membersOnly, whether the user is a member of the chat.
+func (w *Wrapper) eligible(ctx context.Context, chatID int64, user telego.User, required []string, membersOnly bool) (bool, error) {
+	if len(required) > 0 {
+		roles := core.RolesFrom(ctx)
+		if roles == nil {
+			roles = w.Roles(ctx, user.ID, user.Username)
+		}
+		if !config.HasRole(roles, required) {
+			return false, nil
+		}
+	}
+	if membersOnly {
+		return w.bot.IsChatMember(ctx, chatID, user.ID)
+	}
+	return true, nil
+}
+
+// setupVotes registers the vote button callback.
+func (w *Wrapper) setupVotes() {
+	w.router.RegisterCallbackPrefix(VoteCallbackPrefix, func(ctx context.Context, query telego.CallbackQuery) error {
+		id, optionID, _ := strings.Cut(core.ParseCallbackData(query.Data, VoteCallbackPrefix), ":")
+
+		s, err := w.Votes().Get(ctx, id)
+		if errors.Is(err, vote.ErrNotFound) {
+			return w.bot.AnswerCallback(ctx, query.ID, "This vote no longer exists.")
+		}
+		if err != nil {
+			_ = w.bot.AnswerCallback(ctx, query.ID, "")
+			return err
+		}
+		allowed, err := w.voteAllowed(ctx, s, query.From)
+		if err != nil {
+			_ = w.bot.AnswerCallback(ctx, query.ID, "")
+			return err
+		}
+		if !allowed {
+			return w.bot.AnswerCallbackWithAlert(ctx, query.ID, "🚫 You are not eligible to vote here.")
+		}
+
+		s, changed, err := w.Votes().Cast(ctx, id, query.From.ID, userDisplayName(query.From), optionID, time.Now())
+		switch {
+		case errors.Is(err, vote.ErrClosed):
+			_ = w.bot.AnswerCallback(ctx, query.ID, "This vote is closed.")
+			if s != nil && s.Expired(time.Now()) {
+				_, err = w.CloseVote(ctx, id)
+				return err
+			}
+			return nil
+		case errors.Is(err, vote.ErrAlreadyVoted):
+			return w.bot.AnswerCallback(ctx, query.ID, "You already voted.")
+		case errors.Is(err, vote.ErrUnknownOption):
+			return w.bot.AnswerCallback(ctx, query.ID, "")
+		case err != nil:
+			_ = w.bot.AnswerCallback(ctx, query.ID, "")
+			return err
+		case !changed:
+			return w.bot.AnswerCallback(ctx, query.ID, "You already chose this option.")
+		}
+
+		option, _ := s.Option(optionID)
+		_ = w.bot.AnswerCallback(ctx, query.ID, "✅ Voted for "+option.Text)
+		text, entities := voteText(s)
+		_, err = w.bot.EditMessageWithKeyboard(ctx, s.ChatID, s.MessageID, text, voteKeyboard(s), entities...)
+		return err
+	})
+}
+
+// stopVotes stops all closing timers; they resume from the store on the next Start.
+func (w *Wrapper) stopVotes() {
+	w.votes.mu.Lock()
+	defer w.votes.mu.Unlock()
+	for id, timer := range w.votes.timers {
+		timer.Stop()
+		delete(w.votes.timers, id)
+	}
+}
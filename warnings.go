@@ -0,0 +1,107 @@
+package tgwrapper
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/0xVanfer/tg-listener/alert"
+	"github.com/0xVanfer/tg-listener/config"
+	"github.com/0xVanfer/tg-listener/core"
+)
+
+// warningEscalation returns the escalation chain configured for warnings.
+func (w *Wrapper) warningEscalation() (alert.Escalation, *config.EscalationConfig) {
+	cfg := w.Config().Bot.Escalation
+	e := alert.Escalation{Silence: cfg.GetSilence(), ResetAfter: cfg.GetResetAfter()}
+	if cfg != nil {
+		e.WarningAfter = cfg.WarningAfter
+		e.MentionAfter = cfg.MentionAfter
+	}
+	return e, cfg
+}
+
+// warningChat returns the chat a warning of the given level is sent to,
+// falling back to the other of the log and warning chats.
+func (w *Wrapper) warningChat(level alert.Level) *config.ChatConfig {
+	bot := w.Config().Bot
+	if level == alert.LevelLog && bot.HasLogChat() {
+		return bot.LogChat
+	}
+	if bot.HasWarningChat() {
+		return bot.WarningChat
+	}
+	if bot.HasLogChat() {
+		return bot.LogChat
+	}
+	return nil
+}
+
+// Warn sends a warning through the escalation chain. Warnings with the same key
+// are deduplicated: they go to the log chat first, to the warning chat after
+// escalation.warning_after occurrences, and mention escalation.admins once the
+// key has kept occurring for escalation.mention_after. Repeats within the
+// silence window are only counted. Returns an error if no log or warning chat
+// is configured.
+func (w *Wrapper) Warn(ctx context.Context, key string, msg *core.Builder) error {
+	e, cfg := w.warningEscalation()
+	o, send, err := w.Alerts().Occur(ctx, key, time.Now(), e)
+	if err != nil {
+		return err
+	}
+	chat := w.warningChat(o.Level)
+	if chat == nil {
+		return fmt.Errorf("no log or warning chat is configured")
+	}
+	if !send {
+		return nil
+	}
+
+	icon := "⚠️"
+	switch o.Level {
+	case alert.LevelLog:
+		icon = "ℹ️"
+	case alert.LevelMention:
+		icon = "🚨"
+	}
+	b := core.NewBuilder().Text(icon + " ").Code(key)
+	if o.Count > 1 {
+		b.Text(fmt.Sprintf(" · %d times since %s UTC", o.Count, o.FirstAt.UTC().Format("Jan 2 15:04")))
+	}
+	if o.Suppressed > 0 {
+		b.Italic(fmt.Sprintf(" (%d suppressed)", o.Suppressed))
+	}
+	if msg != nil {
+		text, entities := msg.Build()
+		b.Ln().Append(text, entities)
+	}
+	if o.Level == alert.LevelMention && cfg != nil && len(cfg.Admins) > 0 {
+		b.Ln().Ln()
+		for i, id := range cfg.Admins {
+			if i > 0 {
+				b.Text(" ")
+			}
+			name := "admin"
+			if u, err := w.Users().Get(ctx, id); err == nil && u.DisplayName() != "" {
+				name = u.DisplayName()
+			}
+			b.UserMention(name, id)
+		}
+	}
+
+	text, entities := b.Build()
+	_, err = w.bot.SendMessage(ctx, chat.ChatID, chat.TopicID, text, entities...)
+	return err
+}
+
+// SilenceWarning mutes a warning key for the given duration, e.g. during planned
+// maintenance. Occurrences are still counted and keep escalating silently.
+func (w *Wrapper) SilenceWarning(ctx context.Context, key string, d time.Duration) error {
+	return w.Alerts().Silence(ctx, key, time.Now().Add(d))
+}
+
+// ResolveWarning ends a warning key's escalation chain and lifts any silence,
+// so its next occurrence starts at the log chat again.
+func (w *Wrapper) ResolveWarning(ctx context.Context, key string) error {
+	return w.Alerts().Resolve(ctx, key)
+}
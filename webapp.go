@@ -0,0 +1,58 @@
+package tgwrapper
+
+import (
+	"context"
+
+	"github.com/0xVanfer/tg-listener/config"
+	"github.com/0xVanfer/tg-listener/core"
+	"github.com/0xVanfer/tg-listener/handler"
+)
+
+// OnWebAppData sets a function called for data sent by Web Apps with
+// Telegram.WebApp.sendData outside web_app steps. Such data only arrives from
+// Web Apps opened with reply keyboard buttons (web_app on a reply keyboard button).
+func (w *Wrapper) OnWebAppData(fn handler.WebAppDataHandler) {
+	w.router.SetWebAppDataHandler(fn)
+}
+
+// ValidateWebAppInitData checks that init data a Web App sent to the bot's
+// backend, the value of Telegram.WebApp.initData, was signed by Telegram for
+// this bot within web_app.init_data_ttl, and returns the user who opened it.
+// Returns core.ErrInvalidInitData or core.ErrInitDataExpired if not.
+//
+// Example:
+//
+//	http.HandleFunc("/api/order", func(rw http.ResponseWriter, r *http.Request) {
+//	    data, err := wrapper.ValidateWebAppInitData(r.Header.Get("X-Init-Data"))
+//	    if err != nil {
+//	        http.Error(rw, "unauthorized", http.StatusUnauthorized)
+//	        return
+//	    }
+//	    placeOrder(r.Context(), data.User.ID, r.Body)
+//	})
+func (w *Wrapper) ValidateWebAppInitData(initData string) (*core.WebAppInitData, error) {
+	return w.bot.ValidateWebAppInitData(initData, w.Config().Bot.WebApp.GetInitDataTTL())
+}
+
+// menuButton returns the configured menu button, or nil if it is left unchanged.
+func menuButton(cfg *config.Config) *config.MenuButtonConfig {
+	if cfg.Bot.WebApp == nil {
+		return nil
+	}
+	return cfg.Bot.WebApp.MenuButton
+}
+
+// applyMenuButton sets the default menu button of private chats from configuration.
+func (w *Wrapper) applyMenuButton(ctx context.Context, button *config.MenuButtonConfig) {
+	if button == nil {
+		return
+	}
+	switch button.Type {
+	case config.MenuButtonWebApp:
+		_ = w.bot.SetWebAppMenuButton(ctx, 0, button.Text, button.URL)
+	case config.MenuButtonCommands:
+		_ = w.bot.SetCommandsMenuButton(ctx, 0)
+	default:
+		_ = w.bot.ResetMenuButton(ctx, 0)
+	}
+}